@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"idento/backend/internal/broker"
+	"idento/backend/internal/config"
+)
+
+// eventBroker is what main needs from the configured broker on top of
+// broker.Broker: a readiness check and an orderly close.
+type eventBroker interface {
+	broker.Broker
+	Health() error
+	Close()
+}
+
+// openBroker connects the broker EVENT_BROKER selects: Postgres
+// LISTEN/NOTIFY on the database (the default), or Redis pub/sub.
+func openBroker(ctx context.Context, cfg *config.Config) (eventBroker, error) {
+	if cfg.EventBroker == config.BrokerRedis {
+		log.Println("Event broker: Redis pub/sub")
+		return broker.NewRedisBroker(ctx, cfg.RedisURL)
+	}
+	log.Println("Event broker: Postgres LISTEN/NOTIFY")
+	return broker.NewPGBroker(ctx, cfg.DatabaseURL)
+}
@@ -0,0 +1,38 @@
+// Command mock_idp runs a local OpenID provider for trying single sign-on
+// without a real identity provider. It signs in one fixed user without
+// asking. Configure a tenant with:
+//
+//	PUT /api/sso {"issuer":"http://localhost:9000","client_id":"idento",
+//	              "client_secret":"secret","allowed_domains":["example.com"],
+//	              "role_claim":"groups","role_mapping":{"admins":"admin"}}
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+
+	"idento/backend/internal/oidc/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:9000", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL (must match how Idento reaches this server)")
+	email := flag.String("email", "user@example.com", "email of the signed-in user")
+	groups := flag.String("groups", "", "comma-separated groups claim")
+	flag.Parse()
+
+	p, err := oidctest.NewProvider(strings.TrimRight(*issuer, "/"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	u := oidctest.User{Subject: "mock-" + *email, Email: *email, EmailVerified: true}
+	if *groups != "" {
+		u.Extra = map[string]interface{}{"groups": strings.Split(*groups, ",")}
+	}
+	p.SetUser(u)
+
+	log.Printf("Mock OpenID provider %s (client %q / %q) signing in %s", p.Issuer, p.ClientID, p.ClientSecret, *email)
+	log.Fatal(http.ListenAndServe(*addr, p))
+}
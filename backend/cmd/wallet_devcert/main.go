@@ -0,0 +1,49 @@
+// Command wallet_devcert writes wallet credentials for trying passes
+// without Apple or Google accounts: a self-signed pass type certificate
+// (pass.p12, password "idento") and a Google service account key
+// (service-account.json). Install them with:
+//
+//	PUT /api/wallet/apple (multipart) certificate=@pass.p12 password=idento
+//	PUT /api/wallet/google {"issuer_id":"3388000000000000000",
+//	                        "service_account":<service-account.json>}
+//
+// Passes signed this way open in tools that check the signature against
+// the certificate, not on a real device, which only trusts Apple's.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"idento/backend/internal/wallet/wallettest"
+)
+
+func main() {
+	dir := flag.String("out", ".", "output directory")
+	passType := flag.String("pass-type", "pass.com.example.idento", "pass type identifier")
+	team := flag.String("team", "DEVTEAM000", "team identifier")
+	email := flag.String("email", "wallet@idento-dev.iam.gserviceaccount.com", "service account email")
+	flag.Parse()
+
+	cert, err := wallettest.NewCertificate(*passType, *team, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p12, err := cert.PKCS12("idento")
+	if err != nil {
+		log.Fatal(err)
+	}
+	account, _, err := wallettest.NewServiceAccount(*email, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for name, data := range map[string][]byte{"pass.p12": p12, "service-account.json": account} {
+		path := filepath.Join(*dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Wrote %s", path)
+	}
+}
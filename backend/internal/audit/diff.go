@@ -0,0 +1,87 @@
+// Package audit computes the field-level before/after diffs stored in the
+// tenant audit log, so an entry says exactly which fields a change touched
+// ("custom_fields.category": "Standard" -> "VIP") instead of carrying two
+// whole snapshots.
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// Change is one field's value before and after; nil on a side where the
+// field was absent.
+type Change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Diff compares before and after by their JSON encodings and returns the
+// fields that differ. Nested objects are descended into, with dotted keys
+// ("custom_fields.category"); arrays and scalars compare whole. A nil
+// before (creation) or after (deletion) diffs against an empty object.
+// ignore names top-level keys to leave out, such as updated_at.
+func Diff(before, after interface{}, ignore ...string) (map[string]Change, error) {
+	a, err := toObject(before)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toObject(after)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range ignore {
+		delete(a, k)
+		delete(b, k)
+	}
+	out := map[string]Change{}
+	walk("", a, b, out)
+	return out, nil
+}
+
+func toObject(v interface{}) (map[string]interface{}, error) {
+	obj := map[string]interface{}{}
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return obj, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		obj = map[string]interface{}{}
+	}
+	return obj, nil
+}
+
+func walk(prefix string, a, b map[string]interface{}, out map[string]Change) {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		va, vb := a[k], b[k]
+		na, aObj := va.(map[string]interface{})
+		nb, bObj := vb.(map[string]interface{})
+		switch {
+		case aObj && bObj:
+			walk(prefix+k+".", na, nb, out)
+		case aObj && vb == nil:
+			walk(prefix+k+".", na, map[string]interface{}{}, out)
+		case bObj && va == nil:
+			walk(prefix+k+".", map[string]interface{}{}, nb, out)
+		case !reflect.DeepEqual(va, vb):
+			out[prefix+k] = Change{From: va, To: vb}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+)
+
+type person struct {
+	Name    string                 `json:"name"`
+	Blocked bool                   `json:"blocked"`
+	Reason  *string                `json:"reason,omitempty"`
+	Tags    []string               `json:"tags"`
+	Custom  map[string]interface{} `json:"custom_fields,omitempty"`
+	Updated string                 `json:"updated_at"`
+}
+
+func TestDiffReportsOnlyChangedFieldsWithDottedNestedKeys(t *testing.T) {
+	reason := "duplicate badge"
+	before := &person{Name: "Ann", Tags: []string{"a"}, Custom: map[string]interface{}{"category": "Standard", "seat": "12"}, Updated: "mon"}
+	after := &person{Name: "Ann", Blocked: true, Reason: &reason, Tags: []string{"a", "b"}, Custom: map[string]interface{}{"category": "VIP", "seat": "12"}, Updated: "tue"}
+
+	got, err := Diff(before, after, "updated_at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Change{
+		"blocked":                {From: false, To: true},
+		"reason":                 {From: nil, To: "duplicate badge"},
+		"tags":                   {From: []interface{}{"a"}, To: []interface{}{"a", "b"}},
+		"custom_fields.category": {From: "Standard", To: "VIP"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Diff = %#v\nwant %#v", got, want)
+	}
+}
+
+func TestDiffAgainstNothing(t *testing.T) {
+	p := &person{Name: "Ann", Custom: map[string]interface{}{"category": "VIP"}}
+
+	deleted, err := Diff(p, (*person)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted["name"] != (Change{From: "Ann", To: nil}) || deleted["custom_fields.category"] != (Change{From: "VIP", To: nil}) {
+		t.Errorf("deletion diff = %#v", deleted)
+	}
+
+	same, err := Diff(p, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(same) != 0 {
+		t.Errorf("Diff of identical values = %#v, want empty", same)
+	}
+}
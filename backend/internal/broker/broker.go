@@ -1,14 +1,16 @@
 // Package broker provides the event-fanout infrastructure backing the
 // P4.2 live monitor's SSE stream — the codebase's first pub/sub seam. Its
-// entire job is: let a Publish(eventID) call wake up every goroutine
-// currently Subscribed to that event, without ever blocking the publisher.
+// entire job is: let a Publish(msg) call wake up every goroutine currently
+// Subscribed to msg.Key, without ever blocking the publisher.
 //
 // This package deliberately does not import the store package (and must
 // never be imported BY it either) — it knows nothing about attendees,
-// check-ins, tenants, or Postgres schema beyond a bare event UUID. That
+// check-ins, tenants, or Postgres schema beyond the IDs a Message carries
+// (message.go). That
 // keeps the seam reusable and testable in isolation: MemBroker needs no
-// database at all, and PGBroker's Postgres-specific logic is confined to
-// pg_broker.go.
+// database at all, PGBroker's Postgres-specific logic is confined to
+// pg_broker.go, and RedisBroker's to redis_broker.go. All three pass the
+// same conformance suite (conformance_test.go).
 package broker
 
 import (
@@ -20,11 +22,11 @@ import (
 // Broker is the seam the P4.2 monitor SSE handler (and check-in/undo/
 // reprint/heartbeat publish sites) depend on.
 type Broker interface {
-	// Publish signals that eventID's monitor-visible state has changed.
+	// Publish signals that msg.Key's state has changed, as msg describes.
 	// Implementations must never block on a slow or absent subscriber.
-	Publish(ctx context.Context, eventID uuid.UUID) error
+	Publish(ctx context.Context, msg Message) error
 
-	// Subscribe registers interest in eventID's changes. The returned
+	// Subscribe registers interest in key's changes. The returned
 	// channel is 1-buffered: a pending signal coalesces with any later
 	// Publish while it remains unread (drop-if-full) — a slow consumer
 	// therefore never blocks the fanout and never accumulates an unbounded
@@ -39,5 +41,12 @@ type Broker interface {
 	// alongside at least one other case (a request context's Done() and/or
 	// a keep-alive ticker, as the P4.2 SSE handler does) and never `range`
 	// over it, which would block forever instead of observing shutdown.
-	Subscribe(eventID uuid.UUID) (<-chan struct{}, func())
+	Subscribe(key uuid.UUID) (<-chan struct{}, func())
+
+	// SubscribeMessages is Subscribe for consumers that want the messages
+	// themselves, not just the signal: the Subscription's C follows the
+	// same coalescing, never-closed contract, and Drain hands over
+	// everything published since the last call — or reports that some of
+	// it was lost and the consumer must resync.
+	SubscribeMessages(key uuid.UUID) (*Subscription, func())
 }
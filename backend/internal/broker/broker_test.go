@@ -2,6 +2,7 @@ package broker
 
 import (
 	"context"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -23,7 +24,7 @@ func TestMemBroker_PublishOnlyDeliversToItsOwnEvent(t *testing.T) {
 	chB, unsubB := b.Subscribe(eventB)
 	defer unsubB()
 
-	if err := b.Publish(context.Background(), eventA); err != nil {
+	if err := b.Publish(context.Background(), Changed(eventA)); err != nil {
 		t.Fatalf("Publish: %v", err)
 	}
 
@@ -49,7 +50,7 @@ func TestMemBroker_PublishSignalsAllSubscribersOfSameEvent(t *testing.T) {
 	ch2, unsub2 := b.Subscribe(eventID)
 	defer unsub2()
 
-	if err := b.Publish(context.Background(), eventID); err != nil {
+	if err := b.Publish(context.Background(), Changed(eventID)); err != nil {
 		t.Fatalf("Publish: %v", err)
 	}
 
@@ -65,7 +66,7 @@ func TestMemBroker_PublishSignalsAllSubscribersOfSameEvent(t *testing.T) {
 func TestMemBroker_PublishWithNoSubscribersIsNoop(t *testing.T) {
 	b := NewMemBroker()
 
-	if err := b.Publish(context.Background(), uuid.New()); err != nil {
+	if err := b.Publish(context.Background(), Changed(uuid.New())); err != nil {
 		t.Fatalf("Publish to an event with no subscribers should be a no-op, got error: %v", err)
 	}
 }
@@ -79,7 +80,7 @@ func TestMemBroker_UnsubscribeStopsDelivery(t *testing.T) {
 	ch, unsubscribe := b.Subscribe(eventID)
 	unsubscribe()
 
-	if err := b.Publish(context.Background(), eventID); err != nil {
+	if err := b.Publish(context.Background(), Changed(eventID)); err != nil {
 		t.Fatalf("Publish: %v", err)
 	}
 
@@ -127,7 +128,7 @@ func TestMemBroker_UnsubscribeOneLeavesOthersOfSameEventIntact(t *testing.T) {
 
 	unsub1()
 
-	if err := b.Publish(context.Background(), eventID); err != nil {
+	if err := b.Publish(context.Background(), Changed(eventID)); err != nil {
 		t.Fatalf("Publish: %v", err)
 	}
 
@@ -162,7 +163,7 @@ func TestMemBroker_PublishNeverBlocksOnFullChannel(t *testing.T) {
 	go func() {
 		defer close(done)
 		for i := 0; i < publishes; i++ {
-			if err := b.Publish(context.Background(), eventID); err != nil {
+			if err := b.Publish(context.Background(), Changed(eventID)); err != nil {
 				t.Errorf("Publish %d: %v", i, err)
 			}
 		}
@@ -222,7 +223,7 @@ func TestMemBroker_ConcurrentSubscribePublishUnsubscribe(t *testing.T) {
 			defer wg.Done()
 			for i := 0; i < iterations; i++ {
 				eventID := eventIDs[(worker+i)%len(eventIDs)]
-				if err := b.Publish(context.Background(), eventID); err != nil {
+				if err := b.Publish(context.Background(), Changed(eventID)); err != nil {
 					t.Errorf("Publish: %v", err)
 				}
 			}
@@ -306,7 +307,7 @@ func TestMemBroker_BroadcastAllCoalescesWithPendingSignal(t *testing.T) {
 	ch, unsubscribe := b.Subscribe(eventID)
 	defer unsubscribe()
 
-	if err := b.Publish(context.Background(), eventID); err != nil {
+	if err := b.Publish(context.Background(), Changed(eventID)); err != nil {
 		t.Fatalf("Publish: %v", err)
 	}
 
@@ -353,6 +354,54 @@ func TestMemBroker_BroadcastAllDoesNotDeliverToUnsubscribed(t *testing.T) {
 	}
 }
 
+// --- Message subscriptions -----------------------------------------------
+
+func TestSubscribeMessages_OverflowDropsBacklogAndReportsLost(t *testing.T) {
+	b := NewMemBroker()
+	eventID := uuid.New()
+	sub, unsubscribe := b.SubscribeMessages(eventID)
+	defer unsubscribe()
+
+	for i := 0; i <= maxQueuedMessages; i++ {
+		if err := b.Publish(context.Background(), Changed(eventID)); err != nil {
+			t.Fatalf("Publish %d: %v", i, err)
+		}
+	}
+	if msgs, lost := sub.Drain(); !lost || len(msgs) != 0 {
+		t.Fatalf("Drain after overflow = %d messages, lost=%v; want none, lost", len(msgs), lost)
+	}
+
+	if err := b.Publish(context.Background(), Changed(eventID)); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if msgs, lost := sub.Drain(); lost || len(msgs) != 1 {
+		t.Fatalf("Drain after resync = %d messages, lost=%v; want 1, not lost", len(msgs), lost)
+	}
+}
+
+// BroadcastAll follows a transport reconnect: whatever was published
+// during the gap is gone, so message subscribers must resync.
+func TestBroadcastAll_MarksMessageSubscriptionsLost(t *testing.T) {
+	b := NewMemBroker()
+	eventID := uuid.New()
+	sub, unsubscribe := b.SubscribeMessages(eventID)
+	defer unsubscribe()
+	if err := b.Publish(context.Background(), Changed(eventID)); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	b.BroadcastAll()
+
+	select {
+	case <-sub.C:
+	default:
+		t.Fatal("BroadcastAll did not signal the message subscription")
+	}
+	if msgs, lost := sub.Drain(); !lost || len(msgs) != 0 {
+		t.Fatalf("Drain = %d messages, lost=%v; want none, lost", len(msgs), lost)
+	}
+}
+
 // --- Broker interface compliance -----------------------------------------
 
 func TestMemBroker_SatisfiesBrokerInterface(t *testing.T) {
@@ -368,7 +417,7 @@ func TestHandleNotification_ValidUUIDForwardsToFanout(t *testing.T) {
 	ch, unsubscribe := mem.Subscribe(eventID)
 	defer unsubscribe()
 
-	handleNotification(mem, eventID.String())
+	handleNotification(mem, pgTransport, eventID.String())
 
 	select {
 	case <-ch:
@@ -377,6 +426,34 @@ func TestHandleNotification_ValidUUIDForwardsToFanout(t *testing.T) {
 	}
 }
 
+// A bare UUID is what replicas published before messages were typed; a
+// rolling deploy mixes both payloads on the same channel.
+func TestHandleNotification_DecodesMessageAndBareUUID(t *testing.T) {
+	mem := NewMemBroker()
+	eventID, attendeeID := uuid.New(), uuid.New()
+
+	sub, unsubscribe := mem.SubscribeMessages(eventID)
+	defer unsubscribe()
+
+	payload, err := encodeMessage(Message{Key: eventID, Kind: KindCheckin, AttendeeID: &attendeeID})
+	if err != nil {
+		t.Fatalf("encodeMessage: %v", err)
+	}
+	handleNotification(mem, pgTransport, payload)
+	handleNotification(mem, pgTransport, eventID.String())
+
+	msgs, lost := sub.Drain()
+	if lost || len(msgs) != 2 {
+		t.Fatalf("Drain = %+v, lost=%v; want 2 messages", msgs, lost)
+	}
+	if msgs[0].Kind != KindCheckin || msgs[0].AttendeeID == nil || *msgs[0].AttendeeID != attendeeID {
+		t.Errorf("typed payload decoded as %+v", msgs[0])
+	}
+	if msgs[1] != Changed(eventID) {
+		t.Errorf("bare UUID payload decoded as %+v, want Changed", msgs[1])
+	}
+}
+
 func TestHandleNotification_MalformedPayloadIsLoggedAndSkipped(t *testing.T) {
 	mem := NewMemBroker()
 	eventID := uuid.New()
@@ -386,7 +463,7 @@ func TestHandleNotification_MalformedPayloadIsLoggedAndSkipped(t *testing.T) {
 
 	// Garbage payload must not panic, must not forward anything to ANY
 	// subscriber (there's no valid event to attribute it to).
-	handleNotification(mem, "not-a-uuid")
+	handleNotification(mem, pgTransport, "not-a-uuid")
 
 	select {
 	case <-ch:
@@ -402,7 +479,7 @@ func TestHandleNotification_EmptyPayloadIsLoggedAndSkipped(t *testing.T) {
 	ch, unsubscribe := mem.Subscribe(eventID)
 	defer unsubscribe()
 
-	handleNotification(mem, "")
+	handleNotification(mem, pgTransport, "")
 
 	select {
 	case <-ch:
@@ -568,3 +645,29 @@ func TestHandleReconnectSuccess_BroadcastsToAllCurrentSubscribers(t *testing.T)
 		t.Fatal("expected eventB subscriber to receive a signal after a successful reconnect")
 	}
 }
+
+// --- PGBroker: LISTEN health -------------------------------------------
+
+func TestListenState_ReportsLossUntilRestoredAndStop(t *testing.T) {
+	var s listenState
+	now := time.Now()
+	if err := s.err(now); err != nil {
+		t.Fatalf("zero listenState should be healthy, got %v", err)
+	}
+
+	s.lost(now)
+	err := s.err(now.Add(42 * time.Second))
+	if err == nil || !strings.Contains(err.Error(), "42s") {
+		t.Fatalf("expected a reconnecting error naming the outage length, got %v", err)
+	}
+
+	s.restored()
+	if err := s.err(now); err != nil {
+		t.Fatalf("expected healthy after restore, got %v", err)
+	}
+
+	s.stop()
+	if err := s.err(now); err == nil {
+		t.Fatal("expected an error once the listen loop stopped")
+	}
+}
@@ -9,80 +9,87 @@ import (
 
 var _ Broker = (*MemBroker)(nil)
 
-// MemBroker is a pure in-process Broker: Publish fans a signal out to
-// every channel currently Subscribed to that event, entirely in memory
-// (map[uuid.UUID][]chan struct{} guarded by a mutex). It is used directly
-// by every handler test that needs a Broker, and PGBroker wraps one to do
-// its local, in-process delivery once a Postgres NOTIFY has round-tripped
-// back to this process.
+// MemBroker is a pure in-process Broker: Publish fans a message out to
+// every Subscription currently registered for its key, entirely in memory
+// (map[uuid.UUID]map[*Subscription]struct{} guarded by a mutex). It is used
+// directly by every handler test that needs a Broker, and PGBroker and
+// RedisBroker wrap one to do their local, in-process delivery once a
+// message has round-tripped back to this process.
 type MemBroker struct {
 	mu   sync.Mutex
-	subs map[uuid.UUID]map[chan struct{}]struct{}
+	subs map[uuid.UUID]map[*Subscription]struct{}
 }
 
 // NewMemBroker constructs an empty MemBroker.
 func NewMemBroker() *MemBroker {
 	return &MemBroker{
-		subs: make(map[uuid.UUID]map[chan struct{}]struct{}),
+		subs: make(map[uuid.UUID]map[*Subscription]struct{}),
 	}
 }
 
-// Publish signals every current subscriber of eventID. Delivery is
-// drop-if-full: a subscriber whose 1-buffered channel already holds an
-// unread signal simply coalesces — Publish never blocks, never spawns a
-// goroutine, and never queues beyond that single buffered slot. Publishing
-// to an event with no subscribers is a no-op. Publish itself never fails
+// Publish delivers msg to every current subscriber of msg.Key. Signal
+// delivery is drop-if-full: a subscriber whose 1-buffered channel already
+// holds an unread signal simply coalesces, and a message subscription's
+// queue is bounded (see Subscription.Drain) — Publish never blocks, never
+// spawns a goroutine, and never grows a backlog without bound. Publishing
+// to a key with no subscribers is a no-op. Publish itself never fails
 // (the error return exists solely to satisfy Broker — PGBroker's Publish
 // can fail on the network).
-func (b *MemBroker) Publish(_ context.Context, eventID uuid.UUID) error {
+func (b *MemBroker) Publish(_ context.Context, msg Message) error {
+	if msg.Kind == "" {
+		msg.Kind = KindChanged
+	}
+
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	for ch := range b.subs[eventID] {
-		select {
-		case ch <- struct{}{}:
-		default:
-			// Already has a pending, unread signal — coalesce.
-		}
+	for sub := range b.subs[msg.Key] {
+		sub.deliver(msg)
 	}
 	return nil
 }
 
-// BroadcastAll signals EVERY current subscriber across ALL events — unlike
-// Publish, which is scoped to one eventID. It exists for PGBroker's
+// BroadcastAll signals EVERY current subscriber across ALL keys — unlike
+// Publish, which is scoped to one key. It exists for PGBroker's
 // post-reconnect resync (Finding B1, PR #81 bot-review round): NOTIFYs sent
 // while the LISTEN connection was down are permanently lost (Postgres does
 // not replay them), so once a fresh LISTEN is established there is no way
 // to know which specific event(s) changed during the gap — the only correct
 // recovery is to nudge every current subscriber to re-fetch via its normal
-// update path, regardless of event. Same drop-if-full, never-blocks
-// semantics as Publish (see its doc comment), just applied across the whole
-// fanout map in one pass instead of one event's subscriber set.
+// update path, regardless of event. Message subscriptions are additionally
+// marked lost (see Subscription.Drain), since whatever they missed can't be
+// replayed either. Same drop-if-full, never-blocks semantics as Publish,
+// just applied across the whole fanout map in one pass.
 func (b *MemBroker) BroadcastAll() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
 	for _, subs := range b.subs {
-		for ch := range subs {
-			select {
-			case ch <- struct{}{}:
-			default:
-				// Already has a pending, unread signal — coalesce.
-			}
+		for sub := range subs {
+			sub.markLost()
 		}
 	}
 }
 
-// Subscribe registers a new 1-buffered channel for eventID. See
+// Subscribe registers a new 1-buffered signal channel for key. See
 // Broker.Subscribe for the coalescing and idempotent-unsubscribe contract.
-func (b *MemBroker) Subscribe(eventID uuid.UUID) (<-chan struct{}, func()) {
-	ch := make(chan struct{}, 1)
+func (b *MemBroker) Subscribe(key uuid.UUID) (<-chan struct{}, func()) {
+	sub, unsubscribe := b.add(key, newSubscription(false))
+	return sub.C, unsubscribe
+}
+
+// SubscribeMessages registers a new message subscription for key. See
+// Broker.SubscribeMessages.
+func (b *MemBroker) SubscribeMessages(key uuid.UUID) (*Subscription, func()) {
+	return b.add(key, newSubscription(true))
+}
 
+func (b *MemBroker) add(key uuid.UUID, sub *Subscription) (*Subscription, func()) {
 	b.mu.Lock()
-	if b.subs[eventID] == nil {
-		b.subs[eventID] = make(map[chan struct{}]struct{})
+	if b.subs[key] == nil {
+		b.subs[key] = make(map[*Subscription]struct{})
 	}
-	b.subs[eventID][ch] = struct{}{}
+	b.subs[key][sub] = struct{}{}
 	b.mu.Unlock()
 
 	var once sync.Once
@@ -90,12 +97,12 @@ func (b *MemBroker) Subscribe(eventID uuid.UUID) (<-chan struct{}, func()) {
 		once.Do(func() {
 			b.mu.Lock()
 			defer b.mu.Unlock()
-			delete(b.subs[eventID], ch)
-			if len(b.subs[eventID]) == 0 {
-				delete(b.subs, eventID)
+			delete(b.subs[key], sub)
+			if len(b.subs[key]) == 0 {
+				delete(b.subs, key)
 			}
 		})
 	}
 
-	return ch, unsubscribe
+	return sub, unsubscribe
 }
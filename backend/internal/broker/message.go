@@ -0,0 +1,175 @@
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kind says what changed behind a Message's key. Consumers that only care
+// THAT something changed (the thin-ping monitor stream, job progress) can
+// ignore it; consumers that patch state incrementally (the monitor's delta
+// stream) switch on it and fall back to a full re-read for KindChanged and
+// for any kind they don't recognise.
+type Kind string
+
+const (
+	// KindChanged carries no detail: re-read everything behind the key.
+	// It is what every mutation without a typed message publishes, and
+	// what a bare-UUID payload from an older replica decodes to.
+	KindChanged Kind = "changed"
+	// KindCheckin: AttendeeID was checked in, at StationID (nil for a
+	// station-less check-in), attributed to ZoneID (nil when that station
+	// has no zone).
+	KindCheckin Kind = "checkin"
+	// KindUndo: AttendeeID's check-in was cleared, at StationID.
+	KindUndo Kind = "undo"
+	// KindReprint: AttendeeID's badge was reprinted, at StationID.
+	KindReprint Kind = "reprint"
+	// KindHeartbeat: StationID reported in at At.
+	KindHeartbeat Kind = "heartbeat"
+
+	// The kinds below travel on an event's station channel (see the
+	// handler's stationChannelKey), where StationID, when set, is the one
+	// provisioned station the message is for and nil means every station.
+
+	// KindAttendee: AttendeeID was changed (blocked, edited, created,
+	// deleted); stations refetch that one record.
+	KindAttendee Kind = "attendee"
+	// KindSettings: the event's check-in settings changed.
+	KindSettings Kind = "settings"
+	// KindBroadcast: Text is an operator's message to show on screen.
+	KindBroadcast Kind = "broadcast"
+	// KindResync: stations drop their cache and run a full sync.
+	KindResync Kind = "resync"
+	// KindLogout: stations sign out locally.
+	KindLogout Kind = "logout"
+)
+
+// Message is one published change. Key is what subscribers subscribe to
+// (an event ID for the monitor, a job ID for job progress); the rest
+// describes the change for consumers that can apply it without a re-read.
+// It is also the wire format: PGBroker and RedisBroker send it as JSON.
+type Message struct {
+	Key        uuid.UUID  `json:"key"`
+	Kind       Kind       `json:"kind"`
+	AttendeeID *uuid.UUID `json:"attendee_id,omitempty"`
+	StationID  *uuid.UUID `json:"station_id,omitempty"`
+	ZoneID     *uuid.UUID `json:"zone_id,omitempty"`
+	At         time.Time  `json:"at,omitzero"`
+	Text       string     `json:"text,omitempty"`
+}
+
+// Changed is the detail-free message for key.
+func Changed(key uuid.UUID) Message {
+	return Message{Key: key, Kind: KindChanged}
+}
+
+// encodeMessage is the wire payload PGBroker and RedisBroker publish.
+func encodeMessage(msg Message) (string, error) {
+	if msg.Kind == "" {
+		msg.Kind = KindChanged
+	}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// decodeMessage parses a wire payload. A bare UUID — what every replica
+// published before messages were typed — still decodes, as Changed, so a
+// rolling deploy never drops a signal in either direction of the mix.
+func decodeMessage(payload string) (Message, error) {
+	if key, err := uuid.Parse(payload); err == nil {
+		return Changed(key), nil
+	}
+	var msg Message
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		return Message{}, err
+	}
+	if msg.Key == uuid.Nil {
+		return Message{}, errors.New("message has no key")
+	}
+	if msg.Kind == "" {
+		msg.Kind = KindChanged
+	}
+	return msg, nil
+}
+
+// maxQueuedMessages bounds a Subscription's backlog. A consumer that falls
+// this far behind has its queue dropped and is told to resync instead.
+const maxQueuedMessages = 256
+
+// Subscription is a message-carrying subscription (Broker.SubscribeMessages).
+// C is the same coalescing, never-closed signal channel Subscribe returns;
+// each signal means "Drain has something for you" — one or more messages,
+// or a lost backlog.
+type Subscription struct {
+	C <-chan struct{}
+
+	ch chan struct{}
+
+	// queued is false for plain Subscribe channels, which only ever get
+	// the signal.
+	queued bool
+
+	mu    sync.Mutex
+	queue []Message
+	lost  bool
+}
+
+func newSubscription(queued bool) *Subscription {
+	ch := make(chan struct{}, 1)
+	return &Subscription{C: ch, ch: ch, queued: queued}
+}
+
+// Drain returns, and clears, every message delivered since the last Drain,
+// oldest first. lost reports that messages were dropped in between — the
+// backlog overflowed maxQueuedMessages, or a PGBroker/RedisBroker
+// subscription reconnected after missing an unknown number of them — so
+// the consumer must re-read its state from scratch rather than apply msgs
+// on top of what it had.
+func (s *Subscription) Drain() (msgs []Message, lost bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msgs, lost = s.queue, s.lost
+	s.queue, s.lost = nil, false
+	return msgs, lost
+}
+
+// deliver queues msg (for a message subscription) and signals C without
+// blocking.
+func (s *Subscription) deliver(msg Message) {
+	if s.queued {
+		s.mu.Lock()
+		if len(s.queue) >= maxQueuedMessages {
+			s.queue, s.lost = nil, true
+		} else if !s.lost {
+			s.queue = append(s.queue, msg)
+		}
+		s.mu.Unlock()
+	}
+	s.signal()
+}
+
+// markLost flags an unknown gap in a message subscription and signals C.
+func (s *Subscription) markLost() {
+	if s.queued {
+		s.mu.Lock()
+		s.queue, s.lost = nil, true
+		s.mu.Unlock()
+	}
+	s.signal()
+}
+
+func (s *Subscription) signal() {
+	select {
+	case s.ch <- struct{}{}:
+	default:
+		// Already has a pending, unread signal — coalesce.
+	}
+}
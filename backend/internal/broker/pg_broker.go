@@ -2,25 +2,35 @@ package broker
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"sync"
 	"time"
 
+	"idento/backend/internal/metrics"
+	"idento/backend/internal/tracing"
+
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // listenStatement/notifyStatement are the exact SQL PGBroker issues on its
 // two connection resources: LISTEN on the dedicated conn, NOTIFY through
-// the small pool. Both sides agree on the same bare-UUID payload
-// convention; see handleNotification.
+// the small pool. Both sides agree on the same JSON Message payload; see
+// encodeMessage and handleNotification.
 const (
 	listenStatement = "LISTEN checkin_events"
 	notifyStatement = "SELECT pg_notify('checkin_events', $1::text)"
 )
 
-// Backoff bounds for the LISTEN connection's reconnect loop: starts at 1s
-// and doubles on each consecutive failure up to a 30s cap.
+// Backoff bounds for the subscription connection's reconnect loop (LISTEN
+// here, SUBSCRIBE in RedisBroker): starts at 1s and doubles on each
+// consecutive failure up to a 30s cap.
 const (
 	reconnectBackoffInitial = 1 * time.Second
 	reconnectBackoffMax     = 30 * time.Second
@@ -57,6 +67,9 @@ type PGBroker struct {
 	// sanitized config the initial connect used, rather than re-deriving
 	// it (or, as before this fix, falling back to a raw dbURL re-parse).
 	connConfig *pgx.ConnConfig
+
+	// listen tracks the LISTEN connection for Health.
+	listen listenState
 }
 
 // NewPGBroker connects a dedicated LISTEN connection and a small (MaxConns
@@ -182,16 +195,86 @@ func prepareListenConnConfig(poolCfg *pgxpool.Config) *pgx.ConnConfig {
 // the notify pool. It never touches the LISTEN connection (which is
 // permanently blocked inside WaitForNotification and cannot also send a
 // query on the same wire).
-func (b *PGBroker) Publish(ctx context.Context, eventID uuid.UUID) error {
-	_, err := b.pool.Exec(ctx, notifyStatement, eventID.String())
-	return err
+func (b *PGBroker) Publish(ctx context.Context, msg Message) error {
+	ctx, span := tracing.Tracer().Start(ctx, "broker.publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(brokerSpanAttributes(pgTransport, msg)...),
+	)
+	payload, err := encodeMessage(msg)
+	if err == nil {
+		_, err = b.pool.Exec(ctx, notifyStatement, payload)
+	}
+	tracing.End(span, err)
+	if err != nil {
+		metrics.BrokerPublishes.WithLabelValues("error").Inc()
+		return err
+	}
+	metrics.BrokerPublishes.WithLabelValues("ok").Inc()
+	return nil
+}
+
+// PoolStat reports the notify pool's connection statistics for /metrics.
+func (b *PGBroker) PoolStat() *pgxpool.Stat {
+	return b.pool.Stat()
 }
 
 // Subscribe delegates directly to the wrapped MemBroker for local,
 // in-process delivery. See Broker.Subscribe for the coalescing /
 // idempotent-unsubscribe contract.
-func (b *PGBroker) Subscribe(eventID uuid.UUID) (<-chan struct{}, func()) {
-	return b.mem.Subscribe(eventID)
+func (b *PGBroker) Subscribe(key uuid.UUID) (<-chan struct{}, func()) {
+	return b.mem.Subscribe(key)
+}
+
+// SubscribeMessages delegates to the wrapped MemBroker, like Subscribe.
+func (b *PGBroker) SubscribeMessages(key uuid.UUID) (*Subscription, func()) {
+	return b.mem.SubscribeMessages(key)
+}
+
+// Health reports whether the LISTEN connection is up. While listenLoop is
+// in its reconnect backoff, Publishes from other replicas are not reaching
+// this one's subscribers, so the readiness probe fails.
+func (b *PGBroker) Health() error {
+	return b.listen.err(time.Now())
+}
+
+// listenState is the subscription connection's health as the receive loop
+// (PGBroker's LISTEN, RedisBroker's SUBSCRIBE) last saw it. The zero value
+// is a live connection: both constructors only start their loop after the
+// initial subscription succeeded.
+type listenState struct {
+	mu        sync.Mutex
+	downSince time.Time
+	stopped   bool
+}
+
+func (s *listenState) lost(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.downSince = now
+}
+
+func (s *listenState) restored() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.downSince = time.Time{}
+}
+
+func (s *listenState) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+}
+
+func (s *listenState) err(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.stopped:
+		return errors.New("broker closed")
+	case !s.downSince.IsZero():
+		return fmt.Errorf("subscription connection lost %s ago, reconnecting", now.Sub(s.downSince).Round(time.Second))
+	}
+	return nil
 }
 
 // Close stops the LISTEN loop (via context cancellation), waits for it to
@@ -224,6 +307,7 @@ func (b *PGBroker) Close() {
 func (b *PGBroker) listenLoop(ctx context.Context, conn *pgx.Conn) {
 	defer close(b.done)
 	defer func() { closeConn(conn) }()
+	defer b.listen.stop()
 
 	for {
 		notification, err := conn.WaitForNotification(ctx)
@@ -234,6 +318,7 @@ func (b *PGBroker) listenLoop(ctx context.Context, conn *pgx.Conn) {
 			}
 
 			log.Printf("broker: LISTEN connection error, reconnecting: %v", err)
+			b.listen.lost(time.Now())
 			closeConn(conn)
 
 			var ok bool
@@ -251,11 +336,13 @@ func (b *PGBroker) listenLoop(ctx context.Context, conn *pgx.Conn) {
 			// client gets nudged to re-fetch a snapshot via its normal
 			// update path, so it can never stay silently stale for an
 			// unbounded time waiting on some later, unrelated publish.
+			metrics.BrokerReconnects.Inc()
+			b.listen.restored()
 			handleReconnectSuccess(b.mem)
 			continue
 		}
 
-		handleNotification(b.mem, notification.Payload)
+		handleNotification(b.mem, pgTransport, notification.Payload)
 	}
 }
 
@@ -323,23 +410,50 @@ func handleReconnectSuccess(mem *MemBroker) {
 	mem.BroadcastAll()
 }
 
-// handleNotification parses payload as a uuid.UUID and forwards it into
+// handleNotification decodes payload (decodeMessage) and forwards it into
 // mem's fanout. It is factored out of listenLoop specifically so it's
 // unit-testable without a live Postgres connection — see listenLoop's doc
-// comment for why the loop itself isn't. A malformed (non-UUID, including
+// comment for why the loop itself isn't. A malformed (neither a UUID nor a Message, including
 // empty) payload is logged and skipped, never propagated as an error or a
 // panic: one bad NOTIFY payload must not take down the loop.
-func handleNotification(mem *MemBroker, payload string) {
-	eventID, err := uuid.Parse(payload)
+func handleNotification(mem *MemBroker, tr transport, payload string) {
+	msg, err := decodeMessage(payload)
 	if err != nil {
 		log.Printf("broker: skipping malformed notification payload %q: %v", payload, err)
 		return
 	}
 
+	// The payload carries no trace context, so each receive is
+	// the root of its own trace rather than a child of the publish.
+	_, span := tracing.Tracer().Start(context.Background(), "broker.receive",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(brokerSpanAttributes(tr, msg)...),
+	)
+	defer span.End()
+
 	// MemBroker.Publish never actually returns a non-nil error today (see
 	// its doc comment); checked here defensively so a future change can't
 	// silently drop a forwarded signal without at least being logged.
-	if err := mem.Publish(context.Background(), eventID); err != nil {
-		log.Printf("broker: local fanout publish failed for %s: %v", eventID, err)
+	if err := mem.Publish(context.Background(), msg); err != nil {
+		log.Printf("broker: local fanout publish failed for %s: %v", msg.Key, err)
+	}
+}
+
+// transport names a broker's wire for span attributes: the messaging
+// system and the channel every event's signals travel on.
+type transport struct {
+	system  string
+	channel string
+}
+
+var pgTransport = transport{system: "postgresql", channel: "checkin_events"}
+
+// brokerSpanAttributes describes a publish or receive of msg.
+func brokerSpanAttributes(tr transport, msg Message) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.MessagingSystemKey.String(tr.system),
+		semconv.MessagingDestinationName(tr.channel),
+		attribute.String("idento.broker.key", msg.Key.String()),
+		attribute.String("idento.broker.kind", string(msg.Kind)),
 	}
 }
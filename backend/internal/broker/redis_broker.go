@@ -0,0 +1,197 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"time"
+
+	"idento/backend/internal/metrics"
+	"idento/backend/internal/tracing"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// redisTransport is RedisBroker's wire. Redis pub/sub channels ignore the
+// database number, so the channel carries a prefix instead: a Redis shared
+// with other applications never crosses their signals with ours.
+var redisTransport = transport{system: "redis", channel: "idento:checkin_events"}
+
+// redisPingInterval is how long the receive loop waits for a message before
+// pinging: a half-open subscription connection (a Redis failover, a NAT
+// dropping an idle flow) otherwise looks exactly like a quiet event.
+const redisPingInterval = 30 * time.Second
+
+var _ Broker = (*RedisBroker)(nil)
+
+// RedisBroker is a Broker backed by Redis pub/sub, for deployments that
+// would rather keep a NOTIFY per check-in off the Postgres primary. Its
+// shape is PGBroker's: Publish sends the JSON Message to one channel,
+// and a single subscription connection forwards every message it receives
+// — including this process's own — into a wrapped MemBroker, so the
+// coalescing, never-block Subscribe contract is MemBroker's.
+//
+// go-redis re-dials and re-subscribes a dropped subscription connection on
+// its own; receiveLoop only paces retries while Redis is unreachable and,
+// like PGBroker, nudges every local subscriber once the subscription is
+// back, since messages published during the gap are lost.
+type RedisBroker struct {
+	mem    *MemBroker
+	client *redis.Client
+	pubsub *redis.PubSub
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// listen tracks the subscription connection for Health.
+	listen listenState
+}
+
+// NewRedisBroker connects to redisURL (redis://[user:password@]host:port/db,
+// or rediss:// for TLS), subscribes, starts the background forwarding
+// loop, and returns. Like NewPGBroker it does not retry the initial
+// connection: failing at boot is the caller's decision.
+func NewRedisBroker(ctx context.Context, redisURL string) (*RedisBroker, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+
+	// Subscribe only queues the command; the confirmation read here proves
+	// the subscription is live before any Subscriber could miss a message.
+	pubsub := client.Subscribe(ctx, redisTransport.channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		_ = client.Close()
+		return nil, err
+	}
+
+	// As in NewPGBroker, the loop's lifetime is governed by Close, not ctx.
+	loopCtx, cancel := context.WithCancel(context.Background())
+	b := &RedisBroker{
+		mem:    NewMemBroker(),
+		client: client,
+		pubsub: pubsub,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go b.receiveLoop(loopCtx)
+	return b, nil
+}
+
+// Publish sends msg to the channel. Delivery to this replica's own
+// subscribers also goes through Redis, exactly as PGBroker's does through
+// Postgres.
+func (b *RedisBroker) Publish(ctx context.Context, msg Message) error {
+	ctx, span := tracing.Tracer().Start(ctx, "broker.publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(brokerSpanAttributes(redisTransport, msg)...),
+	)
+	payload, err := encodeMessage(msg)
+	if err == nil {
+		err = b.client.Publish(ctx, redisTransport.channel, payload).Err()
+	}
+	tracing.End(span, err)
+	if err != nil {
+		metrics.BrokerPublishes.WithLabelValues("error").Inc()
+		return err
+	}
+	metrics.BrokerPublishes.WithLabelValues("ok").Inc()
+	return nil
+}
+
+// Subscribe delegates to the wrapped MemBroker. See Broker.Subscribe for
+// the coalescing / idempotent-unsubscribe contract.
+func (b *RedisBroker) Subscribe(key uuid.UUID) (<-chan struct{}, func()) {
+	return b.mem.Subscribe(key)
+}
+
+// SubscribeMessages delegates to the wrapped MemBroker, like Subscribe.
+func (b *RedisBroker) SubscribeMessages(key uuid.UUID) (*Subscription, func()) {
+	return b.mem.SubscribeMessages(key)
+}
+
+// Health reports whether the subscription connection is up; see
+// PGBroker.Health.
+func (b *RedisBroker) Health() error {
+	return b.listen.err(time.Now())
+}
+
+// Close stops the receive loop, waits for it to exit, and closes the
+// client. Closing the PubSub is what unblocks a pending receive: go-redis
+// reads do not observe context cancellation. Safe to call again, like
+// PGBroker.Close.
+func (b *RedisBroker) Close() {
+	b.cancel()
+	_ = b.pubsub.Close()
+	<-b.done
+	_ = b.client.Close()
+}
+
+// receiveLoop forwards messages into the MemBroker until Close. A receive
+// that times out pings instead, so a dead connection surfaces as an error
+// within redisPingInterval. After an error go-redis re-dials on the next
+// receive; the loop only backs off (1s, doubling to a 30s cap) between
+// attempts. A subscription confirmation after the initial one (consumed by
+// NewRedisBroker) means go-redis re-subscribed on a fresh connection.
+func (b *RedisBroker) receiveLoop(ctx context.Context) {
+	defer close(b.done)
+	defer b.listen.stop()
+
+	backoff := reconnectBackoffInitial
+	for {
+		msg, err := b.pubsub.ReceiveTimeout(ctx, redisPingInterval)
+		if ctx.Err() != nil {
+			// Close() was called; shut down cleanly.
+			return
+		}
+		if err != nil {
+			if isTimeout(err) {
+				if err := b.pubsub.Ping(ctx); err != nil {
+					log.Printf("broker: Redis ping failed, reconnecting: %v", err)
+					b.listen.lost(time.Now())
+				}
+				continue
+			}
+			log.Printf("broker: Redis subscription error, reconnecting: %v", err)
+			b.listen.lost(time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = nextBackoff(backoff)
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Message:
+			handleNotification(b.mem, redisTransport, msg.Payload)
+		case *redis.Subscription:
+			if msg.Kind != "subscribe" {
+				continue
+			}
+			// Same recovery as PGBroker after a re-LISTEN (Finding B1):
+			// whatever was published during the gap is gone.
+			log.Println("broker: Redis subscription re-established")
+			backoff = reconnectBackoffInitial
+			metrics.BrokerReconnects.Inc()
+			b.listen.restored()
+			handleReconnectSuccess(b.mem)
+		}
+	}
+}
+
+// isTimeout reports whether err is a read deadline expiring, which on a
+// subscription connection just means nothing was published.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
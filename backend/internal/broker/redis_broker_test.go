@@ -0,0 +1,97 @@
+package broker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+)
+
+// newTestRedisBroker starts a RedisBroker against mr, closed with the test.
+func newTestRedisBroker(t *testing.T, mr *miniredis.Miniredis) *RedisBroker {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	b, err := NewRedisBroker(ctx, "redis://"+mr.Addr()+"/0")
+	if err != nil {
+		t.Fatalf("NewRedisBroker: %v", err)
+	}
+	t.Cleanup(b.Close)
+	return b
+}
+
+func TestRedisBroker_Conformance(t *testing.T) {
+	testBrokerConformance(t, func(t *testing.T) Broker {
+		return newTestRedisBroker(t, miniredis.RunT(t))
+	})
+}
+
+// TestRedisBroker_PublishReachesOtherReplica is the point of a networked
+// broker: a check-in on one replica updates monitors attached to another.
+func TestRedisBroker_PublishReachesOtherReplica(t *testing.T) {
+	mr := miniredis.RunT(t)
+	publisher, subscriber := newTestRedisBroker(t, mr), newTestRedisBroker(t, mr)
+	event := uuid.New()
+	ch, unsubscribe := subscriber.Subscribe(event)
+	defer unsubscribe()
+
+	publish(t, publisher, event)
+	waitSignal(t, ch, "subscriber on the other replica")
+}
+
+func TestNewRedisBroker_FailsWhenUnreachable(t *testing.T) {
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	mr.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if b, err := NewRedisBroker(ctx, "redis://"+addr+"/0?max_retries=-1"); err == nil {
+		b.Close()
+		t.Fatal("NewRedisBroker succeeded against a stopped server")
+	}
+	if _, err := NewRedisBroker(ctx, "not a url"); err == nil {
+		t.Fatal("NewRedisBroker accepted a malformed URL")
+	}
+}
+
+// TestRedisBroker_ReconnectReportsHealthAndResyncsSubscribers covers the
+// recovery PGBroker can only unit-test in pieces: while Redis is gone Health
+// fails, and once the subscription is back every local subscriber gets one
+// signal to re-fetch what it may have missed.
+func TestRedisBroker_ReconnectReportsHealthAndResyncsSubscribers(t *testing.T) {
+	mr := miniredis.RunT(t)
+	b := newTestRedisBroker(t, mr)
+	ch, unsubscribe := b.Subscribe(uuid.New())
+	defer unsubscribe()
+	if err := b.Health(); err != nil {
+		t.Fatalf("Health before outage: %v", err)
+	}
+
+	mr.Close()
+	eventually(t, "Health to report the outage", func() bool { return b.Health() != nil })
+
+	if err := mr.Restart(); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	waitSignal(t, ch, "resync signal after reconnect")
+	eventually(t, "Health to recover", func() bool { return b.Health() == nil })
+
+	b.Close()
+	if err := b.Health(); err == nil {
+		t.Fatal("Health after Close = nil, want an error")
+	}
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(signalTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
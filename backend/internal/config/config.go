@@ -7,6 +7,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Deployment modes. OnPrem is the default: a binary running outside our
@@ -29,21 +30,133 @@ type Config struct {
 	// TenantRetentionDays is how long an archived tenant is kept before the
 	// purge job deletes it permanently. 0 disables auto-purge.
 	TenantRetentionDays int
+	// IdempotencyRetentionHours is how long a stored Idempotency-Key
+	// response is replayed (and kept) before the key may be reused.
+	IdempotencyRetentionHours int
+	// JobWorkers is how many background-job workers this replica runs;
+	// 0 makes it enqueue-only.
+	JobWorkers int
+	// JobRetentionDays is how long a finished background job (and its
+	// artifact) is kept.
+	JobRetentionDays int
+	// JobArtifactMaxMB caps a background job's download file (an async
+	// export, ticket zip or badge batch); a job whose file would grow past
+	// it fails and says so.
+	JobArtifactMaxMB int
+	// ShutdownTimeoutSeconds bounds the orderly shutdown on SIGTERM:
+	// in-flight requests and interrupted jobs get this long before the
+	// process exits anyway. Keep it under the orchestrator's grace period.
+	ShutdownTimeoutSeconds int
+	// AccessTokenTTLMinutes is the lifetime of an access JWT; clients renew
+	// it with their refresh token.
+	AccessTokenTTLMinutes int
+	// RefreshTokenTTLDays is how long an unused refresh token stays valid.
+	// Every refresh rotates it and starts the period again.
+	RefreshTokenTTLDays int
+	// SMTP relay for transactional email. An empty SMTPHost disables
+	// sending: messages are logged and dropped.
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	// AppURL is the web app's base URL, used to build the links in emails.
+	AppURL string
+	// PublicAPIURL is the base URL browsers reach this API at; single
+	// sign-on redirects back to it.
+	PublicAPIURL string
+	// MetricsToken, when set, is the bearer token /metrics requires. SaaS
+	// deployments only serve /metrics when it is set.
+	MetricsToken string
+	// TracingExporter is where OpenTelemetry spans go: TracingNone (the
+	// default) disables tracing, TracingStdout prints them, TracingOTLP
+	// sends them over OTLP/HTTP to TracingOTLPEndpoint.
+	TracingExporter     string
+	TracingOTLPEndpoint string
+	// TracingSampleRatio is the fraction of new traces sampled, in [0, 1].
+	// Requests carrying a sampled traceparent are always sampled.
+	TracingSampleRatio float64
+	// EventBroker picks the live-update transport between replicas:
+	// BrokerPostgres (the default, LISTEN/NOTIFY on DatabaseURL) or
+	// BrokerRedis (pub/sub on RedisURL, which is then required).
+	EventBroker string
+	RedisURL    string
 }
 
+// Idempotency-Key defaults. An unfinished claim older than the lock timeout
+// is assumed abandoned (its process died) and may be taken over by a retry.
+const (
+	DefaultIdempotencyRetentionHours = 24
+	IdempotencyLockTimeout           = 10 * time.Minute
+)
+
+// Session defaults. TokenVersionCacheTTL bounds how long a revoked access
+// token can still pass the JWT middleware on a replica that cached the old
+// token version or session.
+const (
+	DefaultAccessTokenTTLMinutes = 15
+	DefaultRefreshTokenTTLDays   = 30
+	TokenVersionCacheTTL         = 30 * time.Second
+)
+
+// Email defaults.
+const (
+	DefaultSMTPPort = 587
+	DefaultAppURL   = "http://localhost:5173"
+)
+
+// Tracing exporters.
+const (
+	TracingNone   = "none"
+	TracingStdout = "stdout"
+	TracingOTLP   = "otlp"
+)
+
+// Event broker transports.
+const (
+	BrokerPostgres = "postgres"
+	BrokerRedis    = "redis"
+)
+
+// DefaultTracingOTLPEndpoint is the OTLP/HTTP collector address used when
+// TRACING_EXPORTER=otlp and no endpoint is configured.
+const DefaultTracingOTLPEndpoint = "http://localhost:4318"
+
+// Background job defaults.
+const (
+	DefaultJobWorkers       = 2
+	DefaultJobRetentionDays = 7
+	DefaultJobArtifactMaxMB = 512
+)
+
+// DefaultShutdownTimeoutSeconds leaves a margin under the 30-second grace
+// period the compose files give the backend.
+const DefaultShutdownTimeoutSeconds = 25
+
 var current *Config
 
 // Load reads and validates configuration from the environment and stores it
 // for package-level accessors. Call once at startup, before serving.
 func Load() (*Config, error) {
 	cfg := &Config{
-		DatabaseURL:    os.Getenv("DATABASE_URL"),
-		JWTSecret:      os.Getenv("JWT_SECRET"),
-		Port:           os.Getenv("PORT"),
-		DeploymentMode: os.Getenv("DEPLOYMENT_MODE"),
-		AdminEmail:     os.Getenv("IDENTO_ADMIN_EMAIL"),
-		AdminPassword:  os.Getenv("IDENTO_ADMIN_PASSWORD"),
-		AdminOrgName:   os.Getenv("IDENTO_ORG_NAME"),
+		DatabaseURL:         os.Getenv("DATABASE_URL"),
+		JWTSecret:           os.Getenv("JWT_SECRET"),
+		Port:                os.Getenv("PORT"),
+		DeploymentMode:      os.Getenv("DEPLOYMENT_MODE"),
+		AdminEmail:          os.Getenv("IDENTO_ADMIN_EMAIL"),
+		AdminPassword:       os.Getenv("IDENTO_ADMIN_PASSWORD"),
+		AdminOrgName:        os.Getenv("IDENTO_ORG_NAME"),
+		SMTPHost:            os.Getenv("SMTP_HOST"),
+		SMTPUsername:        os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:        os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:            os.Getenv("SMTP_FROM"),
+		AppURL:              strings.TrimRight(os.Getenv("APP_URL"), "/"),
+		PublicAPIURL:        strings.TrimRight(os.Getenv("PUBLIC_API_URL"), "/"),
+		MetricsToken:        os.Getenv("METRICS_TOKEN"),
+		TracingExporter:     os.Getenv("TRACING_EXPORTER"),
+		TracingOTLPEndpoint: strings.TrimRight(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "/"),
+		EventBroker:         os.Getenv("EVENT_BROKER"),
+		RedisURL:            os.Getenv("REDIS_URL"),
 	}
 
 	if cfg.DatabaseURL == "" {
@@ -82,6 +195,136 @@ func Load() (*Config, error) {
 		cfg.TenantRetentionDays = n
 	}
 
+	switch raw := os.Getenv("IDEMPOTENCY_RETENTION_HOURS"); raw {
+	case "":
+		cfg.IdempotencyRetentionHours = DefaultIdempotencyRetentionHours
+	default:
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("IDEMPOTENCY_RETENTION_HOURS must be a positive integer, got %q", raw)
+		}
+		cfg.IdempotencyRetentionHours = n
+	}
+
+	switch raw := os.Getenv("JOB_WORKERS"); raw {
+	case "":
+		cfg.JobWorkers = DefaultJobWorkers
+	default:
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("JOB_WORKERS must be a non-negative integer (0 runs no workers on this replica), got %q", raw)
+		}
+		cfg.JobWorkers = n
+	}
+
+	switch raw := os.Getenv("JOB_RETENTION_DAYS"); raw {
+	case "":
+		cfg.JobRetentionDays = DefaultJobRetentionDays
+	default:
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("JOB_RETENTION_DAYS must be a positive integer, got %q", raw)
+		}
+		cfg.JobRetentionDays = n
+	}
+
+	switch raw := os.Getenv("JOB_ARTIFACT_MAX_MB"); raw {
+	case "":
+		cfg.JobArtifactMaxMB = DefaultJobArtifactMaxMB
+	default:
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("JOB_ARTIFACT_MAX_MB must be a positive integer, got %q", raw)
+		}
+		cfg.JobArtifactMaxMB = n
+	}
+
+	switch raw := os.Getenv("SHUTDOWN_TIMEOUT_SECONDS"); raw {
+	case "":
+		cfg.ShutdownTimeoutSeconds = DefaultShutdownTimeoutSeconds
+	default:
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("SHUTDOWN_TIMEOUT_SECONDS must be a positive integer, got %q", raw)
+		}
+		cfg.ShutdownTimeoutSeconds = n
+	}
+
+	switch raw := os.Getenv("ACCESS_TOKEN_TTL_MINUTES"); raw {
+	case "":
+		cfg.AccessTokenTTLMinutes = DefaultAccessTokenTTLMinutes
+	default:
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("ACCESS_TOKEN_TTL_MINUTES must be a positive integer, got %q", raw)
+		}
+		cfg.AccessTokenTTLMinutes = n
+	}
+
+	switch raw := os.Getenv("REFRESH_TOKEN_TTL_DAYS"); raw {
+	case "":
+		cfg.RefreshTokenTTLDays = DefaultRefreshTokenTTLDays
+	default:
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("REFRESH_TOKEN_TTL_DAYS must be a positive integer, got %q", raw)
+		}
+		cfg.RefreshTokenTTLDays = n
+	}
+
+	switch raw := os.Getenv("SMTP_PORT"); raw {
+	case "":
+		cfg.SMTPPort = DefaultSMTPPort
+	default:
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("SMTP_PORT must be a TCP port, got %q", raw)
+		}
+		cfg.SMTPPort = n
+	}
+	if cfg.SMTPHost != "" && cfg.SMTPFrom == "" {
+		return nil, fmt.Errorf("SMTP_FROM is required when SMTP_HOST is set")
+	}
+	if cfg.AppURL == "" {
+		cfg.AppURL = DefaultAppURL
+	}
+	if cfg.PublicAPIURL == "" {
+		cfg.PublicAPIURL = "http://localhost:" + cfg.Port
+	}
+
+	switch cfg.TracingExporter {
+	case "":
+		cfg.TracingExporter = TracingNone
+	case TracingNone, TracingStdout, TracingOTLP:
+	default:
+		return nil, fmt.Errorf("TRACING_EXPORTER must be %q, %q or %q, got %q", TracingNone, TracingStdout, TracingOTLP, cfg.TracingExporter)
+	}
+	switch cfg.EventBroker {
+	case "":
+		cfg.EventBroker = BrokerPostgres
+	case BrokerPostgres:
+	case BrokerRedis:
+		if cfg.RedisURL == "" {
+			return nil, fmt.Errorf("REDIS_URL is required when EVENT_BROKER=%s", BrokerRedis)
+		}
+	default:
+		return nil, fmt.Errorf("EVENT_BROKER must be %q or %q, got %q", BrokerPostgres, BrokerRedis, cfg.EventBroker)
+	}
+
+	if cfg.TracingOTLPEndpoint == "" {
+		cfg.TracingOTLPEndpoint = DefaultTracingOTLPEndpoint
+	}
+	switch raw := os.Getenv("TRACING_SAMPLE_RATIO"); raw {
+	case "":
+		cfg.TracingSampleRatio = 1
+	default:
+		r, err := strconv.ParseFloat(raw, 64)
+		if err != nil || r < 0 || r > 1 {
+			return nil, fmt.Errorf("TRACING_SAMPLE_RATIO must be a number between 0 and 1, got %q", raw)
+		}
+		cfg.TracingSampleRatio = r
+	}
+
 	current = cfg
 	return cfg, nil
 }
@@ -94,3 +337,48 @@ func JWTSecret() string {
 	}
 	return os.Getenv("JWT_SECRET")
 }
+
+// IdempotencyRetention returns the loaded Idempotency-Key retention window,
+// or the default before Load (unit tests that exercise middleware directly).
+func IdempotencyRetention() time.Duration {
+	if current != nil && current.IdempotencyRetentionHours > 0 {
+		return time.Duration(current.IdempotencyRetentionHours) * time.Hour
+	}
+	return DefaultIdempotencyRetentionHours * time.Hour
+}
+
+// AccessTokenTTL returns the loaded access JWT lifetime, or the default
+// before Load.
+func AccessTokenTTL() time.Duration {
+	if current != nil && current.AccessTokenTTLMinutes > 0 {
+		return time.Duration(current.AccessTokenTTLMinutes) * time.Minute
+	}
+	return DefaultAccessTokenTTLMinutes * time.Minute
+}
+
+// RefreshTokenTTL returns the loaded refresh token lifetime, or the default
+// before Load.
+func RefreshTokenTTL() time.Duration {
+	if current != nil && current.RefreshTokenTTLDays > 0 {
+		return time.Duration(current.RefreshTokenTTLDays) * 24 * time.Hour
+	}
+	return DefaultRefreshTokenTTLDays * 24 * time.Hour
+}
+
+// AppURL returns the loaded web app base URL (no trailing slash), or the
+// default before Load.
+func AppURL() string {
+	if current != nil && current.AppURL != "" {
+		return current.AppURL
+	}
+	return DefaultAppURL
+}
+
+// PublicAPIURL returns the loaded public API base URL (no trailing slash),
+// or the local default before Load.
+func PublicAPIURL() string {
+	if current != nil && current.PublicAPIURL != "" {
+		return current.PublicAPIURL
+	}
+	return "http://localhost:8008"
+}
@@ -112,3 +112,311 @@ func TestLoadTenantRetentionDays(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadIdempotencyRetentionHours(t *testing.T) {
+	cases := []struct {
+		name    string
+		env     string
+		want    int
+		wantErr bool
+	}{
+		{name: "unset defaults to 24", env: "", want: 24},
+		{name: "explicit value honored", env: "72", want: 72},
+		{name: "zero rejected", env: "0", wantErr: true},
+		{name: "non-numeric rejected", env: "a day", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv("IDEMPOTENCY_RETENTION_HOURS", tc.env)
+			cfg, err := Load()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Load() succeeded with IDEMPOTENCY_RETENTION_HOURS=%q, want error", tc.env)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if cfg.IdempotencyRetentionHours != tc.want {
+				t.Errorf("IdempotencyRetentionHours = %d, want %d", cfg.IdempotencyRetentionHours, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadJobSettings(t *testing.T) {
+	cases := []struct {
+		name          string
+		workers       string
+		retention     string
+		wantWorkers   int
+		wantRetention int
+		wantErr       bool
+	}{
+		{name: "unset defaults", wantWorkers: 2, wantRetention: 7},
+		{name: "zero workers allowed", workers: "0", wantWorkers: 0, wantRetention: 7},
+		{name: "explicit values honored", workers: "8", retention: "30", wantWorkers: 8, wantRetention: 30},
+		{name: "negative workers rejected", workers: "-1", wantErr: true},
+		{name: "zero retention rejected", retention: "0", wantErr: true},
+		{name: "non-numeric retention rejected", retention: "a week", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv("JOB_WORKERS", tc.workers)
+			t.Setenv("JOB_RETENTION_DAYS", tc.retention)
+			cfg, err := Load()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Load() succeeded with JOB_WORKERS=%q JOB_RETENTION_DAYS=%q, want error", tc.workers, tc.retention)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if cfg.JobWorkers != tc.wantWorkers || cfg.JobRetentionDays != tc.wantRetention {
+				t.Errorf("JobWorkers, JobRetentionDays = %d, %d, want %d, %d",
+					cfg.JobWorkers, cfg.JobRetentionDays, tc.wantWorkers, tc.wantRetention)
+			}
+		})
+	}
+}
+
+func TestLoadJobArtifactMaxMB(t *testing.T) {
+	cases := []struct {
+		name    string
+		raw     string
+		want    int
+		wantErr bool
+	}{
+		{name: "unset defaults", want: DefaultJobArtifactMaxMB},
+		{name: "explicit value honored", raw: "64", want: 64},
+		{name: "zero rejected", raw: "0", wantErr: true},
+		{name: "non-numeric rejected", raw: "1GB", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv("JOB_ARTIFACT_MAX_MB", tc.raw)
+			cfg, err := Load()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Load() succeeded with JOB_ARTIFACT_MAX_MB=%q, want error", tc.raw)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if cfg.JobArtifactMaxMB != tc.want {
+				t.Errorf("JobArtifactMaxMB = %d, want %d", cfg.JobArtifactMaxMB, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadShutdownTimeout(t *testing.T) {
+	cases := []struct {
+		name    string
+		raw     string
+		want    int
+		wantErr bool
+	}{
+		{name: "unset defaults", want: 25},
+		{name: "explicit value honored", raw: "60", want: 60},
+		{name: "zero rejected", raw: "0", wantErr: true},
+		{name: "duration syntax rejected", raw: "30s", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv("SHUTDOWN_TIMEOUT_SECONDS", tc.raw)
+			cfg, err := Load()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Load() succeeded with SHUTDOWN_TIMEOUT_SECONDS=%q, want error", tc.raw)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if cfg.ShutdownTimeoutSeconds != tc.want {
+				t.Errorf("ShutdownTimeoutSeconds = %d, want %d", cfg.ShutdownTimeoutSeconds, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadTokenTTLs(t *testing.T) {
+	cases := []struct {
+		name        string
+		access      string
+		refresh     string
+		wantAccess  int
+		wantRefresh int
+		wantErr     bool
+	}{
+		{name: "unset defaults", wantAccess: 15, wantRefresh: 30},
+		{name: "explicit values honored", access: "5", refresh: "7", wantAccess: 5, wantRefresh: 7},
+		{name: "zero access rejected", access: "0", wantErr: true},
+		{name: "non-numeric refresh rejected", refresh: "a month", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv("ACCESS_TOKEN_TTL_MINUTES", tc.access)
+			t.Setenv("REFRESH_TOKEN_TTL_DAYS", tc.refresh)
+			cfg, err := Load()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Load() succeeded with ACCESS_TOKEN_TTL_MINUTES=%q REFRESH_TOKEN_TTL_DAYS=%q, want error", tc.access, tc.refresh)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if cfg.AccessTokenTTLMinutes != tc.wantAccess || cfg.RefreshTokenTTLDays != tc.wantRefresh {
+				t.Errorf("AccessTokenTTLMinutes, RefreshTokenTTLDays = %d, %d, want %d, %d",
+					cfg.AccessTokenTTLMinutes, cfg.RefreshTokenTTLDays, tc.wantAccess, tc.wantRefresh)
+			}
+		})
+	}
+}
+
+func TestLoadMailSettings(t *testing.T) {
+	cases := []struct {
+		name     string
+		env      map[string]string
+		wantPort int
+		wantApp  string
+		wantErr  bool
+	}{
+		{name: "unset defaults", wantPort: 587, wantApp: "http://localhost:5173"},
+		{name: "explicit values honored", env: map[string]string{
+			"SMTP_HOST": "smtp.example.com", "SMTP_PORT": "2525", "SMTP_FROM": "noreply@example.com", "APP_URL": "https://app.example.com/",
+		}, wantPort: 2525, wantApp: "https://app.example.com"},
+		{name: "host without from rejected", env: map[string]string{"SMTP_HOST": "smtp.example.com"}, wantErr: true},
+		{name: "bad port rejected", env: map[string]string{"SMTP_PORT": "70000"}, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			for _, k := range []string{"SMTP_HOST", "SMTP_PORT", "SMTP_FROM", "APP_URL"} {
+				t.Setenv(k, tc.env[k])
+			}
+			cfg, err := Load()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Load() succeeded with %v, want error", tc.env)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if cfg.SMTPPort != tc.wantPort || cfg.AppURL != tc.wantApp {
+				t.Errorf("SMTPPort, AppURL = %d, %q, want %d, %q", cfg.SMTPPort, cfg.AppURL, tc.wantPort, tc.wantApp)
+			}
+		})
+	}
+}
+
+func TestLoadPublicAPIURL(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("PORT", "9000")
+	t.Setenv("PUBLIC_API_URL", "")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.PublicAPIURL != "http://localhost:9000" {
+		t.Errorf("default PublicAPIURL = %q", cfg.PublicAPIURL)
+	}
+
+	t.Setenv("PUBLIC_API_URL", "https://api.example.com/")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.PublicAPIURL != "https://api.example.com" || PublicAPIURL() != "https://api.example.com" {
+		t.Errorf("PublicAPIURL = %q", cfg.PublicAPIURL)
+	}
+}
+
+func TestLoadTracingSettings(t *testing.T) {
+	cases := []struct {
+		name         string
+		env          map[string]string
+		wantExporter string
+		wantEndpoint string
+		wantRatio    float64
+		wantErr      bool
+	}{
+		{name: "unset defaults", wantExporter: TracingNone, wantEndpoint: DefaultTracingOTLPEndpoint, wantRatio: 1},
+		{name: "explicit values honored", env: map[string]string{
+			"TRACING_EXPORTER": "otlp", "OTEL_EXPORTER_OTLP_ENDPOINT": "https://otel.example.com/", "TRACING_SAMPLE_RATIO": "0.25",
+		}, wantExporter: TracingOTLP, wantEndpoint: "https://otel.example.com", wantRatio: 0.25},
+		{name: "unknown exporter rejected", env: map[string]string{"TRACING_EXPORTER": "jaeger"}, wantErr: true},
+		{name: "ratio above one rejected", env: map[string]string{"TRACING_SAMPLE_RATIO": "1.5"}, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			for _, k := range []string{"TRACING_EXPORTER", "OTEL_EXPORTER_OTLP_ENDPOINT", "TRACING_SAMPLE_RATIO"} {
+				t.Setenv(k, tc.env[k])
+			}
+			cfg, err := Load()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Load() succeeded with %v, want error", tc.env)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if cfg.TracingExporter != tc.wantExporter || cfg.TracingOTLPEndpoint != tc.wantEndpoint || cfg.TracingSampleRatio != tc.wantRatio {
+				t.Errorf("tracing = %q, %q, %v, want %q, %q, %v", cfg.TracingExporter, cfg.TracingOTLPEndpoint, cfg.TracingSampleRatio, tc.wantExporter, tc.wantEndpoint, tc.wantRatio)
+			}
+		})
+	}
+}
+
+func TestLoadEventBroker(t *testing.T) {
+	cases := []struct {
+		name       string
+		env        map[string]string
+		wantBroker string
+		wantErr    bool
+	}{
+		{name: "unset defaults to postgres", wantBroker: BrokerPostgres},
+		{name: "redis with url", env: map[string]string{"EVENT_BROKER": "redis", "REDIS_URL": "redis://redis:6379/0"}, wantBroker: BrokerRedis},
+		{name: "redis without url rejected", env: map[string]string{"EVENT_BROKER": "redis"}, wantErr: true},
+		{name: "unknown broker rejected", env: map[string]string{"EVENT_BROKER": "kafka"}, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			for _, k := range []string{"EVENT_BROKER", "REDIS_URL"} {
+				t.Setenv(k, tc.env[k])
+			}
+			cfg, err := Load()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Load() succeeded with %v, want error", tc.env)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if cfg.EventBroker != tc.wantBroker || cfg.RedisURL != tc.env["REDIS_URL"] {
+				t.Errorf("broker = %q, %q, want %q, %q", cfg.EventBroker, cfg.RedisURL, tc.wantBroker, tc.env["REDIS_URL"])
+			}
+		})
+	}
+}
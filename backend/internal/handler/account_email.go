@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"idento/backend/internal/config"
+	"idento/backend/internal/mail"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Lifetimes of the links mailed by the account email flows.
+const (
+	passwordResetTTL     = time.Hour
+	emailVerificationTTL = 72 * time.Hour
+	mailSendTimeout      = 15 * time.Second
+)
+
+// ForgotPasswordRequest is the JSON body for POST /auth/forgot-password.
+// Locale ("en", "ru") picks the email's language; the Accept-Language
+// header is used when it is empty.
+type ForgotPasswordRequest struct {
+	Email  string `json:"email"`
+	Locale string `json:"locale,omitempty"`
+}
+
+// ResetPasswordRequest is the JSON body for POST /auth/reset-password.
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// VerifyEmailRequest is the JSON body for POST /auth/verify-email.
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// ResendVerificationRequest is the optional JSON body for
+// POST /api/auth/resend-verification.
+type ResendVerificationRequest struct {
+	Locale string `json:"locale,omitempty"`
+}
+
+// mailer returns the configured Sender; without one, mail is logged and
+// dropped (mail.LogSender), like an unset SMTP_HOST.
+func (h *Handler) mailer() mail.Sender {
+	if h.Mailer == nil {
+		return mail.LogSender{}
+	}
+	return h.Mailer
+}
+
+func requestLocale(c echo.Context, preferred string) string {
+	return mail.Locale(preferred, c.Request().Header.Get("Accept-Language"))
+}
+
+// mailUserToken issues a single-use token for user and purpose and mails
+// the link {APP_URL}{path}?token=... rendered from template in locale.
+func (h *Handler) mailUserToken(ctx context.Context, user *models.User, purpose, template, path, locale string, ttl time.Duration) error {
+	link, err := h.userTokenLink(ctx, user.ID, purpose, path, ttl)
+	if err != nil {
+		return err
+	}
+	return h.sendTemplate(ctx, user.Email, template, locale, mail.LinkData{
+		Email:      user.Email,
+		Link:       link,
+		ValidHours: int(ttl / time.Hour),
+	})
+}
+
+// userTokenLink issues a single-use token for userID and purpose and
+// returns the link {APP_URL}{path}?token=... that spends it.
+func (h *Handler) userTokenLink(ctx context.Context, userID uuid.UUID, purpose, path string, ttl time.Duration) (string, error) {
+	raw, hash, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	if err := h.Store.CreateUserToken(ctx, userID, purpose, hash, time.Now().Add(ttl)); err != nil {
+		return "", err
+	}
+	return config.AppURL() + path + "?token=" + url.QueryEscape(raw), nil
+}
+
+// sendTemplate renders template in locale with data and mails it to to.
+func (h *Handler) sendTemplate(ctx context.Context, to, template, locale string, data any) error {
+	msg, err := mail.Render(template, locale, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+	sendCtx, cancel := context.WithTimeout(ctx, mailSendTimeout)
+	defer cancel()
+	return h.mailer().Send(sendCtx, msg)
+}
+
+func (h *Handler) sendVerificationEmail(ctx context.Context, user *models.User, locale string) error {
+	return h.mailUserToken(ctx, user, models.UserTokenEmailVerification, mail.TemplateEmailVerification,
+		"/verify-email", locale, emailVerificationTTL)
+}
+
+// ForgotPassword serves POST /auth/forgot-password: it mails a single-use
+// reset link valid for an hour. The answer is 202 whether or not the
+// account exists, so the endpoint cannot be used to probe for emails.
+func (h *Handler) ForgotPassword(c echo.Context) error {
+	req := new(ForgotPasswordRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+	if req.Email == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	accepted := map[string]string{"message": "If an account with this email exists, a reset link has been sent"}
+	user, err := h.Store.GetUserByEmail(c.Request().Context(), req.Email)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	if user == nil {
+		return c.JSON(http.StatusAccepted, accepted)
+	}
+	if err := h.mailUserToken(c.Request().Context(), user, models.UserTokenPasswordReset, mail.TemplatePasswordReset,
+		"/reset-password", requestLocale(c, req.Locale), passwordResetTTL); err != nil {
+		log.Printf("Failed to send password reset email to user %s: %v", user.ID, err)
+	}
+	return c.JSON(http.StatusAccepted, accepted)
+}
+
+// ResetPassword serves POST /auth/reset-password: it spends a reset token
+// and sets the new password. Every existing session of the account is
+// revoked; the client signs in again with the new password.
+func (h *Handler) ResetPassword(c echo.Context) error {
+	req := new(ResetPasswordRequest)
+	if err := c.Bind(req); err != nil || req.Token == "" || len(req.Password) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "token and password are required"})
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid password"})
+	}
+	userID, err := h.Store.ResetPasswordWithToken(c.Request().Context(), hashOpaqueToken(req.Token), string(hashed))
+	if errors.Is(err, store.ErrUserTokenInvalid) {
+		return invalidUserToken(c)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to reset password"})
+	}
+	log.Printf("Password reset for user %s via emailed link", userID)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// VerifyEmail serves POST /auth/verify-email: it spends a verification
+// token and marks the account's email verified.
+func (h *Handler) VerifyEmail(c echo.Context) error {
+	req := new(VerifyEmailRequest)
+	if err := c.Bind(req); err != nil || req.Token == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "token is required"})
+	}
+	_, err := h.Store.VerifyEmailWithToken(c.Request().Context(), hashOpaqueToken(req.Token))
+	if errors.Is(err, store.ErrUserTokenInvalid) {
+		return invalidUserToken(c)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify email"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func invalidUserToken(c echo.Context) error {
+	return c.JSON(http.StatusBadRequest, map[string]string{"code": "token_invalid", "error": "Invalid or expired link"})
+}
+
+// ResendVerification serves POST /api/auth/resend-verification: it mails
+// the signed-in user a fresh verification link, superseding earlier ones.
+func (h *Handler) ResendVerification(c echo.Context) error {
+	claims, err := claimsFromContext(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid token"})
+	}
+	req := new(ResendVerificationRequest)
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+		}
+	}
+
+	user, err := h.Store.GetUserByID(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load user"})
+	}
+	if user.EmailVerifiedAt != nil {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Email already verified"})
+	}
+	if err := h.sendVerificationEmail(c.Request().Context(), user, requestLocale(c, req.Locale)); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+		return c.JSON(http.StatusBadGateway, map[string]string{"error": "Failed to send email"})
+	}
+	return c.JSON(http.StatusAccepted, map[string]string{"message": "Verification email sent"})
+}
@@ -0,0 +1,280 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"idento/backend/internal/mail"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// recordingMailer is a mail.Sender that keeps what it is asked to send.
+type recordingMailer struct {
+	sent []mail.Message
+	err  error
+}
+
+func (m *recordingMailer) Send(_ context.Context, msg mail.Message) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// linkToken extracts the token query parameter of the link in a mailed body.
+func linkToken(t *testing.T, body, path string) string {
+	t.Helper()
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "http") && strings.Contains(line, path+"?token=") {
+			u, err := url.Parse(strings.TrimSpace(line))
+			if err != nil {
+				t.Fatalf("parse link %q: %v", line, err)
+			}
+			return u.Query().Get("token")
+		}
+	}
+	t.Fatalf("no %s link in mail body:\n%s", path, body)
+	return ""
+}
+
+func TestContractForgotPassword(t *testing.T) {
+	user := contractUser("anna@example.com")
+	fs := &fakeStore{
+		getUserByEmail: func(email string) (*models.User, error) {
+			if email == user.Email {
+				return user, nil
+			}
+			return nil, nil
+		},
+	}
+	mailer := &recordingMailer{}
+	h := &Handler{Store: fs, Mailer: mailer}
+	e := echo.New()
+
+	c, rec := newUnauthedContext(e, http.MethodPost, "/auth/forgot-password", `{"email":" Anna@Example.com "}`)
+	c.Request().Header.Set("Accept-Language", "ru-RU,ru;q=0.9")
+	if err := h.ForgotPassword(c); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("want 202, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodPost, "/auth/forgot-password", rec)
+	if len(mailer.sent) != 1 || len(fs.userTokens) != 1 {
+		t.Fatalf("sent %d mails, stored %d tokens; want 1 and 1", len(mailer.sent), len(fs.userTokens))
+	}
+	msg, tok := mailer.sent[0], fs.userTokens[0]
+	if msg.To != user.Email || msg.Subject != "Сброс пароля Idento" {
+		t.Errorf("mail to %q subject %q, want the Russian reset email to the user", msg.To, msg.Subject)
+	}
+	raw := linkToken(t, msg.Body, "/reset-password")
+	if tok.UserID != user.ID || tok.Purpose != models.UserTokenPasswordReset || tok.Hash != hashOpaqueToken(raw) {
+		t.Errorf("stored token %+v does not match the mailed link", tok)
+	}
+	if d := time.Until(tok.ExpiresAt); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("token expires in %v, want an hour", d)
+	}
+
+	// Unknown accounts and mail failures look exactly like success.
+	for _, tc := range []struct {
+		name  string
+		body  string
+		mails error
+	}{
+		{"unknown email", `{"email":"nobody@example.com"}`, nil},
+		{"mail server down", `{"email":"anna@example.com"}`, errors.New("connection refused")},
+	} {
+		hQuiet := &Handler{Store: fs, Mailer: &recordingMailer{err: tc.mails}}
+		c, rec = newUnauthedContext(e, http.MethodPost, "/auth/forgot-password", tc.body)
+		if err := hQuiet.ForgotPassword(c); err != nil {
+			t.Fatalf("ForgotPassword: %v", err)
+		}
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("%s: want 202, got %d", tc.name, rec.Code)
+		}
+	}
+
+	c, rec = newUnauthedContext(e, http.MethodPost, "/auth/forgot-password", `{}`)
+	if err := h.ForgotPassword(c); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("empty body: want 400, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodPost, "/auth/forgot-password", rec)
+
+	hFail := &Handler{Store: &fakeStore{getUserByEmail: func(string) (*models.User, error) { return nil, errors.New("db down") }}}
+	c, rec = newUnauthedContext(e, http.MethodPost, "/auth/forgot-password", `{"email":"anna@example.com"}`)
+	if err := hFail.ForgotPassword(c); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("want 500, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodPost, "/auth/forgot-password", rec)
+}
+
+func TestContractResetPassword(t *testing.T) {
+	var gotHash, gotPassword string
+	fs := &fakeStore{
+		resetPasswordWithToken: func(tokenHash, passwordHash string) (uuid.UUID, error) {
+			if tokenHash != hashOpaqueToken("good") {
+				return uuid.Nil, store.ErrUserTokenInvalid
+			}
+			gotHash, gotPassword = tokenHash, passwordHash
+			return uuid.New(), nil
+		},
+	}
+	h := New(fs)
+	e := echo.New()
+	call := func(h *Handler, body string) int {
+		c, rec := newUnauthedContext(e, http.MethodPost, "/auth/reset-password", body)
+		if err := h.ResetPassword(c); err != nil {
+			t.Fatalf("ResetPassword: %v", err)
+		}
+		validateResponse(t, http.MethodPost, "/auth/reset-password", rec)
+		return rec.Code
+	}
+
+	if code := call(h, `{"token":"good","password":"n3w-secret"}`); code != http.StatusNoContent {
+		t.Fatalf("want 204, got %d", code)
+	}
+	if gotHash == "" || bcrypt.CompareHashAndPassword([]byte(gotPassword), []byte("n3w-secret")) != nil {
+		t.Errorf("store got hash=%q password=%q, want the token hash and a bcrypt of the new password", gotHash, gotPassword)
+	}
+	if code := call(h, `{"token":"good"}`); code != http.StatusBadRequest {
+		t.Fatalf("missing password: want 400, got %d", code)
+	}
+	if code := call(h, `{"token":"spent","password":"x"}`); code != http.StatusBadRequest {
+		t.Fatalf("invalid token: want 400, got %d", code)
+	}
+	hFail := New(&fakeStore{resetPasswordWithToken: func(string, string) (uuid.UUID, error) { return uuid.Nil, errors.New("db down") }})
+	if code := call(hFail, `{"token":"good","password":"x"}`); code != http.StatusInternalServerError {
+		t.Fatalf("store failure: want 500, got %d", code)
+	}
+}
+
+func TestContractVerifyEmail(t *testing.T) {
+	h := New(&fakeStore{
+		verifyEmailWithToken: func(tokenHash string) (uuid.UUID, error) {
+			if tokenHash != hashOpaqueToken("good") {
+				return uuid.Nil, store.ErrUserTokenInvalid
+			}
+			return uuid.New(), nil
+		},
+	})
+	e := echo.New()
+	call := func(h *Handler, body string) (int, string) {
+		c, rec := newUnauthedContext(e, http.MethodPost, "/auth/verify-email", body)
+		if err := h.VerifyEmail(c); err != nil {
+			t.Fatalf("VerifyEmail: %v", err)
+		}
+		validateResponse(t, http.MethodPost, "/auth/verify-email", rec)
+		return rec.Code, rec.Body.String()
+	}
+
+	if code, _ := call(h, `{"token":"good"}`); code != http.StatusNoContent {
+		t.Fatalf("want 204, got %d", code)
+	}
+	if code, _ := call(h, `{}`); code != http.StatusBadRequest {
+		t.Fatalf("missing token: want 400, got %d", code)
+	}
+	if code, body := call(h, `{"token":"expired"}`); code != http.StatusBadRequest || !strings.Contains(body, "token_invalid") {
+		t.Fatalf("invalid token: want 400 token_invalid, got %d %s", code, body)
+	}
+	hFail := New(&fakeStore{verifyEmailWithToken: func(string) (uuid.UUID, error) { return uuid.Nil, errors.New("db down") }})
+	if code, _ := call(hFail, `{"token":"good"}`); code != http.StatusInternalServerError {
+		t.Fatalf("store failure: want 500, got %d", code)
+	}
+}
+
+func TestContractResendVerification(t *testing.T) {
+	user := contractUser("anna@example.com")
+	verified := contractUser("bob@example.com")
+	now := time.Now()
+	verified.EmailVerifiedAt = &now
+	users := map[uuid.UUID]*models.User{user.ID: user, verified.ID: verified}
+	fs := &fakeStore{getUserByID: func(id uuid.UUID) (*models.User, error) { return users[id], nil }}
+	e := echo.New()
+	call := func(h *Handler, userID uuid.UUID, body string) int {
+		c, rec := newAuthedContextWithUserID(e, http.MethodPost, "/api/auth/resend-verification", body, uuid.NewString(), userID, "staff")
+		if err := h.ResendVerification(c); err != nil {
+			t.Fatalf("ResendVerification: %v", err)
+		}
+		validateResponse(t, http.MethodPost, "/api/auth/resend-verification", rec)
+		return rec.Code
+	}
+
+	mailer := &recordingMailer{}
+	if code := call(&Handler{Store: fs, Mailer: mailer}, user.ID, `{"locale":"ru"}`); code != http.StatusAccepted {
+		t.Fatalf("want 202, got %d", code)
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0].Subject != "Подтвердите email для Idento" {
+		t.Fatalf("sent = %+v, want one Russian verification email", mailer.sent)
+	}
+	if raw := linkToken(t, mailer.sent[0].Body, "/verify-email"); fs.userTokens[0].Hash != hashOpaqueToken(raw) ||
+		fs.userTokens[0].Purpose != models.UserTokenEmailVerification {
+		t.Errorf("stored token %+v does not match the mailed link", fs.userTokens[0])
+	}
+
+	if code := call(&Handler{Store: fs, Mailer: mailer}, verified.ID, ""); code != http.StatusConflict {
+		t.Fatalf("verified: want 409, got %d", code)
+	}
+	if code := call(&Handler{Store: fs, Mailer: &recordingMailer{err: errors.New("refused")}}, user.ID, ""); code != http.StatusBadGateway {
+		t.Fatalf("mail failure: want 502, got %d", code)
+	}
+	hFail := &Handler{Store: &fakeStore{getUserByID: func(uuid.UUID) (*models.User, error) { return nil, errors.New("db down") }}}
+	if code := call(hFail, user.ID, ""); code != http.StatusInternalServerError {
+		t.Fatalf("store failure: want 500, got %d", code)
+	}
+}
+
+// Registering mails the new account a verification link; an account that
+// is already verified gets none.
+func TestRegisterMailsVerificationLink(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	now := time.Now()
+	for _, tc := range []struct {
+		name     string
+		verified *time.Time
+		want     int
+	}{
+		{"new account", nil, 1},
+		{"verified account", &now, 0},
+	} {
+		fs := &fakeStore{
+			provisionTenantWithAdmin: func(tenantName, email, _ string) (*models.Tenant, *models.User, error) {
+				tenant := &models.Tenant{ID: uuid.New(), Name: tenantName}
+				return tenant, &models.User{ID: uuid.New(), TenantID: tenant.ID, Email: email, Role: "admin", EmailVerifiedAt: tc.verified}, nil
+			},
+			getUserTenants: func(uuid.UUID) ([]*models.Tenant, error) { return nil, nil },
+		}
+		mailer := &recordingMailer{}
+		h := &Handler{Store: fs, Mailer: mailer}
+		c, rec := newUnauthedContext(echo.New(), http.MethodPost, "/auth/register",
+			`{"tenant_name":"Acme","email":"owner@acme.test","password":"secret123","locale":"en"}`)
+		if err := h.Register(c); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("%s: want 201, got %d", tc.name, rec.Code)
+		}
+		if len(mailer.sent) != tc.want || len(fs.userTokens) != tc.want {
+			t.Fatalf("%s: sent %d mails, stored %d tokens, want %d", tc.name, len(mailer.sent), len(fs.userTokens), tc.want)
+		}
+		if tc.want == 1 && (mailer.sent[0].To != "owner@acme.test" || !strings.Contains(mailer.sent[0].Subject, "Confirm")) {
+			t.Errorf("%s: mail = %+v", tc.name, mailer.sent[0])
+		}
+	}
+}
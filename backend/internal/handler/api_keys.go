@@ -3,16 +3,38 @@ package handler
 import (
 	"context"
 	"fmt"
+	"idento/backend/internal/broker"
+	"idento/backend/internal/metrics"
 	"idento/backend/internal/middleware"
 	"idento/backend/internal/models"
+	"idento/backend/internal/store"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
+func (h *Handler) apiKeyThrottleLog() *middleware.APIKeyThrottleLog {
+	h.apiKeyThrottlesOnce.Do(func() { h.apiKeyThrottles = middleware.NewAPIKeyThrottleLog(h.Store) })
+	return h.apiKeyThrottles
+}
+
+// StartAPIKeyThrottleFlush writes the public API's rate-limit rejections to
+// usage_logs every interval until ctx is done. FlushAPIKeyThrottles writes
+// the rest once the server has drained.
+func (h *Handler) StartAPIKeyThrottleFlush(ctx context.Context, interval time.Duration) {
+	h.apiKeyThrottleLog().Start(ctx, interval)
+}
+
+// FlushAPIKeyThrottles writes the rate-limit rejections tallied since the
+// last flush.
+func (h *Handler) FlushAPIKeyThrottles(ctx context.Context) {
+	h.apiKeyThrottleLog().Flush(ctx)
+}
+
 // CreateAPIKey создает новый API-ключ для мероприятия
 func (h *Handler) CreateAPIKey(c echo.Context) error {
 	eventID, err := uuid.Parse(c.Param("event_id"))
@@ -23,6 +45,9 @@ func (h *Handler) CreateAPIKey(c echo.Context) error {
 	if _, err := h.requireEventOwnership(c, eventID); err != nil {
 		return writeErr(c, err)
 	}
+	if err := h.requirePermission(c, PermAPIKeysManage, eventID); err != nil {
+		return writeErr(c, err)
+	}
 
 	var req models.CreateAPIKeyRequest
 	if err := c.Bind(&req); err != nil {
@@ -34,6 +59,16 @@ func (h *Handler) CreateAPIKey(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Expiration date must be in the future"})
 	}
 
+	rateLimit := models.DefaultAPIKeyRateLimitPerMinute
+	if req.RateLimitPerMinute != nil {
+		rateLimit = *req.RateLimitPerMinute
+		if rateLimit < 1 || rateLimit > models.MaxAPIKeyRateLimitPerMinute {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("rate_limit_per_minute must be between 1 and %d", models.MaxAPIKeyRateLimitPerMinute),
+			})
+		}
+	}
+
 	// Generate API key (plain key, SHA256 for lookup, bcrypt for verification)
 	plainKey, keyHash, keyHashBcrypt, err := middleware.GenerateAPIKey()
 	if err != nil {
@@ -42,14 +77,15 @@ func (h *Handler) CreateAPIKey(c echo.Context) error {
 	keyPreview := plainKey[:8] + "..." // Store only first 8 characters for display
 
 	apiKey := &models.APIKey{
-		ID:            uuid.New(),
-		EventID:       eventID,
-		Name:          req.Name,
-		KeyHash:       keyHash,
-		KeyHashBcrypt: &keyHashBcrypt,
-		KeyPreview:    keyPreview,
-		ExpiresAt:     req.ExpiresAt,
-		CreatedAt:     time.Now(),
+		ID:                 uuid.New(),
+		EventID:            eventID,
+		Name:               req.Name,
+		KeyHash:            keyHash,
+		KeyHashBcrypt:      &keyHashBcrypt,
+		KeyPreview:         keyPreview,
+		RateLimitPerMinute: rateLimit,
+		ExpiresAt:          req.ExpiresAt,
+		CreatedAt:          time.Now(),
 	}
 
 	if err := h.Store.CreateAPIKey(context.Background(), apiKey); err != nil {
@@ -75,6 +111,9 @@ func (h *Handler) GetAPIKeys(c echo.Context) error {
 	if _, err := h.requireEventOwnership(c, eventID); err != nil {
 		return writeErr(c, err)
 	}
+	if err := h.requirePermission(c, PermAPIKeysManage, eventID); err != nil {
+		return writeErr(c, err)
+	}
 
 	keys, err := h.Store.GetAPIKeysByEventID(context.Background(), eventID)
 	if err != nil {
@@ -84,6 +123,80 @@ func (h *Handler) GetAPIKeys(c echo.Context) error {
 	return c.JSON(http.StatusOK, keys)
 }
 
+// apiKeyUsageMaxDays bounds GetAPIKeyUsage's ?days= window.
+const apiKeyUsageMaxDays = 90
+
+// GetAPIKeyUsage возвращает статистику использования API-ключа по дням:
+// обслуженные запросы и запросы, отклонённые лимитом (?days=, по умолчанию 30).
+func (h *Handler) GetAPIKeyUsage(c echo.Context) error {
+	eventID, err := uuid.Parse(c.Param("event_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid event ID"})
+	}
+
+	if _, err := h.requireEventOwnership(c, eventID); err != nil {
+		return writeErr(c, err)
+	}
+	if err := h.requirePermission(c, PermAPIKeysManage, eventID); err != nil {
+		return writeErr(c, err)
+	}
+
+	keyID, err := uuid.Parse(c.Param("key_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid key ID"})
+	}
+
+	days := 30
+	if raw := c.QueryParam("days"); raw != "" {
+		days, err = strconv.Atoi(raw)
+		if err != nil || days < 1 || days > apiKeyUsageMaxDays {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("days must be an integer between 1 and %d", apiKeyUsageMaxDays),
+			})
+		}
+	}
+
+	// Same key-belongs-to-event check as RevokeAPIKey: usage of another
+	// tenant's key must not be readable through one's own event_id.
+	keys, err := h.Store.GetAPIKeysByEventID(c.Request().Context(), eventID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch API keys"})
+	}
+	var key *models.APIKey
+	for _, k := range keys {
+		if k.ID == keyID {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "API key not found"})
+	}
+
+	to := time.Now().UTC()
+	from := to.Truncate(24*time.Hour).AddDate(0, 0, -(days - 1))
+	buckets, err := h.Store.GetAPIKeyUsage(c.Request().Context(), keyID, from, to)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch API key usage"})
+	}
+
+	resp := models.APIKeyUsageResponse{
+		KeyID:              keyID,
+		RateLimitPerMinute: key.RateLimitPerMinute,
+		From:               from,
+		To:                 to,
+		Days:               buckets,
+	}
+	if resp.Days == nil {
+		resp.Days = []models.APIKeyUsageDay{}
+	}
+	for _, d := range resp.Days {
+		resp.TotalRequests += d.Requests
+		resp.TotalThrottled += d.Throttled
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
 // RevokeAPIKey отзывает API-ключ
 func (h *Handler) RevokeAPIKey(c echo.Context) error {
 	eventID, err := uuid.Parse(c.Param("event_id"))
@@ -94,6 +207,9 @@ func (h *Handler) RevokeAPIKey(c echo.Context) error {
 	if _, err := h.requireEventOwnership(c, eventID); err != nil {
 		return writeErr(c, err)
 	}
+	if err := h.requirePermission(c, PermAPIKeysManage, eventID); err != nil {
+		return writeErr(c, err)
+	}
 
 	keyID, err := uuid.Parse(c.Param("key_id"))
 	if err != nil {
@@ -126,7 +242,14 @@ func (h *Handler) RevokeAPIKey(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{"message": "API key revoked successfully"})
 }
 
-// ExternalImport обрабатывает запросы от внешних систем для импорта участников
+// ExternalImport обрабатывает запросы от внешних систем для импорта участников.
+// Режим mode: insert_only (по умолчанию) — каждая строка создаёт участника;
+// upsert — строка обновляет найденного по match_on участника или создаёт
+// нового; update_only — только обновляет найденных. match_on: external_id
+// (по умолчанию), email или code. Состояние чек-ина импорт не меняет никогда.
+// С ?dry_run=true выполняются все те же проверки (лимит, сопоставление,
+// уникальность), но ничего не сохраняется: ответ показывает, что было бы
+// создано/обновлено, и изменения field_schema (schema_changes).
 func (h *Handler) ExternalImport(c echo.Context) error {
 	// Get event_id from context (set by APIKeyAuth middleware)
 	eventID, err := middleware.GetEventIDFromContext(c)
@@ -143,6 +266,21 @@ func (h *Handler) ExternalImport(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No data provided"})
 	}
 
+	mode := req.Mode
+	if mode == "" {
+		mode = models.ImportModeInsertOnly
+	}
+	if mode != models.ImportModeInsertOnly && mode != models.ImportModeUpsert && mode != models.ImportModeUpdateOnly {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "mode must be one of insert_only, upsert, update_only"})
+	}
+	matchOn := req.MatchOn
+	if matchOn == "" {
+		matchOn = models.ImportMatchExternalID
+	}
+	if matchOn != models.ImportMatchExternalID && matchOn != models.ImportMatchEmail && matchOn != models.ImportMatchCode {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "match_on must be one of external_id, email, code"})
+	}
+
 	// Get event to extract field schema
 	event, err := h.Store.GetEventByID(context.Background(), eventID)
 	if err != nil || event == nil {
@@ -164,60 +302,59 @@ func (h *Handler) ExternalImport(c echo.Context) error {
 		})
 	}
 
+	rows := make([]store.AttendeeImportRow, len(req.Data))
+	for idx, data := range req.Data {
+		rows[idx] = externalImportRow(eventID, data)
+	}
+
 	// P1.3: validate the whole batch against attendees_per_event before
 	// inserting, same as the JWT-authed BulkCreateAttendees path — otherwise
-	// an API key alone lets a caller bypass the plan's attendee limit.
-	allowed, current, max, err := h.Store.CheckAttendeeLimit(c.Request().Context(), event.TenantID, event.ID, len(req.Data))
+	// an API key alone lets a caller bypass the plan's attendee limit. Only
+	// rows that can create an attendee count: none in update_only, and in
+	// upsert only those whose match value is not already in the event.
+	adding, err := h.externalImportAdding(c.Request().Context(), eventID, rows, mode, matchOn)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check attendee limit"})
 	}
-	if !allowed {
-		return c.JSON(http.StatusForbidden, map[string]interface{}{
-			"error":            "Limit exceeded for attendees_per_event",
-			"current":          current,
-			"max":              max,
-			"adding":           len(req.Data),
-			"upgrade_required": true,
-			"limit_type":       "attendees_per_event",
-		})
+	if adding > 0 {
+		allowed, current, max, err := h.Store.CheckAttendeeLimit(c.Request().Context(), event.TenantID, event.ID, adding)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check attendee limit"})
+		}
+		if !allowed {
+			return c.JSON(http.StatusForbidden, map[string]interface{}{
+				"error":            "Limit exceeded for attendees_per_event",
+				"current":          current,
+				"max":              max,
+				"adding":           adding,
+				"upgrade_required": true,
+				"limit_type":       "attendees_per_event",
+			})
+		}
 	}
 
-	// Track import results
-	var created, failed int
-	var errors []string
-
-	for idx, data := range req.Data {
-		// Extract standard fields with type assertions
-		firstName, _ := data["first_name"].(string)
-		lastName, _ := data["last_name"].(string)
-		email, _ := data["email"].(string)
-		company, _ := data["company"].(string)
-		position, _ := data["position"].(string)
-		code, _ := data["code"].(string)
-
-		// Validate required fields
-		if firstName == "" || lastName == "" || email == "" {
-			failed++
-			errors = append(errors, fmt.Sprintf("Row %d: missing required fields (first_name, last_name, email)", idx+1))
+	// Validate every row first; the rows that pass then go to the store
+	// together, one import per row or, on a dry run, one preview for all.
+	dryRun := middleware.IsDryRun(c)
+	results := make([]models.ExternalImportRowResult, len(rows))
+	var pending []int
+	originalSchema := append([]string(nil), event.FieldSchema...)
+	for idx, row := range rows {
+		results[idx] = models.ExternalImportRowResult{Row: idx + 1, ExternalID: derefString(row.ExternalID)}
+
+		// Validate required fields. A row that may create an attendee needs
+		// the full identity; update_only only needs something to match on.
+		if mode != models.ImportModeUpdateOnly && (derefString(row.FirstName) == "" || derefString(row.LastName) == "" || derefString(row.Email) == "") {
+			results[idx].Status, results[idx].Error = models.ImportRowFailed, "missing required fields (first_name, last_name, email)"
 			continue
 		}
-
-		// Generate code if not provided
-		if code == "" {
-			code = generateUniqueCode()
-		}
-
-		// Build custom_fields from remaining data
-		customFields := make(map[string]interface{})
-		for key, value := range data {
-			if key != "first_name" && key != "last_name" && key != "email" &&
-				key != "company" && key != "position" && key != "code" {
-				customFields[key] = value
-			}
+		if mode != models.ImportModeInsertOnly && store.ImportMatchValue(row, matchOn) == "" {
+			results[idx].Status, results[idx].Error = models.ImportRowFailed, fmt.Sprintf("missing %s to match on", matchOn)
+			continue
 		}
 
 		// Update field schema if new fields are detected
-		for key := range customFields {
+		for key := range row.CustomFields {
 			found := false
 			for _, field := range event.FieldSchema {
 				if field == key {
@@ -229,28 +366,78 @@ func (h *Handler) ExternalImport(c echo.Context) error {
 				event.FieldSchema = append(event.FieldSchema, key)
 			}
 		}
+		pending = append(pending, idx)
+	}
 
-		attendee := &models.Attendee{
-			ID:           uuid.New(),
-			EventID:      eventID,
-			FirstName:    firstName,
-			LastName:     lastName,
-			Email:        email,
-			Company:      company,
-			Position:     position,
-			Code:         code,
-			CustomFields: customFields,
-			CreatedAt:    time.Now(),
-			UpdatedAt:    time.Now(),
+	var previews []store.ImportOutcome
+	if dryRun && len(pending) > 0 {
+		batch := make([]store.AttendeeImportRow, len(pending))
+		for i, idx := range pending {
+			batch[i] = rows[idx]
 		}
-
-		// Try to create attendee
-		if err := h.Store.CreateAttendee(context.Background(), attendee); err != nil {
-			failed++
-			errors = append(errors, fmt.Sprintf("Row %d: %s", idx+1, err.Error()))
+		previews, err = h.Store.PreviewAttendeeImport(c.Request().Context(), batch, mode, matchOn)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to preview import"})
+		}
+	}
+	for i, idx := range pending {
+		var outcome store.ImportOutcome
+		if dryRun {
+			outcome = previews[i]
 		} else {
-			created++
+			outcome.ID, outcome.Status, outcome.Err = h.Store.ImportAttendee(c.Request().Context(), rows[idx], mode, matchOn)
+		}
+		if outcome.Err != nil {
+			results[idx].Status, results[idx].Error = models.ImportRowFailed, outcome.Err.Error()
+			continue
 		}
+		results[idx].Status = outcome.Status
+		// A previewed insert, or a later row of the batch updating it, has
+		// no ID yet.
+		if outcome.ID != uuid.Nil {
+			id := outcome.ID
+			results[idx].AttendeeID = &id
+		}
+	}
+
+	// Track import results
+	counts := map[string]int{}
+	var errors []string
+	for _, r := range results {
+		counts[r.Status]++
+		if r.Status == models.ImportRowFailed {
+			errors = append(errors, fmt.Sprintf("Row %d: %s", r.Row, r.Error))
+		}
+	}
+
+	response := map[string]interface{}{
+		"message":  "Import completed",
+		"mode":     mode,
+		"match_on": matchOn,
+		"results": map[string]interface{}{
+			"created":   counts[models.ImportRowCreated],
+			"updated":   counts[models.ImportRowUpdated],
+			"unchanged": counts[models.ImportRowUnchanged],
+			"failed":    counts[models.ImportRowFailed],
+			"total":     len(req.Data),
+		},
+		"rows": results,
+	}
+
+	if len(errors) > 0 {
+		response["errors"] = errors
+	}
+
+	if dryRun {
+		response["message"] = "Dry run completed, nothing was written"
+		response["dry_run"] = true
+		response["schema_changes"] = diffFieldSchema(originalSchema, event.FieldSchema)
+		return c.JSON(http.StatusOK, response)
+	}
+
+	metrics.Imports.WithLabelValues("api").Inc()
+	for _, status := range []string{models.ImportRowCreated, models.ImportRowUpdated, models.ImportRowUnchanged, models.ImportRowFailed} {
+		metrics.ImportRows.WithLabelValues("api", status).Add(float64(counts[status]))
 	}
 
 	// Update event field schema if new fields were added
@@ -259,24 +446,103 @@ func (h *Handler) ExternalImport(c echo.Context) error {
 		log.Printf("Warning: Failed to update event field schema: %v", err)
 	}
 
-	// PR #81 round-5: publish once if any attendees were created so the monitor's
-	// `total` stays current for API-imported events (one publish per request, not per attendee)
-	if created > 0 {
+	// PR #81 round-5: publish once if any attendees were created or changed so
+	// the monitor's `total` stays current for API-imported events (one publish
+	// per request, not per attendee)
+	if counts[models.ImportRowCreated] > 0 || counts[models.ImportRowUpdated] > 0 {
 		h.publishCheckinEvent(c.Request().Context(), eventID)
+		h.pushStationMessage(c.Request().Context(), eventID, broker.KindResync, nil)
 	}
 
-	response := map[string]interface{}{
-		"message": "Import completed",
-		"results": map[string]interface{}{
-			"created": created,
-			"failed":  failed,
-			"total":   len(req.Data),
-		},
+	return c.JSON(http.StatusOK, response)
+}
+
+// externalImportStandardFields are the row keys ExternalImport maps onto
+// attendee columns; every other key lands in custom_fields.
+var externalImportStandardFields = map[string]bool{
+	"external_id": true, "first_name": true, "last_name": true, "email": true,
+	"company": true, "position": true, "code": true,
+}
+
+// externalImportRow maps one JSON row onto a store.AttendeeImportRow. Keys
+// that are absent (or not strings) stay nil so an update leaves those
+// columns alone; an empty code counts as absent, since a code can't be
+// blank. external_id may also arrive as a JSON number.
+func externalImportRow(eventID uuid.UUID, data map[string]interface{}) store.AttendeeImportRow {
+	str := func(key string) *string {
+		v, ok := data[key].(string)
+		if !ok {
+			return nil
+		}
+		return &v
+	}
+	row := store.AttendeeImportRow{
+		EventID:      eventID,
+		FirstName:    str("first_name"),
+		LastName:     str("last_name"),
+		Email:        str("email"),
+		Company:      str("company"),
+		Position:     str("position"),
+		Code:         str("code"),
+		FallbackCode: generateUniqueCode(),
+		CustomFields: make(map[string]interface{}),
+	}
+	switch v := data["external_id"].(type) {
+	case string:
+		if v != "" {
+			row.ExternalID = &v
+		}
+	case float64:
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		row.ExternalID = &s
+	}
+	if row.Code != nil && *row.Code == "" {
+		row.Code = nil
 	}
 
-	if len(errors) > 0 {
-		response["errors"] = errors
+	// Build custom_fields from remaining data
+	for key, value := range data {
+		if !externalImportStandardFields[key] {
+			row.CustomFields[key] = value
+		}
 	}
+	return row
+}
 
-	return c.JSON(http.StatusOK, response)
+// externalImportAdding counts the rows of an import batch that would create
+// attendees, for the attendees_per_event check. Rows sharing a not-yet-
+// existing match value count once: the first creates, the rest update it.
+func (h *Handler) externalImportAdding(ctx context.Context, eventID uuid.UUID, rows []store.AttendeeImportRow, mode, matchOn string) (int, error) {
+	switch mode {
+	case models.ImportModeInsertOnly:
+		return len(rows), nil
+	case models.ImportModeUpdateOnly:
+		return 0, nil
+	}
+	var keys []string
+	seen := map[string]bool{}
+	for _, row := range rows {
+		if k := store.ImportMatchValue(row, matchOn); k != "" && !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	existing, err := h.Store.ExistingImportMatchKeys(ctx, eventID, matchOn, keys)
+	if err != nil {
+		return 0, err
+	}
+	adding := 0
+	for _, k := range keys {
+		if !existing[k] {
+			adding++
+		}
+	}
+	return adding, nil
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
 }
@@ -0,0 +1,277 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"idento/backend/internal/middleware"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type externalImportResponse struct {
+	Mode    string                           `json:"mode"`
+	MatchOn string                           `json:"match_on"`
+	Results map[string]int                   `json:"results"`
+	Rows    []models.ExternalImportRowResult `json:"rows"`
+	Errors  []string                         `json:"errors"`
+}
+
+// externalImportFixture is an active tenant's event whose attendee limit is
+// never hit; tests override the import-specific fakeStore fields.
+func externalImportFixture(eventID uuid.UUID) *fakeStore {
+	tenant := uuid.New()
+	return &fakeStore{
+		getEventByID: func(uuid.UUID) (*models.Event, error) {
+			return &models.Event{ID: eventID, TenantID: tenant}, nil
+		},
+		getTenantStatus: func(uuid.UUID) (string, error) { return "active", nil },
+		getSubscriptionByTenantID: func(uuid.UUID) (*models.Subscription, error) {
+			return &models.Subscription{Status: "active"}, nil
+		},
+		checkAttendeeLimit: func(_, _ uuid.UUID, _ int) (bool, int, int, error) { return true, 0, 100, nil },
+		updateEvent:        func(*models.Event) error { return nil },
+	}
+}
+
+func runExternalImport(t *testing.T, fs *fakeStore, eventID uuid.UUID, body string) (*httptest.ResponseRecorder, externalImportResponse) {
+	t.Helper()
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/public/import", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set(string(middleware.EventIDKey), eventID)
+	if err := (&Handler{Store: fs}).ExternalImport(c); err != nil {
+		t.Fatalf("handler error: %v", err)
+	}
+	var resp externalImportResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return rec, resp
+}
+
+// An upsert reports each row's outcome as the store decided it, and maps
+// absent keys to nil so the store leaves those columns alone.
+func TestExternalImportUpsertReportsPerRowOutcome(t *testing.T) {
+	eventID := uuid.New()
+	fs := externalImportFixture(eventID)
+	fs.existingImportMatchKeys = func(_ uuid.UUID, matchOn string, keys []string) (map[string]bool, error) {
+		if matchOn != models.ImportMatchExternalID {
+			t.Errorf("matchOn = %q, want external_id", matchOn)
+		}
+		return map[string]bool{"A-1": true, "A-2": true}, nil
+	}
+	var seen []store.AttendeeImportRow
+	outcomes := map[string]string{"A-1": models.ImportRowUpdated, "A-2": models.ImportRowUnchanged, "A-3": models.ImportRowCreated}
+	fs.importAttendee = func(row store.AttendeeImportRow, mode, matchOn string) (uuid.UUID, string, error) {
+		if mode != models.ImportModeUpsert {
+			t.Errorf("mode = %q, want upsert", mode)
+		}
+		seen = append(seen, row)
+		return uuid.New(), outcomes[*row.ExternalID], nil
+	}
+
+	body := `{"mode":"upsert","data":[` +
+		`{"external_id":"A-1","first_name":"Ann","last_name":"Lee","email":"ann@x.com","badge":"VIP"},` +
+		`{"external_id":"A-2","first_name":"Bob","last_name":"Ray","email":"bob@x.com"},` +
+		`{"external_id":"A-3","first_name":"Cy","last_name":"Do","email":"cy@x.com"},` +
+		`{"first_name":"No","last_name":"Key","email":"nokey@x.com"}` +
+		`]}`
+	rec, resp := runExternalImport(t, fs, eventID, body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	want := map[string]int{"created": 1, "updated": 1, "unchanged": 1, "failed": 1, "total": 4}
+	for k, v := range want {
+		if resp.Results[k] != v {
+			t.Errorf("results[%s] = %d, want %d (all: %v)", k, resp.Results[k], v, resp.Results)
+		}
+	}
+	if len(resp.Rows) != 4 || resp.Rows[3].Status != models.ImportRowFailed || !strings.Contains(resp.Rows[3].Error, "external_id") {
+		t.Fatalf("rows = %+v, want the key-less 4th row failed for missing external_id", resp.Rows)
+	}
+	if seen[0].Company != nil || seen[0].Code != nil {
+		t.Errorf("absent company/code mapped to %v/%v, want nil (left untouched on update)", seen[0].Company, seen[0].Code)
+	}
+	if seen[0].CustomFields["badge"] != "VIP" || seen[0].CustomFields["external_id"] != nil {
+		t.Errorf("custom_fields = %v, want badge kept and external_id excluded", seen[0].CustomFields)
+	}
+}
+
+// Only rows that would create attendees count against attendees_per_event:
+// in upsert, keys already present in the event are updates.
+func TestExternalImportUpsertLimitCountsOnlyNewRows(t *testing.T) {
+	eventID := uuid.New()
+	fs := externalImportFixture(eventID)
+	fs.existingImportMatchKeys = func(_ uuid.UUID, _ string, keys []string) (map[string]bool, error) {
+		if len(keys) != 2 {
+			t.Errorf("keys = %v, want the 2 distinct lower-cased emails", keys)
+		}
+		return map[string]bool{"ann@x.com": true}, nil
+	}
+	var adding int
+	fs.checkAttendeeLimit = func(_, _ uuid.UUID, n int) (bool, int, int, error) {
+		adding = n
+		return false, 10, 10, nil
+	}
+	body := `{"mode":"upsert","match_on":"email","data":[` +
+		`{"first_name":"Ann","last_name":"Lee","email":"ANN@x.com"},` +
+		`{"first_name":"Bo","last_name":"Ray","email":"bo@x.com"},` +
+		`{"first_name":"Bo","last_name":"Ray","email":"bo@x.com"}` +
+		`]}`
+	rec, _ := runExternalImport(t, fs, eventID, body)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403; body = %s", rec.Code, rec.Body.String())
+	}
+	if adding != 1 {
+		t.Fatalf("CheckAttendeeLimit adding = %d, want 1 (one new email)", adding)
+	}
+}
+
+// update_only never creates, so it skips the limit check entirely and
+// reports unmatched rows as failed.
+func TestExternalImportUpdateOnlyFailsUnmatchedRows(t *testing.T) {
+	eventID := uuid.New()
+	fs := externalImportFixture(eventID)
+	fs.checkAttendeeLimit = func(_, _ uuid.UUID, _ int) (bool, int, int, error) {
+		t.Fatal("CheckAttendeeLimit called for an update_only import")
+		return false, 0, 0, nil
+	}
+	fs.importAttendee = func(row store.AttendeeImportRow, _, matchOn string) (uuid.UUID, string, error) {
+		if matchOn != models.ImportMatchCode {
+			t.Errorf("matchOn = %q, want code", matchOn)
+		}
+		if *row.Code == "MISSING" {
+			return uuid.Nil, "", store.ErrImportNoMatch
+		}
+		return uuid.New(), models.ImportRowUpdated, nil
+	}
+	body := `{"mode":"update_only","match_on":"code","data":[{"code":"C1","company":"Acme"},{"code":"MISSING"}]}`
+	rec, resp := runExternalImport(t, fs, eventID, body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if resp.Results["updated"] != 1 || resp.Results["failed"] != 1 {
+		t.Fatalf("results = %v, want 1 updated / 1 failed", resp.Results)
+	}
+	if resp.Rows[1].Error != store.ErrImportNoMatch.Error() {
+		t.Errorf("row 2 error = %q, want %q", resp.Rows[1].Error, store.ErrImportNoMatch.Error())
+	}
+}
+
+func TestExternalImportRejectsUnknownModeAndMatch(t *testing.T) {
+	eventID := uuid.New()
+	for _, body := range []string{
+		`{"mode":"replace","data":[{"email":"a@x.com"}]}`,
+		`{"mode":"upsert","match_on":"phone","data":[{"email":"a@x.com"}]}`,
+	} {
+		rec, _ := runExternalImport(t, externalImportFixture(eventID), eventID, body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+}
+
+// Insert-only keeps the endpoint's original contract: every row inserts and
+// a store failure (e.g. duplicate code) becomes a "Row N: ..." error.
+func TestExternalImportInsertOnlyIsDefault(t *testing.T) {
+	eventID := uuid.New()
+	fs := externalImportFixture(eventID)
+	fs.importAttendee = func(row store.AttendeeImportRow, mode, _ string) (uuid.UUID, string, error) {
+		if mode != models.ImportModeInsertOnly {
+			t.Errorf("mode = %q, want insert_only", mode)
+		}
+		if row.FallbackCode == "" {
+			t.Error("insert row has no fallback code")
+		}
+		if row.Code != nil && *row.Code == "DUP" {
+			return uuid.Nil, "", errors.New("duplicate key value")
+		}
+		return uuid.New(), models.ImportRowCreated, nil
+	}
+	body := `{"data":[{"first_name":"a","last_name":"b","email":"a@x.com"},{"first_name":"c","last_name":"d","email":"c@x.com","code":"DUP"}]}`
+	rec, resp := runExternalImport(t, fs, eventID, body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if resp.Mode != models.ImportModeInsertOnly || resp.Results["created"] != 1 || resp.Results["failed"] != 1 {
+		t.Fatalf("response = %+v, want insert_only with 1 created / 1 failed", resp)
+	}
+	if len(resp.Errors) != 1 || !strings.HasPrefix(resp.Errors[0], "Row 2: ") {
+		t.Errorf("errors = %v, want one Row 2 error", resp.Errors)
+	}
+}
+
+// A dry run previews the valid rows in one store call, never imports or
+// touches field_schema, and hands out no ID for a would-be insert.
+func TestExternalImportDryRunPreviewsWithoutWriting(t *testing.T) {
+	eventID := uuid.New()
+	fs := externalImportFixture(eventID)
+	fs.existingImportMatchKeys = func(uuid.UUID, string, []string) (map[string]bool, error) {
+		return map[string]bool{"A-1": true}, nil
+	}
+	fs.importAttendee = func(store.AttendeeImportRow, string, string) (uuid.UUID, string, error) {
+		t.Fatal("ImportAttendee called on a dry run")
+		return uuid.Nil, "", nil
+	}
+	fs.updateEvent = func(*models.Event) error {
+		t.Fatal("UpdateEvent called on a dry run")
+		return nil
+	}
+	existing := uuid.New()
+	fs.previewAttendeeImport = func(rows []store.AttendeeImportRow, _, _ string) ([]store.ImportOutcome, error) {
+		if len(rows) != 2 {
+			t.Fatalf("previewed %d rows, want the 2 valid ones", len(rows))
+		}
+		return []store.ImportOutcome{
+			{ID: existing, Status: models.ImportRowUpdated},
+			{Status: models.ImportRowCreated},
+		}, nil
+	}
+
+	body := `{"mode":"upsert","data":[` +
+		`{"external_id":"A-1","first_name":"Ann","last_name":"Lee","email":"ann@x.com","badge":"VIP"},` +
+		`{"first_name":"No","last_name":"Key","email":"nokey@x.com"},` +
+		`{"external_id":"A-3","first_name":"Cy","last_name":"Do","email":"cy@x.com"}` +
+		`]}`
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/public/import?dry_run=true", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set(string(middleware.EventIDKey), eventID)
+	if err := (&Handler{Store: fs}).ExternalImport(c); err != nil {
+		t.Fatalf("handler error: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		externalImportResponse
+		DryRun        bool               `json:"dry_run"`
+		SchemaChanges FieldSchemaChanges `json:"schema_changes"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !resp.DryRun || resp.Results["updated"] != 1 || resp.Results["created"] != 1 || resp.Results["failed"] != 1 {
+		t.Fatalf("response = %+v, want a dry run with 1 updated / 1 created / 1 failed", resp)
+	}
+	if r := resp.Rows; r[0].AttendeeID == nil || *r[0].AttendeeID != existing || r[1].Status != models.ImportRowFailed || r[2].AttendeeID != nil {
+		t.Errorf("rows = %+v, want row 1 with the matched ID, row 2 failed, row 3 without an ID", r)
+	}
+	if len(resp.SchemaChanges.Added) != 1 || resp.SchemaChanges.Added[0] != "badge" {
+		t.Errorf("schema_changes = %+v, want badge added", resp.SchemaChanges)
+	}
+}
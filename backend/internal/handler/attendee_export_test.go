@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+	"idento/backend/internal/tabular"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func runExport(t *testing.T, h *Handler, tenantID, eventID uuid.UUID, query string) (*http.Response, string) {
+	t.Helper()
+	c, rec := newAuthedContext(echo.New(), http.MethodGet, "/api/events/"+eventID.String()+"/attendees/export?"+query, "", tenantID.String(), "admin")
+	c.SetParamNames("event_id")
+	c.SetParamValues(eventID.String())
+	if err := h.ExportAttendees(c); err != nil {
+		t.Fatalf("ExportAttendees: %v", err)
+	}
+	return rec.Result(), rec.Body.String()
+}
+
+// The export passes the list filters through to the store, resolves
+// check-in metadata and a per-zone entry matrix, and neutralizes formula
+// injection in CSV cells.
+func TestExportAttendeesFiltersColumnsAndZones(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	hall, vip := uuid.New(), uuid.New()
+	checkedIn := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	device, point, by := 3, "Gate A", "staff@example.com"
+	attendee := contractAttendee(event.ID)
+	attendee.CheckinStatus, attendee.CheckedInAt = true, &checkedIn
+	attendee.CheckedInDeviceNumber, attendee.CheckedInPointName, attendee.CheckedInByEmail = &device, &point, &by
+	attendee.PrintedCount = 2
+	attendee.CustomFields = map[string]interface{}{"note": "=HYPERLINK(\"x\")"}
+	externalID := "REG-42"
+	attendee.ExternalID = &externalID
+
+	var gotFilter store.AttendeeFilter
+	var gotZones bool
+	h := New(&fakeStore{
+		getEventByID: func(uuid.UUID) (*models.Event, error) { return event, nil },
+		getEventZones: func(uuid.UUID) ([]*models.EventZone, error) {
+			return []*models.EventZone{{ID: hall, Name: "Main hall"}, {ID: vip, Name: "VIP"}}, nil
+		},
+		streamAttendeeExport: func(_ uuid.UUID, f store.AttendeeFilter, withZones bool, fn func(*store.AttendeeExportRow) error) error {
+			gotFilter, gotZones = f, withZones
+			return fn(&store.AttendeeExportRow{
+				Attendee:    attendee,
+				ZoneEntries: map[uuid.UUID][]string{hall: {"2024-05-01 09:31:00", "2024-05-02 10:00:00"}},
+			})
+		},
+	})
+
+	res, body := runExport(t, h, tenantID, event.ID,
+		"status=checked_in&zone="+vip.String()+"&search=ada&columns=code,external_id,checked_in_at,checked_in_by,checked_in_device_number,checked_in_point_name,printed_count,note,zones")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", res.StatusCode, body)
+	}
+	if gotFilter.Status == nil || !*gotFilter.Status || gotFilter.ZoneID == nil || *gotFilter.ZoneID != vip || gotFilter.Search != "ada" || !gotZones {
+		t.Fatalf("filter = %+v, withZones = %v; want checked_in, zone VIP, search ada, zones on", gotFilter, gotZones)
+	}
+	table, err := tabular.Parse([]byte(body), "export.csv", tabular.Options{})
+	if err != nil {
+		t.Fatalf("parse export: %v", err)
+	}
+	wantHeader := "code|external_id|checked_in_at|checked_in_by|checked_in_device_number|checked_in_point_name|printed_count|note|Main hall|VIP"
+	if got := strings.Join(table.Header, "|"); got != wantHeader {
+		t.Fatalf("header = %s, want %s", got, wantHeader)
+	}
+	want := attendee.Code + `|REG-42|2024-05-01 09:30:00|staff@example.com|3|Gate A|2|'=HYPERLINK("x")|2024-05-01 09:31:00, 2024-05-02 10:00:00|`
+	if got := strings.Join(table.Rows[0], "|"); got != want {
+		t.Fatalf("row = %s, want %s", got, want)
+	}
+}
+
+func TestExportAttendeesXLSX(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	attendee := contractAttendee(event.ID)
+	h := New(&fakeStore{
+		getEventByID: func(uuid.UUID) (*models.Event, error) { return event, nil },
+		streamAttendeeExport: func(_ uuid.UUID, _ store.AttendeeFilter, withZones bool, fn func(*store.AttendeeExportRow) error) error {
+			if withZones {
+				t.Error("zones requested without a zones column")
+			}
+			return fn(&store.AttendeeExportRow{Attendee: attendee})
+		},
+	})
+	res, body := runExport(t, h, tenantID, event.ID, "format=xlsx")
+	if res.StatusCode != http.StatusOK || !strings.Contains(res.Header.Get("Content-Disposition"), ".xlsx") {
+		t.Fatalf("status = %d, disposition = %q", res.StatusCode, res.Header.Get("Content-Disposition"))
+	}
+	table, err := tabular.Parse([]byte(body), "export.xlsx", tabular.Options{})
+	if err != nil {
+		t.Fatalf("parse xlsx: %v", err)
+	}
+	if len(table.Rows) != 1 || table.Header[0] != "code" || table.Rows[0][0] != attendee.Code {
+		t.Fatalf("xlsx = %q / %q, want the default columns and one row", table.Header, table.Rows)
+	}
+}
+
+// A store failure before any bytes went out is a plain JSON 500, not a
+// broken download.
+func TestExportAttendeesEarlyFailureIsJSON(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	h := New(&fakeStore{
+		getEventByID: func(uuid.UUID) (*models.Event, error) { return event, nil },
+		streamAttendeeExport: func(uuid.UUID, store.AttendeeFilter, bool, func(*store.AttendeeExportRow) error) error {
+			return errors.New("cursor failed")
+		},
+	})
+	for _, query := range []string{"", "format=xlsx"} {
+		res, body := runExport(t, h, tenantID, event.ID, query)
+		if res.StatusCode != http.StatusInternalServerError || !strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") || res.Header.Get("Content-Disposition") != "" {
+			t.Errorf("%q: status = %d, headers = %v, body = %s", query, res.StatusCode, res.Header, body)
+		}
+	}
+}
+
+func TestExportAttendeesRejectsBadParams(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	h := New(&fakeStore{getEventByID: func(uuid.UUID) (*models.Event, error) { return event, nil }})
+	for _, query := range []string{"format=pdf", "status=maybe", "zone=nope"} {
+		if res, _ := runExport(t, h, tenantID, event.ID, query); res.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, res.StatusCode)
+		}
+	}
+}
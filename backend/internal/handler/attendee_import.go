@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"idento/backend/internal/tabular"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// Upload bounds for ImportAttendeesFile. The row cap matches the JSON bulk
+// route's documented maxItems, so both import paths accept the same batch;
+// an ?async=true import runs as a background job and may be ten times
+// larger.
+const (
+	attendeeImportMaxBytes     = 10 << 20
+	attendeeImportMaxRows      = 5000
+	attendeeImportMaxAsyncRows = 50000
+)
+
+// standardImportFields are the attendee columns a spreadsheet header maps to
+// without a preset, after normalizeImportHeader.
+var standardImportFields = map[string]bool{
+	"first_name": true,
+	"last_name":  true,
+	"email":      true,
+	"company":    true,
+	"position":   true,
+	"code":       true,
+}
+
+// importHeaderAliases are spellings of standard fields that common
+// registration-system exports use, keyed by their normalized form.
+var importHeaderAliases = map[string]string{
+	"e_mail":    "email",
+	"firstname": "first_name",
+	"lastname":  "last_name",
+}
+
+// normalizeImportHeader folds "First Name" / "first-name" / " EMAIL " /
+// "E-mail" onto the snake_case standard field names.
+func normalizeImportHeader(h string) string {
+	h = strings.ToLower(strings.TrimSpace(h))
+	h = strings.NewReplacer(" ", "_", "-", "_").Replace(h)
+	if alias, ok := importHeaderAliases[h]; ok {
+		return alias
+	}
+	return h
+}
+
+// importColumnTarget resolves the field a source header fills: an explicit
+// mapping entry (exact header first, then case-insensitive), else the
+// standard field the header normalizes to, else the header itself as a
+// custom field. "" means the column is dropped.
+func importColumnTarget(header string, columns map[string]string) string {
+	if target, ok := columns[header]; ok {
+		return strings.TrimSpace(target)
+	}
+	for src, target := range columns {
+		if strings.EqualFold(strings.TrimSpace(src), header) {
+			return strings.TrimSpace(target)
+		}
+	}
+	if n := normalizeImportHeader(header); standardImportFields[n] {
+		return n
+	}
+	return header
+}
+
+// applyImportMapping turns a parsed sheet into the row maps and
+// field_schema bulkCreateAttendees takes — the same shape the web client
+// sends to POST .../attendees/bulk. When two columns map to one field, the
+// first non-empty cell wins.
+func applyImportMapping(t *tabular.Table, columns map[string]string) ([]map[string]interface{}, []string) {
+	targets := make([]string, len(t.Header))
+	var fieldSchema []string
+	seen := map[string]bool{}
+	for i, header := range t.Header {
+		if header == "" {
+			continue
+		}
+		targets[i] = importColumnTarget(header, columns)
+		if targets[i] != "" && !seen[targets[i]] {
+			seen[targets[i]] = true
+			fieldSchema = append(fieldSchema, targets[i])
+		}
+	}
+
+	rows := make([]map[string]interface{}, 0, len(t.Rows))
+	for _, cells := range t.Rows {
+		row := make(map[string]interface{}, len(fieldSchema))
+		for i, cell := range cells {
+			target := targets[i]
+			if target == "" {
+				continue
+			}
+			if prev, ok := row[target].(string); ok && prev != "" {
+				continue
+			}
+			row[target] = cell
+		}
+		rows = append(rows, row)
+	}
+	return rows, fieldSchema
+}
+
+// ImportAttendeesFile imports attendees from an uploaded CSV or XLSX
+// (multipart field "file"), parsed server-side so clients don't each
+// re-implement delimiter/encoding detection and header mapping. Optional
+// form fields: mapping_id (a saved ImportMapping of the caller's tenant),
+// columns (a JSON object of header → field applied over the preset),
+// delimiter, encoding and sheet (see tabular.Options). The mapped rows then
+// go through exactly the BulkCreateAttendees pipeline — limit check,
+// field_schema update, duplicate skipping — and get the same response, or
+// with ?async=true the same 202 job.
+func (h *Handler) ImportAttendeesFile(c echo.Context) error {
+	eventID, err := uuid.Parse(c.Param("event_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid event ID"})
+	}
+	event, err := h.requireEventOwnership(c, eventID)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	if err := h.requirePermission(c, PermAttendeesEdit, eventID); err != nil {
+		return writeErr(c, err)
+	}
+
+	columns := map[string]string{}
+	if raw := c.FormValue("mapping_id"); raw != "" {
+		mappingID, err := uuid.Parse(raw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid mapping ID"})
+		}
+		mapping, err := h.Store.GetImportMapping(c.Request().Context(), event.TenantID, mappingID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load import mapping"})
+		}
+		if mapping == nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Import mapping not found"})
+		}
+		for src, target := range mapping.Columns {
+			columns[src] = target
+		}
+	}
+	if raw := c.FormValue("columns"); raw != "" {
+		var override map[string]string
+		if err := json.Unmarshal([]byte(raw), &override); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "columns must be a JSON object of header to field"})
+		}
+		for src, target := range override {
+			columns[src] = target
+		}
+	}
+
+	delimiter, err := tabular.Delimiter(c.FormValue("delimiter"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	opts := tabular.Options{
+		Delimiter: delimiter,
+		Encoding:  c.FormValue("encoding"),
+		Sheet:     c.FormValue("sheet"),
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Import file is required"})
+	}
+	if file.Size > attendeeImportMaxBytes {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Import file too large. Maximum size is 10MB"})
+	}
+	src, err := file.Open()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to read import file"})
+	}
+	defer func() {
+		if closeErr := src.Close(); closeErr != nil {
+			log.Printf("Failed to close file: %v", closeErr)
+		}
+	}()
+
+	table, err := tabular.Read(src, attendeeImportMaxBytes, file.Filename, opts)
+	if errors.Is(err, tabular.ErrTooLarge) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Import file too large. Maximum size is 10MB"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Failed to parse import file: " + err.Error()})
+	}
+	if len(table.Rows) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No attendees provided"})
+	}
+	if isAsync(c) && len(table.Rows) > attendeeImportMaxAsyncRows {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Too many rows. Maximum is 50000 per background import"})
+	}
+	if !isAsync(c) && len(table.Rows) > attendeeImportMaxRows {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Too many rows. Maximum is 5000 per import (use async=true for larger files)"})
+	}
+
+	rows, fieldSchema := applyImportMapping(table, columns)
+	return h.bulkCreateAttendees(c, event, rows, fieldSchema)
+}
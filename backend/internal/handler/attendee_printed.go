@@ -4,7 +4,9 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"time"
 
+	"idento/backend/internal/broker"
 	"idento/backend/internal/store"
 
 	"github.com/google/uuid"
@@ -174,7 +176,7 @@ func (h *Handler) MarkAttendeePrinted(c echo.Context) error {
 			// re-fetch would be a pointless round trip. publishCheckinEvent
 			// (Finding B2) is nil-safe, best-effort, detached,
 			// timeout-bounded — after the row already committed.
-			h.publishCheckinEvent(c.Request().Context(), *eventID)
+			h.publishMonitorMessage(c.Request().Context(), broker.Message{Key: *eventID, Kind: broker.KindReprint, AttendeeID: &attendeeID, StationID: stationID, At: time.Now().UTC()})
 		}
 	}
 
@@ -1,6 +1,9 @@
 package handler
 
 import (
+	"errors"
+	"idento/backend/internal/broker"
+	"idento/backend/internal/metrics"
 	"idento/backend/internal/models"
 	"idento/backend/internal/store"
 	"log"
@@ -53,6 +56,9 @@ func (h *Handler) CreateAttendee(c echo.Context) error {
 	if err != nil {
 		return writeErr(c, err)
 	}
+	if err := h.requirePermission(c, PermAttendeesEdit, eventID); err != nil {
+		return writeErr(c, err)
+	}
 	tenantID := event.TenantID
 
 	attendee := &models.Attendee{
@@ -80,6 +86,7 @@ func (h *Handler) CreateAttendee(c echo.Context) error {
 	// in-flight event with no station heartbeat would show a stale total
 	// indefinitely.
 	h.publishCheckinEvent(c.Request().Context(), eventID)
+	h.pushStationMessage(c.Request().Context(), eventID, broker.KindAttendee, &attendee.ID)
 
 	// Log usage (best-effort, do not fail request)
 	if err := h.Store.LogUsage(c.Request().Context(), &models.UsageLog{
@@ -160,17 +167,40 @@ func (h *Handler) GetAttendees(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "page is too large"})
 	}
 
+	filter, err := attendeeFilterFromQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	filter.Page = page
+	filter.PerPage = perPage
+
+	attendees, total, err := h.Store.GetAttendeesPage(c.Request().Context(), eventID, filter)
+	if err != nil {
+		c.Logger().Error("Failed to fetch attendees: ", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch attendees"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"attendees": attendees,
+		"total":     total,
+		"page":      page,
+		"per_page":  perPage,
+	})
+}
+
+// attendeeFilterFromQuery reads the code/search/zone/status query params
+// shared by the paged attendee list and the export into an AttendeeFilter
+// (paging left unset). The error text is the 400 message.
+func attendeeFilterFromQuery(c echo.Context) (store.AttendeeFilter, error) {
 	filter := store.AttendeeFilter{
-		Code:    code,
-		Search:  search,
-		Page:    page,
-		PerPage: perPage,
+		Code:   c.QueryParam("code"),
+		Search: c.QueryParam("search"),
 	}
 
 	if zoneParam := c.QueryParam("zone"); zoneParam != "" {
 		zoneID, err := uuid.Parse(zoneParam)
 		if err != nil {
-			return c.JSON(http.StatusBadRequest, map[string]string{"error": "zone must be a valid UUID"})
+			return filter, errors.New("zone must be a valid UUID")
 		}
 		filter.ZoneID = &zoneID
 	}
@@ -184,22 +214,10 @@ func (h *Handler) GetAttendees(c echo.Context) error {
 			notCheckedIn := false
 			filter.Status = &notCheckedIn
 		default:
-			return c.JSON(http.StatusBadRequest, map[string]string{"error": "status must be checked_in or not_checked_in"})
+			return filter, errors.New("status must be checked_in or not_checked_in")
 		}
 	}
-
-	attendees, total, err := h.Store.GetAttendeesPage(c.Request().Context(), eventID, filter)
-	if err != nil {
-		c.Logger().Error("Failed to fetch attendees: ", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch attendees"})
-	}
-
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"attendees": attendees,
-		"total":     total,
-		"page":      page,
-		"per_page":  perPage,
-	})
+	return filter, nil
 }
 
 // GetAttendeeDetail fetches a single attendee by id, scoped to the caller's
@@ -231,6 +249,10 @@ func (h *Handler) UpdateAttendeeInfo(c echo.Context) error {
 	if err != nil {
 		return writeErr(c, err)
 	}
+	if err := h.requirePermission(c, PermAttendeesEdit, attendee.EventID); err != nil {
+		return writeErr(c, err)
+	}
+	before := *attendee
 
 	// Bind update request
 	var req UpdateAttendeeRequest
@@ -269,9 +291,11 @@ func (h *Handler) UpdateAttendeeInfo(c echo.Context) error {
 	if err := h.Store.UpdateAttendee(c.Request().Context(), attendee); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update attendee"})
 	}
+	h.logTenantDiff(c, &attendee.EventID, auditUpdateAttendee, "attendee", attendee.ID, &before, attendee, attendeeAuditIgnore...)
 
 	// PR #81 round-5: publish the update so the monitor's last-scans feed stays current
 	h.publishCheckinEvent(c.Request().Context(), attendee.EventID)
+	h.pushStationMessage(c.Request().Context(), attendee.EventID, broker.KindAttendee, &attendee.ID)
 
 	return c.JSON(http.StatusOK, attendee)
 }
@@ -287,6 +311,7 @@ func (h *Handler) UpdateAttendeeHandler(c echo.Context) error {
 	if err != nil {
 		return writeErr(c, err)
 	}
+	before := *existingAttendee
 
 	// Bind update request
 	var req struct {
@@ -296,6 +321,12 @@ func (h *Handler) UpdateAttendeeHandler(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
 	}
+	// Clearing a check-in is an undo.
+	if !req.CheckinStatus && existingAttendee.CheckinStatus {
+		if err := h.requirePermission(c, PermCheckinUndo, existingAttendee.EventID); err != nil {
+			return writeErr(c, err)
+		}
+	}
 
 	// Get current user from JWT token
 	user, err := claimsFromContext(c)
@@ -362,6 +393,7 @@ func (h *Handler) UpdateAttendeeHandler(c echo.Context) error {
 		// already committed, so failing the request here would report a
 		// write that DID happen as failed.
 		if req.CheckinStatus {
+			metrics.Checkins.WithLabelValues("legacy", "checked_in").Inc()
 			// false -> true: station-less 'checkin' row stamped with the
 			// EXACT CheckedInAt the claim persisted, so the monitor's
 			// current-period predicate (ca.created_at >= a.checked_in_at)
@@ -373,6 +405,7 @@ func (h *Handler) UpdateAttendeeHandler(c echo.Context) error {
 			// true -> false: symmetric 'undo' row (nil at -> now()); makes
 			// legacy clears visible to the feed and to the monitor's
 			// latest-state attribution directly.
+			metrics.CheckinUndos.Inc()
 			if err := h.Store.InsertCheckinActionAt(c.Request().Context(), existingAttendee.EventID, existingAttendee.ID, "undo", nil, &userID, nil); err != nil {
 				c.Logger().Errorf("attendee PUT: undo feed row insert failed (event %s, attendee %s): %v", existingAttendee.EventID, existingAttendee.ID, err)
 			}
@@ -382,6 +415,7 @@ func (h *Handler) UpdateAttendeeHandler(c echo.Context) error {
 	if err := h.Store.UpdateAttendee(c.Request().Context(), existingAttendee); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update attendee"})
 	}
+	h.logTenantDiff(c, &existingAttendee.EventID, auditSetAttendeeCheckin, "attendee", existingAttendee.ID, &before, existingAttendee, attendeeAuditIgnore...)
 
 	// Finding B3 (PR #81): publish only when checkin_status actually
 	// flipped — gated on the claim's verdict, after the full row write
@@ -389,6 +423,7 @@ func (h *Handler) UpdateAttendeeHandler(c echo.Context) error {
 	// snapshot refetch already sees the new row.
 	if flipped {
 		h.publishCheckinEvent(c.Request().Context(), existingAttendee.EventID)
+		h.pushStationMessage(c.Request().Context(), existingAttendee.EventID, broker.KindAttendee, &existingAttendee.ID)
 	}
 
 	return c.JSON(http.StatusOK, existingAttendee)
@@ -412,6 +447,11 @@ func (h *Handler) BlockAttendee(c echo.Context) error {
 	if err != nil {
 		return writeErr(c, err)
 	}
+	if err := h.requirePermission(c, PermAttendeesBlock, attendee.EventID); err != nil {
+		return writeErr(c, err)
+	}
+
+	before := *attendee
 
 	// Block attendee with reason
 	attendee.Blocked = true
@@ -421,6 +461,8 @@ func (h *Handler) BlockAttendee(c echo.Context) error {
 	if err := h.Store.UpdateAttendee(c.Request().Context(), attendee); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update attendee"})
 	}
+	h.logTenantDiff(c, &attendee.EventID, auditBlockAttendee, "attendee", attendee.ID, &before, attendee, attendeeAuditIgnore...)
+	h.pushStationMessage(c.Request().Context(), attendee.EventID, broker.KindAttendee, &attendee.ID)
 
 	return c.JSON(http.StatusOK, attendee)
 }
@@ -436,6 +478,11 @@ func (h *Handler) UnblockAttendee(c echo.Context) error {
 	if err != nil {
 		return writeErr(c, err)
 	}
+	if err := h.requirePermission(c, PermAttendeesBlock, attendee.EventID); err != nil {
+		return writeErr(c, err)
+	}
+
+	before := *attendee
 
 	// Unblock attendee
 	attendee.Blocked = false
@@ -445,6 +492,8 @@ func (h *Handler) UnblockAttendee(c echo.Context) error {
 	if err := h.Store.UpdateAttendee(c.Request().Context(), attendee); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update attendee"})
 	}
+	h.logTenantDiff(c, &attendee.EventID, auditUnblockAttendee, "attendee", attendee.ID, &before, attendee, attendeeAuditIgnore...)
+	h.pushStationMessage(c.Request().Context(), attendee.EventID, broker.KindAttendee, &attendee.ID)
 
 	return c.JSON(http.StatusOK, attendee)
 }
@@ -460,14 +509,21 @@ func (h *Handler) DeleteAttendee(c echo.Context) error {
 	if err != nil {
 		return writeErr(c, err)
 	}
+	if err := h.requirePermission(c, PermAttendeesEdit, attendee.EventID); err != nil {
+		return writeErr(c, err)
+	}
 
 	// Soft delete
+	before := *attendee
 	now := time.Now()
 	attendee.DeletedAt = &now
 
 	if err := h.Store.UpdateAttendee(c.Request().Context(), attendee); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete attendee"})
 	}
+	// The whole record goes into the entry: a deleted attendee is no longer
+	// readable through the API.
+	h.logTenantDiff(c, &attendee.EventID, auditDeleteAttendee, "attendee", attendee.ID, &before, nil, attendeeAuditIgnore...)
 
 	// PR #81 round-3 convergence, Backend Finding 3: a deleted attendee
 	// changes the monitor's `total` (and `checked_in` too, if they were
@@ -477,6 +533,7 @@ func (h *Handler) DeleteAttendee(c echo.Context) error {
 	// loop, not a dedicated batch endpoint), so this one publish site also
 	// covers that case — one publish per request, N requests for N deletes.
 	h.publishCheckinEvent(c.Request().Context(), attendee.EventID)
+	h.pushStationMessage(c.Request().Context(), attendee.EventID, broker.KindAttendee, &attendee.ID)
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Attendee deleted successfully"})
 }
@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"idento/backend/internal/audit"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// tenantAuditDefaultLimit and tenantAuditMaxLimit bound GET /api/audit-log.
+const (
+	tenantAuditDefaultLimit = 50
+	tenantAuditMaxLimit     = 200
+)
+
+// Tenant audit actions.
+const (
+	auditUpdateAttendee        = "update_attendee"
+	auditSetAttendeeCheckin    = "set_attendee_checkin"
+	auditBlockAttendee         = "block_attendee"
+	auditUnblockAttendee       = "unblock_attendee"
+	auditDeleteAttendee        = "delete_attendee"
+	auditUpdateEvent           = "update_event"
+	auditDeleteEvent           = "delete_event"
+	auditCloneEvent            = "clone_event"
+	auditCreateZoneAccessRule  = "create_zone_access_rule"
+	auditReplaceZoneAccessRule = "replace_zone_access_rules"
+	auditAssignEventStaff      = "assign_event_staff"
+	auditUnassignEventStaff    = "unassign_event_staff"
+	auditAssignZoneStaff       = "assign_zone_staff"
+	auditRemoveZoneStaff       = "remove_zone_staff"
+)
+
+// attendeeAuditIgnore are the attendee fields a diff leaves out: bookkeeping
+// that changes on every write.
+var attendeeAuditIgnore = []string{"updated_at"}
+
+// eventAuditIgnore is attendeeAuditIgnore for events.
+var eventAuditIgnore = []string{"created_at", "updated_at"}
+
+// zoneRulesAuditView keys a zone's access rules by category, so a rule set
+// replacement diffs as "VIP.allowed": false -> true rather than as two
+// unrelated lists.
+func zoneRulesAuditView(rules []*models.ZoneAccessRule) map[string]interface{} {
+	view := make(map[string]interface{}, len(rules))
+	for _, r := range rules {
+		view[r.Category] = map[string]interface{}{
+			"allowed":   r.Allowed,
+			"time_from": r.TimeFrom,
+			"time_to":   r.TimeTo,
+		}
+	}
+	return view
+}
+
+// auditDiff is audit.Diff for handlers: a value that cannot be diffed is
+// logged and recorded as an empty change set rather than failing the
+// request that already happened.
+func auditDiff(before, after interface{}, ignore ...string) map[string]audit.Change {
+	d, err := audit.Diff(before, after, ignore...)
+	if err != nil {
+		log.Printf("Failed to diff audit values: %v", err)
+		return map[string]audit.Change{}
+	}
+	return d
+}
+
+// logTenantAction appends a tenant audit entry for the request's actor,
+// and for the provisioned station whose token made the request, if any:
+// the station comes from the verified token, never from anything the
+// client merely says, so an entry can't be pinned on another device.
+// Best-effort like LogUsage and LogAdminAction: the change already
+// happened, so an audit failure is logged, never returned.
+func (h *Handler) logTenantAction(c echo.Context, eventID *uuid.UUID, action, targetType string, targetID uuid.UUID, changes interface{}) {
+	claims, err := claimsFromContext(c)
+	if err != nil {
+		return
+	}
+	tenantID, err := uuid.Parse(claims.TenantID)
+	if err != nil {
+		return
+	}
+	raw, err := json.Marshal(changes)
+	if err != nil {
+		log.Printf("Failed to marshal audit changes (%s): %v", action, err)
+		raw = nil
+	}
+	ip, ua := c.RealIP(), c.Request().UserAgent()
+	entry := &models.TenantAuditEntry{
+		TenantID:   tenantID,
+		EventID:    eventID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   &targetID,
+		Changes:    raw,
+		IPAddress:  &ip,
+		UserAgent:  &ua,
+	}
+	if id, err := uuid.Parse(claims.UserID); err == nil {
+		entry.ActorUserID = &id
+	}
+	if id, err := uuid.Parse(claims.ImpersonatedBy); err == nil {
+		entry.ImpersonatedBy = &id
+	}
+	if id, err := uuid.Parse(claims.StationID); err == nil {
+		entry.StationID = &id
+	}
+	if err := h.Store.LogTenantAction(c.Request().Context(), entry); err != nil {
+		log.Printf("Failed to log tenant action %s on %s %s: %v", action, targetType, targetID, err)
+	}
+}
+
+// logTenantDiff logs action with the diff between before and after, and
+// nothing at all when the two do not differ.
+func (h *Handler) logTenantDiff(c echo.Context, eventID *uuid.UUID, action, targetType string, targetID uuid.UUID, before, after interface{}, ignore ...string) {
+	d := auditDiff(before, after, ignore...)
+	if len(d) == 0 {
+		return
+	}
+	h.logTenantAction(c, eventID, action, targetType, targetID, d)
+}
+
+// parseAuditUUID reads an optional UUID query param into dst.
+func parseAuditUUID(c echo.Context, name string, dst **uuid.UUID) error {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return nil
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return newHTTPError(http.StatusBadRequest, "Invalid "+name)
+	}
+	*dst = &id
+	return nil
+}
+
+// parseAuditTime reads an optional from/to query param: RFC 3339, or a
+// YYYY-MM-DD date meaning that day's start (from) or the next day's (to),
+// so ?from=2026-10-01&to=2026-10-01 is the whole day.
+func parseAuditTime(c echo.Context, name string, endOfDay bool, dst **time.Time) error {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		day, derr := time.Parse("2006-01-02", raw)
+		if derr != nil {
+			return newHTTPError(http.StatusBadRequest, "Invalid "+name+": use RFC 3339 or YYYY-MM-DD")
+		}
+		if endOfDay {
+			day = day.AddDate(0, 0, 1)
+		}
+		t = day
+	}
+	*dst = &t
+	return nil
+}
+
+// GetTenantAuditLog serves GET /api/audit-log: the caller's tenant's audit
+// trail, newest first, for tenant admins. Filters: event_id, actor_user_id,
+// station_id, target_id, action, target_type, from, to; paging: limit
+// (default 50, max 200) and offset.
+func (h *Handler) GetTenantAuditLog(c echo.Context) error {
+	tenantID, err := requireTenantAdmin(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+
+	f := store.TenantAuditFilter{
+		Action:     c.QueryParam("action"),
+		TargetType: c.QueryParam("target_type"),
+		Limit:      tenantAuditDefaultLimit,
+	}
+	for _, p := range []struct {
+		name string
+		dst  **uuid.UUID
+	}{
+		{"event_id", &f.EventID},
+		{"actor_user_id", &f.ActorUserID},
+		{"station_id", &f.StationID},
+		{"target_id", &f.TargetID},
+	} {
+		if err := parseAuditUUID(c, p.name, p.dst); err != nil {
+			return writeErr(c, err)
+		}
+	}
+	if err := parseAuditTime(c, "from", false, &f.From); err != nil {
+		return writeErr(c, err)
+	}
+	if err := parseAuditTime(c, "to", true, &f.To); err != nil {
+		return writeErr(c, err)
+	}
+	if raw := c.QueryParam("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > tenantAuditMaxLimit {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("limit must be between 1 and %d", tenantAuditMaxLimit)})
+		}
+		f.Limit = n
+	}
+	if raw := c.QueryParam("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "offset must be a non-negative integer"})
+		}
+		f.Offset = n
+	}
+
+	entries, total, err := h.Store.GetTenantAuditLog(c.Request().Context(), tenantID, f)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get audit log"})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"logs":   entries,
+		"total":  total,
+		"limit":  f.Limit,
+		"offset": f.Offset,
+	})
+}
@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func auditAttendee(eventID uuid.UUID) *models.Attendee {
+	return &models.Attendee{
+		ID:           uuid.New(),
+		EventID:      eventID,
+		FirstName:    "Ada",
+		LastName:     "Lovelace",
+		Code:         "A-1",
+		CustomFields: map[string]interface{}{"category": "Standard"},
+		UpdatedAt:    time.Now().Add(-time.Hour),
+	}
+}
+
+func auditAttendeeStore(event *models.Event, attendee *models.Attendee) *fakeStore {
+	return &fakeStore{
+		getEventByID:    func(uuid.UUID) (*models.Event, error) { return event, nil },
+		getAttendeeByID: func(uuid.UUID) (*models.Attendee, error) { return attendee, nil },
+		updateAttendee:  func(*models.Attendee) error { return nil },
+	}
+}
+
+func attendeeContext(e *echo.Echo, method, action, body string, tenantID, attendeeID uuid.UUID) (echo.Context, *http.Request, func() int) {
+	path := "/api/attendees/" + attendeeID.String() + action
+	c, rec := newAuthedContext(e, method, path, body, tenantID.String(), "admin")
+	c.SetPath("/api/attendees/:id" + action)
+	c.SetParamNames("id")
+	c.SetParamValues(attendeeID.String())
+	return c, c.Request(), func() int { return rec.Code }
+}
+
+func decodeChanges(t *testing.T, entry *models.TenantAuditEntry) map[string]map[string]interface{} {
+	t.Helper()
+	var changes map[string]map[string]interface{}
+	if err := json.Unmarshal(entry.Changes, &changes); err != nil {
+		t.Fatalf("decode changes %s: %v", entry.Changes, err)
+	}
+	return changes
+}
+
+// Blocking records who did it, from which station, and exactly the fields
+// that changed — updated_at is bookkeeping and left out. The station is
+// the token's: a header naming another one is ignored.
+func TestBlockAttendee_LogsTenantAuditDiff(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	attendee := auditAttendee(event.ID)
+	fs := auditAttendeeStore(event, attendee)
+	h := New(fs)
+
+	stationID := uuid.New()
+	c, req, code := attendeeContext(echo.New(), http.MethodPost, "/block", `{"reason":"duplicate badge"}`, tenantID, attendee.ID)
+	c.Get("user").(*models.JWTCustomClaims).StationID = stationID.String()
+	req.Header.Set("X-Station-ID", uuid.NewString())
+	if err := h.BlockAttendee(c); err != nil {
+		t.Fatalf("BlockAttendee: %v", err)
+	}
+	if code() != http.StatusOK {
+		t.Fatalf("want 200, got %d", code())
+	}
+
+	if len(fs.tenantAudit) != 1 {
+		t.Fatalf("tenant audit entries = %d, want 1", len(fs.tenantAudit))
+	}
+	entry := fs.tenantAudit[0]
+	claims, _ := claimsFromContext(c)
+	if entry.TenantID != tenantID || entry.Action != auditBlockAttendee || entry.TargetType != "attendee" ||
+		*entry.TargetID != attendee.ID || *entry.EventID != event.ID {
+		t.Errorf("entry = %+v", entry)
+	}
+	if entry.ActorUserID == nil || entry.ActorUserID.String() != claims.UserID {
+		t.Errorf("actor = %v, want %s", entry.ActorUserID, claims.UserID)
+	}
+	if entry.StationID == nil || *entry.StationID != stationID {
+		t.Errorf("station = %v, want %s", entry.StationID, stationID)
+	}
+	changes := decodeChanges(t, entry)
+	if len(changes) != 2 || changes["blocked"]["to"] != true || changes["block_reason"]["to"] != "duplicate badge" {
+		t.Errorf("changes = %v, want blocked and block_reason only", changes)
+	}
+}
+
+// An update that changes nothing leaves no entry; a custom field change
+// is recorded under its dotted key.
+func TestUpdateAttendeeInfo_LogsOnlyRealChanges(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	attendee := auditAttendee(event.ID)
+	fs := auditAttendeeStore(event, attendee)
+	h := New(fs)
+	e := echo.New()
+
+	c, _, _ := attendeeContext(e, http.MethodPatch, "", `{"first_name":"Ada"}`, tenantID, attendee.ID)
+	if err := h.UpdateAttendeeInfo(c); err != nil {
+		t.Fatalf("UpdateAttendeeInfo: %v", err)
+	}
+	if len(fs.tenantAudit) != 0 {
+		t.Fatalf("no-op update logged %d entries", len(fs.tenantAudit))
+	}
+
+	c, _, _ = attendeeContext(e, http.MethodPatch, "", `{"custom_fields":{"category":"VIP"}}`, tenantID, attendee.ID)
+	if err := h.UpdateAttendeeInfo(c); err != nil {
+		t.Fatalf("UpdateAttendeeInfo: %v", err)
+	}
+	if len(fs.tenantAudit) != 1 {
+		t.Fatalf("tenant audit entries = %d, want 1", len(fs.tenantAudit))
+	}
+	changes := decodeChanges(t, fs.tenantAudit[0])
+	if c := changes["custom_fields.category"]; c["from"] != "Standard" || c["to"] != "VIP" || len(changes) != 1 {
+		t.Errorf("changes = %v, want custom_fields.category Standard -> VIP", changes)
+	}
+}
+
+// A deleted attendee is no longer readable, so its entry keeps the record.
+func TestDeleteAttendee_LogsRemovedRecord(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	attendee := auditAttendee(event.ID)
+	fs := auditAttendeeStore(event, attendee)
+	h := New(fs)
+
+	c, _, code := attendeeContext(echo.New(), http.MethodDelete, "", "", tenantID, attendee.ID)
+	if err := h.DeleteAttendee(c); err != nil {
+		t.Fatalf("DeleteAttendee: %v", err)
+	}
+	if code() != http.StatusOK {
+		t.Fatalf("want 200, got %d", code())
+	}
+	if len(fs.tenantAudit) != 1 || fs.tenantAudit[0].Action != auditDeleteAttendee {
+		t.Fatalf("tenant audit = %+v, want one delete_attendee entry", fs.tenantAudit)
+	}
+	changes := decodeChanges(t, fs.tenantAudit[0])
+	if changes["last_name"]["from"] != "Lovelace" || changes["last_name"]["to"] != nil || changes["code"]["from"] != "A-1" {
+		t.Errorf("changes = %v, want the deleted attendee's fields", changes)
+	}
+}
+
+func TestContractGetTenantAuditLog(t *testing.T) {
+	tenantID := uuid.New()
+	eventID, actorID, targetID := uuid.New(), uuid.New(), uuid.New()
+	email := "admin@example.com"
+	var got store.TenantAuditFilter
+	h := New(&fakeStore{
+		getTenantAuditLog: func(tid uuid.UUID, f store.TenantAuditFilter) ([]*models.TenantAuditEntry, int, error) {
+			if tid != tenantID {
+				t.Errorf("tenant = %s, want %s", tid, tenantID)
+			}
+			got = f
+			return []*models.TenantAuditEntry{{
+				ID:          uuid.New(),
+				TenantID:    tenantID,
+				EventID:     &eventID,
+				ActorUserID: &actorID,
+				ActorEmail:  &email,
+				Action:      auditBlockAttendee,
+				TargetType:  "attendee",
+				TargetID:    &targetID,
+				Changes:     json.RawMessage(`{"blocked":{"from":false,"to":true}}`),
+				CreatedAt:   time.Now(),
+			}}, 7, nil
+		},
+	})
+	e := echo.New()
+
+	path := "/api/audit-log?event_id=" + eventID.String() + "&action=block_attendee&from=2026-10-01&to=2026-10-01&limit=1&offset=2"
+	c, rec := newAuthedContext(e, http.MethodGet, path, "", tenantID.String(), "admin")
+	c.SetPath("/api/audit-log")
+	if err := h.GetTenantAuditLog(c); err != nil {
+		t.Fatalf("GetTenantAuditLog: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodGet, path, rec)
+	if *got.EventID != eventID || got.Action != "block_attendee" || got.Limit != 1 || got.Offset != 2 {
+		t.Errorf("filter = %+v", got)
+	}
+	if !got.From.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)) || !got.To.Equal(time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("from/to = %v/%v, want the whole of 2026-10-01", got.From, got.To)
+	}
+
+	for _, bad := range []string{"?event_id=nope", "?from=yesterday", "?limit=500", "?offset=-1"} {
+		c, rec = newAuthedContext(e, http.MethodGet, "/api/audit-log"+bad, "", tenantID.String(), "admin")
+		c.SetPath("/api/audit-log")
+		if err := h.GetTenantAuditLog(c); err != nil {
+			t.Fatalf("GetTenantAuditLog: %v", err)
+		}
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: want 400, got %d", bad, rec.Code)
+		}
+		validateResponse(t, http.MethodGet, "/api/audit-log", rec)
+	}
+
+	c, rec = newAuthedContext(e, http.MethodGet, "/api/audit-log", "", tenantID.String(), "manager")
+	c.SetPath("/api/audit-log")
+	if err := h.GetTenantAuditLog(c); err != nil {
+		t.Fatalf("GetTenantAuditLog: %v", err)
+	}
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("manager: want 403, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodGet, "/api/audit-log", rec)
+
+	hFail := New(&fakeStore{
+		getTenantAuditLog: func(uuid.UUID, store.TenantAuditFilter) ([]*models.TenantAuditEntry, int, error) {
+			return nil, 0, errors.New("db down")
+		},
+	})
+	c, rec = newAuthedContext(e, http.MethodGet, "/api/audit-log", "", tenantID.String(), "admin")
+	c.SetPath("/api/audit-log")
+	if err := hFail.GetTenantAuditLog(c); err != nil {
+		t.Fatalf("GetTenantAuditLog: %v", err)
+	}
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "Failed to get audit log") {
+		t.Fatalf("want 500, got %d %s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodGet, "/api/audit-log", rec)
+}
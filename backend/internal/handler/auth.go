@@ -2,8 +2,6 @@ package handler
 
 import (
 	"errors"
-	"fmt"
-	"idento/backend/internal/config"
 	"idento/backend/internal/models"
 	"idento/backend/internal/store"
 	"log"
@@ -11,7 +9,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/crypto/bcrypt"
@@ -22,6 +19,8 @@ type RegisterRequest struct {
 	TenantName string `json:"tenant_name"`
 	Email      string `json:"email"`
 	Password   string `json:"password"`
+	// Locale of the verification email; see ForgotPasswordRequest.
+	Locale string `json:"locale,omitempty"`
 }
 
 // LoginRequest is the JSON body for POST /auth/login.
@@ -63,13 +62,28 @@ func (h *Handler) Register(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create tenant"})
 	}
 
-	// 4. Generate Token with this tenant
-	token, err := generateTokenForTenant(existingUser, tenant.ID.String(), "admin")
+	// 4. An existing account with two-factor enabled must pass it before
+	// getting a session; the challenge lands in the new tenant.
+	if existingUser.TwoFactorEnabled {
+		return h.twoFactorChallenge(c, http.StatusCreated, existingUser.ID, &tenant.ID)
+	}
+
+	// 5. Start a session in this tenant
+	session, err := h.startSession(c, existingUser.ID, tenant.ID, "admin", nil, false)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
 	}
 
-	// 5. Get all user's tenants for response
+	// 6. Mail a verification link to a new (or still unverified) account.
+	// Best-effort: the account works either way and the user can ask for
+	// another link.
+	if existingUser.EmailVerifiedAt == nil {
+		if err := h.sendVerificationEmail(c.Request().Context(), existingUser, requestLocale(c, req.Locale)); err != nil {
+			log.Printf("Failed to send verification email to user %s: %v", existingUser.ID, err)
+		}
+	}
+
+	// 7. Get all user's tenants for response
 	tenants, err := h.Store.GetUserTenants(c.Request().Context(), existingUser.ID)
 	if err != nil {
 		// Log error but continue with empty list
@@ -78,13 +92,16 @@ func (h *Handler) Register(c echo.Context) error {
 	}
 
 	return c.JSON(http.StatusCreated, map[string]interface{}{
-		"token":   token,
-		"user":    existingUser,
-		"tenants": tenants,
+		"token":         session.Token,
+		"refresh_token": session.RefreshToken,
+		"expires_at":    session.ExpiresAt,
+		"user":          existingUser,
+		"tenants":       tenants,
 	})
 }
 
-// Login authenticates a user by email and password and returns a JWT and user info.
+// Login authenticates a user by email and password and returns a JWT and user info,
+// or a two-factor challenge when the account has TOTP enabled (see LoginTwoFactor).
 // Returns 400 on invalid input, 401 on wrong credentials or no tenants, 500 on store or token errors.
 func (h *Handler) Login(c echo.Context) error {
 	req := new(LoginRequest)
@@ -111,38 +128,59 @@ func (h *Handler) Login(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid credentials"})
 	}
 
-	// 3. Get all user's tenants
+	// 3. With two-factor enabled, the password only earns a challenge for
+	// POST /auth/login/2fa.
+	if user.TwoFactorEnabled {
+		return h.twoFactorChallenge(c, http.StatusOK, user.ID, nil)
+	}
+	return h.completeLogin(c, user, nil, false)
+}
+
+// completeLogin starts a session for an authenticated user in preferTenant
+// (when set and the user is a member), else their original tenant, else
+// their first one, and writes the login response. twoFactor marks a
+// sign-in that passed a second factor.
+func (h *Handler) completeLogin(c echo.Context, user *models.User, preferTenant *uuid.UUID, twoFactor bool) error {
+	// 1. Get all user's tenants
 	tenants, err := h.Store.GetUserTenants(c.Request().Context(), user.ID)
 	if err != nil || len(tenants) == 0 {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "No organizations found"})
 	}
 
-	// 4. Use first tenant as default (or the original tenant_id if available)
+	// 2. Use first tenant as default (or the preferred / original tenant_id if available)
 	defaultTenant := tenants[0]
-	if user.TenantID != uuid.Nil {
-		// Check if user's original tenant is in the list
+	for _, want := range []*uuid.UUID{preferTenant, &user.TenantID} {
+		if want == nil || *want == uuid.Nil {
+			continue
+		}
+		found := false
 		for _, t := range tenants {
-			if t.ID == user.TenantID {
-				defaultTenant = t
+			if t.ID == *want {
+				defaultTenant, found = t, true
 				break
 			}
 		}
+		if found {
+			break
+		}
 	}
 
-	// 5. Get role in this tenant
+	// 3. Get role in this tenant
 	role, err := h.Store.GetUserTenantRole(c.Request().Context(), user.ID, defaultTenant.ID)
 	if err != nil {
 		role = "member" // fallback
 	}
 
-	// 6. Generate Token with default tenant
-	token, err := generateTokenForTenant(user, defaultTenant.ID.String(), role)
+	// 4. Start a session in the default tenant
+	session, err := h.startSession(c, user.ID, defaultTenant.ID, role, nil, twoFactor)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"token":          token,
+		"token":          session.Token,
+		"refresh_token":  session.RefreshToken,
+		"expires_at":     session.ExpiresAt,
 		"user":           user,
 		"tenants":        tenants,
 		"current_tenant": defaultTenant,
@@ -162,47 +200,19 @@ func (h *Handler) GetMe(c echo.Context) error {
 	})
 }
 
-func generateTokenForTenant(user *models.User, tenantID string, role string) (string, error) {
-	claims := &models.JWTCustomClaims{
-		UserID:   user.ID.String(),
-		TenantID: tenantID,
-		Role:     role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 72)), // 3 days
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	secret := config.JWTSecret()
-	if secret == "" {
-		return "", fmt.Errorf("JWT_SECRET environment variable not set")
-	}
-
-	return token.SignedString([]byte(secret))
-}
-
 // generateImpersonationToken mints a short-lived token that acts inside the
 // target tenant with admin role but attributes every action to the operator:
-// UserID and ImpersonatedBy are both the super admin's id.
-func generateImpersonationToken(superAdminID, tenantID string) (string, time.Time, error) {
-	expiresAt := time.Now().Add(30 * time.Minute)
-	claims := &models.JWTCustomClaims{
+// UserID and ImpersonatedBy are both the super admin's id. It has no
+// refresh token; tokenVersion is the operator's, so revoking the operator's
+// sessions ends it too.
+func generateImpersonationToken(superAdminID, tenantID string, tokenVersion int) (string, time.Time, error) {
+	return signAccessToken(&models.JWTCustomClaims{
 		UserID:         superAdminID,
 		TenantID:       tenantID,
 		Role:           "admin",
 		ImpersonatedBy: superAdminID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expiresAt),
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	secret := config.JWTSecret()
-	if secret == "" {
-		return "", time.Time{}, fmt.Errorf("JWT_SECRET environment variable not set")
-	}
-	signed, err := token.SignedString([]byte(secret))
-	return signed, expiresAt, err
+		TokenVersion:   tokenVersion,
+	}, 30*time.Minute)
 }
 
 type SwitchTenantRequest struct {
@@ -216,7 +226,7 @@ func (h *Handler) SwitchTenant(c echo.Context) error {
 		return writeErr(c, err)
 	}
 	// Impersonation sessions are sealed: no switching out of the target
-	// tenant (and no laundering an imp token into a clean 72h token).
+	// tenant (and no laundering an imp token into a refreshable session).
 	if userClaims.ImpersonatedBy != "" {
 		return c.JSON(http.StatusForbidden, map[string]string{"error": "tenant switching is not available during impersonation"})
 	}
@@ -253,14 +263,21 @@ func (h *Handler) SwitchTenant(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get tenant"})
 	}
 
-	// 4. Generate new token with new tenant
-	token, err := generateTokenForTenant(user, req.TenantID, role)
+	// 4. Start a session in the new tenant; the old one has no further use.
+	session, err := h.startSession(c, user.ID, tenantID, role, nil, userClaims.TwoFactor)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
 	}
+	if oldSession, err := uuid.Parse(userClaims.SessionID); err == nil {
+		if err := h.Store.RevokeRefreshTokenFamily(c.Request().Context(), userID, oldSession); err != nil {
+			log.Printf("Failed to revoke session %s after tenant switch: %v", oldSession, err)
+		}
+	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"token":          token,
+		"token":          session.Token,
+		"refresh_token":  session.RefreshToken,
+		"expires_at":     session.ExpiresAt,
 		"current_tenant": tenant,
 	})
 }
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"net/http"
+	"slices"
 
 	"idento/backend/internal/models"
 
@@ -98,3 +99,132 @@ func (h *Handler) requireZoneOwnership(c echo.Context, zoneID uuid.UUID) (*model
 	}
 	return zone, event, nil
 }
+
+// Permission names one capability that roles grant. Built-in roles grant a
+// fixed set (builtinPermissions); tenant-defined roles (roles.go) grant the
+// permissions they list, optionally on some events only.
+type Permission string
+
+const (
+	PermAttendeesEdit  Permission = "attendees:edit"  // create, import, edit and delete attendees
+	PermAttendeesBlock Permission = "attendees:block" // block and unblock attendees
+	PermCheckinUndo    Permission = "checkin:undo"    // undo a check-in
+	PermExport         Permission = "export"          // export attendee lists
+	PermBadgeEdit      Permission = "badge:edit"      // edit badge templates and fonts
+	PermZonesEdit      Permission = "zones:edit"      // create, edit and delete zones, access rules and attendee zone access
+	PermZonesManage    Permission = "zones:manage"    // assign staff to events and zones; scan any zone
+	PermAPIKeysManage  Permission = "api_keys:manage" // create, list and revoke event API keys
+	PermTicketsSend    Permission = "tickets:send"    // configure ticket emails and the ticket layout; send tickets
+	PermStationsManage Permission = "stations:manage" // provision, revoke and message check-in stations
+)
+
+// allPermissions lists every permission in display order.
+var allPermissions = []Permission{
+	PermAttendeesEdit, PermAttendeesBlock, PermCheckinUndo, PermExport,
+	PermBadgeEdit, PermZonesEdit, PermZonesManage, PermAPIKeysManage,
+	PermTicketsSend, PermStationsManage,
+}
+
+// builtinPermissions is what each built-in role grants on every event of
+// the tenant. Staff keep what they could do before permissions existed;
+// staff assignments, any-zone scanning, ticket emails and stations need a
+// manager, an admin, or a custom role.
+var builtinPermissions = map[string][]Permission{
+	"admin":   allPermissions,
+	"manager": allPermissions,
+	"staff": {
+		PermAttendeesEdit, PermAttendeesBlock, PermCheckinUndo, PermExport,
+		PermBadgeEdit, PermZonesEdit, PermAPIKeysManage,
+	},
+}
+
+func validPermission(p string) bool {
+	for _, known := range allPermissions {
+		if string(known) == p {
+			return true
+		}
+	}
+	return false
+}
+
+// customRoleContextKey caches the caller's custom role for the request, so
+// a handler checking several permissions reads it once.
+const customRoleContextKey = "authz.custom_role"
+
+// callerCustomRole returns the caller's custom role in their current
+// tenant, or nil. Admins never have one that matters.
+func (h *Handler) callerCustomRole(c echo.Context, claims *models.JWTCustomClaims) (*models.TenantRole, error) {
+	if cached, ok := c.Get(customRoleContextKey).(*models.TenantRole); ok {
+		return cached, nil
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, newHTTPError(http.StatusUnauthorized, "Invalid token")
+	}
+	tenantID, err := uuid.Parse(claims.TenantID)
+	if err != nil {
+		return nil, newHTTPError(http.StatusUnauthorized, "Invalid token")
+	}
+	role, err := h.Store.GetMemberCustomRole(c.Request().Context(), userID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	// A typed nil is cached too: "no custom role" is the common answer.
+	c.Set(customRoleContextKey, role)
+	return role, nil
+}
+
+// hasPermission reports whether the caller holds perm on eventID. Admins
+// hold every permission. A member with a custom role holds exactly its
+// permissions, and only on its events when it is scoped; everyone else
+// holds their built-in role's permissions.
+func (h *Handler) hasPermission(c echo.Context, perm Permission, eventID uuid.UUID) (bool, error) {
+	claims, err := claimsFromContext(c)
+	if err != nil {
+		return false, err
+	}
+	if claims.Role == "admin" {
+		return true, nil
+	}
+	custom, err := h.callerCustomRole(c, claims)
+	if err != nil {
+		return false, err
+	}
+	granted := builtinPermissions[claims.Role]
+	if custom != nil {
+		if len(custom.EventIDs) > 0 && !slices.Contains(custom.EventIDs, eventID) {
+			return false, nil
+		}
+		granted = make([]Permission, len(custom.Permissions))
+		for i, p := range custom.Permissions {
+			granted[i] = Permission(p)
+		}
+	}
+	return slices.Contains(granted, perm), nil
+}
+
+// requirePermission is hasPermission as a guard: a caller without perm on
+// eventID gets a 403 naming the permission.
+func (h *Handler) requirePermission(c echo.Context, perm Permission, eventID uuid.UUID) error {
+	ok, err := h.hasPermission(c, perm, eventID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return newHTTPError(http.StatusForbidden, "Permission denied: "+string(perm))
+	}
+	return nil
+}
+
+// requireTenantAdmin returns the caller's current tenant when the caller
+// is its admin, else a 403.
+func requireTenantAdmin(c echo.Context) (uuid.UUID, error) {
+	claims, err := claimsFromContext(c)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if claims.Role != "admin" {
+		return uuid.Nil, newHTTPError(http.StatusForbidden, "Admin access required")
+	}
+	return tenantIDFromContext(c)
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
 
 	"idento/backend/internal/models"
@@ -177,3 +178,90 @@ func TestWriteErr_UnknownError(t *testing.T) {
 		t.Fatalf("unexpected body: %s", body)
 	}
 }
+
+func TestHasPermission(t *testing.T) {
+	tenant := uuid.New()
+	scoped, other := uuid.New(), uuid.New()
+	exporter := &models.TenantRole{ID: uuid.New(), Name: "Exporter", Permissions: []string{"export"}}
+	scopedRole := &models.TenantRole{ID: uuid.New(), Name: "Door", Permissions: []string{"checkin:undo"}, EventIDs: []uuid.UUID{scoped}}
+
+	cases := []struct {
+		name    string
+		role    string
+		custom  *models.TenantRole
+		perm    Permission
+		eventID uuid.UUID
+		want    bool
+	}{
+		{"admin holds everything", "admin", nil, PermAPIKeysManage, other, true},
+		{"admin ignores a custom role", "admin", exporter, PermZonesManage, other, true},
+		{"manager builtin", "manager", nil, PermBadgeEdit, other, true},
+		{"staff builtin grants", "staff", nil, PermAttendeesBlock, other, true},
+		{"staff builtin denies", "staff", nil, PermZonesManage, other, false},
+		{"custom role grants", "staff", exporter, PermExport, other, true},
+		{"custom role replaces builtin", "staff", exporter, PermAttendeesEdit, other, false},
+		{"custom role replaces manager builtin", "manager", exporter, PermZonesManage, other, false},
+		{"scoped role on its event", "staff", scopedRole, PermCheckinUndo, scoped, true},
+		{"scoped role elsewhere", "staff", scopedRole, PermCheckinUndo, other, false},
+		{"unknown role", "viewer", nil, PermAttendeesEdit, other, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &Handler{Store: &fakeStore{
+				getMemberCustomRole: func(uuid.UUID, uuid.UUID) (*models.TenantRole, error) { return tc.custom, nil },
+			}}
+			c, _ := newAuthedContext(echo.New(), http.MethodGet, "/", "", tenant.String(), tc.role)
+			got, err := h.hasPermission(c, tc.perm, tc.eventID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("hasPermission(%s) = %v, want %v", tc.perm, got, tc.want)
+			}
+		})
+	}
+}
+
+// The custom role is looked up once per request, including when the
+// member has none.
+func TestHasPermissionCachesCustomRole(t *testing.T) {
+	lookups := 0
+	h := &Handler{Store: &fakeStore{
+		getMemberCustomRole: func(uuid.UUID, uuid.UUID) (*models.TenantRole, error) {
+			lookups++
+			return nil, nil
+		},
+	}}
+	c, _ := newAuthedContext(echo.New(), http.MethodGet, "/", "", uuid.New().String(), "staff")
+	for _, perm := range []Permission{PermAttendeesEdit, PermExport, PermCheckinUndo} {
+		if _, err := h.hasPermission(c, perm, uuid.New()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("custom role looked up %d times, want 1", lookups)
+	}
+}
+
+func TestRequirePermission_Forbidden(t *testing.T) {
+	h := &Handler{Store: &fakeStore{}}
+	c, _ := newAuthedContext(echo.New(), http.MethodGet, "/", "", uuid.New().String(), "staff")
+	err := h.requirePermission(c, PermStationsManage, uuid.New())
+	he, ok := err.(*httpError)
+	if !ok || he.status != http.StatusForbidden || he.msg != "Permission denied: stations:manage" {
+		t.Fatalf("expected 403 'Permission denied: stations:manage', got %#v", err)
+	}
+}
+
+// Staff keep what they could do before permissions existed. Narrowing this
+// set takes away abilities existing staff rely on, so it is a deliberate
+// change of its own, not a side effect.
+func TestBuiltinStaffPermissions(t *testing.T) {
+	want := []Permission{
+		PermAttendeesEdit, PermAttendeesBlock, PermCheckinUndo, PermExport,
+		PermBadgeEdit, PermZonesEdit, PermAPIKeysManage,
+	}
+	if !slices.Equal(builtinPermissions["staff"], want) {
+		t.Errorf("staff permissions = %v, want %v", builtinPermissions["staff"], want)
+	}
+}
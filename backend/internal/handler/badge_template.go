@@ -81,6 +81,9 @@ func (h *Handler) PutBadgeTemplate(c echo.Context) error {
 	if _, err := h.requireEventOwnership(c, eventID); err != nil {
 		return writeErr(c, err)
 	}
+	if err := h.requirePermission(c, PermBadgeEdit, eventID); err != nil {
+		return writeErr(c, err)
+	}
 
 	var req BadgeTemplatePutRequest
 	if err := c.Bind(&req); err != nil {
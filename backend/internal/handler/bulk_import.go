@@ -1,7 +1,16 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"idento/backend/internal/broker"
+	"idento/backend/internal/jobs"
+	"idento/backend/internal/metrics"
+	"idento/backend/internal/middleware"
 	"idento/backend/internal/models"
+	"log"
 	"net/http"
 	"strings"
 
@@ -38,9 +47,48 @@ type BulkImportResponse struct {
 	// there are no per-row errors) — frontend consumers can rely on it
 	// without a `?? []` fallback.
 	Errors []BulkRowError `json:"errors"`
+	// DryRun marks a ?dry_run=true preview: nothing was written, Created
+	// counts the rows that would be created, and SchemaChanges is the
+	// field_schema edit the import would make.
+	DryRun        bool                `json:"dry_run,omitempty"`
+	SchemaChanges *FieldSchemaChanges `json:"schema_changes,omitempty"`
+	// TicketsQueued counts the ticket emails queued for the created
+	// attendees when the event sends tickets on import (see
+	// ticket_email.go).
+	TicketsQueued int `json:"tickets_queued,omitempty"`
 }
 
-// BulkCreateAttendees creates multiple attendees at once (CSV import)
+// FieldSchemaChanges is the difference an import would make to the event's
+// field_schema. Both lists are always present (possibly empty).
+type FieldSchemaChanges struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// diffFieldSchema lists the fields next adds to and drops from current.
+func diffFieldSchema(current, next []string) *FieldSchemaChanges {
+	changes := &FieldSchemaChanges{Added: []string{}, Removed: []string{}}
+	inCurrent := make(map[string]bool, len(current))
+	for _, f := range current {
+		inCurrent[f] = true
+	}
+	inNext := make(map[string]bool, len(next))
+	for _, f := range next {
+		inNext[f] = true
+		if !inCurrent[f] {
+			changes.Added = append(changes.Added, f)
+		}
+	}
+	for _, f := range current {
+		if !inNext[f] {
+			changes.Removed = append(changes.Removed, f)
+		}
+	}
+	return changes
+}
+
+// BulkCreateAttendees creates multiple attendees at once (CSV import).
+// With ?dry_run=true it only previews the import (see bulkCreateAttendees).
 func (h *Handler) BulkCreateAttendees(c echo.Context) error {
 	eventIDStr := c.Param("event_id")
 	eventID, err := uuid.Parse(eventIDStr)
@@ -62,35 +110,119 @@ func (h *Handler) BulkCreateAttendees(c echo.Context) error {
 	if err != nil {
 		return writeErr(c, err)
 	}
+	if err := h.requirePermission(c, PermAttendeesEdit, eventID); err != nil {
+		return writeErr(c, err)
+	}
 
+	return h.bulkCreateAttendees(c, event, req.Attendees, req.FieldSchema)
+}
+
+// bulkCreateAttendees answers an import request whose rows are ready: with
+// ?async=true it checks the attendee limit and queues an attendee_import
+// job (202); otherwise it runs importAttendeeRows in the request and
+// renders its response.
+//
+// On a dry run (middleware.IsDryRun) everything up to the writes runs as
+// usual — the limit check (a batch over the limit still gets the 403),
+// duplicate detection including duplicates within the batch — but the
+// field_schema update, the inserts and the monitor publish are skipped, and
+// the response is a 200 with DryRun and SchemaChanges set.
+func (h *Handler) bulkCreateAttendees(c echo.Context, event *models.Event, rows []map[string]interface{}, fieldSchema []string) error {
+	dryRun := middleware.IsDryRun(c)
+	if isAsync(c) {
+		if err := h.checkImportLimit(c.Request().Context(), event, len(rows)); err != nil {
+			return importLimitErr(c, err)
+		}
+		return h.enqueueJob(c, event, models.JobTypeAttendeeImport, attendeeImportJob{
+			Rows:        rows,
+			FieldSchema: fieldSchema,
+			DryRun:      dryRun,
+		}, len(rows))
+	}
+
+	response, err := h.importAttendeeRows(c.Request().Context(), event, rows, fieldSchema, dryRun, nil)
+	if err != nil {
+		return importLimitErr(c, err)
+	}
+	if dryRun {
+		return c.JSON(http.StatusOK, response)
+	}
+	return c.JSON(http.StatusCreated, response)
+}
+
+// attendeeLimitError is importAttendeeRows' refusal of a batch that would
+// take the event past its plan's attendees_per_event.
+type attendeeLimitError struct {
+	current, max, adding int
+}
+
+func (e *attendeeLimitError) Error() string {
+	return fmt.Sprintf("Limit exceeded for attendees_per_event: %d of %d used, import adds %d", e.current, e.max, e.adding)
+}
+
+// checkImportLimit validates a batch of adding attendees against
+// attendees_per_event: an *attendeeLimitError when it does not fit.
+func (h *Handler) checkImportLimit(ctx context.Context, event *models.Event, adding int) error {
 	// P1.3: validate the whole batch against attendees_per_event before inserting.
-	allowed, current, max, err := h.Store.CheckAttendeeLimit(c.Request().Context(), event.TenantID, eventID, len(req.Attendees))
+	allowed, current, max, err := h.Store.CheckAttendeeLimit(ctx, event.TenantID, event.ID, adding)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check attendee limit")
+		return err
 	}
 	if !allowed {
-		return c.JSON(http.StatusForbidden, map[string]interface{}{
-			"error":            "Limit exceeded for attendees_per_event",
-			"current":          current,
-			"max":              max,
-			"adding":           len(req.Attendees),
-			"upgrade_required": true,
-			"limit_type":       "attendees_per_event",
-		})
+		return &attendeeLimitError{current: current, max: max, adding: adding}
+	}
+	return nil
+}
+
+// importLimitErr renders checkImportLimit's error: the limits middleware's
+// 403 body for an *attendeeLimitError, a 500 otherwise.
+func importLimitErr(c echo.Context, err error) error {
+	var limitErr *attendeeLimitError
+	if !errors.As(err, &limitErr) {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check attendee limit")
+	}
+	return c.JSON(http.StatusForbidden, map[string]interface{}{
+		"error":            "Limit exceeded for attendees_per_event",
+		"current":          limitErr.current,
+		"max":              limitErr.max,
+		"adding":           limitErr.adding,
+		"upgrade_required": true,
+		"limit_type":       "attendees_per_event",
+	})
+}
+
+// importAttendeeRows is the import core shared by BulkCreateAttendees
+// (rows parsed by the client), ImportAttendeesFile (rows parsed here from
+// an uploaded spreadsheet) and the attendee_import job: the attendee-limit
+// check, the field_schema update, duplicate detection against the event
+// and within the batch, the inserts, and the BulkImportResponse. rows must
+// be non-empty and event already ownership-checked. progress, when set, is
+// told about every processed row. The only error is checkImportLimit's.
+func (h *Handler) importAttendeeRows(ctx context.Context, event *models.Event, rows []map[string]interface{}, fieldSchema []string, dryRun bool, progress jobs.Progress) (*BulkImportResponse, error) {
+	eventID := event.ID
+
+	if err := h.checkImportLimit(ctx, event, len(rows)); err != nil {
+		return nil, err
 	}
 
 	// Update event field schema if provided
-	if len(req.FieldSchema) > 0 {
-		event.FieldSchema = req.FieldSchema
-		if err := h.Store.UpdateEvent(c.Request().Context(), event); err != nil {
-			c.Logger().Errorf("Failed to update event field schema: %v", err)
+	var schemaChanges *FieldSchemaChanges
+	if dryRun {
+		schemaChanges = &FieldSchemaChanges{Added: []string{}, Removed: []string{}}
+		if len(fieldSchema) > 0 {
+			schemaChanges = diffFieldSchema(event.FieldSchema, fieldSchema)
+		}
+	} else if len(fieldSchema) > 0 {
+		event.FieldSchema = fieldSchema
+		if err := h.Store.UpdateEvent(ctx, event); err != nil {
+			log.Printf("Failed to update event field schema: %v", err)
 		}
 	}
 
 	// Get existing attendees to check for duplicates
-	existingAttendees, err := h.Store.GetAttendeesByEventID(c.Request().Context(), eventID, "", "")
+	existingAttendees, err := h.Store.GetAttendeesByEventID(ctx, eventID, "", "")
 	if err != nil {
-		c.Logger().Errorf("Failed to get existing attendees: %v", err)
+		log.Printf("Failed to get existing attendees: %v", err)
 		// Continue anyway, we'll rely on DB constraints
 		existingAttendees = []*models.Attendee{}
 	}
@@ -119,11 +251,15 @@ func (h *Handler) BulkCreateAttendees(c echo.Context) error {
 
 	// Create attendees with duplicate checking
 	createdCount := 0
+	var createdIDs []uuid.UUID
 	skippedCount := 0
 	duplicates := []DuplicateInfo{}
-	errors := []BulkRowError{}
+	rowErrors := []BulkRowError{}
 
-	for i, rowData := range req.Attendees {
+	for i, rowData := range rows {
+		if progress != nil {
+			progress(i, len(rows))
+		}
 		attendee := &models.Attendee{
 			ID:           uuid.New(),
 			EventID:      eventID,
@@ -211,27 +347,32 @@ func (h *Handler) BulkCreateAttendees(c echo.Context) error {
 			if duplicateReason == "code" {
 				problem = "duplicate_code"
 			}
-			errors = append(errors, BulkRowError{
+			rowErrors = append(rowErrors, BulkRowError{
 				Row:     i + 1,
 				Data:    errorData,
 				Problem: problem,
 			})
 			skippedCount++
-			c.Logger().Infof("Skipping duplicate attendee: %s %s (%s) - reason: %s",
+			log.Printf("Skipping duplicate attendee: %s %s (%s) - reason: %s",
 				attendee.FirstName, attendee.LastName, attendee.Email, duplicateReason)
 			continue
 		}
 
-		// Create attendee
-		if err := h.Store.CreateAttendee(c.Request().Context(), attendee); err != nil {
-			c.Logger().Errorf("Failed to create attendee: %v", err)
-			errors = append(errors, BulkRowError{
-				Row:     i + 1,
-				Data:    errorData,
-				Problem: "create_failed",
-			})
-			skippedCount++
-			continue
+		// Create attendee. A dry run inserts nothing but still falls
+		// through to the tracking maps, so a later row duplicating this one
+		// is reported as it would be.
+		if !dryRun {
+			if err := h.Store.CreateAttendee(ctx, attendee); err != nil {
+				log.Printf("Failed to create attendee: %v", err)
+				rowErrors = append(rowErrors, BulkRowError{
+					Row:     i + 1,
+					Data:    errorData,
+					Problem: "create_failed",
+				})
+				skippedCount++
+				continue
+			}
+			createdIDs = append(createdIDs, attendee.ID)
 		}
 
 		// Add to tracking maps
@@ -249,27 +390,73 @@ func (h *Handler) BulkCreateAttendees(c echo.Context) error {
 	// whole batch (never once per row), and only when at least one row
 	// actually got created (an all-duplicate/all-error batch changed
 	// nothing monitor-visible).
-	if createdCount > 0 {
-		h.publishCheckinEvent(c.Request().Context(), eventID)
+	if createdCount > 0 && !dryRun {
+		h.publishCheckinEvent(ctx, eventID)
+		// Stations refetch what they need rather than one message per row.
+		h.pushStationMessage(ctx, eventID, broker.KindResync, nil)
 		// P5.3.5: keep planner statistics fresh after a bulk write so the
 		// very next attendee-list query (e.g. an organizer immediately
 		// filtering by zone) doesn't hit the stale-statistics ~100x-slower
 		// join-plan bug found during P5.3.5 planning. Logged, not fatal --
 		// this is a performance optimization, never worth failing an
 		// otherwise-successful import over.
-		if err := h.Store.AnalyzeAttendeesTable(c.Request().Context()); err != nil {
-			c.Logger().Warnf("bulk import: ANALYZE attendees failed (non-fatal): %v", err)
+		if err := h.Store.AnalyzeAttendeesTable(ctx); err != nil {
+			log.Printf("bulk import: ANALYZE attendees failed (non-fatal): %v", err)
 		}
 	}
 
-	response := BulkImportResponse{
+	if progress != nil {
+		progress(len(rows), len(rows))
+	}
+
+	response := &BulkImportResponse{
 		Message:    "Bulk import completed",
 		Created:    createdCount,
 		Skipped:    skippedCount,
-		Total:      len(req.Attendees),
+		Total:      len(rows),
 		Duplicates: duplicates,
-		Errors:     errors,
+		Errors:     rowErrors,
+	}
+	if !dryRun {
+		response.TicketsQueued = h.queueImportTickets(ctx, event, createdIDs)
+		metrics.Imports.WithLabelValues("bulk").Inc()
+		metrics.ImportRows.WithLabelValues("bulk", "created").Add(float64(createdCount))
+		metrics.ImportRows.WithLabelValues("bulk", "skipped").Add(float64(skippedCount))
+	}
+	if dryRun {
+		response.Message = "Dry run completed, nothing was written"
+		response.DryRun = true
+		response.SchemaChanges = schemaChanges
 	}
+	return response, nil
+}
 
-	return c.JSON(http.StatusCreated, response)
+// attendeeImportJob is the attendee_import job payload: the rows and
+// field_schema exactly as bulkCreateAttendees received them.
+type attendeeImportJob struct {
+	Rows        []map[string]interface{} `json:"rows"`
+	FieldSchema []string                 `json:"field_schema"`
+	DryRun      bool                     `json:"dry_run,omitempty"`
+}
+
+// runAttendeeImportJob runs an ?async=true import; its result is the
+// BulkImportResponse the synchronous request would have returned.
+func (h *Handler) runAttendeeImportJob(ctx context.Context, job *models.Job, progress jobs.Progress) (*jobs.Output, error) {
+	var p attendeeImportJob
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return nil, fmt.Errorf("invalid job payload: %w", err)
+	}
+	event, err := h.jobEvent(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	response, err := h.importAttendeeRows(ctx, event, p.Rows, p.FieldSchema, p.DryRun, progress)
+	if err != nil {
+		var limitErr *attendeeLimitError
+		if errors.As(err, &limitErr) {
+			return nil, err
+		}
+		return nil, errors.New("failed to check attendee limit")
+	}
+	return &jobs.Output{Result: response}, nil
 }
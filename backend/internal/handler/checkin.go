@@ -6,6 +6,8 @@ import (
 	"strconv"
 	"time"
 
+	"idento/backend/internal/broker"
+	"idento/backend/internal/metrics"
 	"idento/backend/internal/models"
 	"idento/backend/internal/store"
 
@@ -69,22 +71,23 @@ type CheckinActionsResponse struct {
 }
 
 // resolveCheckinStation validates a caller-supplied station_id (when
-// present) against eventID and returns its display name — used both to
-// populate checked_in_point_name (stationCheckin) and to reject a foreign
-// station_id (400), shared by stationCheckin and undoCheckin. A nil
-// stationID is valid (station-less check-in) and returns ("", nil).
-func (h *Handler) resolveCheckinStation(c echo.Context, eventID uuid.UUID, stationID *uuid.UUID) (string, error) {
+// present) against eventID and returns it — used to populate
+// checked_in_point_name (stationCheckin), to attribute the monitor message
+// to the station's zone, and to reject a foreign station_id (400), shared
+// by stationCheckin and undoCheckin. A nil stationID is valid
+// (station-less check-in) and returns (nil, nil).
+func (h *Handler) resolveCheckinStation(c echo.Context, eventID uuid.UUID, stationID *uuid.UUID) (*models.CheckinStation, error) {
 	if stationID == nil {
-		return "", nil
+		return nil, nil
 	}
 	station, err := h.Store.GetCheckinStationByID(c.Request().Context(), *stationID)
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
-		return "", newHTTPError(http.StatusInternalServerError, "Failed to verify station")
+		return nil, newHTTPError(http.StatusInternalServerError, "Failed to verify station")
 	}
 	if station == nil || station.EventID != eventID {
-		return "", newHTTPError(http.StatusBadRequest, "Station not found in event")
+		return nil, newHTTPError(http.StatusBadRequest, "Station not found in event")
 	}
-	return station.Name, nil
+	return station, nil
 }
 
 // StationCheckin performs one station's idempotent single-scan check-in
@@ -128,13 +131,19 @@ func (h *Handler) StationCheckin(c echo.Context) error {
 	// Blocked short-circuits BEFORE any station validation or store call —
 	// a blocked attendee is never checked in, regardless of station_id.
 	if attendee.Blocked {
+		metrics.Checkins.WithLabelValues("station", "blocked").Inc()
 		return c.JSON(http.StatusOK, StationCheckinResponse{Outcome: "blocked", Attendee: attendee, Checkin: nil})
 	}
 
-	stationName, err := h.resolveCheckinStation(c, eventID, req.StationID)
+	station, err := h.resolveCheckinStation(c, eventID, req.StationID)
 	if err != nil {
 		return writeErr(c, err)
 	}
+	stationName := ""
+	var zoneID *uuid.UUID
+	if station != nil {
+		stationName, zoneID = station.Name, station.ZoneID
+	}
 
 	claims, err := claimsFromContext(c)
 	if err != nil {
@@ -151,6 +160,7 @@ func (h *Handler) StationCheckin(c echo.Context) error {
 
 	outcome, updated, err := h.Store.CheckInAttendee(c.Request().Context(), eventID, req.AttendeeID, req.StationID, staffUserID, staffUser.Email, stationName)
 	if err != nil {
+		metrics.Checkins.WithLabelValues("station", "error").Inc()
 		// ErrAttendeeNotFound is reachable only via the soft-delete race:
 		// the ownership pre-check above passed, then a concurrent DELETE
 		// set deleted_at before the guarded UPDATE ran (attendee_printed.go
@@ -178,9 +188,16 @@ func (h *Handler) StationCheckin(c echo.Context) error {
 	// nil-safe, best-effort, detached from this request's own
 	// cancellation, and timeout-bounded — AFTER the store call already
 	// committed, so nothing here can turn a successful check-in into an
-	// error response.
+	// error response. The message names the zone the monitor attributes
+	// this check-in to — the station's, exactly as GetMonitorOverview does
+	// for an attendee whose latest action is this one.
+	metrics.Checkins.WithLabelValues("station", outcome).Inc()
 	if outcome == "checked_in" {
-		h.publishCheckinEvent(c.Request().Context(), eventID)
+		msg := broker.Message{Key: eventID, Kind: broker.KindCheckin, AttendeeID: &req.AttendeeID, StationID: req.StationID, ZoneID: zoneID, At: time.Now().UTC()}
+		if updated.CheckedInAt != nil {
+			msg.At = updated.CheckedInAt.UTC()
+		}
+		h.publishMonitorMessage(c.Request().Context(), msg)
 	}
 
 	var checkin *CheckinInfo
@@ -206,6 +223,9 @@ func (h *Handler) UndoCheckin(c echo.Context) error {
 	if _, err := h.requireEventOwnership(c, eventID); err != nil {
 		return writeErr(c, err)
 	}
+	if err := h.requirePermission(c, PermCheckinUndo, eventID); err != nil {
+		return writeErr(c, err)
+	}
 
 	var req UndoCheckinRequest
 	if err := c.Bind(&req); err != nil {
@@ -243,14 +263,17 @@ func (h *Handler) UndoCheckin(c echo.Context) error {
 		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to undo check-in"})
 	}
+	metrics.CheckinUndos.Inc()
 
 	// Publish on every 200 (P4.2 Task 4) — including the idempotent
 	// already-clear case: a redundant signal just costs subscribers one
 	// harmless snapshot re-fetch that comes back unchanged, which is
 	// strictly cheaper than trying to detect "did this undo actually
 	// change anything" here. publishCheckinEvent (Finding B2) is nil-safe,
-	// best-effort, detached, timeout-bounded — AFTER the store call.
-	h.publishCheckinEvent(c.Request().Context(), eventID)
+	// best-effort, detached, timeout-bounded — AFTER the store call. No
+	// zone: which zone the cleared check-in counted towards isn't known
+	// here, so the delta monitor stream re-reads on an undo.
+	h.publishMonitorMessage(c.Request().Context(), broker.Message{Key: eventID, Kind: broker.KindUndo, AttendeeID: &req.AttendeeID, StationID: req.StationID, At: time.Now().UTC()})
 
 	return c.JSON(http.StatusOK, UndoCheckinResponse{Attendee: updated})
 }
@@ -119,6 +119,60 @@ func TestStationCheckin_PublishesOnlyOnCheckedIn(t *testing.T) {
 	}
 }
 
+// TestStationCheckin_PublishesTypedMessageWithStationZone proves the
+// check-in message carries what the delta monitor stream patches with:
+// the attendee, the station, and the zone the monitor attributes the
+// check-in to — the station's.
+func TestStationCheckin_PublishesTypedMessageWithStationZone(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	attendee := contractAttendee(event.ID)
+	staffID, stationID, zoneID := uuid.New(), uuid.New(), uuid.New()
+	checkedInAt := time.Now().UTC().Truncate(time.Second)
+
+	h := newStationCheckinHandler(event, attendee,
+		func(uuid.UUID) (*models.User, error) {
+			return &models.User{ID: staffID, Email: "staff@example.com"}, nil
+		},
+		func(uuid.UUID) (*models.CheckinStation, error) {
+			return &models.CheckinStation{ID: stationID, EventID: event.ID, Name: "Door", ZoneID: &zoneID}, nil
+		},
+		func(_, attendeeID uuid.UUID, _ *uuid.UUID, _ uuid.UUID, _, _ string) (string, *models.Attendee, error) {
+			return "checked_in", &models.Attendee{ID: attendeeID, CheckinStatus: true, CheckedInAt: &checkedInAt}, nil
+		},
+		nil,
+	)
+	mem := broker.NewMemBroker()
+	h.Broker = mem
+	sub, unsubscribe := mem.SubscribeMessages(event.ID)
+	defer unsubscribe()
+
+	e := echo.New()
+	path := checkinPath(event.ID)
+	body := `{"attendee_id":"` + attendee.ID.String() + `","station_id":"` + stationID.String() + `"}`
+	c, rec := newAuthedContextWithUserID(e, http.MethodPost, path, body, tenantID.String(), staffID, "staff")
+	setCheckinPathParams(c, event.ID)
+
+	if err := h.StationCheckin(c); err != nil {
+		t.Fatalf("StationCheckin: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+
+	msgs, _ := sub.Drain()
+	if len(msgs) != 1 {
+		t.Fatalf("published %d messages, want 1", len(msgs))
+	}
+	msg := msgs[0]
+	if msg.Kind != broker.KindCheckin || !msg.At.Equal(checkedInAt) ||
+		msg.AttendeeID == nil || *msg.AttendeeID != attendee.ID ||
+		msg.StationID == nil || *msg.StationID != stationID ||
+		msg.ZoneID == nil || *msg.ZoneID != zoneID {
+		t.Fatalf("message = %+v, want a checkin for the attendee at the station, in its zone", msg)
+	}
+}
+
 // TestStationCheckin_NilBrokerDoesNotPanic proves the nil-safe guard: a
 // Handler with no Broker set (the ~70 existing `&Handler{Store: fs}` test
 // literals across this package) still completes a checked_in scan without
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"idento/backend/internal/broker"
 	"idento/backend/internal/store"
 
 	"github.com/google/uuid"
@@ -151,6 +152,7 @@ func (h *Handler) PutCheckinSettings(c echo.Context) error {
 		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save check-in settings"})
 	}
+	h.pushStationMessage(c.Request().Context(), eventID, broker.KindSettings, nil)
 
 	return c.JSON(http.StatusOK, CheckinSettingsResponse(req))
 }
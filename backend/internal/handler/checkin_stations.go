@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"idento/backend/internal/broker"
 	"idento/backend/internal/models"
 	"idento/backend/internal/store"
 
@@ -142,7 +143,7 @@ func (h *Handler) HeartbeatCheckinStation(c echo.Context) error {
 	// (Finding B5) additionally throttles heartbeat-SOURCED publishes per
 	// event — unlike check-in/undo/reprint, which stay unthrottled.
 	if h.shouldPublishHeartbeat(eventID) {
-		h.publishCheckinEvent(c.Request().Context(), eventID)
+		h.publishMonitorMessage(c.Request().Context(), broker.Message{Key: eventID, Kind: broker.KindHeartbeat, StationID: &stationID, At: time.Now().UTC()})
 	}
 
 	return c.NoContent(http.StatusNoContent)
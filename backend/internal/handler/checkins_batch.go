@@ -3,6 +3,7 @@ package handler
 import (
 	"net/http"
 
+	"idento/backend/internal/metrics"
 	"idento/backend/internal/models"
 	"idento/backend/internal/store"
 
@@ -78,6 +79,9 @@ func (h *Handler) BatchCheckin(c echo.Context) error {
 		}
 
 		outcome, err := h.Store.ApplyBatchCheckin(c.Request().Context(), eventID, staffUserID, &item)
+		if item.Kind == "checkin" {
+			metrics.Checkins.WithLabelValues("batch", batchCheckinMetricOutcome(outcome, err)).Inc()
+		}
 		if err != nil {
 			results = append(results, models.BatchCheckinResult{ClientUUID: item.ClientUUID, Status: "error", Error: err.Error()})
 			continue
@@ -110,3 +114,18 @@ func (h *Handler) BatchCheckin(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, results)
 }
+
+// batchCheckinMetricOutcome maps an ApplyBatchCheckin result onto the
+// outcome label StationCheckin uses, so both sources sum cleanly.
+func batchCheckinMetricOutcome(outcome store.BatchCheckinOutcome, err error) string {
+	switch {
+	case err != nil:
+		return "error"
+	case outcome == store.BatchCheckinCreated:
+		return "checked_in"
+	case outcome == store.BatchCheckinAlreadyCheckedIn, outcome == store.BatchCheckinDuplicateClientUUID:
+		return "already_checked_in"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// sseReconnectDelay is the retry hint sent to stream clients on shutdown:
+// long enough for the load balancer to route the reconnect to another
+// replica, short enough that a monitor screen barely notices.
+const sseReconnectDelay = 2 * time.Second
+
+// streamDrain is closed once, on shutdown, to end every open SSE stream.
+// Its zero value is ready to use, so `&Handler{Store: fs}` test literals
+// stay valid.
+type streamDrain struct {
+	init sync.Once
+	stop sync.Once
+	ch   chan struct{}
+}
+
+func (d *streamDrain) done() <-chan struct{} {
+	d.init.Do(func() { d.ch = make(chan struct{}) })
+	return d.ch
+}
+
+func (d *streamDrain) close() {
+	d.done()
+	d.stop.Do(func() { close(d.ch) })
+}
+
+func (d *streamDrain) closed() bool {
+	select {
+	case <-d.done():
+		return true
+	default:
+		return false
+	}
+}
+
+// DrainStreams ends every open SSE stream with a reconnect frame and makes
+// new ones answer 503. http.Server.Shutdown waits for connections to go
+// idle and a stream never does, so main calls this before Shutdown.
+// Idempotent.
+func (h *Handler) DrainStreams() {
+	h.drain.close()
+}
+
+// refuseStreamWhileDraining answers 503 with a Retry-After once
+// DrainStreams was called. Like the nil-Broker check, it runs before any
+// stream header is written.
+func (h *Handler) refuseStreamWhileDraining(c echo.Context) error {
+	if !h.drain.closed() {
+		return nil
+	}
+	c.Response().Header().Set("Retry-After", fmt.Sprint(int(sseReconnectDelay.Seconds())))
+	return newHTTPError(http.StatusServiceUnavailable, "Server is shutting down")
+}
+
+// writeReconnectFrame tells an SSE client the stream is ending because the
+// server is going away: "retry" sets the EventSource reconnect delay, and
+// the "reconnect" event lets custom clients reconnect deliberately.
+func writeReconnectFrame(res *echo.Response) bool {
+	return writeSSEFrame(res, fmt.Sprintf("retry: %d\nevent: reconnect\ndata: {}\n\n", sseReconnectDelay.Milliseconds()))
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// CloneEventRequest is the JSON body for POST /api/events/:id/clone. Every
+// copy flag defaults to true except attendees; zone_access_rules defaults
+// to whatever zones is, since rules cannot be copied without their zones.
+// start_date moves the copy in time: end_date keeps its distance from the
+// start. Without it the copy keeps the source's dates.
+type CloneEventRequest struct {
+	Name      *string    `json:"name"`
+	StartDate *time.Time `json:"start_date"`
+
+	Zones           *bool `json:"zones"`
+	ZoneAccessRules *bool `json:"zone_access_rules"`
+	BadgeTemplate   *bool `json:"badge_template"`
+	CheckinSettings *bool `json:"checkin_settings"`
+	Fonts           *bool `json:"fonts"`
+	Staff           *bool `json:"staff"`
+	Attendees       bool  `json:"attendees"`
+}
+
+// CloneEventResponse is the 201 body of POST /api/events/:id/clone.
+type CloneEventResponse struct {
+	Event  *models.Event            `json:"event"`
+	Copied *models.EventCloneCounts `json:"copied"`
+}
+
+func boolOr(v *bool, def bool) bool {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+// cloneDates returns the copy's start and end: the source's, or moved so the
+// copy starts at start.
+func cloneDates(src *models.Event, start *time.Time) (*time.Time, *time.Time) {
+	if start == nil {
+		return src.StartDate, src.EndDate
+	}
+	if src.StartDate == nil || src.EndDate == nil {
+		return start, src.EndDate
+	}
+	end := src.EndDate.Add(start.Sub(*src.StartDate))
+	return start, &end
+}
+
+// CloneEvent copies an event for a recurring format: the event's own fields
+// plus, per CloneEventRequest, its zones and their access rules, badge
+// template, check-in settings, fonts, staff and optionally its attendees
+// (never their check-in state). API keys are not copied. The copy is one
+// transaction and counts against events_per_month like any new event.
+func (h *Handler) CloneEvent(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid event ID"})
+	}
+	src, err := h.requireEventOwnership(c, id)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	claims, err := claimsFromContext(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+
+	req := new(CloneEventRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	name := src.Name + " (copy)"
+	if req.Name != nil {
+		name = strings.TrimSpace(*req.Name)
+		if name == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Name must not be empty"})
+		}
+	}
+	opts := store.EventCloneOptions{
+		Zones:           boolOr(req.Zones, true),
+		BadgeTemplate:   boolOr(req.BadgeTemplate, true),
+		CheckinSettings: boolOr(req.CheckinSettings, true),
+		Fonts:           boolOr(req.Fonts, true),
+		Staff:           boolOr(req.Staff, true),
+		Attendees:       req.Attendees,
+	}
+	opts.ZoneAccessRules = boolOr(req.ZoneAccessRules, opts.Zones)
+	if opts.ZoneAccessRules && !opts.Zones {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "zone_access_rules cannot be copied without zones"})
+	}
+	if userID, err := uuid.Parse(claims.UserID); err == nil {
+		opts.AssignedBy = &userID
+	}
+
+	ctx := c.Request().Context()
+	if opts.Attendees {
+		// The copy starts empty and receives the source's whole list, which
+		// may no longer fit if the plan was downgraded since.
+		allowed, current, max, err := h.Store.CheckAttendeeLimit(ctx, src.TenantID, src.ID, 0)
+		if err != nil {
+			return importLimitErr(c, err)
+		}
+		if !allowed {
+			return importLimitErr(c, &attendeeLimitError{current: 0, max: max, adding: current})
+		}
+	}
+
+	clone := &models.Event{
+		TenantID:     src.TenantID,
+		Name:         name,
+		Location:     src.Location,
+		FieldSchema:  src.FieldSchema,
+		CustomFields: src.CustomFields,
+	}
+	clone.StartDate, clone.EndDate = cloneDates(src, req.StartDate)
+
+	counts, err := h.Store.CloneEvent(ctx, src.ID, clone, opts)
+	if errors.Is(err, store.ErrEventNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Event not found"})
+	}
+	if err != nil {
+		log.Printf("Failed to clone event %s: %v", src.ID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to clone event"})
+	}
+
+	if err := h.Store.LogUsage(ctx, &models.UsageLog{
+		TenantID:     src.TenantID,
+		ResourceType: "event",
+		ResourceID:   &clone.ID,
+		Action:       "created",
+		Quantity:     1,
+	}); err != nil {
+		log.Printf("Failed to log usage: %v", err)
+	}
+	h.logTenantAction(c, &clone.ID, auditCloneEvent, "event", clone.ID, map[string]interface{}{
+		"source_event_id": src.ID,
+		"copied":          counts,
+	})
+
+	return c.JSON(http.StatusCreated, CloneEventResponse{Event: clone, Copied: counts})
+}
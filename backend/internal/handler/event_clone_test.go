@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func newCloneContext(e *echo.Echo, event *models.Event, body string) (echo.Context, *httptest.ResponseRecorder) {
+	c, rec := newAuthedContext(e, http.MethodPost, "/api/events/"+event.ID.String()+"/clone", body, event.TenantID.String(), "admin")
+	c.SetPath("/api/events/:id/clone")
+	c.SetParamNames("id")
+	c.SetParamValues(event.ID.String())
+	return c, rec
+}
+
+func TestContractCloneEvent(t *testing.T) {
+	tenantID := uuid.New()
+	src := contractEvent(tenantID, "Monthly Meetup")
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	end := start.Add(8 * time.Hour)
+	src.StartDate, src.EndDate = &start, &end
+	src.FieldSchema = []string{"category"}
+
+	var gotOpts store.EventCloneOptions
+	var gotClone models.Event
+	h := New(&fakeStore{
+		getEventByID: func(uuid.UUID) (*models.Event, error) { return src, nil },
+		cloneEvent: func(srcID uuid.UUID, clone *models.Event, opts store.EventCloneOptions) (*models.EventCloneCounts, error) {
+			if srcID != src.ID {
+				t.Errorf("srcID = %s, want %s", srcID, src.ID)
+			}
+			clone.ID, clone.CreatedAt, clone.UpdatedAt = uuid.New(), time.Now(), time.Now()
+			gotClone, gotOpts = *clone, opts
+			return &models.EventCloneCounts{Zones: 3, ZoneAccessRules: 5, Fonts: 1, Staff: 2}, nil
+		},
+		logUsage: func(*models.UsageLog) error { return nil },
+	})
+	e := echo.New()
+	c, rec := newCloneContext(e, src, `{"start_date":"2026-11-05T09:00:00Z"}`)
+	if err := h.CloneEvent(c); err != nil {
+		t.Fatalf("CloneEvent: %v", err)
+	}
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("want 201, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodPost, "/api/events/"+src.ID.String()+"/clone", rec)
+
+	want := store.EventCloneOptions{Zones: true, ZoneAccessRules: true, BadgeTemplate: true, CheckinSettings: true, Fonts: true, Staff: true}
+	gotOpts.AssignedBy = nil
+	if gotOpts != want {
+		t.Errorf("opts = %+v, want every copy flag but attendees", gotOpts)
+	}
+	if gotClone.Name != "Monthly Meetup (copy)" || gotClone.Location != src.Location || len(gotClone.FieldSchema) != 1 {
+		t.Errorf("clone = %+v", gotClone)
+	}
+	wantStart := time.Date(2026, 11, 5, 9, 0, 0, 0, time.UTC)
+	if !gotClone.StartDate.Equal(wantStart) || !gotClone.EndDate.Equal(wantStart.Add(8*time.Hour)) {
+		t.Errorf("dates = %v .. %v, want the source's 8h span shifted to %v", gotClone.StartDate, gotClone.EndDate, wantStart)
+	}
+	var resp CloneEventResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Event.ID != gotClone.ID || resp.Copied.ZoneAccessRules != 5 {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestCloneEventRejectsRulesWithoutZones(t *testing.T) {
+	tenantID := uuid.New()
+	src := contractEvent(tenantID, "Monthly Meetup")
+	h := New(&fakeStore{
+		getEventByID: func(uuid.UUID) (*models.Event, error) { return src, nil },
+	})
+	for _, body := range []string{`{"zones":false,"zone_access_rules":true}`, `{"name":"  "}`} {
+		c, rec := newCloneContext(echo.New(), src, body)
+		if err := h.CloneEvent(c); err != nil {
+			t.Fatalf("CloneEvent: %v", err)
+		}
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: want 400, got %d, body=%s", body, rec.Code, rec.Body.String())
+		}
+		validateResponse(t, http.MethodPost, "/api/events/"+src.ID.String()+"/clone", rec)
+	}
+}
+
+// Turning zones off takes the rules with it unless they are asked for.
+func TestCloneEventZonesOffDropsRules(t *testing.T) {
+	tenantID := uuid.New()
+	src := contractEvent(tenantID, "Monthly Meetup")
+	var gotOpts store.EventCloneOptions
+	h := New(&fakeStore{
+		getEventByID: func(uuid.UUID) (*models.Event, error) { return src, nil },
+		checkAttendeeLimit: func(uuid.UUID, uuid.UUID, int) (bool, int, int, error) {
+			return true, 40, 50, nil
+		},
+		cloneEvent: func(_ uuid.UUID, clone *models.Event, opts store.EventCloneOptions) (*models.EventCloneCounts, error) {
+			gotOpts = opts
+			return &models.EventCloneCounts{}, nil
+		},
+		logUsage: func(*models.UsageLog) error { return nil },
+	})
+	c, rec := newCloneContext(echo.New(), src, `{"name":"November Meetup","zones":false,"fonts":false,"attendees":true}`)
+	if err := h.CloneEvent(c); err != nil {
+		t.Fatalf("CloneEvent: %v", err)
+	}
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("want 201, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	if gotOpts.Zones || gotOpts.ZoneAccessRules || gotOpts.Fonts || !gotOpts.Attendees || !gotOpts.BadgeTemplate {
+		t.Errorf("opts = %+v", gotOpts)
+	}
+}
+
+func TestContractCloneEventAttendeeLimit(t *testing.T) {
+	tenantID := uuid.New()
+	src := contractEvent(tenantID, "Monthly Meetup")
+	h := New(&fakeStore{
+		getEventByID: func(uuid.UUID) (*models.Event, error) { return src, nil },
+		checkAttendeeLimit: func(uuid.UUID, uuid.UUID, int) (bool, int, int, error) {
+			return false, 80, 50, nil
+		},
+		cloneEvent: func(uuid.UUID, *models.Event, store.EventCloneOptions) (*models.EventCloneCounts, error) {
+			t.Fatal("cloned past the attendee limit")
+			return nil, nil
+		},
+	})
+	c, rec := newCloneContext(echo.New(), src, `{"attendees":true}`)
+	if err := h.CloneEvent(c); err != nil {
+		t.Fatalf("CloneEvent: %v", err)
+	}
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("want 403, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodPost, "/api/events/"+src.ID.String()+"/clone", rec)
+}
+
+func TestContractCloneEventNotFound(t *testing.T) {
+	tenantID := uuid.New()
+	src := contractEvent(tenantID, "Monthly Meetup")
+	h := New(&fakeStore{
+		getEventByID: func(uuid.UUID) (*models.Event, error) { return nil, nil },
+	})
+	c, rec := newCloneContext(echo.New(), src, `{}`)
+	if err := h.CloneEvent(c); err != nil {
+		t.Fatalf("CloneEvent: %v", err)
+	}
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("want 404, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodPost, "/api/events/"+src.ID.String()+"/clone", rec)
+}
@@ -5,6 +5,8 @@ import (
 	"log"
 	"time"
 
+	"idento/backend/internal/broker"
+
 	"github.com/google/uuid"
 )
 
@@ -45,7 +47,18 @@ var publishCheckinTimeout = 2 * time.Second
 // StationCheckin only calls this on outcome=="checked_in") — this helper
 // only owns the ctx/timeout/nil-safety/logging mechanics, never whether to
 // publish at all.
+//
+// It publishes broker.Changed — "re-read the whole monitor". The four
+// original sites know exactly what changed and say so through
+// publishMonitorMessage instead, which lets the delta monitor stream patch
+// its state without a re-read (monitor_feed.go).
 func (h *Handler) publishCheckinEvent(ctx context.Context, eventID uuid.UUID) {
+	h.publishMonitorMessage(ctx, broker.Changed(eventID))
+}
+
+// publishMonitorMessage is publishCheckinEvent for a typed message; same
+// nil-safety, detachment, timeout, and log-don't-fail contract.
+func (h *Handler) publishMonitorMessage(ctx context.Context, msg broker.Message) {
 	if h == nil || h.Broker == nil {
 		return
 	}
@@ -54,7 +67,7 @@ func (h *Handler) publishCheckinEvent(ctx context.Context, eventID uuid.UUID) {
 	pubCtx, cancel := context.WithTimeout(detached, publishCheckinTimeout)
 	defer cancel()
 
-	if err := h.Broker.Publish(pubCtx, eventID); err != nil {
+	if err := h.Broker.Publish(pubCtx, msg); err != nil {
 		log.Printf("publish checkin event: broker publish failed: %v", err)
 	}
 }
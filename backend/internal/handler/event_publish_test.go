@@ -5,6 +5,8 @@ import (
 	"testing"
 	"time"
 
+	"idento/backend/internal/broker"
+
 	"github.com/google/uuid"
 )
 
@@ -37,10 +39,10 @@ type capturingBroker struct {
 	publishedID      uuid.UUID
 }
 
-func (b *capturingBroker) Publish(ctx context.Context, eventID uuid.UUID) error {
+func (b *capturingBroker) Publish(ctx context.Context, msg broker.Message) error {
 	b.published = true
 	b.ctxErrAtCallTime = ctx.Err()
-	b.publishedID = eventID
+	b.publishedID = msg.Key
 	return nil
 }
 
@@ -48,6 +50,10 @@ func (b *capturingBroker) Subscribe(uuid.UUID) (<-chan struct{}, func()) {
 	return nil, func() {}
 }
 
+func (b *capturingBroker) SubscribeMessages(uuid.UUID) (*broker.Subscription, func()) {
+	return nil, func() {}
+}
+
 // TestPublishCheckinEvent_NilBrokerDoesNotPanic proves the helper is
 // nil-safe exactly like every existing per-site `if h.Broker != nil` guard
 // it replaces.
@@ -93,7 +99,7 @@ type wedgedBroker struct {
 	unblock <-chan struct{}
 }
 
-func (b *wedgedBroker) Publish(ctx context.Context, _ uuid.UUID) error {
+func (b *wedgedBroker) Publish(ctx context.Context, _ broker.Message) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -106,6 +112,10 @@ func (b *wedgedBroker) Subscribe(uuid.UUID) (<-chan struct{}, func()) {
 	return nil, func() {}
 }
 
+func (b *wedgedBroker) SubscribeMessages(uuid.UUID) (*broker.Subscription, func()) {
+	return nil, func() {}
+}
+
 // TestPublishCheckinEvent_BoundsAWedgedBrokerByTimeout proves Finding
 // B2(b): a Broker.Publish that never returns on its own must not hang
 // publishCheckinEvent forever — the detached context is itself
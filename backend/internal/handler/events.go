@@ -102,6 +102,7 @@ func (h *Handler) UpdateEvent(c echo.Context) error {
 	if err != nil {
 		return writeErr(c, err)
 	}
+	before := *event
 
 	// Bind update request
 	req := new(UpdateEventRequest)
@@ -120,6 +121,8 @@ func (h *Handler) UpdateEvent(c echo.Context) error {
 	if err := h.Store.UpdateEvent(c.Request().Context(), event); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update event"})
 	}
+	h.logTenantDiff(c, &event.ID, auditUpdateEvent, "event", event.ID, &before, event, eventAuditIgnore...)
+	h.queueWalletUpdate(c.Request().Context(), &before, event)
 
 	return c.JSON(http.StatusOK, event)
 }
@@ -145,6 +148,7 @@ func (h *Handler) PatchEvent(c echo.Context) error {
 	if err != nil {
 		return writeErr(c, err)
 	}
+	before := *event
 	req := new(PatchEventRequest)
 	if err := c.Bind(req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
@@ -167,6 +171,8 @@ func (h *Handler) PatchEvent(c echo.Context) error {
 	if err := h.Store.UpdateEvent(c.Request().Context(), event); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update event"})
 	}
+	h.logTenantDiff(c, &event.ID, auditUpdateEvent, "event", event.ID, &before, event, eventAuditIgnore...)
+	h.queueWalletUpdate(c.Request().Context(), &before, event)
 	return c.JSON(http.StatusOK, event)
 }
 
@@ -177,11 +183,13 @@ func (h *Handler) DeleteEvent(c echo.Context) error {
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid event ID"})
 	}
-	if _, err := h.requireEventOwnership(c, id); err != nil {
+	event, err := h.requireEventOwnership(c, id)
+	if err != nil {
 		return writeErr(c, err)
 	}
 	if err := h.Store.SoftDeleteEvent(c.Request().Context(), id); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete event"})
 	}
+	h.logTenantDiff(c, &event.ID, auditDeleteEvent, "event", event.ID, event, nil, eventAuditIgnore...)
 	return c.NoContent(http.StatusNoContent)
 }
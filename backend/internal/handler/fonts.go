@@ -53,6 +53,9 @@ func (h *Handler) UploadEventFont(c echo.Context) error {
 	if _, err := h.requireEventOwnership(c, eventID); err != nil {
 		return writeErr(c, err)
 	}
+	if err := h.requirePermission(c, PermBadgeEdit, eventID); err != nil {
+		return writeErr(c, err)
+	}
 
 	claims, err := claimsFromContext(c)
 	if err != nil {
@@ -283,6 +286,9 @@ func (h *Handler) DeleteEventFont(c echo.Context) error {
 	if _, err := h.requireEventOwnership(c, eventID); err != nil {
 		return writeErr(c, err)
 	}
+	if err := h.requirePermission(c, PermBadgeEdit, eventID); err != nil {
+		return writeErr(c, err)
+	}
 
 	// Verify font belongs to event
 	font, err := h.Store.GetFontByID(c.Request().Context(), fontID)
@@ -4,13 +4,17 @@
 package handler
 
 import (
+	"net/http"
 	"sync"
 	"time"
 
 	"idento/backend/internal/broker"
 	"idento/backend/internal/config"
+	"idento/backend/internal/mail"
 	"idento/backend/internal/middleware"
+	"idento/backend/internal/oidc"
 	"idento/backend/internal/store"
+	"idento/backend/internal/wallet"
 
 	"github.com/labstack/echo/v4"
 	echomw "github.com/labstack/echo/v4/middleware"
@@ -30,6 +34,24 @@ import (
 type Handler struct {
 	Store  store.Store
 	Broker broker.Broker
+	// Mailer sends account emails; nil-safe like Broker (see mailer).
+	Mailer mail.Sender
+	// OIDC talks to tenants' identity providers; nil-safe (see oidcClient).
+	OIDC *oidc.Client
+	// AllowLoopbackSSOIssuers lets an organization's SSO issuer be a
+	// loopback host, over http too (a local mock provider). main sets it
+	// outside SaaS mode only, where it would let any tenant admin point
+	// discovery at the server itself.
+	AllowLoopbackSSOIssuers bool
+	// LogoHTTP fetches tenant logos for PDF tickets; nil-safe (see
+	// logoClient).
+	LogoHTTP *http.Client
+	// Wallet pushes pass updates to APNs and Google Wallet; nil-safe (see
+	// walletClient).
+	Wallet *wallet.Client
+	// JobArtifactMaxBytes caps a background job's download file; 0 means
+	// jobs.DefaultMaxArtifactBytes.
+	JobArtifactMaxBytes int64
 
 	// heartbeatLastPublish tracks, per event, the last time a
 	// heartbeat-SOURCED broker publish fired (Finding B5, PR #81
@@ -40,6 +62,17 @@ type Handler struct {
 	// sync.Map's zero value is ready to use, so the ~70 existing
 	// `&Handler{Store: fs}` test literals stay valid untouched.
 	heartbeatLastPublish sync.Map
+
+	// drain ends open SSE streams on shutdown (see DrainStreams).
+	drain streamDrain
+
+	// monitorFeeds backs the delta monitor stream (monitor_feed.go).
+	monitorFeeds monitorFeeds
+
+	// apiKeyThrottles tallies API key requests rejected over budget (see
+	// StartAPIKeyThrottleFlush); created on first use.
+	apiKeyThrottlesOnce sync.Once
+	apiKeyThrottles     *middleware.APIKeyThrottleLog
 }
 
 // New returns a new Handler with the given store.
@@ -66,7 +99,31 @@ func (h *Handler) RegisterRoutes(e *echo.Echo, mode string) {
 		auth.POST("/register", h.Register) // self-serve signup is SaaS-only
 	}
 	auth.POST("/login", h.Login, authLimiter)
+	auth.POST("/login/2fa", h.LoginTwoFactor, authLimiter)
 	auth.POST("/login-qr", h.LoginWithQR, authLimiter)
+	// Not behind authLimiter: every signed-in client refreshes on a timer,
+	// often many station phones behind one venue NAT, and a refresh token
+	// is 256 random bits, so there is nothing to guess.
+	auth.POST("/refresh", h.RefreshSession)
+	auth.POST("/forgot-password", h.ForgotPassword, authLimiter)
+	auth.POST("/reset-password", h.ResetPassword, authLimiter)
+	auth.POST("/accept-invite", h.AcceptInvitation, authLimiter)
+	auth.POST("/verify-email", h.VerifyEmail, authLimiter)
+	// Single sign-on (OIDC authorization code + PKCE). The callback is not
+	// rate-limited: it only redeems states this server issued.
+	auth.GET("/oidc/discover", h.DiscoverSSO, authLimiter)
+	auth.GET("/oidc/:tenant_id/login", h.StartSSO, authLimiter)
+	auth.GET("/oidc/callback", h.SSOCallback)
+
+	// PassKit web service: Apple Wallet devices register for and fetch pass
+	// updates, authenticated per pass by "ApplePass <token>" (passkit.go).
+	// Not rate-limited: one venue's phones refetch together after a push.
+	passkit := e.Group("/passkit/v1")
+	passkit.POST("/devices/:device_id/registrations/:pass_type_id/:serial", h.RegisterPassDevice)
+	passkit.DELETE("/devices/:device_id/registrations/:pass_type_id/:serial", h.UnregisterPassDevice)
+	passkit.GET("/devices/:device_id/registrations/:pass_type_id", h.GetDevicePassSerials)
+	passkit.GET("/passes/:pass_type_id/:serial", h.GetLatestPass)
+	passkit.POST("/log", h.LogPassKitErrors)
 
 	// Station provisioning (public — the device has no JWT yet; rate-limited
 	// like login since it's an unauthenticated, token-guessable surface).
@@ -74,11 +131,19 @@ func (h *Handler) RegisterRoutes(e *echo.Echo, mode string) {
 
 	// Protected routes
 	api := e.Group("/api")
-	api.Use(middleware.JWT())
+	api.Use(middleware.JWT(h.Store))
 	api.Use(middleware.TenantGate(h.Store))
+	api.Use(middleware.TwoFactorPolicy(h.Store))
 	api.Use(middleware.ImpersonationAudit(h.Store))
 	api.GET("/me", h.GetMe)
+	api.POST("/auth/logout", h.Logout)
+	api.POST("/auth/resend-verification", h.ResendVerification)
 	api.POST("/auth/switch-tenant", h.SwitchTenant)
+	api.GET("/auth/2fa", h.GetTwoFactorStatus)
+	api.POST("/auth/2fa/setup", h.SetupTwoFactor, authLimiter)
+	api.POST("/auth/2fa/enable", h.EnableTwoFactor, authLimiter)
+	api.POST("/auth/2fa/disable", h.DisableTwoFactor, authLimiter)
+	api.POST("/auth/2fa/recovery-codes", h.RegenerateRecoveryCodes, authLimiter)
 
 	// Tenants/Organizations
 	api.GET("/tenants", h.GetUserTenants)
@@ -89,15 +154,23 @@ func (h *Handler) RegisterRoutes(e *echo.Echo, mode string) {
 	api.GET("/users", h.GetUsers)
 	api.POST("/users", h.CreateUser, middleware.CheckLimits(h.Store, "users"))
 	api.POST("/users/:id/qr-token", h.GenerateQRToken)
+	api.POST("/users/:id/revoke-sessions", h.RevokeUserSessions)
+	api.POST("/users/invite", h.InviteUser, middleware.CheckLimits(h.Store, "users"))
+	api.PUT("/users/:id/role", h.UpdateUserRole)
+	api.POST("/users/:id/deactivate", h.DeactivateUser)
+	api.POST("/users/:id/reactivate", h.ReactivateUser, middleware.CheckLimits(h.Store, "users"))
+	api.DELETE("/users/:id", h.RemoveUser)
 
 	// Events
 	api.GET("/events", h.GetEvents)
 	api.GET("/events/:id", h.GetEvent)
-	api.POST("/events", h.CreateEvent, middleware.CheckLimits(h.Store, "events_per_month"))
+	api.POST("/events", h.CreateEvent, middleware.Idempotency(h.Store), middleware.CheckLimits(h.Store, "events_per_month"))
 	api.PUT("/events/:id", h.UpdateEvent)
 	api.PATCH("/events/:id", h.PatchEvent)
 	api.DELETE("/events/:id", h.DeleteEvent)
+	api.POST("/events/:id/clone", h.CloneEvent, middleware.Idempotency(h.Store), middleware.CheckLimits(h.Store, "events_per_month"))
 	api.POST("/events/:id/badge-zpl", h.BadgeZPL)
+	api.POST("/events/:id/badge-zpl/jobs", h.CreateBadgePrintJob)
 	api.GET("/events/:id/badge-template", h.GetBadgeTemplate)
 	api.PUT("/events/:id/badge-template", h.PutBadgeTemplate)
 	api.GET("/events/:id/checkin-settings", h.GetCheckinSettings)
@@ -116,14 +189,22 @@ func (h *Handler) RegisterRoutes(e *echo.Echo, mode string) {
 	api.POST("/events/:event_id/staff", h.AssignStaffToEvent)
 	api.DELETE("/events/:event_id/staff/:user_id", h.UnassignStaffFromEvent)
 	api.POST("/events/:event_id/stations/provisioning-token", h.CreateStationProvisioningToken)
+	api.POST("/events/:event_id/stations/:station_id/revoke", h.RevokeStation)
+	api.GET("/events/:event_id/stations/stream", h.GetStationStream)
+	api.POST("/events/:event_id/stations/messages", h.PostStationMessage)
 
 	// Attendees
 	api.GET("/events/:event_id/attendees", h.GetAttendees)
-	api.POST("/events/:event_id/attendees", h.CreateAttendee, middleware.CheckAttendeeLimits(h.Store))
-	api.POST("/events/:event_id/attendees/bulk", h.BulkCreateAttendees)
+	api.POST("/events/:event_id/attendees", h.CreateAttendee, middleware.Idempotency(h.Store), middleware.CheckAttendeeLimits(h.Store))
+	api.POST("/events/:event_id/attendees/bulk", h.BulkCreateAttendees, middleware.Idempotency(h.Store))
+	api.POST("/events/:event_id/attendees/import", h.ImportAttendeesFile, middleware.Idempotency(h.Store))
 	api.POST("/events/:event_id/attendees/generate-codes", h.GenerateAttendeeCodes)
-	api.GET("/events/:event_id/attendees/export", h.ExportAttendeesCSV)
+	api.GET("/events/:event_id/attendees/export", h.ExportAttendees)
 	api.GET("/attendees/:id/qr", h.GetAttendeeQR)
+	api.GET("/attendees/:id/ticket-delivery", h.GetAttendeeTicketDelivery)
+	api.GET("/attendees/:id/ticket.pdf", h.GetAttendeeTicketPDF)
+	api.GET("/attendees/:id/wallet.pkpass", h.GetAttendeeApplePass)
+	api.GET("/attendees/:id/google-wallet", h.GetAttendeeGoogleWallet)
 	api.GET("/attendees/:id", h.GetAttendeeDetail)                     // Single-attendee fetch (deep-linking)
 	api.PUT("/attendees/:id", h.UpdateAttendeeHandler)                 // For check-in status
 	api.PATCH("/attendees/:id", h.UpdateAttendeeInfo)                  // For full info update
@@ -132,6 +213,15 @@ func (h *Handler) RegisterRoutes(e *echo.Echo, mode string) {
 	api.DELETE("/attendees/:id", h.DeleteAttendee)                     // Soft delete
 	api.POST("/attendees/:attendee_id/printed", h.MarkAttendeePrinted) // Increment printed_count
 
+	// Ticket emails
+	api.GET("/events/:event_id/ticket-email", h.GetTicketEmailSettings)
+	api.PUT("/events/:event_id/ticket-email", h.PutTicketEmailSettings)
+	api.POST("/events/:event_id/ticket-email/send", h.SendTicketEmails)
+	api.GET("/events/:event_id/ticket-deliveries", h.GetTicketDeliveries)
+	api.GET("/events/:event_id/ticket-layout", h.GetTicketLayout)
+	api.PUT("/events/:event_id/ticket-layout", h.PutTicketLayout)
+	api.GET("/events/:event_id/tickets.zip", h.ExportTicketPDFs)
+
 	// Event Zones
 	api.POST("/events/:event_id/zones", h.CreateEventZone)
 	api.GET("/events/:event_id/zones", h.GetEventZones)
@@ -183,6 +273,42 @@ func (h *Handler) RegisterRoutes(e *echo.Echo, mode string) {
 	api.GET("/events/:event_id/api-keys", h.GetAPIKeys)
 	api.POST("/events/:event_id/api-keys", h.CreateAPIKey)
 	api.DELETE("/events/:event_id/api-keys/:key_id", h.RevokeAPIKey)
+	api.GET("/events/:event_id/api-keys/:key_id/usage", h.GetAPIKeyUsage)
+
+	// Spreadsheet import column-mapping presets (per tenant)
+	api.GET("/import-mappings", h.GetImportMappings)
+	api.POST("/import-mappings", h.CreateImportMapping)
+	api.PUT("/import-mappings/:id", h.UpdateImportMapping)
+	api.DELETE("/import-mappings/:id", h.DeleteImportMapping)
+
+	// Tenant audit trail (admin only)
+	api.GET("/audit-log", h.GetTenantAuditLog)
+
+	// Single sign-on configuration (admin only)
+	api.GET("/sso", h.GetSSOProvider)
+	api.PUT("/sso", h.PutSSOProvider)
+	api.DELETE("/sso", h.DeleteSSOProvider)
+
+	// Wallet pass credentials (admin only)
+	api.GET("/wallet", h.GetWalletSettings)
+	api.PUT("/wallet/apple", h.PutAppleWallet)
+	api.DELETE("/wallet/apple", h.DeleteAppleWallet)
+	api.PUT("/wallet/google", h.PutGoogleWallet)
+	api.DELETE("/wallet/google", h.DeleteGoogleWallet)
+
+	// Custom roles and permissions
+	api.GET("/roles", h.GetRoles)
+	api.POST("/roles", h.CreateRole)
+	api.PUT("/roles/:id", h.UpdateRole)
+	api.DELETE("/roles/:id", h.DeleteRole)
+	api.PUT("/users/:id/custom-role", h.SetUserCustomRole)
+	api.GET("/me/permissions", h.GetMyPermissions)
+
+	// Background jobs (async imports, exports, badge print batches; per tenant)
+	api.GET("/jobs", h.GetJobs)
+	api.GET("/jobs/:id", h.GetJob)
+	api.GET("/jobs/:id/stream", h.GetJobStream)
+	api.GET("/jobs/:id/artifact", h.GetJobArtifact)
 
 	// Fonts management (per event)
 	api.GET("/events/:event_id/fonts", h.GetEventFonts)
@@ -206,7 +332,7 @@ func (h *Handler) RegisterRoutes(e *echo.Echo, mode string) {
 
 	// Public API endpoints (with API key authentication)
 	public := e.Group("/api/public")
-	public.POST("/import", h.ExternalImport, middleware.APIKeyAuth(h.Store))
+	public.POST("/import", h.ExternalImport, middleware.APIKeyAuth(h.Store, h.apiKeyThrottleLog()), middleware.Idempotency(h.Store))
 
 	// Super Admin routes (platform console) — SaaS-only surface
 	if mode == config.ModeSaaS {
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+)
+
+// Import mapping presets are ORG-level resources, like the equipment
+// registry: tenant_id from tenantIDFromContext plus the store's
+// WHERE tenant_id = $1 is the ownership check, and a foreign ID collapses
+// to the same 404 as a missing one.
+
+// ImportMappingRequest is the body of POST /api/import-mappings and
+// PUT /api/import-mappings/{id}.
+type ImportMappingRequest struct {
+	Name    string            `json:"name"`
+	Columns map[string]string `json:"columns"`
+}
+
+func (r *ImportMappingRequest) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(r.Name) > 100 {
+		return errors.New("name must be at most 100 characters")
+	}
+	if len(r.Columns) > 500 {
+		return errors.New("columns may map at most 500 headers")
+	}
+	if r.Columns == nil {
+		r.Columns = map[string]string{}
+	}
+	return nil
+}
+
+// GetImportMappings lists the caller's tenant's saved mappings by name.
+func (h *Handler) GetImportMappings(c echo.Context) error {
+	tenantID, err := tenantIDFromContext(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	mappings, err := h.Store.ListImportMappings(c.Request().Context(), tenantID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch import mappings"})
+	}
+	return c.JSON(http.StatusOK, mappings)
+}
+
+// CreateImportMapping saves a new mapping preset; names are unique per
+// tenant (409 on a duplicate).
+func (h *Handler) CreateImportMapping(c echo.Context) error {
+	tenantID, err := tenantIDFromContext(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	var req ImportMappingRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := req.validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	m := &models.ImportMapping{TenantID: tenantID, Name: req.Name, Columns: req.Columns}
+	if err := h.Store.CreateImportMapping(c.Request().Context(), m); err != nil {
+		return importMappingWriteErr(c, err)
+	}
+	return c.JSON(http.StatusCreated, m)
+}
+
+// UpdateImportMapping replaces a mapping's name and columns.
+func (h *Handler) UpdateImportMapping(c echo.Context) error {
+	tenantID, err := tenantIDFromContext(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid mapping ID"})
+	}
+	var req ImportMappingRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := req.validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	m := &models.ImportMapping{ID: id, TenantID: tenantID, Name: req.Name, Columns: req.Columns}
+	if err := h.Store.UpdateImportMapping(c.Request().Context(), m); err != nil {
+		return importMappingWriteErr(c, err)
+	}
+	return c.JSON(http.StatusOK, m)
+}
+
+// DeleteImportMapping removes a mapping preset.
+func (h *Handler) DeleteImportMapping(c echo.Context) error {
+	tenantID, err := tenantIDFromContext(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid mapping ID"})
+	}
+	if err := h.Store.DeleteImportMapping(c.Request().Context(), tenantID, id); err != nil {
+		return importMappingWriteErr(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func importMappingWriteErr(c echo.Context, err error) error {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, store.ErrImportMappingNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Import mapping not found"})
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		return c.JSON(http.StatusConflict, map[string]string{"error": "An import mapping with this name already exists"})
+	default:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save import mapping"})
+	}
+}
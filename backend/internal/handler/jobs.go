@@ -0,0 +1,356 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"idento/backend/internal/jobs"
+	"idento/backend/internal/metrics"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// Background jobs are tenant-level resources like import mappings: the
+// tenant-scoped store lookups are the ownership check, and a foreign job
+// ID is the same 404 as a missing one. Jobs are created by the ?async=true
+// variants of the import and export routes and by the badge print batch;
+// internal/jobs runs them through JobHandlers.
+
+// jobListDefaultLimit and jobListMaxLimit bound GET /api/jobs.
+const (
+	jobListDefaultLimit = 50
+	jobListMaxLimit     = 200
+)
+
+// jobStreamPingInterval is the job stream's keep-alive cadence, the same
+// 25s as the monitor stream's; a var so tests can shrink it.
+var jobStreamPingInterval = 25 * time.Second
+
+// AsyncParam is the query param that turns a long-running request into a
+// background job.
+const AsyncParam = "async"
+
+// isAsync reports whether the request asked to run as a background job.
+func isAsync(c echo.Context) bool {
+	v, err := strconv.ParseBool(c.QueryParam(AsyncParam))
+	return err == nil && v
+}
+
+// JobHandlers returns the job runners for every job type the API creates,
+// for jobs.NewRunner.
+func (h *Handler) JobHandlers() map[string]jobs.HandlerFunc {
+	return map[string]jobs.HandlerFunc{
+		models.JobTypeAttendeeImport: h.runAttendeeImportJob,
+		models.JobTypeAttendeeExport: h.runAttendeeExportJob,
+		models.JobTypeBadgePrint:     h.runBadgePrintJob,
+		models.JobTypeTicketEmail:    h.runTicketEmailJob,
+		models.JobTypeTicketPDF:      h.runTicketPDFJob,
+		models.JobTypeWalletUpdate:   h.runWalletUpdateJob,
+	}
+}
+
+// enqueueJob queues a job of jobType on event with payload (JSON-encoded)
+// and answers 202 with the job and its URL in Location. total is the
+// progress total when known up front, else 0.
+func (h *Handler) enqueueJob(c echo.Context, event *models.Event, jobType string, payload interface{}, total int) error {
+	claims, err := claimsFromContext(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to queue job"})
+	}
+	job := &models.Job{
+		TenantID: event.TenantID,
+		EventID:  &event.ID,
+		Type:     jobType,
+		Payload:  raw,
+		Progress: models.JobProgress{Total: total},
+	}
+	if userID, err := uuid.Parse(claims.UserID); err == nil {
+		job.CreatedBy = &userID
+	}
+	if err := h.Store.EnqueueJob(c.Request().Context(), job); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to queue job"})
+	}
+	c.Response().Header().Set(echo.HeaderLocation, "/api/jobs/"+job.ID.String())
+	return c.JSON(http.StatusAccepted, job)
+}
+
+// jobEvent loads the event a running job works on, tenant-scoped like the
+// request that queued it.
+func (h *Handler) jobEvent(ctx context.Context, job *models.Job) (*models.Event, error) {
+	if job.EventID == nil {
+		return nil, errors.New("job has no event")
+	}
+	event, err := h.Store.GetEventByIDForTenant(ctx, *job.EventID, job.TenantID)
+	if err != nil {
+		return nil, errors.New("failed to load the event")
+	}
+	if event == nil {
+		return nil, errors.New("the event no longer exists")
+	}
+	return event, nil
+}
+
+// jobPermissions is the permission a job type needs on the job's event to
+// be listed, followed or downloaded: the one starting such a job takes. A
+// type not listed here (badge print, wallet update) is open to whoever may
+// see its event, as starting it is.
+var jobPermissions = map[string]Permission{
+	models.JobTypeAttendeeImport: PermAttendeesEdit,
+	models.JobTypeAttendeeExport: PermExport,
+	models.JobTypeTicketPDF:      PermExport,
+	models.JobTypeTicketEmail:    PermTicketsSend,
+}
+
+// canSeeJob reports whether the caller may see job: it needs the job
+// type's permission on the job's event, and a custom role scoped to other
+// events sees none of this event's jobs.
+func (h *Handler) canSeeJob(c echo.Context, job *models.Job) (bool, error) {
+	if job.EventID == nil {
+		return true, nil
+	}
+	if perm, ok := jobPermissions[job.Type]; ok {
+		return h.hasPermission(c, perm, *job.EventID)
+	}
+	claims, err := claimsFromContext(c)
+	if err != nil {
+		return false, err
+	}
+	if claims.Role == "admin" {
+		return true, nil
+	}
+	custom, err := h.callerCustomRole(c, claims)
+	if err != nil {
+		return false, err
+	}
+	return custom == nil || len(custom.EventIDs) == 0 || slices.Contains(custom.EventIDs, *job.EventID), nil
+}
+
+// jobFromParam loads the :id job of the caller's tenant, if the caller may
+// see it (canSeeJob).
+func (h *Handler) jobFromParam(c echo.Context) (*models.Job, error) {
+	tenantID, err := tenantIDFromContext(c)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, "Invalid job ID")
+	}
+	job, err := h.Store.GetJob(c.Request().Context(), tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, newHTTPError(http.StatusNotFound, "Job not found")
+	}
+	ok, err := h.canSeeJob(c, job)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if perm, typed := jobPermissions[job.Type]; typed {
+			return nil, newHTTPError(http.StatusForbidden, "Permission denied: "+string(perm))
+		}
+		return nil, newHTTPError(http.StatusForbidden, "Permission denied")
+	}
+	return job, nil
+}
+
+// GetJobs lists the caller's tenant's jobs, newest first; ?event_id narrows
+// to one event, ?limit caps the list (default 50, max 200). Jobs the caller
+// may not see (canSeeJob) are left out, so a list can come back shorter
+// than the limit.
+func (h *Handler) GetJobs(c echo.Context) error {
+	tenantID, err := tenantIDFromContext(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	f := store.JobFilter{Limit: jobListDefaultLimit}
+	if raw := c.QueryParam("event_id"); raw != "" {
+		eventID, err := uuid.Parse(raw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid event ID"})
+		}
+		f.EventID = &eventID
+	}
+	if raw := c.QueryParam("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > jobListMaxLimit {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("limit must be between 1 and %d", jobListMaxLimit)})
+		}
+		f.Limit = n
+	}
+	list, err := h.Store.ListJobs(c.Request().Context(), tenantID, f)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch jobs"})
+	}
+	visible := make([]*models.Job, 0, len(list))
+	for _, job := range list {
+		ok, err := h.canSeeJob(c, job)
+		if err != nil {
+			return writeErr(c, err)
+		}
+		if ok {
+			visible = append(visible, job)
+		}
+	}
+	return c.JSON(http.StatusOK, visible)
+}
+
+// GetJob returns one job: status, progress, and once finished its result or
+// error.
+func (h *Handler) GetJob(c echo.Context) error {
+	job, err := h.jobFromParam(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	return c.JSON(http.StatusOK, job)
+}
+
+// GetJobArtifact downloads a finished job's output file.
+func (h *Handler) GetJobArtifact(c echo.Context) error {
+	job, err := h.jobFromParam(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	if !job.Finished() {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Job has not finished"})
+	}
+	artifact, err := h.Store.GetJobArtifact(c.Request().Context(), job.TenantID, job.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load job artifact"})
+	}
+	if artifact == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Job has no artifact"})
+	}
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, artifact.ContentType)
+	res.Header().Set(echo.HeaderContentLength, strconv.FormatInt(artifact.Size, 10))
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", artifact.Name))
+	res.WriteHeader(http.StatusOK)
+	// The status is out: a failure from here on can only truncate the
+	// download, which the Content-Length lets the client notice.
+	if err := h.Store.WriteJobArtifact(c.Request().Context(), job.TenantID, job.ID, res); err != nil {
+		c.Logger().Errorf("job %s artifact download aborted: %v", job.ID, err)
+	}
+	return nil
+}
+
+// spoolJobArtifact runs write into a fresh jobs.ArtifactFile capped at
+// JobArtifactMaxBytes and returns the file as the artifact name. When
+// write fails the file is removed and write's error returned, unless the
+// cap is what stopped it: then the job fails saying so.
+func (h *Handler) spoolJobArtifact(name, contentType string, write func(w io.Writer) error) (*models.JobArtifact, error) {
+	file, err := jobs.NewArtifactFile(h.JobArtifactMaxBytes)
+	if err != nil {
+		log.Printf("Job artifact %q: %v", name, err)
+		return nil, errors.New("failed to create the download file")
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		if tooLarge := file.TooLarge(); tooLarge != nil {
+			return nil, tooLarge
+		}
+		return nil, err
+	}
+	artifact, err := file.Artifact(name, contentType)
+	if err != nil {
+		file.Close()
+		log.Printf("Job artifact %q: %v", name, err)
+		return nil, errors.New("failed to create the download file")
+	}
+	return artifact, nil
+}
+
+// GetJobStream serves GET /api/jobs/{id}/stream: Server-Sent Events with
+// the job itself as data, so a client can follow a long import or export
+// without polling and without holding the original request open. A
+// "progress" frame is sent on connect and whenever the job's worker
+// publishes a change; the final frame is "done", carrying the finished job,
+// after which the server closes the stream. The ordering discipline is the
+// monitor stream's (see GetEventMonitorStream): the lookup and the
+// nil-Broker check come before any stream header, and the subscription is
+// live before the first frame.
+func (h *Handler) GetJobStream(c echo.Context) error {
+	job, err := h.jobFromParam(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	if h.Broker == nil {
+		return writeErr(c, newHTTPError(http.StatusServiceUnavailable, "Job progress stream unavailable: no event broker configured"))
+	}
+	if err := h.refuseStreamWhileDraining(c); err != nil {
+		return writeErr(c, err)
+	}
+
+	res := c.Response()
+	writeSSEHeaders(res)
+
+	ch, unsubscribe := h.Broker.Subscribe(job.ID)
+	defer unsubscribe()
+	streams := metrics.SSEStreams.WithLabelValues("job")
+	streams.Inc()
+	defer streams.Dec()
+
+	ctx := c.Request().Context()
+	// The job may have moved between the lookup and the subscription, so
+	// the first frame is read after subscribing.
+	if job, err = h.Store.GetJob(ctx, job.TenantID, job.ID); err != nil || job == nil {
+		return nil
+	}
+	if !writeJobFrame(res, job) || job.Finished() {
+		return nil
+	}
+
+	ticker := time.NewTicker(jobStreamPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-h.drain.done():
+			writeReconnectFrame(res)
+			return nil
+		case <-ch:
+			next, err := h.Store.GetJob(ctx, job.TenantID, job.ID)
+			if err != nil || next == nil {
+				return nil
+			}
+			if !writeJobFrame(res, next) || next.Finished() {
+				return nil
+			}
+		case <-ticker.C:
+			if !writeSSEFrame(res, ": ping\n\n") {
+				return nil
+			}
+		}
+	}
+}
+
+// writeJobFrame writes job as a "progress" frame, or "done" once finished.
+func writeJobFrame(res *echo.Response, job *models.Job) bool {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return false
+	}
+	event := "progress"
+	if job.Finished() {
+		event = "done"
+	}
+	return writeSSEFrame(res, fmt.Sprintf("event: %s\ndata: %s\n\n", event, data))
+}
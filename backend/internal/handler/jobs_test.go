@@ -0,0 +1,687 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"idento/backend/internal/broker"
+	"idento/backend/internal/jobs"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// Unlike the monitor stream, a job stream ends once its job is finished,
+// so a finished job's stream is one complete body the contract harness can
+// validate — as plain text, which is what an event-stream schema is.
+func init() {
+	openapi3filter.RegisterBodyDecoder("text/event-stream", openapi3filter.PlainBodyDecoder)
+}
+
+func contractJob(tenantID, eventID uuid.UUID, status string) *models.Job {
+	now := time.Now()
+	return &models.Job{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		EventID:   &eventID,
+		Type:      models.JobTypeAttendeeExport,
+		Status:    status,
+		Progress:  models.JobProgress{Done: 3, Total: 10},
+		Attempts:  1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// jobStore is a fakeStore that records enqueued jobs and serves them back
+// through the tenant-scoped getters.
+func jobStore(fs *fakeStore) *fakeStore {
+	var mu sync.Mutex
+	queued := map[uuid.UUID]*models.Job{}
+	fs.enqueueJob = func(j *models.Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+		j.ID, j.Status, j.CreatedAt, j.UpdatedAt = uuid.New(), models.JobStatusQueued, time.Now(), time.Now()
+		queued[j.ID] = j
+		return nil
+	}
+	fs.getJob = func(tenantID, id uuid.UUID) (*models.Job, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if j := queued[id]; j != nil && j.TenantID == tenantID {
+			return j, nil
+		}
+		return nil, nil
+	}
+	return fs
+}
+
+// decodeAcceptedJob checks a 202 job response and returns the job.
+func decodeAcceptedJob(t *testing.T, rec *httptest.ResponseRecorder, wantType string) *models.Job {
+	t.Helper()
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("want 202, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	var job models.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+	if job.Type != wantType || job.Status != models.JobStatusQueued {
+		t.Fatalf("job = %s/%s, want %s/queued", job.Type, job.Status, wantType)
+	}
+	if loc := rec.Header().Get(echo.HeaderLocation); loc != "/api/jobs/"+job.ID.String() {
+		t.Errorf("Location = %q", loc)
+	}
+	return &job
+}
+
+// runQueuedJob runs a queued job through its JobHandlers entry the way a
+// worker would, recording the last progress report, and reads back (and
+// removes) its artifact file.
+func runQueuedJob(t *testing.T, h *Handler, fs *fakeStore, tenantID, id uuid.UUID) (*models.Job, models.JobProgress, interface{}, *models.JobArtifact, []byte) {
+	t.Helper()
+	job, err := fs.getJob(tenantID, id)
+	if err != nil || job == nil {
+		t.Fatalf("queued job %s not found (%v)", id, err)
+	}
+	var last models.JobProgress
+	out, err := h.JobHandlers()[job.Type](context.Background(), job, func(done, total int) {
+		last = models.JobProgress{Done: done, Total: total}
+	})
+	if err != nil {
+		t.Fatalf("run %s job: %v", job.Type, err)
+	}
+	var data []byte
+	if out.Artifact != nil {
+		if data, err = io.ReadAll(out.Artifact.Content); err != nil {
+			t.Fatalf("read %s artifact: %v", job.Type, err)
+		}
+		if c, ok := out.Artifact.Content.(io.Closer); ok {
+			c.Close()
+		}
+	}
+	return job, last, out.Result, out.Artifact, data
+}
+
+func TestContractGetJobs(t *testing.T) {
+	tenantID := uuid.New()
+	eventID := uuid.New()
+	var gotFilter store.JobFilter
+	h := New(&fakeStore{
+		listJobs: func(tid uuid.UUID, f store.JobFilter) ([]*models.Job, error) {
+			if tid != tenantID {
+				t.Errorf("listJobs tenant = %s, want the caller's", tid)
+			}
+			gotFilter = f
+			failed := contractJob(tenantID, eventID, models.JobStatusFailed)
+			msg := "the event no longer exists"
+			failed.Error = &msg
+			return []*models.Job{contractJob(tenantID, eventID, models.JobStatusRunning), failed}, nil
+		},
+	})
+	e := echo.New()
+	path := "/api/jobs?event_id=" + eventID.String() + "&limit=5"
+	c, rec := newAuthedContext(e, http.MethodGet, path, "", tenantID.String(), "admin")
+	if err := h.GetJobs(c); err != nil {
+		t.Fatalf("GetJobs: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodGet, path, rec)
+	if gotFilter.Limit != 5 || gotFilter.EventID == nil || *gotFilter.EventID != eventID {
+		t.Errorf("filter = %+v, want event %s limit 5", gotFilter, eventID)
+	}
+
+	for _, bad := range []string{"/api/jobs?limit=0", "/api/jobs?limit=201", "/api/jobs?event_id=nope"} {
+		c, rec = newAuthedContext(e, http.MethodGet, bad, "", tenantID.String(), "admin")
+		if err := h.GetJobs(c); err != nil {
+			t.Fatalf("GetJobs: %v", err)
+		}
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: want 400, got %d", bad, rec.Code)
+		}
+		validateResponse(t, http.MethodGet, bad, rec)
+	}
+}
+
+func TestContractGetJob(t *testing.T) {
+	tenantID := uuid.New()
+	job := contractJob(tenantID, uuid.New(), models.JobStatusSucceeded)
+	job.Result = json.RawMessage(`{"rows":10}`)
+	name := "Tech-Summit-attendees.csv"
+	job.ArtifactName = &name
+	h := New(&fakeStore{
+		getJob: func(tid, id uuid.UUID) (*models.Job, error) {
+			if tid == tenantID && id == job.ID {
+				return job, nil
+			}
+			return nil, nil
+		},
+	})
+	e := echo.New()
+	for _, tc := range []struct {
+		id     string
+		tenant uuid.UUID
+		want   int
+	}{
+		{job.ID.String(), tenantID, http.StatusOK},
+		{job.ID.String(), uuid.New(), http.StatusNotFound}, // foreign tenant
+		{"not-a-uuid", tenantID, http.StatusBadRequest},
+	} {
+		path := "/api/jobs/" + tc.id
+		c, rec := newAuthedContext(e, http.MethodGet, path, "", tc.tenant.String(), "admin")
+		c.SetParamNames("id")
+		c.SetParamValues(tc.id)
+		if err := h.GetJob(c); err != nil {
+			t.Fatalf("GetJob: %v", err)
+		}
+		if rec.Code != tc.want {
+			t.Fatalf("%s: want %d, got %d, body=%s", tc.id, tc.want, rec.Code, rec.Body.String())
+		}
+		validateResponse(t, http.MethodGet, path, rec)
+	}
+}
+
+func TestContractGetJobArtifact(t *testing.T) {
+	tenantID := uuid.New()
+	done := contractJob(tenantID, uuid.New(), models.JobStatusSucceeded)
+	running := contractJob(tenantID, uuid.New(), models.JobStatusRunning)
+	failed := contractJob(tenantID, uuid.New(), models.JobStatusFailed)
+	jobsByID := map[uuid.UUID]*models.Job{done.ID: done, running.ID: running, failed.ID: failed}
+	h := New(&fakeStore{
+		getJob: func(_, id uuid.UUID) (*models.Job, error) { return jobsByID[id], nil },
+		getJobArtifact: func(_, id uuid.UUID) (*models.JobArtifact, error) {
+			if id != done.ID {
+				return nil, nil
+			}
+			return &models.JobArtifact{Name: "Tech-Summit-attendees.csv", ContentType: "text/csv", Size: 9}, nil
+		},
+		writeJobArtifact: func(_, id uuid.UUID, w io.Writer) error {
+			_, err := io.WriteString(w, "code\nABC\n")
+			return err
+		},
+	})
+	e := echo.New()
+	for _, tc := range []struct {
+		job  *models.Job
+		want int
+	}{
+		{done, http.StatusOK},
+		{running, http.StatusConflict},
+		{failed, http.StatusNotFound},
+	} {
+		path := "/api/jobs/" + tc.job.ID.String() + "/artifact"
+		c, rec := newAuthedContext(e, http.MethodGet, path, "", tenantID.String(), "admin")
+		c.SetParamNames("id")
+		c.SetParamValues(tc.job.ID.String())
+		if err := h.GetJobArtifact(c); err != nil {
+			t.Fatalf("GetJobArtifact: %v", err)
+		}
+		if rec.Code != tc.want {
+			t.Fatalf("%s job: want %d, got %d, body=%s", tc.job.Status, tc.want, rec.Code, rec.Body.String())
+		}
+		validateResponse(t, http.MethodGet, path, rec)
+		if tc.want == http.StatusOK {
+			if rec.Body.String() != "code\nABC\n" {
+				t.Errorf("artifact body = %q", rec.Body.String())
+			}
+			if cd := rec.Header().Get(echo.HeaderContentDisposition); cd != `attachment; filename="Tech-Summit-attendees.csv"` {
+				t.Errorf("Content-Disposition = %q", cd)
+			}
+		}
+	}
+}
+
+// A finished job's stream is one complete response: the done frame, then
+// the end of the body.
+func TestContractGetJobStreamFinished(t *testing.T) {
+	tenantID := uuid.New()
+	job := contractJob(tenantID, uuid.New(), models.JobStatusSucceeded)
+	h := New(&fakeStore{getJob: func(_, id uuid.UUID) (*models.Job, error) {
+		if id == job.ID {
+			return job, nil
+		}
+		return nil, nil
+	}})
+	h.Broker = broker.NewMemBroker()
+	e := echo.New()
+
+	path := "/api/jobs/" + job.ID.String() + "/stream"
+	c, rec := newAuthedContext(e, http.MethodGet, path, "", tenantID.String(), "admin")
+	c.SetParamNames("id")
+	c.SetParamValues(job.ID.String())
+	if err := h.GetJobStream(c); err != nil {
+		t.Fatalf("GetJobStream: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, "event: done\ndata: {") || strings.Count(body, "event:") != 1 {
+		t.Fatalf("body = %q, want exactly one done frame", body)
+	}
+	validateResponse(t, http.MethodGet, path, rec)
+
+	// 404 for a missing job, before any stream header.
+	missing := "/api/jobs/" + uuid.New().String() + "/stream"
+	c, rec = newAuthedContext(e, http.MethodGet, missing, "", tenantID.String(), "admin")
+	c.SetParamNames("id")
+	c.SetParamValues(strings.Split(missing, "/")[3])
+	if err := h.GetJobStream(c); err != nil {
+		t.Fatalf("GetJobStream: %v", err)
+	}
+	if rec.Code != http.StatusNotFound || strings.HasPrefix(rec.Header().Get(echo.HeaderContentType), "text/event-stream") {
+		t.Fatalf("missing job: got %d %q, want a plain 404", rec.Code, rec.Header().Get(echo.HeaderContentType))
+	}
+	validateResponse(t, http.MethodGet, missing, rec)
+
+	// 503 without a broker.
+	h.Broker = nil
+	c, rec = newAuthedContext(e, http.MethodGet, path, "", tenantID.String(), "admin")
+	c.SetParamNames("id")
+	c.SetParamValues(job.ID.String())
+	if err := h.GetJobStream(c); err != nil {
+		t.Fatalf("GetJobStream: %v", err)
+	}
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("nil broker: want 503, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodGet, path, rec)
+}
+
+// A live stream sends progress frames on every publish and ends with the
+// done frame once the job finishes.
+func TestGetJobStreamFollowsJobToDone(t *testing.T) {
+	tenantID := uuid.New()
+	var mu sync.Mutex
+	job := contractJob(tenantID, uuid.New(), models.JobStatusRunning)
+	snapshot := func() *models.Job {
+		mu.Lock()
+		defer mu.Unlock()
+		cp := *job
+		return &cp
+	}
+	mem := broker.NewMemBroker()
+	h := New(&fakeStore{getJob: func(_, id uuid.UUID) (*models.Job, error) { return snapshot(), nil }})
+	h.Broker = mem
+
+	e := echo.New()
+	finished := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(finished)
+		c := e.NewContext(r, w)
+		c.SetParamNames("id")
+		c.SetParamValues(job.ID.String())
+		c.Set("user", &models.JWTCustomClaims{UserID: uuid.New().String(), TenantID: tenantID.String(), Role: "admin"})
+		if err := h.GetJobStream(c); err != nil {
+			t.Errorf("GetJobStream: %v", err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET stream: %v", err)
+	}
+	defer resp.Body.Close()
+	r := bufio.NewReader(resp.Body)
+
+	if frame := readSSEFrame(t, r, 2*time.Second); !strings.HasPrefix(frame, "event: progress\n") || !strings.Contains(frame, `"done":3`) {
+		t.Fatalf("first frame = %q, want progress 3", frame)
+	}
+
+	mu.Lock()
+	job.Progress.Done = 7
+	mu.Unlock()
+	if err := mem.Publish(context.Background(), broker.Changed(job.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if frame := readSSEFrame(t, r, 2*time.Second); !strings.HasPrefix(frame, "event: progress\n") || !strings.Contains(frame, `"done":7`) {
+		t.Fatalf("second frame = %q, want progress 7", frame)
+	}
+
+	mu.Lock()
+	job.Status = models.JobStatusSucceeded
+	mu.Unlock()
+	if err := mem.Publish(context.Background(), broker.Changed(job.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if frame := readSSEFrame(t, r, 2*time.Second); !strings.HasPrefix(frame, "event: done\n") {
+		t.Fatalf("last frame = %q, want done", frame)
+	}
+	select {
+	case <-finished:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not end after the done frame")
+	}
+}
+
+// ?async=true on the bulk route queues the rows instead of importing them;
+// the job then produces the synchronous route's response.
+func TestContractBulkCreateAttendeesAsync(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	var created []*models.Attendee
+	fs := jobStore(&fakeStore{
+		getEventByID:          func(uuid.UUID) (*models.Event, error) { return event, nil },
+		checkAttendeeLimit:    func(uuid.UUID, uuid.UUID, int) (bool, int, int, error) { return true, 0, 100, nil },
+		getAttendeesByEventID: func(uuid.UUID, string, string) ([]*models.Attendee, error) { return nil, nil },
+		createAttendee:        func(a *models.Attendee) error { created = append(created, a); return nil },
+		updateEvent:           func(*models.Event) error { return nil },
+	})
+	h := New(fs)
+	e := echo.New()
+	path := "/api/events/" + event.ID.String() + "/attendees/bulk?async=true"
+	body := `{"attendees":[{"first_name":"Ada","email":"ada@example.com"},{"first_name":"Bob","email":"bob@example.com"}],"field_schema":["first_name","email"]}`
+	c, rec := newAuthedContext(e, http.MethodPost, path, body, tenantID.String(), "admin")
+	c.SetParamNames("event_id")
+	c.SetParamValues(event.ID.String())
+	if err := h.BulkCreateAttendees(c); err != nil {
+		t.Fatalf("BulkCreateAttendees: %v", err)
+	}
+	job := decodeAcceptedJob(t, rec, models.JobTypeAttendeeImport)
+	validateResponse(t, http.MethodPost, path, rec)
+	if len(created) != 0 {
+		t.Fatalf("async request created %d attendees in the request", len(created))
+	}
+	if job.Progress.Total != 2 {
+		t.Errorf("progress.total = %d, want 2", job.Progress.Total)
+	}
+
+	_, progress, result, _, _ := runQueuedJob(t, h, fs, tenantID, job.ID)
+	if len(created) != 2 {
+		t.Fatalf("job created %d attendees, want 2", len(created))
+	}
+	if progress != (models.JobProgress{Done: 2, Total: 2}) {
+		t.Errorf("final progress = %+v", progress)
+	}
+	if res, ok := result.(*BulkImportResponse); !ok || res.Created != 2 {
+		t.Errorf("result = %#v, want a BulkImportResponse with 2 created", result)
+	}
+}
+
+// A batch over the plan limit is refused at enqueue time, not queued.
+func TestBulkCreateAttendeesAsyncChecksLimitUpFront(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	fs := jobStore(&fakeStore{
+		getEventByID:       func(uuid.UUID) (*models.Event, error) { return event, nil },
+		checkAttendeeLimit: func(uuid.UUID, uuid.UUID, int) (bool, int, int, error) { return false, 99, 100, nil },
+	})
+	enqueued := false
+	fs.enqueueJob = func(*models.Job) error { enqueued = true; return nil }
+	h := New(fs)
+	path := "/api/events/" + event.ID.String() + "/attendees/bulk?async=true"
+	c, rec := newAuthedContext(echo.New(), http.MethodPost, path, `{"attendees":[{"first_name":"A"},{"first_name":"B"}]}`, tenantID.String(), "admin")
+	c.SetParamNames("event_id")
+	c.SetParamValues(event.ID.String())
+	if err := h.BulkCreateAttendees(c); err != nil {
+		t.Fatalf("BulkCreateAttendees: %v", err)
+	}
+	if rec.Code != http.StatusForbidden || enqueued {
+		t.Fatalf("got %d (enqueued=%v), want 403 and nothing queued", rec.Code, enqueued)
+	}
+	validateResponse(t, http.MethodPost, path, rec)
+}
+
+func TestContractImportAttendeesFileAsync(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	fs := jobStore(&fakeStore{
+		getEventByID:       func(uuid.UUID) (*models.Event, error) { return event, nil },
+		checkAttendeeLimit: func(uuid.UUID, uuid.UUID, int) (bool, int, int, error) { return true, 0, 100000, nil },
+	})
+	h := New(fs)
+	var sb strings.Builder
+	sb.WriteString("first_name,email\n")
+	for i := 0; i < attendeeImportMaxRows+1; i++ {
+		sb.WriteString("Guest,\n")
+	}
+	e := echo.New()
+	c, rec := newUploadContext(t, e, tenantID.String(), uuid.New(), nil, "file", "guests.csv", []byte(sb.String()))
+	c.Request().URL.RawQuery = "async=true"
+	c.SetParamNames("event_id")
+	c.SetParamValues(event.ID.String())
+	if err := h.ImportAttendeesFile(c); err != nil {
+		t.Fatalf("ImportAttendeesFile: %v", err)
+	}
+	job := decodeAcceptedJob(t, rec, models.JobTypeAttendeeImport)
+	validateResponse(t, http.MethodPost, "/api/events/"+event.ID.String()+"/attendees/import?async=true", rec)
+	if job.Progress.Total != attendeeImportMaxRows+1 {
+		t.Errorf("progress.total = %d, want %d", job.Progress.Total, attendeeImportMaxRows+1)
+	}
+}
+
+func TestContractExportAttendeesAsync(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	attendee := contractAttendee(event.ID)
+	var streamed store.AttendeeFilter
+	fs := jobStore(&fakeStore{
+		getEventByID: func(uuid.UUID) (*models.Event, error) { return event, nil },
+		getAttendeesPage: func(uuid.UUID, store.AttendeeFilter) ([]*models.Attendee, int, error) {
+			return nil, 1, nil
+		},
+		streamAttendeeExport: func(_ uuid.UUID, f store.AttendeeFilter, _ bool, fn func(*store.AttendeeExportRow) error) error {
+			streamed = f
+			return fn(&store.AttendeeExportRow{Attendee: attendee})
+		},
+	})
+	h := New(fs)
+	path := "/api/events/" + event.ID.String() + "/attendees/export?async=true&status=not_checked_in&columns=code,last_name"
+	c, rec := newAuthedContext(echo.New(), http.MethodGet, path, "", tenantID.String(), "admin")
+	c.SetParamNames("event_id")
+	c.SetParamValues(event.ID.String())
+	if err := h.ExportAttendees(c); err != nil {
+		t.Fatalf("ExportAttendees: %v", err)
+	}
+	job := decodeAcceptedJob(t, rec, models.JobTypeAttendeeExport)
+	validateResponse(t, http.MethodGet, path, rec)
+
+	_, progress, result, artifact, data := runQueuedJob(t, h, fs, tenantID, job.ID)
+	if streamed.Status == nil || *streamed.Status {
+		t.Errorf("job export filter = %+v, want the not_checked_in status", streamed)
+	}
+	if progress != (models.JobProgress{Done: 1, Total: 1}) {
+		t.Errorf("final progress = %+v", progress)
+	}
+	if rows, ok := result.(map[string]int); !ok || rows["rows"] != 1 {
+		t.Errorf("result = %#v, want rows 1", result)
+	}
+	if artifact == nil || artifact.Name != "Tech-Summit-attendees.csv" || artifact.ContentType != "text/csv" {
+		t.Fatalf("artifact = %+v", artifact)
+	}
+	want := "code,last_name\n" + attendee.Code + "," + attendee.LastName + "\n"
+	if string(data) != want {
+		t.Errorf("artifact data = %q, want %q", data, want)
+	}
+}
+
+// An export job whose file would outgrow JobArtifactMaxBytes fails saying
+// so, instead of the generic export error its CSV writer reports.
+func TestExportJobFailsPastTheArtifactLimit(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	attendee := contractAttendee(event.ID)
+	fs := jobStore(&fakeStore{
+		getEventByID: func(uuid.UUID) (*models.Event, error) { return event, nil },
+		getAttendeesPage: func(uuid.UUID, store.AttendeeFilter) ([]*models.Attendee, int, error) {
+			return nil, 1000, nil
+		},
+		streamAttendeeExport: func(_ uuid.UUID, _ store.AttendeeFilter, _ bool, fn func(*store.AttendeeExportRow) error) error {
+			for i := 0; i < 1000; i++ {
+				if err := fn(&store.AttendeeExportRow{Attendee: attendee}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	h := New(fs)
+	h.JobArtifactMaxBytes = 1024
+	path := "/api/events/" + event.ID.String() + "/attendees/export?async=true"
+	c, rec := newAuthedContext(echo.New(), http.MethodGet, path, "", tenantID.String(), "admin")
+	c.SetParamNames("event_id")
+	c.SetParamValues(event.ID.String())
+	if err := h.ExportAttendees(c); err != nil {
+		t.Fatalf("ExportAttendees: %v", err)
+	}
+	job := decodeAcceptedJob(t, rec, models.JobTypeAttendeeExport)
+	queued, _ := fs.getJob(tenantID, job.ID)
+
+	_, err := h.JobHandlers()[models.JobTypeAttendeeExport](context.Background(), queued, func(int, int) {})
+	var tooLarge *jobs.ArtifactTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 1024 {
+		t.Fatalf("job error = %v, want the artifact limit", err)
+	}
+}
+
+func TestContractCreateBadgePrintJob(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	first, second := contractAttendee(event.ID), contractAttendee(event.ID)
+	second.Code = "SECOND01"
+	fs := jobStore(&fakeStore{
+		getEventByID: func(uuid.UUID) (*models.Event, error) { return event, nil },
+		getAttendeesPage: func(uuid.UUID, store.AttendeeFilter) ([]*models.Attendee, int, error) {
+			return nil, 2, nil
+		},
+		streamAttendeeExport: func(_ uuid.UUID, _ store.AttendeeFilter, _ bool, fn func(*store.AttendeeExportRow) error) error {
+			for _, a := range []*models.Attendee{first, second} {
+				if err := fn(&store.AttendeeExportRow{Attendee: a}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	h := New(fs)
+	e := echo.New()
+	path := "/api/events/" + event.ID.String() + "/badge-zpl/jobs?status=not_checked_in"
+	c, rec := newAuthedContext(e, http.MethodPost, path, "", tenantID.String(), "admin")
+	c.SetParamNames("id")
+	c.SetParamValues(event.ID.String())
+	if err := h.CreateBadgePrintJob(c); err != nil {
+		t.Fatalf("CreateBadgePrintJob: %v", err)
+	}
+	job := decodeAcceptedJob(t, rec, models.JobTypeBadgePrint)
+	validateResponse(t, http.MethodPost, path, rec)
+
+	_, progress, result, artifact, data := runQueuedJob(t, h, fs, tenantID, job.ID)
+	if progress != (models.JobProgress{Done: 2, Total: 2}) {
+		t.Errorf("final progress = %+v", progress)
+	}
+	if n, ok := result.(map[string]int); !ok || n["badges"] != 2 {
+		t.Errorf("result = %#v, want badges 2", result)
+	}
+	if artifact == nil || artifact.Name != "Tech-Summit-badges.zpl" {
+		t.Fatalf("artifact = %+v", artifact)
+	}
+	if got := strings.Count(string(data), "^XA"); got != 2 {
+		t.Errorf("artifact holds %d labels, want 2:\n%s", got, data)
+	}
+
+	// 400: bad status filter.
+	bad := "/api/events/" + event.ID.String() + "/badge-zpl/jobs?status=maybe"
+	c, rec = newAuthedContext(e, http.MethodPost, bad, "", tenantID.String(), "admin")
+	c.SetParamNames("id")
+	c.SetParamValues(event.ID.String())
+	if err := h.CreateBadgePrintJob(c); err != nil {
+		t.Fatalf("CreateBadgePrintJob: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("want 400, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodPost, bad, rec)
+}
+
+// A job whose event was deleted after it was queued fails with a message
+// fit for its owner.
+func TestAttendeeImportJobEventGone(t *testing.T) {
+	tenantID, eventID := uuid.New(), uuid.New()
+	h := New(&fakeStore{getEventByID: func(uuid.UUID) (*models.Event, error) { return nil, nil }})
+	job := &models.Job{ID: uuid.New(), TenantID: tenantID, EventID: &eventID, Type: models.JobTypeAttendeeImport,
+		Payload: json.RawMessage(`{"rows":[{"first_name":"A"}]}`)}
+	_, err := h.JobHandlers()[job.Type](context.Background(), job, func(int, int) {})
+	if err == nil || err.Error() != "the event no longer exists" {
+		t.Fatalf("err = %v, want the event-gone message", err)
+	}
+}
+
+// A job is only as visible as the permission that starts it: staff can't
+// send tickets, so they neither see a ticket email job nor download its
+// file, while a badge print job stays open to them.
+func TestJobsFollowTheirTypesPermission(t *testing.T) {
+	tenantID, eventID := uuid.New(), uuid.New()
+	tickets := contractJob(tenantID, eventID, models.JobStatusSucceeded)
+	tickets.Type = models.JobTypeTicketEmail
+	badges := contractJob(tenantID, eventID, models.JobStatusSucceeded)
+	badges.Type = models.JobTypeBadgePrint
+	jobsByID := map[uuid.UUID]*models.Job{tickets.ID: tickets, badges.ID: badges}
+	h := New(&fakeStore{
+		listJobs: func(uuid.UUID, store.JobFilter) ([]*models.Job, error) { return []*models.Job{tickets, badges}, nil },
+		getJob:   func(_, id uuid.UUID) (*models.Job, error) { return jobsByID[id], nil },
+		getJobArtifact: func(uuid.UUID, uuid.UUID) (*models.JobArtifact, error) {
+			return &models.JobArtifact{Name: "out", ContentType: "text/plain", Size: 1}, nil
+		},
+		writeJobArtifact: func(_, _ uuid.UUID, w io.Writer) error {
+			_, err := io.WriteString(w, "x")
+			return err
+		},
+	})
+	e := echo.New()
+
+	c, rec := newAuthedContext(e, http.MethodGet, "/api/jobs", "", tenantID.String(), "staff")
+	if err := h.GetJobs(c); err != nil {
+		t.Fatalf("GetJobs: %v", err)
+	}
+	var listed []models.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil || len(listed) != 1 || listed[0].ID != badges.ID {
+		t.Fatalf("staff list = %s, want only the badge print job", rec.Body.String())
+	}
+
+	for _, tc := range []struct {
+		job  *models.Job
+		want int
+	}{
+		{tickets, http.StatusForbidden},
+		{badges, http.StatusOK},
+	} {
+		path := "/api/jobs/" + tc.job.ID.String() + "/artifact"
+		c, rec := newAuthedContext(e, http.MethodGet, path, "", tenantID.String(), "staff")
+		c.SetParamNames("id")
+		c.SetParamValues(tc.job.ID.String())
+		if err := h.GetJobArtifact(c); err != nil {
+			t.Fatalf("GetJobArtifact: %v", err)
+		}
+		if rec.Code != tc.want {
+			t.Fatalf("%s artifact: want %d, got %d", tc.job.Type, tc.want, rec.Code)
+		}
+		validateResponse(t, http.MethodGet, path, rec)
+	}
+
+	// A custom role scoped to another event sees none of this one's jobs.
+	h.Store.(*fakeStore).getMemberCustomRole = func(uuid.UUID, uuid.UUID) (*models.TenantRole, error) {
+		return &models.TenantRole{Permissions: []string{string(PermTicketsSend)}, EventIDs: []uuid.UUID{uuid.New()}}, nil
+	}
+	c, rec = newAuthedContext(e, http.MethodGet, "/api/jobs", "", tenantID.String(), "manager")
+	if err := h.GetJobs(c); err != nil {
+		t.Fatalf("GetJobs: %v", err)
+	}
+	if strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Fatalf("scoped role list = %s, want none", rec.Body.String())
+	}
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"idento/backend/internal/config"
+	"idento/backend/internal/metrics"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterMetricsRoute mounts GET /metrics and reports whether it did. With
+// a token, scrapes must send it as a bearer token. Without one the route is
+// open on-prem, where the backend usually sits on a private network, and
+// not mounted at all in SaaS mode, where it would be public.
+func RegisterMetricsRoute(e *echo.Echo, mode, token string) bool {
+	if token == "" && mode == config.ModeSaaS {
+		return false
+	}
+	e.GET("/metrics", Metrics(token))
+	return true
+}
+
+// Metrics serves the Prometheus exposition, guarded by token when non-empty.
+func Metrics(token string) echo.HandlerFunc {
+	serve := echo.WrapHandler(metrics.Handler())
+	return func(c echo.Context) error {
+		if token != "" && !hasBearerToken(c, token) {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid metrics token"})
+		}
+		return serve(c)
+	}
+}
+
+// hasBearerToken reports whether the request carries token as its bearer
+// token.
+func hasBearerToken(c echo.Context, token string) bool {
+	got, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"idento/backend/internal/config"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRegisterMetricsRoute(t *testing.T) {
+	cases := []struct {
+		name, mode, token, auth string
+		want                    int
+	}{
+		{"saas without token is not served", config.ModeSaaS, "", "", http.StatusNotFound},
+		{"saas with token rejects anonymous", config.ModeSaaS, "scrape", "", http.StatusUnauthorized},
+		{"saas with token rejects a wrong token", config.ModeSaaS, "scrape", "Bearer nope", http.StatusUnauthorized},
+		{"saas with token", config.ModeSaaS, "scrape", "Bearer scrape", http.StatusOK},
+		{"onprem without token is open", config.ModeOnPrem, "", "", http.StatusOK},
+		{"onprem with token rejects anonymous", config.ModeOnPrem, "scrape", "", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			mounted := RegisterMetricsRoute(e, tc.mode, tc.token)
+			if mounted != (tc.mode == config.ModeOnPrem || tc.token != "") {
+				t.Errorf("mounted = %v", mounted)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tc.auth != "" {
+				req.Header.Set(echo.HeaderAuthorization, tc.auth)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tc.want, rec.Body.String())
+			}
+			if tc.want == http.StatusOK && !strings.Contains(rec.Body.String(), "idento_http_requests_in_flight") {
+				t.Errorf("exposition is missing idento metrics:\n%s", rec.Body.String())
+			}
+		})
+	}
+}
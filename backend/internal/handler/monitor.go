@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -76,11 +77,20 @@ func (h *Handler) GetEventMonitor(c echo.Context) error {
 		return writeErr(c, err)
 	}
 
-	ctx := c.Request().Context()
+	snapshot, err := h.buildMonitorSnapshot(c.Request().Context(), eventID)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	return c.JSON(http.StatusOK, snapshot)
+}
 
+// buildMonitorSnapshot runs the queries behind one MonitorSnapshot —
+// GetEventMonitor's whole body, shared with the delta stream's feed
+// (monitor_feed.go). Errors are *httpError 500s naming the failed query.
+func (h *Handler) buildMonitorSnapshot(ctx context.Context, eventID uuid.UUID) (*MonitorSnapshot, error) {
 	total, checkedIn, zoneCounts, unattributed, err := h.Store.GetMonitorOverview(ctx, eventID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch monitor overview"})
+		return nil, newHTTPError(http.StatusInternalServerError, "Failed to fetch monitor overview")
 	}
 
 	// dayStart is UTC start-of-day: the domain for "today's" peak bucket
@@ -91,22 +101,22 @@ func (h *Handler) GetEventMonitor(c echo.Context) error {
 	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
 	buckets, err := h.Store.GetMonitorMinuteBuckets(ctx, eventID, dayStart)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch monitor rate buckets"})
+		return nil, newHTTPError(http.StatusInternalServerError, "Failed to fetch monitor rate buckets")
 	}
 
 	recentCount, err := h.Store.CountRecentCheckins(ctx, eventID, now.Add(-rateWindow))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch monitor recent check-in count"})
+		return nil, newHTTPError(http.StatusInternalServerError, "Failed to fetch monitor recent check-in count")
 	}
 
 	stations, err := h.Store.GetMonitorStations(ctx, eventID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch monitor stations"})
+		return nil, newHTTPError(http.StatusInternalServerError, "Failed to fetch monitor stations")
 	}
 
 	recent, err := h.Store.GetCheckinActions(ctx, eventID, monitorRecentLimit)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch recent check-in actions"})
+		return nil, newHTTPError(http.StatusInternalServerError, "Failed to fetch recent check-in actions")
 	}
 	if recent == nil {
 		recent = []store.CheckinActionRow{}
@@ -130,7 +140,7 @@ func (h *Handler) GetEventMonitor(c echo.Context) error {
 		})
 	}
 
-	return c.JSON(http.StatusOK, MonitorSnapshot{
+	return &MonitorSnapshot{
 		Totals: MonitorTotals{
 			CheckedIn:  checkedIn,
 			Total:      total,
@@ -142,5 +152,5 @@ func (h *Handler) GetEventMonitor(c echo.Context) error {
 		Unattributed: unattributed,
 		Stations:     stationRows,
 		Recent:       recent,
-	})
+	}, nil
 }
@@ -0,0 +1,430 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"idento/backend/internal/broker"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+)
+
+// monitorFeedHistory is how many frames a feed keeps for Last-Event-ID
+// resume; a stream further behind than that gets a snapshot instead.
+const monitorFeedHistory = 256
+
+// monitorSnapshotInterval is how often a feed re-reads the full snapshot.
+// Patches only move counts, the station list and the recent feed; the
+// rate, peak and estimate — and anything a patch got wrong — catch up
+// here. monitorFeedLinger is how long a feed outlives its last stream, so
+// a monitor that reconnects within it resumes instead of starting over.
+// Package vars so monitor_feed_test.go can shrink them.
+var (
+	monitorSnapshotInterval = 30 * time.Second
+	monitorFeedLinger       = 30 * time.Second
+)
+
+// MonitorPatch is a delta monitor stream's "patch" frame: what one broker
+// message changed, as the new values of only the parts it touched. Values
+// are absolute, not increments, so applying a patch to a snapshot that
+// already includes it is harmless. Recent rows are upserted by id into
+// the snapshot's recent list, which stays newest-first and capped at 20.
+type MonitorPatch struct {
+	Kind         broker.Kind              `json:"kind"`
+	At           time.Time                `json:"at"`
+	AttendeeID   *uuid.UUID               `json:"attendee_id,omitempty"`
+	StationID    *uuid.UUID               `json:"station_id,omitempty"`
+	CheckedIn    *int                     `json:"checked_in,omitempty"`
+	Zone         *MonitorZone             `json:"zone,omitempty"`
+	Unattributed *int                     `json:"unattributed,omitempty"`
+	Station      *MonitorStationRow       `json:"station,omitempty"`
+	Recent       []store.CheckinActionRow `json:"recent,omitempty"`
+}
+
+// monitorFrame is one delta-stream frame, already encoded.
+type monitorFrame struct {
+	seq   uint64
+	event string
+	data  []byte
+}
+
+// monitorFeeds holds this replica's feeds, one per watched event. Its zero
+// value is ready to use, so `&Handler{Store: fs}` test literals stay valid.
+type monitorFeeds struct {
+	mu    sync.Mutex
+	feeds map[uuid.UUID]*monitorFeed
+}
+
+// monitorFeed turns one event's broker messages into delta-stream frames
+// once for every stream on this replica: it keeps the event's
+// MonitorSnapshot in memory, patches it per message, and re-reads it only
+// when a message can't be applied (an undo, a generic change, a lost
+// backlog) or monitorSnapshotInterval elapses. Streams then cost no
+// queries of their own — they replay the frames.
+type monitorFeed struct {
+	eventID uuid.UUID
+	// epoch prefixes every frame ID, so a Last-Event-ID from another
+	// replica or an earlier feed never matches this feed's history.
+	epoch  string
+	cancel context.CancelFunc
+	ready  chan struct{}
+	err    error // set before ready closes
+
+	// refs and linger are guarded by monitorFeeds.mu.
+	refs   int
+	linger *time.Timer
+
+	mu    sync.Mutex
+	seq   uint64
+	state *MonitorSnapshot
+	// readAt is when state was last re-read; messages stamped earlier
+	// are already part of it.
+	readAt time.Time
+	frames []monitorFrame
+	wake   chan struct{}
+}
+
+// joinMonitorFeed attaches a stream to eventID's feed, starting the feed
+// if this is its first stream, and waits for the feed's first snapshot.
+// The returned leave func must be called once the stream ends.
+func (h *Handler) joinMonitorFeed(ctx context.Context, eventID uuid.UUID) (*monitorFeed, func(), error) {
+	fs := &h.monitorFeeds
+	fs.mu.Lock()
+	if fs.feeds == nil {
+		fs.feeds = make(map[uuid.UUID]*monitorFeed)
+	}
+	f := fs.feeds[eventID]
+	if f == nil {
+		feedCtx, cancel := context.WithCancel(context.Background())
+		f = &monitorFeed{
+			eventID: eventID,
+			epoch:   uuid.NewString()[:8],
+			cancel:  cancel,
+			ready:   make(chan struct{}),
+			wake:    make(chan struct{}),
+		}
+		fs.feeds[eventID] = f
+		go h.runMonitorFeed(feedCtx, f)
+	}
+	f.refs++
+	if f.linger != nil {
+		f.linger.Stop()
+		f.linger = nil
+	}
+	fs.mu.Unlock()
+
+	var once sync.Once
+	leave := func() { once.Do(func() { fs.release(f) }) }
+
+	select {
+	case <-f.ready:
+	case <-ctx.Done():
+		leave()
+		return nil, nil, ctx.Err()
+	}
+	if f.err != nil {
+		leave()
+		return nil, nil, f.err
+	}
+	return f, leave, nil
+}
+
+// release drops a stream's reference, stopping the feed monitorFeedLinger
+// after its last stream leaves unless another joins in between.
+func (fs *monitorFeeds) release(f *monitorFeed) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f.refs--
+	if f.refs > 0 || fs.feeds[f.eventID] != f {
+		return
+	}
+	f.linger = time.AfterFunc(monitorFeedLinger, func() {
+		fs.mu.Lock()
+		defer fs.mu.Unlock()
+		if f.refs == 0 && fs.feeds[f.eventID] == f {
+			fs.remove(f)
+		}
+	})
+}
+
+// remove stops f and forgets it; fs.mu must be held.
+func (fs *monitorFeeds) remove(f *monitorFeed) {
+	if fs.feeds[f.eventID] == f {
+		delete(fs.feeds, f.eventID)
+	}
+	f.cancel()
+}
+
+// runMonitorFeed is f's goroutine. It subscribes before the first read,
+// so no message published after that read can be missed, and exits when
+// its last stream is gone or on shutdown (DrainStreams).
+func (h *Handler) runMonitorFeed(ctx context.Context, f *monitorFeed) {
+	defer func() {
+		h.monitorFeeds.mu.Lock()
+		h.monitorFeeds.remove(f)
+		h.monitorFeeds.mu.Unlock()
+	}()
+
+	sub, unsubscribe := h.Broker.SubscribeMessages(f.eventID)
+	defer unsubscribe()
+
+	if err := f.reread(ctx, h); err != nil {
+		f.err = err
+		close(f.ready)
+		return
+	}
+	close(f.ready)
+
+	ticker := time.NewTicker(monitorSnapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.drain.done():
+			return
+		case <-ticker.C:
+			f.rereadOrLog(ctx, h)
+		case <-sub.C:
+			msgs, lost := sub.Drain()
+			if lost {
+				f.rereadOrLog(ctx, h)
+				continue
+			}
+			f.apply(ctx, h, msgs)
+		}
+	}
+}
+
+// reread replaces f's state with a fresh snapshot and appends it as a
+// "snapshot" frame.
+func (f *monitorFeed) reread(ctx context.Context, h *Handler) error {
+	readAt := time.Now().UTC()
+	snapshot, err := h.buildMonitorSnapshot(ctx, f.eventID)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state, f.readAt = snapshot, readAt
+	f.appendLocked("snapshot", snapshot)
+	return nil
+}
+
+// rereadOrLog is reread past the first snapshot: a failed re-read keeps
+// the state streams already have, and the next tick tries again.
+func (f *monitorFeed) rereadOrLog(ctx context.Context, h *Handler) {
+	if err := f.reread(ctx, h); err != nil && ctx.Err() == nil {
+		log.Printf("monitor feed %s: re-read snapshot: %v", f.eventID, err)
+	}
+}
+
+// apply patches f's state with msgs in order, falling back to one re-read
+// (which covers every message still unapplied) at the first message a
+// patch can't express.
+func (f *monitorFeed) apply(ctx context.Context, h *Handler, msgs []broker.Message) {
+	// One read of the newest actions serves every check-in and reprint in
+	// the batch with its recent-feed row.
+	actions := 0
+	for _, msg := range msgs {
+		if msg.Kind == broker.KindCheckin || msg.Kind == broker.KindReprint {
+			actions++
+		}
+	}
+	var rows []store.CheckinActionRow
+	if actions > 0 {
+		var err error
+		rows, err = h.Store.GetCheckinActions(ctx, f.eventID, min(actions, monitorRecentLimit))
+		if err != nil {
+			f.rereadOrLog(ctx, h)
+			return
+		}
+	}
+
+	f.mu.Lock()
+	unapplied := false
+	for _, msg := range msgs {
+		if !msg.At.IsZero() && msg.At.Before(f.readAt) {
+			continue
+		}
+		patch, ok := f.patchLocked(msg, rows)
+		if !ok {
+			unapplied = true
+			break
+		}
+		if patch != nil {
+			f.appendLocked("patch", patch)
+		}
+	}
+	f.mu.Unlock()
+	if unapplied {
+		f.rereadOrLog(ctx, h)
+	}
+}
+
+// patchLocked applies msg to f.state and returns the patch describing it:
+// a nil patch for a message that changed nothing visible, ok=false for one
+// a patch can't express. f.mu must be held.
+func (f *monitorFeed) patchLocked(msg broker.Message, rows []store.CheckinActionRow) (*MonitorPatch, bool) {
+	st := f.state
+	patch := &MonitorPatch{Kind: msg.Kind, At: msg.At, AttendeeID: msg.AttendeeID, StationID: msg.StationID}
+
+	var station *MonitorStationRow
+	if msg.StationID != nil {
+		for i := range st.Stations {
+			if st.Stations[i].ID == *msg.StationID {
+				station = &st.Stations[i]
+			}
+		}
+		if station == nil {
+			// A station registered since the last read.
+			return nil, false
+		}
+	}
+
+	switch msg.Kind {
+	case broker.KindCheckin:
+		if msg.AttendeeID == nil {
+			return nil, false
+		}
+		// Attribution mirrors GetMonitorOverview: the check-in's station's
+		// zone, else unattributed.
+		if msg.ZoneID != nil {
+			var zone *MonitorZone
+			for i := range st.Zones {
+				if st.Zones[i].ZoneID == *msg.ZoneID {
+					zone = &st.Zones[i]
+				}
+			}
+			if zone == nil {
+				return nil, false
+			}
+			zone.CheckedIn++
+			z := *zone
+			patch.Zone = &z
+		} else {
+			st.Unattributed++
+			u := st.Unattributed
+			patch.Unattributed = &u
+		}
+		st.Totals.CheckedIn++
+		checkedIn := st.Totals.CheckedIn
+		patch.CheckedIn = &checkedIn
+		if station != nil {
+			station.CheckinCount++
+			s := *station
+			patch.Station = &s
+		}
+		patch.Recent = f.recentLocked(*msg.AttendeeID, "checkin", rows)
+		return patch, true
+
+	case broker.KindReprint:
+		if msg.AttendeeID == nil {
+			return nil, false
+		}
+		patch.Recent = f.recentLocked(*msg.AttendeeID, "reprint", rows)
+		if patch.Recent == nil {
+			return nil, true
+		}
+		return patch, true
+
+	case broker.KindHeartbeat:
+		if station == nil {
+			return nil, false
+		}
+		station.LastSeenAt = msg.At
+		s := *station
+		patch.Station = &s
+		return patch, true
+	}
+
+	// broker.KindChanged, KindUndo (which zone the cleared check-in
+	// counted towards isn't carried), and anything newer than this code.
+	return nil, false
+}
+
+// recentLocked finds attendeeID's newest action row in rows not yet in
+// the recent list, and upserts it there. f.mu must be held.
+func (f *monitorFeed) recentLocked(attendeeID uuid.UUID, action string, rows []store.CheckinActionRow) []store.CheckinActionRow {
+	st := f.state
+	for _, row := range rows {
+		if row.Attendee.ID != attendeeID || row.Action != action {
+			continue
+		}
+		for _, have := range st.Recent {
+			if have.ID == row.ID {
+				return nil
+			}
+		}
+		st.Recent = append(st.Recent, row)
+		sort.SliceStable(st.Recent, func(i, j int) bool { return st.Recent[i].CreatedAt.After(st.Recent[j].CreatedAt) })
+		if len(st.Recent) > monitorRecentLimit {
+			st.Recent = st.Recent[:monitorRecentLimit]
+		}
+		return []store.CheckinActionRow{row}
+	}
+	return nil
+}
+
+// appendLocked encodes v as the feed's next frame and wakes every stream
+// waiting on it. f.mu must be held.
+func (f *monitorFeed) appendLocked(event string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("monitor feed %s: encode %s frame: %v", f.eventID, event, err)
+		return
+	}
+	f.seq++
+	f.frames = append(f.frames, monitorFrame{seq: f.seq, event: event, data: data})
+	if len(f.frames) > monitorFeedHistory {
+		f.frames = f.frames[len(f.frames)-monitorFeedHistory:]
+	}
+	close(f.wake)
+	f.wake = make(chan struct{})
+}
+
+// next returns the frames a stream that has seen everything up to after
+// is missing — or, when it has seen nothing (resume false) or fell behind
+// the retained history, a snapshot of the current state — and a channel
+// closed once more frames exist.
+func (f *monitorFeed) next(after uint64, resume bool) ([]monitorFrame, <-chan struct{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if resume && after <= f.seq && (len(f.frames) == 0 || f.frames[0].seq <= after+1) {
+		i := sort.Search(len(f.frames), func(i int) bool { return f.frames[i].seq > after })
+		return f.frames[i:], f.wake
+	}
+	data, err := json.Marshal(f.state)
+	if err != nil {
+		log.Printf("monitor feed %s: encode snapshot frame: %v", f.eventID, err)
+		return nil, f.wake
+	}
+	return []monitorFrame{{seq: f.seq, event: "snapshot", data: data}}, f.wake
+}
+
+// frameID is a frame's SSE id: the feed's epoch and the frame's sequence.
+func (f *monitorFeed) frameID(seq uint64) string {
+	return fmt.Sprintf("%s-%d", f.epoch, seq)
+}
+
+// resumeFrom parses a Last-Event-ID header; ok is false unless it names a
+// frame of this feed.
+func (f *monitorFeed) resumeFrom(lastEventID string) (uint64, bool) {
+	epoch, seq, found := strings.Cut(lastEventID, "-")
+	if !found || epoch != f.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
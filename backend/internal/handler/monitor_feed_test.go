@@ -0,0 +1,279 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"idento/backend/internal/broker"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// deltaMonitorStore is a fakeStore whose monitor queries serve one fixed
+// event: 3 of 10 checked in, two in zone "Hall" and one unattributed, one
+// station in Hall. It counts overview reads (one per snapshot) and
+// small action reads (one per patched batch), and serves whatever action
+// rows the test has added.
+type deltaMonitorStore struct {
+	event            *models.Event
+	zoneID, station  uuid.UUID
+	mu               sync.Mutex
+	overviewReads    int
+	patchActionReads int
+	actions          []store.CheckinActionRow
+}
+
+func newDeltaMonitorStore(tenantID uuid.UUID) *deltaMonitorStore {
+	return &deltaMonitorStore{event: contractEvent(tenantID, "Tech Summit"), zoneID: uuid.New(), station: uuid.New()}
+}
+
+func (d *deltaMonitorStore) fake() *fakeStore {
+	return &fakeStore{
+		getEventByID: func(uuid.UUID) (*models.Event, error) { return d.event, nil },
+		getMonitorOverview: func(uuid.UUID) (int, int, []store.MonitorZoneCount, int, error) {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			d.overviewReads++
+			return 10, 3, []store.MonitorZoneCount{{ZoneID: d.zoneID, Name: "Hall", CheckedIn: 2}}, 1, nil
+		},
+		getMonitorMinuteBuckets: func(uuid.UUID, time.Time) ([]store.MinuteBucket, error) { return nil, nil },
+		countRecentCheckins:     func(uuid.UUID, time.Time) (int, error) { return 0, nil },
+		getMonitorStations: func(uuid.UUID) ([]store.MonitorStation, error) {
+			return []store.MonitorStation{{ID: d.station, Name: "Door", ZoneID: &d.zoneID, LastSeenAt: time.Now().UTC(), CheckinCount: 2}}, nil
+		},
+		getCheckinActions: func(_ uuid.UUID, limit int) ([]store.CheckinActionRow, error) {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			if limit < monitorRecentLimit {
+				d.patchActionReads++
+			}
+			return append([]store.CheckinActionRow(nil), d.actions...), nil
+		},
+	}
+}
+
+func (d *deltaMonitorStore) addAction(row store.CheckinActionRow) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.actions = append([]store.CheckinActionRow{row}, d.actions...)
+}
+
+func (d *deltaMonitorStore) reads() (overview, patchActions int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.overviewReads, d.patchActionReads
+}
+
+// openDeltaStream GETs the stream in delta mode, optionally resuming.
+func openDeltaStream(t *testing.T, url, lastEventID string) (*http.Response, *bufio.Reader) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url+"?mode=delta", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET stream: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	return resp, bufio.NewReader(resp.Body)
+}
+
+type deltaFrame struct {
+	id, event string
+	data      []byte
+}
+
+func readDeltaFrame(t *testing.T, r *bufio.Reader) deltaFrame {
+	t.Helper()
+	var f deltaFrame
+	for _, line := range strings.Split(strings.TrimSuffix(readSSEFrame(t, r, 2*time.Second), "\n\n"), "\n") {
+		key, value, _ := strings.Cut(line, ": ")
+		switch key {
+		case "id":
+			f.id = value
+		case "event":
+			f.event = value
+		case "data":
+			f.data = []byte(value)
+		}
+	}
+	return f
+}
+
+func readPatch(t *testing.T, r *bufio.Reader) (deltaFrame, MonitorPatch) {
+	t.Helper()
+	f := readDeltaFrame(t, r)
+	if f.event != "patch" {
+		t.Fatalf("frame = %s %s, want a patch", f.event, f.data)
+	}
+	var p MonitorPatch
+	if err := json.Unmarshal(f.data, &p); err != nil {
+		t.Fatalf("patch data %s: %v", f.data, err)
+	}
+	return f, p
+}
+
+func newDeltaMonitorHandler(t *testing.T, d *deltaMonitorStore) (*Handler, *broker.MemBroker) {
+	t.Helper()
+	mem := broker.NewMemBroker()
+	h := New(d.fake())
+	h.Broker = mem
+	t.Cleanup(h.DrainStreams) // stops the lingering feed
+	return h, mem
+}
+
+func TestMonitorDeltaStream_SnapshotThenPatches(t *testing.T) {
+	tenantID := uuid.New()
+	d := newDeltaMonitorStore(tenantID)
+	h, mem := newDeltaMonitorHandler(t, d)
+	srv, _ := newMonitorStreamTestServer(t, h, d.event.ID, tenantID)
+
+	resp, r := openDeltaStream(t, srv.URL, "")
+	defer resp.Body.Close()
+
+	first := readDeltaFrame(t, r)
+	var snap MonitorSnapshot
+	if first.event != "snapshot" || first.id == "" || json.Unmarshal(first.data, &snap) != nil {
+		t.Fatalf("first frame = %+v, want an id-tagged snapshot", first)
+	}
+	if snap.Totals.CheckedIn != 3 || len(snap.Zones) != 1 || snap.Unattributed != 1 {
+		t.Fatalf("snapshot = %+v", snap)
+	}
+
+	attendee := uuid.New()
+	row := store.CheckinActionRow{ID: uuid.New(), Action: "checkin", StationID: &d.station, CreatedAt: time.Now().UTC(), Attendee: store.CheckinActionAttendee{ID: attendee, FirstName: "Ada"}}
+	d.addAction(row)
+	at := time.Now().UTC()
+	if err := mem.Publish(context.Background(), broker.Message{Key: d.event.ID, Kind: broker.KindCheckin, AttendeeID: &attendee, StationID: &d.station, ZoneID: &d.zoneID, At: at}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	frame, p := readPatch(t, r)
+	if frame.id == first.id {
+		t.Errorf("patch reuses the snapshot's id %q", frame.id)
+	}
+	if p.Kind != broker.KindCheckin || p.CheckedIn == nil || *p.CheckedIn != 4 {
+		t.Errorf("patch = %+v, want checked_in 4", p)
+	}
+	if p.Zone == nil || p.Zone.ZoneID != d.zoneID || p.Zone.CheckedIn != 3 || p.Unattributed != nil {
+		t.Errorf("patch zone = %+v, unattributed = %v; want Hall at 3", p.Zone, p.Unattributed)
+	}
+	if p.Station == nil || p.Station.CheckinCount != 3 {
+		t.Errorf("patch station = %+v, want checkin_count 3", p.Station)
+	}
+	if len(p.Recent) != 1 || p.Recent[0].ID != row.ID {
+		t.Errorf("patch recent = %+v, want the check-in's row", p.Recent)
+	}
+
+	seen := time.Now().UTC().Add(time.Minute).Truncate(time.Second)
+	if err := mem.Publish(context.Background(), broker.Message{Key: d.event.ID, Kind: broker.KindHeartbeat, StationID: &d.station, At: seen}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if _, p := readPatch(t, r); p.Station == nil || !p.Station.LastSeenAt.Equal(seen) || p.CheckedIn != nil {
+		t.Errorf("heartbeat patch = %+v, want only the station's last_seen_at", p)
+	}
+
+	// An undo can't be patched (its zone isn't known): a fresh snapshot.
+	if err := mem.Publish(context.Background(), broker.Message{Key: d.event.ID, Kind: broker.KindUndo, AttendeeID: &attendee, At: time.Now().UTC()}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if f := readDeltaFrame(t, r); f.event != "snapshot" {
+		t.Errorf("frame after undo = %s %s, want a snapshot", f.event, f.data)
+	}
+}
+
+// Every stream of an event on a replica shares one feed: a check-in costs
+// one small action read, however many monitors are watching.
+func TestMonitorDeltaStream_StreamsShareOneFeed(t *testing.T) {
+	tenantID := uuid.New()
+	d := newDeltaMonitorStore(tenantID)
+	h, mem := newDeltaMonitorHandler(t, d)
+	srv, _ := newMonitorStreamTestServer(t, h, d.event.ID, tenantID)
+
+	var readers []*bufio.Reader
+	for i := 0; i < 3; i++ {
+		resp, r := openDeltaStream(t, srv.URL, "")
+		defer resp.Body.Close()
+		readDeltaFrame(t, r) // snapshot
+		readers = append(readers, r)
+	}
+
+	attendee := uuid.New()
+	d.addAction(store.CheckinActionRow{ID: uuid.New(), Action: "checkin", CreatedAt: time.Now().UTC(), Attendee: store.CheckinActionAttendee{ID: attendee}})
+	if err := mem.Publish(context.Background(), broker.Message{Key: d.event.ID, Kind: broker.KindCheckin, AttendeeID: &attendee, At: time.Now().UTC()}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	for _, r := range readers {
+		if _, p := readPatch(t, r); p.Unattributed == nil || *p.Unattributed != 2 {
+			t.Errorf("patch = %+v, want unattributed 2", p)
+		}
+	}
+	if overview, actions := d.reads(); overview != 1 || actions != 1 {
+		t.Errorf("reads: %d snapshots, %d action reads; want 1 and 1 for three streams", overview, actions)
+	}
+}
+
+func TestMonitorDeltaStream_ResumesFromLastEventID(t *testing.T) {
+	tenantID := uuid.New()
+	d := newDeltaMonitorStore(tenantID)
+	h, mem := newDeltaMonitorHandler(t, d)
+	srv, done := newMonitorStreamTestServer(t, h, d.event.ID, tenantID)
+
+	resp, r := openDeltaStream(t, srv.URL, "")
+	last := readDeltaFrame(t, r).id
+	resp.Body.Close()
+	<-done
+
+	// Published while no stream is attached; the lingering feed keeps it.
+	seen := time.Now().UTC().Add(time.Minute).Truncate(time.Second)
+	if err := mem.Publish(context.Background(), broker.Message{Key: d.event.ID, Kind: broker.KindHeartbeat, StationID: &d.station, At: seen}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	resumed, rr := openDeltaStream(t, srv.URL, last)
+	defer resumed.Body.Close()
+	if _, p := readPatch(t, rr); p.Kind != broker.KindHeartbeat || !p.Station.LastSeenAt.Equal(seen) {
+		t.Errorf("first resumed frame = %+v, want the missed heartbeat patch", p)
+	}
+
+	// An ID this feed never issued (another replica's) starts over.
+	other, ro := openDeltaStream(t, srv.URL, "0000abcd-7")
+	defer other.Body.Close()
+	if f := readDeltaFrame(t, ro); f.event != "snapshot" {
+		t.Errorf("frame for a foreign Last-Event-ID = %s, want a snapshot", f.event)
+	}
+}
+
+func TestOpenAPIContract_GetEventMonitorStream_InvalidMode400(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	h := New(&fakeStore{getEventByID: func(uuid.UUID) (*models.Event, error) { return event, nil }})
+	h.Broker = broker.NewMemBroker()
+
+	e := echo.New()
+	path := monitorStreamPath(event.ID) + "?mode=full"
+	c, rec := newAuthedContext(e, http.MethodGet, path, "", tenantID.String(), "staff")
+	setMonitorStreamPathParams(c, event.ID)
+
+	if err := h.GetEventMonitorStream(c); err != nil {
+		t.Fatalf("GetEventMonitorStream: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("want 400, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodGet, path, rec)
+}
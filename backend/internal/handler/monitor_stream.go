@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"time"
 
+	"idento/backend/internal/metrics"
+
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
@@ -21,7 +23,9 @@ var monitorStreamPingInterval = 25 * time.Second
 // (P4.2 Task 4, spec §3.3) — the codebase's first Server-Sent Events
 // endpoint. It is a deliberately "thin-ping" stream: frames carry no
 // monitor state themselves, only a signal telling the client to re-fetch
-// Task 3's GET .../monitor snapshot. Order matters: requireEventOwnership
+// Task 3's GET .../monitor snapshot — unless the client asks for
+// mode=delta (streamMonitorDeltas), which carries the state itself. Order
+// matters: requireEventOwnership
 // AND the nil-Broker check both run BEFORE any stream header is written,
 // so a foreign/missing event still gets a plain 404 JSON body, and a
 // misconfigured deployment (no Broker wired) gets a plain 503 — never a
@@ -31,7 +35,8 @@ var monitorStreamPingInterval = 25 * time.Second
 // The handler blocks for the lifetime of the connection — that's the
 // correct shape for a streaming handler, not a goroutine leak: it returns
 // (unsubscribing on the way out via the deferred call) the moment the
-// request context is cancelled, i.e. the client disconnects.
+// request context is cancelled, i.e. the client disconnects, or with a
+// reconnect frame once the server starts shutting down (DrainStreams).
 func (h *Handler) GetEventMonitorStream(c echo.Context) error {
 	eventID, err := uuid.Parse(c.Param("event_id"))
 	if err != nil {
@@ -51,12 +56,19 @@ func (h *Handler) GetEventMonitorStream(c echo.Context) error {
 	if h.Broker == nil {
 		return writeErr(c, newHTTPError(http.StatusServiceUnavailable, "Live monitor stream unavailable: no event broker configured"))
 	}
+	if err := h.refuseStreamWhileDraining(c); err != nil {
+		return writeErr(c, err)
+	}
+	switch c.QueryParam("mode") {
+	case "":
+	case "delta":
+		return h.streamMonitorDeltas(c, eventID)
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid mode"})
+	}
 
 	res := c.Response()
-	res.Header().Set(echo.HeaderContentType, "text/event-stream")
-	res.Header().Set("Cache-Control", "no-cache")
-	res.Header().Set("Connection", "keep-alive")
-	res.WriteHeader(http.StatusOK)
+	writeSSEHeaders(res)
 
 	// Subscribe BEFORE writing the hello frame: that ordering guarantees
 	// that by the time a client has observed hello, the subscription is
@@ -67,6 +79,9 @@ func (h *Handler) GetEventMonitorStream(c echo.Context) error {
 	// does. h.Broker is guaranteed non-nil past the check above.
 	ch, unsubscribe := h.Broker.Subscribe(eventID)
 	defer unsubscribe()
+	streams := metrics.SSEStreams.WithLabelValues("monitor")
+	streams.Inc()
+	defer streams.Dec()
 
 	if !writeSSEFrame(res, "event: hello\ndata: {}\n\n") {
 		return nil
@@ -79,8 +94,13 @@ func (h *Handler) GetEventMonitorStream(c echo.Context) error {
 	for {
 		select {
 		case <-ctx.Done():
-			// Client disconnected (or the server is shutting down): clean
-			// return, deferred unsubscribe/ticker.Stop() run on the way out.
+			// Client disconnected: clean return, deferred
+			// unsubscribe/ticker.Stop() run on the way out.
+			return nil
+		case <-h.drain.done():
+			// Server shutting down: hint the client to reconnect, which
+			// the load balancer sends to a replica that is staying up.
+			writeReconnectFrame(res)
 			return nil
 		case <-ch:
 			frame := fmt.Sprintf("event: update\ndata: {\"at\":%q}\n\n", time.Now().UTC().Format(time.RFC3339))
@@ -95,6 +115,71 @@ func (h *Handler) GetEventMonitorStream(c echo.Context) error {
 	}
 }
 
+// streamMonitorDeltas serves GetEventMonitorStream's mode=delta: the
+// monitor state itself, as an id-tagged "snapshot" frame followed by
+// "patch" frames (MonitorPatch), with a fresh snapshot whenever a change
+// can't be expressed as a patch and every monitorSnapshotInterval. All of
+// it comes from this replica's feed for the event (monitor_feed.go), so a
+// check-in costs one small query per replica, not a snapshot per stream.
+// A reconnecting client that sends Last-Event-ID gets only the frames it
+// missed while the feed still has them, and a snapshot otherwise.
+func (h *Handler) streamMonitorDeltas(c echo.Context, eventID uuid.UUID) error {
+	ctx := c.Request().Context()
+	feed, leave, err := h.joinMonitorFeed(ctx, eventID)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return writeErr(c, err)
+	}
+	defer leave()
+
+	res := c.Response()
+	writeSSEHeaders(res)
+	streams := metrics.SSEStreams.WithLabelValues("monitor")
+	streams.Inc()
+	defer streams.Dec()
+
+	cursor, resume := feed.resumeFrom(c.Request().Header.Get("Last-Event-ID"))
+	frames, wake := feed.next(cursor, resume)
+
+	ticker := time.NewTicker(monitorStreamPingInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, f := range frames {
+			frame := fmt.Sprintf("id: %s\nevent: %s\ndata: %s\n\n", feed.frameID(f.seq), f.event, f.data)
+			if !writeSSEFrame(res, frame) {
+				return nil
+			}
+			cursor, resume = f.seq, true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-h.drain.done():
+			writeReconnectFrame(res)
+			return nil
+		case <-wake:
+			frames, wake = feed.next(cursor, resume)
+		case <-ticker.C:
+			frames = nil
+			if !writeSSEFrame(res, ": ping\n\n") {
+				return nil
+			}
+		}
+	}
+}
+
+// writeSSEHeaders starts an event-stream response.
+func writeSSEHeaders(res *echo.Response) {
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.WriteHeader(http.StatusOK)
+}
+
 // writeSSEFrame writes one SSE frame and immediately flushes it (every
 // frame must be individually flushed — the whole point of a thin-ping
 // stream is that the client sees each signal the moment it's published,
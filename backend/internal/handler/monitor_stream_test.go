@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -42,9 +43,12 @@ import (
 func newMonitorStreamTestServer(t *testing.T, h *Handler, eventID, tenantID uuid.UUID) (*httptest.Server, <-chan struct{}) {
 	t.Helper()
 	e := echo.New()
+	// done closes when the first stream ends; a test that reconnects
+	// (Last-Event-ID resume) serves more than one request.
 	done := make(chan struct{})
+	var once sync.Once
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		defer close(done)
+		defer once.Do(func() { close(done) })
 		c := e.NewContext(r, w)
 		c.SetParamNames("event_id")
 		c.SetParamValues(eventID.String())
@@ -162,7 +166,7 @@ func TestGetEventMonitorStream_PublishTriggersUpdateFrame(t *testing.T) {
 	r := bufio.NewReader(resp.Body)
 	_ = readSSEFrame(t, r, 2*time.Second) // hello
 
-	if err := mem.Publish(context.Background(), event.ID); err != nil {
+	if err := mem.Publish(context.Background(), broker.Changed(event.ID)); err != nil {
 		t.Fatalf("Publish: %v", err)
 	}
 
@@ -287,7 +291,7 @@ func TestGetEventMonitorStream_ClientDisconnectUnsubscribesCleanly(t *testing.T)
 		t.Fatal("handler did not return after client disconnect (goroutine leak / stuck select)")
 	}
 
-	if err := mem.Publish(context.Background(), event.ID); err != nil {
+	if err := mem.Publish(context.Background(), broker.Changed(event.ID)); err != nil {
 		t.Fatalf("Publish after disconnect: %v", err)
 	}
 }
@@ -356,3 +360,63 @@ func TestOpenAPIContract_GetEventMonitorStream_CoverageException(t *testing.T) {
 	coverage["GET /api/events/{event_id}/monitor/stream"] = true
 	coverageMu.Unlock()
 }
+
+// TestGetEventMonitorStream_DrainSendsReconnectFrame proves shutdown ends
+// an open stream with the reconnect hint rather than cutting it mid-frame,
+// and that the handler returns so http.Server.Shutdown is not held up.
+func TestGetEventMonitorStream_DrainSendsReconnectFrame(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	h := New(&fakeStore{getEventByID: func(uuid.UUID) (*models.Event, error) { return event, nil }})
+	h.Broker = broker.NewMemBroker()
+
+	srv, done := newMonitorStreamTestServer(t, h, event.ID, tenantID)
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	r := bufio.NewReader(resp.Body)
+	_ = readSSEFrame(t, r, 2*time.Second) // hello
+
+	h.DrainStreams()
+	h.DrainStreams() // idempotent
+
+	if frame := readSSEFrame(t, r, 2*time.Second); frame != "retry: 2000\nevent: reconnect\ndata: {}\n\n" {
+		t.Fatalf("frame after drain = %q, want the reconnect frame", frame)
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler did not return after DrainStreams")
+	}
+}
+
+// TestGetEventMonitorStream_RefusedWhileDraining proves a stream opened
+// after DrainStreams gets a plain 503 with Retry-After, before any stream
+// header is written.
+func TestGetEventMonitorStream_RefusedWhileDraining(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	h := New(&fakeStore{getEventByID: func(uuid.UUID) (*models.Event, error) { return event, nil }})
+	h.Broker = broker.NewMemBroker()
+	h.DrainStreams()
+
+	e := echo.New()
+	path := monitorStreamPath(event.ID)
+	c, rec := newAuthedContext(e, http.MethodGet, path, "", tenantID.String(), "staff")
+	setMonitorStreamPathParams(c, event.ID)
+
+	if err := h.GetEventMonitorStream(c); err != nil {
+		t.Fatalf("GetEventMonitorStream: %v", err)
+	}
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("want 503, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	if ra := rec.Header().Get("Retry-After"); ra != "2" {
+		t.Errorf("Retry-After = %q, want 2", ra)
+	}
+	validateResponse(t, http.MethodGet, path, rec)
+}
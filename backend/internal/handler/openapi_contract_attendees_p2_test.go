@@ -657,11 +657,9 @@ func TestOpenAPIContract_UnassignStaffFromEvent(t *testing.T) {
 // non-admin/non-manager tenant member (e.g. plain "staff") cannot remove
 // another staff member's event assignment — mirrors
 // TestContractAssignStaffToEvent's role gate on the sibling endpoint.
-// Like AssignStaffToEvent's own role check, the 403 is returned as an
-// *echo.HTTPError rather than written via c.JSON, so the response is
-// rendered through e.HTTPErrorHandler (as Echo would in production) before
-// validating against the spec — pinning the HTTPError branch of the 403
-// oneOf ({"message": ...}, not the Error shape).
+// Staff assignment is zones:manage's: plain staff lack it, and so does a
+// manager whose custom role leaves it out, while a staff member whose
+// custom role grants it may unassign.
 func TestOpenAPIContract_UnassignStaffFromEvent_Forbidden(t *testing.T) {
 	tenantID := uuid.New()
 	event := contractEvent(tenantID, "Tech Summit")
@@ -672,36 +670,47 @@ func TestOpenAPIContract_UnassignStaffFromEvent_Forbidden(t *testing.T) {
 		TenantID: tenantID,
 	}
 
+	var custom *models.TenantRole
+	removed := 0
 	h := New(&fakeStore{
 		getEventByID: func(uuid.UUID) (*models.Event, error) { return event, nil },
 		removeStaffFromEvent: func(eventID, userID uuid.UUID) error {
-			t.Fatalf("RemoveStaffFromEvent should not be called when the caller lacks admin/manager role")
+			removed++
 			return nil
 		},
+		getMemberCustomRole: func(uuid.UUID, uuid.UUID) (*models.TenantRole, error) { return custom, nil },
 	})
 
 	e := echo.New()
 	path := "/api/events/" + event.ID.String() + "/staff/" + staffUser.ID.String()
-	c, rec := newAuthedContext(e, http.MethodDelete, path, "", tenantID.String(), "staff")
-	c.SetPath("/api/events/:event_id/staff/:user_id")
-	c.SetParamNames("event_id", "user_id")
-	c.SetParamValues(event.ID.String(), staffUser.ID.String())
+	call := func(role string) int {
+		t.Helper()
+		c, rec := newAuthedContext(e, http.MethodDelete, path, "", tenantID.String(), role)
+		c.SetPath("/api/events/:event_id/staff/:user_id")
+		c.SetParamNames("event_id", "user_id")
+		c.SetParamValues(event.ID.String(), staffUser.ID.String())
+		if err := h.UnassignStaffFromEvent(c); err != nil {
+			t.Fatalf("UnassignStaffFromEvent: %v", err)
+		}
+		validateResponse(t, http.MethodDelete, path, rec)
+		if rec.Code == http.StatusForbidden && rec.Body.String() != `{"error":"Permission denied: zones:manage"}`+"\n" {
+			t.Errorf("403 body = %s", rec.Body.String())
+		}
+		return rec.Code
+	}
 
-	err := h.UnassignStaffFromEvent(c)
-	httpErr, ok := err.(*echo.HTTPError)
-	if !ok {
-		t.Fatalf("expected *echo.HTTPError, got %v", err)
+	if code := call("staff"); code != http.StatusForbidden {
+		t.Fatalf("staff: want 403, got %d", code)
 	}
-	if httpErr.Code != http.StatusForbidden {
-		t.Fatalf("code = %d, want 403 (non-admin/manager caller)", httpErr.Code)
+	custom = &models.TenantRole{Permissions: []string{string(PermExport)}}
+	if code := call("manager"); code != http.StatusForbidden {
+		t.Fatalf("manager without zones:manage: want 403, got %d", code)
 	}
-	if httpErr.Message != "Access denied" {
-		t.Fatalf("message = %v, want %q (matches AssignStaffToEvent)", httpErr.Message, "Access denied")
+	if removed != 0 {
+		t.Fatalf("RemoveStaffFromEvent called %d times for callers without zones:manage", removed)
 	}
-
-	e.HTTPErrorHandler(err, c)
-	if rec.Code != http.StatusForbidden {
-		t.Fatalf("rendered code = %d, want 403, body=%s", rec.Code, rec.Body.String())
+	custom = &models.TenantRole{Permissions: []string{string(PermZonesManage)}}
+	if code := call("staff"); code != http.StatusNoContent || removed != 1 {
+		t.Fatalf("staff with zones:manage: want 204 and one removal, got %d and %d", code, removed)
 	}
-	validateResponse(t, http.MethodDelete, path, rec)
 }
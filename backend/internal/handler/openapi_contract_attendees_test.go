@@ -9,6 +9,7 @@ import (
 
 	"idento/backend/internal/middleware"
 	"idento/backend/internal/models"
+	"idento/backend/internal/store"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -300,14 +301,14 @@ func TestContractGenerateAttendeeCodes(t *testing.T) {
 	validateResponse(t, http.MethodPost, path, rec)
 }
 
-func TestContractExportAttendeesCSV(t *testing.T) {
+func TestContractExportAttendees(t *testing.T) {
 	tenantID := uuid.New()
 	event := contractEvent(tenantID, "Tech Summit")
 	attendee := contractAttendee(event.ID)
 	h := New(&fakeStore{
 		getEventByID: func(uuid.UUID) (*models.Event, error) { return event, nil },
-		getAttendeesByEventID: func(uuid.UUID, string, string) ([]*models.Attendee, error) {
-			return []*models.Attendee{attendee}, nil
+		streamAttendeeExport: func(_ uuid.UUID, _ store.AttendeeFilter, _ bool, fn func(*store.AttendeeExportRow) error) error {
+			return fn(&store.AttendeeExportRow{Attendee: attendee})
 		},
 	})
 	e := echo.New()
@@ -316,8 +317,8 @@ func TestContractExportAttendeesCSV(t *testing.T) {
 	c.SetPath("/api/events/:event_id/attendees/export")
 	c.SetParamNames("event_id")
 	c.SetParamValues(event.ID.String())
-	if err := h.ExportAttendeesCSV(c); err != nil {
-		t.Fatalf("ExportAttendeesCSV: %v", err)
+	if err := h.ExportAttendees(c); err != nil {
+		t.Fatalf("ExportAttendees: %v", err)
 	}
 	if ct := rec.Header().Get("Content-Type"); ct != "text/csv" {
 		t.Fatalf("want text/csv, got %s", ct)
@@ -338,3 +338,47 @@ func TestContractProvisionStation(t *testing.T) {
 	}
 	validateResponse(t, http.MethodPost, "/api/stations/provision", rec)
 }
+
+// TestContractCreateEventIdempotencyRejections drives middleware.Idempotency
+// (mounted ahead of CheckLimits on POST /api/events) into its two rejection
+// branches: a key still held by an in-flight request (409) and a key reused
+// for a different body (422). Both must match the documented Error shape.
+func TestContractCreateEventIdempotencyRejections(t *testing.T) {
+	tenantID := uuid.New()
+	next := func(c echo.Context) error {
+		t.Fatal("handler should not run for a rejected Idempotency-Key")
+		return nil
+	}
+	cases := []struct {
+		name     string
+		existing *models.IdempotencyRecord
+		want     int
+	}{
+		{name: "in flight", existing: &models.IdempotencyRecord{}, want: http.StatusConflict},
+		{name: "reused", existing: &models.IdempotencyRecord{RequestHash: "other"}, want: http.StatusUnprocessableEntity},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := &fakeStore{
+				claimIdempotencyKey: func(rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+					ex := *tc.existing
+					if ex.RequestHash == "" {
+						ex.RequestHash = rec.RequestHash
+					}
+					return &ex, nil
+				},
+			}
+			e := echo.New()
+			c, rec := newAuthedContext(e, http.MethodPost, "/api/events",
+				`{"name":"Tech Summit"}`, tenantID.String(), "admin")
+			c.Request().Header.Set(middleware.IdempotencyKeyHeader, "retry-1")
+			if err := middleware.Idempotency(fs)(next)(c); err != nil {
+				t.Fatalf("Idempotency: %v", err)
+			}
+			if rec.Code != tc.want {
+				t.Fatalf("want %d, got %d, body=%s", tc.want, rec.Code, rec.Body.String())
+			}
+			validateResponse(t, http.MethodPost, "/api/events", rec)
+		})
+	}
+}
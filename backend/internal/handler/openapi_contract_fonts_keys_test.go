@@ -2,6 +2,7 @@ package handler
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"mime/multipart"
 	"net/http"
@@ -864,6 +865,19 @@ func TestContractCreateAPIKey(t *testing.T) {
 	}
 	validateResponse(t, http.MethodPost, path, rec)
 
+	// 400: rate_limit_per_minute outside 1..MaxAPIKeyRateLimitPerMinute.
+	c, rec = newAuthedContext(e, http.MethodPost, path, `{"name":"Zapier integration","rate_limit_per_minute":0}`, tenantID.String(), "admin")
+	c.SetPath("/api/events/:event_id/api-keys")
+	c.SetParamNames("event_id")
+	c.SetParamValues(event.ID.String())
+	if err := h.CreateAPIKey(c); err != nil {
+		t.Fatalf("CreateAPIKey (zero rate limit): %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("want 400, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodPost, path, rec)
+
 	// 500: Store.CreateAPIKey itself fails.
 	hCreateFail := New(&fakeStore{
 		getEventByID: func(uuid.UUID) (*models.Event, error) { return event, nil },
@@ -1022,3 +1036,84 @@ func TestContractRevokeAPIKey(t *testing.T) {
 	}
 	validateResponse(t, http.MethodDelete, path, rec)
 }
+
+// TestContractGetAPIKeyUsage covers GET
+// /api/events/{event_id}/api-keys/{key_id}/usage, including the same
+// key-belongs-to-event guard RevokeAPIKey applies.
+func TestContractGetAPIKeyUsage(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	key := contractAPIKey(event.ID)
+	key.RateLimitPerMinute = 120
+	var gotFrom, gotTo time.Time
+	h := New(&fakeStore{
+		getEventByID:        func(uuid.UUID) (*models.Event, error) { return event, nil },
+		getAPIKeysByEventID: func(uuid.UUID) ([]*models.APIKey, error) { return []*models.APIKey{key}, nil },
+		getAPIKeyUsage: func(_ uuid.UUID, from, to time.Time) ([]models.APIKeyUsageDay, error) {
+			gotFrom, gotTo = from, to
+			return []models.APIKeyUsageDay{
+				{Date: "2026-10-16", Requests: 40, Throttled: 0},
+				{Date: "2026-10-17", Requests: 118, Throttled: 7},
+			}, nil
+		},
+	})
+	e := echo.New()
+	base := "/api/events/" + event.ID.String() + "/api-keys/" + key.ID.String() + "/usage"
+	do := func(h *Handler, url, eventParam, keyParam string) *httptest.ResponseRecorder {
+		t.Helper()
+		c, rec := newAuthedContext(e, http.MethodGet, url, "", tenantID.String(), "admin")
+		c.SetPath("/api/events/:event_id/api-keys/:key_id/usage")
+		c.SetParamNames("event_id", "key_id")
+		c.SetParamValues(eventParam, keyParam)
+		if err := h.GetAPIKeyUsage(c); err != nil {
+			t.Fatalf("GetAPIKeyUsage: %v", err)
+		}
+		validateResponse(t, http.MethodGet, url, rec)
+		return rec
+	}
+
+	rec := do(h, base+"?days=7", event.ID.String(), key.ID.String())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	var got models.APIKeyUsageResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.TotalRequests != 158 || got.TotalThrottled != 7 || got.RateLimitPerMinute != 120 {
+		t.Fatalf("totals = %d/%d limit %d, want 158/7 limit 120", got.TotalRequests, got.TotalThrottled, got.RateLimitPerMinute)
+	}
+	if span := gotTo.Sub(gotFrom); span < 6*24*time.Hour || span > 7*24*time.Hour {
+		t.Fatalf("window = %v, want between 6 and 7 days for ?days=7", span)
+	}
+
+	// 400: days out of range.
+	if rec := do(h, base+"?days=365", event.ID.String(), key.ID.String()); rec.Code != http.StatusBadRequest {
+		t.Fatalf("want 400 for days=365, got %d", rec.Code)
+	}
+
+	// 400: key_id is not a UUID.
+	badKey := "/api/events/" + event.ID.String() + "/api-keys/not-a-uuid/usage"
+	if rec := do(h, badKey, event.ID.String(), "not-a-uuid"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("want 400 for bad key id, got %d", rec.Code)
+	}
+
+	// 404: key belongs to some other event.
+	foreign := uuid.New()
+	foreignPath := "/api/events/" + event.ID.String() + "/api-keys/" + foreign.String() + "/usage"
+	if rec := do(h, foreignPath, event.ID.String(), foreign.String()); rec.Code != http.StatusNotFound {
+		t.Fatalf("want 404 for foreign key, got %d", rec.Code)
+	}
+
+	// 500: usage query fails.
+	hFail := New(&fakeStore{
+		getEventByID:        func(uuid.UUID) (*models.Event, error) { return event, nil },
+		getAPIKeysByEventID: func(uuid.UUID) ([]*models.APIKey, error) { return []*models.APIKey{key}, nil },
+		getAPIKeyUsage: func(uuid.UUID, time.Time, time.Time) ([]models.APIKeyUsageDay, error) {
+			return nil, errors.New("query failed")
+		},
+	})
+	if rec := do(hFail, base, event.ID.String(), key.ID.String()); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("want 500, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"idento/backend/internal/broker"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+)
+
+func TestContractImportAttendeesFile(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	mappingID := uuid.New()
+	var created []*models.Attendee
+	h := New(&fakeStore{
+		getEventByID:          func(uuid.UUID) (*models.Event, error) { return event, nil },
+		checkAttendeeLimit:    func(uuid.UUID, uuid.UUID, int) (bool, int, int, error) { return true, 0, 100, nil },
+		getAttendeesByEventID: func(uuid.UUID, string, string) ([]*models.Attendee, error) { return nil, nil },
+		createAttendee:        func(a *models.Attendee) error { created = append(created, a); return nil },
+		updateEvent:           func(*models.Event) error { return nil },
+		getImportMapping: func(tid, id uuid.UUID) (*models.ImportMapping, error) {
+			if tid != tenantID || id != mappingID {
+				return nil, nil
+			}
+			return &models.ImportMapping{ID: id, Columns: map[string]string{"Имя": "first_name", "Фамилия": "last_name", "Служебное": ""}}, nil
+		},
+	})
+	e := echo.New()
+	path := "/api/events/" + event.ID.String() + "/attendees/import"
+	csv := []byte("Имя;Фамилия;E-mail;Служебное;Должность в компании\nАда;Лавлейс;ada@example.com;x;Аналитик\n")
+	c, rec := newUploadContext(t, e, tenantID.String(), uuid.New(),
+		map[string]string{"mapping_id": mappingID.String()}, "file", "guests.csv", csv)
+	c.SetParamNames("event_id")
+	c.SetParamValues(event.ID.String())
+	if err := h.ImportAttendeesFile(c); err != nil {
+		t.Fatalf("ImportAttendeesFile: %v", err)
+	}
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("want 201, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodPost, path, rec)
+	if len(created) != 1 {
+		t.Fatalf("created %d attendees, want 1", len(created))
+	}
+	a := created[0]
+	if a.FirstName != "Ада" || a.LastName != "Лавлейс" || a.Email != "ada@example.com" {
+		t.Errorf("attendee = %q %q <%s>, want mapped standard fields (E-mail normalized to email)", a.FirstName, a.LastName, a.Email)
+	}
+	if _, ok := a.CustomFields["Служебное"]; ok {
+		t.Error("column mapped to \"\" was imported")
+	}
+	if a.CustomFields["Должность в компании"] != "Аналитик" {
+		t.Errorf("unmapped column not kept as a custom field: %v", a.CustomFields)
+	}
+	if len(event.FieldSchema) != 4 {
+		t.Errorf("field_schema = %q, want the 4 mapped fields", event.FieldSchema)
+	}
+
+	// 404: a mapping_id of another tenant.
+	c, rec = newUploadContext(t, e, tenantID.String(), uuid.New(),
+		map[string]string{"mapping_id": uuid.New().String()}, "file", "guests.csv", csv)
+	c.SetParamNames("event_id")
+	c.SetParamValues(event.ID.String())
+	if err := h.ImportAttendeesFile(c); err != nil {
+		t.Fatalf("ImportAttendeesFile: %v", err)
+	}
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("want 404, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodPost, path, rec)
+
+	// 400: a header-only file has no attendees.
+	c, rec = newUploadContext(t, e, tenantID.String(), uuid.New(), nil, "file", "empty.csv", []byte("email\n"))
+	c.SetParamNames("event_id")
+	c.SetParamValues(event.ID.String())
+	if err := h.ImportAttendeesFile(c); err != nil {
+		t.Fatalf("ImportAttendeesFile: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("want 400, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodPost, path, rec)
+}
+
+func contractImportMapping() *models.ImportMapping {
+	now := time.Now()
+	return &models.ImportMapping{
+		ID: uuid.New(), Name: "Timepad export",
+		Columns:   map[string]string{"Имя": "first_name"},
+		CreatedAt: now, UpdatedAt: now,
+	}
+}
+
+func TestContractImportMappingsCRUD(t *testing.T) {
+	tenantID := uuid.New()
+	m := contractImportMapping()
+	h := New(&fakeStore{
+		listImportMappings: func(uuid.UUID) ([]*models.ImportMapping, error) { return []*models.ImportMapping{m}, nil },
+		createImportMapping: func(nm *models.ImportMapping) error {
+			if nm.Name == "dup" {
+				return &pgconn.PgError{Code: "23505"}
+			}
+			nm.ID, nm.CreatedAt, nm.UpdatedAt = uuid.New(), time.Now(), time.Now()
+			return nil
+		},
+		updateImportMapping: func(nm *models.ImportMapping) error {
+			if nm.ID != m.ID {
+				return store.ErrImportMappingNotFound
+			}
+			nm.CreatedAt, nm.UpdatedAt = m.CreatedAt, time.Now()
+			return nil
+		},
+		deleteImportMapping: func(_, id uuid.UUID) error {
+			if id != m.ID {
+				return store.ErrImportMappingNotFound
+			}
+			return nil
+		},
+	})
+	e := echo.New()
+
+	c, rec := newAuthedContext(e, http.MethodGet, "/api/import-mappings", "", tenantID.String(), "admin")
+	if err := h.GetImportMappings(c); err != nil {
+		t.Fatalf("GetImportMappings: %v", err)
+	}
+	validateResponse(t, http.MethodGet, "/api/import-mappings", rec)
+
+	for body, want := range map[string]int{
+		`{"name":"Timepad","columns":{"Имя":"first_name","Телефон":"phone"}}`: http.StatusCreated,
+		`{"name":"dup"}`: http.StatusConflict,
+		`{"name":"  "}`:  http.StatusBadRequest,
+	} {
+		c, rec = newAuthedContext(e, http.MethodPost, "/api/import-mappings", body, tenantID.String(), "admin")
+		if err := h.CreateImportMapping(c); err != nil {
+			t.Fatalf("CreateImportMapping: %v", err)
+		}
+		if rec.Code != want {
+			t.Fatalf("%s: want %d, got %d, body=%s", body, want, rec.Code, rec.Body.String())
+		}
+		validateResponse(t, http.MethodPost, "/api/import-mappings", rec)
+	}
+
+	for id, want := range map[uuid.UUID]int{m.ID: http.StatusOK, uuid.New(): http.StatusNotFound} {
+		path := "/api/import-mappings/" + id.String()
+		c, rec = newAuthedContext(e, http.MethodPut, path, `{"name":"Renamed","columns":{}}`, tenantID.String(), "admin")
+		c.SetParamNames("id")
+		c.SetParamValues(id.String())
+		if err := h.UpdateImportMapping(c); err != nil {
+			t.Fatalf("UpdateImportMapping: %v", err)
+		}
+		if rec.Code != want {
+			t.Fatalf("PUT %s: want %d, got %d", id, want, rec.Code)
+		}
+		validateResponse(t, http.MethodPut, path, rec)
+
+		c, rec = newAuthedContext(e, http.MethodDelete, path, "", tenantID.String(), "admin")
+		c.SetParamNames("id")
+		c.SetParamValues(id.String())
+		if err := h.DeleteImportMapping(c); err != nil {
+			t.Fatalf("DeleteImportMapping: %v", err)
+		}
+		if want == http.StatusOK {
+			want = http.StatusNoContent
+		}
+		if rec.Code != want {
+			t.Fatalf("DELETE %s: want %d, got %d", id, want, rec.Code)
+		}
+		validateResponse(t, http.MethodDelete, path, rec)
+	}
+}
+
+// A bulk dry run reports what the import would do — including a row that
+// duplicates an earlier row of the same batch — and writes nothing: no
+// attendee, no field_schema, no monitor publish.
+func TestContractBulkCreateAttendeesDryRun(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	event.FieldSchema = []string{"first_name", "badge"}
+	h := New(&fakeStore{
+		getEventByID:          func(uuid.UUID) (*models.Event, error) { return event, nil },
+		checkAttendeeLimit:    func(uuid.UUID, uuid.UUID, int) (bool, int, int, error) { return true, 0, 100, nil },
+		getAttendeesByEventID: func(uuid.UUID, string, string) ([]*models.Attendee, error) { return nil, nil },
+		createAttendee: func(*models.Attendee) error {
+			t.Fatal("CreateAttendee called on a dry run")
+			return nil
+		},
+		updateEvent: func(*models.Event) error {
+			t.Fatal("UpdateEvent called on a dry run")
+			return nil
+		},
+	})
+	mem := broker.NewMemBroker()
+	h.Broker = mem
+	ch, unsubscribe := mem.Subscribe(event.ID)
+	defer unsubscribe()
+
+	body := `{"field_schema":["first_name","email"],"attendees":[` +
+		`{"first_name":"Ada","email":"ada@example.com"},` +
+		`{"first_name":"Ada","email":"ADA@example.com"}]}`
+	path := "/api/events/" + event.ID.String() + "/attendees/bulk"
+	c, rec := newAuthedContext(echo.New(), http.MethodPost, path+"?dry_run=true", body, tenantID.String(), "admin")
+	c.SetParamNames("event_id")
+	c.SetParamValues(event.ID.String())
+	if err := h.BulkCreateAttendees(c); err != nil {
+		t.Fatalf("BulkCreateAttendees: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodPost, path, rec)
+
+	var resp BulkImportResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !resp.DryRun || resp.Created != 1 || resp.Skipped != 1 || len(resp.Errors) != 1 || resp.Errors[0].Problem != "duplicate_email" {
+		t.Fatalf("response = %+v, want a dry run with 1 created and row 2 a duplicate_email", resp)
+	}
+	if sc := resp.SchemaChanges; sc == nil || len(sc.Added) != 1 || sc.Added[0] != "email" || len(sc.Removed) != 1 || sc.Removed[0] != "badge" {
+		t.Fatalf("schema_changes = %+v, want +email -badge", resp.SchemaChanges)
+	}
+	if len(event.FieldSchema) != 2 || event.FieldSchema[1] != "badge" {
+		t.Errorf("event.FieldSchema mutated to %v", event.FieldSchema)
+	}
+	if pendingSignal(ch) {
+		t.Error("dry run published a monitor signal")
+	}
+}
@@ -1,9 +1,14 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"strings"
 	"testing"
 
+	"idento/backend/internal/health"
+
 	"github.com/labstack/echo/v4"
 )
 
@@ -24,3 +29,57 @@ func TestContractInstance(t *testing.T) {
 	}
 	validateResponse(t, http.MethodGet, "/api/instance", rec)
 }
+
+func TestContractMetrics(t *testing.T) {
+	e := echo.New()
+	c, rec := newUnauthedContext(e, http.MethodGet, "/metrics", "")
+	c.Request().Header.Set(echo.HeaderAuthorization, "Bearer scrape")
+	if err := Metrics("scrape")(c); err != nil {
+		t.Fatalf("Metrics: %v", err)
+	}
+	validateResponse(t, http.MethodGet, "/metrics", rec)
+
+	c, rec = newUnauthedContext(e, http.MethodGet, "/metrics", "")
+	if err := Metrics("scrape")(c); err != nil {
+		t.Fatalf("Metrics: %v", err)
+	}
+	validateResponse(t, http.MethodGet, "/metrics", rec)
+}
+
+func TestContractHealthProbes(t *testing.T) {
+	e := echo.New()
+	live := health.NewChecker().Add("jobs", health.Err(func() error { return nil }))
+	c, rec := newUnauthedContext(e, http.MethodGet, "/health/live", "")
+	if err := Probe(live, "")(c); err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("live status = %d", rec.Code)
+	}
+	validateResponse(t, http.MethodGet, "/health/live", rec)
+
+	ready := health.NewChecker().
+		Add("database", health.Database(func(context.Context) error { return nil }, health.SlowPing)).
+		Add("broker", health.Err(func() error { return errors.New("LISTEN connection lost 3s ago, reconnecting") }))
+	c, rec = newUnauthedContext(e, http.MethodGet, "/health/ready", "")
+	if err := Probe(ready, "scrape")(c); err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"broker":{"status":"down"}`) {
+		t.Fatalf("ready = %d %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "LISTEN") || strings.Contains(rec.Body.String(), "latency_ms") {
+		t.Fatalf("anonymous probe leaks details: %s", rec.Body.String())
+	}
+	validateResponse(t, http.MethodGet, "/health/ready", rec)
+
+	c, rec = newUnauthedContext(e, http.MethodGet, "/health/ready", "")
+	c.Request().Header.Set(echo.HeaderAuthorization, "Bearer scrape")
+	if err := Probe(ready, "scrape")(c); err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "LISTEN connection lost") {
+		t.Fatalf("ready with metrics token = %d %s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodGet, "/health/ready", rec)
+}
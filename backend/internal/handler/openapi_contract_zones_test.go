@@ -555,11 +555,15 @@ func TestContractBulkUpdateZoneAccessRules(t *testing.T) {
 	tenantID := uuid.New()
 	event := contractEvent(tenantID, "Tech Summit")
 	zone := contractZone(event.ID)
-	h := New(&fakeStore{
-		getEventByID:              func(uuid.UUID) (*models.Event, error) { return event, nil },
-		getEventZoneByID:          func(uuid.UUID) (*models.EventZone, error) { return zone, nil },
+	fs := &fakeStore{
+		getEventByID:     func(uuid.UUID) (*models.Event, error) { return event, nil },
+		getEventZoneByID: func(uuid.UUID) (*models.EventZone, error) { return zone, nil },
+		getZoneAccessRules: func(uuid.UUID) ([]*models.ZoneAccessRule, error) {
+			return []*models.ZoneAccessRule{{ZoneID: zone.ID, Category: "vip", Allowed: false}}, nil
+		},
 		bulkUpdateZoneAccessRules: func(uuid.UUID, []*models.ZoneAccessRule) error { return nil },
-	})
+	}
+	h := New(fs)
 	e := echo.New()
 	path := "/api/zones/" + zone.ID.String() + "/access-rules"
 	body := `[{"category":"vip","allowed":true},{"category":"general","allowed":false,"time_from":"09:00","time_to":"17:00"}]`
@@ -571,11 +575,18 @@ func TestContractBulkUpdateZoneAccessRules(t *testing.T) {
 		t.Fatalf("BulkUpdateZoneAccessRules: %v", err)
 	}
 	validateResponse(t, http.MethodPut, path, rec)
+	if len(fs.tenantAudit) != 1 {
+		t.Fatalf("tenant audit entries = %d, want 1", len(fs.tenantAudit))
+	}
+	if got := string(fs.tenantAudit[0].Changes); !strings.Contains(got, `"vip.allowed":{"from":false,"to":true}`) || !strings.Contains(got, `"general.time_from":{"from":null,"to":"09:00"}`) {
+		t.Errorf("audit changes = %s, want per-category rule diffs", got)
+	}
 
 	// 500: Store.BulkUpdateZoneAccessRules itself fails ("Failed to update access rules").
 	hUpdateFail := New(&fakeStore{
 		getEventByID:              func(uuid.UUID) (*models.Event, error) { return event, nil },
 		getEventZoneByID:          func(uuid.UUID) (*models.EventZone, error) { return zone, nil },
+		getZoneAccessRules:        func(uuid.UUID) ([]*models.ZoneAccessRule, error) { return nil, nil },
 		bulkUpdateZoneAccessRules: func(uuid.UUID, []*models.ZoneAccessRule) error { return errors.New("tx failed") },
 	})
 	c, rec = newAuthedContext(e, http.MethodPut, path, body, tenantID.String(), "admin")
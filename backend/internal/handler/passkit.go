@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"idento/backend/internal/models"
+	"idento/backend/internal/wallet"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// The PassKit web service (Apple's "Wallet Passes" web service reference):
+// devices that installed a pass register their push token, are pushed when
+// the pass changes (runWalletUpdateJob), then ask which of their passes
+// changed and fetch those. Requests about one pass carry its
+// authenticationToken; the serial number is the attendee ID.
+
+// maxPassKitLogBytes caps a device's error log submission.
+const maxPassKitLogBytes = 64 << 10
+
+// passKitPass resolves the pass a request names and checks its
+// "ApplePass <token>" authorization; nil means the response was written.
+func (h *Handler) passKitPass(c echo.Context) (*models.WalletPass, error) {
+	serial, err := uuid.Parse(c.Param("serial"))
+	if err != nil {
+		return nil, c.NoContent(http.StatusNotFound)
+	}
+	pass, err := h.Store.GetWalletPass(c.Request().Context(), c.Param("pass_type_id"), serial)
+	if err != nil {
+		return nil, c.NoContent(http.StatusInternalServerError)
+	}
+	token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "ApplePass ")
+	if pass == nil || !ok || subtle.ConstantTimeCompare([]byte(token), []byte(pass.AuthToken)) != 1 {
+		return nil, c.NoContent(http.StatusUnauthorized)
+	}
+	return pass, nil
+}
+
+// passKitRegistration is the body of a device registration.
+type passKitRegistration struct {
+	PushToken string `json:"pushToken"`
+}
+
+// RegisterPassDevice serves POST
+// /passkit/v1/devices/{device_id}/registrations/{pass_type_id}/{serial}:
+// 201 for a new registration, 200 when the device was already registered.
+func (h *Handler) RegisterPassDevice(c echo.Context) error {
+	pass, err := h.passKitPass(c)
+	if pass == nil {
+		return err
+	}
+	req := new(passKitRegistration)
+	if err := c.Bind(req); err != nil || req.PushToken == "" {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	created, err := h.Store.RegisterWalletDevice(c.Request().Context(), c.Param("device_id"), pass.AttendeeID, req.PushToken)
+	if err != nil {
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	if created {
+		return c.NoContent(http.StatusCreated)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// UnregisterPassDevice serves DELETE
+// /passkit/v1/devices/{device_id}/registrations/{pass_type_id}/{serial}:
+// the pass was removed from the device.
+func (h *Handler) UnregisterPassDevice(c echo.Context) error {
+	pass, err := h.passKitPass(c)
+	if pass == nil {
+		return err
+	}
+	if _, err := h.Store.UnregisterWalletDevice(c.Request().Context(), c.Param("device_id"), pass.AttendeeID); err != nil {
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// GetDevicePassSerials serves GET
+// /passkit/v1/devices/{device_id}/registrations/{pass_type_id}: the
+// device's passes changed since passesUpdatedSince (the lastUpdated tag of
+// its previous call), or 204 when none did.
+func (h *Handler) GetDevicePassSerials(c echo.Context) error {
+	var since *time.Time
+	if raw := c.QueryParam("passesUpdatedSince"); raw != "" {
+		micros, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		t := time.UnixMicro(micros)
+		since = &t
+	}
+	serials, latest, err := h.Store.GetWalletDevicePasses(c.Request().Context(), c.Param("device_id"), c.Param("pass_type_id"), since)
+	if err != nil {
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	if len(serials) == 0 {
+		return c.NoContent(http.StatusNoContent)
+	}
+	ids := make([]string, len(serials))
+	for i, s := range serials {
+		ids[i] = s.String()
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"serialNumbers": ids,
+		"lastUpdated":   strconv.FormatInt(latest.UnixMicro(), 10),
+	})
+}
+
+// GetLatestPass serves GET /passkit/v1/passes/{pass_type_id}/{serial}: the
+// pass as it is now, or 304 when it has not changed since If-Modified-Since.
+func (h *Handler) GetLatestPass(c echo.Context) error {
+	pass, err := h.passKitPass(c)
+	if pass == nil {
+		return err
+	}
+	modified := pass.UpdatedAt.UTC().Truncate(time.Second)
+	if since, err := http.ParseTime(c.Request().Header.Get("If-Modified-Since")); err == nil && !modified.After(since) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	ctx := c.Request().Context()
+	attendee, err := h.Store.GetAttendeeByID(ctx, pass.AttendeeID)
+	if err != nil || attendee == nil {
+		// The attendee was deleted: Wallet keeps its last copy.
+		return c.NoContent(http.StatusNotFound)
+	}
+	event, err := h.Store.GetEventByID(ctx, attendee.EventID)
+	if err != nil || event == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	settings, err := h.Store.GetTenantWalletSettings(ctx, event.TenantID)
+	if err != nil {
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	// A pass issued under a certificate the tenant has since replaced or
+	// removed can no longer be signed; Wallet keeps its last copy.
+	if !settings.AppleConfigured() || settings.ApplePassTypeID != pass.PassTypeID {
+		return c.NoContent(http.StatusNotFound)
+	}
+	signer, err := wallet.LoadSigner([]byte(settings.AppleCertificate))
+	if err != nil {
+		log.Printf("PassKit: pass %s: %v", pass.AttendeeID, err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	data, err := h.renderApplePass(ctx, signer, pass, event, attendee)
+	if err != nil {
+		log.Printf("PassKit: pass %s: %v", pass.AttendeeID, err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	c.Response().Header().Set(echo.HeaderLastModified, modified.Format(http.TimeFormat))
+	return c.Blob(http.StatusOK, "application/vnd.apple.pkpass", data)
+}
+
+// passKitLog is the body of a device's error log submission.
+type passKitLog struct {
+	Logs []string `json:"logs"`
+}
+
+// LogPassKitErrors serves POST /passkit/v1/log: devices report problems
+// with the web service or our passes here; they end up in the server log.
+func (h *Handler) LogPassKitErrors(c echo.Context) error {
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, maxPassKitLogBytes)
+	req := new(passKitLog)
+	if err := c.Bind(req); err != nil {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	for _, line := range req.Logs {
+		log.Printf("PassKit device log: %s", line)
+	}
+	return c.NoContent(http.StatusOK)
+}
@@ -132,7 +132,7 @@ func (h *Handler) GetPrinterPairingQR(c echo.Context) error {
 // tenant's registry, one row per printer, with a ready-to-bind qr_payload
 // column (the exact PrinterQRData JSON) plus the human-readable fields a
 // label tool prints alongside the QR. UTF-8 BOM so Excel opens Cyrillic
-// names correctly; encoding/csv escaping (the ExportAttendeesCSV precedent),
+// names correctly; encoding/csv escaping (the ExportAttendees precedent),
 // and sanitizeCSVField against formula injection on free-text columns.
 func (h *Handler) ExportPrinterPairingCSV(c echo.Context) error {
 	tenantID, err := tenantIDFromContext(c)
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+
+	"idento/backend/internal/health"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterProbeRoutes mounts the liveness and readiness probes next to the
+// static /health. Liveness only covers this process's own loops, so an
+// orchestrator restarts a wedged replica but not every replica when
+// Postgres blips; readiness adds the dependencies a replica needs to serve.
+// metricsToken unlocks the full reports (see Probe).
+func RegisterProbeRoutes(e *echo.Echo, live, ready *health.Checker, metricsToken string) {
+	e.GET("/health/live", Probe(live, metricsToken))
+	e.GET("/health/ready", Probe(ready, metricsToken))
+}
+
+// Probe runs checker and answers 200 with its report, or 503 when any
+// component is down. The probes are public, so the report carries only
+// component statuses; a request with the metrics bearer token (when one is
+// configured) also gets the errors and details, which are otherwise only
+// logged.
+func Probe(checker *health.Checker, metricsToken string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		report := checker.Run(c.Request().Context())
+		status := http.StatusOK
+		if !report.OK() {
+			status = http.StatusServiceUnavailable
+		}
+		if metricsToken == "" || !hasBearerToken(c, metricsToken) {
+			report = report.Summary()
+		}
+		c.Response().Header().Set("Cache-Control", "no-store")
+		return c.JSON(status, report)
+	}
+}
@@ -28,8 +28,7 @@ func (h *Handler) GetAttendeeQR(c echo.Context) error {
 		return writeErr(c, err)
 	}
 
-	// Generate QR code (256x256 pixels, medium recovery level)
-	qr, err := qrcode.Encode(attendee.Code, qrcode.Medium, 256)
+	qr, err := attendeeQRPNG(attendee.Code)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate QR code")
 	}
@@ -40,3 +39,10 @@ func (h *Handler) GetAttendeeQR(c echo.Context) error {
 
 	return c.Blob(http.StatusOK, "image/png", qr)
 }
+
+// attendeeQRPNG renders an attendee's ticket code as the QR image scanned
+// at check-in: a 256x256 PNG with medium error correction. Ticket emails
+// embed the same image.
+func attendeeQRPNG(code string) ([]byte, error) {
+	return qrcode.Encode(code, qrcode.Medium, 256)
+}
@@ -30,14 +30,24 @@ func (h *Handler) LoginWithQR(c echo.Context) error {
 		}
 	}
 
-	// Generate JWT
-	tokenString, err := generateTokenForTenant(user, user.TenantID.String(), user.Role)
+	// A QR badge is a single factor: it must not bypass an admin's or
+	// manager's authenticator. Station staff are unaffected.
+	if user.TwoFactorEnabled && twoFactorPolicyApplies(user.Role) {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"code":  "two_factor_required",
+			"error": "This account uses two-factor authentication; sign in with email and password",
+		})
+	}
+
+	session, err := h.startSession(c, user.ID, user.TenantID, user.Role, nil, false)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to issue token"})
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"token": tokenString,
-		"user":  user,
+		"token":         session.Token,
+		"refresh_token": session.RefreshToken,
+		"expires_at":    session.ExpiresAt,
+		"user":          user,
 	})
 }
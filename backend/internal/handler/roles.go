@@ -0,0 +1,288 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+)
+
+// Custom roles are tenant-level resources managed by tenant admins; the
+// store's WHERE tenant_id = $1 is the ownership check, and a foreign ID
+// collapses to the same 404 as a missing one. authz.go enforces them.
+
+// Audit actions for custom roles (see audit.go).
+const (
+	auditCreateRole    = "create_role"
+	auditUpdateRole    = "update_role"
+	auditDeleteRole    = "delete_role"
+	auditSetMemberRole = "set_member_role"
+)
+
+// TenantRoleRequest is the body of POST /api/roles and PUT /api/roles/{id}.
+type TenantRoleRequest struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Permissions []string    `json:"permissions"`
+	EventIDs    []uuid.UUID `json:"event_ids"`
+}
+
+func (r *TenantRoleRequest) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(r.Name) > 100 {
+		return errors.New("name must be at most 100 characters")
+	}
+	if len(r.Description) > 1000 {
+		return errors.New("description must be at most 1000 characters")
+	}
+	permissions := []string{}
+	for _, p := range r.Permissions {
+		if !validPermission(p) {
+			return fmt.Errorf("unknown permission %q", p)
+		}
+		if !slices.Contains(permissions, p) {
+			permissions = append(permissions, p)
+		}
+	}
+	r.Permissions = permissions
+	eventIDs := []uuid.UUID{}
+	for _, id := range r.EventIDs {
+		if !slices.Contains(eventIDs, id) {
+			eventIDs = append(eventIDs, id)
+		}
+	}
+	r.EventIDs = eventIDs
+	return nil
+}
+
+// bindTenantRole binds and validates a role body, checking that every
+// scoped event belongs to the caller's tenant.
+func (h *Handler) bindTenantRole(c echo.Context, tenantID uuid.UUID) (*models.TenantRole, error) {
+	var req TenantRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := req.validate(); err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, err.Error())
+	}
+	for _, eventID := range req.EventIDs {
+		if _, err := h.requireEventOwnership(c, eventID); err != nil {
+			var he *httpError
+			if errors.As(err, &he) && he.status == http.StatusNotFound {
+				return nil, newHTTPError(http.StatusBadRequest, "event_ids contains an unknown event")
+			}
+			return nil, err
+		}
+	}
+	return &models.TenantRole{
+		TenantID:    tenantID,
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+		EventIDs:    req.EventIDs,
+	}, nil
+}
+
+func tenantRoleWriteErr(c echo.Context, err error) error {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, store.ErrTenantRoleNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Role not found"})
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		return c.JSON(http.StatusConflict, map[string]string{"error": "A role with this name already exists"})
+	default:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save role"})
+	}
+}
+
+type builtinRoleView struct {
+	Name        string       `json:"name"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// GetRoles lists every permission, what the built-in roles grant, and the
+// tenant's custom roles (admin only).
+func (h *Handler) GetRoles(c echo.Context) error {
+	tenantID, err := requireTenantAdmin(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	roles, err := h.Store.ListTenantRoles(c.Request().Context(), tenantID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch roles"})
+	}
+	builtin := []builtinRoleView{}
+	for _, name := range []string{"admin", "manager", "staff"} {
+		builtin = append(builtin, builtinRoleView{Name: name, Permissions: builtinPermissions[name]})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"permissions":   allPermissions,
+		"builtin_roles": builtin,
+		"roles":         roles,
+	})
+}
+
+// CreateRole adds a custom role; names are unique per tenant,
+// case-insensitively (409 on a duplicate).
+func (h *Handler) CreateRole(c echo.Context) error {
+	tenantID, err := requireTenantAdmin(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	role, err := h.bindTenantRole(c, tenantID)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	if err := h.Store.CreateTenantRole(c.Request().Context(), role); err != nil {
+		return tenantRoleWriteErr(c, err)
+	}
+	h.logTenantDiff(c, nil, auditCreateRole, "role", role.ID, nil, roleAuditView(role))
+	return c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole replaces a custom role. Members holding it get the new
+// permissions on their next request.
+func (h *Handler) UpdateRole(c echo.Context) error {
+	tenantID, err := requireTenantAdmin(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid role ID"})
+	}
+	before, err := h.Store.GetTenantRole(c.Request().Context(), tenantID, id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch role"})
+	}
+	if before == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Role not found"})
+	}
+	role, err := h.bindTenantRole(c, tenantID)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	role.ID = id
+	if err := h.Store.UpdateTenantRole(c.Request().Context(), role); err != nil {
+		return tenantRoleWriteErr(c, err)
+	}
+	h.logTenantDiff(c, nil, auditUpdateRole, "role", id, roleAuditView(before), roleAuditView(role))
+	return c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole removes a custom role; its members fall back to their
+// built-in role's permissions.
+func (h *Handler) DeleteRole(c echo.Context) error {
+	tenantID, err := requireTenantAdmin(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid role ID"})
+	}
+	before, err := h.Store.GetTenantRole(c.Request().Context(), tenantID, id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch role"})
+	}
+	if before == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Role not found"})
+	}
+	if err := h.Store.DeleteTenantRole(c.Request().Context(), tenantID, id); err != nil {
+		return tenantRoleWriteErr(c, err)
+	}
+	h.logTenantDiff(c, nil, auditDeleteRole, "role", id, roleAuditView(before), nil)
+	return c.NoContent(http.StatusNoContent)
+}
+
+func roleAuditView(r *models.TenantRole) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        r.Name,
+		"description": r.Description,
+		"permissions": r.Permissions,
+		"event_ids":   r.EventIDs,
+	}
+}
+
+// SetUserCustomRole serves PUT /api/users/:id/custom-role: it assigns a
+// custom role to a member of the caller's tenant, or clears it with
+// {"role_id": null} (admin only).
+func (h *Handler) SetUserCustomRole(c echo.Context) error {
+	tenantID, err := requireTenantAdmin(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+	var req struct {
+		RoleID *uuid.UUID `json:"role_id"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	ctx := c.Request().Context()
+	var roleName interface{}
+	if req.RoleID != nil {
+		role, err := h.Store.GetTenantRole(ctx, tenantID, *req.RoleID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch role"})
+		}
+		if role == nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "role_id is not a role of this organization"})
+		}
+		roleName = role.Name
+	}
+	found, err := h.Store.SetMemberCustomRole(ctx, tenantID, userID, req.RoleID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to assign role"})
+	}
+	if !found {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+	h.logTenantAction(c, nil, auditSetMemberRole, "user", userID, map[string]interface{}{"role_id": req.RoleID, "role_name": roleName})
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetMyPermissions serves GET /api/me/permissions: what the caller may do
+// in their current tenant, for clients to hide what they cannot use.
+// event_ids lists the events the permissions apply to; empty means all.
+func (h *Handler) GetMyPermissions(c echo.Context) error {
+	claims, err := claimsFromContext(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	resp := map[string]interface{}{
+		"role":        claims.Role,
+		"custom_role": nil,
+		"permissions": builtinPermissions[claims.Role],
+		"event_ids":   []uuid.UUID{},
+	}
+	if resp["permissions"] == nil {
+		resp["permissions"] = []Permission{}
+	}
+	if claims.Role != "admin" {
+		custom, err := h.callerCustomRole(c, claims)
+		if err != nil {
+			return writeErr(c, err)
+		}
+		if custom != nil {
+			resp["custom_role"] = map[string]interface{}{"id": custom.ID, "name": custom.Name}
+			resp["permissions"] = custom.Permissions
+			resp["event_ids"] = custom.EventIDs
+		}
+	}
+	return c.JSON(http.StatusOK, resp)
+}
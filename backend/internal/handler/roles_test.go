@@ -0,0 +1,350 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+)
+
+// roleStore is an in-memory tenant_roles table for one tenant.
+type roleStore struct {
+	roles   map[uuid.UUID]*models.TenantRole
+	members map[uuid.UUID]*uuid.UUID // user -> custom role
+}
+
+func newRoleFakeStore(tenantID uuid.UUID, events ...*models.Event) (*fakeStore, *roleStore) {
+	rs := &roleStore{roles: map[uuid.UUID]*models.TenantRole{}, members: map[uuid.UUID]*uuid.UUID{}}
+	byName := func(name string, except uuid.UUID) bool {
+		for _, r := range rs.roles {
+			if r.ID != except && strings.EqualFold(r.Name, name) {
+				return true
+			}
+		}
+		return false
+	}
+	fs := &fakeStore{
+		getEventByID: func(id uuid.UUID) (*models.Event, error) {
+			for _, ev := range events {
+				if ev.ID == id {
+					return ev, nil
+				}
+			}
+			return nil, nil
+		},
+		listTenantRoles: func(uuid.UUID) ([]*models.TenantRole, error) {
+			out := []*models.TenantRole{}
+			for _, r := range rs.roles {
+				out = append(out, r)
+			}
+			return out, nil
+		},
+		getTenantRole: func(tid, id uuid.UUID) (*models.TenantRole, error) {
+			if r := rs.roles[id]; r != nil && tid == tenantID {
+				return r, nil
+			}
+			return nil, nil
+		},
+		createTenantRole: func(r *models.TenantRole) error {
+			if byName(r.Name, uuid.Nil) {
+				return &pgconn.PgError{Code: "23505"}
+			}
+			r.ID, r.CreatedAt, r.UpdatedAt, r.UserIDs = uuid.New(), time.Now(), time.Now(), []uuid.UUID{}
+			rs.roles[r.ID] = r
+			return nil
+		},
+		updateTenantRole: func(r *models.TenantRole) error {
+			old := rs.roles[r.ID]
+			if old == nil {
+				return store.ErrTenantRoleNotFound
+			}
+			if byName(r.Name, r.ID) {
+				return &pgconn.PgError{Code: "23505"}
+			}
+			r.CreatedAt, r.UpdatedAt, r.UserIDs = old.CreatedAt, time.Now(), old.UserIDs
+			rs.roles[r.ID] = r
+			return nil
+		},
+		deleteTenantRole: func(_, id uuid.UUID) error {
+			if rs.roles[id] == nil {
+				return store.ErrTenantRoleNotFound
+			}
+			delete(rs.roles, id)
+			return nil
+		},
+		setMemberCustomRole: func(_, userID uuid.UUID, roleID *uuid.UUID) (bool, error) {
+			if _, ok := rs.members[userID]; !ok {
+				return false, nil
+			}
+			rs.members[userID] = roleID
+			return true, nil
+		},
+		getMemberCustomRole: func(userID, _ uuid.UUID) (*models.TenantRole, error) {
+			if id := rs.members[userID]; id != nil {
+				return rs.roles[*id], nil
+			}
+			return nil, nil
+		},
+	}
+	return fs, rs
+}
+
+func TestContractRoles(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	foreign := contractEvent(uuid.New(), "Elsewhere")
+	fs, rs := newRoleFakeStore(tenantID, event, foreign)
+	h := New(fs)
+	e := echo.New()
+
+	create := func(body string) (*models.TenantRole, int) {
+		t.Helper()
+		c, rec := newAuthedContext(e, http.MethodPost, "/api/roles", body, tenantID.String(), "admin")
+		if err := h.CreateRole(c); err != nil {
+			t.Fatal(err)
+		}
+		validateResponse(t, http.MethodPost, "/api/roles", rec)
+		var r models.TenantRole
+		_ = json.Unmarshal(rec.Body.Bytes(), &r)
+		return &r, rec.Code
+	}
+
+	role, code := create(`{"name":"  Door staff ","permissions":["checkin:undo","checkin:undo","export"],"event_ids":["` + event.ID.String() + `"]}`)
+	if code != http.StatusCreated {
+		t.Fatalf("create: want 201, got %d", code)
+	}
+	if role.Name != "Door staff" || len(role.Permissions) != 2 || len(role.EventIDs) != 1 {
+		t.Errorf("created role = %+v", role)
+	}
+	if len(fs.tenantAudit) != 1 || fs.tenantAudit[0].Action != auditCreateRole {
+		t.Errorf("audit = %+v", fs.tenantAudit)
+	}
+
+	for name, body := range map[string]string{
+		"no name":            `{"permissions":["export"]}`,
+		"unknown permission": `{"name":"X","permissions":["attendees:delete"]}`,
+		"foreign event":      `{"name":"X","event_ids":["` + foreign.ID.String() + `"]}`,
+	} {
+		if _, code := create(body); code != http.StatusBadRequest {
+			t.Errorf("%s: want 400, got %d", name, code)
+		}
+	}
+	if _, code := create(`{"name":"door STAFF"}`); code != http.StatusConflict {
+		t.Errorf("duplicate name: want 409, got %d", code)
+	}
+
+	c, rec := newAuthedContext(e, http.MethodGet, "/api/roles", "", tenantID.String(), "admin")
+	if err := h.GetRoles(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: want 200, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodGet, "/api/roles", rec)
+	var list struct {
+		Permissions  []string `json:"permissions"`
+		BuiltinRoles []struct {
+			Name        string   `json:"name"`
+			Permissions []string `json:"permissions"`
+		} `json:"builtin_roles"`
+		Roles []models.TenantRole `json:"roles"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Permissions) != len(allPermissions) || len(list.BuiltinRoles) != 3 || len(list.Roles) != 1 {
+		t.Errorf("list = %+v", list)
+	}
+
+	path := "/api/roles/" + role.ID.String()
+	update := func(id, body string) int {
+		t.Helper()
+		c, rec := newAuthedContext(e, http.MethodPut, "/api/roles/"+id, body, tenantID.String(), "admin")
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		if err := h.UpdateRole(c); err != nil {
+			t.Fatal(err)
+		}
+		validateResponse(t, http.MethodPut, "/api/roles/"+id, rec)
+		return rec.Code
+	}
+	if code := update(role.ID.String(), `{"name":"Door","permissions":["attendees:block"]}`); code != http.StatusOK {
+		t.Fatalf("update: want 200, got %d", code)
+	}
+	if got := rs.roles[role.ID]; got.Name != "Door" || len(got.EventIDs) != 0 || got.Permissions[0] != "attendees:block" {
+		t.Errorf("updated role = %+v", got)
+	}
+	if code := update(uuid.NewString(), `{"name":"Door"}`); code != http.StatusNotFound {
+		t.Errorf("update unknown: want 404, got %d", code)
+	}
+	if code := update("not-a-uuid", `{"name":"Door"}`); code != http.StatusBadRequest {
+		t.Errorf("update bad id: want 400, got %d", code)
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete} {
+		url := "/api/roles"
+		if method == http.MethodPut || method == http.MethodDelete {
+			url = path
+		}
+		c, rec := newAuthedContext(e, method, url, `{"name":"Sneaky"}`, tenantID.String(), "manager")
+		c.SetParamNames("id")
+		c.SetParamValues(role.ID.String())
+		var err error
+		switch method {
+		case http.MethodGet:
+			err = h.GetRoles(c)
+		case http.MethodPost:
+			err = h.CreateRole(c)
+		case http.MethodPut:
+			err = h.UpdateRole(c)
+		default:
+			err = h.DeleteRole(c)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s as manager: want 403, got %d", method, rec.Code)
+		}
+		validateResponse(t, method, url, rec)
+	}
+
+	for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		c, rec := newAuthedContext(e, http.MethodDelete, path, "", tenantID.String(), "admin")
+		c.SetParamNames("id")
+		c.SetParamValues(role.ID.String())
+		if err := h.DeleteRole(c); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != want {
+			t.Errorf("DELETE: want %d, got %d", want, rec.Code)
+		}
+		validateResponse(t, http.MethodDelete, path, rec)
+	}
+	c, rec = newAuthedContext(e, http.MethodDelete, "/api/roles/x", "", tenantID.String(), "admin")
+	c.SetParamNames("id")
+	c.SetParamValues("x")
+	if err := h.DeleteRole(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("DELETE bad id: want 400, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodDelete, "/api/roles/x", rec)
+}
+
+// Assigning a custom role changes what the member may do on their next
+// request: here it narrows a staff member's export to one event.
+func TestContractCustomRoleAssignment(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	other := contractEvent(tenantID, "Other")
+	fs, rs := newRoleFakeStore(tenantID, event, other)
+	fs.streamAttendeeExport = func(uuid.UUID, store.AttendeeFilter, bool, func(*store.AttendeeExportRow) error) error { return nil }
+	h := New(fs)
+	e := echo.New()
+	staffID := uuid.New()
+	rs.members[staffID] = nil
+	role := &models.TenantRole{TenantID: tenantID, Name: "Exporter", Permissions: []string{"export"}, EventIDs: []uuid.UUID{event.ID}}
+	if err := fs.createTenantRole(role); err != nil {
+		t.Fatal(err)
+	}
+
+	export := func(ev *models.Event) int {
+		t.Helper()
+		url := "/api/events/" + ev.ID.String() + "/attendees/export"
+		c, rec := newAuthedContextWithUserID(e, http.MethodGet, url, "", tenantID.String(), staffID, "staff")
+		c.SetParamNames("event_id")
+		c.SetParamValues(ev.ID.String())
+		if err := h.ExportAttendees(c); err != nil {
+			t.Fatal(err)
+		}
+		validateResponse(t, http.MethodGet, url, rec)
+		return rec.Code
+	}
+	if code := export(other); code != http.StatusOK {
+		t.Fatalf("staff export: want 200, got %d", code)
+	}
+
+	assign := func(userID uuid.UUID, body string) int {
+		t.Helper()
+		url := "/api/users/" + userID.String() + "/custom-role"
+		c, rec := newAuthedContext(e, http.MethodPut, url, body, tenantID.String(), "admin")
+		c.SetParamNames("id")
+		c.SetParamValues(userID.String())
+		if err := h.SetUserCustomRole(c); err != nil {
+			t.Fatal(err)
+		}
+		validateResponse(t, http.MethodPut, url, rec)
+		return rec.Code
+	}
+	if code := assign(staffID, `{"role_id":"`+uuid.NewString()+`"}`); code != http.StatusBadRequest {
+		t.Errorf("unknown role: want 400, got %d", code)
+	}
+	if code := assign(uuid.New(), `{"role_id":"`+role.ID.String()+`"}`); code != http.StatusNotFound {
+		t.Errorf("non-member: want 404, got %d", code)
+	}
+	if code := assign(staffID, `{"role_id":"`+role.ID.String()+`"}`); code != http.StatusNoContent {
+		t.Fatalf("assign: want 204, got %d", code)
+	}
+	if last := fs.tenantAudit[len(fs.tenantAudit)-1]; last.Action != auditSetMemberRole {
+		t.Errorf("audit = %+v", last)
+	}
+
+	if code := export(event); code != http.StatusOK {
+		t.Errorf("export with role: want 200, got %d", code)
+	}
+	if code := export(other); code != http.StatusForbidden {
+		t.Errorf("export outside the role's events: want 403, got %d", code)
+	}
+
+	c, rec := newAuthedContextWithUserID(e, http.MethodGet, "/api/me/permissions", "", tenantID.String(), staffID, "staff")
+	if err := h.GetMyPermissions(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("me/permissions: want 200, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodGet, "/api/me/permissions", rec)
+	var me struct {
+		Role       string `json:"role"`
+		CustomRole *struct {
+			Name string `json:"name"`
+		} `json:"custom_role"`
+		Permissions []string    `json:"permissions"`
+		EventIDs    []uuid.UUID `json:"event_ids"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &me); err != nil {
+		t.Fatal(err)
+	}
+	if me.Role != "staff" || me.CustomRole == nil || me.CustomRole.Name != "Exporter" ||
+		len(me.Permissions) != 1 || me.Permissions[0] != "export" || len(me.EventIDs) != 1 {
+		t.Errorf("me = %+v", me)
+	}
+
+	if code := assign(staffID, `{"role_id":null}`); code != http.StatusNoContent {
+		t.Fatalf("clear: want 204, got %d", code)
+	}
+	if code := export(other); code != http.StatusOK {
+		t.Errorf("export after clearing: want 200, got %d", code)
+	}
+
+	c, rec = newAuthedContextWithUserID(e, http.MethodPut, "/api/users/x/custom-role", `{"role_id":null}`, tenantID.String(), staffID, "manager")
+	c.SetParamNames("id")
+	c.SetParamValues(staffID.String())
+	if err := h.SetUserCustomRole(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("assign as manager: want 403, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodPut, "/api/users/"+staffID.String()+"/custom-role", rec)
+}
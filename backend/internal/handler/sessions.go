@@ -0,0 +1,277 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"idento/backend/internal/config"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// Audit actions for session revocation (see audit.go).
+const (
+	auditRevokeUserSessions = "revoke_user_sessions"
+	auditRevokeStation      = "revoke_station"
+)
+
+// sessionTokens is what every sign-in hands out: a short-lived access JWT
+// (token) and the refresh token that renews it at POST /auth/refresh.
+type sessionTokens struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// RefreshRequest is the JSON body for POST /auth/refresh.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// signAccessToken signs claims as an HS256 JWT expiring after ttl.
+func signAccessToken(claims *models.JWTCustomClaims, ttl time.Duration) (string, time.Time, error) {
+	secret := config.JWTSecret()
+	if secret == "" {
+		return "", time.Time{}, fmt.Errorf("JWT_SECRET environment variable not set")
+	}
+	expiresAt := time.Now().Add(ttl)
+	claims.RegisteredClaims.ExpiresAt = jwt.NewNumericDate(expiresAt)
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	return signed, expiresAt, err
+}
+
+// newOpaqueToken returns a random bearer secret (a refresh token or a mailed
+// link token) and the hash stored for it.
+func newOpaqueToken() (raw, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	raw = base64.RawURLEncoding.EncodeToString(b)
+	return raw, hashOpaqueToken(raw), nil
+}
+
+func hashOpaqueToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// accessClaims builds the claims of an access token for session rt, stamped
+// with the user's (and station's) current token versions.
+func (h *Handler) accessClaims(c echo.Context, rt *models.RefreshToken, role string) (*models.JWTCustomClaims, error) {
+	userVersion, stationVersion, err := h.Store.GetTokenVersions(c.Request().Context(), rt.UserID, rt.StationID)
+	if err != nil {
+		return nil, err
+	}
+	claims := &models.JWTCustomClaims{
+		UserID:       rt.UserID.String(),
+		TenantID:     rt.TenantID.String(),
+		Role:         role,
+		TokenVersion: userVersion,
+		SessionID:    rt.FamilyID.String(),
+		TwoFactor:    rt.TwoFactor,
+	}
+	if rt.StationID != nil {
+		claims.StationID = rt.StationID.String()
+		claims.StationTokenVersion = stationVersion
+	}
+	return claims, nil
+}
+
+// startSession opens a new refresh token family for userID in tenantID
+// (bound to stationID for a provisioned station phone) and returns its
+// first access and refresh tokens. twoFactor marks a session signed in
+// with a second factor.
+func (h *Handler) startSession(c echo.Context, userID, tenantID uuid.UUID, role string, stationID *uuid.UUID, twoFactor bool) (*sessionTokens, error) {
+	raw, hash, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	ip, ua := c.RealIP(), c.Request().UserAgent()
+	rt := &models.RefreshToken{
+		TokenHash: hash,
+		UserID:    userID,
+		TenantID:  tenantID,
+		StationID: stationID,
+		TwoFactor: twoFactor,
+		ExpiresAt: time.Now().Add(config.RefreshTokenTTL()),
+		IPAddress: &ip,
+		UserAgent: &ua,
+	}
+	if err := h.Store.CreateRefreshToken(c.Request().Context(), rt); err != nil {
+		return nil, err
+	}
+	claims, err := h.accessClaims(c, rt, role)
+	if err != nil {
+		return nil, err
+	}
+	token, expiresAt, err := signAccessToken(claims, config.AccessTokenTTL())
+	if err != nil {
+		return nil, err
+	}
+	return &sessionTokens{Token: token, RefreshToken: raw, ExpiresAt: expiresAt}, nil
+}
+
+// RefreshSession serves POST /auth/refresh: it rotates the presented
+// refresh token and returns a new access token and refresh token. The role
+// is re-read from the tenant membership, so a demotion takes effect at the
+// next refresh and a removed member cannot refresh at all.
+func (h *Handler) RefreshSession(c echo.Context) error {
+	var req RefreshRequest
+	if err := c.Bind(&req); err != nil || req.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "refresh_token is required"})
+	}
+	ctx := c.Request().Context()
+
+	raw, hash, err := newOpaqueToken()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+	}
+	ip, ua := c.RealIP(), c.Request().UserAgent()
+	next := &models.RefreshToken{
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(config.RefreshTokenTTL()),
+		IPAddress: &ip,
+		UserAgent: &ua,
+	}
+	err = h.Store.RotateRefreshToken(ctx, hashOpaqueToken(req.RefreshToken), next)
+	switch {
+	case errors.Is(err, store.ErrRefreshTokenReused):
+		log.Printf("Refresh token reuse detected for user %s, session %s revoked", next.UserID, next.FamilyID)
+		return invalidRefreshToken(c)
+	case errors.Is(err, store.ErrRefreshTokenInvalid):
+		return invalidRefreshToken(c)
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to refresh session"})
+	}
+
+	role, err := h.Store.GetUserTenantRole(ctx, next.UserID, next.TenantID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && role == "") {
+		if err := h.Store.RevokeRefreshTokenFamily(ctx, next.UserID, next.FamilyID); err != nil {
+			log.Printf("Failed to revoke session %s of removed member: %v", next.FamilyID, err)
+		}
+		return invalidRefreshToken(c)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to refresh session"})
+	}
+
+	claims, err := h.accessClaims(c, next, role)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to refresh session"})
+	}
+	token, expiresAt, err := signAccessToken(claims, config.AccessTokenTTL())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+	}
+	return c.JSON(http.StatusOK, sessionTokens{Token: token, RefreshToken: raw, ExpiresAt: expiresAt})
+}
+
+func invalidRefreshToken(c echo.Context) error {
+	return c.JSON(http.StatusUnauthorized, map[string]string{"code": "refresh_token_invalid", "error": "Invalid refresh token"})
+}
+
+// Logout serves POST /api/auth/logout: it revokes the caller's session, so
+// its refresh token stops working and the JWT middleware rejects its access
+// tokens within config.TokenVersionCacheTTL. To cut every session off at
+// once, use RevokeUserSessions.
+func (h *Handler) Logout(c echo.Context) error {
+	claims, err := claimsFromContext(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid token"})
+	}
+	// Tokens issued before sessions existed (and impersonation tokens)
+	// carry no session; there is nothing server-side to end.
+	if sessionID, err := uuid.Parse(claims.SessionID); err == nil {
+		if err := h.Store.RevokeRefreshTokenFamily(c.Request().Context(), userID, sessionID); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to log out"})
+		}
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RevokeUserSessions serves POST /api/users/:id/revoke-sessions: every
+// access token and refresh token of the user stops working, in every
+// organization, within config.TokenVersionCacheTTL. Tenant admins may
+// revoke any member of their tenant; anyone may revoke their own.
+func (h *Handler) RevokeUserSessions(c echo.Context) error {
+	claims, err := claimsFromContext(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+	tenantID, err := tenantIDFromContext(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	if userID.String() != claims.UserID {
+		if claims.Role != "admin" {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "Admin access required"})
+		}
+		role, err := h.Store.GetUserTenantRole(c.Request().Context(), userID, tenantID)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && role == "") {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify user membership"})
+		}
+	}
+
+	n, err := h.Store.RevokeUserSessions(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke sessions"})
+	}
+	h.logTenantAction(c, nil, auditRevokeUserSessions, "user", userID, map[string]interface{}{"revoked_sessions": n})
+	return c.JSON(http.StatusOK, map[string]int64{"revoked_sessions": n})
+}
+
+// RevokeStation serves POST /api/events/:event_id/stations/:station_id/revoke:
+// a lost or retired station phone is signed out for good. Its tokens stop
+// working within config.TokenVersionCacheTTL; the staff user's other
+// sessions are untouched.
+func (h *Handler) RevokeStation(c echo.Context) error {
+	eventID, err := uuid.Parse(c.Param("event_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid event ID"})
+	}
+	stationID, err := uuid.Parse(c.Param("station_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid station ID"})
+	}
+	if _, err := h.requireEventOwnership(c, eventID); err != nil {
+		return writeErr(c, err)
+	}
+	if err := h.requirePermission(c, PermStationsManage, eventID); err != nil {
+		return writeErr(c, err)
+	}
+
+	n, err := h.Store.RevokeStationSessions(c.Request().Context(), eventID, stationID)
+	if errors.Is(err, store.ErrStationNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Station not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke station"})
+	}
+	h.logTenantAction(c, &eventID, auditRevokeStation, "station", stationID, map[string]interface{}{"revoked_sessions": n})
+	h.pushStationLogout(c.Request().Context(), eventID, stationID)
+	return c.JSON(http.StatusOK, map[string]int64{"revoked_sessions": n})
+}
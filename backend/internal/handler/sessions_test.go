@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+func parseAccessToken(t *testing.T, token string) *models.JWTCustomClaims {
+	t.Helper()
+	claims := &models.JWTCustomClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte("test-secret"), nil
+	}); err != nil {
+		t.Fatalf("parse access token: %v", err)
+	}
+	return claims
+}
+
+// Login opens a session: the stored refresh token is hashed, and the access
+// token carries the user's token version and the session it belongs to.
+func TestLoginStartsSession(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	user := contractUser("a@b.c")
+	tenant := contractTenant("Acme")
+	user.TenantID = tenant.ID
+	fs := &fakeStore{
+		getUserByEmail:    func(string) (*models.User, error) { return user, nil },
+		getUserTenants:    func(uuid.UUID) ([]*models.Tenant, error) { return []*models.Tenant{tenant}, nil },
+		getUserTenantRole: func(_, _ uuid.UUID) (string, error) { return "admin", nil },
+		getTokenVersions:  func(uuid.UUID, *uuid.UUID) (int, int, error) { return 4, -1, nil },
+	}
+	h := New(fs)
+	c, rec := newUnauthedContext(echo.New(), http.MethodPost, "/auth/login", `{"email":"a@b.c","password":"secret123"}`)
+	if err := h.Login(c); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	var body struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := jsonUnmarshalBody(rec, &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(fs.refreshTokens) != 1 {
+		t.Fatalf("refresh tokens stored = %d, want 1", len(fs.refreshTokens))
+	}
+	rt := fs.refreshTokens[0]
+	if body.RefreshToken == "" || rt.TokenHash != hashOpaqueToken(body.RefreshToken) || rt.TokenHash == body.RefreshToken {
+		t.Errorf("stored hash %q does not match issued token %q", rt.TokenHash, body.RefreshToken)
+	}
+	claims := parseAccessToken(t, body.Token)
+	if claims.TokenVersion != 4 || claims.SessionID != rt.FamilyID.String() || rt.UserID != user.ID || rt.TenantID != tenant.ID {
+		t.Errorf("claims = %+v, refresh token = %+v", claims, rt)
+	}
+}
+
+func TestContractRefreshSession(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	userID, tenantID, familyID := uuid.New(), uuid.New(), uuid.New()
+	var presented string
+	rotate := func(oldHash string, next *models.RefreshToken) error {
+		presented = oldHash
+		next.ID, next.FamilyID, next.UserID, next.TenantID = uuid.New(), familyID, userID, tenantID
+		return nil
+	}
+	h := New(&fakeStore{
+		rotateRefreshToken: rotate,
+		getUserTenantRole:  func(_, _ uuid.UUID) (string, error) { return "manager", nil },
+		getTokenVersions:   func(uuid.UUID, *uuid.UUID) (int, int, error) { return 2, -1, nil },
+	})
+	e := echo.New()
+
+	c, rec := newUnauthedContext(e, http.MethodPost, "/auth/refresh", `{"refresh_token":"old-token"}`)
+	if err := h.RefreshSession(c); err != nil {
+		t.Fatalf("RefreshSession: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodPost, "/auth/refresh", rec)
+	if presented != hashOpaqueToken("old-token") {
+		t.Errorf("rotated hash = %q, want the presented token's hash", presented)
+	}
+	var tokens sessionTokens
+	if err := jsonUnmarshalBody(rec, &tokens); err != nil {
+		t.Fatal(err)
+	}
+	claims := parseAccessToken(t, tokens.Token)
+	if claims.Role != "manager" || claims.TokenVersion != 2 || claims.SessionID != familyID.String() || tokens.RefreshToken == "old-token" {
+		t.Errorf("refreshed claims = %+v", claims)
+	}
+
+	c, rec = newUnauthedContext(e, http.MethodPost, "/auth/refresh", `{}`)
+	if err := h.RefreshSession(c); err != nil {
+		t.Fatalf("RefreshSession: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("empty body: want 400, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodPost, "/auth/refresh", rec)
+
+	for _, storeErr := range []error{store.ErrRefreshTokenReused, store.ErrRefreshTokenInvalid} {
+		hBad := New(&fakeStore{rotateRefreshToken: func(string, *models.RefreshToken) error { return storeErr }})
+		c, rec = newUnauthedContext(e, http.MethodPost, "/auth/refresh", `{"refresh_token":"spent"}`)
+		if err := hBad.RefreshSession(c); err != nil {
+			t.Fatalf("RefreshSession: %v", err)
+		}
+		if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "refresh_token_invalid") {
+			t.Fatalf("%v: want 401 refresh_token_invalid, got %d %s", storeErr, rec.Code, rec.Body.String())
+		}
+		validateResponse(t, http.MethodPost, "/auth/refresh", rec)
+	}
+
+	// A user removed from the organization cannot refresh, and the session
+	// is closed for good.
+	var revoked uuid.UUID
+	hRemoved := New(&fakeStore{
+		rotateRefreshToken:       rotate,
+		getUserTenantRole:        func(_, _ uuid.UUID) (string, error) { return "", pgx.ErrNoRows },
+		revokeRefreshTokenFamily: func(_, family uuid.UUID) error { revoked = family; return nil },
+	})
+	c, rec = newUnauthedContext(e, http.MethodPost, "/auth/refresh", `{"refresh_token":"old-token"}`)
+	if err := hRemoved.RefreshSession(c); err != nil {
+		t.Fatalf("RefreshSession: %v", err)
+	}
+	if rec.Code != http.StatusUnauthorized || revoked != familyID {
+		t.Fatalf("removed member: code=%d revoked=%s, want 401 and family %s revoked", rec.Code, revoked, familyID)
+	}
+
+	hFail := New(&fakeStore{rotateRefreshToken: func(string, *models.RefreshToken) error { return errors.New("db down") }})
+	c, rec = newUnauthedContext(e, http.MethodPost, "/auth/refresh", `{"refresh_token":"x"}`)
+	if err := hFail.RefreshSession(c); err != nil {
+		t.Fatalf("RefreshSession: %v", err)
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("want 500, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodPost, "/auth/refresh", rec)
+}
+
+func TestContractLogout(t *testing.T) {
+	sessionID := uuid.New()
+	var revoked uuid.UUID
+	h := New(&fakeStore{
+		revokeRefreshTokenFamily: func(_, family uuid.UUID) error { revoked = family; return nil },
+	})
+	e := echo.New()
+
+	c, rec := newAuthedContext(e, http.MethodPost, "/api/auth/logout", "", uuid.NewString(), "staff")
+	c.Get("user").(*models.JWTCustomClaims).SessionID = sessionID.String()
+	if err := h.Logout(c); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if rec.Code != http.StatusNoContent || revoked != sessionID {
+		t.Fatalf("code=%d revoked=%s, want 204 and session %s revoked", rec.Code, revoked, sessionID)
+	}
+	validateResponse(t, http.MethodPost, "/api/auth/logout", rec)
+
+	hFail := New(&fakeStore{
+		revokeRefreshTokenFamily: func(_, _ uuid.UUID) error { return errors.New("db down") },
+	})
+	c, rec = newAuthedContext(e, http.MethodPost, "/api/auth/logout", "", uuid.NewString(), "staff")
+	c.Get("user").(*models.JWTCustomClaims).SessionID = sessionID.String()
+	if err := hFail.Logout(c); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("want 500, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodPost, "/api/auth/logout", rec)
+}
+
+func TestContractRevokeUserSessions(t *testing.T) {
+	tenantID, target := uuid.New(), uuid.New()
+	fs := &fakeStore{
+		getUserTenantRole:  func(_, _ uuid.UUID) (string, error) { return "staff", nil },
+		revokeUserSessions: func(uuid.UUID) (int64, error) { return 3, nil },
+	}
+	h := New(fs)
+	e := echo.New()
+	path := "/api/users/" + target.String() + "/revoke-sessions"
+	call := func(h *Handler, id, role string) *int {
+		c, rec := newAuthedContext(e, http.MethodPost, "/api/users/"+id+"/revoke-sessions", "", tenantID.String(), role)
+		c.SetPath("/api/users/:id/revoke-sessions")
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		if err := h.RevokeUserSessions(c); err != nil {
+			t.Fatalf("RevokeUserSessions: %v", err)
+		}
+		validateResponse(t, http.MethodPost, path, rec)
+		return &rec.Code
+	}
+
+	if code := call(h, target.String(), "admin"); *code != http.StatusOK {
+		t.Fatalf("admin: want 200, got %d", *code)
+	}
+	if len(fs.tenantAudit) != 1 || fs.tenantAudit[0].Action != auditRevokeUserSessions || *fs.tenantAudit[0].TargetID != target {
+		t.Errorf("tenant audit = %+v, want one revoke_user_sessions entry for the target", fs.tenantAudit)
+	}
+	if code := call(h, target.String(), "manager"); *code != http.StatusForbidden {
+		t.Fatalf("manager revoking someone else: want 403, got %d", *code)
+	}
+	if code := call(h, "nope", "admin"); *code != http.StatusBadRequest {
+		t.Fatalf("bad id: want 400, got %d", *code)
+	}
+
+	hStranger := New(&fakeStore{getUserTenantRole: func(_, _ uuid.UUID) (string, error) { return "", pgx.ErrNoRows }})
+	if code := call(hStranger, target.String(), "admin"); *code != http.StatusNotFound {
+		t.Fatalf("non-member: want 404, got %d", *code)
+	}
+
+	hFail := New(&fakeStore{
+		getUserTenantRole:  func(_, _ uuid.UUID) (string, error) { return "staff", nil },
+		revokeUserSessions: func(uuid.UUID) (int64, error) { return 0, errors.New("db down") },
+	})
+	if code := call(hFail, target.String(), "admin"); *code != http.StatusInternalServerError {
+		t.Fatalf("store failure: want 500, got %d", *code)
+	}
+}
+
+// Anyone may sign themselves out everywhere, without a membership lookup.
+func TestRevokeUserSessions_Self(t *testing.T) {
+	tenantID, self := uuid.New(), uuid.New()
+	var revoked uuid.UUID
+	h := New(&fakeStore{revokeUserSessions: func(id uuid.UUID) (int64, error) { revoked = id; return 1, nil }})
+	c, rec := newAuthedContextWithUserID(echo.New(), http.MethodPost, "/api/users/"+self.String()+"/revoke-sessions", "", tenantID.String(), self, "staff")
+	c.SetParamNames("id")
+	c.SetParamValues(self.String())
+	if err := h.RevokeUserSessions(c); err != nil {
+		t.Fatalf("RevokeUserSessions: %v", err)
+	}
+	if rec.Code != http.StatusOK || revoked != self {
+		t.Fatalf("code=%d revoked=%s, want 200 and %s", rec.Code, revoked, self)
+	}
+}
+
+func TestContractRevokeStation(t *testing.T) {
+	tenantID, stationID := uuid.New(), uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	e := echo.New()
+	path := "/api/events/" + event.ID.String() + "/stations/" + stationID.String() + "/revoke"
+	call := func(h *Handler, station, role string) int {
+		c, rec := newAuthedContext(e, http.MethodPost, "/api/events/"+event.ID.String()+"/stations/"+station+"/revoke", "", tenantID.String(), role)
+		c.SetPath("/api/events/:event_id/stations/:station_id/revoke")
+		c.SetParamNames("event_id", "station_id")
+		c.SetParamValues(event.ID.String(), station)
+		if err := h.RevokeStation(c); err != nil {
+			t.Fatalf("RevokeStation: %v", err)
+		}
+		validateResponse(t, http.MethodPost, path, rec)
+		return rec.Code
+	}
+	storeWith := func(revoke func(eventID, stationID uuid.UUID) (int64, error)) *fakeStore {
+		return &fakeStore{
+			getEventByID:          func(uuid.UUID) (*models.Event, error) { return event, nil },
+			revokeStationSessions: revoke,
+		}
+	}
+
+	var gotEvent, gotStation uuid.UUID
+	fs := storeWith(func(eid, sid uuid.UUID) (int64, error) { gotEvent, gotStation = eid, sid; return 1, nil })
+	if code := call(New(fs), stationID.String(), "manager"); code != http.StatusOK {
+		t.Fatalf("want 200, got %d", code)
+	}
+	if gotEvent != event.ID || gotStation != stationID {
+		t.Errorf("revoked %s/%s, want %s/%s", gotEvent, gotStation, event.ID, stationID)
+	}
+	if len(fs.tenantAudit) != 1 || fs.tenantAudit[0].Action != auditRevokeStation {
+		t.Errorf("tenant audit = %+v, want one revoke_station entry", fs.tenantAudit)
+	}
+
+	if code := call(New(fs), "nope", "admin"); code != http.StatusBadRequest {
+		t.Fatalf("bad station id: want 400, got %d", code)
+	}
+	if code := call(New(fs), stationID.String(), "staff"); code != http.StatusForbidden {
+		t.Fatalf("staff: want 403, got %d", code)
+	}
+	missing := storeWith(func(uuid.UUID, uuid.UUID) (int64, error) { return 0, store.ErrStationNotFound })
+	if code := call(New(missing), stationID.String(), "admin"); code != http.StatusNotFound {
+		t.Fatalf("unknown station: want 404, got %d", code)
+	}
+	failing := storeWith(func(uuid.UUID, uuid.UUID) (int64, error) { return 0, errors.New("db down") })
+	if code := call(New(failing), stationID.String(), "admin"); code != http.StatusInternalServerError {
+		t.Fatalf("store failure: want 500, got %d", code)
+	}
+}
@@ -0,0 +1,398 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"idento/backend/internal/config"
+	"idento/backend/internal/models"
+	"idento/backend/internal/oidc"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// Audit actions for single sign-on configuration (see audit.go).
+const (
+	auditUpdateSSOProvider = "update_sso_provider"
+	auditDeleteSSOProvider = "delete_sso_provider"
+)
+
+// oidcLoginTTL bounds how long a user may take at the identity provider.
+const oidcLoginTTL = 10 * time.Minute
+
+// defaultOIDCClient serves handlers without an injected OIDC client; one
+// shared client keeps the provider metadata cache warm.
+var defaultOIDCClient = oidc.NewClient(nil)
+
+// oidcClient returns the configured OIDC client; nil-safe like Broker.
+func (h *Handler) oidcClient() *oidc.Client {
+	if h.OIDC == nil {
+		return defaultOIDCClient
+	}
+	return h.OIDC
+}
+
+// ssoCallbackURL is the single redirect URI every tenant registers at its
+// provider; the state parameter identifies the tenant.
+func ssoCallbackURL() string {
+	return config.PublicAPIURL() + "/auth/oidc/callback"
+}
+
+func oidcConfig(p *models.TenantOIDCProvider) oidc.Config {
+	return oidc.Config{
+		Issuer:       p.Issuer,
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  ssoCallbackURL(),
+	}
+}
+
+// ssoAppRedirect sends the browser back to the web app. The result rides
+// in the URL fragment, which browsers neither send to servers nor leak in
+// Referer headers.
+func ssoAppRedirect(c echo.Context, fragment url.Values) error {
+	return c.Redirect(http.StatusFound, config.AppURL()+"/sso/callback#"+fragment.Encode())
+}
+
+func ssoFailure(c echo.Context, code string) error {
+	return ssoAppRedirect(c, url.Values{"error": {code}})
+}
+
+// emailDomain returns the lowercase domain of an email address, or "".
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 1 || at == len(email)-1 {
+		return ""
+	}
+	return strings.ToLower(email[at+1:])
+}
+
+// roleRank orders roles by privilege for picking the strongest mapped one.
+var roleRank = map[string]int{"staff": 1, "manager": 2, "admin": 3}
+
+// mapOIDCRole picks the most privileged role that the provider's role
+// claim maps to, or the provider's default role.
+func mapOIDCRole(p *models.TenantOIDCProvider, tok *oidc.IDToken) string {
+	role := p.DefaultRole
+	if p.RoleClaim == "" {
+		return role
+	}
+	best := ""
+	for _, v := range oidc.StringsClaim(tok.Claims[p.RoleClaim]) {
+		if mapped, ok := p.RoleMapping[v]; ok && roleRank[mapped] > roleRank[best] {
+			best = mapped
+		}
+	}
+	if best != "" {
+		return best
+	}
+	return role
+}
+
+// DiscoverSSO serves GET /auth/oidc/discover?email=: the organizations that
+// offer single sign-on for the email's domain, so the login page can offer
+// "Continue with SSO".
+func (h *Handler) DiscoverSSO(c echo.Context) error {
+	domain := emailDomain(strings.TrimSpace(c.QueryParam("email")))
+	if domain == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "A valid email is required"})
+	}
+	tenants, err := h.Store.FindSSOTenantsByEmailDomain(c.Request().Context(), domain)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to look up single sign-on"})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"providers": tenants})
+}
+
+// StartSSO serves GET /auth/oidc/:tenant_id/login: it redirects the
+// browser to the tenant's identity provider with a fresh state, nonce and
+// PKCE challenge. The verifier stays server-side until the callback.
+func (h *Handler) StartSSO(c echo.Context) error {
+	tenantID, err := uuid.Parse(c.Param("tenant_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid tenant ID"})
+	}
+	ctx := c.Request().Context()
+	p, err := h.Store.GetTenantOIDCProvider(ctx, tenantID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load single sign-on"})
+	}
+	if p == nil || !p.Enabled {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Single sign-on is not configured for this organization"})
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start single sign-on"})
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start single sign-on"})
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start single sign-on"})
+	}
+	authURL, err := h.oidcClient().AuthCodeURL(ctx, oidcConfig(p), state, nonce, challenge)
+	if err != nil {
+		log.Printf("SSO discovery for tenant %s failed: %v", tenantID, err)
+		return c.JSON(http.StatusBadGateway, map[string]string{"error": "The identity provider could not be reached"})
+	}
+	if err := h.Store.CreateOIDCLoginRequest(ctx, &models.OIDCLoginRequest{
+		StateHash:    hashOpaqueToken(state),
+		TenantID:     tenantID,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(oidcLoginTTL),
+	}); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start single sign-on"})
+	}
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// SSOCallback serves GET /auth/oidc/callback, where the identity provider
+// sends the browser back. It redeems the code, provisions or links the
+// user and their membership, starts a session and redirects to
+// {APP_URL}/sso/callback#refresh_token=... for the web app to exchange at
+// POST /auth/refresh. Failures redirect with #error=<code> instead.
+func (h *Handler) SSOCallback(c echo.Context) error {
+	ctx := c.Request().Context()
+	state := c.QueryParam("state")
+	if state == "" {
+		return ssoFailure(c, "login_expired")
+	}
+	req, err := h.Store.ConsumeOIDCLoginRequest(ctx, hashOpaqueToken(state))
+	if errors.Is(err, store.ErrOIDCLoginRequestInvalid) {
+		return ssoFailure(c, "login_expired")
+	}
+	if err != nil {
+		log.Printf("SSO callback: %v", err)
+		return ssoFailure(c, "server_error")
+	}
+	if c.QueryParam("error") != "" || c.QueryParam("code") == "" {
+		return ssoFailure(c, "access_denied")
+	}
+
+	p, err := h.Store.GetTenantOIDCProvider(ctx, req.TenantID)
+	if err != nil {
+		log.Printf("SSO callback: %v", err)
+		return ssoFailure(c, "server_error")
+	}
+	if p == nil || !p.Enabled {
+		return ssoFailure(c, "sso_disabled")
+	}
+	tok, err := h.oidcClient().Exchange(ctx, oidcConfig(p), c.QueryParam("code"), req.CodeVerifier, req.Nonce)
+	if err != nil {
+		log.Printf("SSO code exchange for tenant %s failed: %v", req.TenantID, err)
+		return ssoFailure(c, "provider_error")
+	}
+	email := strings.ToLower(strings.TrimSpace(tok.Email))
+	if !slices.Contains(p.AllowedDomains, emailDomain(email)) {
+		return ssoFailure(c, "domain_not_allowed")
+	}
+
+	user, role, err := h.Store.LinkOIDCIdentity(ctx, models.OIDCIdentity{
+		TenantID:      req.TenantID,
+		Issuer:        p.Issuer,
+		Subject:       tok.Subject,
+		Email:         email,
+		EmailVerified: tok.EmailVerified,
+		Role:          mapOIDCRole(p, tok),
+		SyncRole:      p.RoleClaim != "",
+	})
+	switch {
+	case errors.Is(err, store.ErrOIDCEmailUnverified):
+		return ssoFailure(c, "email_unverified")
+	case errors.Is(err, store.ErrOIDCAccountConflict):
+		return ssoFailure(c, "account_conflict")
+	case errors.Is(err, store.ErrOIDCMemberDeactivated):
+		return ssoFailure(c, "account_deactivated")
+	case err != nil:
+		log.Printf("SSO link for tenant %s failed: %v", req.TenantID, err)
+		return ssoFailure(c, "server_error")
+	}
+
+	// A provider that reports multi-factor sign-in (RFC 8176 "mfa")
+	// satisfies the tenant's two-factor policy.
+	session, err := h.startSession(c, user.ID, req.TenantID, role, nil, slices.Contains(tok.AMR, "mfa"))
+	if err != nil {
+		return ssoFailure(c, "server_error")
+	}
+	return ssoAppRedirect(c, url.Values{"refresh_token": {session.RefreshToken}})
+}
+
+// ssoProviderView is the API shape of a tenant's SSO configuration: the
+// client secret is never returned.
+func ssoProviderView(p *models.TenantOIDCProvider) map[string]interface{} {
+	return map[string]interface{}{
+		"issuer":            p.Issuer,
+		"client_id":         p.ClientID,
+		"has_client_secret": p.ClientSecret != "",
+		"allowed_domains":   p.AllowedDomains,
+		"role_claim":        p.RoleClaim,
+		"role_mapping":      p.RoleMapping,
+		"default_role":      p.DefaultRole,
+		"enabled":           p.Enabled,
+		"redirect_uri":      ssoCallbackURL(),
+		"login_url":         config.PublicAPIURL() + "/auth/oidc/" + p.TenantID.String() + "/login",
+		"updated_at":        p.UpdatedAt,
+	}
+}
+
+// GetSSOProvider serves GET /api/sso: the current organization's single
+// sign-on configuration (admin only).
+func (h *Handler) GetSSOProvider(c echo.Context) error {
+	tenantID, err := requireTenantAdmin(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	p, err := h.Store.GetTenantOIDCProvider(c.Request().Context(), tenantID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load single sign-on"})
+	}
+	if p == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Single sign-on is not configured"})
+	}
+	return c.JSON(http.StatusOK, ssoProviderView(p))
+}
+
+// SSOProviderRequest is the JSON body for PUT /api/sso.
+type SSOProviderRequest struct {
+	Issuer string `json:"issuer"`
+	// ClientSecret may be omitted to keep the stored one.
+	ClientID       string            `json:"client_id"`
+	ClientSecret   string            `json:"client_secret"`
+	AllowedDomains []string          `json:"allowed_domains"`
+	RoleClaim      string            `json:"role_claim"`
+	RoleMapping    map[string]string `json:"role_mapping"`
+	DefaultRole    string            `json:"default_role"`
+	Enabled        *bool             `json:"enabled"`
+}
+
+// validIssuer accepts https issuers on non-loopback hosts. With
+// allowLoopback (see Handler.AllowLoopbackSSOIssuers) it also accepts
+// loopback hosts, over http too: a local mock provider during development.
+func validIssuer(raw string, allowLoopback bool) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return false
+	}
+	host := u.Hostname()
+	loopback := host == "localhost" || strings.HasSuffix(host, ".localhost")
+	if ip := net.ParseIP(host); ip != nil {
+		loopback = ip.IsLoopback() || ip.IsUnspecified()
+	}
+	if loopback {
+		return allowLoopback && (u.Scheme == "https" || u.Scheme == "http")
+	}
+	return u.Scheme == "https"
+}
+
+// PutSSOProvider serves PUT /api/sso: it creates or replaces the current
+// organization's single sign-on configuration (admin only). The issuer's
+// discovery document must be reachable.
+func (h *Handler) PutSSOProvider(c echo.Context) error {
+	tenantID, err := requireTenantAdmin(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	req := new(SSOProviderRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	p := &models.TenantOIDCProvider{
+		TenantID:     tenantID,
+		Issuer:       strings.TrimRight(strings.TrimSpace(req.Issuer), "/"),
+		ClientID:     strings.TrimSpace(req.ClientID),
+		ClientSecret: req.ClientSecret,
+		RoleClaim:    strings.TrimSpace(req.RoleClaim),
+		RoleMapping:  req.RoleMapping,
+		DefaultRole:  req.DefaultRole,
+		Enabled:      req.Enabled == nil || *req.Enabled,
+	}
+	if p.DefaultRole == "" {
+		p.DefaultRole = "staff"
+	}
+	if !validIssuer(p.Issuer, h.AllowLoopbackSSOIssuers) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "issuer must be an https URL"})
+	}
+	if p.ClientID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "client_id is required"})
+	}
+	if _, ok := roleRank[p.DefaultRole]; !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "default_role must be admin, manager or staff"})
+	}
+	for value, role := range p.RoleMapping {
+		if _, ok := roleRank[role]; !ok || value == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "role_mapping values must be admin, manager or staff"})
+		}
+	}
+	for _, d := range req.AllowedDomains {
+		d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+		if d == "" || strings.ContainsAny(d, "@/ ") || !strings.Contains(d, ".") {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "allowed_domains must be email domains like example.com"})
+		}
+		if !slices.Contains(p.AllowedDomains, d) {
+			p.AllowedDomains = append(p.AllowedDomains, d)
+		}
+	}
+	if len(p.AllowedDomains) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "allowed_domains is required"})
+	}
+
+	ctx := c.Request().Context()
+	existing, err := h.Store.GetTenantOIDCProvider(ctx, tenantID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load single sign-on"})
+	}
+	if existing == nil && p.ClientSecret == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "client_secret is required"})
+	}
+	if _, err := h.oidcClient().Discover(ctx, p.Issuer); err != nil {
+		log.Printf("SSO discovery for tenant %s at %s failed: %v", tenantID, p.Issuer, err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"code": "issuer_unreachable", "error": "OpenID discovery failed for issuer"})
+	}
+	if err := h.Store.UpsertTenantOIDCProvider(ctx, p); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save single sign-on"})
+	}
+	if p.ClientSecret == "" && existing != nil {
+		p.ClientSecret = existing.ClientSecret
+	}
+
+	view := ssoProviderView(p)
+	changes := map[string]interface{}{}
+	for k, v := range view {
+		if k != "updated_at" && k != "redirect_uri" && k != "login_url" {
+			changes[k] = v
+		}
+	}
+	h.logTenantAction(c, nil, auditUpdateSSOProvider, "tenant", tenantID, changes)
+	return c.JSON(http.StatusOK, view)
+}
+
+// DeleteSSOProvider serves DELETE /api/sso: single sign-on is switched off
+// and its identity links are dropped (admin only). Users created by SSO
+// keep their accounts but need a password reset to sign in.
+func (h *Handler) DeleteSSOProvider(c echo.Context) error {
+	tenantID, err := requireTenantAdmin(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	found, err := h.Store.DeleteTenantOIDCProvider(c.Request().Context(), tenantID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete single sign-on"})
+	}
+	if !found {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Single sign-on is not configured"})
+	}
+	h.logTenantAction(c, nil, auditDeleteSSOProvider, "tenant", tenantID, nil)
+	return c.NoContent(http.StatusNoContent)
+}
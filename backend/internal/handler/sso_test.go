@@ -0,0 +1,426 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"idento/backend/internal/config"
+	"idento/backend/internal/models"
+	"idento/backend/internal/oidc"
+	"idento/backend/internal/oidc/oidctest"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// ssoFixture wires a fakeStore to a running mock identity provider. Login
+// requests are kept in memory so the state round-trips like in Postgres.
+type ssoFixture struct {
+	idp      *oidctest.Provider
+	provider *models.TenantOIDCProvider
+	fs       *fakeStore
+	linked   []models.OIDCIdentity
+	user     *models.User
+}
+
+func newSSOFixture(t *testing.T) *ssoFixture {
+	t.Helper()
+	idp, srv, err := oidctest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	tenant := contractTenant("Acme")
+	f := &ssoFixture{
+		idp: idp,
+		provider: &models.TenantOIDCProvider{
+			TenantID:       tenant.ID,
+			Issuer:         idp.Issuer,
+			ClientID:       idp.ClientID,
+			ClientSecret:   idp.ClientSecret,
+			AllowedDomains: []string{"corp.example"},
+			RoleClaim:      "groups",
+			RoleMapping:    map[string]string{"event-admins": "manager", "it": "admin"},
+			DefaultRole:    "staff",
+			Enabled:        true,
+			UpdatedAt:      time.Now(),
+		},
+		user: contractUser("anna@corp.example"),
+	}
+	f.user.TenantID = tenant.ID
+	requests := map[string]*models.OIDCLoginRequest{}
+	f.fs = &fakeStore{
+		getTenantOIDCProvider: func(uuid.UUID) (*models.TenantOIDCProvider, error) { return f.provider, nil },
+		createOIDCLoginRequest: func(r *models.OIDCLoginRequest) error {
+			requests[r.StateHash] = r
+			return nil
+		},
+		consumeOIDCLoginRequest: func(stateHash string) (*models.OIDCLoginRequest, error) {
+			r, ok := requests[stateHash]
+			if !ok {
+				return nil, store.ErrOIDCLoginRequestInvalid
+			}
+			delete(requests, stateHash)
+			return r, nil
+		},
+		linkOIDCIdentity: func(id models.OIDCIdentity) (*models.User, string, error) {
+			f.linked = append(f.linked, id)
+			return f.user, id.Role, nil
+		},
+	}
+	return f
+}
+
+// signIn runs StartSSO, lets the mock provider approve, and returns the
+// callback's redirect to the web app.
+func (f *ssoFixture) signIn(t *testing.T, h *Handler) *url.URL {
+	t.Helper()
+	e := echo.New()
+	c, rec := newUnauthedContext(e, http.MethodGet, "/auth/oidc/"+f.provider.TenantID.String()+"/login", "")
+	c.SetParamNames("tenant_id")
+	c.SetParamValues(f.provider.TenantID.String())
+	if err := h.StartSSO(c); err != nil {
+		t.Fatalf("StartSSO: %v", err)
+	}
+	if rec.Code != http.StatusFound {
+		t.Fatalf("StartSSO: want 302, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodGet, "/auth/oidc/"+f.provider.TenantID.String()+"/login", rec)
+
+	back, err := oidctest.Follow(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if got := back.Scheme + "://" + back.Host + back.Path; got != ssoCallbackURL() {
+		t.Fatalf("provider redirected to %s, want %s", got, ssoCallbackURL())
+	}
+	return f.callback(t, h, back.RawQuery)
+}
+
+func (f *ssoFixture) callback(t *testing.T, h *Handler, rawQuery string) *url.URL {
+	t.Helper()
+	c, rec := newUnauthedContext(echo.New(), http.MethodGet, "/auth/oidc/callback?"+rawQuery, "")
+	if err := h.SSOCallback(c); err != nil {
+		t.Fatalf("SSOCallback: %v", err)
+	}
+	if rec.Code != http.StatusFound {
+		t.Fatalf("SSOCallback: want 302, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodGet, "/auth/oidc/callback", rec)
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(loc.String(), config.AppURL()+"/sso/callback#") {
+		t.Fatalf("callback redirected to %s", loc)
+	}
+	return loc
+}
+
+func fragment(t *testing.T, u *url.URL) url.Values {
+	t.Helper()
+	v, err := url.ParseQuery(u.Fragment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// The whole code flow against the mock provider: the user lands in the
+// web app with a refresh token for a session in the provider's tenant, with
+// the role mapped from the groups claim. The state cannot be replayed.
+func TestContractSSOLogin(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	f := newSSOFixture(t)
+	f.idp.SetUser(oidctest.User{
+		Subject: "u-42", Email: "Anna@Corp.example", EmailVerified: true,
+		Extra: map[string]interface{}{"groups": []string{"staff", "event-admins"}, "amr": []string{"pwd", "mfa"}},
+	})
+	h := New(f.fs)
+
+	back := f.signIn(t, h)
+	frag := fragment(t, back)
+	if frag.Get("error") != "" || frag.Get("refresh_token") == "" {
+		t.Fatalf("fragment = %v; want a refresh token", frag)
+	}
+	if len(f.linked) != 1 {
+		t.Fatalf("LinkOIDCIdentity called %d times", len(f.linked))
+	}
+	id := f.linked[0]
+	if id.Subject != "u-42" || id.Email != "anna@corp.example" || id.Issuer != f.idp.Issuer ||
+		id.Role != "manager" || !id.SyncRole || !id.EmailVerified || id.TenantID != f.provider.TenantID {
+		t.Errorf("identity = %+v", id)
+	}
+	if len(f.fs.refreshTokens) != 1 {
+		t.Fatalf("sessions = %d, want 1", len(f.fs.refreshTokens))
+	}
+	rt := f.fs.refreshTokens[0]
+	if rt.TokenHash != hashOpaqueToken(frag.Get("refresh_token")) || rt.TenantID != f.provider.TenantID || !rt.TwoFactor {
+		t.Errorf("session = %+v", rt)
+	}
+
+	// The provider's redirect cannot be replayed.
+	if frag := fragment(t, f.callback(t, h, "code=x&state=y")); frag.Get("error") != "login_expired" {
+		t.Errorf("replay fragment = %v; want login_expired", frag)
+	}
+}
+
+func TestSSOCallbackRejections(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	cases := []struct {
+		name  string
+		user  oidctest.User
+		link  error
+		setup func(f *ssoFixture)
+		want  string
+	}{
+		{name: "foreign domain", user: oidctest.User{Subject: "u", Email: "eve@evil.example", EmailVerified: true}, want: "domain_not_allowed"},
+		{name: "unverified email", user: oidctest.User{Subject: "u", Email: "anna@corp.example"}, link: store.ErrOIDCEmailUnverified, want: "email_unverified"},
+		{name: "other tenant", user: oidctest.User{Subject: "u", Email: "anna@corp.example", EmailVerified: true}, link: store.ErrOIDCAccountConflict, want: "account_conflict"},
+		{name: "deactivated member", user: oidctest.User{Subject: "u", Email: "anna@corp.example", EmailVerified: true}, link: store.ErrOIDCMemberDeactivated, want: "account_deactivated"},
+		{name: "wrong client secret", user: oidctest.User{Subject: "u", Email: "anna@corp.example", EmailVerified: true}, setup: func(f *ssoFixture) {
+			f.idp.ClientSecret = "rotated"
+		}, want: "provider_error"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newSSOFixture(t)
+			f.idp.SetUser(tc.user)
+			if tc.link != nil {
+				f.fs.linkOIDCIdentity = func(models.OIDCIdentity) (*models.User, string, error) { return nil, "", tc.link }
+			}
+			if tc.setup != nil {
+				tc.setup(f)
+			}
+			frag := fragment(t, f.signIn(t, New(f.fs)))
+			if frag.Get("error") != tc.want || frag.Get("refresh_token") != "" {
+				t.Errorf("fragment = %v; want error=%s", frag, tc.want)
+			}
+			if len(f.fs.refreshTokens) != 0 {
+				t.Errorf("a session was started")
+			}
+		})
+	}
+}
+
+func TestContractStartSSONotConfigured(t *testing.T) {
+	fs := &fakeStore{getTenantOIDCProvider: func(uuid.UUID) (*models.TenantOIDCProvider, error) { return nil, nil }}
+	tenantID := uuid.New().String()
+	c, rec := newUnauthedContext(echo.New(), http.MethodGet, "/auth/oidc/"+tenantID+"/login", "")
+	c.SetParamNames("tenant_id")
+	c.SetParamValues(tenantID)
+	if err := New(fs).StartSSO(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("want 404, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodGet, "/auth/oidc/"+tenantID+"/login", rec)
+}
+
+func TestContractDiscoverSSO(t *testing.T) {
+	var gotDomain string
+	fs := &fakeStore{findSSOTenantsByEmailDomain: func(domain string) ([]models.SSOTenant, error) {
+		gotDomain = domain
+		return []models.SSOTenant{{TenantID: uuid.New(), TenantName: "Acme"}}, nil
+	}}
+	h := New(fs)
+	e := echo.New()
+
+	c, rec := newUnauthedContext(e, http.MethodGet, "/auth/oidc/discover?email="+url.QueryEscape(" Anna@Corp.Example "), "")
+	if err := h.DiscoverSSO(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || gotDomain != "corp.example" {
+		t.Fatalf("got %d, domain %q", rec.Code, gotDomain)
+	}
+	validateResponse(t, http.MethodGet, "/auth/oidc/discover", rec)
+
+	c, rec = newUnauthedContext(e, http.MethodGet, "/auth/oidc/discover?email=anna", "")
+	if err := h.DiscoverSSO(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("want 400, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodGet, "/auth/oidc/discover", rec)
+}
+
+func TestMapOIDCRole(t *testing.T) {
+	p := &models.TenantOIDCProvider{
+		RoleClaim:   "roles",
+		RoleMapping: map[string]string{"ops": "manager", "it": "admin", "temps": "staff"},
+		DefaultRole: "staff",
+	}
+	cases := []struct {
+		claim interface{}
+		want  string
+	}{
+		{nil, "staff"},
+		{"ops", "manager"},
+		{[]interface{}{"temps", "it", "ops"}, "admin"},
+		{[]interface{}{"unknown"}, "staff"},
+	}
+	for _, tc := range cases {
+		tok := &oidc.IDToken{Claims: map[string]interface{}{"roles": tc.claim}}
+		if got := mapOIDCRole(p, tok); got != tc.want {
+			t.Errorf("mapOIDCRole(%v) = %s, want %s", tc.claim, got, tc.want)
+		}
+	}
+}
+
+// Loopback issuers are only for a local mock provider: without
+// AllowLoopbackSSOIssuers (SaaS mode) they are refused over any scheme.
+func TestValidIssuer(t *testing.T) {
+	cases := []struct {
+		issuer        string
+		allowLoopback bool
+		want          bool
+	}{
+		{"https://idp.example", false, true},
+		{"https://idp.example/realms/corp", false, true},
+		{"http://idp.example", false, false},
+		{"http://idp.example", true, false},
+		{"https://idp.example?x=1", false, false},
+		{"ftp://idp.example", false, false},
+		{"http://localhost:8080", false, false},
+		{"https://localhost", false, false},
+		{"http://127.0.0.1:8080", false, false},
+		{"https://127.0.0.2", false, false},
+		{"http://[::1]:8080", false, false},
+		{"http://0.0.0.0", false, false},
+		{"http://api.localhost", false, false},
+		{"http://localhost:8080", true, true},
+		{"http://127.0.0.1:8080", true, true},
+		{"http://[::1]:8080", true, true},
+		{"ftp://127.0.0.1", true, false},
+	}
+	for _, tc := range cases {
+		if got := validIssuer(tc.issuer, tc.allowLoopback); got != tc.want {
+			t.Errorf("validIssuer(%q, %v) = %v, want %v", tc.issuer, tc.allowLoopback, got, tc.want)
+		}
+	}
+}
+
+func TestContractSSOProviderAdmin(t *testing.T) {
+	idp, srv, err := oidctest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	tenantID := uuid.New()
+	var saved *models.TenantOIDCProvider
+	fs := &fakeStore{
+		getTenantOIDCProvider: func(uuid.UUID) (*models.TenantOIDCProvider, error) { return saved, nil },
+		upsertTenantOIDCProvider: func(p *models.TenantOIDCProvider) error {
+			p.UpdatedAt = time.Now()
+			saved = p
+			return nil
+		},
+		deleteTenantOIDCProvider: func(uuid.UUID) (bool, error) {
+			found := saved != nil
+			saved = nil
+			return found, nil
+		},
+	}
+	h := New(fs)
+	h.AllowLoopbackSSOIssuers = true // the mock provider listens on 127.0.0.1
+	e := echo.New()
+
+	c, rec := newAuthedContext(e, http.MethodGet, "/api/sso", "", tenantID.String(), "admin")
+	if err := h.GetSSOProvider(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("GET unconfigured: want 404, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodGet, "/api/sso", rec)
+
+	for name, body := range map[string]string{
+		"plain http issuer": `{"issuer":"http://idp.example","client_id":"c","client_secret":"s","allowed_domains":["corp.example"]}`,
+		"no domains":        `{"issuer":"` + idp.Issuer + `","client_id":"c","client_secret":"s","allowed_domains":[]}`,
+		"bad mapping":       `{"issuer":"` + idp.Issuer + `","client_id":"c","client_secret":"s","allowed_domains":["corp.example"],"role_mapping":{"x":"owner"}}`,
+		"no secret":         `{"issuer":"` + idp.Issuer + `","client_id":"c","allowed_domains":["corp.example"]}`,
+		"unreachable":       `{"issuer":"http://127.0.0.1:1","client_id":"c","client_secret":"s","allowed_domains":["corp.example"]}`,
+	} {
+		c, rec := newAuthedContext(e, http.MethodPut, "/api/sso", body, tenantID.String(), "admin")
+		if err := h.PutSSOProvider(c); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: want 400, got %d, body=%s", name, rec.Code, rec.Body.String())
+		}
+		if strings.Contains(rec.Body.String(), "127.0.0.1") {
+			t.Errorf("%s: response leaks the discovery error: %s", name, rec.Body.String())
+		}
+		validateResponse(t, http.MethodPut, "/api/sso", rec)
+	}
+	if saved != nil {
+		t.Fatal("an invalid configuration was saved")
+	}
+
+	body := `{"issuer":"` + idp.Issuer + `/","client_id":"idento","client_secret":"secret",` +
+		`"allowed_domains":["@Corp.Example","corp.example"],"role_claim":"groups","role_mapping":{"it":"admin"}}`
+	c, rec = newAuthedContext(e, http.MethodPut, "/api/sso", body, tenantID.String(), "admin")
+	if err := h.PutSSOProvider(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT: want 200, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodPut, "/api/sso", rec)
+	if saved.Issuer != idp.Issuer || saved.TenantID != tenantID || saved.DefaultRole != "staff" || !saved.Enabled ||
+		len(saved.AllowedDomains) != 1 || saved.AllowedDomains[0] != "corp.example" {
+		t.Errorf("saved = %+v", saved)
+	}
+	if strings.Contains(rec.Body.String(), `"client_secret"`) {
+		t.Errorf("response leaks the client secret: %s", rec.Body.String())
+	}
+	if len(fs.tenantAudit) != 1 || fs.tenantAudit[0].Action != auditUpdateSSOProvider {
+		t.Errorf("audit = %+v", fs.tenantAudit)
+	}
+
+	c, rec = newAuthedContext(e, http.MethodGet, "/api/sso", "", tenantID.String(), "admin")
+	if err := h.GetSSOProvider(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET: want 200, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodGet, "/api/sso", rec)
+
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		c, rec := newAuthedContext(e, method, "/api/sso", body, tenantID.String(), "manager")
+		var err error
+		switch method {
+		case http.MethodGet:
+			err = h.GetSSOProvider(c)
+		case http.MethodPut:
+			err = h.PutSSOProvider(c)
+		default:
+			err = h.DeleteSSOProvider(c)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s as manager: want 403, got %d", method, rec.Code)
+		}
+		validateResponse(t, method, "/api/sso", rec)
+	}
+
+	for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		c, rec := newAuthedContext(e, http.MethodDelete, "/api/sso", "", tenantID.String(), "admin")
+		if err := h.DeleteSSOProvider(c); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != want {
+			t.Errorf("DELETE: want %d, got %d", want, rec.Code)
+		}
+		validateResponse(t, http.MethodDelete, "/api/sso", rec)
+	}
+}
@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"idento/backend/internal/broker"
+	"idento/backend/internal/metrics"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const auditStationMessage = "station_message"
+
+// maxStationMessageText bounds a broadcast: it is shown on a station's
+// screen, not read as a document.
+const maxStationMessageText = 500
+
+// stationChannelNamespace derives an event's station channel key. Stations
+// get a channel of their own, not the event's monitor key, so a broadcast
+// never makes monitor feeds re-read and a check-in never wakes stations.
+var stationChannelNamespace = uuid.MustParse("5c1f6a52-8d0e-4b7e-9a43-2f1d7c6e0b19")
+
+// stationChannelKey is the broker key of eventID's station channel.
+func stationChannelKey(eventID uuid.UUID) uuid.UUID {
+	return uuid.NewSHA1(stationChannelNamespace, eventID[:])
+}
+
+// StationPush is the data of one frame on the station stream; the frame's
+// event name repeats Kind.
+type StationPush struct {
+	Kind       broker.Kind `json:"kind"`
+	AttendeeID *uuid.UUID  `json:"attendee_id,omitempty"`
+	Text       string      `json:"text,omitempty"`
+	At         time.Time   `json:"at"`
+}
+
+// StationMessageRequest is the body of POST .../stations/messages.
+type StationMessageRequest struct {
+	Kind      broker.Kind `json:"kind"`
+	Text      string      `json:"text"`
+	StationID *uuid.UUID  `json:"station_id"`
+}
+
+// pushStationMessage publishes kind to every station of eventID, with
+// publishMonitorMessage's nil-safety, detachment, timeout and
+// log-don't-fail contract. Stations refetch on it, so callers push after
+// their write has committed.
+func (h *Handler) pushStationMessage(ctx context.Context, eventID uuid.UUID, kind broker.Kind, attendeeID *uuid.UUID) {
+	h.publishMonitorMessage(ctx, broker.Message{Key: stationChannelKey(eventID), Kind: kind, AttendeeID: attendeeID, At: time.Now().UTC()})
+}
+
+// pushStationLogout tells stationID of eventID to sign out, with
+// pushStationMessage's contract. Callers send it after revoking the
+// station: an open stream outlives the token check it passed when it
+// connected.
+func (h *Handler) pushStationLogout(ctx context.Context, eventID, stationID uuid.UUID) {
+	h.publishMonitorMessage(ctx, broker.Message{Key: stationChannelKey(eventID), Kind: broker.KindLogout, StationID: &stationID, At: time.Now().UTC()})
+}
+
+// GetStationStream serves GET /api/events/{event_id}/stations/stream: the
+// channel a provisioned check-in station keeps open to hear about changes
+// without waiting for its next sync — attendee updates, settings changes,
+// operator broadcasts and remote commands (resync, logout). Only a station
+// token of this event may open it. The stream ends after a logout frame,
+// and a station that fell behind is told to resync. Every ping re-checks
+// the token, so a revocation whose logout push was lost still ends the
+// stream with a logout frame.
+func (h *Handler) GetStationStream(c echo.Context) error {
+	eventID, err := uuid.Parse(c.Param("event_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid event ID"})
+	}
+	claims, err := claimsFromContext(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	stationID, err := uuid.Parse(claims.StationID)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Station token required"})
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid token"})
+	}
+	if _, err := h.requireEventOwnership(c, eventID); err != nil {
+		return writeErr(c, err)
+	}
+	station, err := h.Store.GetStationByID(c.Request().Context(), stationID)
+	if errors.Is(err, store.ErrStationNotFound) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Station token required"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load station"})
+	}
+	if station.EventID != eventID {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Station belongs to another event"})
+	}
+	if h.Broker == nil {
+		return writeErr(c, newHTTPError(http.StatusServiceUnavailable, "Station stream unavailable: no event broker configured"))
+	}
+	if err := h.refuseStreamWhileDraining(c); err != nil {
+		return writeErr(c, err)
+	}
+
+	// Subscribe before hello, as GetEventMonitorStream does, so nothing
+	// published after the client saw hello is missed.
+	sub, unsubscribe := h.Broker.SubscribeMessages(stationChannelKey(eventID))
+	defer unsubscribe()
+
+	res := c.Response()
+	writeSSEHeaders(res)
+	streams := metrics.SSEStreams.WithLabelValues("station")
+	streams.Inc()
+	defer streams.Dec()
+
+	if !writeSSEFrame(res, "event: hello\ndata: {}\n\n") {
+		return nil
+	}
+
+	ticker := time.NewTicker(monitorStreamPingInterval)
+	defer ticker.Stop()
+
+	ctx := c.Request().Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-h.drain.done():
+			writeReconnectFrame(res)
+			return nil
+		case <-sub.C:
+			msgs, lost := sub.Drain()
+			if lost {
+				// Something was dropped; whatever it was, a full sync
+				// covers it.
+				msgs = []broker.Message{{Kind: broker.KindResync, At: time.Now().UTC()}}
+			}
+			for _, msg := range msgs {
+				if msg.StationID != nil && *msg.StationID != stationID {
+					continue
+				}
+				if msg.Kind == broker.KindChanged {
+					msg.Kind = broker.KindResync
+				}
+				if !writeStationPush(res, msg) {
+					return nil
+				}
+				if msg.Kind == broker.KindLogout {
+					return nil
+				}
+			}
+		case <-ticker.C:
+			userVersion, stationVersion, err := h.Store.GetTokenVersions(ctx, userID, &stationID)
+			if err != nil {
+				c.Logger().Errorf("station stream: token check failed (station %s): %v", stationID, err)
+			} else if userVersion != claims.TokenVersion || stationVersion != claims.StationTokenVersion {
+				writeStationPush(res, broker.Message{Kind: broker.KindLogout, At: time.Now().UTC()})
+				return nil
+			}
+			if !writeSSEFrame(res, ": ping\n\n") {
+				return nil
+			}
+		}
+	}
+}
+
+func writeStationPush(res *echo.Response, msg broker.Message) bool {
+	data, err := json.Marshal(StationPush{Kind: msg.Kind, AttendeeID: msg.AttendeeID, Text: msg.Text, At: msg.At})
+	if err != nil {
+		return false
+	}
+	return writeSSEFrame(res, fmt.Sprintf("event: %s\ndata: %s\n\n", msg.Kind, data))
+}
+
+// PostStationMessage serves POST /api/events/{event_id}/stations/messages:
+// a caller holding stations:manage sends a broadcast ("switch to manual search") or a
+// command (resync, logout) to every station of the event, or to one. A
+// logout only asks the station to sign out; RevokeStation is what takes
+// its token away.
+func (h *Handler) PostStationMessage(c echo.Context) error {
+	eventID, err := uuid.Parse(c.Param("event_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid event ID"})
+	}
+	if _, err := h.requireEventOwnership(c, eventID); err != nil {
+		return writeErr(c, err)
+	}
+	if err := h.requirePermission(c, PermStationsManage, eventID); err != nil {
+		return writeErr(c, err)
+	}
+
+	var req StationMessageRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	req.Text = strings.TrimSpace(req.Text)
+	switch req.Kind {
+	case broker.KindBroadcast:
+		if req.Text == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "text is required for a broadcast"})
+		}
+	case broker.KindResync, broker.KindLogout:
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "kind must be broadcast, resync or logout"})
+	}
+	if len([]rune(req.Text)) > maxStationMessageText {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("text must be at most %d characters", maxStationMessageText)})
+	}
+	if req.StationID != nil {
+		station, err := h.Store.GetStationByID(c.Request().Context(), *req.StationID)
+		if errors.Is(err, store.ErrStationNotFound) || (err == nil && station.EventID != eventID) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Station not found"})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load station"})
+		}
+	}
+
+	// Unlike the pushes that follow a write, this one is the whole
+	// request: publish on the caller's context and say so if it failed.
+	if h.Broker == nil {
+		return writeErr(c, newHTTPError(http.StatusServiceUnavailable, "Station messages unavailable: no event broker configured"))
+	}
+	ctx, cancel := context.WithTimeout(c.Request().Context(), publishCheckinTimeout)
+	defer cancel()
+	msg := broker.Message{Key: stationChannelKey(eventID), Kind: req.Kind, StationID: req.StationID, Text: req.Text, At: time.Now().UTC()}
+	if err := h.Broker.Publish(ctx, msg); err != nil {
+		c.Logger().Errorf("station message: broker publish failed (event %s): %v", eventID, err)
+		return writeErr(c, newHTTPError(http.StatusServiceUnavailable, "Failed to deliver station message"))
+	}
+
+	targetType, targetID := "event", eventID
+	if req.StationID != nil {
+		targetType, targetID = "station", *req.StationID
+	}
+	h.logTenantAction(c, &eventID, auditStationMessage, targetType, targetID, map[string]interface{}{"kind": req.Kind, "text": req.Text})
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,327 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"idento/backend/internal/broker"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func stationStreamPath(eventID uuid.UUID) string {
+	return "/api/events/" + eventID.String() + "/stations/stream"
+}
+
+// stationStore serves one event with one provisioned station on it.
+func stationStore(event *models.Event, station *models.Station) *fakeStore {
+	return &fakeStore{
+		getEventByID: func(uuid.UUID) (*models.Event, error) { return event, nil },
+		getStationByID: func(id uuid.UUID) (*models.Station, error) {
+			if id != station.ID {
+				return nil, store.ErrStationNotFound
+			}
+			return station, nil
+		},
+	}
+}
+
+// newStationStreamTestServer serves GetStationStream for eventID to a
+// caller holding stationID's token.
+func newStationStreamTestServer(t *testing.T, h *Handler, eventID, tenantID, stationID uuid.UUID) (*httptest.Server, <-chan struct{}) {
+	t.Helper()
+	e := echo.New()
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		c := e.NewContext(r, w)
+		c.SetParamNames("event_id")
+		c.SetParamValues(eventID.String())
+		c.Set("user", &models.JWTCustomClaims{
+			UserID:    uuid.New().String(),
+			TenantID:  tenantID.String(),
+			Role:      "staff",
+			StationID: stationID.String(),
+		})
+		if err := h.GetStationStream(c); err != nil {
+			t.Errorf("GetStationStream: %v", err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, done
+}
+
+func openStationStream(t *testing.T, url string) (*http.Response, *bufio.Reader) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET stream: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	r := bufio.NewReader(resp.Body)
+	if f := readDeltaFrame(t, r); f.event != "hello" {
+		t.Fatalf("first frame = %+v, want hello", f)
+	}
+	return resp, r
+}
+
+func readStationPush(t *testing.T, r *bufio.Reader) StationPush {
+	t.Helper()
+	f := readDeltaFrame(t, r)
+	var p StationPush
+	if err := json.Unmarshal(f.data, &p); err != nil {
+		t.Fatalf("frame %s %s: %v", f.event, f.data, err)
+	}
+	if string(p.Kind) != f.event {
+		t.Fatalf("frame event %q carries kind %q", f.event, p.Kind)
+	}
+	return p
+}
+
+func TestGetStationStream_PushesMessagesForThisStation(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	station := &models.Station{ID: uuid.New(), EventID: event.ID, DeviceNumber: 1}
+	mem := broker.NewMemBroker()
+	h := New(stationStore(event, station))
+	h.Broker = mem
+	srv, done := newStationStreamTestServer(t, h, event.ID, tenantID, station.ID)
+
+	resp, r := openStationStream(t, srv.URL)
+	defer resp.Body.Close()
+
+	key, other, attendee := stationChannelKey(event.ID), uuid.New(), uuid.New()
+	for _, msg := range []broker.Message{
+		{Key: key, Kind: broker.KindBroadcast, Text: "Switch to manual search", At: time.Now().UTC()},
+		{Key: key, Kind: broker.KindLogout, StationID: &other, At: time.Now().UTC()},
+		{Key: key, Kind: broker.KindAttendee, AttendeeID: &attendee, At: time.Now().UTC()},
+		{Key: key, Kind: broker.KindLogout, StationID: &station.ID, At: time.Now().UTC()},
+	} {
+		if err := mem.Publish(context.Background(), msg); err != nil {
+			t.Fatalf("Publish(%s): %v", msg.Kind, err)
+		}
+	}
+
+	if p := readStationPush(t, r); p.Kind != broker.KindBroadcast || p.Text != "Switch to manual search" {
+		t.Errorf("first push = %+v, want the broadcast", p)
+	}
+	// The other station's logout is skipped.
+	if p := readStationPush(t, r); p.Kind != broker.KindAttendee || p.AttendeeID == nil || *p.AttendeeID != attendee {
+		t.Errorf("second push = %+v, want the attendee update", p)
+	}
+	if p := readStationPush(t, r); p.Kind != broker.KindLogout {
+		t.Errorf("third push = %+v, want this station's logout", p)
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream still open after a logout")
+	}
+}
+
+// A station whose messages were dropped can't know what it missed, so it
+// is told to resync.
+func TestGetStationStream_LostMessagesAskForResync(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	station := &models.Station{ID: uuid.New(), EventID: event.ID, DeviceNumber: 1}
+	mem := broker.NewMemBroker()
+	h := New(stationStore(event, station))
+	h.Broker = mem
+	srv, _ := newStationStreamTestServer(t, h, event.ID, tenantID, station.ID)
+
+	resp, r := openStationStream(t, srv.URL)
+	defer resp.Body.Close()
+
+	mem.BroadcastAll()
+	if p := readStationPush(t, r); p.Kind != broker.KindResync {
+		t.Errorf("push = %+v, want a resync", p)
+	}
+}
+
+// A revoked station whose logout push never arrived is still signed out
+// by the token check on the next ping.
+func TestGetStationStream_RevokedTokenLogsOutOnPing(t *testing.T) {
+	orig := monitorStreamPingInterval
+	monitorStreamPingInterval = 20 * time.Millisecond
+	t.Cleanup(func() { monitorStreamPingInterval = orig })
+
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	station := &models.Station{ID: uuid.New(), EventID: event.ID, DeviceNumber: 1}
+	fs := stationStore(event, station)
+	fs.getTokenVersions = func(uuid.UUID, *uuid.UUID) (int, int, error) { return 0, 1, nil }
+	h := New(fs)
+	h.Broker = broker.NewMemBroker()
+	srv, done := newStationStreamTestServer(t, h, event.ID, tenantID, station.ID)
+
+	resp, r := openStationStream(t, srv.URL)
+	defer resp.Body.Close()
+
+	if p := readStationPush(t, r); p.Kind != broker.KindLogout {
+		t.Errorf("push = %+v, want a logout", p)
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream still open after the token was revoked")
+	}
+}
+
+func TestOpenAPIContract_GetStationStream_Forbidden(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	station := &models.Station{ID: uuid.New(), EventID: event.ID, DeviceNumber: 1}
+	elsewhere := &models.Station{ID: uuid.New(), EventID: uuid.New(), DeviceNumber: 1}
+	path := stationStreamPath(event.ID)
+
+	call := func(fs *fakeStore, stationID string) {
+		t.Helper()
+		h := New(fs)
+		h.Broker = broker.NewMemBroker()
+		c, rec := newAuthedContext(echo.New(), http.MethodGet, path, "", tenantID.String(), "staff")
+		c.Get("user").(*models.JWTCustomClaims).StationID = stationID
+		c.SetParamNames("event_id")
+		c.SetParamValues(event.ID.String())
+		if err := h.GetStationStream(c); err != nil {
+			t.Fatalf("GetStationStream: %v", err)
+		}
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("station %q: want 403, got %d, body=%s", stationID, rec.Code, rec.Body.String())
+		}
+		validateResponse(t, http.MethodGet, path, rec)
+	}
+
+	call(stationStore(event, station), "")                      // a user's token, not a station's
+	call(stationStore(event, station), uuid.NewString())        // revoked or unknown station
+	call(stationStore(event, elsewhere), elsewhere.ID.String()) // another event's station
+}
+
+// TestOpenAPIContract_GetStationStream_CoverageException marks the stream's
+// route covered: like GetEventMonitorStream's (monitor_stream_test.go), its
+// 200 is an open-ended frame sequence ValidateResponse can't take. The
+// frames are asserted by the streaming tests above.
+func TestOpenAPIContract_GetStationStream_CoverageException(t *testing.T) {
+	coverageMu.Lock()
+	coverage["GET /api/events/{event_id}/stations/stream"] = true
+	coverageMu.Unlock()
+}
+
+func TestContractPostStationMessage(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	station := &models.Station{ID: uuid.New(), EventID: event.ID, DeviceNumber: 1}
+	path := "/api/events/" + event.ID.String() + "/stations/messages"
+
+	call := func(h *Handler, role, body string) int {
+		t.Helper()
+		c, rec := newAuthedContext(echo.New(), http.MethodPost, path, body, tenantID.String(), role)
+		c.SetPath("/api/events/:event_id/stations/messages")
+		c.SetParamNames("event_id")
+		c.SetParamValues(event.ID.String())
+		if err := h.PostStationMessage(c); err != nil {
+			t.Fatalf("PostStationMessage: %v", err)
+		}
+		validateResponse(t, http.MethodPost, path, rec)
+		return rec.Code
+	}
+
+	fs := stationStore(event, station)
+	mem := broker.NewMemBroker()
+	h := New(fs)
+	h.Broker = mem
+	sub, unsubscribe := mem.SubscribeMessages(stationChannelKey(event.ID))
+	defer unsubscribe()
+
+	if code := call(h, "manager", `{"kind":"broadcast","text":"  Switch to manual search  "}`); code != http.StatusNoContent {
+		t.Fatalf("broadcast: want 204, got %d", code)
+	}
+	if code := call(h, "admin", `{"kind":"logout","station_id":"`+station.ID.String()+`"}`); code != http.StatusNoContent {
+		t.Fatalf("targeted logout: want 204, got %d", code)
+	}
+	msgs, _ := sub.Drain()
+	if len(msgs) != 2 ||
+		msgs[0].Kind != broker.KindBroadcast || msgs[0].Text != "Switch to manual search" || msgs[0].StationID != nil ||
+		msgs[1].Kind != broker.KindLogout || msgs[1].StationID == nil || *msgs[1].StationID != station.ID {
+		t.Fatalf("published %+v, want the broadcast to all and the logout to the station", msgs)
+	}
+	if len(fs.tenantAudit) != 2 || fs.tenantAudit[0].Action != auditStationMessage {
+		t.Errorf("tenant audit = %+v, want two station_message entries", fs.tenantAudit)
+	}
+
+	for body, want := range map[string]int{
+		`{"kind":"broadcast"}`: http.StatusBadRequest,
+		`{"kind":"reboot"}`:    http.StatusBadRequest,
+		`{"kind":"broadcast","text":"` + strings.Repeat("x", 501) + `"}`: http.StatusBadRequest,
+		`{"kind":"resync","station_id":"` + uuid.NewString() + `"}`:      http.StatusNotFound,
+	} {
+		if code := call(h, "admin", body); code != want {
+			t.Errorf("%.40s: want %d, got %d", body, want, code)
+		}
+	}
+	if code := call(h, "staff", `{"kind":"resync"}`); code != http.StatusForbidden {
+		t.Errorf("staff: want 403, got %d", code)
+	}
+	if code := call(New(fs), "admin", `{"kind":"resync"}`); code != http.StatusServiceUnavailable {
+		t.Errorf("no broker: want 503, got %d", code)
+	}
+
+	// The check is the stations:manage permission, not the role name.
+	fs.getMemberCustomRole = func(uuid.UUID, uuid.UUID) (*models.TenantRole, error) {
+		return &models.TenantRole{Permissions: []string{string(PermStationsManage)}}, nil
+	}
+	if code := call(h, "staff", `{"kind":"resync"}`); code != http.StatusNoContent {
+		t.Errorf("staff with stations:manage: want 204, got %d", code)
+	}
+}
+
+// Blocking an attendee and revoking a station reach the stations without
+// a sync.
+func TestStationPushes_FollowBlockAndRevoke(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	attendee := contractAttendee(event.ID)
+	stationID := uuid.New()
+	mem := broker.NewMemBroker()
+	h := New(&fakeStore{
+		getEventByID:          func(uuid.UUID) (*models.Event, error) { return event, nil },
+		getAttendeeByID:       func(uuid.UUID) (*models.Attendee, error) { return attendee, nil },
+		updateAttendee:        func(*models.Attendee) error { return nil },
+		revokeStationSessions: func(uuid.UUID, uuid.UUID) (int64, error) { return 1, nil },
+	})
+	h.Broker = mem
+	sub, unsubscribe := mem.SubscribeMessages(stationChannelKey(event.ID))
+	defer unsubscribe()
+
+	e := echo.New()
+	c, rec := newAuthedContext(e, http.MethodPost, "/api/attendees/"+attendee.ID.String()+"/block", `{"reason":"No-show"}`, tenantID.String(), "admin")
+	c.SetParamNames("id")
+	c.SetParamValues(attendee.ID.String())
+	if err := h.BlockAttendee(c); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("BlockAttendee: %v, %d", err, rec.Code)
+	}
+	c, rec = newAuthedContext(e, http.MethodPost, "/revoke", "", tenantID.String(), "admin")
+	c.SetParamNames("event_id", "station_id")
+	c.SetParamValues(event.ID.String(), stationID.String())
+	if err := h.RevokeStation(c); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("RevokeStation: %v, %d", err, rec.Code)
+	}
+
+	msgs, _ := sub.Drain()
+	if len(msgs) != 2 ||
+		msgs[0].Kind != broker.KindAttendee || msgs[0].AttendeeID == nil || *msgs[0].AttendeeID != attendee.ID ||
+		msgs[1].Kind != broker.KindLogout || msgs[1].StationID == nil || *msgs[1].StationID != stationID {
+		t.Fatalf("pushed %+v, want the attendee update then the station's logout", msgs)
+	}
+}
@@ -12,7 +12,7 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
-// CreateStationProvisioningToken lets a manager/admin mint a short-lived (10
+// CreateStationProvisioningToken lets a caller holding stations:manage mint a short-lived (10
 // minute), one-time token — shown as a QR in the web console — that binds a new
 // mobile station to a specific existing staff user for this event.
 func (h *Handler) CreateStationProvisioningToken(c echo.Context) error {
@@ -24,9 +24,12 @@ func (h *Handler) CreateStationProvisioningToken(c echo.Context) error {
 		return writeErr(c, err)
 	}
 
-	claims := c.Get("user").(*models.JWTCustomClaims)
-	if claims.Role != "admin" && claims.Role != "manager" {
-		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only admins/managers can provision stations"})
+	if err := h.requirePermission(c, PermStationsManage, eventID); err != nil {
+		return writeErr(c, err)
+	}
+	claims, err := claimsFromContext(c)
+	if err != nil {
+		return writeErr(c, err)
 	}
 	callerID, err := uuid.Parse(claims.UserID)
 	if err != nil {
@@ -117,7 +120,7 @@ func (h *Handler) ProvisionStation(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create station"})
 	}
 
-	jwtToken, err := generateTokenForTenant(staffUser, event.TenantID.String(), tenantRole)
+	session, err := h.startSession(c, staffUser.ID, event.TenantID, tenantRole, &station.ID, false)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to issue token"})
 	}
@@ -128,7 +131,9 @@ func (h *Handler) ProvisionStation(c echo.Context) error {
 			EventName: event.Name,
 			StaffName: staffUser.Email,
 		},
-		StaffJWT:     jwtToken,
+		StaffJWT:     session.Token,
+		RefreshToken: session.RefreshToken,
+		ExpiresAt:    session.ExpiresAt,
 		DeviceNumber: station.DeviceNumber,
 	})
 }
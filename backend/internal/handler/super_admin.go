@@ -509,11 +509,15 @@ func (h *Handler) ImpersonateTenant(c echo.Context) error {
 		}
 		return c.JSON(http.StatusConflict, map[string]string{"error": "tenant is " + status + " — " + hint})
 	}
-	token, expiresAt, err := generateImpersonationToken(claims.UserID, tenantID.String())
+	adminID := uuid.MustParse(claims.UserID)
+	tokenVersion, _, err := h.Store.GetTokenVersions(c.Request().Context(), adminID, nil)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to mint impersonation token"})
+	}
+	token, expiresAt, err := generateImpersonationToken(claims.UserID, tenantID.String(), tokenVersion)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to mint impersonation token"})
 	}
-	adminID := uuid.MustParse(claims.UserID)
 	changes := map[string]interface{}{"expires_at": expiresAt}
 	if body.Reason != "" {
 		changes["reason"] = body.Reason
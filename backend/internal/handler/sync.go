@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"idento/backend/internal/metrics"
 	"idento/backend/internal/models"
 	"net/http"
 	"strconv"
@@ -181,6 +182,7 @@ func (h *Handler) SyncPush(c echo.Context) error {
 		// visible state even if the full-row write below fails.
 		if flipped {
 			if attendee.CheckinStatus {
+				metrics.Checkins.WithLabelValues("sync", "checked_in").Inc()
 				// created_at = the CLIENT-supplied CheckedInAt exactly as
 				// the claim persisted it into checked_in_at (nil → now();
 				// a nil checked_in_at with status=true already reads as
@@ -190,6 +192,7 @@ func (h *Handler) SyncPush(c echo.Context) error {
 					c.Logger().Errorf("sync: checkin feed row insert failed (event %s, attendee %s): %v", existingAttendee.EventID, existingAttendee.ID, err)
 				}
 			} else {
+				metrics.CheckinUndos.Inc()
 				if err := h.Store.InsertCheckinActionAt(c.Request().Context(), existingAttendee.EventID, existingAttendee.ID, "undo", nil, staffUserID, nil); err != nil {
 					c.Logger().Errorf("sync: undo feed row insert failed (event %s, attendee %s): %v", existingAttendee.EventID, existingAttendee.ID, err)
 				}
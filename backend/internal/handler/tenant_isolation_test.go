@@ -53,7 +53,7 @@ func TestCrossTenantAccessIs404(t *testing.T) {
 		{"DeleteAttendee", http.MethodDelete, "", "id", attendeeID.String(), h.DeleteAttendee},
 		{"BadgeZPL", http.MethodPost, `{"attendee_id":"` + attendeeID.String() + `"}`, "id", eventID.String(), h.BadgeZPL},
 		{"GenerateAttendeeCodes", http.MethodPost, "", "event_id", eventID.String(), h.GenerateAttendeeCodes},
-		{"ExportAttendeesCSV", http.MethodGet, "", "event_id", eventID.String(), h.ExportAttendeesCSV},
+		{"ExportAttendees", http.MethodGet, "", "event_id", eventID.String(), h.ExportAttendees},
 		{"BulkCreateAttendees", http.MethodPost, `{"attendees":[{"first_name":"x"}]}`, "event_id", eventID.String(), h.BulkCreateAttendees},
 	}
 
@@ -138,6 +138,13 @@ func (h *Handler) UpdateTenant(c echo.Context) error {
 		tenant.Name = *req.Name
 	}
 	if req.Settings != nil {
+		// two_factor_required is enforced by middleware.TwoFactorPolicy,
+		// which only honours a JSON true; reject anything ambiguous.
+		if v, ok := (*req.Settings)["two_factor_required"]; ok {
+			if _, isBool := v.(bool); !isBool {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "settings.two_factor_required must be a boolean"})
+			}
+		}
 		tenant.Settings = *req.Settings
 	}
 	if req.LogoURL != nil {
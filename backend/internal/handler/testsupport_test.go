@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/http/httptest"
 	"strings"
 	"time"
@@ -54,13 +55,29 @@ type fakeStore struct {
 	getStaffZoneAssignments       func(userID uuid.UUID) ([]*models.StaffZoneAssignment, error)
 	getAPIKeysByEventID           func(eventID uuid.UUID) ([]*models.APIKey, error)
 	revokeAPIKey                  func(id uuid.UUID) error
+	getAPIKeyUsage                func(keyID uuid.UUID, from, to time.Time) ([]models.APIKeyUsageDay, error)
+	claimIdempotencyKey           func(rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error)
 	createAttendee                func(attendee *models.Attendee) error
+	importAttendee                func(row store.AttendeeImportRow, mode, matchOn string) (uuid.UUID, string, error)
+	existingImportMatchKeys       func(eventID uuid.UUID, matchOn string, keys []string) (map[string]bool, error)
+	previewAttendeeImport         func(rows []store.AttendeeImportRow, mode, matchOn string) ([]store.ImportOutcome, error)
+	listImportMappings            func(tenantID uuid.UUID) ([]*models.ImportMapping, error)
+	getImportMapping              func(tenantID, id uuid.UUID) (*models.ImportMapping, error)
+	createImportMapping           func(m *models.ImportMapping) error
+	updateImportMapping           func(m *models.ImportMapping) error
+	deleteImportMapping           func(tenantID, id uuid.UUID) error
+	enqueueJob                    func(j *models.Job) error
+	getJob                        func(tenantID, id uuid.UUID) (*models.Job, error)
+	listJobs                      func(tenantID uuid.UUID, f store.JobFilter) ([]*models.Job, error)
+	getJobArtifact                func(tenantID, id uuid.UUID) (*models.JobArtifact, error)
+	writeJobArtifact              func(tenantID, id uuid.UUID, w io.Writer) error
 	analyzeAttendeesTable         func() error
 	updateAttendee                func(attendee *models.Attendee) error
 	incrementAttendeePrintedCount func(attendeeID uuid.UUID) (int, error)
 	getAttendeesByEventID         func(eventID uuid.UUID, code, search string) ([]*models.Attendee, error)
 	countAttendeesByEventID       func(eventID uuid.UUID) (int, error)
 	getAttendeesPage              func(eventID uuid.UUID, f store.AttendeeFilter) ([]*models.Attendee, int, error)
+	streamAttendeeExport          func(eventID uuid.UUID, f store.AttendeeFilter, withZones bool, fn func(*store.AttendeeExportRow) error) error
 	getAttendeeZoneCheckins       func(attendeeID uuid.UUID) ([]*models.ZoneCheckin, error)
 	getEventBadgeTemplate         func(eventID uuid.UUID) (json.RawMessage, int, error)
 	updateEventBadgeTemplate      func(eventID uuid.UUID, template json.RawMessage, expectedVersion int) (int, error)
@@ -100,6 +117,98 @@ type fakeStore struct {
 	updateSubscriptionPlan    func(plan *models.SubscriptionPlan) error
 	logAdminAction            func(adminID uuid.UUID, action, targetType string, targetID uuid.UUID, changes interface{}, ip, userAgent string) error
 	getAuditLog               func(filters map[string]interface{}, limit, offset int) ([]*models.AdminAuditLog, int, error)
+	getTenantAuditLog         func(tenantID uuid.UUID, f store.TenantAuditFilter) ([]*models.TenantAuditEntry, int, error)
+	// tenantAudit collects LogTenantAction entries; tenant audit logging is
+	// best-effort and runs after most mutations, so it is recorded rather
+	// than required to be stubbed.
+	tenantAudit []*models.TenantAuditEntry
+
+	// Sessions. Every sign-in starts one, so unset getTokenVersions reads
+	// as version 0 and unset createRefreshToken records into
+	// refreshTokens instead of panicking.
+	getTokenVersions         func(userID uuid.UUID, stationID *uuid.UUID) (int, int, error)
+	sessionActive            func(userID, familyID uuid.UUID) (bool, error)
+	createRefreshToken       func(rt *models.RefreshToken) error
+	refreshTokens            []*models.RefreshToken
+	rotateRefreshToken       func(oldHash string, next *models.RefreshToken) error
+	revokeRefreshTokenFamily func(userID, familyID uuid.UUID) error
+	revokeUserSessions       func(userID uuid.UUID) (int64, error)
+	revokeStationSessions    func(eventID, stationID uuid.UUID) (int64, error)
+
+	// Mailed user tokens. Register mails a verification link, so unset
+	// createUserToken records into userTokens instead of panicking.
+	createUserToken        func(userID uuid.UUID, purpose, tokenHash string, expiresAt time.Time) error
+	userTokens             []fakeUserToken
+	resetPasswordWithToken func(tokenHash, passwordHash string) (uuid.UUID, error)
+	verifyEmailWithToken   func(tokenHash string) (uuid.UUID, error)
+
+	// TOTP two-factor. Unset getTenantTwoFactorRequired reads as "not
+	// required" so routed tests don't trip over the policy middleware.
+	setPendingTOTPSecret       func(userID uuid.UUID, secret string) error
+	getTOTPSecret              func(userID uuid.UUID) (string, bool, error)
+	enableTOTP                 func(userID uuid.UUID, step int64, recoveryCodeHashes []string) error
+	useTOTPStep                func(userID uuid.UUID, step int64) (bool, error)
+	useRecoveryCode            func(userID uuid.UUID, codeHash string) (bool, error)
+	countUnusedRecoveryCodes   func(userID uuid.UUID) (int, error)
+	replaceRecoveryCodes       func(userID uuid.UUID, codeHashes []string) error
+	disableTOTP                func(userID uuid.UUID) error
+	getTenantTwoFactorRequired func(tenantID uuid.UUID) (bool, error)
+	// Login challenges are kept in twoFactorChallenges, allowing 5 codes
+	// each, unless attemptTwoFactorChallenge overrides the attempt.
+	twoFactorChallenges       map[uuid.UUID]*fakeTwoFactorChallenge
+	attemptTwoFactorChallenge func(id, userID uuid.UUID) error
+
+	// OIDC single sign-on.
+	getTenantOIDCProvider       func(tenantID uuid.UUID) (*models.TenantOIDCProvider, error)
+	upsertTenantOIDCProvider    func(p *models.TenantOIDCProvider) error
+	deleteTenantOIDCProvider    func(tenantID uuid.UUID) (bool, error)
+	findSSOTenantsByEmailDomain func(domain string) ([]models.SSOTenant, error)
+	createOIDCLoginRequest      func(r *models.OIDCLoginRequest) error
+	consumeOIDCLoginRequest     func(stateHash string) (*models.OIDCLoginRequest, error)
+	linkOIDCIdentity            func(id models.OIDCIdentity) (*models.User, string, error)
+
+	// Custom roles.
+	listTenantRoles     func(tenantID uuid.UUID) ([]*models.TenantRole, error)
+	getTenantRole       func(tenantID, roleID uuid.UUID) (*models.TenantRole, error)
+	createTenantRole    func(r *models.TenantRole) error
+	updateTenantRole    func(r *models.TenantRole) error
+	deleteTenantRole    func(tenantID, roleID uuid.UUID) error
+	setMemberCustomRole func(tenantID, userID uuid.UUID, roleID *uuid.UUID) (bool, error)
+	getMemberCustomRole func(userID, tenantID uuid.UUID) (*models.TenantRole, error)
+
+	// Member lifecycle.
+	getTenantMember           func(tenantID, userID uuid.UUID) (*models.User, error)
+	updateUserTenantRole      func(userID, tenantID uuid.UUID, role string) error
+	deactivateMember          func(tenantID, userID uuid.UUID) (int64, []store.RevokedStation, error)
+	reactivateMember          func(tenantID, userID uuid.UUID) error
+	removeUserFromTenant      func(userID, tenantID uuid.UUID) ([]store.RevokedStation, error)
+	createInvitedUser         func(tenantID uuid.UUID, email, role string) (*models.User, error)
+	acceptInvitationWithToken func(tokenHash, passwordHash string) (uuid.UUID, error)
+
+	// Ticket emails.
+	getTicketEmailSettings    func(eventID uuid.UUID) (*models.TicketEmailSettings, error)
+	upsertTicketEmailSettings func(t *models.TicketEmailSettings) error
+	queueTicketDeliveries     func(eventID uuid.UUID, q store.TicketDeliveryQuery) (int64, error)
+	listTicketDeliveries      func(eventID uuid.UUID, status string, limit, offset int) ([]*models.TicketDelivery, int, error)
+	countTicketDeliveries     func(eventID uuid.UUID) (map[string]int, error)
+	getTicketDelivery         func(attendeeID uuid.UUID) (*models.TicketDelivery, error)
+	countDueTicketDeliveries  func(eventID uuid.UUID) (int, error)
+	claimTicketDeliveries     func(eventID uuid.UUID, limit int, lease time.Duration) ([]*models.TicketDelivery, error)
+	recordTicketDelivery      func(d *models.TicketDelivery) error
+	getTicketLayout           func(eventID uuid.UUID) (json.RawMessage, error)
+	updateTicketLayout        func(eventID uuid.UUID, layout json.RawMessage) error
+
+	// Wallet passes (see walletStore).
+	getTenantWalletSettings    func(tenantID uuid.UUID) (*models.TenantWalletSettings, error)
+	upsertTenantWalletSettings func(s *models.TenantWalletSettings) error
+	ensureWalletPass           func(attendeeID, eventID uuid.UUID, passTypeID string) (*models.WalletPass, error)
+	getWalletPass              func(passTypeID string, serial uuid.UUID) (*models.WalletPass, error)
+	registerWalletDevice       func(deviceID string, serial uuid.UUID, pushToken string) (bool, error)
+	unregisterWalletDevice     func(deviceID string, serial uuid.UUID) (bool, error)
+	getWalletDevicePasses      func(deviceID, passTypeID string, since *time.Time) ([]uuid.UUID, time.Time, error)
+	touchWalletPasses          func(eventID uuid.UUID) (int64, error)
+	getWalletPushTargets       func(eventID uuid.UUID) ([]models.WalletPushTarget, error)
+	deleteWalletPushTokens     func(tokens []string) (int64, error)
 
 	getUserTenantRole func(userID, tenantID uuid.UUID) (string, error)
 	updateUserQRToken func(userID uuid.UUID, token string, createdAt time.Time) error
@@ -108,9 +217,11 @@ type fakeStore struct {
 	createProvisioningToken  func(tok *models.StationProvisioningToken) error
 	consumeProvisioningToken func(token string) (*models.StationProvisioningToken, error)
 	createStation            func(eventID, staffUserID uuid.UUID, deviceInfo map[string]interface{}) (*models.Station, error)
+	getStationByID           func(id uuid.UUID) (*models.Station, error)
 
 	getEventsByTenantID  func(tenantID uuid.UUID) ([]*models.Event, error)
 	createEvent          func(event *models.Event) error
+	cloneEvent           func(srcID uuid.UUID, clone *models.Event, opts store.EventCloneOptions) (*models.EventCloneCounts, error)
 	updateEvent          func(event *models.Event) error
 	softDeleteEvent      func(id uuid.UUID) error
 	getEventStaff        func(eventID uuid.UUID) ([]*models.User, error)
@@ -270,9 +381,54 @@ func (f *fakeStore) GetAPIKeysByEventID(_ context.Context, eventID uuid.UUID) ([
 func (f *fakeStore) RevokeAPIKey(_ context.Context, id uuid.UUID) error {
 	return f.revokeAPIKey(id)
 }
+func (f *fakeStore) GetAPIKeyUsage(_ context.Context, keyID uuid.UUID, from, to time.Time) ([]models.APIKeyUsageDay, error) {
+	return f.getAPIKeyUsage(keyID, from, to)
+}
+func (f *fakeStore) ClaimIdempotencyKey(_ context.Context, rec *models.IdempotencyRecord, _, _ time.Duration) (*models.IdempotencyRecord, error) {
+	return f.claimIdempotencyKey(rec)
+}
 func (f *fakeStore) CreateAttendee(_ context.Context, attendee *models.Attendee) error {
 	return f.createAttendee(attendee)
 }
+func (f *fakeStore) ImportAttendee(_ context.Context, row store.AttendeeImportRow, mode, matchOn string) (uuid.UUID, string, error) {
+	return f.importAttendee(row, mode, matchOn)
+}
+func (f *fakeStore) PreviewAttendeeImport(_ context.Context, rows []store.AttendeeImportRow, mode, matchOn string) ([]store.ImportOutcome, error) {
+	return f.previewAttendeeImport(rows, mode, matchOn)
+}
+func (f *fakeStore) ListImportMappings(_ context.Context, tenantID uuid.UUID) ([]*models.ImportMapping, error) {
+	return f.listImportMappings(tenantID)
+}
+func (f *fakeStore) GetImportMapping(_ context.Context, tenantID, id uuid.UUID) (*models.ImportMapping, error) {
+	return f.getImportMapping(tenantID, id)
+}
+func (f *fakeStore) CreateImportMapping(_ context.Context, m *models.ImportMapping) error {
+	return f.createImportMapping(m)
+}
+func (f *fakeStore) UpdateImportMapping(_ context.Context, m *models.ImportMapping) error {
+	return f.updateImportMapping(m)
+}
+func (f *fakeStore) DeleteImportMapping(_ context.Context, tenantID, id uuid.UUID) error {
+	return f.deleteImportMapping(tenantID, id)
+}
+func (f *fakeStore) EnqueueJob(_ context.Context, j *models.Job) error {
+	return f.enqueueJob(j)
+}
+func (f *fakeStore) GetJob(_ context.Context, tenantID, id uuid.UUID) (*models.Job, error) {
+	return f.getJob(tenantID, id)
+}
+func (f *fakeStore) ListJobs(_ context.Context, tenantID uuid.UUID, filter store.JobFilter) ([]*models.Job, error) {
+	return f.listJobs(tenantID, filter)
+}
+func (f *fakeStore) GetJobArtifact(_ context.Context, tenantID, id uuid.UUID) (*models.JobArtifact, error) {
+	return f.getJobArtifact(tenantID, id)
+}
+func (f *fakeStore) WriteJobArtifact(_ context.Context, tenantID, id uuid.UUID, w io.Writer) error {
+	return f.writeJobArtifact(tenantID, id, w)
+}
+func (f *fakeStore) ExistingImportMatchKeys(_ context.Context, eventID uuid.UUID, matchOn string, keys []string) (map[string]bool, error) {
+	return f.existingImportMatchKeys(eventID, matchOn, keys)
+}
 
 // AnalyzeAttendeesTable is deliberately nil-safe, unlike every other
 // fakeStore method above (which panics on an unset field so a test must
@@ -303,6 +459,9 @@ func (f *fakeStore) CountAttendeesByEventID(_ context.Context, eventID uuid.UUID
 func (f *fakeStore) GetAttendeesPage(_ context.Context, eventID uuid.UUID, filter store.AttendeeFilter) ([]*models.Attendee, int, error) {
 	return f.getAttendeesPage(eventID, filter)
 }
+func (f *fakeStore) StreamAttendeeExport(_ context.Context, eventID uuid.UUID, filter store.AttendeeFilter, withZones bool, fn func(*store.AttendeeExportRow) error) error {
+	return f.streamAttendeeExport(eventID, filter, withZones, fn)
+}
 func (f *fakeStore) GetAttendeeZoneCheckins(_ context.Context, attendeeID uuid.UUID) ([]*models.ZoneCheckin, error) {
 	return f.getAttendeeZoneCheckins(attendeeID)
 }
@@ -413,6 +572,273 @@ func (f *fakeStore) LogAdminAction(_ context.Context, adminID uuid.UUID, action,
 func (f *fakeStore) GetAuditLog(_ context.Context, filters map[string]interface{}, limit int, offset int) ([]*models.AdminAuditLog, int, error) {
 	return f.getAuditLog(filters, limit, offset)
 }
+func (f *fakeStore) LogTenantAction(_ context.Context, entry *models.TenantAuditEntry) error {
+	f.tenantAudit = append(f.tenantAudit, entry)
+	return nil
+}
+func (f *fakeStore) GetTenantAuditLog(_ context.Context, tenantID uuid.UUID, filter store.TenantAuditFilter) ([]*models.TenantAuditEntry, int, error) {
+	return f.getTenantAuditLog(tenantID, filter)
+}
+func (f *fakeStore) GetTokenVersions(_ context.Context, userID uuid.UUID, stationID *uuid.UUID) (int, int, error) {
+	if f.getTokenVersions == nil {
+		return 0, 0, nil
+	}
+	return f.getTokenVersions(userID, stationID)
+}
+func (f *fakeStore) SessionActive(_ context.Context, userID, familyID uuid.UUID) (bool, error) {
+	if f.sessionActive == nil {
+		return true, nil
+	}
+	return f.sessionActive(userID, familyID)
+}
+func (f *fakeStore) CreateRefreshToken(_ context.Context, rt *models.RefreshToken) error {
+	if f.createRefreshToken != nil {
+		return f.createRefreshToken(rt)
+	}
+	rt.ID, rt.FamilyID, rt.CreatedAt = uuid.New(), uuid.New(), time.Now()
+	f.refreshTokens = append(f.refreshTokens, rt)
+	return nil
+}
+func (f *fakeStore) RotateRefreshToken(_ context.Context, oldHash string, next *models.RefreshToken) error {
+	return f.rotateRefreshToken(oldHash, next)
+}
+func (f *fakeStore) RevokeRefreshTokenFamily(_ context.Context, userID, familyID uuid.UUID) error {
+	return f.revokeRefreshTokenFamily(userID, familyID)
+}
+func (f *fakeStore) RevokeUserSessions(_ context.Context, userID uuid.UUID) (int64, error) {
+	return f.revokeUserSessions(userID)
+}
+func (f *fakeStore) RevokeStationSessions(_ context.Context, eventID, stationID uuid.UUID) (int64, error) {
+	return f.revokeStationSessions(eventID, stationID)
+}
+func (f *fakeStore) PurgeExpiredRefreshTokens(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+
+type fakeUserToken struct {
+	UserID    uuid.UUID
+	Purpose   string
+	Hash      string
+	ExpiresAt time.Time
+}
+
+func (f *fakeStore) CreateUserToken(_ context.Context, userID uuid.UUID, purpose, tokenHash string, expiresAt time.Time) error {
+	if f.createUserToken != nil {
+		return f.createUserToken(userID, purpose, tokenHash, expiresAt)
+	}
+	f.userTokens = append(f.userTokens, fakeUserToken{UserID: userID, Purpose: purpose, Hash: tokenHash, ExpiresAt: expiresAt})
+	return nil
+}
+func (f *fakeStore) ResetPasswordWithToken(_ context.Context, tokenHash, passwordHash string) (uuid.UUID, error) {
+	return f.resetPasswordWithToken(tokenHash, passwordHash)
+}
+func (f *fakeStore) VerifyEmailWithToken(_ context.Context, tokenHash string) (uuid.UUID, error) {
+	return f.verifyEmailWithToken(tokenHash)
+}
+func (f *fakeStore) PurgeExpiredUserTokens(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+func (f *fakeStore) SetPendingTOTPSecret(_ context.Context, userID uuid.UUID, secret string) error {
+	return f.setPendingTOTPSecret(userID, secret)
+}
+func (f *fakeStore) GetTOTPSecret(_ context.Context, userID uuid.UUID) (string, bool, error) {
+	return f.getTOTPSecret(userID)
+}
+func (f *fakeStore) EnableTOTP(_ context.Context, userID uuid.UUID, step int64, recoveryCodeHashes []string) error {
+	return f.enableTOTP(userID, step, recoveryCodeHashes)
+}
+func (f *fakeStore) UseTOTPStep(_ context.Context, userID uuid.UUID, step int64) (bool, error) {
+	return f.useTOTPStep(userID, step)
+}
+func (f *fakeStore) UseRecoveryCode(_ context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	return f.useRecoveryCode(userID, codeHash)
+}
+func (f *fakeStore) CountUnusedRecoveryCodes(_ context.Context, userID uuid.UUID) (int, error) {
+	return f.countUnusedRecoveryCodes(userID)
+}
+func (f *fakeStore) ReplaceRecoveryCodes(_ context.Context, userID uuid.UUID, codeHashes []string) error {
+	return f.replaceRecoveryCodes(userID, codeHashes)
+}
+func (f *fakeStore) DisableTOTP(_ context.Context, userID uuid.UUID) error {
+	return f.disableTOTP(userID)
+}
+func (f *fakeStore) GetTenantTwoFactorRequired(_ context.Context, tenantID uuid.UUID) (bool, error) {
+	if f.getTenantTwoFactorRequired == nil {
+		return false, nil
+	}
+	return f.getTenantTwoFactorRequired(tenantID)
+}
+
+type fakeTwoFactorChallenge struct {
+	userID   uuid.UUID
+	attempts int
+	used     bool
+}
+
+func (f *fakeStore) CreateTwoFactorChallenge(_ context.Context, userID uuid.UUID, _ time.Time) (uuid.UUID, error) {
+	if f.twoFactorChallenges == nil {
+		f.twoFactorChallenges = map[uuid.UUID]*fakeTwoFactorChallenge{}
+	}
+	id := uuid.New()
+	f.twoFactorChallenges[id] = &fakeTwoFactorChallenge{userID: userID}
+	return id, nil
+}
+func (f *fakeStore) AttemptTwoFactorChallenge(_ context.Context, id, userID uuid.UUID) error {
+	if f.attemptTwoFactorChallenge != nil {
+		return f.attemptTwoFactorChallenge(id, userID)
+	}
+	ch := f.twoFactorChallenges[id]
+	if ch == nil || ch.userID != userID || ch.used || ch.attempts >= 5 {
+		return store.ErrTwoFactorChallengeSpent
+	}
+	ch.attempts++
+	return nil
+}
+func (f *fakeStore) UseTwoFactorChallenge(_ context.Context, id uuid.UUID) error {
+	ch := f.twoFactorChallenges[id]
+	if ch == nil || ch.used {
+		return store.ErrTwoFactorChallengeSpent
+	}
+	ch.used = true
+	return nil
+}
+func (f *fakeStore) GetTenantOIDCProvider(_ context.Context, tenantID uuid.UUID) (*models.TenantOIDCProvider, error) {
+	return f.getTenantOIDCProvider(tenantID)
+}
+func (f *fakeStore) UpsertTenantOIDCProvider(_ context.Context, p *models.TenantOIDCProvider) error {
+	return f.upsertTenantOIDCProvider(p)
+}
+func (f *fakeStore) DeleteTenantOIDCProvider(_ context.Context, tenantID uuid.UUID) (bool, error) {
+	return f.deleteTenantOIDCProvider(tenantID)
+}
+func (f *fakeStore) FindSSOTenantsByEmailDomain(_ context.Context, domain string) ([]models.SSOTenant, error) {
+	return f.findSSOTenantsByEmailDomain(domain)
+}
+func (f *fakeStore) CreateOIDCLoginRequest(_ context.Context, r *models.OIDCLoginRequest) error {
+	return f.createOIDCLoginRequest(r)
+}
+func (f *fakeStore) ConsumeOIDCLoginRequest(_ context.Context, stateHash string) (*models.OIDCLoginRequest, error) {
+	return f.consumeOIDCLoginRequest(stateHash)
+}
+func (f *fakeStore) LinkOIDCIdentity(_ context.Context, id models.OIDCIdentity) (*models.User, string, error) {
+	return f.linkOIDCIdentity(id)
+}
+func (f *fakeStore) ListTenantRoles(_ context.Context, tenantID uuid.UUID) ([]*models.TenantRole, error) {
+	return f.listTenantRoles(tenantID)
+}
+func (f *fakeStore) GetTenantRole(_ context.Context, tenantID, roleID uuid.UUID) (*models.TenantRole, error) {
+	return f.getTenantRole(tenantID, roleID)
+}
+func (f *fakeStore) CreateTenantRole(_ context.Context, r *models.TenantRole) error {
+	return f.createTenantRole(r)
+}
+func (f *fakeStore) UpdateTenantRole(_ context.Context, r *models.TenantRole) error {
+	return f.updateTenantRole(r)
+}
+func (f *fakeStore) DeleteTenantRole(_ context.Context, tenantID, roleID uuid.UUID) error {
+	return f.deleteTenantRole(tenantID, roleID)
+}
+func (f *fakeStore) SetMemberCustomRole(_ context.Context, tenantID, userID uuid.UUID, roleID *uuid.UUID) (bool, error) {
+	return f.setMemberCustomRole(tenantID, userID, roleID)
+}
+
+// GetMemberCustomRole defaults to no custom role, so handlers behind a
+// permission check keep their built-in role's permissions in tests.
+func (f *fakeStore) GetMemberCustomRole(_ context.Context, userID, tenantID uuid.UUID) (*models.TenantRole, error) {
+	if f.getMemberCustomRole == nil {
+		return nil, nil
+	}
+	return f.getMemberCustomRole(userID, tenantID)
+}
+func (f *fakeStore) GetTenantMember(_ context.Context, tenantID, userID uuid.UUID) (*models.User, error) {
+	return f.getTenantMember(tenantID, userID)
+}
+func (f *fakeStore) UpdateUserTenantRole(_ context.Context, userID, tenantID uuid.UUID, role string) error {
+	return f.updateUserTenantRole(userID, tenantID, role)
+}
+func (f *fakeStore) DeactivateMember(_ context.Context, tenantID, userID uuid.UUID) (int64, []store.RevokedStation, error) {
+	return f.deactivateMember(tenantID, userID)
+}
+func (f *fakeStore) ReactivateMember(_ context.Context, tenantID, userID uuid.UUID) error {
+	return f.reactivateMember(tenantID, userID)
+}
+func (f *fakeStore) RemoveUserFromTenant(_ context.Context, userID, tenantID uuid.UUID) ([]store.RevokedStation, error) {
+	return f.removeUserFromTenant(userID, tenantID)
+}
+func (f *fakeStore) CreateInvitedUser(_ context.Context, tenantID uuid.UUID, email, role string) (*models.User, error) {
+	return f.createInvitedUser(tenantID, email, role)
+}
+func (f *fakeStore) AcceptInvitationWithToken(_ context.Context, tokenHash, passwordHash string) (uuid.UUID, error) {
+	return f.acceptInvitationWithToken(tokenHash, passwordHash)
+}
+func (f *fakeStore) GetTicketEmailSettings(_ context.Context, eventID uuid.UUID) (*models.TicketEmailSettings, error) {
+	return f.getTicketEmailSettings(eventID)
+}
+func (f *fakeStore) UpsertTicketEmailSettings(_ context.Context, t *models.TicketEmailSettings) error {
+	return f.upsertTicketEmailSettings(t)
+}
+func (f *fakeStore) QueueTicketDeliveries(_ context.Context, eventID uuid.UUID, q store.TicketDeliveryQuery) (int64, error) {
+	return f.queueTicketDeliveries(eventID, q)
+}
+func (f *fakeStore) ListTicketDeliveries(_ context.Context, eventID uuid.UUID, status string, limit, offset int) ([]*models.TicketDelivery, int, error) {
+	return f.listTicketDeliveries(eventID, status, limit, offset)
+}
+func (f *fakeStore) CountTicketDeliveries(_ context.Context, eventID uuid.UUID) (map[string]int, error) {
+	return f.countTicketDeliveries(eventID)
+}
+func (f *fakeStore) GetTicketDelivery(_ context.Context, attendeeID uuid.UUID) (*models.TicketDelivery, error) {
+	return f.getTicketDelivery(attendeeID)
+}
+func (f *fakeStore) CountDueTicketDeliveries(_ context.Context, eventID uuid.UUID) (int, error) {
+	return f.countDueTicketDeliveries(eventID)
+}
+func (f *fakeStore) ClaimTicketDeliveries(_ context.Context, eventID uuid.UUID, limit int, lease time.Duration) ([]*models.TicketDelivery, error) {
+	return f.claimTicketDeliveries(eventID, limit, lease)
+}
+func (f *fakeStore) RecordTicketDelivery(_ context.Context, d *models.TicketDelivery) error {
+	return f.recordTicketDelivery(d)
+}
+func (f *fakeStore) GetTicketLayout(_ context.Context, eventID uuid.UUID) (json.RawMessage, error) {
+	return f.getTicketLayout(eventID)
+}
+func (f *fakeStore) UpdateTicketLayout(_ context.Context, eventID uuid.UUID, layout json.RawMessage) error {
+	return f.updateTicketLayout(eventID, layout)
+}
+
+// GetTenantWalletSettings is nil-safe: every event update consults it.
+func (f *fakeStore) GetTenantWalletSettings(_ context.Context, tenantID uuid.UUID) (*models.TenantWalletSettings, error) {
+	if f.getTenantWalletSettings == nil {
+		return nil, nil
+	}
+	return f.getTenantWalletSettings(tenantID)
+}
+func (f *fakeStore) UpsertTenantWalletSettings(_ context.Context, s *models.TenantWalletSettings) error {
+	return f.upsertTenantWalletSettings(s)
+}
+func (f *fakeStore) EnsureWalletPass(_ context.Context, attendeeID, eventID uuid.UUID, passTypeID string) (*models.WalletPass, error) {
+	return f.ensureWalletPass(attendeeID, eventID, passTypeID)
+}
+func (f *fakeStore) GetWalletPass(_ context.Context, passTypeID string, serial uuid.UUID) (*models.WalletPass, error) {
+	return f.getWalletPass(passTypeID, serial)
+}
+func (f *fakeStore) RegisterWalletDevice(_ context.Context, deviceID string, serial uuid.UUID, pushToken string) (bool, error) {
+	return f.registerWalletDevice(deviceID, serial, pushToken)
+}
+func (f *fakeStore) UnregisterWalletDevice(_ context.Context, deviceID string, serial uuid.UUID) (bool, error) {
+	return f.unregisterWalletDevice(deviceID, serial)
+}
+func (f *fakeStore) GetWalletDevicePasses(_ context.Context, deviceID, passTypeID string, since *time.Time) ([]uuid.UUID, time.Time, error) {
+	return f.getWalletDevicePasses(deviceID, passTypeID, since)
+}
+func (f *fakeStore) TouchWalletPasses(_ context.Context, eventID uuid.UUID) (int64, error) {
+	return f.touchWalletPasses(eventID)
+}
+func (f *fakeStore) GetWalletPushTargets(_ context.Context, eventID uuid.UUID) ([]models.WalletPushTarget, error) {
+	return f.getWalletPushTargets(eventID)
+}
+func (f *fakeStore) DeleteWalletPushTokens(_ context.Context, tokens []string) (int64, error) {
+	return f.deleteWalletPushTokens(tokens)
+}
 func (f *fakeStore) GetUserTenantRole(_ context.Context, userID, tenantID uuid.UUID) (string, error) {
 	return f.getUserTenantRole(userID, tenantID)
 }
@@ -432,6 +858,9 @@ func (f *fakeStore) ConsumeProvisioningToken(_ context.Context, token string) (*
 func (f *fakeStore) CreateStation(_ context.Context, eventID, staffUserID uuid.UUID, deviceInfo map[string]interface{}) (*models.Station, error) {
 	return f.createStation(eventID, staffUserID, deviceInfo)
 }
+func (f *fakeStore) GetStationByID(_ context.Context, id uuid.UUID) (*models.Station, error) {
+	return f.getStationByID(id)
+}
 
 func (f *fakeStore) GetEventsByTenantID(_ context.Context, tenantID uuid.UUID) ([]*models.Event, error) {
 	return f.getEventsByTenantID(tenantID)
@@ -439,6 +868,9 @@ func (f *fakeStore) GetEventsByTenantID(_ context.Context, tenantID uuid.UUID) (
 func (f *fakeStore) CreateEvent(_ context.Context, event *models.Event) error {
 	return f.createEvent(event)
 }
+func (f *fakeStore) CloneEvent(_ context.Context, srcID uuid.UUID, clone *models.Event, opts store.EventCloneOptions) (*models.EventCloneCounts, error) {
+	return f.cloneEvent(srcID, clone, opts)
+}
 func (f *fakeStore) UpdateEvent(_ context.Context, event *models.Event) error {
 	return f.updateEvent(event)
 }
@@ -0,0 +1,617 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"idento/backend/internal/jobs"
+	mailer "idento/backend/internal/mail"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// Ticket emails mail each attendee their QR ticket. The ticket_deliveries
+// rows are the queue and the per-attendee delivery state: the send
+// endpoint (or an import, when the event asks for it) queues attendees
+// and a ticket_email job sends whatever is due. Temporary failures are
+// retried with backoff by jobs that StartTicketEmailRetries queues.
+
+// Audit actions for ticket emails (see audit.go).
+const (
+	auditUpdateTicketEmail = "update_ticket_email"
+	auditSendTicketEmails  = "send_ticket_emails"
+)
+
+const (
+	// ticketSendBatch is how many deliveries a job claims at a time.
+	ticketSendBatch = 50
+	// ticketSendLease is how long a claimed delivery stays invisible to
+	// other jobs; it only matters when its worker dies mid-send.
+	ticketSendLease = 5 * time.Minute
+	// ticketQRContentID names the inline QR image in the HTML body.
+	ticketQRContentID = "ticket-qr"
+
+	ticketTemplateMaxSubject = 300
+	ticketTemplateMaxBody    = 20000
+)
+
+// ticketRetryBackoff is the wait before each retry of a deferred ticket;
+// the attempt after the last one is final (failed).
+var ticketRetryBackoff = []time.Duration{5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 6 * time.Hour}
+
+// ticketDeliveryStatuses lists every delivery status, for filters and the
+// summary counts.
+var ticketDeliveryStatuses = []string{
+	models.TicketDeliveryQueued, models.TicketDeliveryDeferred, models.TicketDeliverySent,
+	models.TicketDeliveryFailed, models.TicketDeliveryBounced,
+}
+
+// ticketPlaceholder matches a {{name}} placeholder in an event's ticket
+// template. The standard names are first_name, last_name, email, company,
+// position, code, event_name, event_date and event_location; any other
+// name is looked up in the attendee's custom fields, and unknown names
+// render empty.
+var ticketPlaceholder = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+func ticketEventDate(event *models.Event) string {
+	if event.StartDate == nil {
+		return ""
+	}
+	return event.StartDate.Format("2006-01-02")
+}
+
+// ticketPlaceholders returns the values of an attendee's placeholders.
+func ticketPlaceholders(event *models.Event, a *models.Attendee) map[string]string {
+	values := map[string]string{}
+	for k, v := range a.CustomFields {
+		if v != nil {
+			values[k] = fmt.Sprint(v)
+		}
+	}
+	for k, v := range map[string]string{
+		"first_name":     a.FirstName,
+		"last_name":      a.LastName,
+		"email":          a.Email,
+		"company":        a.Company,
+		"position":       a.Position,
+		"code":           a.Code,
+		"event_name":     event.Name,
+		"event_date":     ticketEventDate(event),
+		"event_location": event.Location,
+	} {
+		values[k] = v
+	}
+	return values
+}
+
+func fillTicketPlaceholders(text string, values map[string]string) string {
+	return ticketPlaceholder.ReplaceAllStringFunc(text, func(m string) string {
+		return values[ticketPlaceholder.FindStringSubmatch(m)[1]]
+	})
+}
+
+// defaultTicketEmailSettings are the settings of an event that has not
+// saved any: the built-in template, QR inline, nothing sent on import.
+func defaultTicketEmailSettings(eventID uuid.UUID) *models.TicketEmailSettings {
+	return &models.TicketEmailSettings{
+		EventID:       eventID,
+		QRPlacement:   models.TicketQRInline,
+		DefaultLocale: mailer.DefaultLocale,
+		Templates:     map[string]models.TicketEmailTemplate{},
+	}
+}
+
+func (h *Handler) ticketEmailSettings(ctx context.Context, eventID uuid.UUID) (*models.TicketEmailSettings, error) {
+	settings, err := h.Store.GetTicketEmailSettings(ctx, eventID)
+	if err != nil || settings != nil {
+		return settings, err
+	}
+	return defaultTicketEmailSettings(eventID), nil
+}
+
+// attendeeTicketLocale picks the attendee's language from settings'
+// LocaleField (a standard or custom field), else DefaultLocale.
+func attendeeTicketLocale(settings *models.TicketEmailSettings, values map[string]string) string {
+	return mailer.Locale(values[settings.LocaleField], settings.DefaultLocale)
+}
+
+// ticketMessage renders an attendee's ticket email: the event's template
+// for the attendee's locale (the built-in one when it has none) with the
+// QR code inline in an HTML alternative or attached, per settings, and
+// the PDF ticket attached when pdfs is set.
+func ticketMessage(settings *models.TicketEmailSettings, event *models.Event, a *models.Attendee, pdfs *ticketPDFs) (mailer.Message, error) {
+	values := ticketPlaceholders(event, a)
+	locale := attendeeTicketLocale(settings, values)
+	var msg mailer.Message
+	if t, ok := settings.Templates[locale]; ok {
+		msg.Subject = fillTicketPlaceholders(t.Subject, values)
+		msg.Body = fillTicketPlaceholders(t.Body, values)
+	} else {
+		var err error
+		msg, err = mailer.Render(mailer.TemplateTicket, locale, mailer.TicketData{
+			FirstName: a.FirstName,
+			LastName:  a.LastName,
+			EventName: event.Name,
+			EventDate: ticketEventDate(event),
+			Location:  event.Location,
+			Code:      a.Code,
+		})
+		if err != nil {
+			return mailer.Message{}, err
+		}
+	}
+	qr, err := attendeeQRPNG(a.Code)
+	if err != nil {
+		return mailer.Message{}, fmt.Errorf("render QR code: %w", err)
+	}
+	msg.Subject = strings.Join(strings.Fields(msg.Subject), " ")
+	msg.ReplyTo = settings.ReplyTo
+	qrFile := mailer.Attachment{Filename: "ticket-" + a.Code + ".png", ContentType: "image/png", Data: qr}
+	if pdfs != nil {
+		pdf, err := pdfs.render(a)
+		if err != nil {
+			return mailer.Message{}, fmt.Errorf("render PDF ticket: %w", err)
+		}
+		msg.Attachments = append(msg.Attachments, mailer.Attachment{Filename: ticketFileName(a), ContentType: "application/pdf", Data: pdf})
+	}
+	if settings.QRPlacement == models.TicketQRAttachment {
+		msg.Attachments = append(msg.Attachments, qrFile)
+		return msg, nil
+	}
+	qrFile.ContentID = ticketQRContentID
+	msg.Attachments = append(msg.Attachments, qrFile)
+	msg.HTML = `<div style="font-family: sans-serif">` +
+		strings.ReplaceAll(html.EscapeString(msg.Body), "\n", "<br>\n") +
+		`<p><img src="cid:` + ticketQRContentID + `" alt="` + html.EscapeString(a.Code) + `" width="256" height="256"></p></div>`
+	return msg, nil
+}
+
+// TicketEmailSettingsRequest is the body of PUT
+// /api/events/{event_id}/ticket-email.
+type TicketEmailSettingsRequest struct {
+	SendOnImport  bool                                  `json:"send_on_import"`
+	QRPlacement   string                                `json:"qr_placement"`
+	AttachPDF     bool                                  `json:"attach_pdf"`
+	DefaultLocale string                                `json:"default_locale"`
+	LocaleField   string                                `json:"locale_field"`
+	ReplyTo       string                                `json:"reply_to"`
+	Templates     map[string]models.TicketEmailTemplate `json:"templates"`
+}
+
+func (r *TicketEmailSettingsRequest) validate() error {
+	if r.QRPlacement == "" {
+		r.QRPlacement = models.TicketQRInline
+	}
+	if r.QRPlacement != models.TicketQRInline && r.QRPlacement != models.TicketQRAttachment {
+		return errors.New("qr_placement must be inline or attachment")
+	}
+	if r.DefaultLocale == "" {
+		r.DefaultLocale = mailer.DefaultLocale
+	}
+	if mailer.Locale(r.DefaultLocale, "") != r.DefaultLocale {
+		return errors.New("default_locale must be en or ru")
+	}
+	r.LocaleField = strings.TrimSpace(r.LocaleField)
+	r.ReplyTo = strings.TrimSpace(r.ReplyTo)
+	if r.ReplyTo != "" {
+		if _, err := mail.ParseAddress(r.ReplyTo); err != nil {
+			return errors.New("reply_to must be an email address")
+		}
+	}
+	if r.Templates == nil {
+		r.Templates = map[string]models.TicketEmailTemplate{}
+	}
+	for locale, t := range r.Templates {
+		if mailer.Locale(locale, "") != locale {
+			return fmt.Errorf("templates: unsupported locale %q (use en or ru)", locale)
+		}
+		if strings.TrimSpace(t.Subject) == "" || strings.TrimSpace(t.Body) == "" {
+			return fmt.Errorf("templates.%s needs a subject and a body", locale)
+		}
+		if len(t.Subject) > ticketTemplateMaxSubject || len(t.Body) > ticketTemplateMaxBody {
+			return fmt.Errorf("templates.%s: subject is limited to %d and body to %d characters",
+				locale, ticketTemplateMaxSubject, ticketTemplateMaxBody)
+		}
+	}
+	return nil
+}
+
+// ticketEvent parses :event_id and checks the caller may send its tickets.
+func (h *Handler) ticketEvent(c echo.Context) (*models.Event, error) {
+	eventID, err := uuid.Parse(c.Param("event_id"))
+	if err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, "Invalid event ID")
+	}
+	event, err := h.requireEventOwnership(c, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.requirePermission(c, PermTicketsSend, eventID); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// GetTicketEmailSettings serves GET /api/events/{event_id}/ticket-email:
+// the event's ticket email settings, or the defaults when it has none.
+func (h *Handler) GetTicketEmailSettings(c echo.Context) error {
+	event, err := h.ticketEvent(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	settings, err := h.ticketEmailSettings(c.Request().Context(), event.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load ticket email settings"})
+	}
+	return c.JSON(http.StatusOK, settings)
+}
+
+// PutTicketEmailSettings serves PUT /api/events/{event_id}/ticket-email.
+func (h *Handler) PutTicketEmailSettings(c echo.Context) error {
+	event, err := h.ticketEvent(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	var req TicketEmailSettingsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := req.validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	ctx := c.Request().Context()
+	before, err := h.Store.GetTicketEmailSettings(ctx, event.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load ticket email settings"})
+	}
+	settings := &models.TicketEmailSettings{
+		EventID:       event.ID,
+		SendOnImport:  req.SendOnImport,
+		QRPlacement:   req.QRPlacement,
+		AttachPDF:     req.AttachPDF,
+		DefaultLocale: req.DefaultLocale,
+		LocaleField:   req.LocaleField,
+		ReplyTo:       req.ReplyTo,
+		Templates:     req.Templates,
+	}
+	if err := h.Store.UpsertTicketEmailSettings(ctx, settings); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save ticket email settings"})
+	}
+	var beforeView interface{}
+	if before != nil {
+		beforeView = ticketSettingsAuditView(before)
+	}
+	h.logTenantDiff(c, &event.ID, auditUpdateTicketEmail, "event", event.ID, beforeView, ticketSettingsAuditView(settings))
+	return c.JSON(http.StatusOK, settings)
+}
+
+func ticketSettingsAuditView(s *models.TicketEmailSettings) map[string]interface{} {
+	return map[string]interface{}{
+		"send_on_import": s.SendOnImport,
+		"qr_placement":   s.QRPlacement,
+		"attach_pdf":     s.AttachPDF,
+		"default_locale": s.DefaultLocale,
+		"locale_field":   s.LocaleField,
+		"reply_to":       s.ReplyTo,
+		"templates":      s.Templates,
+	}
+}
+
+// SendTicketEmailsRequest is the body of POST
+// /api/events/{event_id}/ticket-email/send. The filter fields mean what
+// the attendee list's query params do; AttendeeIDs narrows further.
+type SendTicketEmailsRequest struct {
+	AttendeeIDs []uuid.UUID `json:"attendee_ids"`
+	Code        string      `json:"code"`
+	Search      string      `json:"search"`
+	ZoneID      *uuid.UUID  `json:"zone_id"`
+	Status      string      `json:"status"` // checked_in or not_checked_in
+	// Resend mails attendees who already have a delivery record too
+	// (sent, failed or bounced ones included); by default they are
+	// skipped.
+	Resend bool `json:"resend"`
+}
+
+// SendTicketEmails serves POST /api/events/{event_id}/ticket-email/send:
+// it queues ticket emails for the selected attendees that have an email
+// address and are not blocked, and starts a ticket_email job to send
+// them (202 with the job). Nothing to send is a 200 with queued 0.
+func (h *Handler) SendTicketEmails(c echo.Context) error {
+	event, err := h.ticketEvent(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	if h.Mailer == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Email is not configured on this server (SMTP_HOST)"})
+	}
+	var req SendTicketEmailsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	q := store.TicketDeliveryQuery{
+		Filter:      store.AttendeeFilter{Code: req.Code, Search: req.Search, ZoneID: req.ZoneID},
+		AttendeeIDs: req.AttendeeIDs,
+		Resend:      req.Resend,
+	}
+	switch req.Status {
+	case "":
+	case "checked_in", "not_checked_in":
+		checkedIn := req.Status == "checked_in"
+		q.Filter.Status = &checkedIn
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "status must be checked_in or not_checked_in"})
+	}
+	n, err := h.Store.QueueTicketDeliveries(c.Request().Context(), event.ID, q)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to queue ticket emails"})
+	}
+	h.logTenantAction(c, &event.ID, auditSendTicketEmails, "event", event.ID, map[string]interface{}{
+		"queued": n, "resend": req.Resend, "attendee_ids": len(req.AttendeeIDs),
+	})
+	if n == 0 {
+		return c.JSON(http.StatusOK, map[string]int64{"queued": 0})
+	}
+	return h.enqueueJob(c, event, models.JobTypeTicketEmail, struct{}{}, int(n))
+}
+
+// GetTicketDeliveries serves GET /api/events/{event_id}/ticket-deliveries:
+// counts per status and one page of delivery records, newest activity
+// first (?status=, ?limit= up to 200, ?offset=).
+func (h *Handler) GetTicketDeliveries(c echo.Context) error {
+	event, err := h.ticketEvent(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	status := c.QueryParam("status")
+	if status != "" && !slices.Contains(ticketDeliveryStatuses, status) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "status must be one of " + strings.Join(ticketDeliveryStatuses, ", ")})
+	}
+	limit, offset := jobListDefaultLimit, 0
+	if v := c.QueryParam("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > jobListMaxLimit {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("limit must be between 1 and %d", jobListMaxLimit)})
+		}
+	}
+	if v := c.QueryParam("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "offset must be a non-negative integer"})
+		}
+	}
+	ctx := c.Request().Context()
+	counts, err := h.Store.CountTicketDeliveries(ctx, event.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load ticket deliveries"})
+	}
+	deliveries, total, err := h.Store.ListTicketDeliveries(ctx, event.ID, status, limit, offset)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load ticket deliveries"})
+	}
+	summary := map[string]int{}
+	for _, s := range ticketDeliveryStatuses {
+		summary[s] = counts[s]
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"summary":    summary,
+		"total":      total,
+		"deliveries": deliveries,
+	})
+}
+
+// GetAttendeeTicketDelivery serves GET /api/attendees/{id}/ticket-delivery:
+// the state of the attendee's ticket email, 404 when none was queued.
+func (h *Handler) GetAttendeeTicketDelivery(c echo.Context) error {
+	attendeeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid attendee ID"})
+	}
+	attendee, err := h.requireAttendeeOwnership(c, attendeeID)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	if err := h.requirePermission(c, PermTicketsSend, attendee.EventID); err != nil {
+		return writeErr(c, err)
+	}
+	d, err := h.Store.GetTicketDelivery(c.Request().Context(), attendeeID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load ticket delivery"})
+	}
+	if d == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "No ticket email was sent to this attendee"})
+	}
+	return c.JSON(http.StatusOK, d)
+}
+
+// queueImportTickets queues ticket emails for attendees an import just
+// created when the event sends tickets on import, and returns how many it
+// queued. Failures are logged: the import itself succeeded.
+func (h *Handler) queueImportTickets(ctx context.Context, event *models.Event, attendeeIDs []uuid.UUID) int {
+	if h.Mailer == nil || len(attendeeIDs) == 0 {
+		return 0
+	}
+	settings, err := h.Store.GetTicketEmailSettings(ctx, event.ID)
+	if err != nil {
+		log.Printf("Import: failed to load ticket email settings for event %s: %v", event.ID, err)
+		return 0
+	}
+	if settings == nil || !settings.SendOnImport {
+		return 0
+	}
+	n, err := h.Store.QueueTicketDeliveries(ctx, event.ID, store.TicketDeliveryQuery{AttendeeIDs: attendeeIDs})
+	if err != nil {
+		log.Printf("Import: failed to queue ticket emails for event %s: %v", event.ID, err)
+		return 0
+	}
+	if n == 0 {
+		return 0
+	}
+	job := &models.Job{
+		TenantID: event.TenantID,
+		EventID:  &event.ID,
+		Type:     models.JobTypeTicketEmail,
+		Progress: models.JobProgress{Total: int(n)},
+	}
+	if err := h.Store.EnqueueJob(ctx, job); err != nil {
+		// The deliveries stay queued; the retry sweep starts a job.
+		log.Printf("Import: failed to start ticket email job for event %s: %v", event.ID, err)
+	}
+	return int(n)
+}
+
+// ticketEmailResult is a ticket_email job's result: how the attempts it
+// made ended.
+type ticketEmailResult struct {
+	Sent     int `json:"sent"`
+	Deferred int `json:"deferred"`
+	Failed   int `json:"failed"`
+	Bounced  int `json:"bounced"`
+}
+
+// runTicketEmailJob sends the event's due ticket emails, batch by batch,
+// until none is due.
+func (h *Handler) runTicketEmailJob(ctx context.Context, job *models.Job, progress jobs.Progress) (*jobs.Output, error) {
+	if h.Mailer == nil {
+		return nil, errors.New("email is not configured on this server")
+	}
+	event, err := h.jobEvent(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := h.ticketEmailSettings(ctx, event.ID)
+	if err != nil {
+		return nil, errors.New("failed to load the ticket email settings")
+	}
+	var pdfs *ticketPDFs
+	if settings.AttachPDF {
+		if pdfs, err = h.newTicketPDFs(ctx, event); err != nil {
+			return nil, errors.New("failed to load the ticket layout")
+		}
+	}
+	total, err := h.Store.CountDueTicketDeliveries(ctx, event.ID)
+	if err != nil {
+		return nil, errors.New("failed to count the queued ticket emails")
+	}
+	var result ticketEmailResult
+	done := 0
+	for ctx.Err() == nil {
+		batch, err := h.Store.ClaimTicketDeliveries(ctx, event.ID, ticketSendBatch, ticketSendLease)
+		if err != nil {
+			return nil, errors.New("failed to claim queued ticket emails")
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, d := range batch {
+			if ctx.Err() != nil {
+				// Unsent claims come due again when their lease runs out.
+				break
+			}
+			h.sendTicket(ctx, settings, event, pdfs, d)
+			if err := h.Store.RecordTicketDelivery(ctx, d); err != nil {
+				log.Printf("Ticket email %s: failed to record the outcome: %v", d.ID, err)
+			}
+			switch d.Status {
+			case models.TicketDeliverySent:
+				result.Sent++
+			case models.TicketDeliveryDeferred:
+				result.Deferred++
+			case models.TicketDeliveryBounced:
+				result.Bounced++
+			default:
+				result.Failed++
+			}
+			done++
+			progress(done, max(total, done))
+		}
+	}
+	return &jobs.Output{Result: result}, nil
+}
+
+// sendTicket makes one attempt at d and sets its outcome on d: sent,
+// bounced (the address is unusable), deferred with the next attempt time,
+// or failed once the retries have run out or the email cannot be built.
+func (h *Handler) sendTicket(ctx context.Context, settings *models.TicketEmailSettings, event *models.Event, pdfs *ticketPDFs, d *models.TicketDelivery) {
+	fail := func(status string, err error) {
+		msg := err.Error()
+		d.Status, d.LastError = status, &msg
+	}
+	attendee, err := h.Store.GetAttendeeByID(ctx, d.AttendeeID)
+	if err != nil {
+		h.deferTicket(d, fmt.Errorf("load attendee: %w", err))
+		return
+	}
+	if attendee == nil {
+		fail(models.TicketDeliveryFailed, errors.New("the attendee was deleted"))
+		return
+	}
+	msg, err := ticketMessage(settings, event, attendee, pdfs)
+	if err != nil {
+		fail(models.TicketDeliveryFailed, err)
+		return
+	}
+	msg.To = d.Email
+	sendCtx, cancel := context.WithTimeout(ctx, mailSendTimeout)
+	defer cancel()
+	err = h.mailer().Send(sendCtx, msg)
+	switch {
+	case err == nil:
+		d.Status, d.LastError = models.TicketDeliverySent, nil
+	case mailer.IsBounce(err):
+		fail(models.TicketDeliveryBounced, err)
+	default:
+		h.deferTicket(d, err)
+	}
+}
+
+// deferTicket schedules a retry of d after a temporary failure, or fails
+// it when this was its last attempt.
+func (h *Handler) deferTicket(d *models.TicketDelivery, err error) {
+	msg := err.Error()
+	d.LastError = &msg
+	if d.Attempts > len(ticketRetryBackoff) {
+		d.Status = models.TicketDeliveryFailed
+		return
+	}
+	next := time.Now().Add(ticketRetryBackoff[d.Attempts-1])
+	d.Status, d.NextAttemptAt = models.TicketDeliveryDeferred, &next
+}
+
+// StartTicketEmailRetries launches a loop that, every interval until ctx is
+// cancelled, starts a ticket_email job for each event whose deferred
+// tickets came due. No-op without a mailer.
+func (h *Handler) StartTicketEmailRetries(ctx context.Context, interval time.Duration) {
+	if h.Mailer == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			passCtx, cancel := context.WithTimeout(ctx, time.Minute)
+			n, err := h.Store.EnqueueDueTicketEmailJobs(passCtx)
+			cancel()
+			if err != nil {
+				log.Printf("Ticket email retries: %v", err)
+			} else if n > 0 {
+				log.Printf("Ticket email retries: started %d job(s)", n)
+			}
+		}
+	}()
+}
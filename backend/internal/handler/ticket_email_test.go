@@ -0,0 +1,364 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"idento/backend/internal/mail"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// addressMailer is a mail.Sender that fails sends to the addresses in
+// errs and records the rest.
+type addressMailer struct {
+	sent []mail.Message
+	errs map[string]error
+}
+
+func (m *addressMailer) Send(_ context.Context, msg mail.Message) error {
+	if err := m.errs[msg.To]; err != nil {
+		return err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func ticketEventContext(e *echo.Echo, method, path, body string, event *models.Event, role string) (echo.Context, *httptest.ResponseRecorder) {
+	c, rec := newAuthedContext(e, method, path, body, event.TenantID.String(), role)
+	c.SetParamNames("event_id")
+	c.SetParamValues(event.ID.String())
+	return c, rec
+}
+
+func TestContractTicketEmailSettings(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	var saved *models.TicketEmailSettings
+	fs := &fakeStore{
+		getEventByID:           func(uuid.UUID) (*models.Event, error) { return event, nil },
+		getTicketEmailSettings: func(uuid.UUID) (*models.TicketEmailSettings, error) { return saved, nil },
+		upsertTicketEmailSettings: func(s *models.TicketEmailSettings) error {
+			s.UpdatedAt = time.Now()
+			saved = s
+			return nil
+		},
+	}
+	h := New(fs)
+	e := echo.New()
+	path := "/api/events/" + event.ID.String() + "/ticket-email"
+
+	c, rec := ticketEventContext(e, http.MethodGet, path, "", event, "admin")
+	if err := h.GetTicketEmailSettings(c); err != nil {
+		t.Fatalf("GetTicketEmailSettings: %v", err)
+	}
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"qr_placement":"inline"`) {
+		t.Fatalf("defaults: %d %s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodGet, path, rec)
+
+	body := `{"send_on_import":true,"qr_placement":"attachment","default_locale":"ru","locale_field":"lang",
+		"reply_to":"desk@example.com","templates":{"en":{"subject":"Your ticket, {{first_name}}","body":"See you at {{event_name}}."}}}`
+	c, rec = ticketEventContext(e, http.MethodPut, path, body, event, "admin")
+	if err := h.PutTicketEmailSettings(c); err != nil {
+		t.Fatalf("PutTicketEmailSettings: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodPut, path, rec)
+	if saved == nil || !saved.SendOnImport || saved.QRPlacement != models.TicketQRAttachment || saved.Templates["en"].Subject != "Your ticket, {{first_name}}" {
+		t.Fatalf("saved = %+v", saved)
+	}
+	if len(fs.tenantAudit) != 1 || fs.tenantAudit[0].Action != auditUpdateTicketEmail {
+		t.Errorf("audit = %+v", fs.tenantAudit)
+	}
+
+	for _, bad := range []string{
+		`{"qr_placement":"link"}`,
+		`{"default_locale":"de"}`,
+		`{"reply_to":"not an address"}`,
+		`{"templates":{"fr":{"subject":"s","body":"b"}}}`,
+		`{"templates":{"en":{"subject":"","body":"b"}}}`,
+	} {
+		c, rec = ticketEventContext(e, http.MethodPut, path, bad, event, "admin")
+		if err := h.PutTicketEmailSettings(c); err != nil {
+			t.Fatalf("PutTicketEmailSettings: %v", err)
+		}
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: want 400, got %d", bad, rec.Code)
+		}
+		validateResponse(t, http.MethodPut, path, rec)
+	}
+}
+
+// The send endpoint queues the selected attendees and starts a job; the
+// job's attempts end sent, bounced or deferred by how the server answers.
+func TestContractSendTicketEmails(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	attendees := map[uuid.UUID]*models.Attendee{}
+	var deliveries []*models.TicketDelivery
+	for i, email := range []string{"ok@example.com", "gone@example.com", "later@example.com"} {
+		a := contractAttendee(event.ID)
+		a.Email, a.Code = email, fmt.Sprintf("CODE%d", i)
+		attendees[a.ID] = a
+		deliveries = append(deliveries, &models.TicketDelivery{ID: uuid.New(), EventID: event.ID, AttendeeID: a.ID,
+			Email: email, Status: models.TicketDeliveryQueued})
+	}
+	var queuedWith store.TicketDeliveryQuery
+	var recorded []*models.TicketDelivery
+	claimed := false
+	fs := jobStore(&fakeStore{
+		getEventByID:           func(uuid.UUID) (*models.Event, error) { return event, nil },
+		getAttendeeByID:        func(id uuid.UUID) (*models.Attendee, error) { return attendees[id], nil },
+		getTicketEmailSettings: func(uuid.UUID) (*models.TicketEmailSettings, error) { return nil, nil },
+		queueTicketDeliveries: func(_ uuid.UUID, q store.TicketDeliveryQuery) (int64, error) {
+			queuedWith = q
+			return int64(len(deliveries)), nil
+		},
+		countDueTicketDeliveries: func(uuid.UUID) (int, error) { return len(deliveries), nil },
+		claimTicketDeliveries: func(_ uuid.UUID, limit int, _ time.Duration) ([]*models.TicketDelivery, error) {
+			if claimed {
+				return nil, nil
+			}
+			claimed = true
+			for _, d := range deliveries {
+				d.Attempts++
+			}
+			return deliveries, nil
+		},
+		recordTicketDelivery: func(d *models.TicketDelivery) error {
+			recorded = append(recorded, d)
+			return nil
+		},
+	})
+	h := New(fs)
+	e := echo.New()
+	path := "/api/events/" + event.ID.String() + "/ticket-email/send"
+	body := `{"status":"not_checked_in","search":"example"}`
+
+	// 503 until a mail server is configured.
+	c, rec := ticketEventContext(e, http.MethodPost, path, body, event, "admin")
+	if err := h.SendTicketEmails(c); err != nil {
+		t.Fatalf("SendTicketEmails: %v", err)
+	}
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("want 503, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodPost, path, rec)
+
+	mailer := &addressMailer{errs: map[string]error{
+		"gone@example.com":  fmt.Errorf("smtp: %w", mail.ErrRecipientRejected),
+		"later@example.com": errors.New("dial tcp: connection refused"),
+	}}
+	h.Mailer = mailer
+	c, rec = ticketEventContext(e, http.MethodPost, path, body, event, "admin")
+	if err := h.SendTicketEmails(c); err != nil {
+		t.Fatalf("SendTicketEmails: %v", err)
+	}
+	job := decodeAcceptedJob(t, rec, models.JobTypeTicketEmail)
+	validateResponse(t, http.MethodPost, path, rec)
+	if queuedWith.Filter.Search != "example" || queuedWith.Filter.Status == nil || *queuedWith.Filter.Status || queuedWith.Resend {
+		t.Errorf("queued with %+v", queuedWith)
+	}
+
+	_, progress, result, _, _ := runQueuedJob(t, h, fs, tenantID, job.ID)
+	if progress != (models.JobProgress{Done: 3, Total: 3}) {
+		t.Errorf("final progress = %+v", progress)
+	}
+	if r, ok := result.(ticketEmailResult); !ok || r != (ticketEmailResult{Sent: 1, Bounced: 1, Deferred: 1}) {
+		t.Errorf("result = %#v", result)
+	}
+	if len(recorded) != 3 {
+		t.Fatalf("recorded %d outcomes, want 3", len(recorded))
+	}
+	sent, bounced, deferred := recorded[0], recorded[1], recorded[2]
+	if sent.Status != models.TicketDeliverySent || sent.LastError != nil {
+		t.Errorf("sent = %+v", sent)
+	}
+	if bounced.Status != models.TicketDeliveryBounced || bounced.LastError == nil {
+		t.Errorf("bounced = %+v", bounced)
+	}
+	if deferred.Status != models.TicketDeliveryDeferred || deferred.NextAttemptAt == nil || time.Until(*deferred.NextAttemptAt) < 4*time.Minute {
+		t.Errorf("deferred = %+v", deferred)
+	}
+	if len(mailer.sent) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(mailer.sent))
+	}
+	msg := mailer.sent[0]
+	if msg.To != "ok@example.com" || !strings.Contains(msg.Body, "CODE0") || !strings.Contains(msg.HTML, "cid:"+ticketQRContentID) {
+		t.Errorf("message = %+v", msg)
+	}
+	if len(msg.Attachments) != 1 || msg.Attachments[0].ContentID != ticketQRContentID || msg.Attachments[0].ContentType != "image/png" {
+		t.Errorf("attachments = %+v", msg.Attachments)
+	}
+
+	// Nothing left to queue: 200, no job.
+	fs.queueTicketDeliveries = func(uuid.UUID, store.TicketDeliveryQuery) (int64, error) { return 0, nil }
+	c, rec = ticketEventContext(e, http.MethodPost, path, `{"attendee_ids":[]}`, event, "admin")
+	if err := h.SendTicketEmails(c); err != nil {
+		t.Fatalf("SendTicketEmails: %v", err)
+	}
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"queued":0`) {
+		t.Fatalf("empty send: %d %s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodPost, path, rec)
+
+	// Staff cannot send tickets.
+	fs.getMemberCustomRole = func(uuid.UUID, uuid.UUID) (*models.TenantRole, error) { return nil, nil }
+	c, rec = ticketEventContext(e, http.MethodPost, path, body, event, "staff")
+	if err := h.SendTicketEmails(c); err != nil {
+		t.Fatalf("SendTicketEmails: %v", err)
+	}
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("staff: want 403, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodPost, path, rec)
+}
+
+// The last attempt of a temporarily failing ticket is final.
+func TestTicketEmailRetriesRunOut(t *testing.T) {
+	d := &models.TicketDelivery{Attempts: 1}
+	new(Handler).deferTicket(d, errors.New("timeout"))
+	if d.Status != models.TicketDeliveryDeferred || d.NextAttemptAt == nil {
+		t.Fatalf("first failure: %+v", d)
+	}
+	d = &models.TicketDelivery{Attempts: len(ticketRetryBackoff) + 1}
+	new(Handler).deferTicket(d, errors.New("timeout"))
+	if d.Status != models.TicketDeliveryFailed || d.LastError == nil || *d.LastError != "timeout" {
+		t.Fatalf("last failure: %+v", d)
+	}
+}
+
+// An event template is picked by the attendee's locale field and filled
+// from standard and custom fields; locales without one use the built-in
+// template.
+func TestTicketMessageTemplates(t *testing.T) {
+	event := contractEvent(uuid.New(), "Tech Summit")
+	a := contractAttendee(event.ID)
+	a.CustomFields = map[string]interface{}{"lang": "ru", "seat": "B12"}
+	settings := &models.TicketEmailSettings{
+		QRPlacement:   models.TicketQRAttachment,
+		DefaultLocale: "en",
+		LocaleField:   "lang",
+		ReplyTo:       "desk@example.com",
+		Templates: map[string]models.TicketEmailTemplate{
+			"ru": {Subject: "Билет: {{ event_name }}", Body: "{{first_name}}, место {{seat}}, код {{code}}.{{unknown}}"},
+		},
+	}
+	msg, err := ticketMessage(settings, event, a, nil)
+	if err != nil {
+		t.Fatalf("ticketMessage: %v", err)
+	}
+	if msg.Subject != "Билет: Tech Summit" || msg.Body != "Ada, место B12, код ABC123." {
+		t.Errorf("ru message = %q / %q", msg.Subject, msg.Body)
+	}
+	if msg.HTML != "" || msg.ReplyTo != "desk@example.com" || len(msg.Attachments) != 1 ||
+		msg.Attachments[0].Filename != "ticket-ABC123.png" || msg.Attachments[0].ContentID != "" {
+		t.Errorf("attachment placement = %+v", msg)
+	}
+
+	a.CustomFields["lang"] = "en-GB"
+	msg, err = ticketMessage(settings, event, a, nil)
+	if err != nil {
+		t.Fatalf("ticketMessage: %v", err)
+	}
+	if !strings.Contains(msg.Subject, "Tech Summit") || !strings.Contains(msg.Body, "ABC123") || strings.Contains(msg.Body, "место") {
+		t.Errorf("built-in en message = %q / %q", msg.Subject, msg.Body)
+	}
+}
+
+func TestContractGetTicketDeliveries(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	attendee := contractAttendee(event.ID)
+	now := time.Now()
+	lastErr := "550 no such user"
+	delivery := &models.TicketDelivery{ID: uuid.New(), EventID: event.ID, AttendeeID: attendee.ID, Email: attendee.Email,
+		Status: models.TicketDeliveryBounced, Attempts: 1, LastError: &lastErr, BouncedAt: &now, CreatedAt: now, UpdatedAt: now}
+	var listedStatus string
+	withDelivery := attendee.ID
+	h := New(&fakeStore{
+		getEventByID:    func(uuid.UUID) (*models.Event, error) { return event, nil },
+		getAttendeeByID: func(uuid.UUID) (*models.Attendee, error) { return attendee, nil },
+		countTicketDeliveries: func(uuid.UUID) (map[string]int, error) {
+			return map[string]int{models.TicketDeliveryBounced: 1, models.TicketDeliverySent: 4}, nil
+		},
+		listTicketDeliveries: func(_ uuid.UUID, status string, limit, offset int) ([]*models.TicketDelivery, int, error) {
+			listedStatus = status
+			return []*models.TicketDelivery{delivery}, 1, nil
+		},
+		getTicketDelivery: func(id uuid.UUID) (*models.TicketDelivery, error) {
+			if id == withDelivery {
+				return delivery, nil
+			}
+			return nil, nil
+		},
+	})
+	e := echo.New()
+	path := "/api/events/" + event.ID.String() + "/ticket-deliveries?status=bounced&limit=10"
+	c, rec := ticketEventContext(e, http.MethodGet, path, "", event, "admin")
+	if err := h.GetTicketDeliveries(c); err != nil {
+		t.Fatalf("GetTicketDeliveries: %v", err)
+	}
+	if rec.Code != http.StatusOK || listedStatus != models.TicketDeliveryBounced {
+		t.Fatalf("want 200 for bounced, got %d (%q)", rec.Code, listedStatus)
+	}
+	validateResponse(t, http.MethodGet, path, rec)
+	var resp struct {
+		Summary map[string]int `json:"summary"`
+		Total   int            `json:"total"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Summary) != 5 || resp.Summary[models.TicketDeliverySent] != 4 || resp.Summary[models.TicketDeliveryQueued] != 0 || resp.Total != 1 {
+		t.Errorf("response = %+v", resp)
+	}
+
+	bad := "/api/events/" + event.ID.String() + "/ticket-deliveries?status=lost"
+	c, rec = ticketEventContext(e, http.MethodGet, bad, "", event, "admin")
+	if err := h.GetTicketDeliveries(c); err != nil {
+		t.Fatalf("GetTicketDeliveries: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("want 400, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodGet, bad, rec)
+
+	one := "/api/attendees/" + attendee.ID.String() + "/ticket-delivery"
+	c, rec = newAuthedContext(e, http.MethodGet, one, "", tenantID.String(), "admin")
+	c.SetParamNames("id")
+	c.SetParamValues(attendee.ID.String())
+	if err := h.GetAttendeeTicketDelivery(c); err != nil {
+		t.Fatalf("GetAttendeeTicketDelivery: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodGet, one, rec)
+
+	attendee.ID = uuid.New()
+	none := "/api/attendees/" + attendee.ID.String() + "/ticket-delivery"
+	c, rec = newAuthedContext(e, http.MethodGet, none, "", tenantID.String(), "admin")
+	c.SetParamNames("id")
+	c.SetParamValues(attendee.ID.String())
+	if err := h.GetAttendeeTicketDelivery(c); err != nil {
+		t.Fatalf("GetAttendeeTicketDelivery: %v", err)
+	}
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("want 404, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodGet, none, rec)
+}
@@ -0,0 +1,490 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"idento/backend/internal/jobs"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+	"idento/backend/internal/ticketpdf"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// PDF e-tickets: one printable page per attendee (see package ticketpdf),
+// laid out per the event's ticket layout and branded with the tenant's
+// logo and the event's uploaded fonts. They download one at a time or as
+// a zip of the attendee list's filter, and ride along on ticket emails
+// whose settings ask for it.
+
+// Audit actions for PDF tickets (see audit.go).
+const auditUpdateTicketLayout = "update_ticket_layout"
+
+const (
+	// maxLogoBytes caps a tenant logo fetched for tickets.
+	maxLogoBytes     = 2 << 20
+	logoFetchTimeout = 5 * time.Second
+)
+
+// publicHTTPClient fetches tenant logos. It only dials public addresses,
+// so a logo URL cannot make the server probe its own network.
+var publicHTTPClient = &http.Client{
+	Timeout: logoFetchTimeout,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: logoFetchTimeout, Control: dialPublicOnly}).DialContext,
+		TLSHandshakeTimeout: logoFetchTimeout,
+	},
+}
+
+func dialPublicOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return fmt.Errorf("%s is not a public address", host)
+	}
+	return nil
+}
+
+// logoClient returns the client that fetches tenant logos: LogoHTTP when
+// set (tests), else publicHTTPClient.
+func (h *Handler) logoClient() *http.Client {
+	if h.LogoHTTP != nil {
+		return h.LogoHTTP
+	}
+	return publicHTTPClient
+}
+
+// fetchLogo loads a tenant logo from its http(s) or data: URL.
+func (h *Handler) fetchLogo(ctx context.Context, raw string) ([]byte, error) {
+	if rest, ok := strings.CutPrefix(raw, "data:"); ok {
+		meta, data, ok := strings.Cut(rest, ",")
+		if !ok || !strings.HasSuffix(meta, ";base64") {
+			return nil, errors.New("data URL is not base64")
+		}
+		if base64.StdEncoding.DecodedLen(len(data)) > maxLogoBytes {
+			return nil, errors.New("logo is too large")
+		}
+		return base64.StdEncoding.DecodeString(data)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, errors.New("logo URL is not http(s)")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := h.logoClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("logo URL answered %s", res.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxLogoBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxLogoBytes {
+		return nil, errors.New("logo is too large")
+	}
+	return data, nil
+}
+
+// ticketLayout returns the event's ticket layout, or the default when it
+// has none.
+func (h *Handler) ticketLayout(ctx context.Context, eventID uuid.UUID) (ticketpdf.Layout, error) {
+	raw, err := h.Store.GetTicketLayout(ctx, eventID)
+	if err != nil {
+		return ticketpdf.Layout{}, err
+	}
+	if raw == nil {
+		return ticketpdf.DefaultLayout(), nil
+	}
+	var layout ticketpdf.Layout
+	if err := json.Unmarshal(raw, &layout); err != nil || layout.Normalize() != nil {
+		log.Printf("Event %s: unreadable ticket layout, using the default", eventID)
+		return ticketpdf.DefaultLayout(), nil
+	}
+	return layout, nil
+}
+
+// ticketBranding loads the logo and fonts layout asks for. Neither is
+// worth failing a ticket over: a logo that cannot be fetched is logged and
+// left out, and the built-in typeface stands in for missing fonts.
+func (h *Handler) ticketBranding(ctx context.Context, event *models.Event, layout ticketpdf.Layout) (ticketpdf.Branding, error) {
+	var b ticketpdf.Branding
+	if layout.ShowLogo {
+		tenant, err := h.Store.GetTenantByID(ctx, event.TenantID)
+		if err != nil {
+			return b, err
+		}
+		if tenant != nil && tenant.LogoURL != nil && *tenant.LogoURL != "" {
+			fetchCtx, cancel := context.WithTimeout(ctx, logoFetchTimeout)
+			b.Logo, err = h.fetchLogo(fetchCtx, *tenant.LogoURL)
+			cancel()
+			if err != nil {
+				log.Printf("Tickets for event %s: tenant logo left out: %v", event.ID, err)
+			}
+		}
+	}
+	if layout.FontFamily == "" {
+		return b, nil
+	}
+	fonts, err := h.Store.GetFontsByEventID(ctx, event.ID)
+	if err != nil {
+		return b, err
+	}
+	for _, f := range fonts {
+		if !strings.EqualFold(f.Family, layout.FontFamily) || (f.Style != "" && f.Style != "normal") ||
+			(f.Format != "truetype" && f.Format != "opentype") {
+			continue
+		}
+		slot := &b.Regular
+		if fontWeightBold(f.Weight) {
+			slot = &b.Bold
+		}
+		if *slot != nil {
+			continue
+		}
+		font, err := h.Store.GetFontByID(ctx, f.ID)
+		if err != nil {
+			return b, err
+		}
+		if font != nil {
+			*slot = font.Data
+		}
+	}
+	return b, nil
+}
+
+func fontWeightBold(weight string) bool {
+	if weight == "bold" {
+		return true
+	}
+	n, err := strconv.Atoi(weight)
+	return err == nil && n >= 600
+}
+
+// ticketPDFs renders the PDF tickets of one event.
+type ticketPDFs struct {
+	event    *models.Event
+	layout   ticketpdf.Layout
+	renderer *ticketpdf.Renderer
+}
+
+func (h *Handler) newTicketPDFs(ctx context.Context, event *models.Event) (*ticketPDFs, error) {
+	layout, err := h.ticketLayout(ctx, event.ID)
+	if err != nil {
+		return nil, err
+	}
+	branding, err := h.ticketBranding(ctx, event, layout)
+	if err != nil {
+		return nil, err
+	}
+	return &ticketPDFs{event: event, layout: layout, renderer: ticketpdf.NewRenderer(layout, branding)}, nil
+}
+
+// ticketEventDates formats the event's dates for a ticket: the start date,
+// or the range when it ends on another day.
+func ticketEventDates(event *models.Event) string {
+	start := ticketEventDate(event)
+	if start == "" || event.EndDate == nil {
+		return start
+	}
+	if end := event.EndDate.Format("2006-01-02"); end != start {
+		return start + " – " + end
+	}
+	return start
+}
+
+// render returns a's ticket as a PDF.
+func (t *ticketPDFs) render(a *models.Attendee) ([]byte, error) {
+	qr, err := attendeeQRPNG(a.Code)
+	if err != nil {
+		return nil, fmt.Errorf("render QR code: %w", err)
+	}
+	values := ticketPlaceholders(t.event, a)
+	ticket := ticketpdf.Ticket{
+		EventName: t.event.Name,
+		Dates:     ticketEventDates(t.event),
+		Location:  t.event.Location,
+		Name:      strings.TrimSpace(a.FirstName + " " + a.LastName),
+		Code:      a.Code,
+		QR:        qr,
+	}
+	if t.layout.TicketTypeField != "" {
+		ticket.Type = values[t.layout.TicketTypeField]
+	}
+	for _, field := range t.layout.Details {
+		if v := strings.TrimSpace(values[field]); v != "" {
+			ticket.Details = append(ticket.Details, v)
+		}
+	}
+	var buf bytes.Buffer
+	if err := t.renderer.Render(&buf, ticket); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ticketFileName is a's PDF ticket file name.
+func ticketFileName(a *models.Attendee) string {
+	return ticketFileBase(a) + ".pdf"
+}
+
+// ticketFileBase names a's ticket files; codes come from imports, so
+// anything but letters, digits, - and _ becomes _.
+func ticketFileBase(a *models.Attendee) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, a.Code)
+	if name == "" {
+		name = a.ID.String()
+	}
+	return "ticket-" + name
+}
+
+// GetTicketLayout serves GET /api/events/{event_id}/ticket-layout: the
+// event's ticket layout, or the default when it has none.
+func (h *Handler) GetTicketLayout(c echo.Context) error {
+	eventID, err := uuid.Parse(c.Param("event_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid event ID"})
+	}
+	if _, err := h.requireEventOwnership(c, eventID); err != nil {
+		return writeErr(c, err)
+	}
+	layout, err := h.ticketLayout(c.Request().Context(), eventID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load ticket layout"})
+	}
+	return c.JSON(http.StatusOK, layout)
+}
+
+// PutTicketLayout serves PUT /api/events/{event_id}/ticket-layout.
+func (h *Handler) PutTicketLayout(c echo.Context) error {
+	eventID, err := uuid.Parse(c.Param("event_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid event ID"})
+	}
+	if _, err := h.requireEventOwnership(c, eventID); err != nil {
+		return writeErr(c, err)
+	}
+	if err := h.requirePermission(c, PermTicketsSend, eventID); err != nil {
+		return writeErr(c, err)
+	}
+	var layout ticketpdf.Layout
+	if err := c.Bind(&layout); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := layout.Normalize(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	ctx := c.Request().Context()
+	before, err := h.ticketLayout(ctx, eventID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load ticket layout"})
+	}
+	raw, err := json.Marshal(layout)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save ticket layout"})
+	}
+	if err := h.Store.UpdateTicketLayout(ctx, eventID, raw); err != nil {
+		if errors.Is(err, store.ErrEventNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Event not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save ticket layout"})
+	}
+	h.logTenantDiff(c, &eventID, auditUpdateTicketLayout, "event", eventID, before, layout)
+	return c.JSON(http.StatusOK, layout)
+}
+
+// GetAttendeeTicketPDF serves GET /api/attendees/{id}/ticket.pdf: the
+// attendee's PDF ticket as a download.
+func (h *Handler) GetAttendeeTicketPDF(c echo.Context) error {
+	attendeeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid attendee ID"})
+	}
+	attendee, err := h.requireAttendeeOwnership(c, attendeeID)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	event, err := h.requireEventOwnership(c, attendee.EventID)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	ctx := c.Request().Context()
+	tickets, err := h.newTicketPDFs(ctx, event)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load ticket layout"})
+	}
+	pdf, err := tickets.render(attendee)
+	if err != nil {
+		log.Printf("Ticket PDF for attendee %s: %v", attendee.ID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to render ticket"})
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", ticketFileName(attendee)))
+	return c.Blob(http.StatusOK, "application/pdf", pdf)
+}
+
+// ticketPDFJob is the ticket_pdf job payload: the attendee filter.
+type ticketPDFJob struct {
+	Code   string     `json:"code,omitempty"`
+	Search string     `json:"search,omitempty"`
+	ZoneID *uuid.UUID `json:"zone_id,omitempty"`
+	Status *bool      `json:"status,omitempty"`
+}
+
+func (p *ticketPDFJob) filter() store.AttendeeFilter {
+	return store.AttendeeFilter{Code: p.Code, Search: p.Search, ZoneID: p.ZoneID, Status: p.Status}
+}
+
+func ticketZipName(event *models.Event) string {
+	return strings.ReplaceAll(event.Name, " ", "-") + "-tickets.zip"
+}
+
+// writeTicketZip streams the PDF tickets of the attendees matching f into
+// zw, one file per attendee in last-name order, and returns how many it
+// wrote; onTicket, when set, is called after each.
+func (h *Handler) writeTicketZip(ctx context.Context, tickets *ticketPDFs, f store.AttendeeFilter, zw *zip.Writer, onTicket func(count int)) (int, error) {
+	count := 0
+	err := h.Store.StreamAttendeeExport(ctx, tickets.event.ID, f, false, func(r *store.AttendeeExportRow) error {
+		pdf, err := tickets.render(r.Attendee)
+		if err != nil {
+			return fmt.Errorf("attendee %s: %w", r.Attendee.ID, err)
+		}
+		// PDFs are compressed already.
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: ticketFileName(r.Attendee), Method: zip.Store, Modified: time.Now()})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(pdf); err != nil {
+			return err
+		}
+		count++
+		if onTicket != nil {
+			onTicket(count)
+		}
+		return nil
+	})
+	return count, err
+}
+
+// ExportTicketPDFs serves GET /api/events/{event_id}/tickets.zip: the PDF
+// tickets of the attendees matching the attendee list's filters (code,
+// search, zone, status) as a zip streamed into the response. With
+// ?async=true it runs as a ticket_pdf job (202) whose artifact is the zip.
+func (h *Handler) ExportTicketPDFs(c echo.Context) error {
+	eventID, err := uuid.Parse(c.Param("event_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid event ID"})
+	}
+	event, err := h.requireEventOwnership(c, eventID)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	if err := h.requirePermission(c, PermExport, eventID); err != nil {
+		return writeErr(c, err)
+	}
+	filter, err := attendeeFilterFromQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if isAsync(c) {
+		return h.enqueueJob(c, event, models.JobTypeTicketPDF, ticketPDFJob{
+			Code:   filter.Code,
+			Search: filter.Search,
+			ZoneID: filter.ZoneID,
+			Status: filter.Status,
+		}, 0)
+	}
+
+	ctx := c.Request().Context()
+	tickets, err := h.newTicketPDFs(ctx, event)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load ticket layout"})
+	}
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "application/zip")
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", ticketZipName(event)))
+	zw := zip.NewWriter(res)
+	count, err := h.writeTicketZip(ctx, tickets, filter, zw, nil)
+	if err != nil && !res.Committed {
+		res.Header().Del(echo.HeaderContentType)
+		res.Header().Del(echo.HeaderContentDisposition)
+		log.Printf("Ticket zip for event %s: %v", eventID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to export tickets"})
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		c.Logger().Errorf("ticket zip for event %s aborted after %d tickets: %v", eventID, count, err)
+	}
+	return nil
+}
+
+// runTicketPDFJob renders a ticket zip; the zip is the job's artifact and
+// the result counts its tickets.
+func (h *Handler) runTicketPDFJob(ctx context.Context, job *models.Job, progress jobs.Progress) (*jobs.Output, error) {
+	var p ticketPDFJob
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return nil, fmt.Errorf("invalid job payload: %w", err)
+	}
+	event, err := h.jobEvent(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	tickets, err := h.newTicketPDFs(ctx, event)
+	if err != nil {
+		return nil, errors.New("failed to load the ticket layout")
+	}
+	total := h.countExportRows(ctx, event.ID, p.filter())
+	progress(0, total)
+	count := 0
+	artifact, err := h.spoolJobArtifact(ticketZipName(event), "application/zip", func(w io.Writer) error {
+		zw := zip.NewWriter(w)
+		n, err := h.writeTicketZip(ctx, tickets, p.filter(), zw, func(count int) {
+			progress(count, max(total, count))
+		})
+		count = n
+		if err == nil {
+			err = zw.Close()
+		}
+		if err != nil {
+			log.Printf("Ticket zip job %s: %v", job.ID, err)
+			return errors.New("failed to render the tickets")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &jobs.Output{Result: map[string]int{"tickets": count}, Artifact: artifact}, nil
+}
@@ -0,0 +1,308 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+	"idento/backend/internal/ticketpdf"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/image/font/gofont/gomono"
+)
+
+// ticketPDFStore serves one event, its attendees and a saved layout.
+func ticketPDFStore(event *models.Event, attendees ...*models.Attendee) *fakeStore {
+	var layout json.RawMessage
+	return &fakeStore{
+		getEventByID: func(uuid.UUID) (*models.Event, error) { return event, nil },
+		getAttendeeByID: func(id uuid.UUID) (*models.Attendee, error) {
+			for _, a := range attendees {
+				if a.ID == id {
+					return a, nil
+				}
+			}
+			return nil, nil
+		},
+		getTenantByID:   func(uuid.UUID) (*models.Tenant, error) { return contractTenant("Acme"), nil },
+		getTicketLayout: func(uuid.UUID) (json.RawMessage, error) { return layout, nil },
+		updateTicketLayout: func(_ uuid.UUID, l json.RawMessage) error {
+			layout = l
+			return nil
+		},
+		getAttendeesPage: func(uuid.UUID, store.AttendeeFilter) ([]*models.Attendee, int, error) {
+			return nil, len(attendees), nil
+		},
+		streamAttendeeExport: func(_ uuid.UUID, _ store.AttendeeFilter, _ bool, fn func(*store.AttendeeExportRow) error) error {
+			for _, a := range attendees {
+				if err := fn(&store.AttendeeExportRow{Attendee: a}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func TestContractTicketLayout(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	fs := ticketPDFStore(event)
+	h := New(fs)
+	e := echo.New()
+	path := "/api/events/" + event.ID.String() + "/ticket-layout"
+
+	c, rec := ticketEventContext(e, http.MethodGet, path, "", event, "admin")
+	if err := h.GetTicketLayout(c); err != nil {
+		t.Fatalf("GetTicketLayout: %v", err)
+	}
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"page_size":"a5"`) {
+		t.Fatalf("default layout: %d %s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodGet, path, rec)
+
+	body := `{"page_size":"a6","orientation":"landscape","accent_color":"#0055AA","font_family":"Inter",
+		"show_logo":false,"ticket_type_field":"tier","details":["company","seat"],"note":"Bring photo ID."}`
+	c, rec = ticketEventContext(e, http.MethodPut, path, body, event, "admin")
+	if err := h.PutTicketLayout(c); err != nil {
+		t.Fatalf("PutTicketLayout: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodPut, path, rec)
+	layout, err := h.ticketLayout(c.Request().Context(), event.ID)
+	if err != nil || layout.PageSize != "a6" || layout.TicketTypeField != "tier" || !slices.Equal(layout.Details, []string{"company", "seat"}) {
+		t.Fatalf("saved layout = %+v, %v", layout, err)
+	}
+	if len(fs.tenantAudit) != 1 || fs.tenantAudit[0].Action != auditUpdateTicketLayout {
+		t.Errorf("audit = %+v", fs.tenantAudit)
+	}
+
+	c, rec = ticketEventContext(e, http.MethodPut, path, `{"accent_color":"blue"}`, event, "admin")
+	if err := h.PutTicketLayout(c); err != nil {
+		t.Fatalf("PutTicketLayout: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("want 400, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodPut, path, rec)
+
+	// Staff cannot change the layout.
+	fs.getMemberCustomRole = func(uuid.UUID, uuid.UUID) (*models.TenantRole, error) { return nil, nil }
+	c, rec = ticketEventContext(e, http.MethodPut, path, body, event, "staff")
+	if err := h.PutTicketLayout(c); err != nil {
+		t.Fatalf("PutTicketLayout: %v", err)
+	}
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("staff: want 403, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodPut, path, rec)
+}
+
+// A single ticket carries the tenant's logo, fetched from its URL, and
+// renders in the event's uploaded TrueType font.
+func TestContractGetAttendeeTicketPDF(t *testing.T) {
+	var logo bytes.Buffer
+	if err := png.Encode(&logo, image.NewGray(image.Rect(0, 0, 30, 10))); err != nil {
+		t.Fatal(err)
+	}
+	logoHits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logoHits++
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(logo.Bytes())
+	}))
+	defer srv.Close()
+
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	start := time.Date(2027, 5, 1, 9, 0, 0, 0, time.UTC)
+	end := start.Add(48 * time.Hour)
+	event.StartDate, event.EndDate = &start, &end
+	attendee := contractAttendee(event.ID)
+	attendee.CustomFields = map[string]interface{}{"ticket_type": "VIP"}
+	fs := ticketPDFStore(event, attendee)
+	tenant := contractTenant("Acme")
+	logoURL := srv.URL + "/logo.png"
+	tenant.LogoURL = &logoURL
+	fs.getTenantByID = func(uuid.UUID) (*models.Tenant, error) { return tenant, nil }
+	fontID := uuid.New()
+	fs.getFontsByEventID = func(uuid.UUID) ([]*models.FontListItem, error) {
+		return []*models.FontListItem{
+			{ID: uuid.New(), Family: "Mono", Weight: "normal", Style: "normal", Format: "woff2"},
+			{ID: fontID, Family: "Mono", Weight: "400", Style: "normal", Format: "truetype"},
+		}, nil
+	}
+	fontLoads := 0
+	fs.getFontByID = func(id uuid.UUID) (*models.Font, error) {
+		fontLoads++
+		if id != fontID {
+			t.Errorf("loaded font %s, want only the TrueType one", id)
+		}
+		return &models.Font{ID: id, Data: gomono.TTF}, nil
+	}
+	fs.getTicketLayout = func(uuid.UUID) (json.RawMessage, error) {
+		return json.RawMessage(`{"font_family":"mono","show_logo":true,"ticket_type_field":"ticket_type"}`), nil
+	}
+	h := New(fs)
+	h.LogoHTTP = srv.Client()
+
+	path := "/api/attendees/" + attendee.ID.String() + "/ticket.pdf"
+	c, rec := newAuthedContext(echo.New(), http.MethodGet, path, "", tenantID.String(), "staff")
+	c.SetParamNames("id")
+	c.SetParamValues(attendee.ID.String())
+	if err := h.GetAttendeeTicketPDF(c); err != nil {
+		t.Fatalf("GetAttendeeTicketPDF: %v", err)
+	}
+	if rec.Code != http.StatusOK || rec.Header().Get(echo.HeaderContentType) != "application/pdf" {
+		t.Fatalf("want a 200 PDF, got %d %s", rec.Code, rec.Header().Get(echo.HeaderContentType))
+	}
+	validateResponse(t, http.MethodGet, path, rec)
+	if got := rec.Header().Get(echo.HeaderContentDisposition); got != `attachment; filename="ticket-ABC123.pdf"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	pdf := rec.Body.String()
+	if !strings.HasPrefix(pdf, "%PDF-") {
+		t.Fatalf("body is not a PDF: %.40q", pdf)
+	}
+	if n := strings.Count(pdf, "/Subtype /Image"); n != 2 {
+		t.Errorf("%d images, want the logo and the QR code", n)
+	}
+	if fontLoads != 1 {
+		t.Errorf("font loaded %d times, want 1", fontLoads)
+	}
+	if logoHits != 1 {
+		t.Errorf("logo fetched %d times, want 1", logoHits)
+	}
+
+	missing := "/api/attendees/" + uuid.NewString() + "/ticket.pdf"
+	c, rec = newAuthedContext(echo.New(), http.MethodGet, missing, "", tenantID.String(), "admin")
+	c.SetParamNames("id")
+	c.SetParamValues(strings.Split(missing, "/")[3])
+	if err := h.GetAttendeeTicketPDF(c); err != nil {
+		t.Fatalf("GetAttendeeTicketPDF: %v", err)
+	}
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("want 404, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodGet, missing, rec)
+}
+
+func TestContractExportTicketPDFs(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	first, second := contractAttendee(event.ID), contractAttendee(event.ID)
+	second.Code = "../EVIL"
+	fs := jobStore(ticketPDFStore(event, first, second))
+	h := New(fs)
+	e := echo.New()
+
+	zipNames := func(data []byte) []string {
+		t.Helper()
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("read zip: %v", err)
+		}
+		var names []string
+		for _, f := range zr.File {
+			names = append(names, f.Name)
+		}
+		return names
+	}
+	want := []string{"ticket-ABC123.pdf", "ticket-___EVIL.pdf"}
+
+	path := "/api/events/" + event.ID.String() + "/tickets.zip?status=not_checked_in"
+	c, rec := ticketEventContext(e, http.MethodGet, path, "", event, "admin")
+	if err := h.ExportTicketPDFs(c); err != nil {
+		t.Fatalf("ExportTicketPDFs: %v", err)
+	}
+	if rec.Code != http.StatusOK || rec.Header().Get(echo.HeaderContentType) != "application/zip" {
+		t.Fatalf("want a 200 zip, got %d %s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodGet, path, rec)
+	if got := zipNames(rec.Body.Bytes()); !slices.Equal(got, want) {
+		t.Errorf("zip holds %v, want %v", got, want)
+	}
+
+	async := path + "&async=true"
+	c, rec = ticketEventContext(e, http.MethodGet, async, "", event, "admin")
+	if err := h.ExportTicketPDFs(c); err != nil {
+		t.Fatalf("ExportTicketPDFs: %v", err)
+	}
+	job := decodeAcceptedJob(t, rec, models.JobTypeTicketPDF)
+	validateResponse(t, http.MethodGet, async, rec)
+	_, progress, result, artifact, data := runQueuedJob(t, h, fs, tenantID, job.ID)
+	if progress != (models.JobProgress{Done: 2, Total: 2}) {
+		t.Errorf("final progress = %+v", progress)
+	}
+	if n, ok := result.(map[string]int); !ok || n["tickets"] != 2 {
+		t.Errorf("result = %#v, want tickets 2", result)
+	}
+	if artifact == nil || artifact.Name != "Tech-Summit-tickets.zip" || artifact.ContentType != "application/zip" {
+		t.Fatalf("artifact = %+v", artifact)
+	}
+	if got := zipNames(data); !slices.Equal(got, want) {
+		t.Errorf("job zip holds %v, want %v", got, want)
+	}
+
+	bad := "/api/events/" + event.ID.String() + "/tickets.zip?status=maybe"
+	c, rec = ticketEventContext(e, http.MethodGet, bad, "", event, "admin")
+	if err := h.ExportTicketPDFs(c); err != nil {
+		t.Fatalf("ExportTicketPDFs: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("want 400, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodGet, bad, rec)
+}
+
+// Ticket emails attach the PDF when the settings ask for it.
+func TestTicketMessageAttachesPDF(t *testing.T) {
+	event := contractEvent(uuid.New(), "Tech Summit")
+	a := contractAttendee(event.ID)
+	layout := ticketpdf.DefaultLayout()
+	pdfs := &ticketPDFs{event: event, layout: layout, renderer: ticketpdf.NewRenderer(layout, ticketpdf.Branding{})}
+	msg, err := ticketMessage(defaultTicketEmailSettings(event.ID), event, a, pdfs)
+	if err != nil {
+		t.Fatalf("ticketMessage: %v", err)
+	}
+	if len(msg.Attachments) != 2 {
+		t.Fatalf("attachments = %+v", msg.Attachments)
+	}
+	pdf := msg.Attachments[0]
+	if pdf.Filename != "ticket-ABC123.pdf" || pdf.ContentType != "application/pdf" || !bytes.HasPrefix(pdf.Data, []byte("%PDF-")) {
+		t.Errorf("PDF attachment = %s %s", pdf.Filename, pdf.ContentType)
+	}
+}
+
+func TestLogoFetchRefusesPrivateAddresses(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:80", "10.1.2.3:443", "[::1]:80", "169.254.169.254:80", "0.0.0.0:80"} {
+		if err := dialPublicOnly("tcp", addr, nil); err == nil {
+			t.Errorf("%s: dial allowed", addr)
+		}
+	}
+	if err := dialPublicOnly("tcp", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("public address refused: %v", err)
+	}
+
+	h := New(&fakeStore{})
+	if _, err := h.fetchLogo(t.Context(), "file:///etc/passwd"); err == nil {
+		t.Error("file URL fetched")
+	}
+	data, err := h.fetchLogo(t.Context(), "data:image/png;base64,iVBORw0KGgo=")
+	if err != nil || !bytes.HasPrefix(data, []byte("\x89PNG")) {
+		t.Errorf("data URL = %q, %v", data, err)
+	}
+}
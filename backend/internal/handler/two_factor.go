@@ -0,0 +1,491 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"idento/backend/internal/config"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+	"idento/backend/internal/totp"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// Audit actions for two-factor changes (see audit.go).
+const (
+	auditEnableTwoFactor  = "enable_two_factor"
+	auditDisableTwoFactor = "disable_two_factor"
+)
+
+const (
+	// twoFactorChallengeTTL bounds how long a password-verified login may
+	// wait for its second factor.
+	twoFactorChallengeTTL = 5 * time.Minute
+	// totpIssuer is the account label prefix shown in authenticator apps.
+	totpIssuer         = "Idento"
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+)
+
+// twoFactorChallengeClaims identify a user who passed the password step.
+// They are signed with a key derived from JWT_SECRET, so a challenge token
+// is never accepted where an access token is expected. The token ID is the
+// stored challenge (see Store.AttemptTwoFactorChallenge), which limits the
+// codes tried with it and lets it sign in only once.
+type twoFactorChallengeClaims struct {
+	UserID   string `json:"user_id"`
+	TenantID string `json:"tenant_id,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func challengeKey() ([]byte, error) {
+	secret := config.JWTSecret()
+	if secret == "" {
+		return nil, fmt.Errorf("JWT_SECRET environment variable not set")
+	}
+	sum := sha256.Sum256([]byte("two-factor-challenge:" + secret))
+	return sum[:], nil
+}
+
+// twoFactorChallenge answers a correct password on a two-factor account:
+// no session yet, only a challenge token for POST /auth/login/2fa.
+// tenantID, when set, is where the session will start.
+func (h *Handler) twoFactorChallenge(c echo.Context, status int, userID uuid.UUID, tenantID *uuid.UUID) error {
+	key, err := challengeKey()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+	}
+	expiresAt := time.Now().Add(twoFactorChallengeTTL)
+	challengeID, err := h.Store.CreateTwoFactorChallenge(c.Request().Context(), userID, expiresAt)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+	}
+	claims := &twoFactorChallengeClaims{
+		UserID: userID.String(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        challengeID.String(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	if tenantID != nil {
+		claims.TenantID = tenantID.String()
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+	}
+	return c.JSON(status, map[string]interface{}{
+		"two_factor_required": true,
+		"challenge_token":     token,
+		"expires_at":          expiresAt,
+	})
+}
+
+func parseTwoFactorChallenge(token string) (*twoFactorChallengeClaims, error) {
+	key, err := challengeKey()
+	if err != nil {
+		return nil, err
+	}
+	claims := &twoFactorChallengeClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		return key, nil
+	})
+	if err != nil || !parsed.Valid {
+		return nil, errors.New("invalid challenge token")
+	}
+	return claims, nil
+}
+
+// newRecoveryCodes returns recoveryCodeCount fresh codes ("xxxxx-xxxxx")
+// and their hashes.
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(enc.EncodeToString(b))[:recoveryCodeLength]
+		codes = append(codes, raw[:recoveryCodeLength/2]+"-"+raw[recoveryCodeLength/2:])
+		hashes = append(hashes, hashOpaqueToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode accepts a recovery code as typed: any case, with or
+// without the dash and spaces.
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+}
+
+// checkTOTP validates a code against userID's enabled secret and spends its
+// time step, so the same code cannot be used twice.
+func (h *Handler) checkTOTP(ctx context.Context, userID uuid.UUID, code string) (bool, error) {
+	secret, enabled, err := h.Store.GetTOTPSecret(ctx, userID)
+	if err != nil || !enabled {
+		return false, err
+	}
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return h.Store.UseTOTPStep(ctx, userID, step)
+}
+
+// checkSecondFactor accepts either a TOTP code or a recovery code.
+func (h *Handler) checkSecondFactor(ctx context.Context, userID uuid.UUID, code, recoveryCode string) (bool, error) {
+	switch {
+	case code != "":
+		return h.checkTOTP(ctx, userID, code)
+	case recoveryCode != "":
+		return h.Store.UseRecoveryCode(ctx, userID, hashOpaqueToken(normalizeRecoveryCode(recoveryCode)))
+	}
+	return false, nil
+}
+
+// verifySecondFactor checks a code a signed-in user offers to change their
+// two-factor settings. It goes through a one-off challenge, so a wrong code
+// counts towards the same lockout as LoginTwoFactor's and a locked-out user
+// gets store.ErrTwoFactorLockedOut without the code being checked. Only
+// TOTP codes are accepted unless allowRecovery is set.
+func (h *Handler) verifySecondFactor(ctx context.Context, userID uuid.UUID, req *TwoFactorCodeRequest, allowRecovery bool) (bool, error) {
+	challengeID, err := h.Store.CreateTwoFactorChallenge(ctx, userID, time.Now().Add(twoFactorChallengeTTL))
+	if err != nil {
+		return false, err
+	}
+	if err := h.Store.AttemptTwoFactorChallenge(ctx, challengeID, userID); err != nil {
+		return false, err
+	}
+	var ok bool
+	if allowRecovery {
+		ok, err = h.checkSecondFactor(ctx, userID, req.Code, req.RecoveryCode)
+	} else {
+		ok, err = h.checkTOTP(ctx, userID, req.Code)
+	}
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, h.Store.UseTwoFactorChallenge(ctx, challengeID)
+}
+
+func twoFactorLocked(c echo.Context) error {
+	return c.JSON(http.StatusTooManyRequests, map[string]string{"code": "two_factor_locked", "error": "Too many incorrect codes, try again later"})
+}
+
+func invalidSecondFactor(c echo.Context, status int) error {
+	return c.JSON(status, map[string]string{"code": "two_factor_invalid", "error": "Invalid authentication code"})
+}
+
+// twoFactorPolicyApplies reports whether the tenant policy covers role.
+func twoFactorPolicyApplies(role string) bool {
+	return role == "admin" || role == "manager"
+}
+
+// TwoFactorLoginRequest is the JSON body for POST /auth/login/2fa: the
+// challenge from Login plus either a TOTP code or a recovery code.
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code,omitempty"`
+	RecoveryCode   string `json:"recovery_code,omitempty"`
+}
+
+// LoginTwoFactor serves POST /auth/login/2fa, the second step of Login for
+// an account with two-factor enabled. The response is Login's. Each code
+// spends an attempt of the challenge before it is checked; a spent
+// challenge sends the user back to the password, and too many wrong codes
+// across challenges answer 429 until the lockout passes.
+func (h *Handler) LoginTwoFactor(c echo.Context) error {
+	req := new(TwoFactorLoginRequest)
+	if err := c.Bind(req); err != nil || req.ChallengeToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "challenge_token and code or recovery_code are required"})
+	}
+	challenge, err := parseTwoFactorChallenge(req.ChallengeToken)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"code": "challenge_invalid", "error": "Sign-in expired, enter your password again"})
+	}
+	userID, err := uuid.Parse(challenge.UserID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"code": "challenge_invalid", "error": "Sign-in expired, enter your password again"})
+	}
+	challengeID, err := uuid.Parse(challenge.ID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"code": "challenge_invalid", "error": "Sign-in expired, enter your password again"})
+	}
+
+	ctx := c.Request().Context()
+	err = h.Store.AttemptTwoFactorChallenge(ctx, challengeID, userID)
+	if errors.Is(err, store.ErrTwoFactorLockedOut) {
+		return twoFactorLocked(c)
+	}
+	if errors.Is(err, store.ErrTwoFactorChallengeSpent) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"code": "challenge_invalid", "error": "Sign-in expired, enter your password again"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify code"})
+	}
+	ok, err := h.checkSecondFactor(ctx, userID, req.Code, req.RecoveryCode)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify code"})
+	}
+	if !ok {
+		return invalidSecondFactor(c, http.StatusUnauthorized)
+	}
+	err = h.Store.UseTwoFactorChallenge(ctx, challengeID)
+	if errors.Is(err, store.ErrTwoFactorChallengeSpent) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"code": "challenge_invalid", "error": "Sign-in expired, enter your password again"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify code"})
+	}
+	user, err := h.Store.GetUserByID(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	var preferTenant *uuid.UUID
+	if id, err := uuid.Parse(challenge.TenantID); err == nil {
+		preferTenant = &id
+	}
+	return h.completeLogin(c, user, preferTenant, true)
+}
+
+// twoFactorCaller resolves the signed-in user for the /api/auth/2fa
+// endpoints. Impersonation sessions may not change the operator's factors.
+func twoFactorCaller(c echo.Context) (*models.JWTCustomClaims, uuid.UUID, error) {
+	claims, err := claimsFromContext(c)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	if claims.ImpersonatedBy != "" {
+		return nil, uuid.Nil, newHTTPError(http.StatusForbidden, "two-factor settings are not available during impersonation")
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, uuid.Nil, newHTTPError(http.StatusUnauthorized, "Invalid token")
+	}
+	return claims, userID, nil
+}
+
+// GetTwoFactorStatus serves GET /api/auth/2fa: whether the caller has
+// two-factor enabled, how many recovery codes are left, and whether the
+// current organization requires it for the caller's role.
+func (h *Handler) GetTwoFactorStatus(c echo.Context) error {
+	claims, userID, err := twoFactorCaller(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	ctx := c.Request().Context()
+	_, enabled, err := h.Store.GetTOTPSecret(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load two-factor status"})
+	}
+	resp := map[string]interface{}{"enabled": enabled, "required": false}
+	if enabled {
+		n, err := h.Store.CountUnusedRecoveryCodes(ctx, userID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load two-factor status"})
+		}
+		resp["recovery_codes_remaining"] = n
+	}
+	if tenantID, err := uuid.Parse(claims.TenantID); err == nil && twoFactorPolicyApplies(claims.Role) {
+		required, err := h.Store.GetTenantTwoFactorRequired(ctx, tenantID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load two-factor status"})
+		}
+		resp["required"] = required
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// SetupTwoFactor serves POST /api/auth/2fa/setup: it generates a new TOTP
+// secret for the caller and returns it with the otpauth:// URI to show as a
+// QR code. Two-factor is not on until EnableTwoFactor confirms a code.
+func (h *Handler) SetupTwoFactor(c echo.Context) error {
+	_, userID, err := twoFactorCaller(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	ctx := c.Request().Context()
+	user, err := h.Store.GetUserByID(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load user"})
+	}
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate secret"})
+	}
+	err = h.Store.SetPendingTOTPSecret(ctx, userID, secret)
+	if errors.Is(err, store.ErrTwoFactorAlreadyEnabled) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Two-factor authentication is already enabled"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to set up two-factor authentication"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(totpIssuer, user.Email, secret),
+	})
+}
+
+// TwoFactorCodeRequest is the JSON body of the /api/auth/2fa endpoints that
+// need proof of the second factor. RecoveryCode is accepted only by
+// DisableTwoFactor.
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// EnableTwoFactor serves POST /api/auth/2fa/enable: a first valid code
+// confirms the secret from SetupTwoFactor. The response carries the
+// recovery codes (shown only this once) and a new session signed in with
+// two-factor, replacing the caller's current one.
+func (h *Handler) EnableTwoFactor(c echo.Context) error {
+	claims, userID, err := twoFactorCaller(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	req := new(TwoFactorCodeRequest)
+	if err := c.Bind(req); err != nil || req.Code == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "code is required"})
+	}
+	ctx := c.Request().Context()
+	secret, enabled, err := h.Store.GetTOTPSecret(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to enable two-factor authentication"})
+	}
+	if enabled {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Two-factor authentication is already enabled"})
+	}
+	if secret == "" {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Set up two-factor authentication first"})
+	}
+	step, ok := totp.Validate(secret, req.Code, time.Now())
+	if !ok {
+		return invalidSecondFactor(c, http.StatusBadRequest)
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate recovery codes"})
+	}
+	err = h.Store.EnableTOTP(ctx, userID, step, hashes)
+	if errors.Is(err, store.ErrTwoFactorNotPending) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Set up two-factor authentication first"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to enable two-factor authentication"})
+	}
+	h.logTenantAction(c, nil, auditEnableTwoFactor, "user", userID, nil)
+
+	tenantID, err := tenantIDFromContext(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	session, err := h.startSession(c, userID, tenantID, claims.Role, nil, true)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
+	}
+	if oldSession, err := uuid.Parse(claims.SessionID); err == nil {
+		if err := h.Store.RevokeRefreshTokenFamily(ctx, userID, oldSession); err != nil {
+			log.Printf("Failed to revoke session %s after enabling two-factor: %v", oldSession, err)
+		}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"recovery_codes": codes,
+		"token":          session.Token,
+		"refresh_token":  session.RefreshToken,
+		"expires_at":     session.ExpiresAt,
+	})
+}
+
+// DisableTwoFactor serves POST /api/auth/2fa/disable with a current code or
+// a recovery code. It is refused while the organization requires
+// two-factor for the caller's role. Wrong codes count towards the sign-in
+// lockout.
+func (h *Handler) DisableTwoFactor(c echo.Context) error {
+	claims, userID, err := twoFactorCaller(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	req := new(TwoFactorCodeRequest)
+	if err := c.Bind(req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "code or recovery_code is required"})
+	}
+	ctx := c.Request().Context()
+	if tenantID, err := uuid.Parse(claims.TenantID); err == nil && twoFactorPolicyApplies(claims.Role) {
+		required, err := h.Store.GetTenantTwoFactorRequired(ctx, tenantID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to disable two-factor authentication"})
+		}
+		if required {
+			return c.JSON(http.StatusForbidden, map[string]string{"code": "two_factor_required", "error": "Your organization requires two-factor authentication"})
+		}
+	}
+	_, enabled, err := h.Store.GetTOTPSecret(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to disable two-factor authentication"})
+	}
+	if !enabled {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Two-factor authentication is not enabled"})
+	}
+	ok, err := h.verifySecondFactor(ctx, userID, req, true)
+	if errors.Is(err, store.ErrTwoFactorLockedOut) {
+		return twoFactorLocked(c)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify code"})
+	}
+	if !ok {
+		return invalidSecondFactor(c, http.StatusBadRequest)
+	}
+	if err := h.Store.DisableTOTP(ctx, userID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to disable two-factor authentication"})
+	}
+	h.logTenantAction(c, nil, auditDisableTwoFactor, "user", userID, nil)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes serves POST /api/auth/2fa/recovery-codes: a
+// current TOTP code replaces every recovery code with a fresh set. Wrong
+// codes count towards the sign-in lockout.
+func (h *Handler) RegenerateRecoveryCodes(c echo.Context) error {
+	_, userID, err := twoFactorCaller(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	req := new(TwoFactorCodeRequest)
+	if err := c.Bind(req); err != nil || req.Code == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "code is required"})
+	}
+	ctx := c.Request().Context()
+	ok, err := h.verifySecondFactor(ctx, userID, req, false)
+	if errors.Is(err, store.ErrTwoFactorLockedOut) {
+		return twoFactorLocked(c)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify code"})
+	}
+	if !ok {
+		return invalidSecondFactor(c, http.StatusBadRequest)
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate recovery codes"})
+	}
+	if err := h.Store.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate recovery codes"})
+	}
+	return c.JSON(http.StatusOK, map[string][]string{"recovery_codes": codes})
+}
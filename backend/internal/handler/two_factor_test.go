@@ -0,0 +1,485 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+	"idento/backend/internal/totp"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const testTOTPSecret = "JBSWY3DPEHPK3PXP"
+
+func currentTOTPCode(t *testing.T) string {
+	t.Helper()
+	code, err := totp.Code(testTOTPSecret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// twoFactorStore is a fakeStore for a user with testTOTPSecret enabled.
+func twoFactorStore(user *models.User, tenant *models.Tenant) *fakeStore {
+	user.TwoFactorEnabled = true
+	var lastStep int64
+	return &fakeStore{
+		getUserByEmail:    func(string) (*models.User, error) { return user, nil },
+		getUserByID:       func(uuid.UUID) (*models.User, error) { return user, nil },
+		getUserTenants:    func(uuid.UUID) ([]*models.Tenant, error) { return []*models.Tenant{tenant}, nil },
+		getUserTenantRole: func(_, _ uuid.UUID) (string, error) { return "admin", nil },
+		getTOTPSecret:     func(uuid.UUID) (string, bool, error) { return testTOTPSecret, true, nil },
+		useTOTPStep: func(_ uuid.UUID, step int64) (bool, error) {
+			if step <= lastStep {
+				return false, nil
+			}
+			lastStep = step
+			return true, nil
+		},
+		useRecoveryCode: func(_ uuid.UUID, hash string) (bool, error) {
+			return hash == hashOpaqueToken("abcdefghij"), nil
+		},
+	}
+}
+
+// Login for a two-factor account stops at a challenge; the challenge plus
+// a code opens a session marked as signed in with two-factor, and the
+// same code cannot be used again.
+func TestContractLoginTwoFactor(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	user := contractUser("a@b.c")
+	tenant := contractTenant("Acme")
+	user.TenantID = tenant.ID
+	fs := twoFactorStore(user, tenant)
+	h := New(fs)
+	e := echo.New()
+
+	c, rec := newUnauthedContext(e, http.MethodPost, "/auth/login", `{"email":"a@b.c","password":"secret123"}`)
+	if err := h.Login(c); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	validateResponse(t, http.MethodPost, "/auth/login", rec)
+	var challenge struct {
+		TwoFactorRequired bool   `json:"two_factor_required"`
+		ChallengeToken    string `json:"challenge_token"`
+	}
+	if err := jsonUnmarshalBody(rec, &challenge); err != nil {
+		t.Fatal(err)
+	}
+	if !challenge.TwoFactorRequired || challenge.ChallengeToken == "" || len(fs.refreshTokens) != 0 {
+		t.Fatalf("login body = %s, sessions = %d; want a challenge and no session", rec.Body.String(), len(fs.refreshTokens))
+	}
+
+	code := currentTOTPCode(t)
+	body := `{"challenge_token":"` + challenge.ChallengeToken + `","code":"` + code + `"}`
+	c, rec = newUnauthedContext(e, http.MethodPost, "/auth/login/2fa", body)
+	if err := h.LoginTwoFactor(c); err != nil {
+		t.Fatalf("LoginTwoFactor: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodPost, "/auth/login/2fa", rec)
+	var session struct {
+		Token string `json:"token"`
+	}
+	if err := jsonUnmarshalBody(rec, &session); err != nil {
+		t.Fatal(err)
+	}
+	if claims := parseAccessToken(t, session.Token); !claims.TwoFactor || len(fs.refreshTokens) != 1 || !fs.refreshTokens[0].TwoFactor {
+		t.Errorf("claims = %+v; want a two-factor session", claims)
+	}
+
+	// Replay of the same code.
+	c, rec = newUnauthedContext(e, http.MethodPost, "/auth/login/2fa", body)
+	if err := h.LoginTwoFactor(c); err != nil {
+		t.Fatalf("LoginTwoFactor: %v", err)
+	}
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed code: want 401, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodPost, "/auth/login/2fa", rec)
+
+	// A challenge token is not an access token, and vice versa.
+	c, rec = newUnauthedContext(e, http.MethodPost, "/auth/login/2fa", `{"challenge_token":"`+session.Token+`","code":"`+code+`"}`)
+	if err := h.LoginTwoFactor(c); err != nil {
+		t.Fatalf("LoginTwoFactor: %v", err)
+	}
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("access token as challenge: want 401, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodPost, "/auth/login/2fa", rec)
+}
+
+func TestLoginTwoFactorWithRecoveryCode(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	user := contractUser("a@b.c")
+	tenant := contractTenant("Acme")
+	user.TenantID = tenant.ID
+	h := New(twoFactorStore(user, tenant))
+	e := echo.New()
+
+	c, rec := newUnauthedContext(e, http.MethodPost, "/auth/login", `{"email":"a@b.c","password":"secret123"}`)
+	if err := h.Login(c); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	var challenge struct {
+		ChallengeToken string `json:"challenge_token"`
+	}
+	if err := jsonUnmarshalBody(rec, &challenge); err != nil {
+		t.Fatal(err)
+	}
+	c, rec = newUnauthedContext(e, http.MethodPost, "/auth/login/2fa",
+		`{"challenge_token":"`+challenge.ChallengeToken+`","recovery_code":" ABCDE-fghij "}`)
+	if err := h.LoginTwoFactor(c); err != nil {
+		t.Fatalf("LoginTwoFactor: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+}
+
+// A challenge takes a handful of codes and signs in once; past that, and
+// during a lockout, the password has to be entered again.
+func TestLoginTwoFactorChallengeLimits(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	user := contractUser("a@b.c")
+	tenant := contractTenant("Acme")
+	user.TenantID = tenant.ID
+	fs := twoFactorStore(user, tenant)
+	h := New(fs)
+	e := echo.New()
+
+	login := func() string {
+		c, rec := newUnauthedContext(e, http.MethodPost, "/auth/login", `{"email":"a@b.c","password":"secret123"}`)
+		if err := h.Login(c); err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+		var challenge struct {
+			ChallengeToken string `json:"challenge_token"`
+		}
+		if err := jsonUnmarshalBody(rec, &challenge); err != nil {
+			t.Fatal(err)
+		}
+		return challenge.ChallengeToken
+	}
+	second := func(token, code string) (int, string) {
+		c, rec := newUnauthedContext(e, http.MethodPost, "/auth/login/2fa",
+			`{"challenge_token":"`+token+`","code":"`+code+`"}`)
+		if err := h.LoginTwoFactor(c); err != nil {
+			t.Fatalf("LoginTwoFactor: %v", err)
+		}
+		validateResponse(t, http.MethodPost, "/auth/login/2fa", rec)
+		var body struct {
+			Code string `json:"code"`
+		}
+		_ = jsonUnmarshalBody(rec, &body)
+		return rec.Code, body.Code
+	}
+
+	token := login()
+	for i := 0; i < 5; i++ {
+		if status, code := second(token, "000000"); status != http.StatusUnauthorized || code != "two_factor_invalid" {
+			t.Fatalf("wrong code %d: %d %s, want 401 two_factor_invalid", i+1, status, code)
+		}
+	}
+	if status, code := second(token, currentTOTPCode(t)); status != http.StatusUnauthorized || code != "challenge_invalid" {
+		t.Fatalf("6th code on one challenge: %d %s, want 401 challenge_invalid", status, code)
+	}
+
+	token = login()
+	if status, _ := second(token, currentTOTPCode(t)); status != http.StatusOK {
+		t.Fatalf("fresh challenge: %d, want 200", status)
+	}
+	if status, code := second(token, currentTOTPCode(t)); status != http.StatusUnauthorized || code != "challenge_invalid" {
+		t.Fatalf("used challenge: %d %s, want 401 challenge_invalid", status, code)
+	}
+
+	fs.attemptTwoFactorChallenge = func(uuid.UUID, uuid.UUID) error { return store.ErrTwoFactorLockedOut }
+	if status, code := second(login(), currentTOTPCode(t)); status != http.StatusTooManyRequests || code != "two_factor_locked" {
+		t.Fatalf("locked out: %d %s, want 429 two_factor_locked", status, code)
+	}
+}
+
+// A QR badge must not stand in for an admin's second factor; staff keep
+// signing in with it.
+func TestLoginWithQRRefusesTwoFactorAdmin(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	for role, want := range map[string]int{"admin": http.StatusForbidden, "staff": http.StatusOK} {
+		user := contractUser("a@b.c")
+		user.Role = role
+		user.TwoFactorEnabled = true
+		h := New(&fakeStore{getUserByQRToken: func(string) (*models.User, error) { return user, nil }})
+		c, rec := newUnauthedContext(echo.New(), http.MethodPost, "/auth/login-qr", `{"qr_token":"qr"}`)
+		if err := h.LoginWithQR(c); err != nil {
+			t.Fatalf("LoginWithQR: %v", err)
+		}
+		if rec.Code != want {
+			t.Fatalf("%s: want %d, got %d", role, want, rec.Code)
+		}
+		validateResponse(t, http.MethodPost, "/auth/login-qr", rec)
+	}
+}
+
+func TestContractGetTwoFactorStatus(t *testing.T) {
+	userID, tenantID := uuid.New(), uuid.New()
+	h := New(&fakeStore{
+		getTOTPSecret:              func(uuid.UUID) (string, bool, error) { return testTOTPSecret, true, nil },
+		countUnusedRecoveryCodes:   func(uuid.UUID) (int, error) { return 7, nil },
+		getTenantTwoFactorRequired: func(uuid.UUID) (bool, error) { return true, nil },
+	})
+	c, rec := newAuthedContextWithUserID(echo.New(), http.MethodGet, "/api/auth/2fa", "", tenantID.String(), userID, "manager")
+	if err := h.GetTwoFactorStatus(c); err != nil {
+		t.Fatalf("GetTwoFactorStatus: %v", err)
+	}
+	validateResponse(t, http.MethodGet, "/api/auth/2fa", rec)
+	var body struct {
+		Enabled   bool `json:"enabled"`
+		Remaining int  `json:"recovery_codes_remaining"`
+		Required  bool `json:"required"`
+	}
+	if err := jsonUnmarshalBody(rec, &body); err != nil {
+		t.Fatal(err)
+	}
+	if !body.Enabled || body.Remaining != 7 || !body.Required {
+		t.Errorf("body = %+v", body)
+	}
+}
+
+func TestContractSetupTwoFactor(t *testing.T) {
+	user := contractUser("a@b.c")
+	var stored string
+	h := New(&fakeStore{
+		getUserByID: func(uuid.UUID) (*models.User, error) { return user, nil },
+		setPendingTOTPSecret: func(_ uuid.UUID, secret string) error {
+			if user.TwoFactorEnabled {
+				return store.ErrTwoFactorAlreadyEnabled
+			}
+			stored = secret
+			return nil
+		},
+	})
+	e := echo.New()
+
+	c, rec := newAuthedContextWithUserID(e, http.MethodPost, "/api/auth/2fa/setup", "", user.TenantID.String(), user.ID, "admin")
+	if err := h.SetupTwoFactor(c); err != nil {
+		t.Fatalf("SetupTwoFactor: %v", err)
+	}
+	validateResponse(t, http.MethodPost, "/api/auth/2fa/setup", rec)
+	var body struct {
+		Secret string `json:"secret"`
+	}
+	if err := jsonUnmarshalBody(rec, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Secret == "" || body.Secret != stored {
+		t.Errorf("returned secret %q, stored %q", body.Secret, stored)
+	}
+
+	user.TwoFactorEnabled = true
+	c, rec = newAuthedContextWithUserID(e, http.MethodPost, "/api/auth/2fa/setup", "", user.TenantID.String(), user.ID, "admin")
+	if err := h.SetupTwoFactor(c); err != nil {
+		t.Fatalf("SetupTwoFactor: %v", err)
+	}
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("want 409, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodPost, "/api/auth/2fa/setup", rec)
+}
+
+// Enabling stores hashes of the returned recovery codes and swaps the
+// caller's session for a two-factor one.
+func TestContractEnableTwoFactor(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	userID, tenantID := uuid.New(), uuid.New()
+	var hashes []string
+	fs := &fakeStore{
+		getTOTPSecret: func(uuid.UUID) (string, bool, error) { return testTOTPSecret, false, nil },
+		enableTOTP: func(_ uuid.UUID, _ int64, h []string) error {
+			hashes = h
+			return nil
+		},
+	}
+	h := New(fs)
+	e := echo.New()
+
+	c, rec := newAuthedContextWithUserID(e, http.MethodPost, "/api/auth/2fa/enable", `{"code":"000000"}`, tenantID.String(), userID, "admin")
+	if err := h.EnableTwoFactor(c); err != nil {
+		t.Fatalf("EnableTwoFactor: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("wrong code: want 400, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodPost, "/api/auth/2fa/enable", rec)
+
+	c, rec = newAuthedContextWithUserID(e, http.MethodPost, "/api/auth/2fa/enable", `{"code":"`+currentTOTPCode(t)+`"}`, tenantID.String(), userID, "admin")
+	if err := h.EnableTwoFactor(c); err != nil {
+		t.Fatalf("EnableTwoFactor: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d, body=%s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodPost, "/api/auth/2fa/enable", rec)
+	var body struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+		Token         string   `json:"token"`
+	}
+	if err := jsonUnmarshalBody(rec, &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.RecoveryCodes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("got %d codes, %d hashes", len(body.RecoveryCodes), len(hashes))
+	}
+	if hashes[0] != hashOpaqueToken(normalizeRecoveryCode(body.RecoveryCodes[0])) {
+		t.Errorf("stored hash does not match code %q", body.RecoveryCodes[0])
+	}
+	if claims := parseAccessToken(t, body.Token); !claims.TwoFactor {
+		t.Errorf("new session claims = %+v, want two-factor", claims)
+	}
+	if len(fs.tenantAudit) != 1 || fs.tenantAudit[0].Action != auditEnableTwoFactor {
+		t.Errorf("tenant audit = %+v", fs.tenantAudit)
+	}
+}
+
+func TestContractDisableTwoFactor(t *testing.T) {
+	userID, tenantID := uuid.New(), uuid.New()
+	required, disabled := true, false
+	fs := &fakeStore{
+		getTenantTwoFactorRequired: func(uuid.UUID) (bool, error) { return required, nil },
+		getTOTPSecret:              func(uuid.UUID) (string, bool, error) { return testTOTPSecret, true, nil },
+		useRecoveryCode: func(_ uuid.UUID, hash string) (bool, error) {
+			return hash == hashOpaqueToken("abcdefghij"), nil
+		},
+		disableTOTP: func(uuid.UUID) error {
+			disabled = true
+			return nil
+		},
+	}
+	h := New(fs)
+	e := echo.New()
+	body := `{"recovery_code":"abcde-fghij"}`
+
+	c, rec := newAuthedContextWithUserID(e, http.MethodPost, "/api/auth/2fa/disable", body, tenantID.String(), userID, "admin")
+	if err := h.DisableTwoFactor(c); err != nil {
+		t.Fatalf("DisableTwoFactor: %v", err)
+	}
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("required by policy: want 403, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodPost, "/api/auth/2fa/disable", rec)
+
+	required = false
+	c, rec = newAuthedContextWithUserID(e, http.MethodPost, "/api/auth/2fa/disable", `{"recovery_code":"wrong-wrong"}`, tenantID.String(), userID, "admin")
+	if err := h.DisableTwoFactor(c); err != nil {
+		t.Fatalf("DisableTwoFactor: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest || disabled {
+		t.Fatalf("wrong code: want 400, got %d (disabled=%v)", rec.Code, disabled)
+	}
+	validateResponse(t, http.MethodPost, "/api/auth/2fa/disable", rec)
+
+	c, rec = newAuthedContextWithUserID(e, http.MethodPost, "/api/auth/2fa/disable", body, tenantID.String(), userID, "admin")
+	if err := h.DisableTwoFactor(c); err != nil {
+		t.Fatalf("DisableTwoFactor: %v", err)
+	}
+	if rec.Code != http.StatusNoContent || !disabled {
+		t.Fatalf("want 204 and disabled, got %d (disabled=%v)", rec.Code, disabled)
+	}
+	validateResponse(t, http.MethodPost, "/api/auth/2fa/disable", rec)
+	if len(fs.tenantAudit) != 1 || fs.tenantAudit[0].Action != auditDisableTwoFactor {
+		t.Errorf("tenant audit = %+v", fs.tenantAudit)
+	}
+}
+
+func TestContractRegenerateRecoveryCodes(t *testing.T) {
+	user := contractUser("a@b.c")
+	fs := twoFactorStore(user, contractTenant("Acme"))
+	var replaced []string
+	fs.replaceRecoveryCodes = func(_ uuid.UUID, hashes []string) error {
+		replaced = hashes
+		return nil
+	}
+	h := New(fs)
+	c, rec := newAuthedContextWithUserID(echo.New(), http.MethodPost, "/api/auth/2fa/recovery-codes",
+		`{"code":"`+currentTOTPCode(t)+`"}`, user.TenantID.String(), user.ID, "admin")
+	if err := h.RegenerateRecoveryCodes(c); err != nil {
+		t.Fatalf("RegenerateRecoveryCodes: %v", err)
+	}
+	if rec.Code != http.StatusOK || len(replaced) != recoveryCodeCount {
+		t.Fatalf("want 200 with %d new codes, got %d (%d)", recoveryCodeCount, rec.Code, len(replaced))
+	}
+	validateResponse(t, http.MethodPost, "/api/auth/2fa/recovery-codes", rec)
+}
+
+// Codes offered to disable two-factor or regenerate recovery codes spend
+// attempts like sign-in codes: a wrong one stays counted and a locked-out
+// user gets 429 without the change being made.
+func TestTwoFactorChangesCountTowardsLockout(t *testing.T) {
+	user := contractUser("a@b.c")
+	fs := twoFactorStore(user, contractTenant("Acme"))
+	disabled := false
+	fs.disableTOTP = func(uuid.UUID) error {
+		disabled = true
+		return nil
+	}
+	fs.replaceRecoveryCodes = func(uuid.UUID, []string) error {
+		t.Fatal("recovery codes replaced while locked out")
+		return nil
+	}
+	h := New(fs)
+	e := echo.New()
+
+	c, rec := newAuthedContextWithUserID(e, http.MethodPost, "/api/auth/2fa/disable", `{"code":"000000"}`, user.TenantID.String(), user.ID, "admin")
+	if err := h.DisableTwoFactor(c); err != nil {
+		t.Fatalf("DisableTwoFactor: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest || disabled {
+		t.Fatalf("wrong code: want 400, got %d (disabled=%v)", rec.Code, disabled)
+	}
+	failures := 0
+	for _, ch := range fs.twoFactorChallenges {
+		if !ch.used {
+			failures += ch.attempts
+		}
+	}
+	if failures != 1 {
+		t.Errorf("counted failures = %d, want 1", failures)
+	}
+
+	fs.attemptTwoFactorChallenge = func(uuid.UUID, uuid.UUID) error { return store.ErrTwoFactorLockedOut }
+	c, rec = newAuthedContextWithUserID(e, http.MethodPost, "/api/auth/2fa/disable", `{"code":"`+currentTOTPCode(t)+`"}`, user.TenantID.String(), user.ID, "admin")
+	if err := h.DisableTwoFactor(c); err != nil {
+		t.Fatalf("DisableTwoFactor: %v", err)
+	}
+	if rec.Code != http.StatusTooManyRequests || disabled {
+		t.Fatalf("locked out: want 429, got %d (disabled=%v)", rec.Code, disabled)
+	}
+	validateResponse(t, http.MethodPost, "/api/auth/2fa/disable", rec)
+
+	c, rec = newAuthedContextWithUserID(e, http.MethodPost, "/api/auth/2fa/recovery-codes", `{"code":"`+currentTOTPCode(t)+`"}`, user.TenantID.String(), user.ID, "admin")
+	if err := h.RegenerateRecoveryCodes(c); err != nil {
+		t.Fatalf("RegenerateRecoveryCodes: %v", err)
+	}
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("locked out: want 429, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodPost, "/api/auth/2fa/recovery-codes", rec)
+}
+
+// Impersonation sessions cannot touch the operator's factors.
+func TestTwoFactorRefusesImpersonation(t *testing.T) {
+	h := New(&fakeStore{})
+	c, rec := newAuthedContext(echo.New(), http.MethodPost, "/api/auth/2fa/setup", "", uuid.New().String(), "admin")
+	c.Get("user").(*models.JWTCustomClaims).ImpersonatedBy = uuid.New().String()
+	if err := h.SetupTwoFactor(c); err != nil {
+		t.Fatalf("SetupTwoFactor: %v", err)
+	}
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("want 403, got %d", rec.Code)
+	}
+}
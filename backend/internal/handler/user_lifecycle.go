@@ -0,0 +1,336 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"idento/backend/internal/config"
+	"idento/backend/internal/mail"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// invitationTTL is how long a mailed invitation link stays valid; inviting
+// the same email again mails a fresh one.
+const invitationTTL = 7 * 24 * time.Hour
+
+// Audit actions for the member lifecycle (see audit.go).
+const (
+	auditInviteUser     = "invite_user"
+	auditChangeUserRole = "change_user_role"
+	auditDeactivateUser = "deactivate_user"
+	auditReactivateUser = "reactivate_user"
+	auditRemoveUser     = "remove_user"
+)
+
+// InviteUserRequest is the JSON body for POST /api/users/invite. Locale
+// picks the email's language; see ForgotPasswordRequest.
+type InviteUserRequest struct {
+	Email  string `json:"email"`
+	Role   string `json:"role"` // staff or manager
+	Locale string `json:"locale,omitempty"`
+}
+
+// AcceptInvitationRequest is the JSON body for POST /auth/accept-invite.
+type AcceptInvitationRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// UpdateUserRoleRequest is the JSON body for PUT /api/users/:id/role.
+type UpdateUserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+func validTenantRole(role string) bool {
+	return role == "admin" || role == "manager" || role == "staff"
+}
+
+// logMemberUsage records a change in the tenant's active member count in
+// usage_logs, so SUM(quantity) for "user" keeps matching what the users
+// limit counts.
+func (h *Handler) logMemberUsage(ctx context.Context, tenantID, userID uuid.UUID, action string, quantity int) {
+	if err := h.Store.LogUsage(ctx, &models.UsageLog{
+		TenantID:     tenantID,
+		ResourceType: "user",
+		ResourceID:   &userID,
+		Action:       action,
+		Quantity:     quantity,
+	}); err != nil {
+		log.Printf("Failed to log usage: %v", err)
+	}
+}
+
+// memberTarget resolves the :id member that a tenant admin is about to
+// change. Admins cannot change their own membership, which also keeps
+// every tenant with at least one active admin.
+func (h *Handler) memberTarget(c echo.Context) (uuid.UUID, *models.User, error) {
+	tenantID, err := requireTenantAdmin(c)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return uuid.Nil, nil, newHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+	claims, err := claimsFromContext(c)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	if userID.String() == claims.UserID {
+		return uuid.Nil, nil, newHTTPError(http.StatusBadRequest, "You cannot change your own membership")
+	}
+	member, err := h.Store.GetTenantMember(c.Request().Context(), tenantID, userID)
+	if err != nil {
+		return uuid.Nil, nil, newHTTPError(http.StatusInternalServerError, "Failed to fetch user")
+	}
+	if member == nil {
+		return uuid.Nil, nil, newHTTPError(http.StatusNotFound, "User not found")
+	}
+	return tenantID, member, nil
+}
+
+// memberResponse re-reads member after a change and writes it.
+func (h *Handler) memberResponse(c echo.Context, tenantID, userID uuid.UUID) error {
+	member, err := h.Store.GetTenantMember(c.Request().Context(), tenantID, userID)
+	if err != nil || member == nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch user"})
+	}
+	member.HasQRToken = member.QRToken != nil
+	return c.JSON(http.StatusOK, member)
+}
+
+// InviteUser serves POST /api/users/invite (admin only). A new email gets
+// an account without a password and a mailed link to choose one
+// (POST /auth/accept-invite); an existing account is added to the tenant
+// right away and notified. Inviting a still-pending invitee again mails a
+// fresh link (200).
+func (h *Handler) InviteUser(c echo.Context) error {
+	tenantID, err := requireTenantAdmin(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	req := new(InviteUserRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+	if !strings.Contains(req.Email, "@") {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "A valid email is required"})
+	}
+	if req.Role != "staff" && req.Role != "manager" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Role must be 'staff' or 'manager'"})
+	}
+	ctx := c.Request().Context()
+	tenant, err := h.Store.GetTenantByID(ctx, tenantID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get tenant"})
+	}
+
+	existing, err := h.Store.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	status := http.StatusCreated
+	template, linkPath, ttl := mail.TemplateInvitation, "/accept-invite", invitationTTL
+	var userID uuid.UUID
+	switch {
+	case existing == nil:
+		user, err := h.Store.CreateInvitedUser(ctx, tenantID, req.Email, req.Role)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "User is already a member of this organization"})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to invite user"})
+		}
+		userID = user.ID
+	default:
+		userID = existing.ID
+		member, err := h.Store.GetTenantMember(ctx, tenantID, userID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify user membership"})
+		}
+		switch {
+		case member != nil && member.Status == models.MemberStatusInvited:
+			status = http.StatusOK
+		case member != nil:
+			return c.JSON(http.StatusConflict, map[string]string{"error": "User is already a member of this organization"})
+		default:
+			if err := h.Store.AddUserToTenant(ctx, &models.UserTenant{
+				UserID: userID, TenantID: tenantID, Role: req.Role, JoinedAt: time.Now(),
+			}); err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to add user to tenant"})
+			}
+			template, linkPath, ttl = mail.TemplateMemberAdded, "/login", 0
+		}
+	}
+	if status == http.StatusCreated {
+		h.logMemberUsage(ctx, tenantID, userID, "invited", 1)
+		h.logTenantAction(c, nil, auditInviteUser, "user", userID, map[string]interface{}{"email": req.Email, "role": req.Role})
+	}
+
+	data := mail.InviteData{
+		LinkData:     mail.LinkData{Email: req.Email, ValidHours: int(ttl / time.Hour)},
+		Organization: tenant.Name,
+		Role:         req.Role,
+	}
+	if template == mail.TemplateInvitation {
+		data.Link, err = h.userTokenLink(ctx, userID, models.UserTokenInvitation, linkPath, ttl)
+	} else {
+		data.Link = config.AppURL() + linkPath
+	}
+	if err == nil {
+		err = h.sendTemplate(ctx, req.Email, template, requestLocale(c, req.Locale), data)
+	}
+	if err != nil {
+		// The membership stands; inviting again retries the email.
+		log.Printf("Failed to send invitation email to user %s: %v", userID, err)
+		return c.JSON(http.StatusBadGateway, map[string]string{"error": "Failed to send email"})
+	}
+
+	member, err := h.Store.GetTenantMember(ctx, tenantID, userID)
+	if err != nil || member == nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch user"})
+	}
+	return c.JSON(status, member)
+}
+
+// AcceptInvitation serves POST /auth/accept-invite: it spends an
+// invitation token and sets the invitee's password. The client then signs
+// in with it.
+func (h *Handler) AcceptInvitation(c echo.Context) error {
+	req := new(AcceptInvitationRequest)
+	if err := c.Bind(req); err != nil || req.Token == "" || len(req.Password) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "token and password are required"})
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid password"})
+	}
+	_, err = h.Store.AcceptInvitationWithToken(c.Request().Context(), hashOpaqueToken(req.Token), string(hashed))
+	if errors.Is(err, store.ErrUserTokenInvalid) {
+		return invalidUserToken(c)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to accept invitation"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// UpdateUserRole serves PUT /api/users/:id/role (admin only). The member's
+// current access tokens stop working; their next refresh carries the new
+// role.
+func (h *Handler) UpdateUserRole(c echo.Context) error {
+	tenantID, member, err := h.memberTarget(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	req := new(UpdateUserRoleRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if !validTenantRole(req.Role) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Role must be 'admin', 'manager' or 'staff'"})
+	}
+	if req.Role != member.Role {
+		err := h.Store.UpdateUserTenantRole(c.Request().Context(), member.ID, tenantID, req.Role)
+		if errors.Is(err, store.ErrMemberNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update role"})
+		}
+		h.logTenantDiff(c, nil, auditChangeUserRole, "user", member.ID,
+			map[string]string{"role": member.Role}, map[string]string{"role": req.Role})
+	}
+	return h.memberResponse(c, tenantID, member.ID)
+}
+
+// DeactivateUser serves POST /api/users/:id/deactivate (admin only), for
+// temporary staff after an event. The member can no longer sign in to the
+// tenant: their QR token is cleared, their sessions and stations there are
+// revoked (within config.TokenVersionCacheTTL; connected stations are told
+// to sign out at once), and they stop counting
+// against the users limit. Assignments are kept for ReactivateUser.
+func (h *Handler) DeactivateUser(c echo.Context) error {
+	tenantID, member, err := h.memberTarget(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	ctx := c.Request().Context()
+	n, stations, err := h.Store.DeactivateMember(ctx, tenantID, member.ID)
+	if errors.Is(err, store.ErrMemberNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to deactivate user"})
+	}
+	for _, st := range stations {
+		h.pushStationLogout(ctx, st.EventID, st.ID)
+	}
+	if member.Status != models.MemberStatusDeactivated {
+		h.logMemberUsage(ctx, tenantID, member.ID, "deactivated", -1)
+		h.logTenantAction(c, nil, auditDeactivateUser, "user", member.ID, map[string]interface{}{"revoked_sessions": n})
+	}
+	return h.memberResponse(c, tenantID, member.ID)
+}
+
+// ReactivateUser serves POST /api/users/:id/reactivate (admin only; the
+// route enforces the users limit). The member signs in again; a QR badge
+// has to be issued anew.
+func (h *Handler) ReactivateUser(c echo.Context) error {
+	tenantID, member, err := h.memberTarget(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	if member.Status == models.MemberStatusDeactivated {
+		ctx := c.Request().Context()
+		err := h.Store.ReactivateMember(ctx, tenantID, member.ID)
+		if errors.Is(err, store.ErrMemberNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to reactivate user"})
+		}
+		h.logMemberUsage(ctx, tenantID, member.ID, "reactivated", 1)
+		h.logTenantAction(c, nil, auditReactivateUser, "user", member.ID, nil)
+	}
+	return h.memberResponse(c, tenantID, member.ID)
+}
+
+// RemoveUser serves DELETE /api/users/:id (admin only): the user leaves
+// the tenant, signed out of it like DeactivateUser and with their event
+// and zone assignments dropped. The account and its other memberships
+// stay.
+func (h *Handler) RemoveUser(c echo.Context) error {
+	tenantID, member, err := h.memberTarget(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	ctx := c.Request().Context()
+	stations, err := h.Store.RemoveUserFromTenant(ctx, member.ID, tenantID)
+	if errors.Is(err, store.ErrMemberNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to remove user"})
+	}
+	for _, st := range stations {
+		h.pushStationLogout(ctx, st.EventID, st.ID)
+	}
+	if member.Status != models.MemberStatusDeactivated {
+		h.logMemberUsage(ctx, tenantID, member.ID, "removed", -1)
+	}
+	h.logTenantAction(c, nil, auditRemoveUser, "user", member.ID, map[string]interface{}{"email": member.Email, "role": member.Role})
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,324 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"idento/backend/internal/broker"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// memberStore is an in-memory user_tenants table for one tenant, plus the
+// usage log the lifecycle handlers keep.
+type memberStore struct {
+	members map[uuid.UUID]*models.User
+	users   map[string]*models.User // every account, by email
+	usage   []*models.UsageLog
+	// stations are the stations each member's revocation returns.
+	stations map[uuid.UUID][]store.RevokedStation
+}
+
+func newMemberFakeStore(tenant *models.Tenant) (*fakeStore, *memberStore) {
+	ms := &memberStore{members: map[uuid.UUID]*models.User{}, users: map[string]*models.User{}}
+	member := func(id uuid.UUID) *models.User {
+		if m := ms.members[id]; m != nil {
+			cp := *m
+			return &cp
+		}
+		return nil
+	}
+	fs := &fakeStore{
+		getTenantByID: func(uuid.UUID) (*models.Tenant, error) { return tenant, nil },
+		getUserByEmail: func(email string) (*models.User, error) {
+			return ms.users[email], nil
+		},
+		getTenantMember: func(_, userID uuid.UUID) (*models.User, error) { return member(userID), nil },
+		addUserToTenant: func(ut *models.UserTenant) error {
+			for _, u := range ms.users {
+				if u.ID == ut.UserID {
+					ms.members[u.ID] = &models.User{ID: u.ID, TenantID: u.TenantID, Email: u.Email, Role: ut.Role, Status: models.MemberStatusActive}
+				}
+			}
+			return nil
+		},
+		createInvitedUser: func(tenantID uuid.UUID, email, role string) (*models.User, error) {
+			if ms.users[email] != nil {
+				return nil, &pgconn.PgError{Code: "23505"}
+			}
+			u := &models.User{ID: uuid.New(), TenantID: tenantID, Email: email, Role: role, Status: models.MemberStatusInvited,
+				CreatedAt: time.Now(), UpdatedAt: time.Now()}
+			ms.users[email], ms.members[u.ID] = u, u
+			return u, nil
+		},
+		updateUserTenantRole: func(userID, _ uuid.UUID, role string) error {
+			if ms.members[userID] == nil {
+				return store.ErrMemberNotFound
+			}
+			ms.members[userID].Role = role
+			return nil
+		},
+		deactivateMember: func(_, userID uuid.UUID) (int64, []store.RevokedStation, error) {
+			m := ms.members[userID]
+			if m == nil {
+				return 0, nil, store.ErrMemberNotFound
+			}
+			now := time.Now()
+			m.Status, m.DeactivatedAt, m.QRToken = models.MemberStatusDeactivated, &now, nil
+			return 2, ms.stations[userID], nil
+		},
+		reactivateMember: func(_, userID uuid.UUID) error {
+			m := ms.members[userID]
+			if m == nil {
+				return store.ErrMemberNotFound
+			}
+			m.Status, m.DeactivatedAt = models.MemberStatusActive, nil
+			return nil
+		},
+		removeUserFromTenant: func(userID, _ uuid.UUID) ([]store.RevokedStation, error) {
+			if ms.members[userID] == nil {
+				return nil, store.ErrMemberNotFound
+			}
+			delete(ms.members, userID)
+			return ms.stations[userID], nil
+		},
+		logUsage: func(l *models.UsageLog) error {
+			ms.usage = append(ms.usage, l)
+			return nil
+		},
+	}
+	return fs, ms
+}
+
+// activeUsers sums the "user" usage log, which must track the number of
+// members the users limit counts.
+func (ms *memberStore) activeUsers() int {
+	n := 0
+	for _, l := range ms.usage {
+		if l.ResourceType == "user" {
+			n += l.Quantity
+		}
+	}
+	return n
+}
+
+func (ms *memberStore) addMember(email, role string) *models.User {
+	u := &models.User{ID: uuid.New(), TenantID: uuid.New(), Email: email, Role: role, Status: models.MemberStatusActive,
+		CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	ms.users[email], ms.members[u.ID] = u, u
+	ms.usage = append(ms.usage, &models.UsageLog{ResourceType: "user", Action: "created", Quantity: 1})
+	return u
+}
+
+func TestContractInviteUser(t *testing.T) {
+	tenant := contractTenant("Acme")
+	fs, ms := newMemberFakeStore(tenant)
+	mailer := &recordingMailer{}
+	h := &Handler{Store: fs, Mailer: mailer}
+	e := echo.New()
+	invite := func(h *Handler, role, body string) (int, *models.User) {
+		t.Helper()
+		c, rec := newAuthedContext(e, http.MethodPost, "/api/users/invite", body, tenant.ID.String(), role)
+		if err := h.InviteUser(c); err != nil {
+			t.Fatal(err)
+		}
+		validateResponse(t, http.MethodPost, "/api/users/invite", rec)
+		var u models.User
+		_ = json.Unmarshal(rec.Body.Bytes(), &u)
+		return rec.Code, &u
+	}
+
+	code, u := invite(h, "admin", `{"email":" Volunteer@Example.com ","role":"staff","locale":"ru"}`)
+	if code != http.StatusCreated || u.Status != models.MemberStatusInvited || u.Email != "volunteer@example.com" {
+		t.Fatalf("invite: got %d %+v", code, u)
+	}
+	if len(mailer.sent) != 1 || len(fs.userTokens) != 1 || fs.userTokens[0].Purpose != models.UserTokenInvitation {
+		t.Fatalf("sent %d mails, tokens %+v", len(mailer.sent), fs.userTokens)
+	}
+	if token := linkToken(t, mailer.sent[0].Body, "/accept-invite"); hashOpaqueToken(token) != fs.userTokens[0].Hash {
+		t.Errorf("mailed token does not match the stored hash")
+	}
+	if !strings.Contains(mailer.sent[0].Body, "Acme") {
+		t.Errorf("invitation does not name the organization:\n%s", mailer.sent[0].Body)
+	}
+
+	// Inviting a pending invitee again re-sends without a second usage entry.
+	if code, _ := invite(h, "admin", `{"email":"volunteer@example.com","role":"staff"}`); code != http.StatusOK {
+		t.Fatalf("re-invite: want 200, got %d", code)
+	}
+	if len(mailer.sent) != 2 || ms.activeUsers() != 1 {
+		t.Errorf("re-invite: sent %d mails, usage %d", len(mailer.sent), ms.activeUsers())
+	}
+
+	// An existing account joins right away and gets no password link.
+	other := &models.User{ID: uuid.New(), TenantID: uuid.New(), Email: "pro@example.com", Role: "admin"}
+	ms.users[other.Email] = other
+	code, u = invite(h, "admin", `{"email":"pro@example.com","role":"manager"}`)
+	if code != http.StatusCreated || u.Status != models.MemberStatusActive || u.Role != "manager" {
+		t.Fatalf("invite existing: got %d %+v", code, u)
+	}
+	if len(fs.userTokens) != 2 || strings.Contains(mailer.sent[2].Body, "token=") {
+		t.Errorf("existing account must not get a token link: tokens %d, mail:\n%s", len(fs.userTokens), mailer.sent[2].Body)
+	}
+	if code, _ := invite(h, "admin", `{"email":"pro@example.com","role":"staff"}`); code != http.StatusConflict {
+		t.Errorf("invite member: want 409, got %d", code)
+	}
+
+	for name, body := range map[string]string{
+		"no email":   `{"role":"staff"}`,
+		"admin role": `{"email":"x@example.com","role":"admin"}`,
+	} {
+		if code, _ := invite(h, "admin", body); code != http.StatusBadRequest {
+			t.Errorf("%s: want 400, got %d", name, code)
+		}
+	}
+	if code, _ := invite(h, "manager", `{"email":"x@example.com","role":"staff"}`); code != http.StatusForbidden {
+		t.Errorf("manager: want 403, got %d", code)
+	}
+	hDown := &Handler{Store: fs, Mailer: &recordingMailer{err: errors.New("refused")}}
+	if code, _ := invite(hDown, "admin", `{"email":"late@example.com","role":"staff"}`); code != http.StatusBadGateway {
+		t.Errorf("mail failure: want 502, got %d", code)
+	}
+	if ms.activeUsers() != 3 {
+		t.Errorf("usage = %d, want 3 (two invitees and one added account)", ms.activeUsers())
+	}
+}
+
+func TestContractAcceptInvitation(t *testing.T) {
+	var gotPassword string
+	h := New(&fakeStore{
+		acceptInvitationWithToken: func(tokenHash, passwordHash string) (uuid.UUID, error) {
+			if tokenHash != hashOpaqueToken("good") {
+				return uuid.Nil, store.ErrUserTokenInvalid
+			}
+			gotPassword = passwordHash
+			return uuid.New(), nil
+		},
+	})
+	e := echo.New()
+	call := func(body string) int {
+		c, rec := newUnauthedContext(e, http.MethodPost, "/auth/accept-invite", body)
+		if err := h.AcceptInvitation(c); err != nil {
+			t.Fatal(err)
+		}
+		validateResponse(t, http.MethodPost, "/auth/accept-invite", rec)
+		return rec.Code
+	}
+	if code := call(`{"token":"good","password":"s3cret"}`); code != http.StatusNoContent {
+		t.Fatalf("want 204, got %d", code)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(gotPassword), []byte("s3cret")) != nil {
+		t.Errorf("store did not get a bcrypt of the password")
+	}
+	if code := call(`{"token":"good"}`); code != http.StatusBadRequest {
+		t.Errorf("missing password: want 400, got %d", code)
+	}
+	if code := call(`{"token":"spent","password":"x"}`); code != http.StatusBadRequest {
+		t.Errorf("invalid token: want 400, got %d", code)
+	}
+}
+
+func TestContractMemberLifecycle(t *testing.T) {
+	tenant := contractTenant("Acme")
+	fs, ms := newMemberFakeStore(tenant)
+	h := New(fs)
+	e := echo.New()
+	adminID := ms.addMember("admin@example.com", "admin").ID
+	volunteer := ms.addMember("volunteer@example.com", "staff")
+	qr := "QR_x"
+	volunteer.QRToken = &qr
+	eventID, stationID := uuid.New(), uuid.New()
+	ms.stations = map[uuid.UUID][]store.RevokedStation{volunteer.ID: {{ID: stationID, EventID: eventID}}}
+	mem := broker.NewMemBroker()
+	h.Broker = mem
+	sub, unsubscribe := mem.SubscribeMessages(stationChannelKey(eventID))
+	defer unsubscribe()
+
+	call := func(method, action, id, body, role string) (int, *models.User) {
+		t.Helper()
+		url := "/api/users/" + id
+		if action != "" {
+			url += "/" + action
+		}
+		c, rec := newAuthedContextWithUserID(e, method, url, body, tenant.ID.String(), adminID, role)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		handlers := map[string]echo.HandlerFunc{
+			"role": h.UpdateUserRole, "deactivate": h.DeactivateUser, "reactivate": h.ReactivateUser, "": h.RemoveUser,
+		}
+		if err := handlers[action](c); err != nil {
+			t.Fatal(err)
+		}
+		validateResponse(t, method, url, rec)
+		var u models.User
+		_ = json.Unmarshal(rec.Body.Bytes(), &u)
+		return rec.Code, &u
+	}
+	id := volunteer.ID.String()
+
+	code, u := call(http.MethodPut, "role", id, `{"role":"manager"}`, "admin")
+	if code != http.StatusOK || u.Role != "manager" {
+		t.Fatalf("change role: got %d %+v", code, u)
+	}
+	if code, _ := call(http.MethodPut, "role", id, `{"role":"owner"}`, "admin"); code != http.StatusBadRequest {
+		t.Errorf("unknown role: want 400, got %d", code)
+	}
+
+	code, u = call(http.MethodPost, "deactivate", id, "", "admin")
+	if code != http.StatusOK || u.Status != models.MemberStatusDeactivated || u.DeactivatedAt == nil || u.HasQRToken {
+		t.Fatalf("deactivate: got %d %+v", code, u)
+	}
+	if ms.activeUsers() != 1 {
+		t.Errorf("after deactivate: usage = %d, want 1", ms.activeUsers())
+	}
+	// A station the volunteer was signed in on is told to sign out now,
+	// not when its stream next checks the token.
+	if msgs, _ := sub.Drain(); len(msgs) != 1 || msgs[0].Kind != broker.KindLogout ||
+		msgs[0].StationID == nil || *msgs[0].StationID != stationID {
+		t.Errorf("after deactivate: pushed %+v, want the station's logout", msgs)
+	}
+	if code, _ := call(http.MethodPost, "deactivate", id, "", "admin"); code != http.StatusOK || ms.activeUsers() != 1 {
+		t.Errorf("deactivate twice: got %d, usage %d", code, ms.activeUsers())
+	}
+	code, u = call(http.MethodPost, "reactivate", id, "", "admin")
+	if code != http.StatusOK || u.Status != models.MemberStatusActive || ms.activeUsers() != 2 {
+		t.Fatalf("reactivate: got %d %+v, usage %d", code, u, ms.activeUsers())
+	}
+
+	if code, _ := call(http.MethodDelete, "", id, "", "admin"); code != http.StatusNoContent {
+		t.Fatalf("remove: want 204, got %d", code)
+	}
+	if ms.members[volunteer.ID] != nil || ms.activeUsers() != 1 {
+		t.Errorf("after remove: member %+v, usage %d", ms.members[volunteer.ID], ms.activeUsers())
+	}
+	if code, _ := call(http.MethodDelete, "", id, "", "admin"); code != http.StatusNotFound {
+		t.Errorf("remove again: want 404, got %d", code)
+	}
+	if code, _ := call(http.MethodPost, "deactivate", adminID.String(), "", "admin"); code != http.StatusBadRequest {
+		t.Errorf("deactivate self: want 400, got %d", code)
+	}
+	if code, _ := call(http.MethodPut, "role", "nope", `{"role":"staff"}`, "admin"); code != http.StatusBadRequest {
+		t.Errorf("bad id: want 400, got %d", code)
+	}
+	if code, _ := call(http.MethodPost, "deactivate", adminID.String(), "", "manager"); code != http.StatusForbidden {
+		t.Errorf("manager: want 403, got %d", code)
+	}
+
+	var actions []string
+	for _, a := range fs.tenantAudit {
+		actions = append(actions, a.Action)
+	}
+	want := []string{auditChangeUserRole, auditDeactivateUser, auditReactivateUser, auditRemoveUser}
+	if strings.Join(actions, ",") != strings.Join(want, ",") {
+		t.Errorf("audit = %v, want %v", actions, want)
+	}
+}
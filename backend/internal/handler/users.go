@@ -186,17 +186,20 @@ func (h *Handler) AssignStaffToEvent(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid tenant ID")
 	}
 
-	// Only admin and manager can assign staff
-	if user.Role != "admin" && user.Role != "manager" {
-		return echo.NewHTTPError(http.StatusForbidden, "Access denied")
-	}
-
 	eventID := c.Param("event_id")
 	eventUUID, err := uuid.Parse(eventID)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid event ID")
 	}
 
+	// Verify event belongs to the active tenant (scoped lookup, 404 on foreign).
+	if _, err := h.requireEventOwnership(c, eventUUID); err != nil {
+		return writeErr(c, err)
+	}
+	if err := h.requirePermission(c, PermZonesManage, eventUUID); err != nil {
+		return writeErr(c, err)
+	}
+
 	var req struct {
 		UserID string `json:"user_id"`
 	}
@@ -209,11 +212,6 @@ func (h *Handler) AssignStaffToEvent(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
 	}
 
-	// Verify event belongs to the active tenant (scoped lookup, 404 on foreign).
-	if _, err := h.requireEventOwnership(c, eventUUID); err != nil {
-		return writeErr(c, err)
-	}
-
 	// Verify the target user is a member of the active tenant.
 	targetUser, err := h.Store.GetUserByID(c.Request().Context(), userUUID)
 	if err != nil || targetUser == nil {
@@ -240,6 +238,7 @@ func (h *Handler) AssignStaffToEvent(c echo.Context) error {
 	if err := h.Store.AssignStaffToEvent(c.Request().Context(), assignment); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to assign staff")
 	}
+	h.logTenantDiff(c, &eventUUID, auditAssignEventStaff, "user", userUUID, nil, map[string]interface{}{"event_id": eventUUID, "role": role})
 
 	return c.JSON(http.StatusCreated, assignment)
 }
@@ -267,16 +266,6 @@ func (h *Handler) GetEventStaff(c echo.Context) error {
 
 // UnassignStaffFromEvent removes a staff member from an event
 func (h *Handler) UnassignStaffFromEvent(c echo.Context) error {
-	user, err := claimsFromContext(c)
-	if err != nil {
-		return writeErr(c, err)
-	}
-
-	// Only admin and manager can unassign staff
-	if user.Role != "admin" && user.Role != "manager" {
-		return echo.NewHTTPError(http.StatusForbidden, "Access denied")
-	}
-
 	eventID := c.Param("event_id")
 	eventUUID, err := uuid.Parse(eventID)
 	if err != nil {
@@ -293,11 +282,15 @@ func (h *Handler) UnassignStaffFromEvent(c echo.Context) error {
 	if _, err := h.requireEventOwnership(c, eventUUID); err != nil {
 		return writeErr(c, err)
 	}
+	if err := h.requirePermission(c, PermZonesManage, eventUUID); err != nil {
+		return writeErr(c, err)
+	}
 
 	// Remove the staff assignment (idempotent — no error if already not assigned)
 	if err := h.Store.RemoveStaffFromEvent(c.Request().Context(), eventUUID, userUUID); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to remove staff"})
 	}
+	h.logTenantDiff(c, &eventUUID, auditUnassignEventStaff, "user", userUUID, map[string]interface{}{"event_id": eventUUID}, nil)
 
 	return c.NoContent(http.StatusNoContent)
 }
@@ -0,0 +1,521 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"idento/backend/internal/config"
+	"idento/backend/internal/jobs"
+	"idento/backend/internal/models"
+	"idento/backend/internal/wallet"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// Wallet passes: attendees add their ticket to Apple Wallet (a .pkpass
+// signed with the tenant's pass type certificate) or Google Wallet (a
+// save link signed with the tenant's service account). Both carry the
+// attendee's check-in code, as GetAttendeeQR encodes it, and follow event
+// changes: Apple devices are pushed to refetch through the PassKit web
+// service (passkit.go), Google's event class is updated in place.
+
+// Audit actions for wallet configuration (see audit.go).
+const (
+	auditUpdateAppleWallet  = "update_apple_wallet"
+	auditDeleteAppleWallet  = "delete_apple_wallet"
+	auditUpdateGoogleWallet = "update_google_wallet"
+	auditDeleteGoogleWallet = "delete_google_wallet"
+)
+
+// maxWalletCredentialBytes caps an uploaded certificate or key file.
+const maxWalletCredentialBytes = 64 << 10
+
+// defaultWalletClient serves handlers without an injected wallet client.
+var defaultWalletClient = wallet.NewClient()
+
+// walletClient returns the configured wallet client; nil-safe like Broker.
+func (h *Handler) walletClient() *wallet.Client {
+	if h.Wallet == nil {
+		return defaultWalletClient
+	}
+	return h.Wallet
+}
+
+// passKitURL is the PassKit web service passes point devices at. Wallet
+// only talks to https services, so passes issued by a plain-http
+// deployment carry none and never update.
+func passKitURL() string {
+	base := config.PublicAPIURL()
+	if !strings.HasPrefix(base, "https://") {
+		return ""
+	}
+	return base + "/passkit"
+}
+
+// walletSettingsView is the API shape of a tenant's wallet configuration:
+// the certificate key and service account key are never returned.
+func walletSettingsView(s *models.TenantWalletSettings) map[string]interface{} {
+	view := map[string]interface{}{"apple": nil, "google": nil, "web_service_url": passKitURL()}
+	if s.AppleConfigured() {
+		view["apple"] = map[string]interface{}{
+			"pass_type_id": s.ApplePassTypeID,
+			"team_id":      s.AppleTeamID,
+			"expires_at":   s.AppleExpiresAt,
+		}
+	}
+	if s.GoogleConfigured() {
+		view["google"] = map[string]interface{}{
+			"issuer_id":             s.GoogleIssuerID,
+			"service_account_email": s.GoogleServiceAccountEmail,
+		}
+	}
+	return view
+}
+
+// GetWalletSettings serves GET /api/wallet: the current organization's
+// wallet pass configuration (admin only).
+func (h *Handler) GetWalletSettings(c echo.Context) error {
+	tenantID, err := requireTenantAdmin(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	s, err := h.Store.GetTenantWalletSettings(c.Request().Context(), tenantID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load wallet settings"})
+	}
+	return c.JSON(http.StatusOK, walletSettingsView(s))
+}
+
+// walletSettingsForUpdate loads the tenant's settings, or a blank record
+// for a tenant configuring its first wallet.
+func (h *Handler) walletSettingsForUpdate(ctx context.Context, tenantID uuid.UUID) (*models.TenantWalletSettings, error) {
+	s, err := h.Store.GetTenantWalletSettings(ctx, tenantID)
+	if err != nil || s != nil {
+		return s, err
+	}
+	return &models.TenantWalletSettings{TenantID: tenantID}, nil
+}
+
+// readFormFile reads an optional multipart file; a missing one is nil.
+func readFormFile(c echo.Context, name string) ([]byte, error) {
+	fh, err := c.FormFile(name)
+	if errors.Is(err, http.ErrMissingFile) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if fh.Size > maxWalletCredentialBytes {
+		return nil, fmt.Errorf("%s is larger than %d KB", name, maxWalletCredentialBytes>>10)
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, maxWalletCredentialBytes))
+}
+
+// PutAppleWallet serves PUT /api/wallet/apple: it installs the
+// organization's pass type certificate (admin only). The multipart form
+// carries certificate (a .p12 export, or PEM certificate and key), its
+// password, and intermediate, Apple's WWDR certificate, when the .p12
+// lacks it. The pass type identifier and team come from the certificate.
+func (h *Handler) PutAppleWallet(c echo.Context) error {
+	tenantID, err := requireTenantAdmin(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	cert, err := readFormFile(c, "certificate")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid certificate file: " + err.Error()})
+	}
+	if cert == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "certificate file is required"})
+	}
+	intermediate, err := readFormFile(c, "intermediate")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid intermediate file: " + err.Error()})
+	}
+	signer, err := wallet.ParseCertificate(cert, c.FormValue("password"), intermediate)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid pass certificate: " + err.Error()})
+	}
+	pem, err := signer.PEM()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid pass certificate: " + err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	s, err := h.walletSettingsForUpdate(ctx, tenantID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load wallet settings"})
+	}
+	expires := signer.Certificate.NotAfter
+	s.AppleCertificate = string(pem)
+	s.ApplePassTypeID = signer.PassTypeID
+	s.AppleTeamID = signer.TeamID
+	s.AppleExpiresAt = &expires
+	if err := h.Store.UpsertTenantWalletSettings(ctx, s); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save wallet settings"})
+	}
+	view := walletSettingsView(s)
+	h.logTenantAction(c, nil, auditUpdateAppleWallet, "tenant", tenantID, view["apple"])
+	return c.JSON(http.StatusOK, view)
+}
+
+// DeleteAppleWallet serves DELETE /api/wallet/apple: it removes the pass
+// certificate (admin only). Issued passes stay in wallets but no longer
+// update.
+func (h *Handler) DeleteAppleWallet(c echo.Context) error {
+	tenantID, err := requireTenantAdmin(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	ctx := c.Request().Context()
+	s, err := h.Store.GetTenantWalletSettings(ctx, tenantID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load wallet settings"})
+	}
+	if !s.AppleConfigured() {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Apple Wallet is not configured"})
+	}
+	s.AppleCertificate, s.ApplePassTypeID, s.AppleTeamID, s.AppleExpiresAt = "", "", "", nil
+	if err := h.Store.UpsertTenantWalletSettings(ctx, s); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save wallet settings"})
+	}
+	h.logTenantAction(c, nil, auditDeleteAppleWallet, "tenant", tenantID, nil)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GoogleWalletRequest is the JSON body for PUT /api/wallet/google.
+type GoogleWalletRequest struct {
+	IssuerID string `json:"issuer_id"`
+	// ServiceAccount is the key file as downloaded; omit it to keep the
+	// stored one.
+	ServiceAccount json.RawMessage `json:"service_account"`
+}
+
+// PutGoogleWallet serves PUT /api/wallet/google: it sets the
+// organization's Google Wallet issuer and service account (admin only).
+func (h *Handler) PutGoogleWallet(c echo.Context) error {
+	tenantID, err := requireTenantAdmin(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	req := new(GoogleWalletRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	req.IssuerID = strings.TrimSpace(req.IssuerID)
+	if !wallet.ValidIssuerID(req.IssuerID) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "issuer_id must be a Google Wallet issuer ID (digits)"})
+	}
+
+	ctx := c.Request().Context()
+	s, err := h.walletSettingsForUpdate(ctx, tenantID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load wallet settings"})
+	}
+	if len(req.ServiceAccount) > 0 && string(req.ServiceAccount) != "null" {
+		account, err := wallet.ParseServiceAccount(req.ServiceAccount)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid service_account: " + err.Error()})
+		}
+		s.GoogleServiceAccount = string(req.ServiceAccount)
+		s.GoogleServiceAccountEmail = account.Email
+	} else if s.GoogleServiceAccount == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "service_account is required"})
+	}
+	s.GoogleIssuerID = req.IssuerID
+	if err := h.Store.UpsertTenantWalletSettings(ctx, s); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save wallet settings"})
+	}
+	view := walletSettingsView(s)
+	h.logTenantAction(c, nil, auditUpdateGoogleWallet, "tenant", tenantID, view["google"])
+	return c.JSON(http.StatusOK, view)
+}
+
+// DeleteGoogleWallet serves DELETE /api/wallet/google (admin only).
+func (h *Handler) DeleteGoogleWallet(c echo.Context) error {
+	tenantID, err := requireTenantAdmin(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	ctx := c.Request().Context()
+	s, err := h.Store.GetTenantWalletSettings(ctx, tenantID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load wallet settings"})
+	}
+	if !s.GoogleConfigured() {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Google Wallet is not configured"})
+	}
+	s.GoogleIssuerID, s.GoogleServiceAccount, s.GoogleServiceAccountEmail = "", "", ""
+	if err := h.Store.UpsertTenantWalletSettings(ctx, s); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save wallet settings"})
+	}
+	h.logTenantAction(c, nil, auditDeleteGoogleWallet, "tenant", tenantID, nil)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// walletTicket describes the event (and attendee a, when not nil) for a
+// wallet pass, colored with the event's ticket layout accent.
+func (h *Handler) walletTicket(ctx context.Context, event *models.Event, a *models.Attendee) (wallet.Ticket, error) {
+	tenant, err := h.Store.GetTenantByID(ctx, event.TenantID)
+	if err != nil {
+		return wallet.Ticket{}, err
+	}
+	layout, err := h.ticketLayout(ctx, event.ID)
+	if err != nil {
+		return wallet.Ticket{}, err
+	}
+	t := wallet.Ticket{
+		EventID:   event.ID.String(),
+		EventName: event.Name,
+		Dates:     ticketEventDates(event),
+		Start:     event.StartDate,
+		End:       event.EndDate,
+		Location:  event.Location,
+		Color:     layout.AccentColor,
+	}
+	if tenant != nil {
+		t.Organization = tenant.Name
+		if tenant.LogoURL != nil {
+			t.LogoURL = *tenant.LogoURL
+		}
+	}
+	if a != nil {
+		t.Serial = a.ID.String()
+		t.Code = a.Code
+		t.AttendeeName = strings.TrimSpace(a.FirstName + " " + a.LastName)
+	}
+	return t, nil
+}
+
+// renderApplePass builds and signs a's pass. A tenant logo that cannot be
+// fetched is left out, as on PDF tickets.
+func (h *Handler) renderApplePass(ctx context.Context, signer *wallet.Signer, pass *models.WalletPass, event *models.Event, a *models.Attendee) ([]byte, error) {
+	ticket, err := h.walletTicket(ctx, event, a)
+	if err != nil {
+		return nil, err
+	}
+	p := signer.NewPass(ticket)
+	if p.WebServiceURL = passKitURL(); p.WebServiceURL != "" {
+		p.AuthenticationToken = pass.AuthToken
+	}
+	var logo []byte
+	if ticket.LogoURL != "" {
+		fetchCtx, cancel := context.WithTimeout(ctx, logoFetchTimeout)
+		logo, err = h.fetchLogo(fetchCtx, ticket.LogoURL)
+		cancel()
+		if err != nil {
+			log.Printf("Wallet pass for attendee %s: tenant logo left out: %v", a.ID, err)
+		}
+	}
+	return signer.Package(p, wallet.Images(logo, ticket.Color))
+}
+
+// GetAttendeeApplePass serves GET /api/attendees/{id}/wallet.pkpass: the
+// attendee's Apple Wallet pass.
+func (h *Handler) GetAttendeeApplePass(c echo.Context) error {
+	attendeeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid attendee ID"})
+	}
+	attendee, err := h.requireAttendeeOwnership(c, attendeeID)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	event, err := h.requireEventOwnership(c, attendee.EventID)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	ctx := c.Request().Context()
+	settings, err := h.Store.GetTenantWalletSettings(ctx, event.TenantID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load wallet settings"})
+	}
+	if !settings.AppleConfigured() {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Apple Wallet is not configured"})
+	}
+	signer, err := wallet.LoadSigner([]byte(settings.AppleCertificate))
+	if err != nil {
+		log.Printf("Wallet pass for attendee %s: %v", attendee.ID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "The pass certificate cannot be used: " + err.Error()})
+	}
+	pass, err := h.Store.EnsureWalletPass(ctx, attendee.ID, event.ID, signer.PassTypeID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create wallet pass"})
+	}
+	data, err := h.renderApplePass(ctx, signer, pass, event, attendee)
+	if err != nil {
+		log.Printf("Wallet pass for attendee %s: %v", attendee.ID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create wallet pass"})
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", ticketFileBase(attendee)+".pkpass"))
+	return c.Blob(http.StatusOK, "application/vnd.apple.pkpass", data)
+}
+
+// GetAttendeeGoogleWallet serves GET /api/attendees/{id}/google-wallet:
+// the attendee's "Add to Google Wallet" link.
+func (h *Handler) GetAttendeeGoogleWallet(c echo.Context) error {
+	attendeeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid attendee ID"})
+	}
+	attendee, err := h.requireAttendeeOwnership(c, attendeeID)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	event, err := h.requireEventOwnership(c, attendee.EventID)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	ctx := c.Request().Context()
+	settings, err := h.Store.GetTenantWalletSettings(ctx, event.TenantID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load wallet settings"})
+	}
+	if !settings.GoogleConfigured() {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Google Wallet is not configured"})
+	}
+	account, err := wallet.ParseServiceAccount([]byte(settings.GoogleServiceAccount))
+	if err != nil {
+		log.Printf("Google Wallet link for attendee %s: %v", attendee.ID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "The service account cannot be used: " + err.Error()})
+	}
+	ticket, err := h.walletTicket(ctx, event, attendee)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create wallet pass"})
+	}
+	link, err := account.SaveURL(settings.GoogleIssuerID, ticket, []string{config.AppURL()})
+	if err != nil {
+		log.Printf("Google Wallet link for attendee %s: %v", attendee.ID, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create wallet pass"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"save_url": link})
+}
+
+// walletFieldsChanged reports whether an event edit changed anything its
+// wallet passes show.
+func walletFieldsChanged(before, after *models.Event) bool {
+	sameTime := func(a, b *time.Time) bool {
+		return (a == nil) == (b == nil) && (a == nil || a.Equal(*b))
+	}
+	return before.Name != after.Name || before.Location != after.Location ||
+		!sameTime(before.StartDate, after.StartDate) || !sameTime(before.EndDate, after.EndDate)
+}
+
+// queueWalletUpdate starts a wallet_update job when an event edit changed
+// what its passes show and the tenant issues wallet passes. Failures are
+// logged: the edit itself succeeded.
+func (h *Handler) queueWalletUpdate(ctx context.Context, before, after *models.Event) {
+	if !walletFieldsChanged(before, after) {
+		return
+	}
+	settings, err := h.Store.GetTenantWalletSettings(ctx, after.TenantID)
+	if err != nil {
+		log.Printf("Event %s: failed to load wallet settings: %v", after.ID, err)
+		return
+	}
+	if !settings.AppleConfigured() && !settings.GoogleConfigured() {
+		return
+	}
+	job := &models.Job{TenantID: after.TenantID, EventID: &after.ID, Type: models.JobTypeWalletUpdate}
+	if err := h.Store.EnqueueJob(ctx, job); err != nil {
+		log.Printf("Event %s: failed to start wallet update job: %v", after.ID, err)
+	}
+}
+
+// walletUpdateResult is a wallet_update job's result.
+type walletUpdateResult struct {
+	Passes              int64 `json:"passes"`
+	Pushed              int   `json:"pushed"`
+	Unregistered        int   `json:"unregistered"`
+	GoogleClassUpdated  bool  `json:"google_class_updated"`
+	GoogleClassNotSaved bool  `json:"google_class_not_saved,omitempty"`
+}
+
+// runWalletUpdateJob brings an event's wallet passes up to date: Apple
+// passes are marked changed and their devices pushed, so they refetch
+// them from the PassKit web service; the Google class is patched.
+func (h *Handler) runWalletUpdateJob(ctx context.Context, job *models.Job, progress jobs.Progress) (*jobs.Output, error) {
+	event, err := h.jobEvent(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := h.Store.GetTenantWalletSettings(ctx, event.TenantID)
+	if err != nil {
+		return nil, errors.New("failed to load the wallet settings")
+	}
+	var res walletUpdateResult
+	if res.Passes, err = h.Store.TouchWalletPasses(ctx, event.ID); err != nil {
+		return nil, errors.New("failed to mark the passes changed")
+	}
+	client := h.walletClient()
+
+	if settings.AppleConfigured() {
+		signer, err := wallet.LoadSigner([]byte(settings.AppleCertificate))
+		if err != nil {
+			return nil, fmt.Errorf("the pass certificate cannot be used: %v", err)
+		}
+		targets, err := h.Store.GetWalletPushTargets(ctx, event.ID)
+		if err != nil {
+			return nil, errors.New("failed to load the devices to notify")
+		}
+		// Passes issued under an earlier certificate cannot be pushed.
+		var tokens []string
+		for _, t := range targets {
+			if t.PassTypeID == signer.PassTypeID {
+				tokens = append(tokens, t.PushToken)
+			}
+		}
+		progress(0, len(tokens))
+		pushed, pushErr := client.Push(ctx, signer, tokens)
+		res.Pushed = pushed.Sent
+		if len(pushed.Gone) > 0 {
+			if _, err := h.Store.DeleteWalletPushTokens(ctx, pushed.Gone); err != nil {
+				log.Printf("Wallet update job %s: failed to drop gone devices: %v", job.ID, err)
+			}
+			res.Unregistered = len(pushed.Gone)
+		}
+		if pushErr != nil {
+			log.Printf("Wallet update job %s: %v", job.ID, pushErr)
+			if pushed.Sent == 0 && len(pushed.Gone) == 0 {
+				return nil, errors.New("failed to push pass updates")
+			}
+		}
+		progress(len(tokens), len(tokens))
+	}
+
+	if settings.GoogleConfigured() {
+		account, err := wallet.ParseServiceAccount([]byte(settings.GoogleServiceAccount))
+		if err != nil {
+			return nil, fmt.Errorf("the Google service account cannot be used: %v", err)
+		}
+		ticket, err := h.walletTicket(ctx, event, nil)
+		if err != nil {
+			return nil, errors.New("failed to load the event's branding")
+		}
+		switch err := client.UpdateGoogleClass(ctx, account, settings.GoogleIssuerID, ticket); {
+		case errors.Is(err, wallet.ErrGoogleClassNotFound):
+			// Nobody saved a pass yet; the next save link carries the
+			// current class.
+			res.GoogleClassNotSaved = true
+		case err != nil:
+			log.Printf("Wallet update job %s: %v", job.ID, err)
+			return nil, errors.New("failed to update the Google Wallet event")
+		default:
+			res.GoogleClassUpdated = true
+		}
+	}
+	return &jobs.Output{Result: res}, nil
+}
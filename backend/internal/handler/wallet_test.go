@@ -0,0 +1,576 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"idento/backend/internal/models"
+	"idento/backend/internal/wallet"
+	"idento/backend/internal/wallet/wallettest"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const walletPassType = "pass.com.example.idento"
+
+// walletStore keeps wallet settings, passes and device registrations in
+// memory on top of ticketPDFStore's event and attendees.
+type walletStore struct {
+	mu       sync.Mutex
+	settings *models.TenantWalletSettings
+	passes   map[uuid.UUID]*models.WalletPass
+	// registrations maps device library ID → serial → push token.
+	registrations map[string]map[uuid.UUID]string
+}
+
+func newWalletStore(event *models.Event, attendees ...*models.Attendee) (*fakeStore, *walletStore) {
+	fs := jobStore(ticketPDFStore(event, attendees...))
+	ws := &walletStore{passes: map[uuid.UUID]*models.WalletPass{}, registrations: map[string]map[uuid.UUID]string{}}
+	fs.getTenantWalletSettings = func(uuid.UUID) (*models.TenantWalletSettings, error) {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		if ws.settings == nil {
+			return nil, nil
+		}
+		s := *ws.settings
+		return &s, nil
+	}
+	fs.upsertTenantWalletSettings = func(s *models.TenantWalletSettings) error {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		saved := *s
+		ws.settings = &saved
+		return nil
+	}
+	fs.ensureWalletPass = func(attendeeID, eventID uuid.UUID, passTypeID string) (*models.WalletPass, error) {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		p := ws.passes[attendeeID]
+		if p == nil {
+			p = &models.WalletPass{AttendeeID: attendeeID, EventID: eventID, AuthToken: "token-" + attendeeID.String()[:8], UpdatedAt: pgNow()}
+			ws.passes[attendeeID] = p
+		}
+		if p.PassTypeID != passTypeID {
+			p.PassTypeID, p.UpdatedAt = passTypeID, pgNow()
+		}
+		out := *p
+		return &out, nil
+	}
+	fs.getWalletPass = func(passTypeID string, serial uuid.UUID) (*models.WalletPass, error) {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		if p := ws.passes[serial]; p != nil && p.PassTypeID == passTypeID {
+			out := *p
+			return &out, nil
+		}
+		return nil, nil
+	}
+	fs.registerWalletDevice = func(deviceID string, serial uuid.UUID, pushToken string) (bool, error) {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		if ws.registrations[deviceID] == nil {
+			ws.registrations[deviceID] = map[uuid.UUID]string{}
+		}
+		_, found := ws.registrations[deviceID][serial]
+		ws.registrations[deviceID][serial] = pushToken
+		return !found, nil
+	}
+	fs.unregisterWalletDevice = func(deviceID string, serial uuid.UUID) (bool, error) {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		_, found := ws.registrations[deviceID][serial]
+		delete(ws.registrations[deviceID], serial)
+		return found, nil
+	}
+	fs.getWalletDevicePasses = func(deviceID, passTypeID string, since *time.Time) ([]uuid.UUID, time.Time, error) {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		var (
+			serials []uuid.UUID
+			latest  time.Time
+		)
+		for serial := range ws.registrations[deviceID] {
+			p := ws.passes[serial]
+			if p == nil || p.PassTypeID != passTypeID || (since != nil && !p.UpdatedAt.After(*since)) {
+				continue
+			}
+			serials = append(serials, serial)
+			if p.UpdatedAt.After(latest) {
+				latest = p.UpdatedAt
+			}
+		}
+		return serials, latest, nil
+	}
+	fs.touchWalletPasses = func(eventID uuid.UUID) (int64, error) {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		var n int64
+		for _, p := range ws.passes {
+			if p.EventID == eventID {
+				p.UpdatedAt = pgNow().Add(time.Second)
+				n++
+			}
+		}
+		return n, nil
+	}
+	fs.getWalletPushTargets = func(eventID uuid.UUID) ([]models.WalletPushTarget, error) {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		var targets []models.WalletPushTarget
+		for _, serials := range ws.registrations {
+			for serial, token := range serials {
+				if p := ws.passes[serial]; p != nil && p.EventID == eventID {
+					targets = append(targets, models.WalletPushTarget{PassTypeID: p.PassTypeID, PushToken: token})
+				}
+			}
+		}
+		return targets, nil
+	}
+	fs.deleteWalletPushTokens = func(tokens []string) (int64, error) {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		var n int64
+		for _, serials := range ws.registrations {
+			for serial, token := range serials {
+				if slices.Contains(tokens, token) {
+					delete(serials, serial)
+					n++
+				}
+			}
+		}
+		return n, nil
+	}
+	return fs, ws
+}
+
+// pgNow is the current time at Postgres' microsecond precision, which the
+// passesUpdatedSince tag relies on.
+func pgNow() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+// configureWallet stores a self-signed pass certificate and a service
+// account for tokenURI, as the admin endpoints would.
+func configureWallet(t *testing.T, ws *walletStore, tenantID uuid.UUID, tokenURI string) {
+	t.Helper()
+	cert, err := wallettest.NewCertificate(walletPassType, "TEAM123456", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := wallet.ParseCertificate(cert.PEM(), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pem, err := signer.PEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, _, err := wallettest.NewServiceAccount("issuer@idento-test.iam.gserviceaccount.com", tokenURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.settings = &models.TenantWalletSettings{
+		TenantID:                  tenantID,
+		AppleCertificate:          string(pem),
+		ApplePassTypeID:           walletPassType,
+		AppleTeamID:               "TEAM123456",
+		GoogleIssuerID:            "3388000000012345678",
+		GoogleServiceAccount:      string(account),
+		GoogleServiceAccountEmail: "issuer@idento-test.iam.gserviceaccount.com",
+	}
+}
+
+func TestContractWalletSettings(t *testing.T) {
+	tenantID := uuid.New()
+	fs, ws := newWalletStore(contractEvent(tenantID, "Tech Summit"))
+	h := New(fs)
+	e := echo.New()
+
+	c, rec := newAuthedContext(e, http.MethodGet, "/api/wallet", "", tenantID.String(), "admin")
+	if err := h.GetWalletSettings(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"apple":null`) {
+		t.Fatalf("unconfigured: %d %s", rec.Code, rec.Body.String())
+	}
+	validateResponse(t, http.MethodGet, "/api/wallet", rec)
+
+	c, rec = newAuthedContext(e, http.MethodGet, "/api/wallet", "", tenantID.String(), "manager")
+	if err := h.GetWalletSettings(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("manager: want 403, got %d", rec.Code)
+	}
+	validateResponse(t, http.MethodGet, "/api/wallet", rec)
+
+	// Apple: a .p12 export plus the intermediate it was issued by.
+	cert, err := wallettest.NewCertificate(walletPassType, "TEAM123456", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p12, err := cert.PKCS12("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	putApple := func(password string, intermediate []byte) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		w := multipartWriter(t, body, map[string]string{"password": password},
+			map[string][]byte{"certificate": p12, "intermediate": intermediate})
+		req := httptest.NewRequest(http.MethodPut, "/api/wallet/apple", body)
+		req.Header.Set(echo.HeaderContentType, w)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("user", &models.JWTCustomClaims{UserID: uuid.NewString(), TenantID: tenantID.String(), Role: "admin"})
+		if err := h.PutAppleWallet(c); err != nil {
+			t.Fatal(err)
+		}
+		validateResponse(t, http.MethodPut, "/api/wallet/apple", rec)
+		return rec
+	}
+	if rec := putApple("wrong", cert.IssuerPEM()); rec.Code != http.StatusBadRequest {
+		t.Fatalf("wrong password: want 400, got %d", rec.Code)
+	}
+	if rec := putApple("secret", nil); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "intermediate") {
+		t.Fatalf("no intermediate: want 400, got %d %s", rec.Code, rec.Body.String())
+	}
+	rec = putApple("secret", cert.IssuerPEM())
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"pass_type_id":"`+walletPassType+`"`) {
+		t.Fatalf("PutAppleWallet: %d %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "PRIVATE KEY") || !strings.Contains(ws.settings.AppleCertificate, "PRIVATE KEY") {
+		t.Error("the certificate key must be stored and never returned")
+	}
+
+	// Google: the key is required the first time, then kept when omitted.
+	put := func(body string) *httptest.ResponseRecorder {
+		c, rec := newAuthedContext(e, http.MethodPut, "/api/wallet/google", body, tenantID.String(), "admin")
+		if err := h.PutGoogleWallet(c); err != nil {
+			t.Fatal(err)
+		}
+		validateResponse(t, http.MethodPut, "/api/wallet/google", rec)
+		return rec
+	}
+	if rec := put(`{"issuer_id":"3388000000012345678"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("no service account: want 400, got %d", rec.Code)
+	}
+	if rec := put(`{"issuer_id":"acme","service_account":{}}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("bad issuer: want 400, got %d", rec.Code)
+	}
+	account, _, err := wallettest.NewServiceAccount("issuer@idento-test.iam.gserviceaccount.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := put(`{"issuer_id":"3388000000012345678","service_account":` + string(account) + `}`); rec.Code != http.StatusOK ||
+		!strings.Contains(rec.Body.String(), `"service_account_email":"issuer@idento-test.iam.gserviceaccount.com"`) {
+		t.Fatalf("PutGoogleWallet: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := put(`{"issuer_id":"3388000000087654321"}`); rec.Code != http.StatusOK || ws.settings.GoogleServiceAccount != string(account) {
+		t.Fatalf("keep the key: %d %s", rec.Code, rec.Body.String())
+	}
+	if !ws.settings.AppleConfigured() {
+		t.Error("saving Google Wallet dropped the Apple certificate")
+	}
+
+	for _, tc := range []struct {
+		path string
+		fn   func(echo.Context) error
+	}{{"/api/wallet/apple", h.DeleteAppleWallet}, {"/api/wallet/google", h.DeleteGoogleWallet}} {
+		for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+			c, rec := newAuthedContext(e, http.MethodDelete, tc.path, "", tenantID.String(), "admin")
+			if err := tc.fn(c); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != want {
+				t.Fatalf("DELETE %s: want %d, got %d", tc.path, want, rec.Code)
+			}
+			validateResponse(t, http.MethodDelete, tc.path, rec)
+		}
+	}
+	var actions []string
+	for _, a := range fs.tenantAudit {
+		actions = append(actions, a.Action)
+	}
+	want := []string{auditUpdateAppleWallet, auditUpdateGoogleWallet, auditUpdateGoogleWallet, auditDeleteAppleWallet, auditDeleteGoogleWallet}
+	if !slices.Equal(actions, want) {
+		t.Errorf("audit = %v, want %v", actions, want)
+	}
+}
+
+// multipartWriter writes fields and non-nil files into body and returns
+// the request content type.
+func multipartWriter(t *testing.T, body *bytes.Buffer, fields map[string]string, files map[string][]byte) string {
+	t.Helper()
+	w := multipart.NewWriter(body)
+	for k, v := range fields {
+		if err := w.WriteField(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, data := range files {
+		if data == nil {
+			continue
+		}
+		fw, err := w.CreateFormFile(name, name+".bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return w.FormDataContentType()
+}
+
+func TestContractAttendeeWalletPasses(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	start := time.Date(2027, 5, 1, 9, 0, 0, 0, time.UTC)
+	event.StartDate = &start
+	attendee := contractAttendee(event.ID)
+	fs, ws := newWalletStore(event, attendee)
+	h := New(fs)
+
+	get := func(path string, fn func(echo.Context) error) *httptest.ResponseRecorder {
+		c, rec := newAuthedContext(echo.New(), http.MethodGet, path, "", tenantID.String(), "staff")
+		c.SetParamNames("id")
+		c.SetParamValues(strings.Split(path, "/")[3])
+		if err := fn(c); err != nil {
+			t.Fatal(err)
+		}
+		validateResponse(t, http.MethodGet, path, rec)
+		return rec
+	}
+	pkpass := "/api/attendees/" + attendee.ID.String() + "/wallet.pkpass"
+	google := "/api/attendees/" + attendee.ID.String() + "/google-wallet"
+	if rec := get(pkpass, h.GetAttendeeApplePass); rec.Code != http.StatusNotFound {
+		t.Fatalf("unconfigured pkpass: want 404, got %d", rec.Code)
+	}
+	if rec := get(google, h.GetAttendeeGoogleWallet); rec.Code != http.StatusNotFound {
+		t.Fatalf("unconfigured Google Wallet: want 404, got %d", rec.Code)
+	}
+
+	configureWallet(t, ws, tenantID, "")
+	rec := get(pkpass, h.GetAttendeeApplePass)
+	if rec.Code != http.StatusOK || rec.Header().Get(echo.HeaderContentType) != "application/vnd.apple.pkpass" {
+		t.Fatalf("want a 200 pkpass, got %d %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get(echo.HeaderContentDisposition); got != `attachment; filename="ticket-ABC123.pkpass"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatalf("not a zip: %v", err)
+	}
+	var pass map[string]interface{}
+	for _, f := range zr.File {
+		if f.Name == "pass.json" {
+			rc, _ := f.Open()
+			if err := json.NewDecoder(rc).Decode(&pass); err != nil {
+				t.Fatal(err)
+			}
+			rc.Close()
+		}
+	}
+	if pass["serialNumber"] != attendee.ID.String() || pass["passTypeIdentifier"] != walletPassType {
+		t.Errorf("pass.json = %v", pass)
+	}
+	// PUBLIC_API_URL is plain http here: no web service to point at.
+	if _, ok := pass["webServiceURL"]; ok {
+		t.Error("an http deployment must not advertise a web service")
+	}
+	if p := ws.passes[attendee.ID]; p == nil || p.PassTypeID != walletPassType {
+		t.Errorf("pass record = %+v", p)
+	}
+
+	rec = get(google, h.GetAttendeeGoogleWallet)
+	var link struct {
+		SaveURL string `json:"save_url"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &link); err != nil || rec.Code != http.StatusOK ||
+		!strings.HasPrefix(link.SaveURL, "https://pay.google.com/gp/v/save/") {
+		t.Fatalf("GetAttendeeGoogleWallet: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestContractPassKitWebService(t *testing.T) {
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	attendee := contractAttendee(event.ID)
+	fs, ws := newWalletStore(event, attendee)
+	configureWallet(t, ws, tenantID, "")
+	h := New(fs)
+	e := echo.New()
+	pass, _ := fs.ensureWalletPass(attendee.ID, event.ID, walletPassType)
+	serial := attendee.ID.String()
+
+	call := func(method, path, body, auth string, fn func(echo.Context) error, params ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if auth != "" {
+			req.Header.Set(echo.HeaderAuthorization, auth)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		var names, values []string
+		for i := 0; i < len(params); i += 2 {
+			names, values = append(names, params[i]), append(values, params[i+1])
+		}
+		c.SetParamNames(names...)
+		c.SetParamValues(values...)
+		if err := fn(c); err != nil {
+			t.Fatal(err)
+		}
+		validateResponse(t, method, strings.SplitN(path, "?", 2)[0], rec)
+		return rec
+	}
+	registration := "/passkit/v1/devices/device-1/registrations/" + walletPassType + "/" + serial
+	params := []string{"device_id", "device-1", "pass_type_id", walletPassType, "serial", serial}
+	auth := "ApplePass " + pass.AuthToken
+
+	if rec := call(http.MethodPost, registration, `{"pushToken":"push-1"}`, "ApplePass wrong", h.RegisterPassDevice, params...); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token: want 401, got %d", rec.Code)
+	}
+	if rec := call(http.MethodPost, registration, `{}`, auth, h.RegisterPassDevice, params...); rec.Code != http.StatusBadRequest {
+		t.Fatalf("no push token: want 400, got %d", rec.Code)
+	}
+	for _, want := range []int{http.StatusCreated, http.StatusOK} {
+		if rec := call(http.MethodPost, registration, `{"pushToken":"push-1"}`, auth, h.RegisterPassDevice, params...); rec.Code != want {
+			t.Fatalf("register: want %d, got %d", want, rec.Code)
+		}
+	}
+
+	serials := "/passkit/v1/devices/device-1/registrations/" + walletPassType
+	rec := call(http.MethodGet, serials, "", "", h.GetDevicePassSerials, params[:4]...)
+	var changed struct {
+		SerialNumbers []string `json:"serialNumbers"`
+		LastUpdated   string   `json:"lastUpdated"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &changed); err != nil || !slices.Equal(changed.SerialNumbers, []string{serial}) {
+		t.Fatalf("serials: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := call(http.MethodGet, serials+"?passesUpdatedSince="+changed.LastUpdated, "", "", h.GetDevicePassSerials, params[:4]...); rec.Code != http.StatusNoContent {
+		t.Fatalf("nothing changed: want 204, got %d", rec.Code)
+	}
+	fs.touchWalletPasses(event.ID)
+	if rec := call(http.MethodGet, serials+"?passesUpdatedSince="+changed.LastUpdated, "", "", h.GetDevicePassSerials, params[:4]...); rec.Code != http.StatusOK {
+		t.Fatalf("after an event change: want 200, got %d", rec.Code)
+	}
+
+	latest := "/passkit/v1/passes/" + walletPassType + "/" + serial
+	rec = call(http.MethodGet, latest, "", auth, h.GetLatestPass, params[2:]...)
+	if rec.Code != http.StatusOK || rec.Header().Get(echo.HeaderContentType) != "application/vnd.apple.pkpass" {
+		t.Fatalf("latest pass: %d %s", rec.Code, rec.Body.String())
+	}
+	lastModified := rec.Header().Get(echo.HeaderLastModified)
+	req := httptest.NewRequest(http.MethodGet, latest, nil)
+	req.Header.Set(echo.HeaderAuthorization, auth)
+	req.Header.Set("If-Modified-Since", lastModified)
+	rec = httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("pass_type_id", "serial")
+	c.SetParamValues(walletPassType, serial)
+	if err := h.GetLatestPass(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("If-Modified-Since %s: want 304, got %d", lastModified, rec.Code)
+	}
+	validateResponse(t, http.MethodGet, latest, rec)
+	if rec := call(http.MethodGet, latest, "", "", h.GetLatestPass, params[2:]...); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("no token: want 401, got %d", rec.Code)
+	}
+
+	if rec := call(http.MethodDelete, registration, "", auth, h.UnregisterPassDevice, params...); rec.Code != http.StatusOK {
+		t.Fatalf("unregister: want 200, got %d", rec.Code)
+	}
+	if rec := call(http.MethodGet, serials, "", "", h.GetDevicePassSerials, params[:4]...); rec.Code != http.StatusNoContent {
+		t.Fatalf("unregistered device: want 204, got %d", rec.Code)
+	}
+	if rec := call(http.MethodPost, "/passkit/v1/log", `{"logs":["pass download failed"]}`, "", h.LogPassKitErrors); rec.Code != http.StatusOK {
+		t.Fatalf("log: want 200, got %d", rec.Code)
+	}
+}
+
+// Renaming an event queues a wallet_update job that pushes registered
+// devices, drops the ones APNs reports gone and patches the Google class.
+func TestEventChangeUpdatesWalletPasses(t *testing.T) {
+	apns := wallettest.NewAPNs()
+	defer apns.Close()
+	apns.SetGone("push-gone")
+	google := wallettest.NewGoogle()
+	defer google.Close()
+
+	tenantID := uuid.New()
+	event := contractEvent(tenantID, "Tech Summit")
+	a, b := contractAttendee(event.ID), contractAttendee(event.ID)
+	fs, ws := newWalletStore(event, a, b)
+	fs.updateEvent = func(*models.Event) error { return nil }
+	var queued *models.Job
+	enqueue := fs.enqueueJob
+	fs.enqueueJob = func(j *models.Job) error {
+		queued = j
+		return enqueue(j)
+	}
+	configureWallet(t, ws, tenantID, google.URL+"/token")
+	for i, attendee := range []*models.Attendee{a, b} {
+		if _, err := fs.ensureWalletPass(attendee.ID, event.ID, walletPassType); err != nil {
+			t.Fatal(err)
+		}
+		token := []string{"push-a", "push-gone"}[i]
+		if _, err := fs.registerWalletDevice("device-"+strconv.Itoa(i), attendee.ID, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	classID := wallet.GoogleClassID("3388000000012345678", event.ID.String())
+	google.Save(classID)
+	h := New(fs)
+	h.Wallet = &wallet.Client{APNsURL: apns.URL, RootCAs: apns.RootCAs(), GoogleAPIURL: google.URL}
+
+	patch := func(body string) {
+		c, rec := newAuthedContext(echo.New(), http.MethodPatch, "/api/events/"+event.ID.String(), body, tenantID.String(), "admin")
+		c.SetParamNames("id")
+		c.SetParamValues(event.ID.String())
+		if err := h.PatchEvent(c); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("PatchEvent: %v %d", err, rec.Code)
+		}
+	}
+	patch(`{"field_schema":["company"]}`)
+	if queued != nil {
+		t.Fatal("a change passes do not show queued a wallet update")
+	}
+	patch(`{"name":"Tech Summit 2027"}`)
+	if queued == nil || queued.Type != models.JobTypeWalletUpdate {
+		t.Fatalf("queued = %+v, want a wallet_update job", queued)
+	}
+
+	_, _, result, _, _ := runQueuedJob(t, h, fs, tenantID, queued.ID)
+	res := result.(walletUpdateResult)
+	if res.Passes != 2 || res.Pushed != 1 || res.Unregistered != 1 || !res.GoogleClassUpdated {
+		t.Errorf("result = %+v", res)
+	}
+	if n := len(apns.Pushes()); n != 2 {
+		t.Errorf("%d pushes, want 2", n)
+	}
+	if _, ok := ws.registrations["device-1"][b.ID]; ok {
+		t.Error("the gone device is still registered")
+	}
+	name := google.Class(classID)["eventName"].(map[string]interface{})["defaultValue"].(map[string]interface{})["value"]
+	if name != "Tech Summit 2027" {
+		t.Errorf("Google class event name = %v", name)
+	}
+}
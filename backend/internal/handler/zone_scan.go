@@ -1,10 +1,12 @@
 package handler
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"time"
 
+	"idento/backend/internal/metrics"
 	"idento/backend/internal/models"
 
 	"github.com/google/uuid"
@@ -33,7 +35,11 @@ func (h *Handler) ZoneScan(c echo.Context) error {
 	if err != nil {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid token"})
 	}
-	if claims.Role != "admin" && claims.Role != "manager" {
+	anyZone, err := h.hasPermission(c, PermZonesManage, event.ID)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	if !anyZone {
 		assignments, err := h.Store.GetZoneStaffAssignments(c.Request().Context(), zoneID)
 		if err != nil {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify zone assignment"})
@@ -73,9 +79,7 @@ func (h *Handler) ZoneScan(c echo.Context) error {
 	}
 
 	if !zone.IsActive || !isWithinZoneTime(zone, now) {
-		if err := h.Store.CreateZoneScanLog(c.Request().Context(), zoneID, &attendee.ID, "no_access"); err != nil {
-			log.Printf("Failed to log zone scan: %v", err)
-		}
+		h.recordZoneVerdict(c.Request().Context(), zoneID, attendee.ID, "no_access")
 		return c.JSON(http.StatusOK, models.ZoneScanResponse{
 			Verdict:      "no_access",
 			Reason:       "Zone is closed",
@@ -85,9 +89,7 @@ func (h *Handler) ZoneScan(c echo.Context) error {
 	}
 
 	if zone.RequiresRegistration && attendee.RegisteredAt == nil {
-		if err := h.Store.CreateZoneScanLog(c.Request().Context(), zoneID, &attendee.ID, "not_registered"); err != nil {
-			log.Printf("Failed to log zone scan: %v", err)
-		}
+		h.recordZoneVerdict(c.Request().Context(), zoneID, attendee.ID, "not_registered")
 		return c.JSON(http.StatusOK, models.ZoneScanResponse{
 			Verdict:      "not_registered",
 			Reason:       "Attendee has not registered yet",
@@ -101,9 +103,7 @@ func (h *Handler) ZoneScan(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to evaluate zone access"})
 	}
 	if !allowed {
-		if err := h.Store.CreateZoneScanLog(c.Request().Context(), zoneID, &attendee.ID, "no_access"); err != nil {
-			log.Printf("Failed to log zone scan: %v", err)
-		}
+		h.recordZoneVerdict(c.Request().Context(), zoneID, attendee.ID, "no_access")
 		return c.JSON(http.StatusOK, models.ZoneScanResponse{
 			Verdict:      "no_access",
 			Reason:       reason,
@@ -128,9 +128,7 @@ func (h *Handler) ZoneScan(c echo.Context) error {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record zone entry"})
 		}
 	}
-	if err := h.Store.CreateZoneScanLog(c.Request().Context(), zoneID, &attendee.ID, "allowed"); err != nil {
-		log.Printf("Failed to log zone scan: %v", err)
-	}
+	h.recordZoneVerdict(c.Request().Context(), zoneID, attendee.ID, "allowed")
 
 	return c.JSON(http.StatusOK, models.ZoneScanResponse{
 		Verdict:      "allowed",
@@ -141,3 +139,13 @@ func (h *Handler) ZoneScan(c echo.Context) error {
 		FirstEntry:   firstEntry,
 	})
 }
+
+// recordZoneVerdict logs a scan's verdict to zone_scan_log for the zone
+// stats and counts it in metrics.ZoneVerdicts. Log-don't-fail: the verdict
+// is already decided, a failed log write must not change it.
+func (h *Handler) recordZoneVerdict(ctx context.Context, zoneID, attendeeID uuid.UUID, verdict string) {
+	metrics.ZoneVerdicts.WithLabelValues(verdict).Inc()
+	if err := h.Store.CreateZoneScanLog(ctx, zoneID, &attendeeID, verdict); err != nil {
+		log.Printf("Failed to log zone scan: %v", err)
+	}
+}
@@ -24,6 +24,9 @@ func (h *Handler) CreateEventZone(c echo.Context) error {
 	if _, err := h.requireEventOwnership(c, eventID); err != nil {
 		return writeErr(c, err)
 	}
+	if err := h.requirePermission(c, PermZonesEdit, eventID); err != nil {
+		return writeErr(c, err)
+	}
 
 	var zone models.EventZone
 	if err := c.Bind(&zone); err != nil {
@@ -103,6 +106,9 @@ func (h *Handler) UpdateEventZone(c echo.Context) error {
 	if err != nil {
 		return writeErr(c, err)
 	}
+	if err := h.requirePermission(c, PermZonesEdit, existingZone.EventID); err != nil {
+		return writeErr(c, err)
+	}
 
 	var zone models.EventZone
 	if err := c.Bind(&zone); err != nil {
@@ -139,6 +145,9 @@ func (h *Handler) DeleteEventZone(c echo.Context) error {
 	if err != nil {
 		return writeErr(c, err)
 	}
+	if err := h.requirePermission(c, PermZonesEdit, existingZone.EventID); err != nil {
+		return writeErr(c, err)
+	}
 
 	if err := h.Store.DeleteEventZone(c.Request().Context(), id); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete zone"})
@@ -166,7 +175,11 @@ func (h *Handler) CreateZoneAccessRule(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid zone ID"})
 	}
 
-	if _, _, err := h.requireZoneOwnership(c, zoneID); err != nil {
+	zone, _, err := h.requireZoneOwnership(c, zoneID)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	if err := h.requirePermission(c, PermZonesEdit, zone.EventID); err != nil {
 		return writeErr(c, err)
 	}
 
@@ -180,6 +193,7 @@ func (h *Handler) CreateZoneAccessRule(c echo.Context) error {
 	if err := h.Store.CreateZoneAccessRule(c.Request().Context(), &rule); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create access rule"})
 	}
+	h.logTenantDiff(c, &zone.EventID, auditCreateZoneAccessRule, "zone", zoneID, nil, zoneRulesAuditView([]*models.ZoneAccessRule{&rule}))
 
 	return c.JSON(http.StatusCreated, rule)
 }
@@ -210,7 +224,11 @@ func (h *Handler) BulkUpdateZoneAccessRules(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid zone ID"})
 	}
 
-	if _, _, err := h.requireZoneOwnership(c, zoneID); err != nil {
+	zone, _, err := h.requireZoneOwnership(c, zoneID)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	if err := h.requirePermission(c, PermZonesEdit, zone.EventID); err != nil {
 		return writeErr(c, err)
 	}
 
@@ -219,9 +237,17 @@ func (h *Handler) BulkUpdateZoneAccessRules(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
 	}
 
+	// Read the rules being replaced for the audit diff. Failing to is not a
+	// reason to refuse the update; the entry then just shows every new rule.
+	previous, err := h.Store.GetZoneAccessRules(c.Request().Context(), zoneID)
+	if err != nil {
+		log.Printf("Failed to read zone %s access rules for audit: %v", zoneID, err)
+	}
+
 	if err := h.Store.BulkUpdateZoneAccessRules(c.Request().Context(), zoneID, rules); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update access rules"})
 	}
+	h.logTenantDiff(c, &zone.EventID, auditReplaceZoneAccessRule, "zone", zoneID, zoneRulesAuditView(previous), zoneRulesAuditView(rules))
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Access rules updated successfully"})
 }
@@ -242,6 +268,9 @@ func (h *Handler) CreateAttendeeZoneAccess(c echo.Context) error {
 	if _, err := h.requireEventOwnership(c, attendee.EventID); err != nil {
 		return writeErr(c, err)
 	}
+	if err := h.requirePermission(c, PermZonesEdit, attendee.EventID); err != nil {
+		return writeErr(c, err)
+	}
 
 	var access models.AttendeeZoneAccess
 	if err := c.Bind(&access); err != nil {
@@ -291,7 +320,11 @@ func (h *Handler) UpdateAttendeeZoneAccess(c echo.Context) error {
 	if err != nil || existing == nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "Access override not found"})
 	}
-	if _, _, err := h.requireZoneOwnership(c, existing.ZoneID); err != nil {
+	zone, _, err := h.requireZoneOwnership(c, existing.ZoneID)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	if err := h.requirePermission(c, PermZonesEdit, zone.EventID); err != nil {
 		return writeErr(c, err)
 	}
 
@@ -320,7 +353,11 @@ func (h *Handler) DeleteAttendeeZoneAccess(c echo.Context) error {
 	if err != nil || existing == nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "Access override not found"})
 	}
-	if _, _, err := h.requireZoneOwnership(c, existing.ZoneID); err != nil {
+	zone, _, err := h.requireZoneOwnership(c, existing.ZoneID)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	if err := h.requirePermission(c, PermZonesEdit, zone.EventID); err != nil {
 		return writeErr(c, err)
 	}
 
@@ -340,20 +377,15 @@ func (h *Handler) AssignStaffToZone(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid zone ID"})
 	}
 
-	// Only admin/manager may re-scope staff zone assignments — same gate as
-	// the event-staff siblings in users.go. requireZoneOwnership below is
-	// tenant scope only; without this a staff caller could assign anyone
-	// (including themself) to any zone in the tenant. Gate before ownership,
-	// so a staff caller gets 403 without touching the store.
-	claims, err := claimsFromContext(c)
+	// Re-scoping staff zone assignments needs zones:manage on the zone's
+	// event. requireZoneOwnership is tenant scope only; without the
+	// permission a staff caller could assign anyone (including themself) to
+	// any zone in the tenant.
+	zone, _, err := h.requireZoneOwnership(c, zoneID)
 	if err != nil {
 		return writeErr(c, err)
 	}
-	if claims.Role != "admin" && claims.Role != "manager" {
-		return writeErr(c, newHTTPError(http.StatusForbidden, "Access denied"))
-	}
-
-	if _, _, err := h.requireZoneOwnership(c, zoneID); err != nil {
+	if err := h.requirePermission(c, PermZonesManage, zone.EventID); err != nil {
 		return writeErr(c, err)
 	}
 
@@ -382,6 +414,10 @@ func (h *Handler) AssignStaffToZone(c echo.Context) error {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
 	}
 
+	claims, err := claimsFromContext(c)
+	if err != nil {
+		return writeErr(c, err)
+	}
 	assignedByID := uuid.MustParse(claims.UserID)
 
 	assignment := &models.StaffZoneAssignment{
@@ -393,6 +429,7 @@ func (h *Handler) AssignStaffToZone(c echo.Context) error {
 	if err := h.Store.AssignStaffToZone(c.Request().Context(), assignment); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to assign staff"})
 	}
+	h.logTenantDiff(c, &zone.EventID, auditAssignZoneStaff, "user", req.UserID, nil, map[string]interface{}{"zone_id": zoneID})
 
 	return c.JSON(http.StatusCreated, assignment)
 }
@@ -428,23 +465,19 @@ func (h *Handler) RemoveStaffFromZone(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
 	}
 
-	// Only admin/manager may re-scope staff zone assignments — same gate as
-	// AssignStaffToZone above and the event-staff siblings in users.go.
-	claims, err := claimsFromContext(c)
+	// Same zones:manage gate as AssignStaffToZone above.
+	zone, _, err := h.requireZoneOwnership(c, zoneID)
 	if err != nil {
 		return writeErr(c, err)
 	}
-	if claims.Role != "admin" && claims.Role != "manager" {
-		return writeErr(c, newHTTPError(http.StatusForbidden, "Access denied"))
-	}
-
-	if _, _, err := h.requireZoneOwnership(c, zoneID); err != nil {
+	if err := h.requirePermission(c, PermZonesManage, zone.EventID); err != nil {
 		return writeErr(c, err)
 	}
 
 	if err := h.Store.RemoveStaffFromZone(c.Request().Context(), userID, zoneID); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to remove staff"})
 	}
+	h.logTenantDiff(c, &zone.EventID, auditRemoveZoneStaff, "user", userID, map[string]interface{}{"zone_id": zoneID}, nil)
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Staff removed successfully"})
 }
@@ -511,7 +544,8 @@ func (h *Handler) ZoneCheckIn(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, models.ZoneCheckInResponse{Success: false, Error: "Internal error"})
 	}
 
-	// 1b. Only admin/manager or staff assigned to this zone may check attendees in.
+	// 1b. Only callers who manage zones, or staff assigned to this zone,
+	// may check attendees in.
 	claims, err := claimsFromContext(c)
 	if err != nil {
 		if he, ok := err.(*httpError); ok {
@@ -519,7 +553,14 @@ func (h *Handler) ZoneCheckIn(c echo.Context) error {
 		}
 		return c.JSON(http.StatusInternalServerError, models.ZoneCheckInResponse{Success: false, Error: "Internal error"})
 	}
-	if claims.Role != "admin" && claims.Role != "manager" {
+	anyZone, err := h.hasPermission(c, PermZonesManage, zone.EventID)
+	if err != nil {
+		if he, ok := err.(*httpError); ok {
+			return c.JSON(he.status, models.ZoneCheckInResponse{Success: false, Error: he.msg})
+		}
+		return c.JSON(http.StatusInternalServerError, models.ZoneCheckInResponse{Success: false, Error: "Internal error"})
+	}
+	if !anyZone {
 		callerID, err := uuid.Parse(claims.UserID)
 		if err != nil {
 			return c.JSON(http.StatusUnauthorized, models.ZoneCheckInResponse{Success: false, Error: "Invalid token"})
@@ -762,8 +803,12 @@ func (h *Handler) GetAvailableZones(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get zones"})
 	}
 
-	// If admin/manager, return all zones
-	if claims.Role == "admin" || claims.Role == "manager" {
+	// Callers who manage zones work every zone
+	anyZone, err := h.hasPermission(c, PermZonesManage, eventID)
+	if err != nil {
+		return writeErr(c, err)
+	}
+	if anyZone {
 		return c.JSON(http.StatusOK, allZones)
 	}
 
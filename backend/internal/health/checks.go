@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"idento/backend/internal/store"
+)
+
+// SlowPing is the database round trip above which the database component
+// is reported degraded.
+const SlowPing = 500 * time.Millisecond
+
+// Database pings the database and reports the round trip: down on error,
+// degraded when slower than slow.
+func Database(ping func(context.Context) error, slow time.Duration) CheckFunc {
+	return func(ctx context.Context) Result {
+		start := time.Now()
+		err := ping(ctx)
+		latency := time.Since(start)
+		if err != nil {
+			return Down(err.Error())
+		}
+		details := map[string]interface{}{"latency_ms": latency.Milliseconds()}
+		if latency > slow {
+			return Degraded(fmt.Sprintf("ping took %s", latency.Round(time.Millisecond)), details)
+		}
+		return OK(details)
+	}
+}
+
+// Migrations is down while the database lacks migrations embedded in this
+// binary: the code expects a schema the database doesn't have yet.
+func Migrations(status func(context.Context) (*store.MigrationStatus, error)) CheckFunc {
+	return func(ctx context.Context) Result {
+		st, err := status(ctx)
+		if err != nil {
+			return Down(err.Error())
+		}
+		details := map[string]interface{}{"latest": st.Latest, "applied": st.Applied}
+		if len(st.Pending) > 0 {
+			details["pending"] = st.Pending
+			return Result{Status: StatusDown, Error: fmt.Sprintf("%d migration(s) not applied", len(st.Pending)), Details: details}
+		}
+		return OK(details)
+	}
+}
+
+// Err adapts a component's own health method (broker, job runner) that
+// returns nil when healthy.
+func Err(health func() error) CheckFunc {
+	return func(context.Context) Result {
+		return FromError(health())
+	}
+}
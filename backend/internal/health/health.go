@@ -0,0 +1,191 @@
+// Package health runs the dependency checks behind the liveness and
+// readiness probes. A Checker holds named checks, runs them concurrently
+// under one deadline and folds their results into a Report whose overall
+// status is the worst component's.
+package health
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Component and overall statuses, best to worst. Degraded still serves
+// traffic (a slow database ping, say); Down fails the probe.
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+)
+
+// DefaultTimeout bounds a whole probe: a check still running when it
+// expires is reported down.
+const DefaultTimeout = 3 * time.Second
+
+// DefaultMaxAge is how long a report is reused: probes arriving in quick
+// succession (several orchestrators, or anyone hammering the public
+// route) cost one round of checks rather than a database ping each.
+const DefaultMaxAge = 2 * time.Second
+
+// Result is one component's state. Details carries check-specific facts
+// (ping latency, pending migrations, ...) for the JSON report.
+type Result struct {
+	Status  string                 `json:"status"`
+	Error   string                 `json:"error,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// CheckFunc reports a component's state. It should honour ctx.
+type CheckFunc func(ctx context.Context) Result
+
+// Report is the probe response body.
+type Report struct {
+	Status     string            `json:"status"`
+	Components map[string]Result `json:"components"`
+}
+
+// OK reports whether the probe passes: no component is down.
+func (r Report) OK() bool {
+	return r.Status != StatusDown
+}
+
+// Summary is r with each component reduced to its status, for callers
+// who may not see error messages, latencies or migration versions.
+func (r Report) Summary() Report {
+	out := Report{Status: r.Status, Components: make(map[string]Result, len(r.Components))}
+	for name, c := range r.Components {
+		out.Components[name] = Result{Status: c.Status}
+	}
+	return out
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker is an ordered set of named checks. Add them before serving; Run
+// is safe for concurrent use.
+type Checker struct {
+	Timeout time.Duration
+	// MaxAge is how long Run hands out its last report instead of checking
+	// again; zero checks every time.
+	MaxAge time.Duration
+	checks []check
+
+	mu     sync.Mutex
+	last   Report
+	lastAt time.Time
+}
+
+// NewChecker returns an empty Checker with DefaultTimeout and
+// DefaultMaxAge.
+func NewChecker() *Checker {
+	return &Checker{Timeout: DefaultTimeout, MaxAge: DefaultMaxAge}
+}
+
+// Add registers fn under name.
+func (c *Checker) Add(name string, fn CheckFunc) *Checker {
+	c.checks = append(c.checks, check{name: name, fn: fn})
+	return c
+}
+
+// Run returns the last report while it is younger than MaxAge and
+// otherwise checks again; callers arriving during a check wait for its
+// report. A component whose status changed is logged with its error, the
+// detail a public probe response leaves out (see Report.Summary).
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.lastAt.IsZero() && time.Since(c.lastAt) < c.MaxAge {
+		return c.last
+	}
+	report := c.run(ctx)
+	for name, r := range report.Components {
+		if prev, ok := c.last.Components[name]; (ok && prev.Status != r.Status) || (!ok && r.Status != StatusOK) {
+			if r.Error != "" {
+				log.Printf("Health check %s is %s: %s", name, r.Status, r.Error)
+			} else {
+				log.Printf("Health check %s is %s", name, r.Status)
+			}
+		}
+	}
+	c.last, c.lastAt = report, time.Now()
+	return report
+}
+
+// run executes every check concurrently and waits for all of them or the
+// timeout, whichever comes first.
+func (c *Checker) run(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	results := make(map[string]Result, len(c.checks))
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, ch := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := ch.fn(ctx)
+			mu.Lock()
+			results[ch.name] = r
+			mu.Unlock()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	report := Report{Status: StatusOK, Components: make(map[string]Result, len(c.checks))}
+	for _, ch := range c.checks {
+		r, ok := results[ch.name]
+		if !ok {
+			r = Down("check timed out")
+		}
+		report.Components[ch.name] = r
+		report.Status = worst(report.Status, r.Status)
+	}
+	return report
+}
+
+// OK is a healthy Result.
+func OK(details map[string]interface{}) Result {
+	return Result{Status: StatusOK, Details: details}
+}
+
+// Degraded is a Result that still passes the probe.
+func Degraded(msg string, details map[string]interface{}) Result {
+	return Result{Status: StatusDegraded, Error: msg, Details: details}
+}
+
+// Down is a Result that fails the probe.
+func Down(msg string) Result {
+	return Result{Status: StatusDown, Error: msg}
+}
+
+// FromError is OK when err is nil and Down with its message otherwise.
+func FromError(err error) Result {
+	if err != nil {
+		return Down(err.Error())
+	}
+	return OK(nil)
+}
+
+var rank = map[string]int{StatusOK: 0, StatusDegraded: 1, StatusDown: 2}
+
+func worst(a, b string) string {
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
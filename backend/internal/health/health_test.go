@@ -0,0 +1,118 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"idento/backend/internal/store"
+)
+
+func fixed(r Result) CheckFunc {
+	return func(context.Context) Result { return r }
+}
+
+func TestRunReportsWorstComponent(t *testing.T) {
+	cases := []struct {
+		name   string
+		checks map[string]Result
+		want   string
+		ok     bool
+	}{
+		{"all ok", map[string]Result{"a": OK(nil), "b": OK(nil)}, StatusOK, true},
+		{"degraded still passes", map[string]Result{"a": OK(nil), "b": Degraded("slow", nil)}, StatusDegraded, true},
+		{"down fails", map[string]Result{"a": Degraded("slow", nil), "b": Down("gone")}, StatusDown, false},
+		{"no checks", nil, StatusOK, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewChecker()
+			for name, r := range tc.checks {
+				c.Add(name, fixed(r))
+			}
+			report := c.Run(context.Background())
+			if report.Status != tc.want || report.OK() != tc.ok || len(report.Components) != len(tc.checks) {
+				t.Errorf("report = %+v, want status %s ok %v", report, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestRunReportsHungCheckDown(t *testing.T) {
+	c := NewChecker()
+	c.Timeout = 20 * time.Millisecond
+	block := make(chan struct{})
+	defer close(block)
+	c.Add("fast", fixed(OK(nil)))
+	c.Add("hung", func(context.Context) Result { <-block; return OK(nil) })
+
+	start := time.Now()
+	report := c.Run(context.Background())
+	if time.Since(start) > time.Second {
+		t.Fatal("Run waited for the hung check")
+	}
+	if report.Components["fast"].Status != StatusOK || report.Components["hung"].Status != StatusDown || report.OK() {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestRunReusesRecentReport(t *testing.T) {
+	calls := 0
+	c := NewChecker().Add("db", func(context.Context) Result { calls++; return OK(nil) })
+	c.Run(context.Background())
+	c.Run(context.Background())
+	if calls != 1 {
+		t.Fatalf("checks ran %d times within MaxAge, want 1", calls)
+	}
+	c.lastAt = time.Now().Add(-c.MaxAge)
+	c.Run(context.Background())
+	if calls != 2 {
+		t.Fatalf("checks ran %d times after MaxAge, want 2", calls)
+	}
+}
+
+func TestReportSummaryKeepsOnlyStatuses(t *testing.T) {
+	report := Report{Status: StatusDown, Components: map[string]Result{
+		"db":         Degraded("ping took 700ms", map[string]interface{}{"latency_ms": 700}),
+		"migrations": {Status: StatusDown, Error: "1 migration(s) not applied", Details: map[string]interface{}{"pending": []int64{42}}},
+	}}
+	got := report.Summary()
+	if got.Status != StatusDown || got.Components["db"].Status != StatusDegraded || got.Components["db"].Error != "" ||
+		got.Components["migrations"].Error != "" || got.Components["migrations"].Details != nil {
+		t.Fatalf("summary = %+v", got)
+	}
+	if report.Components["db"].Error == "" {
+		t.Fatal("Summary modified the report")
+	}
+}
+
+func TestDatabaseCheck(t *testing.T) {
+	ok := Database(func(context.Context) error { return nil }, time.Second)(context.Background())
+	if ok.Status != StatusOK || ok.Details["latency_ms"] == nil {
+		t.Errorf("fast ping = %+v", ok)
+	}
+	slow := Database(func(context.Context) error { time.Sleep(5 * time.Millisecond); return nil }, time.Millisecond)(context.Background())
+	if slow.Status != StatusDegraded {
+		t.Errorf("slow ping = %+v", slow)
+	}
+	down := Database(func(context.Context) error { return errors.New("connection refused") }, time.Second)(context.Background())
+	if down.Status != StatusDown || down.Error != "connection refused" {
+		t.Errorf("failed ping = %+v", down)
+	}
+}
+
+func TestMigrationsCheck(t *testing.T) {
+	current := Migrations(func(context.Context) (*store.MigrationStatus, error) {
+		return &store.MigrationStatus{Latest: "000050", Applied: "000050"}, nil
+	})(context.Background())
+	if current.Status != StatusOK || current.Details["latest"] != "000050" {
+		t.Errorf("current schema = %+v", current)
+	}
+	behind := Migrations(func(context.Context) (*store.MigrationStatus, error) {
+		return &store.MigrationStatus{Latest: "000050", Applied: "000049", Pending: []string{"000050"}}, nil
+	})(context.Background())
+	if behind.Status != StatusDown || behind.Details["pending"] == nil {
+		t.Errorf("schema behind = %+v", behind)
+	}
+}
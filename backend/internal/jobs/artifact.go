@@ -0,0 +1,93 @@
+package jobs
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"idento/backend/internal/models"
+)
+
+// DefaultMaxArtifactBytes caps a job's artifact when no limit is configured.
+const DefaultMaxArtifactBytes int64 = 512 << 20
+
+// ArtifactTooLargeError is the job error of a handler whose artifact grew
+// past its limit; the message is meant for the job's owner.
+type ArtifactTooLargeError struct {
+	Limit int64
+}
+
+func (e *ArtifactTooLargeError) Error() string {
+	return fmt.Sprintf("the file would exceed the %d MB download limit; narrow the filter and run it again", e.Limit>>20)
+}
+
+// ArtifactFile spools a job's artifact to a temporary file, so an export
+// of any size costs disk rather than memory, and refuses writes that would
+// take it past its limit. A handler writes into it and returns Artifact;
+// the runner closes it, which removes the file, once the job is stored.
+type ArtifactFile struct {
+	f        *os.File
+	size     int64
+	limit    int64
+	tooLarge *ArtifactTooLargeError
+}
+
+// NewArtifactFile opens an empty spool holding at most limit bytes
+// (DefaultMaxArtifactBytes when limit <= 0).
+func NewArtifactFile(limit int64) (*ArtifactFile, error) {
+	if limit <= 0 {
+		limit = DefaultMaxArtifactBytes
+	}
+	f, err := os.CreateTemp("", "idento-job-artifact-*")
+	if err != nil {
+		return nil, fmt.Errorf("create artifact file: %w", err)
+	}
+	return &ArtifactFile{f: f, limit: limit}, nil
+}
+
+// Write appends p, or fails with *ArtifactTooLargeError when that would
+// exceed the limit.
+func (a *ArtifactFile) Write(p []byte) (int, error) {
+	if a.size+int64(len(p)) > a.limit {
+		a.tooLarge = &ArtifactTooLargeError{Limit: a.limit}
+		return 0, a.tooLarge
+	}
+	n, err := a.f.Write(p)
+	a.size += int64(n)
+	return n, err
+}
+
+// TooLarge is the *ArtifactTooLargeError of a write refused for the limit,
+// or nil. Writers in between (zip, xlsx) may replace that error with their
+// own, so callers ask the spool rather than unwrap what they got back.
+func (a *ArtifactFile) TooLarge() error {
+	if a.tooLarge == nil {
+		return nil
+	}
+	return a.tooLarge
+}
+
+// Artifact rewinds the spool and returns it as the content of an artifact
+// named name.
+func (a *ArtifactFile) Artifact(name, contentType string) (*models.JobArtifact, error) {
+	if _, err := a.f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("rewind artifact file: %w", err)
+	}
+	return &models.JobArtifact{Name: name, ContentType: contentType, Size: a.size, Content: a}, nil
+}
+
+// Read reads the spooled bytes back after Artifact rewound them.
+func (a *ArtifactFile) Read(p []byte) (int, error) {
+	return a.f.Read(p)
+}
+
+// Close removes the file. It is safe to call more than once.
+func (a *ArtifactFile) Close() error {
+	if a.f == nil {
+		return nil
+	}
+	name := a.f.Name()
+	a.f.Close()
+	a.f = nil
+	return os.Remove(name)
+}
@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"idento/backend/internal/models"
+)
+
+func TestArtifactFileRefusesWritesPastItsLimit(t *testing.T) {
+	file, err := NewArtifactFile(8)
+	if err != nil {
+		t.Fatalf("NewArtifactFile: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.Write([]byte("12345")); err != nil {
+		t.Fatalf("first write: %v", err)
+	}
+	if file.TooLarge() != nil {
+		t.Fatalf("TooLarge = %v before the limit", file.TooLarge())
+	}
+	_, err = file.Write([]byte("6789"))
+	var tooLarge *ArtifactTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 8 {
+		t.Fatalf("write past the limit = %v, want *ArtifactTooLargeError{8}", err)
+	}
+	if !errors.As(file.TooLarge(), &tooLarge) {
+		t.Fatalf("TooLarge = %v after a refused write", file.TooLarge())
+	}
+
+	a, err := file.Artifact("a.txt", "text/plain")
+	if err != nil {
+		t.Fatalf("Artifact: %v", err)
+	}
+	data, err := io.ReadAll(a.Content)
+	if err != nil || string(data) != "12345" || a.Size != 5 {
+		t.Fatalf("artifact = %q (size %d), %v; want the 5 accepted bytes", data, a.Size, err)
+	}
+}
+
+func TestRunnerRemovesTheArtifactFileOnceStored(t *testing.T) {
+	job := queued("export")
+	s := newFakeStore(job)
+	var path string
+	r := testRunner(s, nil, map[string]HandlerFunc{
+		"export": func(context.Context, *models.Job, Progress) (*Output, error) {
+			file, err := NewArtifactFile(0)
+			if err != nil {
+				return nil, err
+			}
+			path = file.f.Name()
+			if _, err := io.WriteString(file, "code\nABC\n"); err != nil {
+				return nil, err
+			}
+			a, err := file.Artifact("a.csv", "text/csv")
+			return &Output{Artifact: a}, err
+		},
+	})
+	if claimed, err := r.RunOnce(context.Background()); err != nil || !claimed {
+		t.Fatalf("RunOnce = %v, %v; want a claimed job", claimed, err)
+	}
+	if got := s.status(job.ID); got != models.JobStatusSucceeded {
+		t.Fatalf("status = %q, want succeeded", got)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("artifact file %s still exists after the job (stat: %v)", path, err)
+	}
+}
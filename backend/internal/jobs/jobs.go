@@ -0,0 +1,375 @@
+// Package jobs runs background jobs (models.Job) queued in Postgres: long
+// imports, exports and badge print batches that would not survive a single
+// HTTP request behind a proxy timeout. A Runner's workers claim jobs
+// through the store (FOR UPDATE SKIP LOCKED, so any number of replicas can
+// run workers side by side), keep their lease alive with a heartbeat that
+// also records progress, and publish every state change on the broker
+// under the job's ID, which is what GET /api/jobs/{id}/stream listens to.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"idento/backend/internal/broker"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+)
+
+// Runner defaults.
+const (
+	DefaultPollInterval     = time.Second
+	DefaultProgressInterval = time.Second
+	DefaultLease            = time.Minute
+	DefaultMaxAttempts      = 3
+)
+
+// publishTimeout bounds one broker publish, like the handlers' own.
+const publishTimeout = 5 * time.Second
+
+// Store is the slice of the data layer the workers need.
+type Store interface {
+	ClaimJob(ctx context.Context, workerID string, types []string, lease time.Duration) (*models.Job, error)
+	UpdateJobProgress(ctx context.Context, id uuid.UUID, workerID string, progress models.JobProgress, lease time.Duration) error
+	CompleteJob(ctx context.Context, id uuid.UUID, workerID string, progress models.JobProgress, result json.RawMessage, artifact *models.JobArtifact) error
+	FailJob(ctx context.Context, id uuid.UUID, workerID, msg string) error
+}
+
+// Progress reports done of total items processed (total 0 while unknown).
+// It is cheap to call once per item: the runner only persists and
+// publishes the latest value every ProgressInterval.
+type Progress func(done, total int)
+
+// Output is what a successful job produces: Result is marshalled into the
+// job's JSON result, Artifact (optional) is the downloadable file. The
+// runner closes an Artifact.Content that is an io.Closer (an ArtifactFile)
+// once the job is recorded, whatever became of it.
+type Output struct {
+	Result   interface{}
+	Artifact *models.JobArtifact
+}
+
+// HandlerFunc runs one job. ctx is cancelled when the worker loses the
+// job's lease or shuts down. The returned error's message is stored on the
+// job as-is and shown to its owner, so it must be fit for that; jobs that
+// fail are not retried.
+type HandlerFunc func(ctx context.Context, job *models.Job, progress Progress) (*Output, error)
+
+// Runner claims and runs jobs. Configure the exported fields before Start.
+type Runner struct {
+	Store    Store
+	Broker   broker.Broker // optional: nil only disables live progress
+	Handlers map[string]HandlerFunc
+
+	// WorkerID identifies this process in locked_by.
+	WorkerID string
+	// PollInterval is how long an idle worker waits before polling again.
+	PollInterval time.Duration
+	// ProgressInterval is the heartbeat tick: progress is flushed at most
+	// this often.
+	ProgressInterval time.Duration
+	// Lease is how long a claim lasts without a heartbeat; the heartbeat
+	// renews it at least every Lease/3.
+	Lease time.Duration
+	// MaxAttempts is how many times a job may be claimed before the runner
+	// gives up on it (each re-claim means its previous worker died).
+	MaxAttempts int
+
+	// started and lastBeat back Health: every worker loop iteration and
+	// every heartbeat tick stores the current time in lastBeat (unix nanos).
+	started  atomic.Bool
+	lastBeat atomic.Int64
+	// workers tracks the worker goroutines, for Wait.
+	workers sync.WaitGroup
+}
+
+// NewRunner returns a Runner with the default intervals and a WorkerID
+// made of the host name, pid and a random suffix.
+func NewRunner(s Store, b broker.Broker, handlers map[string]HandlerFunc) *Runner {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "worker"
+	}
+	return &Runner{
+		Store:            s,
+		Broker:           b,
+		Handlers:         handlers,
+		WorkerID:         fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.NewString()[:8]),
+		PollInterval:     DefaultPollInterval,
+		ProgressInterval: DefaultProgressInterval,
+		Lease:            DefaultLease,
+		MaxAttempts:      DefaultMaxAttempts,
+	}
+}
+
+// Start launches workers worker goroutines that run until ctx is cancelled,
+// and reports whether it did. No-op when workers <= 0: the replica then
+// only enqueues, and other replicas run the jobs.
+func (r *Runner) Start(ctx context.Context, workers int) bool {
+	if workers <= 0 {
+		log.Println("Background job workers disabled on this replica (JOB_WORKERS=0)")
+		return false
+	}
+	log.Printf("Background job workers: %d (worker id %s)", workers, r.WorkerID)
+	r.beat()
+	r.started.Store(true)
+	for i := 0; i < workers; i++ {
+		r.workers.Add(1)
+		go func() {
+			defer r.workers.Done()
+			r.work(ctx)
+		}()
+	}
+	return true
+}
+
+// Wait blocks until every worker has returned after the Start context was
+// cancelled, or until ctx is done, whichever comes first. An interrupted
+// job stays under its lease, so another worker takes it over later.
+func (r *Runner) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		r.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Runner) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		r.beat()
+		claimed, err := r.RunOnce(ctx)
+		if err != nil {
+			log.Printf("Background jobs: %v", err)
+		}
+		if claimed {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+func (r *Runner) beat() {
+	r.lastBeat.Store(time.Now().UnixNano())
+}
+
+// Health reports whether the workers are still turning over: an idle worker
+// beats every PollInterval, a busy one every ProgressInterval, so no beat
+// for a whole Lease means every worker is stuck (a hung claim query, a
+// handler ignoring its context). A runner that was never started, on an
+// enqueue-only replica, is healthy.
+func (r *Runner) Health() error {
+	if !r.started.Load() {
+		return nil
+	}
+	last := time.Unix(0, r.lastBeat.Load())
+	if idle := time.Since(last); idle > r.Lease {
+		return fmt.Errorf("no worker activity for %s", idle.Round(time.Second))
+	}
+	return nil
+}
+
+func (r *Runner) types() []string {
+	types := make([]string, 0, len(r.Handlers))
+	for t := range r.Handlers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// RunOnce claims one job and runs it to completion. It reports whether a
+// job was claimed; the error is only ever a claim failure — what happens
+// to the job itself is recorded on the job.
+func (r *Runner) RunOnce(ctx context.Context) (bool, error) {
+	job, err := r.Store.ClaimJob(ctx, r.WorkerID, r.types(), r.Lease)
+	if err != nil {
+		return false, err
+	}
+	if job == nil {
+		return false, nil
+	}
+	r.publish(job.ID)
+	defer r.publish(job.ID)
+
+	if job.Attempts > r.MaxAttempts {
+		r.finish(job, r.Store.FailJob(ctx, job.ID, r.WorkerID,
+			fmt.Sprintf("job abandoned: its worker stopped %d times", job.Attempts-1)))
+		return true, nil
+	}
+	r.run(ctx, job)
+	return true, nil
+}
+
+func (r *Runner) run(ctx context.Context, job *models.Job) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	t := &tracker{p: job.Progress}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.heartbeat(jobCtx, cancel, job.ID, t, stop)
+	}()
+
+	out, err := call(jobCtx, r.Handlers[job.Type], job, t.set)
+	if out != nil && out.Artifact != nil {
+		if c, ok := out.Artifact.Content.(io.Closer); ok {
+			defer c.Close()
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		// Shutting down: leave the job running under its lease, so another
+		// worker takes it over once the lease runs out.
+		log.Printf("Background job %s interrupted by shutdown", job.ID)
+		return
+	}
+	if jobCtx.Err() != nil {
+		log.Printf("Background job %s: lease lost, abandoning it", job.ID)
+		return
+	}
+	progress, _ := t.snapshot()
+	if err != nil {
+		r.finish(job, r.Store.FailJob(ctx, job.ID, r.WorkerID, err.Error()))
+		return
+	}
+	var result json.RawMessage
+	var artifact *models.JobArtifact
+	if out != nil {
+		artifact = out.Artifact
+		if out.Result != nil {
+			if result, err = json.Marshal(out.Result); err != nil {
+				r.finish(job, r.Store.FailJob(ctx, job.ID, r.WorkerID, "failed to encode job result"))
+				return
+			}
+		}
+	}
+	r.finish(job, r.Store.CompleteJob(ctx, job.ID, r.WorkerID, progress, result, artifact))
+}
+
+// call runs h, turning a missing handler or a panic into a job error.
+func call(ctx context.Context, h HandlerFunc, job *models.Job, progress Progress) (out *Output, err error) {
+	if h == nil {
+		return nil, fmt.Errorf("unknown job type %q", job.Type)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("Background job %s (%s) panicked: %v", job.ID, job.Type, p)
+			out, err = nil, errors.New("internal error")
+		}
+	}()
+	return h(ctx, job, progress)
+}
+
+// finish logs the outcome of the final CompleteJob/FailJob.
+func (r *Runner) finish(job *models.Job, err error) {
+	switch {
+	case errors.Is(err, store.ErrJobLost):
+		log.Printf("Background job %s: lease lost before it finished; its result was dropped", job.ID)
+	case err != nil:
+		log.Printf("Background job %s: failed to record outcome: %v", job.ID, err)
+	}
+}
+
+// heartbeat flushes changed progress every ProgressInterval and renews the
+// lease at least every Lease/3, until stop closes. Losing the lease
+// cancels the job.
+func (r *Runner) heartbeat(ctx context.Context, cancel context.CancelFunc, id uuid.UUID, t *tracker, stop <-chan struct{}) {
+	ticker := time.NewTicker(r.ProgressInterval)
+	defer ticker.Stop()
+	lastFlush := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		r.beat()
+		p, changed := t.snapshot()
+		if !changed && time.Since(lastFlush) < r.Lease/3 {
+			continue
+		}
+		err := r.Store.UpdateJobProgress(ctx, id, r.WorkerID, p, r.Lease)
+		if errors.Is(err, store.ErrJobLost) {
+			cancel()
+			return
+		}
+		if err != nil {
+			log.Printf("Background job %s: heartbeat failed: %v", id, err)
+			t.markDirty()
+			continue
+		}
+		lastFlush = time.Now()
+		if changed {
+			r.publish(id)
+		}
+	}
+}
+
+func (r *Runner) publish(id uuid.UUID) {
+	if r.Broker == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	if err := r.Broker.Publish(ctx, broker.Changed(id)); err != nil {
+		log.Printf("Background job %s: broker publish failed: %v", id, err)
+	}
+}
+
+// tracker holds a running job's latest progress between heartbeats.
+type tracker struct {
+	mu    sync.Mutex
+	p     models.JobProgress
+	dirty bool
+}
+
+func (t *tracker) set(done, total int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.p.Done != done || t.p.Total != total {
+		t.p = models.JobProgress{Done: done, Total: total}
+		t.dirty = true
+	}
+}
+
+func (t *tracker) markDirty() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dirty = true
+}
+
+// snapshot returns the progress and whether it changed since the last one.
+func (t *tracker) snapshot() (models.JobProgress, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	changed := t.dirty
+	t.dirty = false
+	return t.p, changed
+}
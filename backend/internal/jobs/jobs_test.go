@@ -0,0 +1,327 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"idento/backend/internal/broker"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+)
+
+// fakeStore is an in-memory jobs table with the store's lease semantics.
+type fakeStore struct {
+	mu       sync.Mutex
+	jobs     []*models.Job
+	lockedBy map[uuid.UUID]string
+	progress []models.JobProgress
+	results  map[uuid.UUID]json.RawMessage
+	errs     map[uuid.UUID]string
+	files    map[uuid.UUID]*models.JobArtifact
+}
+
+func newFakeStore(jobs ...*models.Job) *fakeStore {
+	return &fakeStore{
+		jobs:     jobs,
+		lockedBy: map[uuid.UUID]string{},
+		results:  map[uuid.UUID]json.RawMessage{},
+		errs:     map[uuid.UUID]string{},
+		files:    map[uuid.UUID]*models.JobArtifact{},
+	}
+}
+
+func (f *fakeStore) ClaimJob(_ context.Context, workerID string, types []string, _ time.Duration) (*models.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, j := range f.jobs {
+		if j.Status != models.JobStatusQueued {
+			continue
+		}
+		for _, t := range types {
+			if t == j.Type {
+				j.Status = models.JobStatusRunning
+				j.Attempts++
+				f.lockedBy[j.ID] = workerID
+				cp := *j
+				return &cp, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeStore) held(id uuid.UUID, workerID string) *models.Job {
+	for _, j := range f.jobs {
+		if j.ID == id && j.Status == models.JobStatusRunning && f.lockedBy[id] == workerID {
+			return j
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) UpdateJobProgress(_ context.Context, id uuid.UUID, workerID string, p models.JobProgress, _ time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	j := f.held(id, workerID)
+	if j == nil {
+		return store.ErrJobLost
+	}
+	j.Progress = p
+	f.progress = append(f.progress, p)
+	return nil
+}
+
+func (f *fakeStore) CompleteJob(_ context.Context, id uuid.UUID, workerID string, p models.JobProgress, result json.RawMessage, a *models.JobArtifact) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	j := f.held(id, workerID)
+	if j == nil {
+		return store.ErrJobLost
+	}
+	j.Status, j.Progress = models.JobStatusSucceeded, p
+	f.results[id], f.files[id] = result, a
+	return nil
+}
+
+func (f *fakeStore) FailJob(_ context.Context, id uuid.UUID, workerID, msg string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	j := f.held(id, workerID)
+	if j == nil {
+		return store.ErrJobLost
+	}
+	j.Status = models.JobStatusFailed
+	f.errs[id] = msg
+	return nil
+}
+
+func (f *fakeStore) status(id uuid.UUID) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, j := range f.jobs {
+		if j.ID == id {
+			return j.Status
+		}
+	}
+	return ""
+}
+
+func queued(jobType string) *models.Job {
+	return &models.Job{ID: uuid.New(), Type: jobType, Status: models.JobStatusQueued}
+}
+
+func testRunner(s Store, b broker.Broker, handlers map[string]HandlerFunc) *Runner {
+	r := NewRunner(s, b, handlers)
+	r.ProgressInterval = 5 * time.Millisecond
+	r.PollInterval = 5 * time.Millisecond
+	return r
+}
+
+func TestRunOnceCompletesJobWithResultArtifactAndProgress(t *testing.T) {
+	job := queued("export")
+	s := newFakeStore(job)
+	mem := broker.NewMemBroker()
+	updates, unsubscribe := mem.Subscribe(job.ID)
+	defer unsubscribe()
+
+	r := testRunner(s, mem, map[string]HandlerFunc{
+		"export": func(ctx context.Context, j *models.Job, progress Progress) (*Output, error) {
+			progress(1, 2)
+			time.Sleep(30 * time.Millisecond) // let a heartbeat flush it
+			progress(2, 2)
+			return &Output{
+				Result:   map[string]int{"rows": 2},
+				Artifact: &models.JobArtifact{Name: "a.csv", ContentType: "text/csv", Size: 1, Content: strings.NewReader("x")},
+			}, nil
+		},
+	})
+	claimed, err := r.RunOnce(context.Background())
+	if err != nil || !claimed {
+		t.Fatalf("RunOnce = %v, %v; want a claimed job", claimed, err)
+	}
+	if got := s.status(job.ID); got != models.JobStatusSucceeded {
+		t.Fatalf("status = %q, want succeeded", got)
+	}
+	if string(s.results[job.ID]) != `{"rows":2}` || s.files[job.ID] == nil || s.files[job.ID].Name != "a.csv" {
+		t.Errorf("result = %s, artifact = %+v", s.results[job.ID], s.files[job.ID])
+	}
+	if job.Progress != (models.JobProgress{Done: 2, Total: 2}) {
+		t.Errorf("final progress = %+v, want 2/2", job.Progress)
+	}
+	if len(s.progress) == 0 || s.progress[0] != (models.JobProgress{Done: 1, Total: 2}) {
+		t.Errorf("heartbeat progress = %+v, want 1/2 flushed mid-run", s.progress)
+	}
+	select {
+	case <-updates:
+	default:
+		t.Error("no broker signal for the job")
+	}
+
+	claimed, err = r.RunOnce(context.Background())
+	if err != nil || claimed {
+		t.Fatalf("second RunOnce = %v, %v; want nothing to claim", claimed, err)
+	}
+}
+
+func TestRunOnceFailsJobOnHandlerErrorAndPanic(t *testing.T) {
+	bad, boom, unknown := queued("bad"), queued("boom"), queued("bad")
+	unknown.Type = "unregistered"
+	s := newFakeStore(bad, boom, unknown)
+	r := testRunner(s, nil, map[string]HandlerFunc{
+		"bad": func(context.Context, *models.Job, Progress) (*Output, error) {
+			return nil, errors.New("the event no longer exists")
+		},
+		"boom": func(context.Context, *models.Job, Progress) (*Output, error) { panic("nil map") },
+	})
+	for i := 0; i < 2; i++ {
+		if _, err := r.RunOnce(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.errs[bad.ID] != "the event no longer exists" || s.status(bad.ID) != models.JobStatusFailed {
+		t.Errorf("bad job: %q / %s", s.errs[bad.ID], s.status(bad.ID))
+	}
+	if s.errs[boom.ID] != "internal error" || s.status(boom.ID) != models.JobStatusFailed {
+		t.Errorf("panicking job: %q / %s", s.errs[boom.ID], s.status(boom.ID))
+	}
+	if s.status(unknown.ID) != models.JobStatusQueued {
+		t.Errorf("a job of a type this runner has no handler for was claimed")
+	}
+}
+
+// A job that keeps killing its worker is failed instead of run again.
+func TestRunOnceAbandonsJobPastMaxAttempts(t *testing.T) {
+	job := queued("export")
+	job.Attempts = DefaultMaxAttempts
+	s := newFakeStore(job)
+	ran := false
+	r := testRunner(s, nil, map[string]HandlerFunc{
+		"export": func(context.Context, *models.Job, Progress) (*Output, error) { ran = true; return nil, nil },
+	})
+	if _, err := r.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if ran || s.status(job.ID) != models.JobStatusFailed {
+		t.Fatalf("ran = %v, status = %s; want the job failed without running", ran, s.status(job.ID))
+	}
+}
+
+// Losing the lease cancels the job and leaves its outcome to whoever holds
+// the job now.
+func TestRunOnceStopsJobWhenLeaseIsLost(t *testing.T) {
+	job := queued("export")
+	s := newFakeStore(job)
+	r := testRunner(s, nil, map[string]HandlerFunc{
+		"export": func(ctx context.Context, j *models.Job, progress Progress) (*Output, error) {
+			s.mu.Lock()
+			s.lockedBy[j.ID] = "another-worker"
+			s.mu.Unlock()
+			progress(1, 1)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(2 * time.Second):
+				return nil, errors.New("job was not cancelled")
+			}
+		},
+	})
+	if _, err := r.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s.status(job.ID) != models.JobStatusRunning || s.errs[job.ID] != "" {
+		t.Fatalf("status = %s, err = %q; want the job left to its new holder", s.status(job.ID), s.errs[job.ID])
+	}
+}
+
+func TestStartRunsQueuedJobsUntilCancelled(t *testing.T) {
+	first, second := queued("export"), queued("export")
+	s := newFakeStore(first, second)
+	r := testRunner(s, nil, map[string]HandlerFunc{
+		"export": func(context.Context, *models.Job, Progress) (*Output, error) { return nil, nil },
+	})
+	if r.Start(context.Background(), 0) {
+		t.Fatal("Start with 0 workers reported started")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if !r.Start(ctx, 2) {
+		t.Fatal("Start did not start")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for s.status(first.ID) != models.JobStatusSucceeded || s.status(second.ID) != models.JobStatusSucceeded {
+		if time.Now().After(deadline) {
+			t.Fatalf("jobs not run: %s, %s", s.status(first.ID), s.status(second.ID))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHealthFailsWhenWorkersStopBeating(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	s := newFakeStore(queued("export"))
+	r := testRunner(s, nil, map[string]HandlerFunc{
+		// Ignores its context, like a handler wedged on a hung call.
+		"export": func(context.Context, *models.Job, Progress) (*Output, error) { <-release; return nil, nil },
+	})
+	r.Lease = 50 * time.Millisecond
+	if err := r.Health(); err != nil {
+		t.Fatalf("unstarted runner: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.Start(ctx, 1)
+	if err := r.Health(); err != nil {
+		t.Fatalf("just started: %v", err)
+	}
+	// The heartbeat keeps beating while the handler runs; once shutdown
+	// stops it, the stuck worker goes silent.
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	deadline := time.Now().Add(2 * time.Second)
+	for r.Health() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Health never reported the silent worker")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWaitReturnsOnceWorkersStopAndLeavesInterruptedJobLeased(t *testing.T) {
+	job := queued("export")
+	s := newFakeStore(job)
+	started := make(chan struct{})
+	r := testRunner(s, nil, map[string]HandlerFunc{
+		"export": func(ctx context.Context, _ *models.Job, _ Progress) (*Output, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	r.Start(ctx, 1)
+	<-started
+
+	short, cancelShort := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelShort()
+	if err := r.Wait(short); err == nil {
+		t.Fatal("Wait returned while the worker was still running")
+	}
+
+	cancel()
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelWait()
+	if err := r.Wait(waitCtx); err != nil {
+		t.Fatalf("Wait after cancel: %v", err)
+	}
+	if got := s.status(job.ID); got != models.JobStatusRunning {
+		t.Errorf("interrupted job status = %q, want %q (left under its lease)", got, models.JobStatusRunning)
+	}
+}
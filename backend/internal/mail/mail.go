@@ -0,0 +1,311 @@
+// Package mail sends the backend's transactional email (password reset,
+// email verification, invitations, tickets) over SMTP, rendered from
+// localized templates.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message is one outgoing email: a plain-text body, optionally with an
+// HTML alternative and attachments.
+type Message struct {
+	To      string
+	ReplyTo string // optional
+	Subject string
+	Body    string
+	HTML    string // optional
+	// Attachments with a ContentID are inline parts that HTML references
+	// as cid:<ContentID>; the others are regular attachments.
+	Attachments []Attachment
+}
+
+// Attachment is a file sent with a Message.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+	ContentID   string
+}
+
+// Errors that mean the message can never reach its recipient, as opposed
+// to a failure worth retrying (see IsBounce).
+var (
+	ErrInvalidRecipient  = errors.New("invalid recipient address")
+	ErrRecipientRejected = errors.New("recipient rejected")
+)
+
+// IsBounce reports whether err from Send means the recipient address is
+// unusable: malformed, or refused by the server with a permanent (5xx)
+// reply. Anything else may succeed on a later attempt.
+func IsBounce(err error) bool {
+	return errors.Is(err, ErrInvalidRecipient) || errors.Is(err, ErrRecipientRejected)
+}
+
+// Sender delivers messages. Send returns once the message is handed off
+// (accepted by the relay), not when it reaches the inbox.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPConfig is where and as whom SMTPSender relays mail.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // empty: no AUTH
+	Password string
+	From     string
+}
+
+// SMTPSender relays mail through an SMTP server, upgrading the connection
+// with STARTTLS whenever the server offers it.
+type SMTPSender struct {
+	cfg SMTPConfig
+}
+
+// NewSMTPSender returns a Sender for cfg.
+func NewSMTPSender(cfg SMTPConfig) *SMTPSender {
+	return &SMTPSender{cfg: cfg}
+}
+
+// Send delivers msg in a single SMTP session bounded by ctx.
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	from, err := mail.ParseAddress(s.cfg.From)
+	if err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRecipient, err)
+	}
+	data, err := compose(from, to, msg)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.cfg.Host}); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("smtp MAIL FROM: %w", err)
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		var reply *textproto.Error
+		if errors.As(err, &reply) && reply.Code >= 500 {
+			return fmt.Errorf("%w: smtp RCPT TO: %v", ErrRecipientRejected, err)
+		}
+		return fmt.Errorf("smtp RCPT TO: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("smtp write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	return c.Quit()
+}
+
+// compose renders msg as an RFC 5322 message: UTF-8 headers are
+// Q-encoded and text bodies are quoted-printable, so Cyrillic survives
+// 7-bit relays. A message with only a text body is a single text/plain
+// entity; HTML and attachments nest it in multipart/alternative, /related
+// (inline parts) and /mixed (attachments).
+func compose(from, to *mail.Address, msg Message) ([]byte, error) {
+	domain := "localhost"
+	if at := strings.LastIndexByte(from.Address, '@'); at >= 0 {
+		domain = from.Address[at+1:]
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	if msg.ReplyTo != "" {
+		replyTo, err := mail.ParseAddress(msg.ReplyTo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reply-to address: %w", err)
+		}
+		fmt.Fprintf(&buf, "Reply-To: %s\r\n", replyTo.String())
+	}
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", uuid.NewString(), domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	root, err := messageEntity(msg)
+	if err != nil {
+		return nil, err
+	}
+	header, body, err := root.render()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%s: %s\r\n", k, header.Get(k))
+	}
+	buf.WriteString("\r\n")
+	buf.Write(body)
+	return buf.Bytes(), nil
+}
+
+// entity is one MIME entity: a leaf with an encoded body, or a multipart
+// container of parts.
+type entity struct {
+	header  textproto.MIMEHeader
+	body    []byte
+	subtype string // multipart subtype; empty for a leaf
+	parts   []entity
+}
+
+// render returns e's own headers and its encoded body.
+func (e entity) render() (textproto.MIMEHeader, []byte, error) {
+	if e.subtype == "" {
+		return e.header, e.body, nil
+	}
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, p := range e.parts {
+		header, body, err := p.render()
+		if err != nil {
+			return nil, nil, err
+		}
+		w, err := mw.CreatePart(header)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := w.Write(body); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, nil, err
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mime.FormatMediaType("multipart/"+e.subtype, map[string]string{"boundary": mw.Boundary()}))
+	return header, buf.Bytes(), nil
+}
+
+func messageEntity(msg Message) (entity, error) {
+	body, err := textEntity("text/plain", msg.Body)
+	if err != nil {
+		return entity{}, err
+	}
+	if msg.HTML != "" {
+		html, err := textEntity("text/html", msg.HTML)
+		if err != nil {
+			return entity{}, err
+		}
+		body = entity{subtype: "alternative", parts: []entity{body, html}}
+	}
+	var inline, attached []entity
+	for _, a := range msg.Attachments {
+		if a.ContentID != "" {
+			inline = append(inline, binaryEntity(a, "inline"))
+		} else {
+			attached = append(attached, binaryEntity(a, "attachment"))
+		}
+	}
+	if len(inline) > 0 {
+		body = entity{subtype: "related", parts: append([]entity{body}, inline...)}
+	}
+	if len(attached) > 0 {
+		body = entity{subtype: "mixed", parts: append([]entity{body}, attached...)}
+	}
+	return body, nil
+}
+
+func textEntity(contentType, text string) (entity, error) {
+	var buf bytes.Buffer
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(text)); err != nil {
+		return entity{}, err
+	}
+	if err := qp.Close(); err != nil {
+		return entity{}, err
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType+"; charset=UTF-8")
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	return entity{header: header, body: buf.Bytes()}, nil
+}
+
+// binaryEntity base64-encodes a in 76-character lines.
+func binaryEntity(a Attachment, disposition string) entity {
+	encoded := base64.StdEncoding.EncodeToString(a.Data)
+	var buf bytes.Buffer
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", a.ContentType)
+	header.Set("Content-Transfer-Encoding", "base64")
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename}))
+	if a.ContentID != "" {
+		header.Set("Content-ID", "<"+a.ContentID+">")
+	}
+	return entity{header: header, body: buf.Bytes()}
+}
+
+// LogSender is the Sender used when SMTP is not configured: it logs that a
+// message was dropped (recipient and subject only; bodies carry tokens).
+type LogSender struct{}
+
+// Send logs msg's envelope and reports success.
+func (LogSender) Send(_ context.Context, msg Message) error {
+	log.Printf("SMTP not configured; dropped email %q to %s", msg.Subject, msg.To)
+	return nil
+}
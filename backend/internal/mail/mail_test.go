@@ -0,0 +1,270 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpStandIn is a minimal local SMTP server: it accepts one session
+// without STARTTLS or AUTH and records the envelope and message.
+type smtpStandIn struct {
+	ln   net.Listener
+	from string
+	rcpt []string
+	data string
+	done chan struct{}
+	// rcptReply answers RCPT TO; empty means "250 OK".
+	rcptReply string
+}
+
+func startSMTPStandIn(t *testing.T) *smtpStandIn {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &smtpStandIn{ln: ln, done: make(chan struct{})}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *smtpStandIn) port() int { return s.ln.Addr().(*net.TCPAddr).Port }
+
+func (s *smtpStandIn) serve() {
+	defer close(s.done)
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	reply := func(line string) bool { return tp.PrintfLine("%s", line) == nil }
+	if !reply("220 localhost ESMTP stand-in") {
+		return
+	}
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			addr, _, _ := strings.Cut(line[len("MAIL FROM:"):], " ")
+			s.from = strings.Trim(addr, "<>")
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.rcpt = append(s.rcpt, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			if s.rcptReply != "" {
+				reply(s.rcptReply)
+			} else {
+				reply("250 OK")
+			}
+		case cmd == "DATA":
+			reply("354 end with .")
+			b, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			s.data = string(b)
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPSenderDeliversLocalizedMessage(t *testing.T) {
+	srv := startSMTPStandIn(t)
+	sender := NewSMTPSender(SMTPConfig{Host: "127.0.0.1", Port: srv.port(), From: "Idento <noreply@idento.test>"})
+
+	msg, err := Render(TemplatePasswordReset, LocaleRU, LinkData{Email: "anna@example.com", Link: "https://app.test/reset-password?token=abc", ValidHours: 1})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	msg.To = "anna@example.com"
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sender.Send(ctx, msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	<-srv.done
+
+	if srv.from != "noreply@idento.test" || len(srv.rcpt) != 1 || srv.rcpt[0] != "anna@example.com" {
+		t.Fatalf("envelope from=%q rcpt=%v", srv.from, srv.rcpt)
+	}
+	tp := textproto.NewReader(bufio.NewReader(strings.NewReader(srv.data)))
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("parse headers: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(header.Get("Subject"))
+	if err != nil || subject != "Сброс пароля Idento" {
+		t.Errorf("subject = %q (%v)", subject, err)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(tp.R))
+	if err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if !strings.Contains(string(body), "https://app.test/reset-password?token=abc") || !strings.Contains(string(body), "Здравствуйте") {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestSMTPSenderReportsRefusedConnection(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	sender := NewSMTPSender(SMTPConfig{Host: "127.0.0.1", Port: port, From: "noreply@idento.test"})
+	if err := sender.Send(context.Background(), Message{To: "a@b.c", Subject: "x", Body: "y"}); err == nil || !strings.Contains(err.Error(), strconv.Itoa(port)) {
+		t.Fatalf("err = %v, want a connect error naming the port", err)
+	}
+}
+
+func TestComposeWithHTMLAndAttachments(t *testing.T) {
+	from, to := &mail.Address{Address: "noreply@idento.test"}, &mail.Address{Address: "anna@example.com"}
+	png := []byte("\x89PNG fake image bytes")
+	data, err := compose(from, to, Message{
+		ReplyTo: "Events <events@acme.test>",
+		Subject: "Ticket",
+		Body:    "Привет",
+		HTML:    `<p>Привет</p><img src="cid:qr">`,
+		Attachments: []Attachment{
+			{Filename: "qr.png", ContentType: "image/png", Data: png, ContentID: "qr"},
+			{Filename: "ticket.png", ContentType: "image/png", Data: png},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Header.Get("Reply-To") != `"Events" <events@acme.test>` {
+		t.Errorf("Reply-To = %q", m.Header.Get("Reply-To"))
+	}
+
+	// mixed{related{alternative{text, html}, inline qr}, attachment}
+	var leaves []string
+	var walk func(r io.Reader, contentType string, depth int)
+	walk = func(r io.Reader, contentType string, depth int) {
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(mediaType, "multipart/") {
+			leaves = append(leaves, mediaType)
+			return
+		}
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Header.Get("Content-ID") == "<qr>" || p.FileName() == "ticket.png" {
+				raw, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+				if !bytes.Equal(raw, png) {
+					t.Errorf("%s: decoded %q", p.FileName(), raw)
+				}
+			}
+			walk(p, p.Header.Get("Content-Type"), depth+1)
+		}
+	}
+	walk(m.Body, m.Header.Get("Content-Type"), 0)
+	if strings.Join(leaves, ",") != "text/plain,text/html,image/png,image/png" {
+		t.Errorf("parts = %v", leaves)
+	}
+}
+
+func TestSMTPSenderReportsRejectedRecipientAsBounce(t *testing.T) {
+	srv := startSMTPStandIn(t)
+	srv.rcptReply = "550 5.1.1 no such user"
+	sender := NewSMTPSender(SMTPConfig{Host: "127.0.0.1", Port: srv.port(), From: "noreply@idento.test"})
+	err := sender.Send(context.Background(), Message{To: "ghost@example.com", Subject: "x", Body: "y"})
+	if !IsBounce(err) {
+		t.Fatalf("err = %v, want a bounce", err)
+	}
+	if err := sender.Send(context.Background(), Message{To: "not an address", Subject: "x", Body: "y"}); !IsBounce(err) {
+		t.Errorf("malformed recipient: err = %v, want a bounce", err)
+	}
+}
+
+func TestTemplatesCoverEveryLocale(t *testing.T) {
+	data := InviteData{LinkData: LinkData{Email: "a@b.c", Link: "https://app.test/x?token=t", ValidHours: 2}, Organization: "Acme", Role: "staff"}
+	for _, name := range []string{TemplatePasswordReset, TemplateEmailVerification, TemplateInvitation, TemplateMemberAdded} {
+		for _, locale := range []string{DefaultLocale, LocaleRU} {
+			if parsed.Lookup(name+"."+locale+".tmpl") == nil {
+				t.Errorf("missing template %s.%s.tmpl", name, locale)
+				continue
+			}
+			msg, err := Render(name, locale, data)
+			if err != nil {
+				t.Errorf("Render(%s, %s): %v", name, locale, err)
+				continue
+			}
+			if msg.Subject == "" || !strings.Contains(msg.Body, data.Link) || strings.HasPrefix(msg.Body, "Subject:") {
+				t.Errorf("Render(%s, %s) = %+v", name, locale, msg)
+			}
+		}
+	}
+	if _, err := Render("nope", DefaultLocale, data); err == nil {
+		t.Error("unknown template rendered")
+	}
+
+	ticket := TicketData{FirstName: "Anna", EventName: "Tech Summit", Code: "A1B2"}
+	for _, locale := range []string{DefaultLocale, LocaleRU} {
+		msg, err := Render(TemplateTicket, locale, ticket)
+		if err != nil {
+			t.Errorf("Render(ticket, %s): %v", locale, err)
+			continue
+		}
+		if !strings.Contains(msg.Subject, ticket.EventName) || !strings.Contains(msg.Body, ticket.Code) {
+			t.Errorf("Render(ticket, %s) = %+v", locale, msg)
+		}
+	}
+}
+
+func TestLocale(t *testing.T) {
+	cases := []struct{ preferred, accept, want string }{
+		{"", "", "en"},
+		{"", "ru-RU,ru;q=0.9,en;q=0.8", "ru"},
+		{"en", "ru-RU", "en"},
+		{"ru", "", "ru"},
+		{"", "de-DE", "en"},
+		{"xx-not-a-tag!", "ru", "ru"},
+	}
+	for _, tc := range cases {
+		if got := Locale(tc.preferred, tc.accept); got != tc.want {
+			t.Errorf("Locale(%q, %q) = %q, want %q", tc.preferred, tc.accept, got, tc.want)
+		}
+	}
+}
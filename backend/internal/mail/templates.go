@@ -0,0 +1,111 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"golang.org/x/text/language"
+)
+
+// Template names.
+const (
+	TemplatePasswordReset     = "password_reset"
+	TemplateEmailVerification = "email_verification"
+	TemplateInvitation        = "invitation"
+	TemplateMemberAdded       = "member_added"
+	TemplateTicket            = "ticket"
+)
+
+// LinkData is the data of the link-carrying templates: the recipient's
+// address, the one-time link and how many hours it stays valid.
+type LinkData struct {
+	Email      string
+	Link       string
+	ValidHours int
+}
+
+// InviteData is the data of the invitation templates: the link data plus
+// the inviting organization and the role the invitee gets there.
+// member_added uses it with ValidHours unset.
+type InviteData struct {
+	LinkData
+	Organization string
+	Role         string
+}
+
+// TicketData is the data of the built-in ticket template, used for the
+// locales an event has not written its own ticket email for.
+type TicketData struct {
+	FirstName string
+	LastName  string
+	EventName string
+	EventDate string // empty when the event has no start date
+	Location  string
+	Code      string
+}
+
+// Supported locales; DefaultLocale is used for anything else.
+const (
+	DefaultLocale = "en"
+	LocaleRU      = "ru"
+)
+
+// templates/<name>.<locale>.tmpl: a "Subject: ..." line, a blank line, then
+// the plain-text body. Every template exists in every locale (enforced by
+// TestTemplatesCoverEveryLocale).
+//
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var (
+	parsed  = template.Must(template.New("").Option("missingkey=error").ParseFS(templateFS, "templates/*.tmpl"))
+	locales = []language.Tag{language.English, language.Russian}
+	matcher = language.NewMatcher(locales)
+)
+
+// Locale picks the supported locale for a request: preferred (an explicit
+// choice such as a "locale" body field) wins, then the Accept-Language
+// header, then DefaultLocale.
+func Locale(preferred, acceptLanguage string) string {
+	for _, raw := range []string{preferred, acceptLanguage} {
+		if raw == "" {
+			continue
+		}
+		tags, _, err := language.ParseAcceptLanguage(raw)
+		if err != nil || len(tags) == 0 {
+			continue
+		}
+		_, i, conf := matcher.Match(tags...)
+		if conf == language.No {
+			continue
+		}
+		base, _ := locales[i].Base()
+		return base.String()
+	}
+	return DefaultLocale
+}
+
+// Render executes template name in locale (falling back to DefaultLocale)
+// with data and returns the message without a recipient.
+func Render(name, locale string, data any) (Message, error) {
+	t := parsed.Lookup(name + "." + locale + ".tmpl")
+	if t == nil {
+		t = parsed.Lookup(name + "." + DefaultLocale + ".tmpl")
+	}
+	if t == nil {
+		return Message{}, fmt.Errorf("unknown mail template %q", name)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return Message{}, fmt.Errorf("render mail template %q: %w", name, err)
+	}
+	head, body, ok := strings.Cut(buf.String(), "\n\n")
+	subject, hasSubject := strings.CutPrefix(head, "Subject: ")
+	if !ok || !hasSubject {
+		return Message{}, fmt.Errorf("mail template %q must start with a Subject line and a blank line", t.Name())
+	}
+	return Message{Subject: strings.TrimSpace(subject), Body: strings.TrimLeft(body, "\n")}, nil
+}
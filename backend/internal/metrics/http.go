@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// unmatchedRoute labels requests no route matched (404s, probes, scanners),
+// so arbitrary paths can't blow up the route label's cardinality.
+const unmatchedRoute = "unmatched"
+
+// Middleware records HTTPRequests, HTTPDuration and HTTPInFlight for every
+// request. It labels by c.Path(), the route template echo matched. Register
+// it before middleware.Recover, which then sits inside it and turns panics
+// into the committed 500s this records.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			HTTPInFlight.Inc()
+			defer HTTPInFlight.Dec()
+			start := time.Now()
+
+			err := next(c)
+
+			route := c.Path()
+			if route == "" || route == "/*" {
+				route = unmatchedRoute
+			}
+			method := c.Request().Method
+			HTTPRequests.WithLabelValues(method, route, strconv.Itoa(responseStatus(c, err))).Inc()
+			HTTPDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
+
+// responseStatus is the status the client will see. A returned error has
+// not been written yet — echo's HTTPErrorHandler renders it after the
+// middleware chain unwinds — so its code wins over the response's default.
+func responseStatus(c echo.Context, err error) int {
+	if err == nil || c.Response().Committed {
+		return c.Response().Status
+	}
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		return he.Code
+	}
+	return http.StatusInternalServerError
+}
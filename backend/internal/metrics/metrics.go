@@ -0,0 +1,120 @@
+// Package metrics holds the backend's Prometheus instrumentation: HTTP
+// request rate, errors and duration per route, database pool saturation,
+// check-in throughput and the live-update plumbing (broker, SSE streams).
+// Everything is registered on Registry, served at /metrics by Handler.
+//
+// Instrumentation sites call the package-level collectors directly; they
+// are safe for concurrent use and cost a few atomic operations, so no
+// call site needs a nil check or a feature flag.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "idento"
+
+// Registry holds every idento_* collector plus the Go runtime and process
+// collectors. A dedicated registry (not prometheus.DefaultRegisterer)
+// keeps what /metrics serves limited to what this package registers.
+var Registry = prometheus.NewRegistry()
+
+// HTTP RED metrics, labelled by route template (e.g.
+// /api/events/:event_id/checkin), never by raw path.
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by method and route template. SSE streams count their whole lifetime.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"method", "route"})
+	HTTPInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "HTTP requests currently being served.",
+	})
+)
+
+// Business counters.
+var (
+	// Checkins counts check-in attempts by source (station, batch, and the
+	// legacy attendee PUT and sync paths) and outcome (checked_in,
+	// already_checked_in, blocked, error). The legacy paths only report
+	// status flips, so they only ever record checked_in.
+	Checkins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "checkins_total",
+		Help:      "Check-in attempts, by source and outcome.",
+	}, []string{"source", "outcome"})
+	CheckinUndos = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "checkin_undos_total",
+		Help:      "Check-in undos served, including idempotent ones.",
+	})
+	// ZoneVerdicts counts zone access decisions: allowed, no_access,
+	// not_registered.
+	ZoneVerdicts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "zone_verdicts_total",
+		Help:      "Zone access decisions, by verdict.",
+	}, []string{"verdict"})
+	// Imports counts attendee imports that wrote rows, by source (bulk for
+	// the panel and async jobs, api for API-key imports).
+	Imports = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "attendee_imports_total",
+		Help:      "Attendee imports run (dry runs excluded), by source.",
+	}, []string{"source"})
+	// ImportRows counts imported rows by source and result (created,
+	// updated, unchanged, skipped, failed).
+	ImportRows = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "attendee_import_rows_total",
+		Help:      "Attendee rows processed by imports, by source and result.",
+	}, []string{"source", "result"})
+)
+
+// Live-update plumbing.
+var (
+	BrokerPublishes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "broker_publishes_total",
+		Help:      "Broker publishes, by result (ok, error).",
+	}, []string{"result"})
+	BrokerReconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "broker_reconnects_total",
+		Help:      "Times the broker's LISTEN connection was lost and re-established.",
+	})
+	// SSEStreams counts open server-sent event streams by stream (monitor,
+	// job, station).
+	SSEStreams = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sse_streams",
+		Help:      "Server-sent event streams currently open, by stream.",
+	}, []string{"stream"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests, HTTPDuration, HTTPInFlight,
+		Checkins, CheckinUndos, ZoneVerdicts, Imports, ImportRows,
+		BrokerPublishes, BrokerReconnects, SSEStreams,
+	)
+}
+
+// Handler serves Registry in the Prometheus text exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
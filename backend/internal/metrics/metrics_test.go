@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareLabelsByRouteTemplate(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware())
+	e.GET("/api/events/:event_id", func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })
+	e.GET("/api/broken", func(c echo.Context) error { return errors.New("boom") })
+	e.GET("/api/denied", func(c echo.Context) error { return echo.NewHTTPError(http.StatusForbidden) })
+
+	serve := func(path string) {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	count := func(route, status string) float64 {
+		return testutil.ToFloat64(HTTPRequests.WithLabelValues(http.MethodGet, route, status))
+	}
+	before := map[string]float64{
+		"event":     count("/api/events/:event_id", "204"),
+		"broken":    count("/api/broken", "500"),
+		"denied":    count("/api/denied", "403"),
+		"unmatched": count(unmatchedRoute, "404"),
+	}
+
+	serve("/api/events/11111111-1111-1111-1111-111111111111")
+	serve("/api/events/22222222-2222-2222-2222-222222222222")
+	serve("/api/broken")
+	serve("/api/denied")
+	serve("/wp-login.php")
+
+	for key, want := range map[string]struct {
+		route, status string
+		delta         float64
+	}{
+		"event":     {"/api/events/:event_id", "204", 2},
+		"broken":    {"/api/broken", "500", 1},
+		"denied":    {"/api/denied", "403", 1},
+		"unmatched": {unmatchedRoute, "404", 1},
+	} {
+		if got := count(want.route, want.status) - before[key]; got != want.delta {
+			t.Errorf("%s %s: counted %v, want %v", want.route, want.status, got, want.delta)
+		}
+	}
+	if got := testutil.ToFloat64(HTTPInFlight); got != 0 {
+		t.Errorf("in flight = %v after all requests finished", got)
+	}
+}
+
+func TestMiddlewareCountsRecoveredPanics(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware())
+	e.Use(middleware.Recover())
+	e.GET("/api/panics", func(c echo.Context) error { panic("boom") })
+
+	before := testutil.ToFloat64(HTTPRequests.WithLabelValues(http.MethodGet, "/api/panics", "500"))
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/panics", nil))
+	if got := testutil.ToFloat64(HTTPRequests.WithLabelValues(http.MethodGet, "/api/panics", "500")) - before; got != 1 {
+		t.Errorf("counted %v recovered panics, want 1", got)
+	}
+}
+
+type fakePool struct{ stat *pgxpool.Stat }
+
+func (p fakePool) PoolStat() *pgxpool.Stat { return p.stat }
+
+func TestPoolCollectorSkipsPoolWithoutStats(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(&poolCollector{name: "store", pool: fakePool{}}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if n, err := testutil.GatherAndCount(reg); err != nil || n != 0 {
+		t.Errorf("gathered %d metrics, %v; want none for a pool without stats", n, err)
+	}
+}
+
+func TestPoolCollectorReportsPoolStats(t *testing.T) {
+	pool, err := pgxpool.New(t.Context(), "postgres://idento@127.0.0.1:1/idento?pool_max_conns=7")
+	if err != nil {
+		t.Fatalf("pgxpool.New: %v", err)
+	}
+	defer pool.Close()
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(&poolCollector{name: "store", pool: fakePool{stat: pool.Stat()}}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	n, err := testutil.GatherAndCount(reg)
+	if err != nil || n != 8 {
+		t.Fatalf("gathered %d metrics, %v; want 8", n, err)
+	}
+	mfs, _ := reg.Gather()
+	for _, mf := range mfs {
+		if mf.GetName() == "idento_db_pool_max_conns" && mf.GetMetric()[0].GetGauge().GetValue() != 7 {
+			t.Errorf("max_conns = %v, want 7", mf.GetMetric()[0].GetGauge().GetValue())
+		}
+	}
+}
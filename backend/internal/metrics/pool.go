@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolStatter is anything that can report pgxpool statistics: the store's
+// main pool and the broker's notify pool.
+type PoolStatter interface {
+	PoolStat() *pgxpool.Stat
+}
+
+var (
+	poolLabels       = []string{"pool"}
+	poolAcquired     = poolDesc("acquired_conns", "Connections currently checked out of the pool.")
+	poolIdle         = poolDesc("idle_conns", "Idle connections in the pool.")
+	poolTotal        = poolDesc("total_conns", "Connections in the pool, including ones being established.")
+	poolMax          = poolDesc("max_conns", "Maximum size of the pool.")
+	poolAcquires     = poolDesc("acquires_total", "Successful connection acquires.")
+	poolAcquireWait  = poolDesc("acquire_duration_seconds_total", "Total time spent waiting to acquire a connection.")
+	poolEmptyAcquire = poolDesc("empty_acquires_total", "Acquires that had to wait because no idle connection was available.")
+	poolCanceled     = poolDesc("canceled_acquires_total", "Acquires canceled by their context while waiting.")
+)
+
+func poolDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, poolLabels, nil)
+}
+
+// poolCollector reads pgxpool.Stat at scrape time; the pool already keeps
+// these counters, so nothing is tracked between scrapes.
+type poolCollector struct {
+	name string
+	pool PoolStatter
+}
+
+// RegisterPool exposes p's statistics under idento_db_pool_*{pool=name}.
+func RegisterPool(name string, p PoolStatter) error {
+	return Registry.Register(&poolCollector{name: name, pool: p})
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{poolAcquired, poolIdle, poolTotal, poolMax, poolAcquires, poolAcquireWait, poolEmptyAcquire, poolCanceled} {
+		ch <- d
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.PoolStat()
+	if s == nil {
+		return
+	}
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, c.name)
+	}
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v, c.name)
+	}
+	gauge(poolAcquired, float64(s.AcquiredConns()))
+	gauge(poolIdle, float64(s.IdleConns()))
+	gauge(poolTotal, float64(s.TotalConns()))
+	gauge(poolMax, float64(s.MaxConns()))
+	counter(poolAcquires, float64(s.AcquireCount()))
+	counter(poolAcquireWait, s.AcquireDuration().Seconds())
+	counter(poolEmptyAcquire, float64(s.EmptyAcquireCount()))
+	counter(poolCanceled, float64(s.CanceledAcquireCount()))
+}
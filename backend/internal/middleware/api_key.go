@@ -17,10 +17,19 @@ import (
 
 type contextKey string
 
-const EventIDKey contextKey = "event_id"
+const (
+	EventIDKey  contextKey = "event_id"
+	TenantIDKey contextKey = "tenant_id" // the API key's event's tenant
+)
 
-// APIKeyAuth middleware for public endpoints
-func APIKeyAuth(s store.Store) echo.MiddlewareFunc {
+// APIKeyAuth middleware for public endpoints. Besides authenticating the
+// key it enforces the key's own request budget (rate_limit_per_minute, with
+// X-RateLimit-* headers on every response and a 429 once spent) and meters
+// each request into usage_logs as resource_type 'api_call' — action
+// 'request' for served calls. Rejected calls are tallied in throttled,
+// which writes them in batches; a nil throttled leaves them unmetered.
+func APIKeyAuth(s store.Store, throttled *APIKeyThrottleLog) echo.MiddlewareFunc {
+	limiters := newAPIKeyLimiters()
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			apiKey := c.Request().Header.Get("X-API-Key")
@@ -64,6 +73,21 @@ func APIKeyAuth(s store.Store) echo.MiddlewareFunc {
 				})
 			}
 
+			perMinute := key.RateLimitPerMinute
+			if perMinute <= 0 {
+				perMinute = models.DefaultAPIKeyRateLimitPerMinute
+			}
+			decision := limiters.allow(key.ID, perMinute)
+			setRateLimitHeaders(c, decision)
+			if !decision.allowed {
+				if throttled != nil {
+					throttled.add(key)
+				}
+				return c.JSON(http.StatusTooManyRequests, map[string]string{
+					"error": "API key rate limit exceeded",
+				})
+			}
+
 			// Update last used timestamp (async, don't block request)
 			go func() {
 				if err := s.UpdateAPIKeyLastUsed(context.Background(), key.ID); err != nil {
@@ -73,12 +97,48 @@ func APIKeyAuth(s store.Store) echo.MiddlewareFunc {
 
 			// Store event_id in context for later use
 			c.Set(string(EventIDKey), key.EventID)
+			c.Set(string(TenantIDKey), key.TenantID)
 
-			return next(c)
+			err = next(c)
+			status := c.Response().Status
+			if err != nil {
+				if he, ok := err.(*echo.HTTPError); ok {
+					status = he.Code
+				} else {
+					status = http.StatusInternalServerError
+				}
+			}
+			logAPIKeyUsage(s, key, "request", c.Request().Method, c.Path(), status)
+			return err
 		}
 	}
 }
 
+// logAPIKeyUsage writes one 'api_call' usage row for key, asynchronously
+// and best-effort like UpdateAPIKeyLastUsed: metering must never fail or
+// slow down the request it describes.
+func logAPIKeyUsage(s store.Store, key *models.APIKey, action, method, path string, status int) {
+	keyID := key.ID
+	entry := &models.UsageLog{
+		TenantID:     key.TenantID,
+		ResourceType: "api_call",
+		ResourceID:   &keyID,
+		Action:       action,
+		Quantity:     1,
+		Metadata: map[string]interface{}{
+			"method":   method,
+			"path":     path,
+			"status":   status,
+			"event_id": key.EventID.String(),
+		},
+	}
+	go func() {
+		if err := s.LogUsage(context.Background(), entry); err != nil {
+			log.Printf("Failed to log API key usage: %v", err)
+		}
+	}()
+}
+
 // GetEventIDFromContext extracts event_id from context
 func GetEventIDFromContext(c echo.Context) (uuid.UUID, error) {
 	eventID := c.Get(string(EventIDKey))
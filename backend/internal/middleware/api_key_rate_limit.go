@@ -0,0 +1,215 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/time/rate"
+)
+
+// apiKeyBucketIdleTTL is how long an unused key's bucket is kept before the
+// sweep drops it. A dropped bucket simply starts full again on the key's
+// next request, so this only bounds memory, never correctness.
+const apiKeyBucketIdleTTL = 10 * time.Minute
+
+// apiKeyLimiters holds one token bucket per API key, sized from that key's
+// own rate_limit_per_minute: the bucket refills at perMinute/60 tokens per
+// second and holds at most perMinute tokens, so a key can burst its whole
+// minute's budget and then settles to the steady rate. Echo's
+// RateLimiterMemoryStore can't express this — it applies a single rate to
+// every identifier — hence the small dedicated store. In-memory and
+// per-process, like the auth limiter in handler.RegisterRoutes.
+type apiKeyLimiters struct {
+	mu        sync.Mutex
+	buckets   map[uuid.UUID]*apiKeyBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type apiKeyBucket struct {
+	limiter   *rate.Limiter
+	perMinute int
+	lastSeen  time.Time
+}
+
+// rateLimitDecision is the outcome of one allow call, carrying everything
+// needed for the X-RateLimit-* response headers.
+type rateLimitDecision struct {
+	allowed    bool
+	limit      int
+	remaining  int
+	reset      time.Duration // until the bucket is full again
+	retryAfter time.Duration // until the next token; zero when allowed
+}
+
+func newAPIKeyLimiters() *apiKeyLimiters {
+	return &apiKeyLimiters{buckets: map[uuid.UUID]*apiKeyBucket{}, now: time.Now}
+}
+
+// allow spends one token from keyID's bucket. A key whose configured budget
+// changed since its bucket was created gets a fresh bucket at the new size.
+func (l *apiKeyLimiters) allow(keyID uuid.UUID, perMinute int) rateLimitDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) > apiKeyBucketIdleTTL {
+		for id, b := range l.buckets {
+			if now.Sub(b.lastSeen) > apiKeyBucketIdleTTL {
+				delete(l.buckets, id)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[keyID]
+	if !ok || b.perMinute != perMinute {
+		b = &apiKeyBucket{
+			limiter:   rate.NewLimiter(rate.Limit(float64(perMinute)/60.0), perMinute),
+			perMinute: perMinute,
+		}
+		l.buckets[keyID] = b
+	}
+	b.lastSeen = now
+
+	allowed := b.limiter.AllowN(now, 1)
+	tokens := b.limiter.TokensAt(now)
+	perSecond := float64(perMinute) / 60.0
+
+	d := rateLimitDecision{
+		allowed:   allowed,
+		limit:     perMinute,
+		remaining: int(math.Max(0, math.Floor(tokens))),
+		reset:     secondsToDuration((float64(perMinute) - tokens) / perSecond),
+	}
+	if !allowed {
+		d.retryAfter = secondsToDuration((1 - tokens) / perSecond)
+	}
+	return d
+}
+
+func secondsToDuration(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// ceilSeconds renders a duration as whole seconds, rounding up so a client
+// that waits exactly that long never arrives a fraction too early.
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// setRateLimitHeaders writes the conventional X-RateLimit-* headers (and
+// Retry-After on a rejection). Reset is relative seconds, not an epoch.
+func setRateLimitHeaders(c echo.Context, d rateLimitDecision) {
+	h := c.Response().Header()
+	h.Set("X-RateLimit-Limit", strconv.Itoa(d.limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(d.remaining))
+	h.Set("X-RateLimit-Reset", ceilSeconds(d.reset))
+	if !d.allowed {
+		h.Set(echo.HeaderRetryAfter, ceilSeconds(d.retryAfter))
+	}
+}
+
+// APIKeyThrottleLog tallies requests APIKeyAuth rejected over their key's
+// budget and writes them to usage_logs as one 'throttled' row per key and
+// flush, so a key hammering past its budget costs one insert per flush
+// rather than one per rejected request.
+type APIKeyThrottleLog struct {
+	s       store.Store
+	mu      sync.Mutex
+	pending map[uuid.UUID]*apiKeyThrottleCount
+	now     func() time.Time
+}
+
+type apiKeyThrottleCount struct {
+	key   *models.APIKey
+	count int
+	since time.Time
+}
+
+// NewAPIKeyThrottleLog returns an empty tally that flushes to s.
+func NewAPIKeyThrottleLog(s store.Store) *APIKeyThrottleLog {
+	return &APIKeyThrottleLog{s: s, pending: map[uuid.UUID]*apiKeyThrottleCount{}, now: time.Now}
+}
+
+// add counts one rejected request for key.
+func (t *APIKeyThrottleLog) add(key *models.APIKey) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.pending[key.ID]
+	if !ok {
+		p = &apiKeyThrottleCount{key: key, since: t.now()}
+		t.pending[key.ID] = p
+	}
+	p.count++
+}
+
+// take returns one 'throttled' usage row per key with tallied rejections,
+// quantity being their count, and starts a new tally.
+func (t *APIKeyThrottleLog) take() []*models.UsageLog {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pending) == 0 {
+		return nil
+	}
+	rows := make([]*models.UsageLog, 0, len(t.pending))
+	for _, p := range t.pending {
+		keyID := p.key.ID
+		rows = append(rows, &models.UsageLog{
+			TenantID:     p.key.TenantID,
+			ResourceType: "api_call",
+			ResourceID:   &keyID,
+			Action:       "throttled",
+			Quantity:     p.count,
+			Metadata: map[string]interface{}{
+				"status":   http.StatusTooManyRequests,
+				"event_id": p.key.EventID.String(),
+				"since":    p.since.UTC().Format(time.RFC3339),
+			},
+		})
+	}
+	t.pending = map[uuid.UUID]*apiKeyThrottleCount{}
+	return rows
+}
+
+// Start flushes the tally every interval until ctx is done. What is
+// tallied after the last tick is left for a final Flush at shutdown.
+func (t *APIKeyThrottleLog) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			// Not ctx: rows taken just before shutdown are still written.
+			flushCtx, cancel := context.WithTimeout(context.Background(), interval)
+			t.Flush(flushCtx)
+			cancel()
+		}
+	}()
+}
+
+// Flush writes the current tally to usage_logs and starts a new one.
+func (t *APIKeyThrottleLog) Flush(ctx context.Context) {
+	for _, row := range t.take() {
+		if err := t.s.LogUsage(ctx, row); err != nil {
+			log.Printf("Failed to log API key throttling: %v", err)
+		}
+	}
+}
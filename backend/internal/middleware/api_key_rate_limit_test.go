@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAPIKeyLimitersBurstThenRefill(t *testing.T) {
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	l := newAPIKeyLimiters()
+	l.now = func() time.Time { return now }
+	key := uuid.New()
+
+	for i := 0; i < 3; i++ {
+		d := l.allow(key, 3)
+		if !d.allowed {
+			t.Fatalf("request %d denied within the burst", i+1)
+		}
+		if d.remaining != 2-i {
+			t.Fatalf("request %d remaining = %d, want %d", i+1, d.remaining, 2-i)
+		}
+	}
+	d := l.allow(key, 3)
+	if d.allowed {
+		t.Fatal("4th request allowed past a 3/min budget")
+	}
+	// 3/min refills one token every 20s.
+	if d.retryAfter <= 0 || d.retryAfter > 20*time.Second {
+		t.Fatalf("retryAfter = %v, want (0, 20s]", d.retryAfter)
+	}
+
+	now = now.Add(20 * time.Second)
+	if d := l.allow(key, 3); !d.allowed {
+		t.Fatal("request after one refill interval denied")
+	}
+}
+
+func TestAPIKeyLimitersBudgetChangeResetsBucket(t *testing.T) {
+	l := newAPIKeyLimiters()
+	key := uuid.New()
+	l.allow(key, 1)
+	if d := l.allow(key, 1); d.allowed {
+		t.Fatal("second request allowed on a 1/min budget")
+	}
+	if d := l.allow(key, 10); !d.allowed || d.limit != 10 {
+		t.Fatalf("raised budget not applied: allowed=%v limit=%d", d.allowed, d.limit)
+	}
+}
+
+type apiKeyFakeStore struct {
+	store.Store
+	key *models.APIKey
+
+	mu    sync.Mutex
+	usage []*models.UsageLog
+	done  chan struct{}
+}
+
+func (f *apiKeyFakeStore) GetActiveAPIKeys(context.Context) ([]*models.APIKey, error) {
+	return []*models.APIKey{f.key}, nil
+}
+
+func (f *apiKeyFakeStore) UpdateAPIKeyLastUsed(context.Context, uuid.UUID) error { return nil }
+
+func (f *apiKeyFakeStore) LogUsage(_ context.Context, l *models.UsageLog) error {
+	f.mu.Lock()
+	f.usage = append(f.usage, l)
+	f.mu.Unlock()
+	f.done <- struct{}{}
+	return nil
+}
+
+func TestAPIKeyAuthRateLimitsAndMetersPerKey(t *testing.T) {
+	const plain = "test-api-key"
+	hash, err := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashStr := string(hash)
+	fs := &apiKeyFakeStore{
+		key: &models.APIKey{
+			ID:                 uuid.New(),
+			EventID:            uuid.New(),
+			TenantID:           uuid.New(),
+			KeyHashBcrypt:      &hashStr,
+			RateLimitPerMinute: 2,
+		},
+		done: make(chan struct{}, 8),
+	}
+
+	e := echo.New()
+	throttles := NewAPIKeyThrottleLog(fs)
+	mw := APIKeyAuth(fs, throttles)
+	h := mw(func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	call := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/public/import", nil)
+		req.Header.Set("X-API-Key", plain)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/api/public/import")
+		if err := h(c); err != nil {
+			t.Fatalf("handler error: %v", err)
+		}
+		if rec.Code == http.StatusOK {
+			<-fs.done
+		}
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		rec := call()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200", i+1, rec.Code)
+		}
+		if got := rec.Header().Get("X-RateLimit-Limit"); got != "2" {
+			t.Fatalf("X-RateLimit-Limit = %q, want 2", got)
+		}
+	}
+	rec := call()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("3rd request status = %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("X-RateLimit-Remaining") != "0" || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("429 headers = %v, want Remaining=0 and a Retry-After", rec.Header())
+	}
+	throttles.Flush(context.Background())
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	var requests, throttled int
+	for _, u := range fs.usage {
+		if u.ResourceType != "api_call" || u.TenantID != fs.key.TenantID || u.ResourceID == nil || *u.ResourceID != fs.key.ID {
+			t.Fatalf("usage row not attributed to the key/tenant: %+v", u)
+		}
+		switch u.Action {
+		case "request":
+			requests++
+		case "throttled":
+			throttled++
+		}
+	}
+	if requests != 2 || throttled != 1 {
+		t.Fatalf("usage rows = %d request / %d throttled, want 2 / 1", requests, throttled)
+	}
+}
+
+// Rejections are tallied per key between flushes: a flush writes one row
+// carrying their count and starts over.
+func TestAPIKeyThrottleLogFlushesTallies(t *testing.T) {
+	fs := &apiKeyFakeStore{done: make(chan struct{}, 8)}
+	th := NewAPIKeyThrottleLog(fs)
+	key := &models.APIKey{ID: uuid.New(), EventID: uuid.New(), TenantID: uuid.New()}
+
+	for i := 0; i < 500; i++ {
+		th.add(key)
+	}
+	th.Flush(context.Background())
+	if len(fs.usage) != 1 {
+		t.Fatalf("flush wrote %d rows, want 1", len(fs.usage))
+	}
+	r := fs.usage[0]
+	if r.Action != "throttled" || r.ResourceType != "api_call" || r.Quantity != 500 ||
+		r.TenantID != key.TenantID || r.ResourceID == nil || *r.ResourceID != key.ID {
+		t.Fatalf("throttled row = %+v, want 500 rejections of the key", r)
+	}
+	th.Flush(context.Background())
+	if len(fs.usage) != 1 {
+		t.Fatalf("tally not reset: %d rows", len(fs.usage))
+	}
+}
+
+// Start flushes on its own, without waiting for another request, and stops
+// with its context.
+func TestAPIKeyThrottleLogStartFlushesOnATicker(t *testing.T) {
+	fs := &apiKeyFakeStore{done: make(chan struct{}, 8)}
+	th := NewAPIKeyThrottleLog(fs)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	th.Start(ctx, 10*time.Millisecond)
+
+	th.add(&models.APIKey{ID: uuid.New(), EventID: uuid.New(), TenantID: uuid.New()})
+	select {
+	case <-fs.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("tally was not flushed by the ticker")
+	}
+}
@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"idento/backend/internal/config"
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// IdempotencyKeyHeader is the request header clients set to make a POST
+// safe to retry; IdempotentReplayedHeader marks a replayed response.
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// DryRunParam is the query parameter that turns an import route into a
+// preview that writes nothing.
+const DryRunParam = "dry_run"
+
+// IsDryRun reports whether the request asks for a dry run
+// (?dry_run=true, or any other strconv.ParseBool truthy value).
+func IsDryRun(c echo.Context) bool {
+	v, err := strconv.ParseBool(c.QueryParam(DryRunParam))
+	return err == nil && v
+}
+
+// maxIdempotencyKeyLen bounds the header value; clients typically send a
+// UUID, so this is generous.
+const maxIdempotencyKeyLen = 255
+
+// Idempotency makes a create/import route safe to retry after a network
+// timeout. A request carrying an Idempotency-Key claims that key for its
+// tenant; when it finishes, its status and body are stored, and any retry
+// with the same key within the retention window (config
+// IDEMPOTENCY_RETENTION_HOURS) gets that stored response replayed instead
+// of running the handler again. A retry while the first request is still
+// running gets 409; reusing a key for a different method/path/body gets
+// 422. Server-side failures (5xx or a handler error) release the claim so
+// the retry can genuinely run again. Requests without the header pass
+// through untouched, and so do dry runs (IsDryRun): they write nothing, so
+// a client may send the key it will reuse for the real import.
+//
+// Mount it outside the limit middleware (earlier in the route's list): a
+// replay of a successful create must not be re-judged against limits the
+// original request itself used up.
+func Idempotency(s store.Store) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(IdempotencyKeyHeader)
+			if key == "" || IsDryRun(c) {
+				return next(c)
+			}
+			if len(key) > maxIdempotencyKeyLen {
+				return c.JSON(http.StatusBadRequest, map[string]string{
+					"error": "Idempotency-Key must be at most 255 characters",
+				})
+			}
+			tenantID, ok := idempotencyTenant(c)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+			}
+
+			req := c.Request()
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Failed to read request body"})
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			sum := sha256.New()
+			sum.Write([]byte(req.Method + "\n" + req.URL.Path + "\n"))
+			sum.Write(body)
+			rec := &models.IdempotencyRecord{
+				TenantID:    tenantID,
+				Key:         key,
+				Method:      req.Method,
+				Path:        req.URL.Path,
+				RequestHash: hex.EncodeToString(sum.Sum(nil)),
+			}
+
+			existing, err := s.ClaimIdempotencyKey(req.Context(), rec, config.IdempotencyRetention(), config.IdempotencyLockTimeout)
+			if errors.Is(err, store.ErrIdempotencyKeyVanished) {
+				return idempotencyInProgress(c)
+			}
+			if err != nil {
+				log.Printf("Idempotency claim failed: %v", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to process Idempotency-Key"})
+			}
+			if existing != nil {
+				if existing.RequestHash != rec.RequestHash {
+					return c.JSON(http.StatusUnprocessableEntity, map[string]string{
+						"code":  "idempotency_key_reused",
+						"error": "Idempotency-Key was already used for a different request",
+					})
+				}
+				if existing.StatusCode == nil {
+					return idempotencyInProgress(c)
+				}
+				c.Response().Header().Set(IdempotentReplayedHeader, "true")
+				return c.Blob(*existing.StatusCode, existing.ContentType, existing.ResponseBody)
+			}
+
+			capture := &responseCapture{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = capture
+			handlerErr := next(c)
+
+			// The request context may already be cancelled (client gone);
+			// the claim must still be settled either way.
+			settleCtx := context.WithoutCancel(req.Context())
+			status := c.Response().Status
+			if handlerErr != nil || status >= http.StatusInternalServerError || !c.Response().Committed {
+				if err := s.ReleaseIdempotencyKey(settleCtx, tenantID, key); err != nil {
+					log.Printf("Idempotency release failed: %v", err)
+				}
+				return handlerErr
+			}
+			contentType := c.Response().Header().Get(echo.HeaderContentType)
+			if err := s.CompleteIdempotencyKey(settleCtx, tenantID, key, status, contentType, capture.body.Bytes()); err != nil {
+				log.Printf("Idempotency complete failed: %v", err)
+			}
+			return nil
+		}
+	}
+}
+
+func idempotencyInProgress(c echo.Context) error {
+	return c.JSON(http.StatusConflict, map[string]string{
+		"code":  "idempotency_key_in_progress",
+		"error": "A request with this Idempotency-Key is still being processed",
+	})
+}
+
+// idempotencyTenant scopes keys to the caller's tenant: from the JWT claims
+// on /api routes, or from the API key on the public surface.
+func idempotencyTenant(c echo.Context) (uuid.UUID, bool) {
+	if claims, ok := c.Get("user").(*models.JWTCustomClaims); ok && claims != nil {
+		id, err := uuid.Parse(claims.TenantID)
+		return id, err == nil
+	}
+	if id, ok := c.Get(string(TenantIDKey)).(uuid.UUID); ok {
+		return id, true
+	}
+	return uuid.Nil, false
+}
+
+// responseCapture tees everything the handler writes so it can be stored
+// for replay, while still streaming it to the client.
+type responseCapture struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseCapture) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// idempotencyFakeStore keeps claimed keys in memory with the same
+// claim/complete/release semantics as the PG implementation.
+type idempotencyFakeStore struct {
+	store.Store
+	mu   sync.Mutex
+	rows map[string]*models.IdempotencyRecord
+}
+
+func newIdempotencyFakeStore() *idempotencyFakeStore {
+	return &idempotencyFakeStore{rows: map[string]*models.IdempotencyRecord{}}
+}
+
+func (f *idempotencyFakeStore) ClaimIdempotencyKey(_ context.Context, rec *models.IdempotencyRecord, _, _ time.Duration) (*models.IdempotencyRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := rec.TenantID.String() + "/" + rec.Key
+	if existing, ok := f.rows[id]; ok {
+		cp := *existing
+		return &cp, nil
+	}
+	cp := *rec
+	f.rows[id] = &cp
+	return nil, nil
+}
+
+func (f *idempotencyFakeStore) CompleteIdempotencyKey(_ context.Context, tenantID uuid.UUID, key string, status int, contentType string, body []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r, ok := f.rows[tenantID.String()+"/"+key]; ok && r.StatusCode == nil {
+		r.StatusCode = &status
+		r.ContentType = contentType
+		r.ResponseBody = append([]byte(nil), body...)
+	}
+	return nil
+}
+
+func (f *idempotencyFakeStore) ReleaseIdempotencyKey(_ context.Context, tenantID uuid.UUID, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := tenantID.String() + "/" + key
+	if r, ok := f.rows[id]; ok && r.StatusCode == nil {
+		delete(f.rows, id)
+	}
+	return nil
+}
+
+type idempotencyHarness struct {
+	t        *testing.T
+	e        *echo.Echo
+	tenantID uuid.UUID
+	calls    int
+	status   int
+	handler  echo.HandlerFunc
+}
+
+func newIdempotencyHarness(t *testing.T, fs store.Store) *idempotencyHarness {
+	h := &idempotencyHarness{t: t, e: echo.New(), tenantID: uuid.New(), status: http.StatusCreated}
+	h.handler = Idempotency(fs)(func(c echo.Context) error {
+		h.calls++
+		return c.JSON(h.status, map[string]int{"call": h.calls})
+	})
+	return h
+}
+
+func (h *idempotencyHarness) do(key, body string) *httptest.ResponseRecorder {
+	h.t.Helper()
+	return h.doURL("/api/events", key, body)
+}
+
+func (h *idempotencyHarness) doURL(target, key, body string) *httptest.ResponseRecorder {
+	h.t.Helper()
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	c := h.e.NewContext(req, rec)
+	c.Set("user", &models.JWTCustomClaims{TenantID: h.tenantID.String()})
+	if err := h.handler(c); err != nil {
+		h.t.Fatalf("handler error: %v", err)
+	}
+	return rec
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	h := newIdempotencyHarness(t, newIdempotencyFakeStore())
+
+	first := h.do("abc", `{"name":"Expo"}`)
+	if first.Code != http.StatusCreated || first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Fatalf("first: status=%d replayed=%q, want 201 and not replayed", first.Code, first.Header().Get(IdempotentReplayedHeader))
+	}
+	second := h.do("abc", `{"name":"Expo"}`)
+	if second.Code != http.StatusCreated || second.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatalf("retry: status=%d replayed=%q, want replayed 201", second.Code, second.Header().Get(IdempotentReplayedHeader))
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Fatalf("retry body = %q, want %q", second.Body.String(), first.Body.String())
+	}
+	if h.calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", h.calls)
+	}
+}
+
+func TestIdempotencyRejectsKeyReuseWithDifferentBody(t *testing.T) {
+	h := newIdempotencyHarness(t, newIdempotencyFakeStore())
+	h.do("abc", `{"name":"Expo"}`)
+	rec := h.do("abc", `{"name":"Other"}`)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "idempotency_key_reused") {
+		t.Fatalf("status=%d body=%s, want 422 idempotency_key_reused", rec.Code, rec.Body.String())
+	}
+}
+
+func TestIdempotencyConflictWhileInFlight(t *testing.T) {
+	fs := newIdempotencyFakeStore()
+	h := newIdempotencyHarness(t, fs)
+	// Simulate a first attempt that claimed the key and is still running.
+	h.handler = Idempotency(fs)(func(c echo.Context) error {
+		h.calls++
+		if h.calls == 1 {
+			inner := h.do("abc", `{}`)
+			if inner.Code != http.StatusConflict || !strings.Contains(inner.Body.String(), "idempotency_key_in_progress") {
+				t.Errorf("concurrent retry: status=%d body=%s, want 409 idempotency_key_in_progress", inner.Code, inner.Body.String())
+			}
+		}
+		return c.NoContent(http.StatusCreated)
+	})
+	h.do("abc", `{}`)
+	if h.calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", h.calls)
+	}
+}
+
+func TestIdempotencyReleasesKeyOnServerError(t *testing.T) {
+	h := newIdempotencyHarness(t, newIdempotencyFakeStore())
+	h.status = http.StatusInternalServerError
+	if rec := h.do("abc", `{}`); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("first: status=%d, want 500", rec.Code)
+	}
+	h.status = http.StatusCreated
+	rec := h.do("abc", `{}`)
+	if rec.Code != http.StatusCreated || rec.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Fatalf("retry after 5xx: status=%d replayed=%q, want a fresh 201", rec.Code, rec.Header().Get(IdempotentReplayedHeader))
+	}
+	if h.calls != 2 {
+		t.Fatalf("handler ran %d times, want 2", h.calls)
+	}
+}
+
+func TestIdempotencyWithoutHeaderPassesThrough(t *testing.T) {
+	h := newIdempotencyHarness(t, nil)
+	h.do("", `{}`)
+	h.do("", `{}`)
+	if h.calls != 2 {
+		t.Fatalf("handler ran %d times, want 2", h.calls)
+	}
+}
+
+// A dry run neither claims nor consumes the key: the real import sent with
+// the same key afterwards runs, rather than replaying the preview.
+func TestIdempotencyDryRunBypassesKey(t *testing.T) {
+	h := newIdempotencyHarness(t, newIdempotencyFakeStore())
+	h.doURL("/api/events/x/attendees/bulk?dry_run=true", "abc", `{}`)
+	rec := h.doURL("/api/events/x/attendees/bulk", "abc", `{}`)
+	if rec.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Fatal("real request replayed the dry run's response")
+	}
+	if h.calls != 2 {
+		t.Fatalf("handler ran %d times, want 2", h.calls)
+	}
+}
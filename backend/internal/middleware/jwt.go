@@ -4,15 +4,39 @@ import (
 	"errors"
 	"idento/backend/internal/config"
 	"idento/backend/internal/models"
+	"idento/backend/internal/store"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
 // JWT returns Echo middleware that validates Bearer JWT and sets claims in context under "user".
-func JWT() echo.MiddlewareFunc {
+// A token whose user (or station) token_version has moved on since it was
+// issued is rejected as revoked, as is one whose session (sid, its refresh
+// token family) has been logged out. Versions and session state are cached
+// per user, station and session for config.TokenVersionCacheTTL, so a
+// revocation reaches every replica within that window without a DB hit per
+// request.
+func JWT(s store.Store) echo.MiddlewareFunc {
+	return jwtWithTTL(s, config.TokenVersionCacheTTL)
+}
+
+type versionEntry struct {
+	user, station int
+	sessionEnded  bool
+	expires       time.Time
+}
+
+func jwtWithTTL(s store.Store, ttl time.Duration) echo.MiddlewareFunc {
+	var (
+		mu    sync.RWMutex
+		cache = map[string]versionEntry{}
+	)
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			authHeader := c.Request().Header.Get("Authorization")
@@ -34,6 +58,10 @@ func JWT() echo.MiddlewareFunc {
 				return []byte(secret), nil
 			})
 
+			if errors.Is(err, jwt.ErrTokenExpired) {
+				// Machine-readable so clients know to use their refresh token.
+				return c.JSON(http.StatusUnauthorized, map[string]string{"code": "token_expired", "error": "Token expired"})
+			}
 			if err != nil || !token.Valid {
 				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid token"})
 			}
@@ -43,8 +71,69 @@ func JWT() echo.MiddlewareFunc {
 				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid claims"})
 			}
 
+			userID, err := uuid.Parse(claims.UserID)
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid claims"})
+			}
+			var stationID *uuid.UUID
+			if claims.StationID != "" {
+				id, err := uuid.Parse(claims.StationID)
+				if err != nil {
+					return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid claims"})
+				}
+				stationID = &id
+			}
+			var sessionID *uuid.UUID
+			if claims.SessionID != "" {
+				id, err := uuid.Parse(claims.SessionID)
+				if err != nil {
+					return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid claims"})
+				}
+				sessionID = &id
+			}
+
+			key := claims.UserID + "/" + claims.StationID + "/" + claims.SessionID
+			mu.RLock()
+			entry, hit := cache[key]
+			mu.RUnlock()
+			if !hit || !time.Now().Before(entry.expires) {
+				userVersion, stationVersion, err := s.GetTokenVersions(c.Request().Context(), userID, stationID)
+				if err != nil {
+					return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify session"})
+				}
+				entry = versionEntry{user: userVersion, station: stationVersion, expires: time.Now().Add(ttl)}
+				if sessionID != nil {
+					active, err := s.SessionActive(c.Request().Context(), userID, *sessionID)
+					if err != nil {
+						return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify session"})
+					}
+					entry.sessionEnded = !active
+				}
+				if ttl > 0 {
+					mu.Lock()
+					if len(cache) >= maxGateCacheEntries {
+						sweepExpiredVersionsLocked(cache, time.Now())
+					}
+					cache[key] = entry
+					mu.Unlock()
+				}
+			}
+			if claims.TokenVersion != entry.user || (stationID != nil && claims.StationTokenVersion != entry.station) || entry.sessionEnded {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"code": "token_revoked", "error": "Session revoked"})
+			}
+
 			c.Set("user", claims)
 			return next(c)
 		}
 	}
 }
+
+// sweepExpiredVersionsLocked is sweepExpiredLocked for the token version
+// cache; callers hold the write lock.
+func sweepExpiredVersionsLocked(cache map[string]versionEntry, now time.Time) {
+	for k, v := range cache {
+		if now.After(v.expires) {
+			delete(cache, k)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"context"
 	"idento/backend/internal/models"
+	"idento/backend/internal/store"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -9,6 +11,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
@@ -18,12 +21,33 @@ import (
 // scanners don't flag this defunct, deliberately-referenced test fixture.
 var oldHardcodedFallbackSecret = strings.Join([]string{"idento", "secret", "key", "change", "me"}, "_")
 
+// versionFakeStore serves fixed token versions and counts lookups.
+// Sessions are live unless listed in ended.
+type versionFakeStore struct {
+	store.Store
+	user, station int
+	calls         int
+	ended         map[uuid.UUID]bool
+}
+
+func (f *versionFakeStore) GetTokenVersions(_ context.Context, _ uuid.UUID, stationID *uuid.UUID) (int, int, error) {
+	f.calls++
+	if stationID == nil {
+		return f.user, -1, nil
+	}
+	return f.user, f.station, nil
+}
+
+func (f *versionFakeStore) SessionActive(_ context.Context, _, familyID uuid.UUID) (bool, error) {
+	return !f.ended[familyID], nil
+}
+
 // signTokenWithSecret builds a valid HS256 token, signed with the given
 // secret, using the same claims shape the middleware expects.
 func signTokenWithSecret(t *testing.T, secret string) string {
 	t.Helper()
 	claims := models.JWTCustomClaims{
-		UserID:   "x",
+		UserID:   uuid.NewString(),
 		TenantID: "x",
 		Role:     "admin",
 		RegisteredClaims: jwt.RegisteredClaims{
@@ -49,7 +73,7 @@ func callJWTMiddleware(token string) (handlerCalled bool, code int) {
 	c := e.NewContext(req, rec)
 
 	called := false
-	h := JWT()(func(c echo.Context) error { called = true; return nil })
+	h := JWT(&versionFakeStore{})(func(c echo.Context) error { called = true; return nil })
 	_ = h(c)
 
 	return called, rec.Code
@@ -95,3 +119,87 @@ func TestJWT_RejectsWhenSecretUnset(t *testing.T) {
 		}
 	})
 }
+
+func signClaims(t *testing.T, claims *models.JWTCustomClaims) string {
+	t.Helper()
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatalf("failed to sign test token: %v", err)
+	}
+	return signed
+}
+
+func runJWT(mw echo.MiddlewareFunc, token string) (called bool, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	h := mw(func(c echo.Context) error { called = true; return c.NoContent(http.StatusOK) })
+	if err := h(e.NewContext(req, rec)); err != nil {
+		panic(err)
+	}
+	return called, rec
+}
+
+// A token minted before its user's (or station's) token_version was bumped
+// is rejected; versions are cached, so a burst of requests is one lookup.
+func TestJWT_RejectsRevokedTokenVersions(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	expires := jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+	userID, stationID := uuid.NewString(), uuid.NewString()
+	fs := &versionFakeStore{user: 2, station: 1}
+	mw := jwtWithTTL(fs, time.Minute)
+
+	current := signClaims(t, &models.JWTCustomClaims{UserID: userID, TokenVersion: 2, RegisteredClaims: expires})
+	for i := 0; i < 3; i++ {
+		if called, rec := runJWT(mw, current); !called {
+			t.Fatalf("current token rejected: %d %s", rec.Code, rec.Body.String())
+		}
+	}
+	if fs.calls != 1 {
+		t.Errorf("version lookups = %d, want 1 (cached)", fs.calls)
+	}
+
+	stale := signClaims(t, &models.JWTCustomClaims{UserID: userID, TokenVersion: 1, RegisteredClaims: expires})
+	called, rec := runJWT(mw, stale)
+	if called || rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), `"code":"token_revoked"`) {
+		t.Errorf("stale user version: called=%v code=%d body=%s, want 401 token_revoked", called, rec.Code, rec.Body.String())
+	}
+
+	station := signClaims(t, &models.JWTCustomClaims{UserID: userID, TokenVersion: 2, StationID: stationID, StationTokenVersion: 0, RegisteredClaims: expires})
+	if called, rec := runJWT(mw, station); called || rec.Code != http.StatusUnauthorized {
+		t.Errorf("revoked station: called=%v code=%d, want 401", called, rec.Code)
+	}
+}
+
+// An access token of a logged-out session is rejected before it expires;
+// other sessions of the same user are unaffected.
+func TestJWT_RejectsLoggedOutSession(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	expires := jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+	userID, loggedOut, live := uuid.NewString(), uuid.New(), uuid.New()
+	mw := jwtWithTTL(&versionFakeStore{ended: map[uuid.UUID]bool{loggedOut: true}}, time.Minute)
+
+	ended := signClaims(t, &models.JWTCustomClaims{UserID: userID, SessionID: loggedOut.String(), RegisteredClaims: expires})
+	called, rec := runJWT(mw, ended)
+	if called || rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), `"code":"token_revoked"`) {
+		t.Errorf("logged-out session: called=%v code=%d body=%s, want 401 token_revoked", called, rec.Code, rec.Body.String())
+	}
+
+	current := signClaims(t, &models.JWTCustomClaims{UserID: userID, SessionID: live.String(), RegisteredClaims: expires})
+	if called, rec := runJWT(mw, current); !called {
+		t.Errorf("live session rejected: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestJWT_ExpiredTokenIsMachineReadable(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	token := signClaims(t, &models.JWTCustomClaims{
+		UserID:           uuid.NewString(),
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute))},
+	})
+	called, rec := runJWT(JWT(&versionFakeStore{}), token)
+	if called || rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), `"code":"token_expired"`) {
+		t.Errorf("expired token: called=%v code=%d body=%s, want 401 token_expired", called, rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// TwoFactorPolicy enforces a tenant's "two-factor required" setting: an
+// admin or manager whose session was not signed in with a second factor
+// gets 403 two_factor_enrollment_required everywhere except the endpoints
+// needed to enroll. Staff and station sessions are never affected. The
+// policy is cached per tenant for 2 minutes, like TenantGate.
+func TwoFactorPolicy(s store.Store) echo.MiddlewareFunc {
+	return twoFactorPolicyWithTTL(s, 2*time.Minute)
+}
+
+func twoFactorPolicyWithTTL(s store.Store, ttl time.Duration) echo.MiddlewareFunc {
+	var (
+		mu    sync.RWMutex
+		cache = map[string]gateEntry{}
+	)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			path := c.Request().URL.Path
+			// Exempt: enrollment itself (/api/auth/2fa/*), session endpoints,
+			// and platform operators.
+			if path == "/api/me" || strings.HasPrefix(path, "/api/auth/") || strings.HasPrefix(path, "/api/super-admin") {
+				return next(c)
+			}
+			claims, ok := c.Get("user").(*models.JWTCustomClaims)
+			if !ok || claims == nil || claims.TwoFactor || claims.ImpersonatedBy != "" {
+				return next(c)
+			}
+			if claims.Role != "admin" && claims.Role != "manager" {
+				return next(c)
+			}
+
+			if ttl > 0 {
+				mu.RLock()
+				entry, hit := cache[claims.TenantID]
+				mu.RUnlock()
+				if hit && time.Now().Before(entry.expires) {
+					if entry.blocked {
+						return enrollmentRequiredResponse(c)
+					}
+					return next(c)
+				}
+			}
+
+			tenantID, err := uuid.Parse(claims.TenantID)
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid token"})
+			}
+			required, err := s.GetTenantTwoFactorRequired(c.Request().Context(), tenantID)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify two-factor policy"})
+			}
+			if ttl > 0 {
+				mu.Lock()
+				if len(cache) >= maxGateCacheEntries {
+					sweepExpiredLocked(cache, time.Now())
+				}
+				cache[claims.TenantID] = gateEntry{blocked: required, expires: time.Now().Add(ttl)}
+				mu.Unlock()
+			}
+			if required {
+				return enrollmentRequiredResponse(c)
+			}
+			return next(c)
+		}
+	}
+}
+
+func enrollmentRequiredResponse(c echo.Context) error {
+	return c.JSON(http.StatusForbidden, map[string]string{
+		"code":  "two_factor_enrollment_required",
+		"error": "This organization requires two-factor authentication. Set it up to continue.",
+	})
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"idento/backend/internal/models"
+	"idento/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type policyFakeStore struct {
+	store.Store
+	required bool
+	calls    int
+}
+
+func (f *policyFakeStore) GetTenantTwoFactorRequired(_ context.Context, _ uuid.UUID) (bool, error) {
+	f.calls++
+	return f.required, nil
+}
+
+func policyRequest(t *testing.T, mw echo.MiddlewareFunc, path string, claims *models.JWTCustomClaims) *httptest.ResponseRecorder {
+	t.Helper()
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user", claims)
+	handler := mw(func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	if err := handler(c); err != nil {
+		t.Fatalf("middleware error: %v", err)
+	}
+	return rec
+}
+
+func TestTwoFactorPolicy(t *testing.T) {
+	tenant := uuid.New().String()
+	cases := map[string]struct {
+		required bool
+		path     string
+		claims   models.JWTCustomClaims
+		want     int
+	}{
+		"admin without second factor":   {true, "/api/events", models.JWTCustomClaims{TenantID: tenant, Role: "admin"}, http.StatusForbidden},
+		"manager without second factor": {true, "/api/events", models.JWTCustomClaims{TenantID: tenant, Role: "manager"}, http.StatusForbidden},
+		"admin signed in with 2fa":      {true, "/api/events", models.JWTCustomClaims{TenantID: tenant, Role: "admin", TwoFactor: true}, http.StatusOK},
+		"staff is never affected":       {true, "/api/events", models.JWTCustomClaims{TenantID: tenant, Role: "staff"}, http.StatusOK},
+		"enrollment stays reachable":    {true, "/api/auth/2fa/setup", models.JWTCustomClaims{TenantID: tenant, Role: "admin"}, http.StatusOK},
+		"impersonation":                 {true, "/api/events", models.JWTCustomClaims{TenantID: tenant, Role: "admin", ImpersonatedBy: uuid.New().String()}, http.StatusOK},
+		"policy off":                    {false, "/api/events", models.JWTCustomClaims{TenantID: tenant, Role: "admin"}, http.StatusOK},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fs := &policyFakeStore{required: tc.required}
+			rec := policyRequest(t, twoFactorPolicyWithTTL(fs, 0), tc.path, &tc.claims)
+			if rec.Code != tc.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.want)
+			}
+			if tc.want == http.StatusForbidden && !contains(rec.Body.String(), `"code":"two_factor_enrollment_required"`) {
+				t.Errorf("body %q missing machine-readable code", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestTwoFactorPolicyCachesPerTenant(t *testing.T) {
+	fs := &policyFakeStore{required: true}
+	mw := twoFactorPolicyWithTTL(fs, time.Minute)
+	claims := &models.JWTCustomClaims{TenantID: uuid.New().String(), Role: "admin"}
+	for i := 0; i < 3; i++ {
+		if rec := policyRequest(t, mw, "/api/events", claims); rec.Code != http.StatusForbidden {
+			t.Fatalf("request %d: status = %d, want 403", i, rec.Code)
+		}
+	}
+	if fs.calls != 1 {
+		t.Errorf("store calls = %d, want 1 (cached)", fs.calls)
+	}
+}
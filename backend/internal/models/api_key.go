@@ -6,22 +6,35 @@ import (
 	"github.com/google/uuid"
 )
 
+// API-key request budget bounds. A key created without an explicit
+// rate_limit_per_minute gets the default, which matches the column default
+// in migration 000026 so pre-existing keys behave the same as new ones.
+const (
+	DefaultAPIKeyRateLimitPerMinute = 60
+	MaxAPIKeyRateLimitPerMinute     = 6000
+)
+
 type APIKey struct {
-	ID            uuid.UUID  `json:"id"`
-	EventID       uuid.UUID  `json:"event_id"`
-	Name          string     `json:"name"`
-	KeyHash       string     `json:"-"`           // SHA256 for indexed lookup only
-	KeyHashBcrypt *string    `json:"-"`           // bcrypt for verification when set (new keys)
-	KeyPreview    string     `json:"key_preview"` // Only first 8 chars for display
-	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
-	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
-	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
-	CreatedAt     time.Time  `json:"created_at"`
+	ID                 uuid.UUID  `json:"id"`
+	EventID            uuid.UUID  `json:"event_id"`
+	TenantID           uuid.UUID  `json:"-"` // joined from events; set by GetActiveAPIKeys for usage metering
+	Name               string     `json:"name"`
+	KeyHash            string     `json:"-"`           // SHA256 for indexed lookup only
+	KeyHashBcrypt      *string    `json:"-"`           // bcrypt for verification when set (new keys)
+	KeyPreview         string     `json:"key_preview"` // Only first 8 chars for display
+	RateLimitPerMinute int        `json:"rate_limit_per_minute"`
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt         *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt          *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
 }
 
 type CreateAPIKeyRequest struct {
 	Name      string     `json:"name" binding:"required"`
 	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// RateLimitPerMinute is the key's request budget; nil means
+	// DefaultAPIKeyRateLimitPerMinute.
+	RateLimitPerMinute *int `json:"rate_limit_per_minute,omitempty"`
 }
 
 type CreateAPIKeyResponse struct {
@@ -31,4 +44,63 @@ type CreateAPIKeyResponse struct {
 
 type ExternalImportRequest struct {
 	Data []map[string]interface{} `json:"data" binding:"required"`
+	// Mode is one of the ImportMode* values; "" means ImportModeInsertOnly,
+	// the behaviour the endpoint always had.
+	Mode string `json:"mode,omitempty"`
+	// MatchOn is one of the ImportMatch* values; "" means
+	// ImportMatchExternalID. Ignored in insert-only mode.
+	MatchOn string `json:"match_on,omitempty"`
+}
+
+// External import modes: insert-only creates every row; upsert updates the
+// attendee a row matches and creates it otherwise; update-only updates
+// matched attendees and fails rows that match nobody.
+const (
+	ImportModeInsertOnly = "insert_only"
+	ImportModeUpsert     = "upsert"
+	ImportModeUpdateOnly = "update_only"
+)
+
+// Attendee fields an external import row can be matched on.
+const (
+	ImportMatchExternalID = "external_id"
+	ImportMatchEmail      = "email"
+	ImportMatchCode       = "code"
+)
+
+// Per-row outcomes of an external import.
+const (
+	ImportRowCreated   = "created"
+	ImportRowUpdated   = "updated"
+	ImportRowUnchanged = "unchanged"
+	ImportRowFailed    = "failed"
+)
+
+// ExternalImportRowResult reports what happened to one row (1-based, in
+// request order) of an external import.
+type ExternalImportRowResult struct {
+	Row        int        `json:"row"`
+	Status     string     `json:"status"`
+	AttendeeID *uuid.UUID `json:"attendee_id,omitempty"`
+	ExternalID string     `json:"external_id,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// APIKeyUsageDay is one UTC day of a key's metered traffic: requests the
+// key was allowed to make, and requests rejected by its rate limit.
+type APIKeyUsageDay struct {
+	Date      string `json:"date"` // YYYY-MM-DD
+	Requests  int    `json:"requests"`
+	Throttled int    `json:"throttled"`
+}
+
+// APIKeyUsageResponse is GET /api/events/:event_id/api-keys/:key_id/usage.
+type APIKeyUsageResponse struct {
+	KeyID              uuid.UUID        `json:"key_id"`
+	RateLimitPerMinute int              `json:"rate_limit_per_minute"`
+	From               time.Time        `json:"from"`
+	To                 time.Time        `json:"to"`
+	TotalRequests      int              `json:"total_requests"`
+	TotalThrottled     int              `json:"total_throttled"`
+	Days               []APIKeyUsageDay `json:"days"`
 }
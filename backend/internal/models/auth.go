@@ -1,6 +1,11 @@
 package models
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
 
 type JWTCustomClaims struct {
 	UserID   string `json:"user_id"`
@@ -9,5 +14,44 @@ type JWTCustomClaims struct {
 	// ImpersonatedBy is set only on operator-minted impersonation tokens:
 	// the super admin's user id. Its presence marks the session for audit.
 	ImpersonatedBy string `json:"imp_by,omitempty"`
+	// TokenVersion is the user's token_version at issue time; the JWT
+	// middleware rejects the token once the user's version has moved on.
+	TokenVersion int `json:"tv,omitempty"`
+	// StationID and StationTokenVersion are set on tokens issued to a
+	// provisioned station phone, which is revocable on its own.
+	StationID           string `json:"station_id,omitempty"`
+	StationTokenVersion int    `json:"stv,omitempty"`
+	// SessionID is the refresh token family this access token belongs to,
+	// so logout can end exactly this session.
+	SessionID string `json:"sid,omitempty"`
+	// TwoFactor is set when the session was signed in with a second factor
+	// (TOTP or a recovery code); tenants that require two-factor for
+	// admins and managers only admit such tokens.
+	TwoFactor bool `json:"mfa,omitempty"`
 	jwt.RegisteredClaims
 }
+
+// RefreshToken is one link of a rotating refresh token family (a session).
+// Only the hash of the token is stored.
+type RefreshToken struct {
+	ID         uuid.UUID  `json:"id"`
+	FamilyID   uuid.UUID  `json:"family_id"`
+	TokenHash  string     `json:"-"`
+	UserID     uuid.UUID  `json:"user_id"`
+	TenantID   uuid.UUID  `json:"tenant_id"`
+	StationID  *uuid.UUID `json:"station_id,omitempty"`
+	TwoFactor  bool       `json:"two_factor"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	ReplacedBy *uuid.UUID `json:"replaced_by,omitempty"`
+	IPAddress  *string    `json:"ip_address,omitempty"`
+	UserAgent  *string    `json:"user_agent,omitempty"`
+}
+
+// Purposes of the single-use tokens mailed to a user (user_tokens).
+const (
+	UserTokenPasswordReset     = "password_reset"
+	UserTokenEmailVerification = "email_verification"
+	UserTokenInvitation        = "invitation"
+)
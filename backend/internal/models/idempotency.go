@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyRecord is one idempotency_keys row: a tenant-scoped
+// Idempotency-Key claimed by the first request that used it and, once that
+// request finished, the response to replay on retries. StatusCode is nil
+// while the first request is still in flight.
+type IdempotencyRecord struct {
+	TenantID     uuid.UUID
+	Key          string
+	Method       string
+	Path         string
+	RequestHash  string
+	StatusCode   *int
+	ContentType  string
+	ResponseBody []byte
+	CreatedAt    time.Time
+	CompletedAt  *time.Time
+}
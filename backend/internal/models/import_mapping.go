@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImportMapping is a saved, per-tenant column-mapping preset for the
+// spreadsheet import. Columns maps a source header to the attendee field it
+// fills: one of the standard fields (first_name, last_name, email, company,
+// position, code) or any other name, which becomes a custom field and a
+// FieldSchema entry. An empty target drops the column.
+type ImportMapping struct {
+	ID        uuid.UUID         `json:"id"`
+	TenantID  uuid.UUID         `json:"-"`
+	Name      string            `json:"name"`
+	Columns   map[string]string `json:"columns"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
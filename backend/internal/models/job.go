@@ -0,0 +1,73 @@
+package models
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Job statuses. queued and running are unfinished; succeeded and failed are
+// terminal and never change again.
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// Job types, each run by the handler registered for it with the job runner.
+const (
+	JobTypeAttendeeImport = "attendee_import"
+	JobTypeAttendeeExport = "attendee_export"
+	JobTypeBadgePrint     = "badge_print"
+	JobTypeTicketEmail    = "ticket_email"
+	JobTypeTicketPDF      = "ticket_pdf"
+	JobTypeWalletUpdate   = "wallet_update"
+)
+
+// Job is one jobs row: a unit of background work, its progress, and once
+// finished its result. Payload is the type-specific input and never leaves
+// the server; the artifact bytes are served separately (GetJobArtifact), so
+// only their name is part of the JSON.
+type Job struct {
+	ID           uuid.UUID       `json:"id"`
+	TenantID     uuid.UUID       `json:"-"`
+	EventID      *uuid.UUID      `json:"event_id,omitempty"`
+	CreatedBy    *uuid.UUID      `json:"created_by,omitempty"`
+	Type         string          `json:"type"`
+	Payload      json.RawMessage `json:"-"`
+	Status       string          `json:"status"`
+	Progress     JobProgress     `json:"progress"`
+	Result       json.RawMessage `json:"result,omitempty"`
+	Error        *string         `json:"error,omitempty"`
+	ArtifactName *string         `json:"artifact_name,omitempty"`
+	Attempts     int             `json:"attempts"`
+	CreatedAt    time.Time       `json:"created_at"`
+	StartedAt    *time.Time      `json:"started_at,omitempty"`
+	FinishedAt   *time.Time      `json:"finished_at,omitempty"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+// JobProgress counts processed items. Total is 0 while unknown.
+type JobProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// Finished reports whether the job reached a terminal status.
+func (j *Job) Finished() bool {
+	return j.Status == JobStatusSucceeded || j.Status == JobStatusFailed
+}
+
+// JobArtifact is a job's downloadable output file. Its bytes are never
+// held whole: a worker hands CompleteJob a Content reader over a spooled
+// file, and a download streams them back chunk by chunk
+// (WriteJobArtifact), so Content is only set on the way in.
+type JobArtifact struct {
+	Name        string
+	ContentType string
+	Size        int64
+	Content     io.Reader
+}
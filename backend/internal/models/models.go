@@ -46,9 +46,22 @@ type User struct {
 	QRToken          *string    `json:"-"`
 	HasQRToken       bool       `json:"has_qr_token"`
 	QRTokenCreatedAt *time.Time `json:"qr_token_created_at,omitempty"`
-	CreatedAt        time.Time  `json:"created_at"`
-	UpdatedAt        time.Time  `json:"updated_at"`
-}
+	EmailVerifiedAt  *time.Time `json:"email_verified_at,omitempty"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	// Status and DeactivatedAt describe the membership in the tenant the
+	// user was listed for (GetUsersByTenantID, GetTenantMember).
+	Status        string     `json:"status,omitempty"` // active, invited, deactivated
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Membership statuses (User.Status).
+const (
+	MemberStatusActive      = "active"
+	MemberStatusInvited     = "invited"
+	MemberStatusDeactivated = "deactivated"
+)
 
 type EventStaff struct {
 	ID         uuid.UUID `json:"id"`
@@ -89,6 +102,17 @@ type Event struct {
 	DeletedAt       *time.Time      `json:"deleted_at,omitempty"`
 }
 
+// EventCloneCounts reports how many rows of each kind an event clone copied.
+type EventCloneCounts struct {
+	Zones                int64 `json:"zones"`
+	ZoneAccessRules      int64 `json:"zone_access_rules"`
+	Fonts                int64 `json:"fonts"`
+	Staff                int64 `json:"staff"`
+	StaffZoneAssignments int64 `json:"staff_zone_assignments"`
+	Attendees            int64 `json:"attendees"`
+	AttendeeZoneAccess   int64 `json:"attendee_zone_access"`
+}
+
 type Attendee struct {
 	ID                    uuid.UUID              `json:"id"`
 	EventID               uuid.UUID              `json:"event_id"`
@@ -98,6 +122,7 @@ type Attendee struct {
 	Company               string                 `json:"company"`
 	Position              string                 `json:"position"`
 	Code                  string                 `json:"code"`
+	ExternalID            *string                `json:"external_id,omitempty"` // registration system's ID, set by external imports
 	CheckinStatus         bool                   `json:"checkin_status"`
 	CheckedInAt           *time.Time             `json:"checked_in_at,omitempty"`
 	CheckedInBy           *uuid.UUID             `json:"checked_in_by,omitempty"`
@@ -186,6 +211,26 @@ type AdminAuditLog struct {
 	CreatedAt   time.Time              `json:"created_at"`
 }
 
+// TenantAuditEntry is one row of a tenant's own audit trail: a change made
+// inside the organization, by whom (and from which station), with the
+// field-level diff in Changes (see package audit).
+type TenantAuditEntry struct {
+	ID             uuid.UUID       `json:"id"`
+	TenantID       uuid.UUID       `json:"tenant_id"`
+	EventID        *uuid.UUID      `json:"event_id,omitempty"`
+	ActorUserID    *uuid.UUID      `json:"actor_user_id,omitempty"`
+	ActorEmail     *string         `json:"actor_email,omitempty"`
+	ImpersonatedBy *uuid.UUID      `json:"impersonated_by,omitempty"`
+	StationID      *uuid.UUID      `json:"station_id,omitempty"`
+	Action         string          `json:"action"`
+	TargetType     string          `json:"target_type"`
+	TargetID       *uuid.UUID      `json:"target_id,omitempty"`
+	Changes        json.RawMessage `json:"changes"`
+	IPAddress      *string         `json:"ip_address,omitempty"`
+	UserAgent      *string         `json:"user_agent,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
 // TimeCount is a single point in a time-bucketed series (signups, checkins).
 type TimeCount struct {
 	Period string `json:"period"` // YYYY-MM-DD (day) or ISO week start date
@@ -348,6 +393,8 @@ type ProvisionedStationConfig struct {
 type ProvisionStationResponse struct {
 	StationConfig ProvisionedStationConfig `json:"station_config"`
 	StaffJWT      string                   `json:"staff_jwt"`
+	RefreshToken  string                   `json:"refresh_token"`
+	ExpiresAt     time.Time                `json:"expires_at"`
 	DeviceNumber  int                      `json:"device_number"`
 }
 
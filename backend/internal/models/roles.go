@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TenantRole is a tenant-defined role: a named set of permissions,
+// optionally limited to some events. Members assigned to it get exactly
+// these permissions instead of their built-in role's.
+type TenantRole struct {
+	ID          uuid.UUID `json:"id"`
+	TenantID    uuid.UUID `json:"tenant_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	// EventIDs limits the role to these events; empty means all events.
+	EventIDs []uuid.UUID `json:"event_ids"`
+	// UserIDs are the members assigned to the role (read-only).
+	UserIDs   []uuid.UUID `json:"user_ids"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TenantOIDCProvider is a tenant's OpenID Connect single sign-on setup.
+type TenantOIDCProvider struct {
+	TenantID     uuid.UUID `json:"tenant_id"`
+	Issuer       string    `json:"issuer"`
+	ClientID     string    `json:"client_id"`
+	ClientSecret string    `json:"-"`
+	// AllowedDomains are the email domains that may sign in (lowercase).
+	AllowedDomains []string `json:"allowed_domains"`
+	// RoleClaim names the ID token claim (string or array) whose values
+	// RoleMapping maps to Idento roles; empty disables mapping and every
+	// user gets DefaultRole on first sign-in.
+	RoleClaim   string            `json:"role_claim"`
+	RoleMapping map[string]string `json:"role_mapping"`
+	DefaultRole string            `json:"default_role"`
+	Enabled     bool              `json:"enabled"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// OIDCLoginRequest is an authorization request in flight, keyed by the
+// hash of its state parameter.
+type OIDCLoginRequest struct {
+	StateHash    string
+	TenantID     uuid.UUID
+	CodeVerifier string
+	Nonce        string
+	ExpiresAt    time.Time
+}
+
+// OIDCIdentity is a verified provider account signing in to a tenant.
+type OIDCIdentity struct {
+	TenantID      uuid.UUID
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	// Role is the mapped role; SyncRole applies it to an existing
+	// membership too (the provider is the source of truth for roles).
+	Role     string
+	SyncRole bool
+}
+
+// SSOTenant is a tenant offering single sign-on for an email domain.
+type SSOTenant struct {
+	TenantID   uuid.UUID `json:"tenant_id"`
+	TenantName string    `json:"tenant_name"`
+}
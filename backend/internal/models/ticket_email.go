@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Where a ticket email carries the attendee's QR code.
+const (
+	TicketQRInline     = "inline"     // an image in the HTML body
+	TicketQRAttachment = "attachment" // a PNG attachment
+)
+
+// TicketEmailTemplate is an event's ticket email in one locale. Subject
+// and Body are plain text with {{placeholders}}; see
+// handler.ticketPlaceholders for the names.
+type TicketEmailTemplate struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// TicketEmailSettings configures an event's ticket emails. A locale
+// missing from Templates uses the built-in ticket template.
+type TicketEmailSettings struct {
+	EventID       uuid.UUID `json:"event_id"`
+	SendOnImport  bool      `json:"send_on_import"`
+	QRPlacement   string    `json:"qr_placement"`
+	AttachPDF     bool      `json:"attach_pdf"` // attach the PDF e-ticket too
+	DefaultLocale string    `json:"default_locale"`
+	// LocaleField names the attendee field (standard or custom) holding
+	// the attendee's language; empty or unrecognized values fall back to
+	// DefaultLocale.
+	LocaleField string                         `json:"locale_field"`
+	ReplyTo     string                         `json:"reply_to"`
+	Templates   map[string]TicketEmailTemplate `json:"templates"`
+	UpdatedAt   time.Time                      `json:"updated_at"`
+}
+
+// Ticket delivery statuses. queued and deferred are waiting to be sent;
+// sent, failed and bounced are final until the ticket is sent again.
+const (
+	TicketDeliveryQueued   = "queued"
+	TicketDeliveryDeferred = "deferred" // a temporary failure; retried at NextAttemptAt
+	TicketDeliverySent     = "sent"
+	TicketDeliveryFailed   = "failed"  // retries exhausted
+	TicketDeliveryBounced  = "bounced" // the recipient was rejected
+)
+
+// TicketDelivery is the state of an attendee's latest ticket email.
+type TicketDelivery struct {
+	ID            uuid.UUID  `json:"id"`
+	EventID       uuid.UUID  `json:"event_id"`
+	AttendeeID    uuid.UUID  `json:"attendee_id"`
+	Email         string     `json:"email"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     *string    `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"` // only while queued or deferred
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	BouncedAt     *time.Time `json:"bounced_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TenantWalletSettings holds a tenant's wallet pass credentials. Each
+// wallet is configured when its secret is set.
+type TenantWalletSettings struct {
+	TenantID uuid.UUID `json:"tenant_id"`
+	// AppleCertificate is the pass type certificate, its intermediates and
+	// its key as PEM (see wallet.Signer.PEM).
+	AppleCertificate string     `json:"-"`
+	ApplePassTypeID  string     `json:"apple_pass_type_id"`
+	AppleTeamID      string     `json:"apple_team_id"`
+	AppleExpiresAt   *time.Time `json:"apple_expires_at,omitempty"`
+	GoogleIssuerID   string     `json:"google_issuer_id"`
+	// GoogleServiceAccount is the service account key file (JSON).
+	GoogleServiceAccount      string    `json:"-"`
+	GoogleServiceAccountEmail string    `json:"google_service_account_email"`
+	CreatedAt                 time.Time `json:"created_at"`
+	UpdatedAt                 time.Time `json:"updated_at"`
+}
+
+// AppleConfigured reports whether Apple Wallet passes can be issued.
+func (s *TenantWalletSettings) AppleConfigured() bool {
+	return s != nil && s.AppleCertificate != ""
+}
+
+// GoogleConfigured reports whether Google Wallet links can be issued.
+func (s *TenantWalletSettings) GoogleConfigured() bool {
+	return s != nil && s.GoogleServiceAccount != "" && s.GoogleIssuerID != ""
+}
+
+// WalletPass is an attendee's Apple Wallet pass; its serial number is the
+// attendee ID.
+type WalletPass struct {
+	AttendeeID uuid.UUID
+	EventID    uuid.UUID
+	PassTypeID string
+	AuthToken  string
+	UpdatedAt  time.Time
+}
+
+// WalletPushTarget is a device to wake when a pass of PassTypeID changes.
+type WalletPushTarget struct {
+	PassTypeID string
+	PushToken  string
+}
@@ -0,0 +1,335 @@
+// Package oidc is a minimal OpenID Connect relying party: provider
+// discovery, the authorization-code flow with PKCE (S256), and ID token
+// verification against the provider's JWKS. Only what per-tenant single
+// sign-on needs; no UserInfo calls, no token refresh.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// metadataTTL is how long discovery documents and keys are reused.
+	metadataTTL = time.Hour
+	// keyRefetchInterval rate-limits JWKS refetches for an unknown key id,
+	// so forged tokens cannot make us hammer the provider.
+	keyRefetchInterval = time.Minute
+	// maxResponseBytes caps what we read from a provider.
+	maxResponseBytes = 1 << 20
+)
+
+// ErrInvalidToken is returned when the provider's ID token fails
+// verification (signature, issuer, audience, expiry or nonce).
+var ErrInvalidToken = errors.New("invalid id token")
+
+// Config identifies one relying-party registration at a provider.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+// Metadata is the subset of the discovery document we use.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// IDToken holds the verified claims of an ID token.
+type IDToken struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	// AMR lists the authentication methods the provider reports (RFC 8176),
+	// e.g. "pwd", "mfa", "otp".
+	AMR []string
+	// Claims is every claim, for mapping provider-specific ones (groups,
+	// roles) onto Idento roles.
+	Claims map[string]interface{}
+}
+
+// Client talks to OpenID providers. It caches each provider's discovery
+// document and signing keys; it is safe for concurrent use.
+type Client struct {
+	http *http.Client
+
+	mu        sync.Mutex
+	providers map[string]*provider
+}
+
+type provider struct {
+	meta      Metadata
+	keys      map[string]interface{}
+	fetchedAt time.Time
+	keysAt    time.Time
+}
+
+// NewClient returns a Client using hc, or a client with a 10-second
+// timeout when hc is nil.
+func NewClient(hc *http.Client) *Client {
+	if hc == nil {
+		hc = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Client{http: hc, providers: map[string]*provider{}}
+}
+
+// NewPKCE returns a random code verifier and its S256 code challenge.
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	return verifier, pkceChallenge(verifier), nil
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// RandomString returns 32 random bytes, base64url-encoded: suitable for a
+// state, a nonce or a PKCE verifier.
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Discover returns the provider's metadata, fetching it when not cached.
+func (c *Client) Discover(ctx context.Context, issuer string) (Metadata, error) {
+	p, err := c.provider(ctx, issuer)
+	if err != nil {
+		return Metadata{}, err
+	}
+	return p.meta, nil
+}
+
+// AuthCodeURL returns the provider URL to send the browser to.
+func (c *Client) AuthCodeURL(ctx context.Context, cfg Config, state, nonce, codeChallenge string) (string, error) {
+	meta, err := c.Discover(ctx, cfg.Issuer)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("authorization endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", cfg.ClientID)
+	q.Set("redirect_uri", cfg.RedirectURL)
+	q.Set("scope", "openid email profile")
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified ID
+// token. nonce must be the one sent with the authorization request.
+func (c *Client) Exchange(ctx context.Context, cfg Config, code, codeVerifier, nonce string) (*IDToken, error) {
+	meta, err := c.Discover(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {cfg.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("token response: %w", err)
+	}
+	var tok struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &tok); err != nil {
+		return nil, fmt.Errorf("token response: status %d: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || tok.Error != "" {
+		return nil, fmt.Errorf("token endpoint: status %d: %s %s", resp.StatusCode, tok.Error, tok.ErrorDescription)
+	}
+	if tok.IDToken == "" {
+		return nil, fmt.Errorf("token endpoint returned no id_token")
+	}
+	return c.Verify(ctx, cfg, tok.IDToken, nonce)
+}
+
+// Verify checks an ID token's signature, issuer, audience, expiry and nonce.
+func (c *Client) Verify(ctx context.Context, cfg Config, raw, nonce string) (*IDToken, error) {
+	p, err := c.provider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return c.key(ctx, cfg.Issuer, p, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "PS256"}),
+		jwt.WithIssuer(p.meta.Issuer),
+		jwt.WithAudience(cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+	tok := &IDToken{Claims: claims}
+	tok.Subject, _ = claims["sub"].(string)
+	tok.Email, _ = claims["email"].(string)
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		tok.EmailVerified = v
+	case string: // some providers send "true"
+		tok.EmailVerified = v == "true"
+	}
+	tok.AMR = StringsClaim(claims["amr"])
+	if tok.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	}
+	return tok, nil
+}
+
+// StringsClaim reads a claim that may be a string or an array of strings.
+func StringsClaim(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func (c *Client) provider(ctx context.Context, issuer string) (*provider, error) {
+	issuer = strings.TrimRight(issuer, "/")
+	c.mu.Lock()
+	p, ok := c.providers[issuer]
+	c.mu.Unlock()
+	if ok && time.Since(p.fetchedAt) < metadataTTL {
+		return p, nil
+	}
+
+	var meta Metadata
+	if err := c.getJSON(ctx, issuer+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if strings.TrimRight(meta.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discovery: issuer %q does not match %q", meta.Issuer, issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery: incomplete provider metadata")
+	}
+	keys, err := c.fetchKeys(ctx, meta.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	p = &provider{meta: meta, keys: keys, fetchedAt: now, keysAt: now}
+	c.mu.Lock()
+	c.providers[issuer] = p
+	c.mu.Unlock()
+	return p, nil
+}
+
+// key returns the signing key kid, refetching the JWKS once (rate-limited)
+// when the provider has rotated to a key we have not seen.
+func (c *Client) key(ctx context.Context, issuer string, p *provider, kid string) (interface{}, error) {
+	c.mu.Lock()
+	k, ok := lookupKey(p.keys, kid)
+	stale := time.Since(p.keysAt) >= keyRefetchInterval
+	c.mu.Unlock()
+	if ok {
+		return k, nil
+	}
+	if !stale {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	keys, err := c.fetchKeys(ctx, p.meta.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	p.keys, p.keysAt = keys, time.Now()
+	k, ok = lookupKey(keys, kid)
+	c.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return k, nil
+}
+
+// lookupKey finds kid, or the only key when the token names none.
+func lookupKey(keys map[string]interface{}, kid string) (interface{}, bool) {
+	if kid == "" && len(keys) == 1 {
+		for _, k := range keys {
+			return k, true
+		}
+	}
+	k, ok := keys[kid]
+	return k, ok
+}
+
+func (c *Client) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(v)
+}
@@ -0,0 +1,118 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"idento/backend/internal/oidc/oidctest"
+)
+
+func newProvider(t *testing.T) (*oidctest.Provider, Config) {
+	t.Helper()
+	p, srv, err := oidctest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	return p, Config{
+		Issuer:       p.Issuer,
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  "http://rp.example/callback",
+	}
+}
+
+// login runs the whole code flow and returns the code and the URL state.
+func login(t *testing.T, c *Client, cfg Config, nonce, challenge string) (code, state string) {
+	t.Helper()
+	authURL, err := c.AuthCodeURL(context.Background(), cfg, "the-state", nonce, challenge)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	back, err := oidctest.Follow(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	return back.Query().Get("code"), back.Query().Get("state")
+}
+
+func TestCodeFlowWithPKCE(t *testing.T) {
+	p, cfg := newProvider(t)
+	p.SetUser(oidctest.User{
+		Subject: "u-42", Email: "anna@corp.example", EmailVerified: true,
+		Extra: map[string]interface{}{"groups": []string{"staff", "event-admins"}, "amr": []string{"pwd", "mfa"}},
+	})
+	c := NewClient(nil)
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, state := login(t, c, cfg, "n-1", challenge)
+	if state != "the-state" || code == "" {
+		t.Fatalf("state %q, code %q", state, code)
+	}
+	tok, err := c.Exchange(context.Background(), cfg, code, verifier, "n-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if tok.Subject != "u-42" || tok.Email != "anna@corp.example" || !tok.EmailVerified {
+		t.Errorf("token = %+v", tok)
+	}
+	if groups := StringsClaim(tok.Claims["groups"]); len(groups) != 2 || groups[1] != "event-admins" {
+		t.Errorf("groups = %v", groups)
+	}
+	if len(tok.AMR) != 2 || tok.AMR[1] != "mfa" {
+		t.Errorf("amr = %v", tok.AMR)
+	}
+
+	// The code is single use.
+	if _, err := c.Exchange(context.Background(), cfg, code, verifier, "n-1"); err == nil {
+		t.Error("second exchange of the same code succeeded")
+	}
+}
+
+func TestExchangeRejectsWrongVerifierAndNonce(t *testing.T) {
+	_, cfg := newProvider(t)
+	c := NewClient(nil)
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ := login(t, c, cfg, "n-1", challenge)
+	if _, err := c.Exchange(context.Background(), cfg, code, verifier+"x", "n-1"); err == nil {
+		t.Error("exchange with the wrong verifier succeeded")
+	}
+
+	code, _ = login(t, c, cfg, "n-1", challenge)
+	if _, err := c.Exchange(context.Background(), cfg, code, verifier, "n-2"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("err = %v, want ErrInvalidToken for a nonce mismatch", err)
+	}
+}
+
+func TestVerifyRejectsOtherAudience(t *testing.T) {
+	p, cfg := newProvider(t)
+	c := NewClient(nil)
+	raw, err := p.IDToken(oidctest.User{Subject: "u-1", Email: "a@corp.example"}, "n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Verify(context.Background(), cfg, raw, "n"); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	other := cfg
+	other.ClientID = "someone-else"
+	if _, err := c.Verify(context.Background(), other, raw, "n"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("err = %v, want ErrInvalidToken for a foreign audience", err)
+	}
+}
+
+func TestDiscoverRejectsIssuerMismatch(t *testing.T) {
+	p, cfg := newProvider(t)
+	p.Issuer = "https://impostor.example"
+	if _, err := NewClient(nil).Discover(context.Background(), cfg.Issuer); err == nil {
+		t.Error("Discover accepted a document for another issuer")
+	}
+}
@@ -1,6 +1,7 @@
-// Package retention removes archived tenants whose retention window has
-// expired (the retention half of P1.4 soft-delete). It is the backend's only
-// background job: one ticker loop started from main.go.
+// Package retention removes data whose retention window has expired:
+// archived tenants (the retention half of P1.4 soft-delete), stored
+// Idempotency-Key responses, finished background jobs and spent credential
+// tokens. Each is one ticker loop started from main.go.
 package retention
 
 import (
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"idento/backend/internal/store"
+	"idento/backend/internal/tracing"
 )
 
 // Store is the slice of the data layer the purge loop needs.
@@ -18,34 +20,50 @@ type Store interface {
 
 // Start launches the purge loop in a goroutine and reports whether it did.
 // No-op when retentionDays <= 0. The first pass runs after initialDelay
-// (lets the server settle at boot), then every interval.
-func Start(s Store, retentionDays int, initialDelay, interval time.Duration) bool {
+// (lets the server settle at boot), then every interval, until ctx is
+// cancelled.
+func Start(ctx context.Context, s Store, retentionDays int, initialDelay, interval time.Duration) bool {
 	if retentionDays <= 0 {
 		log.Println("Tenant retention purge disabled (TENANT_RETENTION_DAYS=0)")
 		return false
 	}
 	log.Printf("Tenant retention purge enabled: archived tenants are deleted after %d days", retentionDays)
+	every(ctx, initialDelay, interval, time.Hour, func(ctx context.Context) {
+		RunOnce(ctx, s, retentionDays)
+	})
+	return true
+}
+
+// every runs pass in a goroutine after initialDelay and then every
+// interval, until ctx is cancelled. Each pass gets timeout, on a context
+// derived from ctx so shutdown also aborts a pass in flight.
+func every(ctx context.Context, initialDelay, interval, timeout time.Duration, pass func(ctx context.Context)) {
 	go func() {
-		runPass := func() {
-			ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
-			defer cancel()
-			RunOnce(ctx, s, retentionDays)
-		}
-		time.Sleep(initialDelay)
-		runPass()
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-		for range ticker.C {
-			runPass()
+		timer := time.NewTimer(initialDelay)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			passCtx, cancel := context.WithTimeout(ctx, timeout)
+			pass(passCtx)
+			cancel()
+			timer.Reset(interval)
 		}
 	}()
-	return true
 }
 
 // RunOnce executes a single purge pass. Idle passes are silent; passes that
 // purge tenants or hit errors log one summary line.
 func RunOnce(ctx context.Context, s Store, retentionDays int) {
+	ctx, span := tracing.Tracer().Start(ctx, "retention.purge_tenants")
 	purged, err := s.PurgeExpiredTenants(ctx, retentionDays)
+	tracing.End(span, err)
 	if err != nil {
 		log.Printf("Tenant retention purge: %d purged, errors: %v", len(purged), err)
 		return
@@ -54,3 +72,91 @@ func RunOnce(ctx context.Context, s Store, retentionDays int) {
 		log.Printf("Tenant retention purge: deleted %d archived tenant(s) past %d-day retention", len(purged), retentionDays)
 	}
 }
+
+// IdempotencyStore is the slice of the data layer the idempotency-key purge
+// loop needs.
+type IdempotencyStore interface {
+	PurgeExpiredIdempotencyKeys(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// StartIdempotencyPurge launches a loop that deletes Idempotency-Key rows
+// older than retention, every interval until ctx is cancelled. Expired rows are already
+// ignored (and taken over) by new claims; this only keeps the table from growing.
+func StartIdempotencyPurge(ctx context.Context, s IdempotencyStore, retention, interval time.Duration) {
+	every(ctx, interval, interval, 10*time.Minute, func(ctx context.Context) {
+		ctx, span := tracing.Tracer().Start(ctx, "retention.purge_idempotency_keys")
+		n, err := s.PurgeExpiredIdempotencyKeys(ctx, retention)
+		tracing.End(span, err)
+		if err != nil {
+			log.Printf("Idempotency key purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Idempotency key purge: deleted %d expired key(s)", n)
+		}
+	})
+}
+
+// JobStore is the slice of the data layer the finished-job purge loop
+// needs.
+type JobStore interface {
+	PurgeFinishedJobs(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// StartJobPurge launches a loop that deletes background jobs (with their
+// artifacts) that finished more than retention ago, every interval until ctx
+// is cancelled.
+func StartJobPurge(ctx context.Context, s JobStore, retention, interval time.Duration) {
+	every(ctx, interval, interval, 10*time.Minute, func(ctx context.Context) {
+		ctx, span := tracing.Tracer().Start(ctx, "retention.purge_jobs")
+		n, err := s.PurgeFinishedJobs(ctx, retention)
+		tracing.End(span, err)
+		if err != nil {
+			log.Printf("Finished job purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Finished job purge: deleted %d job(s)", n)
+		}
+	})
+}
+
+// SessionStore is the slice of the data layer the refresh token purge loop
+// needs.
+type SessionStore interface {
+	PurgeExpiredRefreshTokens(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// StartRefreshTokenPurge launches a loop that deletes refresh tokens that
+// expired more than retention ago, every interval until ctx is cancelled. An expired
+// token is refused either way; this only keeps the table from growing.
+func StartRefreshTokenPurge(ctx context.Context, s SessionStore, retention, interval time.Duration) {
+	every(ctx, interval, interval, 10*time.Minute, func(ctx context.Context) {
+		ctx, span := tracing.Tracer().Start(ctx, "retention.purge_refresh_tokens")
+		n, err := s.PurgeExpiredRefreshTokens(ctx, retention)
+		tracing.End(span, err)
+		if err != nil {
+			log.Printf("Refresh token purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Refresh token purge: deleted %d expired token(s)", n)
+		}
+	})
+}
+
+// UserTokenStore is the slice of the data layer the password reset / email
+// verification token purge loop needs.
+type UserTokenStore interface {
+	PurgeExpiredUserTokens(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// StartUserTokenPurge launches a loop that deletes password reset and email
+// verification tokens that expired more than retention ago, every interval
+// until ctx is cancelled.
+func StartUserTokenPurge(ctx context.Context, s UserTokenStore, retention, interval time.Duration) {
+	every(ctx, interval, interval, 10*time.Minute, func(ctx context.Context) {
+		ctx, span := tracing.Tracer().Start(ctx, "retention.purge_user_tokens")
+		n, err := s.PurgeExpiredUserTokens(ctx, retention)
+		tracing.End(span, err)
+		if err != nil {
+			log.Printf("User token purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("User token purge: deleted %d expired token(s)", n)
+		}
+	})
+}
@@ -21,7 +21,7 @@ func (f *fakePurger) PurgeExpiredTenants(_ context.Context, retentionDays int) (
 
 func TestStartDisabledWhenRetentionZero(t *testing.T) {
 	f := &fakePurger{calls: make(chan int, 1)}
-	if Start(f, 0, time.Millisecond, time.Millisecond) {
+	if Start(t.Context(), f, 0, time.Millisecond, time.Millisecond) {
 		t.Fatal("Start(days=0) = true, want false (disabled)")
 	}
 	select {
@@ -33,7 +33,7 @@ func TestStartDisabledWhenRetentionZero(t *testing.T) {
 
 func TestStartRunsFirstPassAfterInitialDelay(t *testing.T) {
 	f := &fakePurger{calls: make(chan int, 1)}
-	if !Start(f, 90, time.Millisecond, time.Hour) {
+	if !Start(t.Context(), f, 90, time.Millisecond, time.Hour) {
 		t.Fatal("Start(days=90) = false, want true")
 	}
 	select {
@@ -53,3 +53,39 @@ func TestRunOnceSurvivesStoreError(t *testing.T) {
 		t.Errorf("purge called with %d days, want 90", got)
 	}
 }
+
+type fakeIdempotencyPurger struct {
+	calls chan time.Duration
+}
+
+func (f *fakeIdempotencyPurger) PurgeExpiredIdempotencyKeys(_ context.Context, retention time.Duration) (int64, error) {
+	f.calls <- retention
+	return 0, nil
+}
+
+func TestStartIdempotencyPurgeRunsEveryInterval(t *testing.T) {
+	f := &fakeIdempotencyPurger{calls: make(chan time.Duration, 4)}
+	StartIdempotencyPurge(t.Context(), f, 24*time.Hour, time.Millisecond)
+	for i := 0; i < 2; i++ {
+		select {
+		case got := <-f.calls:
+			if got != 24*time.Hour {
+				t.Errorf("purge called with retention %v, want 24h", got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("purge pass %d never ran", i+1)
+		}
+	}
+}
+
+func TestStartIdempotencyPurgeStopsOnCancel(t *testing.T) {
+	f := &fakeIdempotencyPurger{calls: make(chan time.Duration, 1)}
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	StartIdempotencyPurge(ctx, f, 24*time.Hour, time.Millisecond)
+	select {
+	case <-f.calls:
+		t.Fatal("purge ran after its context was cancelled")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
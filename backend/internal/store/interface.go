@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"idento/backend/internal/models"
+	"io"
 	"time"
 
 	"github.com/google/uuid"
@@ -48,10 +49,20 @@ type Store interface {
 
 	// Multi-organization support
 	AddUserToTenant(ctx context.Context, userTenant *models.UserTenant) error
-	RemoveUserFromTenant(ctx context.Context, userID, tenantID uuid.UUID) error
 	GetUserTenants(ctx context.Context, userID uuid.UUID) ([]*models.Tenant, error)
 	GetUserTenantRole(ctx context.Context, userID, tenantID uuid.UUID) (string, error)
+
+	// Member lifecycle. The methods return ErrMemberNotFound for a user
+	// who is not a member of the tenant; deactivating or removing a member
+	// clears their QR token and revokes their sessions and stations in the
+	// tenant, returning the stations revoked.
+	GetTenantMember(ctx context.Context, tenantID, userID uuid.UUID) (*models.User, error)
 	UpdateUserTenantRole(ctx context.Context, userID, tenantID uuid.UUID, role string) error
+	DeactivateMember(ctx context.Context, tenantID, userID uuid.UUID) (int64, []RevokedStation, error)
+	ReactivateMember(ctx context.Context, tenantID, userID uuid.UUID) error
+	RemoveUserFromTenant(ctx context.Context, userID, tenantID uuid.UUID) ([]RevokedStation, error)
+	CreateInvitedUser(ctx context.Context, tenantID uuid.UUID, email, role string) (*models.User, error)
+	AcceptInvitationWithToken(ctx context.Context, tokenHash, passwordHash string) (uuid.UUID, error)
 
 	AssignStaffToEvent(ctx context.Context, assignment *models.EventStaff) error
 	GetEventStaff(ctx context.Context, eventID uuid.UUID) ([]*models.User, error)
@@ -68,6 +79,10 @@ type Store interface {
 	// SoftDeleteEvent marks an event deleted (deleted_at = now()); listings
 	// and direct fetches already exclude soft-deleted rows.
 	SoftDeleteEvent(ctx context.Context, id uuid.UUID) error
+	// CloneEvent copies the tenant's event srcID into clone with what opts
+	// selects, in one transaction; ErrEventNotFound if srcID is not the
+	// tenant's live event.
+	CloneEvent(ctx context.Context, srcID uuid.UUID, clone *models.Event, opts EventCloneOptions) (*models.EventCloneCounts, error)
 
 	// GetEventBadgeTemplate reads the dedicated badge_template/
 	// badge_template_version column pair (P3.1) — never the legacy
@@ -315,6 +330,18 @@ type Store interface {
 	GetMonitorStations(ctx context.Context, eventID uuid.UUID) ([]MonitorStation, error)
 
 	CreateAttendee(ctx context.Context, attendee *models.Attendee) error
+	// ImportAttendee applies one external-import row (insert-only, upsert
+	// or update-only, matched on external_id/email/code) in a transaction
+	// and returns the attendee ID and the models.ImportRow* outcome. It
+	// never writes check-in state. ErrImportNoMatch / ErrImportAmbiguousMatch
+	// report rows that can't be applied.
+	ImportAttendee(ctx context.Context, row AttendeeImportRow, mode, matchOn string) (uuid.UUID, string, error)
+	// ExistingImportMatchKeys reports which match values (ImportMatchValue
+	// form) already identify a live attendee of eventID.
+	ExistingImportMatchKeys(ctx context.Context, eventID uuid.UUID, matchOn string, keys []string) (map[string]bool, error)
+	// PreviewAttendeeImport reports what ImportAttendee would do with each
+	// row, from one read and without writing or locking (import dry runs).
+	PreviewAttendeeImport(ctx context.Context, rows []AttendeeImportRow, mode, matchOn string) ([]ImportOutcome, error)
 	// AnalyzeAttendeesTable runs ANALYZE on the attendees table. A bulk
 	// insert (e.g. a large CSV import) doesn't trigger a synchronous
 	// ANALYZE, and autovacuum's own analyze may not run before the next
@@ -341,6 +368,10 @@ type Store interface {
 	// calling; the PGStore implementation re-checks this at the store
 	// boundary as defense-in-depth, but does not trust the caller blindly.
 	GetAttendeesPage(ctx context.Context, eventID uuid.UUID, f AttendeeFilter) ([]*models.Attendee, int, error)
+	// StreamAttendeeExport calls fn for every attendee matching f (paging
+	// ignored) from a server-side cursor, optionally with per-zone entry
+	// history; see the PGStore implementation.
+	StreamAttendeeExport(ctx context.Context, eventID uuid.UUID, f AttendeeFilter, withZones bool, fn func(*AttendeeExportRow) error) error
 	GetAttendeeByCode(ctx context.Context, eventID uuid.UUID, code string) (*models.Attendee, error)
 	GetAttendeeByID(ctx context.Context, id uuid.UUID) (*models.Attendee, error)
 	// GetAttendeeByIDForTenant scopes the attendee through its event's tenant.
@@ -371,6 +402,20 @@ type Store interface {
 	GetActiveAPIKeys(ctx context.Context) ([]*models.APIKey, error)
 	RevokeAPIKey(ctx context.Context, id uuid.UUID) error
 	UpdateAPIKeyLastUsed(ctx context.Context, id uuid.UUID) error
+	// GetAPIKeyUsage returns the key's metered traffic (usage_logs rows with
+	// resource_type 'api_call', written by middleware.APIKeyAuth) bucketed
+	// by UTC day over [from, to), oldest first. Days with no traffic are
+	// omitted; the slice is empty, never nil, for an unused key.
+	GetAPIKeyUsage(ctx context.Context, keyID uuid.UUID, from, to time.Time) ([]models.APIKeyUsageDay, error)
+
+	// Idempotency keys (middleware.Idempotency): tenant-scoped stored
+	// first responses for retried POSTs. See PGStore for the claim/takeover
+	// rules; ClaimIdempotencyKey returns (nil, nil) when the caller now owns
+	// the key and the existing row otherwise.
+	ClaimIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord, retention, lockTimeout time.Duration) (*models.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, tenantID uuid.UUID, key string, statusCode int, contentType string, body []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, tenantID uuid.UUID, key string) error
+	PurgeExpiredIdempotencyKeys(ctx context.Context, retention time.Duration) (int64, error)
 
 	// Fonts for badge printing (per event)
 	CreateFont(ctx context.Context, font *models.Font) error
@@ -408,6 +453,10 @@ type Store interface {
 	// attribution (ip/user_agent from the HTTP request that caused it).
 	LogAdminAction(ctx context.Context, adminID uuid.UUID, action string, targetType string, targetID uuid.UUID, changes interface{}, ip, userAgent string) error
 	GetAuditLog(ctx context.Context, filters map[string]interface{}, limit int, offset int) ([]*models.AdminAuditLog, int, error)
+	// LogTenantAction appends to the tenant's own audit trail (changes made
+	// inside the organization); GetTenantAuditLog queries it, newest first.
+	LogTenantAction(ctx context.Context, entry *models.TenantAuditEntry) error
+	GetTenantAuditLog(ctx context.Context, tenantID uuid.UUID, f TenantAuditFilter) ([]*models.TenantAuditEntry, int, error)
 
 	// Event Zones
 	CreateEventZone(ctx context.Context, zone *models.EventZone) error
@@ -452,6 +501,7 @@ type Store interface {
 	CreateProvisioningToken(ctx context.Context, tok *models.StationProvisioningToken) error
 	ConsumeProvisioningToken(ctx context.Context, token string) (*models.StationProvisioningToken, error)
 	CreateStation(ctx context.Context, eventID, staffUserID uuid.UUID, deviceInfo map[string]interface{}) (*models.Station, error)
+	GetStationByID(ctx context.Context, id uuid.UUID) (*models.Station, error) // ErrStationNotFound when missing or revoked
 
 	// Mobile offline-sync batch check-in (idempotent by client_uuid)
 	ApplyBatchCheckin(ctx context.Context, eventID, staffUserID uuid.UUID, item *models.BatchCheckinItem) (BatchCheckinOutcome, error)
@@ -569,6 +619,142 @@ type Store interface {
 	// query (Task 4 wires this into the readiness endpoint alongside the
 	// existing checks).
 	TenantHasTestedDefaultPrinter(ctx context.Context, tenantID uuid.UUID) (bool, error)
+
+	// Import mappings: per-tenant column-mapping presets for the
+	// spreadsheet import. Every method is tenant-scoped; a foreign ID
+	// behaves exactly like a missing one.
+	ListImportMappings(ctx context.Context, tenantID uuid.UUID) ([]*models.ImportMapping, error)
+	// GetImportMapping returns (nil, nil) when not found.
+	GetImportMapping(ctx context.Context, tenantID, id uuid.UUID) (*models.ImportMapping, error)
+	CreateImportMapping(ctx context.Context, m *models.ImportMapping) error
+	// UpdateImportMapping and DeleteImportMapping return
+	// ErrImportMappingNotFound on 0 rows.
+	UpdateImportMapping(ctx context.Context, m *models.ImportMapping) error
+	DeleteImportMapping(ctx context.Context, tenantID, id uuid.UUID) error
+
+	// Background jobs (internal/jobs). EnqueueJob is the producer side;
+	// ClaimJob / UpdateJobProgress / CompleteJob / FailJob are the worker
+	// side, every one after the claim guarded on the worker's lease
+	// (ErrJobLost once it is gone). GetJob, ListJobs, GetJobArtifact and
+	// WriteJobArtifact are tenant-scoped; the getters return (nil, nil)
+	// for a foreign or missing ID.
+	EnqueueJob(ctx context.Context, j *models.Job) error
+	ClaimJob(ctx context.Context, workerID string, types []string, lease time.Duration) (*models.Job, error)
+	UpdateJobProgress(ctx context.Context, id uuid.UUID, workerID string, progress models.JobProgress, lease time.Duration) error
+	CompleteJob(ctx context.Context, id uuid.UUID, workerID string, progress models.JobProgress, result json.RawMessage, artifact *models.JobArtifact) error
+	FailJob(ctx context.Context, id uuid.UUID, workerID, msg string) error
+	GetJob(ctx context.Context, tenantID, id uuid.UUID) (*models.Job, error)
+	ListJobs(ctx context.Context, tenantID uuid.UUID, f JobFilter) ([]*models.Job, error)
+	GetJobArtifact(ctx context.Context, tenantID, id uuid.UUID) (*models.JobArtifact, error)
+	WriteJobArtifact(ctx context.Context, tenantID, id uuid.UUID, w io.Writer) error
+	PurgeFinishedJobs(ctx context.Context, retention time.Duration) (int64, error)
+
+	// Ticket emails. Settings getters return (nil, nil) when unset. The
+	// ticket_deliveries rows are the send queue: QueueTicketDeliveries
+	// fills it, ticket_email jobs drain it (ClaimTicketDeliveries, then
+	// RecordTicketDelivery per message) and EnqueueDueTicketEmailJobs
+	// starts jobs for retries coming due.
+	GetTicketEmailSettings(ctx context.Context, eventID uuid.UUID) (*models.TicketEmailSettings, error)
+	UpsertTicketEmailSettings(ctx context.Context, t *models.TicketEmailSettings) error
+	QueueTicketDeliveries(ctx context.Context, eventID uuid.UUID, q TicketDeliveryQuery) (int64, error)
+	ListTicketDeliveries(ctx context.Context, eventID uuid.UUID, status string, limit, offset int) ([]*models.TicketDelivery, int, error)
+	CountTicketDeliveries(ctx context.Context, eventID uuid.UUID) (map[string]int, error)
+	GetTicketDelivery(ctx context.Context, attendeeID uuid.UUID) (*models.TicketDelivery, error)
+	CountDueTicketDeliveries(ctx context.Context, eventID uuid.UUID) (int, error)
+	ClaimTicketDeliveries(ctx context.Context, eventID uuid.UUID, limit int, lease time.Duration) ([]*models.TicketDelivery, error)
+	RecordTicketDelivery(ctx context.Context, d *models.TicketDelivery) error
+	EnqueueDueTicketEmailJobs(ctx context.Context) (int64, error)
+
+	// PDF ticket layout (events.ticket_layout), stored as JSON like the
+	// check-in settings: GetTicketLayout returns (nil, nil) when unset and
+	// UpdateTicketLayout ErrEventNotFound when the event is gone.
+	GetTicketLayout(ctx context.Context, eventID uuid.UUID) (json.RawMessage, error)
+	UpdateTicketLayout(ctx context.Context, eventID uuid.UUID, layout json.RawMessage) error
+
+	// Wallet passes. GetTenantWalletSettings returns nil for a tenant that
+	// never configured a wallet. EnsureWalletPass creates an attendee's
+	// Apple pass record (keeping its auth token when it exists) and
+	// GetWalletPass returns nil for an unknown pass. The PassKit web
+	// service registers devices against passes; GetWalletDevicePasses
+	// lists a device's passes changed after since (all when nil) and the
+	// latest change among them. TouchWalletPasses marks an event's passes
+	// changed so devices fetch them again.
+	GetTenantWalletSettings(ctx context.Context, tenantID uuid.UUID) (*models.TenantWalletSettings, error)
+	UpsertTenantWalletSettings(ctx context.Context, s *models.TenantWalletSettings) error
+	EnsureWalletPass(ctx context.Context, attendeeID, eventID uuid.UUID, passTypeID string) (*models.WalletPass, error)
+	GetWalletPass(ctx context.Context, passTypeID string, serial uuid.UUID) (*models.WalletPass, error)
+	RegisterWalletDevice(ctx context.Context, deviceID string, serial uuid.UUID, pushToken string) (created bool, err error)
+	UnregisterWalletDevice(ctx context.Context, deviceID string, serial uuid.UUID) (bool, error)
+	GetWalletDevicePasses(ctx context.Context, deviceID, passTypeID string, since *time.Time) ([]uuid.UUID, time.Time, error)
+	TouchWalletPasses(ctx context.Context, eventID uuid.UUID) (int64, error)
+	GetWalletPushTargets(ctx context.Context, eventID uuid.UUID) ([]models.WalletPushTarget, error)
+	DeleteWalletPushTokens(ctx context.Context, tokens []string) (int64, error)
+
+	// Sessions: rotating refresh tokens and the token versions the JWT
+	// middleware checks access tokens against. Bumping a version (user or
+	// station) revokes every access token issued under the old one.
+	GetTokenVersions(ctx context.Context, userID uuid.UUID, stationID *uuid.UUID) (userVersion, stationVersion int, err error)
+	SessionActive(ctx context.Context, userID, familyID uuid.UUID) (bool, error)
+	CreateRefreshToken(ctx context.Context, rt *models.RefreshToken) error
+	RotateRefreshToken(ctx context.Context, oldHash string, next *models.RefreshToken) error
+	RevokeRefreshTokenFamily(ctx context.Context, userID, familyID uuid.UUID) error
+	RevokeUserSessions(ctx context.Context, userID uuid.UUID) (int64, error)
+	RevokeStationSessions(ctx context.Context, eventID, stationID uuid.UUID) (int64, error)
+	PurgeExpiredRefreshTokens(ctx context.Context, retention time.Duration) (int64, error)
+
+	// Single-use tokens mailed for password reset and email verification.
+	// The *WithToken methods spend a token and apply its effect in one
+	// transaction, returning ErrUserTokenInvalid for an unknown, expired,
+	// used or superseded token.
+	CreateUserToken(ctx context.Context, userID uuid.UUID, purpose, tokenHash string, expiresAt time.Time) error
+	ResetPasswordWithToken(ctx context.Context, tokenHash, passwordHash string) (uuid.UUID, error)
+	VerifyEmailWithToken(ctx context.Context, tokenHash string) (uuid.UUID, error)
+	PurgeExpiredUserTokens(ctx context.Context, retention time.Duration) (int64, error)
+
+	// TOTP two-factor authentication. A secret is set up pending and
+	// enabled by the first valid code; UseTOTPStep and UseRecoveryCode
+	// report false for a replayed code or a spent recovery code.
+	SetPendingTOTPSecret(ctx context.Context, userID uuid.UUID, secret string) error
+	GetTOTPSecret(ctx context.Context, userID uuid.UUID) (secret string, enabled bool, err error)
+	EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, recoveryCodeHashes []string) error
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error)
+	CountUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	DisableTOTP(ctx context.Context, userID uuid.UUID) error
+	GetTenantTwoFactorRequired(ctx context.Context, tenantID uuid.UUID) (bool, error)
+	// Login challenges between the password and the second factor: each
+	// takes a limited number of codes and signs in once, and repeated
+	// wrong codes lock the user's second step for a while.
+	CreateTwoFactorChallenge(ctx context.Context, userID uuid.UUID, expiresAt time.Time) (uuid.UUID, error)
+	AttemptTwoFactorChallenge(ctx context.Context, id, userID uuid.UUID) error
+	UseTwoFactorChallenge(ctx context.Context, id uuid.UUID) error
+
+	// Per-tenant OpenID Connect single sign-on. GetTenantOIDCProvider
+	// returns nil for a tenant without one; LinkOIDCIdentity resolves a
+	// verified provider account to a user and membership (see
+	// ErrOIDCEmailUnverified, ErrOIDCAccountConflict,
+	// ErrOIDCMemberDeactivated).
+	GetTenantOIDCProvider(ctx context.Context, tenantID uuid.UUID) (*models.TenantOIDCProvider, error)
+	UpsertTenantOIDCProvider(ctx context.Context, p *models.TenantOIDCProvider) error
+	DeleteTenantOIDCProvider(ctx context.Context, tenantID uuid.UUID) (bool, error)
+	FindSSOTenantsByEmailDomain(ctx context.Context, domain string) ([]models.SSOTenant, error)
+	CreateOIDCLoginRequest(ctx context.Context, r *models.OIDCLoginRequest) error
+	ConsumeOIDCLoginRequest(ctx context.Context, stateHash string) (*models.OIDCLoginRequest, error)
+	LinkOIDCIdentity(ctx context.Context, id models.OIDCIdentity) (*models.User, string, error)
+
+	// Tenant-defined roles. GetTenantRole and GetMemberCustomRole return
+	// nil when there is none; Update/Delete return ErrTenantRoleNotFound,
+	// and a duplicate name surfaces as the raw unique violation (23505).
+	// SetMemberCustomRole reports whether the user is a member of the
+	// tenant.
+	ListTenantRoles(ctx context.Context, tenantID uuid.UUID) ([]*models.TenantRole, error)
+	GetTenantRole(ctx context.Context, tenantID, roleID uuid.UUID) (*models.TenantRole, error)
+	CreateTenantRole(ctx context.Context, r *models.TenantRole) error
+	UpdateTenantRole(ctx context.Context, r *models.TenantRole) error
+	DeleteTenantRole(ctx context.Context, tenantID, roleID uuid.UUID) error
+	SetMemberCustomRole(ctx context.Context, tenantID, userID uuid.UUID, roleID *uuid.UUID) (bool, error)
+	GetMemberCustomRole(ctx context.Context, userID, tenantID uuid.UUID) (*models.TenantRole, error)
 }
 
 // ErrDeviceNotFound is the equipment registry's not-found sentinel —
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"idento/backend/internal/models"
+	"idento/backend/internal/tracing"
 	"idento/backend/migrations"
 	"io/fs"
 	"log"
@@ -19,6 +20,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -26,9 +28,11 @@ import (
 // interface so tests can substitute an in-memory mock (pgxmock).
 type dbConn interface {
 	Begin(ctx context.Context) (pgx.Tx, error)
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
 	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
 	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
 	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Ping(ctx context.Context) error
 	Close()
 }
 
@@ -37,7 +41,13 @@ type PGStore struct {
 }
 
 func NewPGStore(dbURL string) (*PGStore, error) {
-	pool, err := pgxpool.New(context.Background(), dbURL)
+	cfg, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ConnConfig.Tracer = tracing.QueryTracer{}
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -53,6 +63,86 @@ func (s *PGStore) Close() {
 	s.db.Close()
 }
 
+// PoolStat reports the connection pool's statistics for /metrics, or nil
+// when the store isn't backed by a real pool (pgxmock in tests).
+func (s *PGStore) PoolStat() *pgxpool.Stat {
+	if pool, ok := s.db.(*pgxpool.Pool); ok {
+		return pool.Stat()
+	}
+	return nil
+}
+
+// embeddedMigrationFiles lists the migrations compiled into the binary, in
+// the order they apply.
+func embeddedMigrationFiles() ([]string, error) {
+	entries, err := fs.ReadDir(migrations.Files, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		files = append(files, entry.Name())
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// migrationVersion is a migration file's schema_migrations key, its numeric
+// prefix ("000001_init_schema.up.sql" -> "000001").
+func migrationVersion(filename string) string {
+	return strings.Split(filename, "_")[0]
+}
+
+// Ping checks the database answers, for the readiness probe.
+func (s *PGStore) Ping(ctx context.Context) error {
+	return s.db.Ping(ctx)
+}
+
+// MigrationStatus compares schema_migrations with the migrations embedded
+// in this binary.
+type MigrationStatus struct {
+	// Latest is the newest embedded version, Applied the newest recorded
+	// one ("" before any migration ran).
+	Latest  string
+	Applied string
+	// Pending lists embedded versions not recorded as applied, oldest
+	// first.
+	Pending []string
+}
+
+// GetMigrationStatus reports which embedded migrations the database has
+// not applied: a replica running against a schema older than its code.
+func (s *PGStore) GetMigrationStatus(ctx context.Context) (*MigrationStatus, error) {
+	files, err := embeddedMigrationFiles()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[string]bool, len(applied))
+	st := &MigrationStatus{}
+	for _, v := range applied {
+		done[v] = true
+		if v > st.Applied {
+			st.Applied = v
+		}
+	}
+	for _, f := range files {
+		v := migrationVersion(f)
+		st.Latest = v
+		if !done[v] {
+			st.Pending = append(st.Pending, v)
+		}
+	}
+	return st, nil
+}
+
 func (s *PGStore) RunMigrations() error {
 	log.Printf("Running migrations...")
 	// Create schema_migrations table if not exists
@@ -66,17 +156,10 @@ func (s *PGStore) RunMigrations() error {
 		return fmt.Errorf("failed to create schema_migrations table: %w", err)
 	}
 
-	// Read migration files from the embedded FS (binary is self-contained).
-	entries, err := fs.ReadDir(migrations.Files, ".")
+	migrationFiles, err := embeddedMigrationFiles()
 	if err != nil {
-		return fmt.Errorf("failed to read embedded migrations: %w", err)
-	}
-
-	var migrationFiles []string
-	for _, entry := range entries {
-		migrationFiles = append(migrationFiles, entry.Name())
+		return err
 	}
-	sort.Strings(migrationFiles)
 
 	// schema_migrations is keyed on the numeric version prefix alone, so two
 	// files sharing a prefix would make the second one silently no-op (it
@@ -87,8 +170,7 @@ func (s *PGStore) RunMigrations() error {
 
 	appliedCount := 0
 	for _, filename := range migrationFiles {
-		// Extract version from filename (e.g., "000001_init_schema.up.sql" -> "000001")
-		version := strings.Split(filename, "_")[0]
+		version := migrationVersion(filename)
 
 		// Check if already applied — skip if yes
 		var exists bool
@@ -139,7 +221,7 @@ func (s *PGStore) RunMigrations() error {
 func duplicateMigrationVersion(sortedFilenames []string) (first, second, version string, ok bool) {
 	var prevVersion, prevFilename string
 	for _, filename := range sortedFilenames {
-		v := strings.Split(filename, "_")[0]
+		v := migrationVersion(filename)
 		if v == prevVersion {
 			return prevFilename, filename, v, true
 		}
@@ -243,8 +325,8 @@ func (s *PGStore) ProvisionTenantWithAdmin(ctx context.Context, tenantName, emai
 	user := &models.User{Email: email}
 	var storedHash string
 	err = tx.QueryRow(ctx,
-		`SELECT id, tenant_id, role, is_super_admin, password_hash, created_at, updated_at FROM users WHERE email = $1`, email).
-		Scan(&user.ID, &user.TenantID, &user.Role, &user.IsSuperAdmin, &storedHash, &user.CreatedAt, &user.UpdatedAt)
+		`SELECT id, tenant_id, role, is_super_admin, password_hash, email_verified_at, totp_enabled_at IS NOT NULL, created_at, updated_at FROM users WHERE email = $1`, email).
+		Scan(&user.ID, &user.TenantID, &user.Role, &user.IsSuperAdmin, &storedHash, &user.EmailVerifiedAt, &user.TwoFactorEnabled, &user.CreatedAt, &user.UpdatedAt)
 	switch {
 	case err == pgx.ErrNoRows:
 		user.TenantID = tenant.ID
@@ -354,10 +436,10 @@ func (s *PGStore) CreateUser(ctx context.Context, user *models.User) error {
 
 func (s *PGStore) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	var u models.User
-	query := `SELECT id, tenant_id, email, password_hash, role, is_super_admin, qr_token, qr_token_created_at, created_at, updated_at 
+	query := `SELECT id, tenant_id, email, password_hash, role, is_super_admin, qr_token, qr_token_created_at, email_verified_at, totp_enabled_at IS NOT NULL, created_at, updated_at 
 			  FROM users WHERE email = $1`
 	err := s.db.QueryRow(ctx, query, email).Scan(
-		&u.ID, &u.TenantID, &u.Email, &u.PasswordHash, &u.Role, &u.IsSuperAdmin, &u.QRToken, &u.QRTokenCreatedAt, &u.CreatedAt, &u.UpdatedAt,
+		&u.ID, &u.TenantID, &u.Email, &u.PasswordHash, &u.Role, &u.IsSuperAdmin, &u.QRToken, &u.QRTokenCreatedAt, &u.EmailVerifiedAt, &u.TwoFactorEnabled, &u.CreatedAt, &u.UpdatedAt,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -378,10 +460,10 @@ func (s *PGStore) HasAnyUsers(ctx context.Context) (bool, error) {
 
 func (s *PGStore) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	var u models.User
-	query := `SELECT id, tenant_id, email, password_hash, role, is_super_admin, qr_token, qr_token_created_at, created_at, updated_at 
+	query := `SELECT id, tenant_id, email, password_hash, role, is_super_admin, qr_token, qr_token_created_at, email_verified_at, totp_enabled_at IS NOT NULL, created_at, updated_at 
 			  FROM users WHERE id = $1`
 	err := s.db.QueryRow(ctx, query, id).Scan(
-		&u.ID, &u.TenantID, &u.Email, &u.PasswordHash, &u.Role, &u.IsSuperAdmin, &u.QRToken, &u.QRTokenCreatedAt, &u.CreatedAt, &u.UpdatedAt,
+		&u.ID, &u.TenantID, &u.Email, &u.PasswordHash, &u.Role, &u.IsSuperAdmin, &u.QRToken, &u.QRTokenCreatedAt, &u.EmailVerifiedAt, &u.TwoFactorEnabled, &u.CreatedAt, &u.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -389,9 +471,13 @@ func (s *PGStore) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User,
 	return &u, nil
 }
 
+// GetUsersByTenantID lists the tenant's members, newest first, with their
+// role in the tenant and membership status (models.MemberStatus*).
 func (s *PGStore) GetUsersByTenantID(ctx context.Context, tenantID uuid.UUID) ([]*models.User, error) {
-	query := `SELECT id, tenant_id, email, role, is_super_admin, qr_token, qr_token_created_at, created_at, updated_at 
-			  FROM users WHERE tenant_id = $1 ORDER BY created_at DESC`
+	query := `SELECT u.id, u.tenant_id, u.email, ut.role, u.is_super_admin, u.qr_token, u.qr_token_created_at,
+	                 ` + memberStatusSQL + `, ut.deactivated_at, u.created_at, u.updated_at
+			  FROM user_tenants ut JOIN users u ON u.id = ut.user_id
+			  WHERE ut.tenant_id = $1 ORDER BY ut.joined_at DESC`
 	rows, err := s.db.Query(ctx, query, tenantID)
 	if err != nil {
 		return nil, err
@@ -401,20 +487,20 @@ func (s *PGStore) GetUsersByTenantID(ctx context.Context, tenantID uuid.UUID) ([
 	users := []*models.User{}
 	for rows.Next() {
 		var u models.User
-		if err := rows.Scan(&u.ID, &u.TenantID, &u.Email, &u.Role, &u.IsSuperAdmin, &u.QRToken, &u.QRTokenCreatedAt, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		if err := rows.Scan(&u.ID, &u.TenantID, &u.Email, &u.Role, &u.IsSuperAdmin, &u.QRToken, &u.QRTokenCreatedAt, &u.Status, &u.DeactivatedAt, &u.CreatedAt, &u.UpdatedAt); err != nil {
 			return nil, err
 		}
 		users = append(users, &u)
 	}
-	return users, nil
+	return users, rows.Err()
 }
 
 func (s *PGStore) GetUserByQRToken(ctx context.Context, token string) (*models.User, error) {
 	var u models.User
-	query := `SELECT id, tenant_id, email, password_hash, role, is_super_admin, qr_token, qr_token_created_at, created_at, updated_at 
+	query := `SELECT id, tenant_id, email, password_hash, role, is_super_admin, qr_token, qr_token_created_at, email_verified_at, totp_enabled_at IS NOT NULL, created_at, updated_at 
 			  FROM users WHERE qr_token = $1`
 	err := s.db.QueryRow(ctx, query, token).Scan(
-		&u.ID, &u.TenantID, &u.Email, &u.PasswordHash, &u.Role, &u.IsSuperAdmin, &u.QRToken, &u.QRTokenCreatedAt, &u.CreatedAt, &u.UpdatedAt,
+		&u.ID, &u.TenantID, &u.Email, &u.PasswordHash, &u.Role, &u.IsSuperAdmin, &u.QRToken, &u.QRTokenCreatedAt, &u.EmailVerifiedAt, &u.TwoFactorEnabled, &u.CreatedAt, &u.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -828,19 +914,19 @@ func (s *PGStore) GetCheckinStationByID(ctx context.Context, id uuid.UUID) (*mod
 
 // checkinAttendeeColumnsSQL is the plain (non-joined) attendee column list
 // (in scan order) shared by CheckInAttendee's and UndoCheckin's guarded
-// UPDATE ... RETURNING clauses — the same 19 columns as
+// UPDATE ... RETURNING clauses — the same 20 columns as
 // GetAttendeeByID/GetAttendeeByCode. It deliberately excludes
 // checked_in_by_email: attendees has no such COLUMN — that field is always
 // derived from users.email via checked_in_by (see attendeeListColumnsSQL),
 // never persisted, so a RETURNING clause can't produce it.
-const checkinAttendeeColumnsSQL = `id, event_id, first_name, last_name, email, company, position, code, checkin_status, checked_in_at, checked_in_by, checked_in_device_number, checked_in_point_name, printed_count, custom_fields, blocked, block_reason, created_at, updated_at`
+const checkinAttendeeColumnsSQL = `id, event_id, first_name, last_name, email, company, position, code, external_id, checkin_status, checked_in_at, checked_in_by, checked_in_device_number, checked_in_point_name, printed_count, custom_fields, blocked, block_reason, created_at, updated_at`
 
 // scanCheckinAttendeeRow scans one row shaped by checkinAttendeeColumnsSQL
 // into a fresh *models.Attendee, unmarshaling custom_fields.
 func scanCheckinAttendeeRow(row pgx.Row) (*models.Attendee, error) {
 	var a models.Attendee
 	var customFieldsJSON []byte
-	if err := row.Scan(&a.ID, &a.EventID, &a.FirstName, &a.LastName, &a.Email, &a.Company, &a.Position, &a.Code,
+	if err := row.Scan(&a.ID, &a.EventID, &a.FirstName, &a.LastName, &a.Email, &a.Company, &a.Position, &a.Code, &a.ExternalID,
 		&a.CheckinStatus, &a.CheckedInAt, &a.CheckedInBy, &a.CheckedInDeviceNumber, &a.CheckedInPointName,
 		&a.PrintedCount, &customFieldsJSON, &a.Blocked, &a.BlockReason, &a.CreatedAt, &a.UpdatedAt); err != nil {
 		return nil, err
@@ -862,7 +948,7 @@ func scanCheckinAttendeeRow(row pgx.Row) (*models.Attendee, error) {
 func scanAttendeeByEmailJoinRow(row pgx.Row) (*models.Attendee, error) {
 	var a models.Attendee
 	var customFieldsJSON []byte
-	if err := row.Scan(&a.ID, &a.EventID, &a.FirstName, &a.LastName, &a.Email, &a.Company, &a.Position, &a.Code,
+	if err := row.Scan(&a.ID, &a.EventID, &a.FirstName, &a.LastName, &a.Email, &a.Company, &a.Position, &a.Code, &a.ExternalID,
 		&a.CheckinStatus, &a.CheckedInAt, &a.CheckedInBy, &a.CheckedInDeviceNumber, &a.CheckedInPointName,
 		&a.PrintedCount, &customFieldsJSON, &a.Blocked, &a.BlockReason, &a.CreatedAt, &a.UpdatedAt, &a.CheckedInByEmail); err != nil {
 		return nil, err
@@ -1086,6 +1172,11 @@ func checkInAttendeeAttempt(ctx context.Context, tx pgx.Tx, eventID, attendeeID
 // but with a RETURNING clause so the full row comes back in the same round
 // trip as the write.
 func (s *PGStore) CheckInAttendee(ctx context.Context, eventID, attendeeID uuid.UUID, stationID *uuid.UUID, staffUserID uuid.UUID, staffEmail, stationName string) (string, *models.Attendee, error) {
+	// One span around the transaction so its queries, and how many
+	// attempts the retry loop below took, read as one unit in a trace.
+	ctx, span := tracing.Tracer().Start(ctx, "store.CheckInAttendee")
+	defer span.End()
+
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
 		return "", nil, err
@@ -1113,6 +1204,7 @@ func (s *PGStore) CheckInAttendee(ctx context.Context, eventID, attendeeID uuid.
 		if err != nil {
 			return "", nil, err
 		}
+		span.SetAttributes(attribute.Int("idento.checkin.attempts", attempt+1), attribute.String("idento.checkin.outcome", outcome))
 		if outcome != "conflict" {
 			break
 		}
@@ -1325,18 +1417,20 @@ func attendeeFilterClause(eventID uuid.UUID, code, search string, zoneID *uuid.U
 // GetAttendeesByEventID and GetAttendeesPage, including the LEFT JOINed
 // checked_in_by_email.
 const attendeeListColumnsSQL = `
-	a.id, a.event_id, a.first_name, a.last_name, a.email, a.company, a.position, a.code,
+	a.id, a.event_id, a.first_name, a.last_name, a.email, a.company, a.position, a.code, a.external_id,
 	a.checkin_status, a.checked_in_at, a.checked_in_by, a.checked_in_device_number, a.checked_in_point_name, a.printed_count, a.custom_fields,
 	a.blocked, a.block_reason, a.created_at, a.updated_at,
 	u.email as checked_in_by_email
 `
 
 // scanAttendeeRow scans one row shaped by attendeeListColumnsSQL, shared by
-// GetAttendeesByEventID and GetAttendeesPage.
-func scanAttendeeRow(rows pgx.Rows) (*models.Attendee, error) {
+// GetAttendeesByEventID, GetAttendeesPage and StreamAttendeeExport. extra
+// receives any columns the query selects after the shared list.
+func scanAttendeeRow(rows pgx.Rows, extra ...interface{}) (*models.Attendee, error) {
 	var a models.Attendee
 	var customFieldsJSON []byte
-	if err := rows.Scan(&a.ID, &a.EventID, &a.FirstName, &a.LastName, &a.Email, &a.Company, &a.Position, &a.Code, &a.CheckinStatus, &a.CheckedInAt, &a.CheckedInBy, &a.CheckedInDeviceNumber, &a.CheckedInPointName, &a.PrintedCount, &customFieldsJSON, &a.Blocked, &a.BlockReason, &a.CreatedAt, &a.UpdatedAt, &a.CheckedInByEmail); err != nil {
+	dest := []interface{}{&a.ID, &a.EventID, &a.FirstName, &a.LastName, &a.Email, &a.Company, &a.Position, &a.Code, &a.ExternalID, &a.CheckinStatus, &a.CheckedInAt, &a.CheckedInBy, &a.CheckedInDeviceNumber, &a.CheckedInPointName, &a.PrintedCount, &customFieldsJSON, &a.Blocked, &a.BlockReason, &a.CreatedAt, &a.UpdatedAt, &a.CheckedInByEmail}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
 		return nil, fmt.Errorf("scan attendee row: %w", err)
 	}
 	if len(customFieldsJSON) > 0 && string(customFieldsJSON) != "null" {
@@ -1452,10 +1546,10 @@ func (s *PGStore) GetAttendeesPage(ctx context.Context, eventID uuid.UUID, f Att
 func (s *PGStore) GetAttendeeByCode(ctx context.Context, eventID uuid.UUID, code string) (*models.Attendee, error) {
 	var a models.Attendee
 	var customFieldsJSON []byte
-	query := `SELECT id, event_id, first_name, last_name, email, company, position, code, checkin_status, checked_in_at, checked_in_by, checked_in_device_number, checked_in_point_name, printed_count, custom_fields, blocked, block_reason, created_at, updated_at
+	query := `SELECT id, event_id, first_name, last_name, email, company, position, code, external_id, checkin_status, checked_in_at, checked_in_by, checked_in_device_number, checked_in_point_name, printed_count, custom_fields, blocked, block_reason, created_at, updated_at
 			  FROM attendees WHERE event_id = $1 AND code = $2 AND deleted_at IS NULL`
 	err := s.db.QueryRow(ctx, query, eventID, code).Scan(
-		&a.ID, &a.EventID, &a.FirstName, &a.LastName, &a.Email, &a.Company, &a.Position, &a.Code, &a.CheckinStatus, &a.CheckedInAt, &a.CheckedInBy, &a.CheckedInDeviceNumber, &a.CheckedInPointName, &a.PrintedCount, &customFieldsJSON, &a.Blocked, &a.BlockReason, &a.CreatedAt, &a.UpdatedAt,
+		&a.ID, &a.EventID, &a.FirstName, &a.LastName, &a.Email, &a.Company, &a.Position, &a.Code, &a.ExternalID, &a.CheckinStatus, &a.CheckedInAt, &a.CheckedInBy, &a.CheckedInDeviceNumber, &a.CheckedInPointName, &a.PrintedCount, &customFieldsJSON, &a.Blocked, &a.BlockReason, &a.CreatedAt, &a.UpdatedAt,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -1474,10 +1568,10 @@ func (s *PGStore) GetAttendeeByCode(ctx context.Context, eventID uuid.UUID, code
 func (s *PGStore) GetAttendeeByID(ctx context.Context, id uuid.UUID) (*models.Attendee, error) {
 	var a models.Attendee
 	var customFieldsJSON []byte
-	query := `SELECT id, event_id, first_name, last_name, email, company, position, code, checkin_status, checked_in_at, checked_in_by, checked_in_device_number, checked_in_point_name, printed_count, custom_fields, blocked, block_reason, created_at, updated_at
+	query := `SELECT id, event_id, first_name, last_name, email, company, position, code, external_id, checkin_status, checked_in_at, checked_in_by, checked_in_device_number, checked_in_point_name, printed_count, custom_fields, blocked, block_reason, created_at, updated_at
 			  FROM attendees WHERE id = $1 AND deleted_at IS NULL`
 	err := s.db.QueryRow(ctx, query, id).Scan(
-		&a.ID, &a.EventID, &a.FirstName, &a.LastName, &a.Email, &a.Company, &a.Position, &a.Code, &a.CheckinStatus, &a.CheckedInAt, &a.CheckedInBy, &a.CheckedInDeviceNumber, &a.CheckedInPointName, &a.PrintedCount, &customFieldsJSON, &a.Blocked, &a.BlockReason, &a.CreatedAt, &a.UpdatedAt,
+		&a.ID, &a.EventID, &a.FirstName, &a.LastName, &a.Email, &a.Company, &a.Position, &a.Code, &a.ExternalID, &a.CheckinStatus, &a.CheckedInAt, &a.CheckedInBy, &a.CheckedInDeviceNumber, &a.CheckedInPointName, &a.PrintedCount, &customFieldsJSON, &a.Blocked, &a.BlockReason, &a.CreatedAt, &a.UpdatedAt,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -1580,16 +1674,16 @@ func (s *PGStore) IncrementAttendeePrintedCount(ctx context.Context, attendeeID
 
 // API Keys methods
 func (s *PGStore) CreateAPIKey(ctx context.Context, apiKey *models.APIKey) error {
-	query := `INSERT INTO api_keys (id, event_id, name, key_hash, key_hash_bcrypt, key_preview, expires_at, created_at)
-			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	query := `INSERT INTO api_keys (id, event_id, name, key_hash, key_hash_bcrypt, key_preview, rate_limit_per_minute, expires_at, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
 	_, err := s.db.Exec(ctx, query,
-		apiKey.ID, apiKey.EventID, apiKey.Name, apiKey.KeyHash, apiKey.KeyHashBcrypt, apiKey.KeyPreview, apiKey.ExpiresAt, apiKey.CreatedAt,
+		apiKey.ID, apiKey.EventID, apiKey.Name, apiKey.KeyHash, apiKey.KeyHashBcrypt, apiKey.KeyPreview, apiKey.RateLimitPerMinute, apiKey.ExpiresAt, apiKey.CreatedAt,
 	)
 	return err
 }
 
 func (s *PGStore) GetAPIKeysByEventID(ctx context.Context, eventID uuid.UUID) ([]*models.APIKey, error) {
-	query := `SELECT id, event_id, name, key_hash, key_hash_bcrypt, key_preview, expires_at, last_used_at, revoked_at, created_at
+	query := `SELECT id, event_id, name, key_hash, key_hash_bcrypt, key_preview, rate_limit_per_minute, expires_at, last_used_at, revoked_at, created_at
 			  FROM api_keys
 			  WHERE event_id = $1
 			  ORDER BY created_at DESC`
@@ -1604,7 +1698,7 @@ func (s *PGStore) GetAPIKeysByEventID(ctx context.Context, eventID uuid.UUID) ([
 	for rows.Next() {
 		var key models.APIKey
 		if err := rows.Scan(&key.ID, &key.EventID, &key.Name, &key.KeyHash, &key.KeyHashBcrypt, &key.KeyPreview,
-			&key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.CreatedAt); err != nil {
+			&key.RateLimitPerMinute, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.CreatedAt); err != nil {
 			return nil, err
 		}
 		keys = append(keys, &key)
@@ -1613,14 +1707,14 @@ func (s *PGStore) GetAPIKeysByEventID(ctx context.Context, eventID uuid.UUID) ([
 }
 
 func (s *PGStore) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
-	query := `SELECT id, event_id, name, key_hash, key_hash_bcrypt, key_preview, expires_at, last_used_at, revoked_at, created_at
+	query := `SELECT id, event_id, name, key_hash, key_hash_bcrypt, key_preview, rate_limit_per_minute, expires_at, last_used_at, revoked_at, created_at
 			  FROM api_keys
 			  WHERE key_hash = $1`
 
 	var key models.APIKey
 	err := s.db.QueryRow(ctx, query, keyHash).Scan(
 		&key.ID, &key.EventID, &key.Name, &key.KeyHash, &key.KeyHashBcrypt, &key.KeyPreview,
-		&key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.CreatedAt,
+		&key.RateLimitPerMinute, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.CreatedAt,
 	)
 	if err == pgx.ErrNoRows {
 		return nil, fmt.Errorf("API key not found")
@@ -1632,12 +1726,16 @@ func (s *PGStore) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.
 }
 
 // GetActiveAPIKeys returns all non-revoked, non-expired API keys (with key_hash_bcrypt set) for verification.
+// Each key carries its event's tenant_id so APIKeyAuth can meter usage
+// without a second lookup per request.
 func (s *PGStore) GetActiveAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
-	query := `SELECT id, event_id, name, key_hash, key_hash_bcrypt, key_preview, expires_at, last_used_at, revoked_at, created_at
-			  FROM api_keys
-			  WHERE revoked_at IS NULL
-			    AND (expires_at IS NULL OR expires_at > NOW())
-			    AND key_hash_bcrypt IS NOT NULL`
+	query := `SELECT k.id, k.event_id, e.tenant_id, k.name, k.key_hash, k.key_hash_bcrypt, k.key_preview, k.rate_limit_per_minute,
+			         k.expires_at, k.last_used_at, k.revoked_at, k.created_at
+			  FROM api_keys k
+			  JOIN events e ON e.id = k.event_id
+			  WHERE k.revoked_at IS NULL
+			    AND (k.expires_at IS NULL OR k.expires_at > NOW())
+			    AND k.key_hash_bcrypt IS NOT NULL`
 
 	rows, err := s.db.Query(ctx, query)
 	if err != nil {
@@ -1648,8 +1746,8 @@ func (s *PGStore) GetActiveAPIKeys(ctx context.Context) ([]*models.APIKey, error
 	var keys []*models.APIKey
 	for rows.Next() {
 		var key models.APIKey
-		if err := rows.Scan(&key.ID, &key.EventID, &key.Name, &key.KeyHash, &key.KeyHashBcrypt, &key.KeyPreview,
-			&key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.CreatedAt); err != nil {
+		if err := rows.Scan(&key.ID, &key.EventID, &key.TenantID, &key.Name, &key.KeyHash, &key.KeyHashBcrypt, &key.KeyPreview,
+			&key.RateLimitPerMinute, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.CreatedAt); err != nil {
 			return nil, err
 		}
 		keys = append(keys, &key)
@@ -1669,6 +1767,35 @@ func (s *PGStore) UpdateAPIKeyLastUsed(ctx context.Context, id uuid.UUID) error
 	return err
 }
 
+// GetAPIKeyUsage buckets a key's metered 'api_call' usage_logs rows by UTC
+// day over [from, to), splitting allowed requests from rate-limited ones.
+// Days without traffic are omitted; the result is never nil.
+func (s *PGStore) GetAPIKeyUsage(ctx context.Context, keyID uuid.UUID, from, to time.Time) ([]models.APIKeyUsageDay, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT to_char(date_trunc('day', logged_at), 'YYYY-MM-DD') AS day,
+		       COALESCE(SUM(quantity) FILTER (WHERE action = 'request'), 0),
+		       COALESCE(SUM(quantity) FILTER (WHERE action = 'throttled'), 0)
+		FROM usage_logs
+		WHERE resource_type = 'api_call' AND resource_id = $1
+		  AND logged_at >= $2 AND logged_at < $3
+		GROUP BY day
+		ORDER BY day`, keyID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []models.APIKeyUsageDay{}
+	for rows.Next() {
+		var d models.APIKeyUsageDay
+		if err := rows.Scan(&d.Date, &d.Requests, &d.Throttled); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
 // Font methods (per event)
 
 func (s *PGStore) CreateFont(ctx context.Context, font *models.Font) error {
@@ -1741,17 +1868,13 @@ func (s *PGStore) AddUserToTenant(ctx context.Context, userTenant *models.UserTe
 	return err
 }
 
-func (s *PGStore) RemoveUserFromTenant(ctx context.Context, userID, tenantID uuid.UUID) error {
-	query := `DELETE FROM user_tenants WHERE user_id = $1 AND tenant_id = $2`
-	_, err := s.db.Exec(ctx, query, userID, tenantID)
-	return err
-}
-
+// GetUserTenants lists the tenants userID can sign in to: deactivated
+// memberships are left out.
 func (s *PGStore) GetUserTenants(ctx context.Context, userID uuid.UUID) ([]*models.Tenant, error) {
 	query := `SELECT t.id, t.name, t.status, t.settings, t.logo_url, t.website, t.contact_email, t.created_at, t.updated_at
 			  FROM tenants t
 			  INNER JOIN user_tenants ut ON t.id = ut.tenant_id
-			  WHERE ut.user_id = $1
+			  WHERE ut.user_id = $1 AND ut.deactivated_at IS NULL
 			  ORDER BY ut.joined_at DESC`
 	rows, err := s.db.Query(ctx, query, userID)
 	if err != nil {
@@ -1776,9 +1899,12 @@ func (s *PGStore) GetUserTenants(ctx context.Context, userID uuid.UUID) ([]*mode
 	return tenants, nil
 }
 
+// GetUserTenantRole returns userID's role in tenantID. A deactivated
+// membership reads like none (pgx.ErrNoRows), so every sign-in, refresh and
+// membership check refuses it.
 func (s *PGStore) GetUserTenantRole(ctx context.Context, userID, tenantID uuid.UUID) (string, error) {
 	var role string
-	query := `SELECT role FROM user_tenants WHERE user_id = $1 AND tenant_id = $2`
+	query := `SELECT role FROM user_tenants WHERE user_id = $1 AND tenant_id = $2 AND deactivated_at IS NULL`
 	err := s.db.QueryRow(ctx, query, userID, tenantID).Scan(&role)
 	if err != nil {
 		return "", err
@@ -1786,12 +1912,6 @@ func (s *PGStore) GetUserTenantRole(ctx context.Context, userID, tenantID uuid.U
 	return role, nil
 }
 
-func (s *PGStore) UpdateUserTenantRole(ctx context.Context, userID, tenantID uuid.UUID, role string) error {
-	query := `UPDATE user_tenants SET role = $1 WHERE user_id = $2 AND tenant_id = $3`
-	_, err := s.db.Exec(ctx, query, role, userID, tenantID)
-	return err
-}
-
 // Super Admin - Organizations Management
 
 func (s *PGStore) GetAllTenants(ctx context.Context, filters map[string]interface{}) ([]*models.TenantWithStats, error) {
@@ -2349,10 +2469,14 @@ func (s *PGStore) LogUsage(ctx context.Context, log *models.UsageLog) error {
 	).Scan(&log.ID, &log.LoggedAt)
 }
 
+// GetUsageStats sums a tenant's usage per resource type. API key traffic
+// ('api_call' rows) is left out: it is metered per key for GetAPIKeyUsage,
+// not usage the tenant's plan counts.
 func (s *PGStore) GetUsageStats(ctx context.Context, tenantID uuid.UUID, startDate, endDate time.Time) (map[string]int, error) {
 	query := `SELECT resource_type, SUM(quantity) as total
 	          FROM usage_logs
 	          WHERE tenant_id = $1 AND logged_at BETWEEN $2 AND $3
+	            AND resource_type <> 'api_call'
 	          GROUP BY resource_type`
 
 	rows, err := s.db.Query(ctx, query, tenantID, startDate, endDate)
@@ -2427,7 +2551,7 @@ func (s *PGStore) CheckTenantLimit(ctx context.Context, tenantID uuid.UUID, limi
 		}
 	case "users":
 		if err := s.db.QueryRow(ctx, `
-			SELECT COUNT(*) FROM user_tenants WHERE tenant_id = $1
+			SELECT COUNT(*) FROM user_tenants WHERE tenant_id = $1 AND deactivated_at IS NULL
 		`, tenantID).Scan(&current); err != nil {
 			return false, 0, 0, fmt.Errorf("failed to count users: %w", err)
 		}
@@ -14,7 +14,7 @@ import (
 // attendeesByEventColumns mirrors the column list GetAttendeesByEventID
 // selects (including the LEFT JOINed checked_in_by_email), in scan order.
 var attendeesByEventColumns = []string{
-	"id", "event_id", "first_name", "last_name", "email", "company", "position", "code",
+	"id", "event_id", "first_name", "last_name", "email", "company", "position", "code", "external_id",
 	"checkin_status", "checked_in_at", "checked_in_by", "checked_in_device_number", "checked_in_point_name",
 	"printed_count", "custom_fields", "blocked", "block_reason", "created_at", "updated_at",
 	"checked_in_by_email",
@@ -24,7 +24,7 @@ var attendeesByEventColumns = []string{
 // zero/nil values for everything else, matching attendeesByEventColumns.
 func addAttendeeRow(rows *pgxmock.Rows, id, eventID uuid.UUID, firstName, lastName, email, code string, now time.Time) *pgxmock.Rows {
 	return rows.AddRow(
-		id, eventID, firstName, lastName, email, "Acme", "Eng", code,
+		id, eventID, firstName, lastName, email, "Acme", "Eng", code, nil,
 		false, nil, nil, nil, nil,
 		0, nil, false, nil, now, now,
 		nil,
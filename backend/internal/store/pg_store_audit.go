@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// TenantAuditFilter narrows GetTenantAuditLog; zero fields do not filter.
+// From is inclusive, To exclusive.
+type TenantAuditFilter struct {
+	EventID     *uuid.UUID
+	ActorUserID *uuid.UUID
+	StationID   *uuid.UUID
+	TargetID    *uuid.UUID
+	Action      string
+	TargetType  string
+	From        *time.Time
+	To          *time.Time
+	Limit       int
+	Offset      int
+}
+
+// LogTenantAction appends entry to the tenant audit log and fills its ID
+// and CreatedAt. A nil Changes is stored as an empty object.
+func (s *PGStore) LogTenantAction(ctx context.Context, entry *models.TenantAuditEntry) error {
+	changes := []byte(entry.Changes)
+	if len(changes) == 0 {
+		changes = []byte("{}")
+	}
+	var ip string
+	if entry.IPAddress != nil {
+		ip = *entry.IPAddress
+	}
+	return s.db.QueryRow(ctx, `
+		INSERT INTO tenant_audit_log (tenant_id, event_id, actor_user_id, impersonated_by, station_id,
+		                              action, target_type, target_id, changes, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at`,
+		entry.TenantID, entry.EventID, entry.ActorUserID, entry.ImpersonatedBy, entry.StationID,
+		entry.Action, entry.TargetType, entry.TargetID, changes, auditIPValue(ip), entry.UserAgent,
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// GetTenantAuditLog returns tenantID's audit entries matching f, newest
+// first, with the actor's current email, plus the total number of matches.
+func (s *PGStore) GetTenantAuditLog(ctx context.Context, tenantID uuid.UUID, f TenantAuditFilter) ([]*models.TenantAuditEntry, int, error) {
+	args := []interface{}{tenantID}
+	conditions := []string{"l.tenant_id = $1"}
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+	if f.EventID != nil {
+		add("l.event_id = $%d", *f.EventID)
+	}
+	if f.ActorUserID != nil {
+		add("l.actor_user_id = $%d", *f.ActorUserID)
+	}
+	if f.StationID != nil {
+		add("l.station_id = $%d", *f.StationID)
+	}
+	if f.TargetID != nil {
+		add("l.target_id = $%d", *f.TargetID)
+	}
+	if f.Action != "" {
+		add("l.action = $%d", f.Action)
+	}
+	if f.TargetType != "" {
+		add("l.target_type = $%d", f.TargetType)
+	}
+	if f.From != nil {
+		add("l.created_at >= $%d", *f.From)
+	}
+	if f.To != nil {
+		add("l.created_at < $%d", *f.To)
+	}
+	where := "WHERE " + strings.Join(conditions, " AND ")
+
+	var total int
+	if err := s.db.QueryRow(ctx, "SELECT COUNT(*) FROM tenant_audit_log l "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count tenant audit log: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT l.id, l.tenant_id, l.event_id, l.actor_user_id, u.email, l.impersonated_by, l.station_id,
+		       l.action, l.target_type, l.target_id, l.changes, l.ip_address::text, l.user_agent, l.created_at
+		FROM tenant_audit_log l
+		LEFT JOIN users u ON u.id = l.actor_user_id
+		%s
+		ORDER BY l.created_at DESC, l.id
+		LIMIT $%d OFFSET $%d`, where, len(args)+1, len(args)+2)
+	rows, err := s.db.Query(ctx, query, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query tenant audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*models.TenantAuditEntry{}
+	for rows.Next() {
+		var e models.TenantAuditEntry
+		var changes []byte
+		if err := rows.Scan(&e.ID, &e.TenantID, &e.EventID, &e.ActorUserID, &e.ActorEmail, &e.ImpersonatedBy, &e.StationID,
+			&e.Action, &e.TargetType, &e.TargetID, &changes, &e.IPAddress, &e.UserAgent, &e.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("scan tenant audit log: %w", err)
+		}
+		e.Changes = changes
+		entries = append(entries, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterate tenant audit log: %w", err)
+	}
+	return entries, total, nil
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+func TestLogTenantActionStoresEmptyChangesAsObject(t *testing.T) {
+	mock := newImportMock(t)
+	tenantID, actor, target, id := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	now := time.Now()
+	ip, ua := "10.0.0.7", "kiosk"
+
+	mock.ExpectQuery(`INSERT INTO tenant_audit_log .*RETURNING id, created_at`).
+		WithArgs(tenantID, (*uuid.UUID)(nil), &actor, (*uuid.UUID)(nil), (*uuid.UUID)(nil),
+			"block_attendee", "attendee", &target, []byte("{}"), "10.0.0.7", &ua).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "created_at"}).AddRow(id, now))
+
+	s := &PGStore{db: mock}
+	entry := &models.TenantAuditEntry{
+		TenantID: tenantID, ActorUserID: &actor, Action: "block_attendee", TargetType: "attendee",
+		TargetID: &target, IPAddress: &ip, UserAgent: &ua,
+	}
+	if err := s.LogTenantAction(context.Background(), entry); err != nil {
+		t.Fatalf("LogTenantAction: %v", err)
+	}
+	if entry.ID != id || !entry.CreatedAt.Equal(now) {
+		t.Errorf("entry = %+v, want the inserted id and created_at", entry)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+// Filters become numbered conditions shared by the count and the page
+// query; limit and offset are appended after them.
+func TestGetTenantAuditLogAppliesFilters(t *testing.T) {
+	mock := newImportMock(t)
+	tenantID, eventID, station, id := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Now()
+	email := "admin@example.com"
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM tenant_audit_log l WHERE l.tenant_id = \$1 AND l.event_id = \$2 AND l.station_id = \$3 AND l.action = \$4 AND l.created_at >= \$5$`).
+		WithArgs(tenantID, eventID, station, "delete_attendee", from).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(`FROM tenant_audit_log l\s+LEFT JOIN users u ON u.id = l.actor_user_id\s+WHERE .* AND l.created_at >= \$5\s+ORDER BY l.created_at DESC, l.id\s+LIMIT \$6 OFFSET \$7`).
+		WithArgs(tenantID, eventID, station, "delete_attendee", from, 1, 2).
+		WillReturnRows(pgxmock.NewRows([]string{
+			"id", "tenant_id", "event_id", "actor_user_id", "email", "impersonated_by", "station_id",
+			"action", "target_type", "target_id", "changes", "ip_address", "user_agent", "created_at",
+		}).AddRow(id, tenantID, &eventID, (*uuid.UUID)(nil), &email, (*uuid.UUID)(nil), &station,
+			"delete_attendee", "attendee", (*uuid.UUID)(nil), []byte(`{"code":{"from":"A-1","to":null}}`), (*string)(nil), (*string)(nil), now))
+
+	s := &PGStore{db: mock}
+	entries, total, err := s.GetTenantAuditLog(context.Background(), tenantID, TenantAuditFilter{
+		EventID: &eventID, StationID: &station, Action: "delete_attendee", From: &from, Limit: 1, Offset: 2,
+	})
+	if err != nil {
+		t.Fatalf("GetTenantAuditLog: %v", err)
+	}
+	if total != 3 || len(entries) != 1 {
+		t.Fatalf("total=%d entries=%d, want 3 and 1", total, len(entries))
+	}
+	if e := entries[0]; e.ID != id || *e.ActorEmail != email || string(e.Changes) != `{"code":{"from":"A-1","to":null}}` {
+		t.Errorf("entry = %+v", e)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
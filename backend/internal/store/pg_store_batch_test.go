@@ -15,7 +15,7 @@ import (
 // select, in scan order — kept here so both ApplyBatchCheckin tests can build
 // rows without repeating the 19-column list inline.
 var attendeeSelectColumns = []string{
-	"id", "event_id", "first_name", "last_name", "email", "company", "position", "code",
+	"id", "event_id", "first_name", "last_name", "email", "company", "position", "code", "external_id",
 	"checkin_status", "checked_in_at", "checked_in_by", "checked_in_device_number", "checked_in_point_name",
 	"printed_count", "custom_fields", "blocked", "block_reason", "created_at", "updated_at",
 }
@@ -51,7 +51,7 @@ func TestApplyBatchCheckin_CheckinPersistsDeviceAndPointName(t *testing.T) {
 	mock.ExpectQuery(`FROM attendees WHERE id`).
 		WithArgs(attendeeID).
 		WillReturnRows(pgxmock.NewRows(attendeeSelectColumns).AddRow(
-			attendeeID, eventID, "Jane", "Doe", "jane@example.com", "Acme", "Eng", "CODE1",
+			attendeeID, eventID, "Jane", "Doe", "jane@example.com", "Acme", "Eng", "CODE1", nil,
 			false, nil, nil, nil, nil,
 			0, nil, false, nil, now, now,
 		))
@@ -102,7 +102,7 @@ func TestApplyBatchCheckin_CheckinPersistsDeviceAndPointName(t *testing.T) {
 	mock.ExpectQuery(`FROM attendees WHERE id`).
 		WithArgs(attendeeID).
 		WillReturnRows(pgxmock.NewRows(attendeeSelectColumns).AddRow(
-			attendeeID, eventID, "Jane", "Doe", "jane@example.com", "Acme", "Eng", "CODE1",
+			attendeeID, eventID, "Jane", "Doe", "jane@example.com", "Acme", "Eng", "CODE1", nil,
 			true, &at, &staffUserID, &deviceNumber, &pointName,
 			0, nil, false, nil, now, now,
 		))
@@ -186,7 +186,7 @@ func TestApplyBatchCheckin_ZoneEntryDoesNotTouchCheckinDeviceOrPoint(t *testing.
 	mock.ExpectQuery(`FROM attendees WHERE id`).
 		WithArgs(attendeeID).
 		WillReturnRows(pgxmock.NewRows(attendeeSelectColumns).AddRow(
-			attendeeID, eventID, "Jane", "Doe", "jane@example.com", "Acme", "Eng", "CODE1",
+			attendeeID, eventID, "Jane", "Doe", "jane@example.com", "Acme", "Eng", "CODE1", nil,
 			false, nil, nil, nil, nil,
 			0, nil, false, nil, time.Now(), time.Now(),
 		))
@@ -257,7 +257,7 @@ func TestApplyBatchCheckin_AlreadyCheckedInByAnotherDeviceDoesNotRewrite(t *test
 	mock.ExpectQuery(`FROM attendees WHERE id`).
 		WithArgs(attendeeID).
 		WillReturnRows(pgxmock.NewRows(attendeeSelectColumns).AddRow(
-			attendeeID, eventID, "Jane", "Doe", "jane@example.com", "Acme", "Eng", "CODE1",
+			attendeeID, eventID, "Jane", "Doe", "jane@example.com", "Acme", "Eng", "CODE1", nil,
 			true, &originalAt, &originalStaffUserID, &originalDevice, &originalPoint,
 			0, nil, false, nil, now, now,
 		))
@@ -301,7 +301,7 @@ func TestApplyBatchCheckin_AlreadyCheckedInByAnotherDeviceDoesNotRewrite(t *test
 	mock.ExpectQuery(`FROM attendees WHERE id`).
 		WithArgs(attendeeID).
 		WillReturnRows(pgxmock.NewRows(attendeeSelectColumns).AddRow(
-			attendeeID, eventID, "Jane", "Doe", "jane@example.com", "Acme", "Eng", "CODE1",
+			attendeeID, eventID, "Jane", "Doe", "jane@example.com", "Acme", "Eng", "CODE1", nil,
 			true, &originalAt, &originalStaffUserID, &originalDevice, &originalPoint,
 			0, nil, false, nil, now, now,
 		))
@@ -423,7 +423,7 @@ func TestApplyBatchCheckin_ConcurrentCheckinsRaceSecondCallGetsAlreadyCheckedIn(
 	mock.ExpectQuery(`FROM attendees WHERE id`).
 		WithArgs(attendeeID).
 		WillReturnRows(pgxmock.NewRows(attendeeSelectColumns).AddRow(
-			attendeeID, eventID, "Jane", "Doe", "jane@example.com", "Acme", "Eng", "CODE1",
+			attendeeID, eventID, "Jane", "Doe", "jane@example.com", "Acme", "Eng", "CODE1", nil,
 			false, nil, nil, nil, nil,
 			0, nil, false, nil, now, now,
 		))
@@ -470,7 +470,7 @@ func TestApplyBatchCheckin_ConcurrentCheckinsRaceSecondCallGetsAlreadyCheckedIn(
 	mock.ExpectQuery(`FROM attendees WHERE id`).
 		WithArgs(attendeeID).
 		WillReturnRows(pgxmock.NewRows(attendeeSelectColumns).AddRow(
-			attendeeID, eventID, "Jane", "Doe", "jane@example.com", "Acme", "Eng", "CODE1",
+			attendeeID, eventID, "Jane", "Doe", "jane@example.com", "Acme", "Eng", "CODE1", nil,
 			false, nil, nil, nil, nil,
 			0, nil, false, nil, now, now,
 		))
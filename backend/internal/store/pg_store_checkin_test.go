@@ -442,7 +442,7 @@ func TestGetCheckinStationByIDUnknownSurfacesRawErrNoRows(t *testing.T) {
 // checkinAttendeeReturningColumns is checkinAttendeeColumnsSQL's names, in
 // scan order (no checked_in_by_email — see pg_store.go's doc on why).
 var checkinAttendeeReturningColumns = []string{
-	"id", "event_id", "first_name", "last_name", "email", "company", "position", "code",
+	"id", "event_id", "first_name", "last_name", "email", "company", "position", "code", "external_id",
 	"checkin_status", "checked_in_at", "checked_in_by", "checked_in_device_number", "checked_in_point_name",
 	"printed_count", "custom_fields", "blocked", "block_reason", "created_at", "updated_at",
 }
@@ -458,12 +458,12 @@ var checkinAttendeeReturningColumns = []string{
 // Finding 2) that mirrors UndoCheckin's clear, so a fresh panel check-in
 // never inherits a stale device number left over from an earlier mobile
 // check-in (P2.1 lesson: assert real SQL text, not a loose matcher).
-const checkInAttendeeUpdateSQL = `UPDATE attendees\s+SET checkin_status = true, checked_in_at = now\(\), checked_in_by = \$1, checked_in_device_number = NULL, checked_in_point_name = \$2, updated_at = now\(\)\s+WHERE id = \$3 AND event_id = \$4 AND checkin_status = false AND blocked = false AND deleted_at IS NULL\s+RETURNING id, event_id, first_name, last_name, email, company, position, code, checkin_status, checked_in_at, checked_in_by, checked_in_device_number, checked_in_point_name, printed_count, custom_fields, blocked, block_reason, created_at, updated_at`
+const checkInAttendeeUpdateSQL = `UPDATE attendees\s+SET checkin_status = true, checked_in_at = now\(\), checked_in_by = \$1, checked_in_device_number = NULL, checked_in_point_name = \$2, updated_at = now\(\)\s+WHERE id = \$3 AND event_id = \$4 AND checkin_status = false AND blocked = false AND deleted_at IS NULL\s+RETURNING id, event_id, first_name, last_name, email, company, position, code, external_id, checkin_status, checked_in_at, checked_in_by, checked_in_device_number, checked_in_point_name, printed_count, custom_fields, blocked, block_reason, created_at, updated_at`
 
 // checkInAttendeeFallbackSelectSQL matches the 0-row fallback SELECT — the
 // same LEFT JOIN ... users shape as attendeeListColumnsSQL/scanAttendeeRow,
 // scoped to one attendee id within eventID.
-const checkInAttendeeFallbackSelectSQL = `SELECT\s+a\.id, a\.event_id, a\.first_name, a\.last_name, a\.email, a\.company, a\.position, a\.code,\s+a\.checkin_status, a\.checked_in_at, a\.checked_in_by, a\.checked_in_device_number, a\.checked_in_point_name, a\.printed_count, a\.custom_fields,\s+a\.blocked, a\.block_reason, a\.created_at, a\.updated_at,\s+u\.email as checked_in_by_email\s+FROM attendees a\s+LEFT JOIN users u ON a\.checked_in_by = u\.id\s+WHERE a\.id = \$1 AND a\.event_id = \$2 AND a\.deleted_at IS NULL`
+const checkInAttendeeFallbackSelectSQL = `SELECT\s+a\.id, a\.event_id, a\.first_name, a\.last_name, a\.email, a\.company, a\.position, a\.code, a\.external_id,\s+a\.checkin_status, a\.checked_in_at, a\.checked_in_by, a\.checked_in_device_number, a\.checked_in_point_name, a\.printed_count, a\.custom_fields,\s+a\.blocked, a\.block_reason, a\.created_at, a\.updated_at,\s+u\.email as checked_in_by_email\s+FROM attendees a\s+LEFT JOIN users u ON a\.checked_in_by = u\.id\s+WHERE a\.id = \$1 AND a\.event_id = \$2 AND a\.deleted_at IS NULL`
 
 // checkinActionsInsertSQL matches the feed row INSERT shared by
 // CheckInAttendee ('checkin'), UndoCheckin ('undo'), and the standalone
@@ -497,7 +497,7 @@ func TestCheckInAttendeeFreshAttendeeChecksInAndLogsFeedRow(t *testing.T) {
 	mock.ExpectQuery(checkInAttendeeUpdateSQL).
 		WithArgs(staffID, &stationName, attendeeID, eventID).
 		WillReturnRows(pgxmock.NewRows(checkinAttendeeReturningColumns).
-			AddRow(attendeeID, eventID, "Ada", "Lovelace", "ada@example.com", "Acme", "Eng", "CODE1",
+			AddRow(attendeeID, eventID, "Ada", "Lovelace", "ada@example.com", "Acme", "Eng", "CODE1", nil,
 				true, &now, &staffID, nil, &stationName, 0, nil, false, nil, now, now))
 	mock.ExpectExec(checkinActionsInsertCheckinSQL).
 		WithArgs(eventID, attendeeID, &stationID, "checkin", staffID).
@@ -540,7 +540,7 @@ func TestCheckInAttendeeNoStationLeavesPointNameNull(t *testing.T) {
 	mock.ExpectQuery(checkInAttendeeUpdateSQL).
 		WithArgs(staffID, (*string)(nil), attendeeID, eventID).
 		WillReturnRows(pgxmock.NewRows(checkinAttendeeReturningColumns).
-			AddRow(attendeeID, eventID, "Ada", "Lovelace", "ada@example.com", "Acme", "Eng", "CODE1",
+			AddRow(attendeeID, eventID, "Ada", "Lovelace", "ada@example.com", "Acme", "Eng", "CODE1", nil,
 				true, &now, &staffID, nil, nil, 0, nil, false, nil, now, now))
 	mock.ExpectExec(checkinActionsInsertCheckinSQL).
 		WithArgs(eventID, attendeeID, (*uuid.UUID)(nil), "checkin", staffID).
@@ -588,7 +588,7 @@ func TestCheckInAttendeeClearsStaleDeviceNumber(t *testing.T) {
 	mock.ExpectQuery(checkInAttendeeUpdateSQL).
 		WithArgs(staffID, (*string)(nil), attendeeID, eventID).
 		WillReturnRows(pgxmock.NewRows(checkinAttendeeReturningColumns).
-			AddRow(attendeeID, eventID, "Ada", "Lovelace", "ada@example.com", "Acme", "Eng", "CODE1",
+			AddRow(attendeeID, eventID, "Ada", "Lovelace", "ada@example.com", "Acme", "Eng", "CODE1", nil,
 				true, &now, &staffID, nil, nil, 0, nil, false, nil, now, now))
 	mock.ExpectExec(checkinActionsInsertCheckinSQL).
 		WithArgs(eventID, attendeeID, (*uuid.UUID)(nil), "checkin", staffID).
@@ -637,7 +637,7 @@ func TestCheckInAttendeeAlreadyCheckedInFallsBackNoFeedRow(t *testing.T) {
 	mock.ExpectQuery(checkInAttendeeFallbackSelectSQL).
 		WithArgs(attendeeID, eventID).
 		WillReturnRows(pgxmock.NewRows(attendeesByEventColumns).
-			AddRow(attendeeID, eventID, "Ada", "Lovelace", "ada@example.com", "Acme", "Eng", "CODE1",
+			AddRow(attendeeID, eventID, "Ada", "Lovelace", "ada@example.com", "Acme", "Eng", "CODE1", nil,
 				true, &firstScan, &originalStaff, nil, &originalPointName, 0, nil, false, nil, firstScan, firstScan,
 				&originalEmail))
 	mock.ExpectCommit()
@@ -690,7 +690,7 @@ func TestCheckInAttendeeNewlyBlockedReturnsBlockedOutcome(t *testing.T) {
 	mock.ExpectQuery(checkInAttendeeFallbackSelectSQL).
 		WithArgs(attendeeID, eventID).
 		WillReturnRows(pgxmock.NewRows(attendeesByEventColumns).
-			AddRow(attendeeID, eventID, "Ada", "Lovelace", "ada@example.com", "Acme", "Eng", "CODE1",
+			AddRow(attendeeID, eventID, "Ada", "Lovelace", "ada@example.com", "Acme", "Eng", "CODE1", nil,
 				false, nil, nil, nil, nil, 0, nil, true, &blockReason, now, now,
 				nil))
 	mock.ExpectCommit()
@@ -754,7 +754,7 @@ func TestCheckInAttendeeConflictStateRetriesAndSucceeds(t *testing.T) {
 	mock.ExpectQuery(checkInAttendeeUpdateSQL).
 		WithArgs(staffID, (*string)(nil), attendeeID, eventID).
 		WillReturnRows(pgxmock.NewRows(checkinAttendeeReturningColumns).
-			AddRow(attendeeID, eventID, "Ada", "Lovelace", "ada@example.com", "Acme", "Eng", "CODE1",
+			AddRow(attendeeID, eventID, "Ada", "Lovelace", "ada@example.com", "Acme", "Eng", "CODE1", nil,
 				true, &now, &staffID, nil, nil, 0, nil, false, nil, now, now))
 	mock.ExpectExec(checkinActionsInsertCheckinSQL).
 		WithArgs(eventID, attendeeID, (*uuid.UUID)(nil), "checkin", staffID).
@@ -866,11 +866,11 @@ func TestCheckInAttendeeMissingReturnsErrAttendeeNotFound(t *testing.T) {
 // attendee checked in via the mobile batch path carries a
 // checked_in_device_number that UndoCheckin used to leave stale — this
 // column must be nulled out in the SAME UPDATE as the rest.
-const undoCheckinUpdateSQL = `UPDATE attendees\s+SET checkin_status = false, checked_in_at = NULL, checked_in_by = NULL, checked_in_device_number = NULL, checked_in_point_name = NULL, updated_at = now\(\)\s+WHERE id = \$1 AND event_id = \$2 AND checkin_status = true AND deleted_at IS NULL\s+RETURNING id, event_id, first_name, last_name, email, company, position, code, checkin_status, checked_in_at, checked_in_by, checked_in_device_number, checked_in_point_name, printed_count, custom_fields, blocked, block_reason, created_at, updated_at`
+const undoCheckinUpdateSQL = `UPDATE attendees\s+SET checkin_status = false, checked_in_at = NULL, checked_in_by = NULL, checked_in_device_number = NULL, checked_in_point_name = NULL, updated_at = now\(\)\s+WHERE id = \$1 AND event_id = \$2 AND checkin_status = true AND deleted_at IS NULL\s+RETURNING id, event_id, first_name, last_name, email, company, position, code, external_id, checkin_status, checked_in_at, checked_in_by, checked_in_device_number, checked_in_point_name, printed_count, custom_fields, blocked, block_reason, created_at, updated_at`
 
 // undoCheckinFallbackSelectSQL matches the 0-row fallback SELECT (plain,
 // non-joined — an undone/never-checked-in attendee has no email to show).
-const undoCheckinFallbackSelectSQL = `SELECT id, event_id, first_name, last_name, email, company, position, code, checkin_status, checked_in_at, checked_in_by, checked_in_device_number, checked_in_point_name, printed_count, custom_fields, blocked, block_reason, created_at, updated_at FROM attendees WHERE id = \$1 AND event_id = \$2 AND deleted_at IS NULL`
+const undoCheckinFallbackSelectSQL = `SELECT id, event_id, first_name, last_name, email, company, position, code, external_id, checkin_status, checked_in_at, checked_in_by, checked_in_device_number, checked_in_point_name, printed_count, custom_fields, blocked, block_reason, created_at, updated_at FROM attendees WHERE id = \$1 AND event_id = \$2 AND deleted_at IS NULL`
 
 // checkinActionsInsertUndoSQL is retained as an alias so the "undo" test
 // below reads the same as before the P4.1 Task 4 extraction — it's the
@@ -894,7 +894,7 @@ func TestUndoCheckinClearsAndLogsFeedRow(t *testing.T) {
 	mock.ExpectQuery(undoCheckinUpdateSQL).
 		WithArgs(attendeeID, eventID).
 		WillReturnRows(pgxmock.NewRows(checkinAttendeeReturningColumns).
-			AddRow(attendeeID, eventID, "Ada", "Lovelace", "ada@example.com", "Acme", "Eng", "CODE1",
+			AddRow(attendeeID, eventID, "Ada", "Lovelace", "ada@example.com", "Acme", "Eng", "CODE1", nil,
 				false, nil, nil, nil, nil, 0, nil, false, nil, now, now))
 	mock.ExpectExec(checkinActionsInsertUndoSQL).
 		WithArgs(eventID, attendeeID, &stationID, "undo", staffID).
@@ -942,7 +942,7 @@ func TestUndoCheckinClearsDeviceNumber(t *testing.T) {
 	mock.ExpectQuery(undoCheckinUpdateSQL).
 		WithArgs(attendeeID, eventID).
 		WillReturnRows(pgxmock.NewRows(checkinAttendeeReturningColumns).
-			AddRow(attendeeID, eventID, "Ada", "Lovelace", "ada@example.com", "Acme", "Eng", "CODE1",
+			AddRow(attendeeID, eventID, "Ada", "Lovelace", "ada@example.com", "Acme", "Eng", "CODE1", nil,
 				false, nil, nil, nil, nil, 0, nil, false, nil, now, now))
 	mock.ExpectExec(checkinActionsInsertUndoSQL).
 		WithArgs(eventID, attendeeID, (*uuid.UUID)(nil), "undo", staffID).
@@ -983,7 +983,7 @@ func TestUndoCheckinAlreadyClearIsIdempotentNoFeedRow(t *testing.T) {
 	mock.ExpectQuery(undoCheckinFallbackSelectSQL).
 		WithArgs(attendeeID, eventID).
 		WillReturnRows(pgxmock.NewRows(checkinAttendeeReturningColumns).
-			AddRow(attendeeID, eventID, "Ada", "Lovelace", "ada@example.com", "Acme", "Eng", "CODE1",
+			AddRow(attendeeID, eventID, "Ada", "Lovelace", "ada@example.com", "Acme", "Eng", "CODE1", nil,
 				false, nil, nil, nil, nil, 0, nil, false, nil, now, now))
 	mock.ExpectCommit()
 
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// EventCloneOptions selects what CloneEvent copies besides the event row
+// itself. Zone-scoped data (access rules, staff zone assignments, attendee
+// zone overrides) is only copied along with Zones, since it has nothing to
+// point at otherwise. API keys are never copied: a key is a credential for
+// one event, not configuration.
+type EventCloneOptions struct {
+	Zones           bool
+	ZoneAccessRules bool
+	BadgeTemplate   bool
+	CheckinSettings bool
+	Fonts           bool
+	Staff           bool
+	// Attendees copies the guest list without any check-in state: check-in,
+	// print count, registration and packet flags all start fresh.
+	Attendees bool
+	// AssignedBy is recorded on copied staff assignments.
+	AssignedBy *uuid.UUID
+}
+
+const cloneEventInsertSQL = `
+	INSERT INTO events (tenant_id, name, start_date, end_date, location, field_schema, custom_fields, badge_template, checkin_settings)
+	SELECT $2, $3, $4, $5, $6, $7, $8,
+	       CASE WHEN $9::boolean THEN badge_template END,
+	       CASE WHEN $10::boolean THEN checkin_settings END
+	FROM events WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+	RETURNING id, created_at, updated_at`
+
+// Zones are unique by name within an event, so the copies are matched back
+// to their originals by name rather than by carrying an ID map through Go.
+const (
+	cloneZonesSQL = `
+	INSERT INTO event_zones (event_id, name, zone_type, order_index, open_time, close_time,
+	                         is_registration_zone, requires_registration, is_active, settings)
+	SELECT $2, name, zone_type, order_index, open_time, close_time,
+	       is_registration_zone, requires_registration, is_active, settings
+	FROM event_zones WHERE event_id = $1`
+
+	cloneZoneAccessRulesSQL = `
+	INSERT INTO zone_access_rules (zone_id, category, allowed, time_from, time_to)
+	SELECT nz.id, r.category, r.allowed, r.time_from, r.time_to
+	FROM zone_access_rules r
+	JOIN event_zones oz ON oz.id = r.zone_id AND oz.event_id = $1
+	JOIN event_zones nz ON nz.event_id = $2 AND nz.name = oz.name`
+
+	cloneFontsSQL = `
+	INSERT INTO fonts (event_id, name, family, weight, style, format, data, size, mime_type, uploaded_by, license_accepted_at)
+	SELECT $2, name, family, weight, style, format, data, size, mime_type, uploaded_by, license_accepted_at
+	FROM fonts WHERE event_id = $1`
+
+	cloneEventStaffSQL = `
+	INSERT INTO event_staff (event_id, user_id, assigned_by)
+	SELECT $2, user_id, $3 FROM event_staff WHERE event_id = $1`
+
+	cloneStaffZoneAssignmentsSQL = `
+	INSERT INTO staff_zone_assignments (user_id, zone_id, assigned_by)
+	SELECT a.user_id, nz.id, $3
+	FROM staff_zone_assignments a
+	JOIN event_zones oz ON oz.id = a.zone_id AND oz.event_id = $1
+	JOIN event_zones nz ON nz.event_id = $2 AND nz.name = oz.name`
+
+	cloneAttendeesSQL = `
+	INSERT INTO attendees (event_id, first_name, last_name, email, company, position, code,
+	                       custom_fields, external_id, blocked, block_reason)
+	SELECT $2, first_name, last_name, email, company, position, code,
+	       custom_fields, external_id, blocked, block_reason
+	FROM attendees WHERE event_id = $1 AND deleted_at IS NULL`
+
+	// Attendees are unique by code within an event, which is what matches a
+	// copied override to its copied attendee.
+	cloneAttendeeZoneAccessSQL = `
+	INSERT INTO attendee_zone_access (attendee_id, zone_id, allowed, notes)
+	SELECT na.id, nz.id, x.allowed, x.notes
+	FROM attendee_zone_access x
+	JOIN attendees oa ON oa.id = x.attendee_id AND oa.event_id = $1 AND oa.deleted_at IS NULL
+	JOIN attendees na ON na.event_id = $2 AND na.code = oa.code
+	JOIN event_zones oz ON oz.id = x.zone_id
+	JOIN event_zones nz ON nz.event_id = $2 AND nz.name = oz.name`
+)
+
+// CloneEvent creates clone (TenantID, Name, dates, Location, FieldSchema and
+// CustomFields as set by the caller) as a copy of the tenant's event srcID,
+// with what opts selects, in one transaction: either the whole copy lands or
+// nothing does. It fills clone's ID and timestamps and returns how many rows
+// of each kind were copied. ErrEventNotFound if srcID is missing, deleted or
+// belongs to another tenant.
+func (s *PGStore) CloneEvent(ctx context.Context, srcID uuid.UUID, clone *models.Event, opts EventCloneOptions) (*models.EventCloneCounts, error) {
+	var customFieldsJSON []byte
+	if clone.CustomFields != nil {
+		b, err := json.Marshal(clone.CustomFields)
+		if err != nil {
+			return nil, err
+		}
+		customFieldsJSON = b
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			log.Printf("rollback clone event: %v", rbErr)
+		}
+	}()
+
+	err = tx.QueryRow(ctx, cloneEventInsertSQL,
+		srcID, clone.TenantID, clone.Name, clone.StartDate, clone.EndDate, clone.Location, clone.FieldSchema, customFieldsJSON,
+		opts.BadgeTemplate, opts.CheckinSettings,
+	).Scan(&clone.ID, &clone.CreatedAt, &clone.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("clone event: %w", err)
+	}
+
+	counts := &models.EventCloneCounts{}
+	steps := []struct {
+		on    bool
+		what  string
+		sql   string
+		args  []any
+		count *int64
+	}{
+		{opts.Zones, "zones", cloneZonesSQL, nil, &counts.Zones},
+		{opts.Zones && opts.ZoneAccessRules, "zone access rules", cloneZoneAccessRulesSQL, nil, &counts.ZoneAccessRules},
+		{opts.Fonts, "fonts", cloneFontsSQL, nil, &counts.Fonts},
+		{opts.Staff, "staff", cloneEventStaffSQL, []any{opts.AssignedBy}, &counts.Staff},
+		{opts.Staff && opts.Zones, "staff zone assignments", cloneStaffZoneAssignmentsSQL, []any{opts.AssignedBy}, &counts.StaffZoneAssignments},
+		{opts.Attendees, "attendees", cloneAttendeesSQL, nil, &counts.Attendees},
+		{opts.Attendees && opts.Zones, "attendee zone access", cloneAttendeeZoneAccessSQL, nil, &counts.AttendeeZoneAccess},
+	}
+	for _, step := range steps {
+		if !step.on {
+			continue
+		}
+		tag, err := tx.Exec(ctx, step.sql, append([]any{srcID, clone.ID}, step.args...)...)
+		if err != nil {
+			return nil, fmt.Errorf("clone %s: %w", step.what, err)
+		}
+		*step.count = tag.RowsAffected()
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+// The whole copy is one transaction: the event row, then each selected
+// kind of child row, matched to the copied zones by name.
+func TestCloneEventCopiesSelectedRowsInOneTransaction(t *testing.T) {
+	mock := newImportMock(t)
+	srcID, tenantID, newID, actor := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	now := time.Now()
+	clone := &models.Event{TenantID: tenantID, Name: "Meetup (copy)", Location: "Hall"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO events .*SELECT \$2, \$3, \$4, \$5, \$6, \$7, \$8,\s+CASE WHEN \$9::boolean THEN badge_template END,\s+CASE WHEN \$10::boolean THEN checkin_settings END\s+FROM events WHERE id = \$1 AND tenant_id = \$2 AND deleted_at IS NULL`).
+		WithArgs(srcID, tenantID, "Meetup (copy)", (*time.Time)(nil), (*time.Time)(nil), "Hall", []string(nil), []byte(nil), true, false).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(newID, now, now))
+	mock.ExpectExec(`INSERT INTO event_zones .*FROM event_zones WHERE event_id = \$1`).
+		WithArgs(srcID, newID).WillReturnResult(pgxmock.NewResult("INSERT", 3))
+	mock.ExpectExec(`INSERT INTO zone_access_rules .*JOIN event_zones nz ON nz.event_id = \$2 AND nz.name = oz.name`).
+		WithArgs(srcID, newID).WillReturnResult(pgxmock.NewResult("INSERT", 4))
+	mock.ExpectExec(`INSERT INTO event_staff \(event_id, user_id, assigned_by\)`).
+		WithArgs(srcID, newID, &actor).WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectExec(`INSERT INTO staff_zone_assignments`).
+		WithArgs(srcID, newID, &actor).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(`INSERT INTO attendees .*FROM attendees WHERE event_id = \$1 AND deleted_at IS NULL`).
+		WithArgs(srcID, newID).WillReturnResult(pgxmock.NewResult("INSERT", 10))
+	mock.ExpectExec(`INSERT INTO attendee_zone_access .*JOIN attendees na ON na.event_id = \$2 AND na.code = oa.code`).
+		WithArgs(srcID, newID).WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectCommit()
+
+	s := &PGStore{db: mock}
+	counts, err := s.CloneEvent(context.Background(), srcID, clone, EventCloneOptions{
+		Zones: true, ZoneAccessRules: true, BadgeTemplate: true, Staff: true, Attendees: true, AssignedBy: &actor,
+	})
+	if err != nil {
+		t.Fatalf("CloneEvent: %v", err)
+	}
+	want := models.EventCloneCounts{Zones: 3, ZoneAccessRules: 4, Staff: 2, StaffZoneAssignments: 1, Attendees: 10, AttendeeZoneAccess: 2}
+	if *counts != want {
+		t.Errorf("counts = %+v, want %+v", *counts, want)
+	}
+	if clone.ID != newID || !clone.CreatedAt.Equal(now) {
+		t.Errorf("clone = %+v, want the inserted row's id and timestamps", clone)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+// A failing step rolls the copy back, event row included.
+func TestCloneEventRollsBackOnFailure(t *testing.T) {
+	mock := newImportMock(t)
+	srcID, tenantID, newID := uuid.New(), uuid.New(), uuid.New()
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO events`).
+		WithArgs(anyArgs(10)...).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(newID, now, now))
+	mock.ExpectExec(`INSERT INTO fonts`).
+		WithArgs(srcID, newID).WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
+
+	s := &PGStore{db: mock}
+	_, err := s.CloneEvent(context.Background(), srcID, &models.Event{TenantID: tenantID, Name: "x"}, EventCloneOptions{Fonts: true})
+	if err == nil {
+		t.Fatal("CloneEvent succeeded despite a failed step")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestCloneEventSourceNotFound(t *testing.T) {
+	mock := newImportMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO events`).
+		WithArgs(anyArgs(10)...).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "created_at", "updated_at"}))
+	mock.ExpectRollback()
+
+	s := &PGStore{db: mock}
+	_, err := s.CloneEvent(context.Background(), uuid.New(), &models.Event{TenantID: uuid.New(), Name: "x"}, EventCloneOptions{})
+	if !errors.Is(err, ErrEventNotFound) {
+		t.Fatalf("CloneEvent = %v, want ErrEventNotFound", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func anyArgs(n int) []interface{} {
+	args := make([]interface{}, n)
+	for i := range args {
+		args[i] = pgxmock.AnyArg()
+	}
+	return args
+}
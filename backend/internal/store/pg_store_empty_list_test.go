@@ -25,9 +25,9 @@ func TestPGStore_EmptyResultsReturnEmptySliceNotNil(t *testing.T) {
 		{
 			name: "GetUsersByTenantID",
 			setup: func(mock pgxmock.PgxPoolIface, id uuid.UUID, _ time.Time) {
-				mock.ExpectQuery(`FROM users WHERE tenant_id`).
+				mock.ExpectQuery(`FROM user_tenants ut JOIN users u`).
 					WithArgs(id).
-					WillReturnRows(pgxmock.NewRows([]string{"id", "tenant_id", "email", "role", "is_super_admin", "qr_token", "qr_token_created_at", "created_at", "updated_at"}))
+					WillReturnRows(pgxmock.NewRows([]string{"id", "tenant_id", "email", "role", "is_super_admin", "qr_token", "qr_token_created_at", "status", "deactivated_at", "created_at", "updated_at"}))
 			},
 			run: func(s *PGStore, id uuid.UUID, _ time.Time) (int, bool, error) {
 				users, err := s.GetUsersByTenantID(context.Background(), id)
@@ -63,13 +63,25 @@ func TestPGStore_EmptyResultsReturnEmptySliceNotNil(t *testing.T) {
 			setup: func(mock pgxmock.PgxPoolIface, id uuid.UUID, _ time.Time) {
 				mock.ExpectQuery(`FROM api_keys`).
 					WithArgs(id).
-					WillReturnRows(pgxmock.NewRows([]string{"id", "event_id", "name", "key_hash", "key_hash_bcrypt", "key_preview", "expires_at", "last_used_at", "revoked_at", "created_at"}))
+					WillReturnRows(pgxmock.NewRows([]string{"id", "event_id", "name", "key_hash", "key_hash_bcrypt", "key_preview", "rate_limit_per_minute", "expires_at", "last_used_at", "revoked_at", "created_at"}))
 			},
 			run: func(s *PGStore, id uuid.UUID, _ time.Time) (int, bool, error) {
 				keys, err := s.GetAPIKeysByEventID(context.Background(), id)
 				return len(keys), keys == nil, err
 			},
 		},
+		{
+			name: "GetAPIKeyUsage",
+			setup: func(mock pgxmock.PgxPoolIface, id uuid.UUID, at time.Time) {
+				mock.ExpectQuery(`FROM usage_logs`).
+					WithArgs(id, at, at).
+					WillReturnRows(pgxmock.NewRows([]string{"day", "requests", "throttled"}))
+			},
+			run: func(s *PGStore, id uuid.UUID, at time.Time) (int, bool, error) {
+				days, err := s.GetAPIKeyUsage(context.Background(), id, at, at)
+				return len(days), days == nil, err
+			},
+		},
 		{
 			name: "GetUserTenants",
 			setup: func(mock pgxmock.PgxPoolIface, id uuid.UUID, _ time.Time) {
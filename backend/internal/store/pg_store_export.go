@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// attendeeExportBatch is how many rows StreamAttendeeExport FETCHes from
+// its cursor at a time — the most attendees it ever holds in memory.
+const attendeeExportBatch = 500
+
+// AttendeeExportRow is one attendee as StreamAttendeeExport yields it.
+type AttendeeExportRow struct {
+	*models.Attendee
+	// ZoneEntries maps a zone ID to the attendee's zone_checkins entry
+	// times in it ("YYYY-MM-DD HH:MM:SS", oldest first; one per event day).
+	// Only filled when the export asked for zones.
+	ZoneEntries map[uuid.UUID][]string
+}
+
+// attendeeZoneEntriesSQL aggregates an attendee's zone_checkins into a
+// {zone_id: [entry, ...]} object, NULL when there are none.
+const attendeeZoneEntriesSQL = `,
+	(SELECT jsonb_object_agg(z.zone_id, z.entries) FROM (
+		SELECT zc.zone_id, jsonb_agg(to_char(zc.checked_in_at, 'YYYY-MM-DD HH24:MI:SS') ORDER BY zc.checked_in_at) AS entries
+		FROM zone_checkins zc WHERE zc.attendee_id = a.id
+		GROUP BY zc.zone_id) z) AS zone_entries
+`
+
+// StreamAttendeeExport calls fn for every attendee of the event matching f
+// (Code, Search, ZoneID and Status — paging is ignored), in the same
+// last_name/first_name/id order as GetAttendeesPage. Rows are read through a
+// server-side cursor attendeeExportBatch at a time inside a read-only
+// REPEATABLE READ transaction, so a large event never sits in memory and
+// the export is one consistent snapshot even while check-ins continue.
+// withZones adds each attendee's zone entries (AttendeeExportRow.ZoneEntries).
+// An error from fn stops the stream and is returned as is.
+func (s *PGStore) StreamAttendeeExport(ctx context.Context, eventID uuid.UUID, f AttendeeFilter, withZones bool, fn func(*AttendeeExportRow) error) error {
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("begin attendee export: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Printf("rollback attendee export: %v", err)
+		}
+	}()
+
+	join, where, args := attendeeFilterClause(eventID, f.Code, f.Search, f.ZoneID, f.Status)
+	zoneCol := ",\n\tNULL::jsonb AS zone_entries\n"
+	if withZones {
+		zoneCol = attendeeZoneEntriesSQL
+	}
+	declare := "DECLARE attendee_export NO SCROLL CURSOR FOR SELECT" + attendeeListColumnsSQL + zoneCol + `
+		FROM attendees a
+		LEFT JOIN users u ON a.checked_in_by = u.id
+		` + join + `
+		` + where + `
+		ORDER BY a.last_name, a.first_name, a.id`
+	if _, err := tx.Exec(ctx, declare, args...); err != nil {
+		return fmt.Errorf("declare attendee export cursor: %w", err)
+	}
+
+	fetch := fmt.Sprintf("FETCH %d FROM attendee_export", attendeeExportBatch)
+	for {
+		n, err := fetchAttendeeExportBatch(ctx, tx, fetch, fn)
+		if err != nil {
+			return err
+		}
+		if n < attendeeExportBatch {
+			return nil
+		}
+	}
+}
+
+// fetchAttendeeExportBatch runs one FETCH and hands its rows to fn,
+// returning how many there were.
+func fetchAttendeeExportBatch(ctx context.Context, tx pgx.Tx, fetch string, fn func(*AttendeeExportRow) error) (int, error) {
+	rows, err := tx.Query(ctx, fetch)
+	if err != nil {
+		return 0, fmt.Errorf("fetch attendee export: %w", err)
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var zonesJSON []byte
+		a, err := scanAttendeeRow(rows, &zonesJSON)
+		if err != nil {
+			return n, err
+		}
+		row := &AttendeeExportRow{Attendee: a}
+		if len(zonesJSON) > 0 {
+			if err := json.Unmarshal(zonesJSON, &row.ZoneEntries); err != nil {
+				return n, fmt.Errorf("unmarshal zone entries: %w", err)
+			}
+		}
+		n++
+		if err := fn(row); err != nil {
+			return n, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return n, fmt.Errorf("attendee export rows: %w", err)
+	}
+	return n, nil
+}
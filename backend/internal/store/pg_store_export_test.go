@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+var attendeeExportColumns = []string{
+	"id", "event_id", "first_name", "last_name", "email", "company", "position", "code", "external_id",
+	"checkin_status", "checked_in_at", "checked_in_by", "checked_in_device_number", "checked_in_point_name", "printed_count", "custom_fields",
+	"blocked", "block_reason", "created_at", "updated_at", "checked_in_by_email", "zone_entries",
+}
+
+func addExportRow(rows *pgxmock.Rows, eventID uuid.UUID, last string, zones []byte) {
+	now := time.Now()
+	rows.AddRow(uuid.New(), eventID, "A", last, "a@x.com", "", "", "C-"+last, (*string)(nil),
+		false, (*time.Time)(nil), (*uuid.UUID)(nil), (*int)(nil), (*string)(nil), 0, []byte(nil),
+		false, (*string)(nil), now, now, (*string)(nil), zones)
+}
+
+// The export declares a filtered cursor inside a read-only snapshot and
+// FETCHes until a short batch, never committing.
+func TestStreamAttendeeExportFetchesFromCursor(t *testing.T) {
+	mock := newImportMock(t)
+	eventID, zoneID := uuid.New(), uuid.New()
+	checkedIn := true
+
+	full := pgxmock.NewRows(attendeeExportColumns)
+	for i := 0; i < attendeeExportBatch; i++ {
+		addExportRow(full, eventID, "L", nil)
+	}
+	last := pgxmock.NewRows(attendeeExportColumns)
+	addExportRow(last, eventID, "Z", []byte(`{"`+zoneID.String()+`":["2024-05-01 09:00:00"]}`))
+
+	mock.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	mock.ExpectExec(`DECLARE attendee_export NO SCROLL CURSOR FOR SELECT[\s\S]+zone_checkins[\s\S]+JOIN attendee_zone_access aza[\s\S]+a\.checkin_status = \$3\s+ORDER BY a\.last_name, a\.first_name, a\.id`).
+		WithArgs(eventID, zoneID, true).
+		WillReturnResult(pgxmock.NewResult("DECLARE CURSOR", 0))
+	mock.ExpectQuery(`FETCH 500 FROM attendee_export`).WillReturnRows(full)
+	mock.ExpectQuery(`FETCH 500 FROM attendee_export`).WillReturnRows(last)
+	mock.ExpectRollback()
+
+	var got []*AttendeeExportRow
+	err := (&PGStore{db: mock}).StreamAttendeeExport(context.Background(), eventID,
+		AttendeeFilter{ZoneID: &zoneID, Status: &checkedIn, Page: 3, PerPage: 10}, true,
+		func(r *AttendeeExportRow) error {
+			got = append(got, r)
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("StreamAttendeeExport: %v", err)
+	}
+	if len(got) != attendeeExportBatch+1 {
+		t.Fatalf("streamed %d rows, want %d (paging ignored)", len(got), attendeeExportBatch+1)
+	}
+	if entries := got[len(got)-1].ZoneEntries[zoneID]; len(entries) != 1 || entries[0] != "2024-05-01 09:00:00" {
+		t.Errorf("zone entries = %v", got[len(got)-1].ZoneEntries)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ClaimIdempotencyKey tries to take rec.(TenantID, Key) for a new request.
+// The INSERT wins outright for an unused key; a conflicting row is only
+// taken over (reset to in-flight with rec's fingerprint) when it has aged
+// past retention, or when it is an unfinished claim older than lockTimeout
+// — the first request's process died before completing or releasing it.
+// On a win it returns (nil, nil). Otherwise it returns the live row
+// unchanged for the caller to replay or reject.
+func (s *PGStore) ClaimIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord, retention, lockTimeout time.Duration) (*models.IdempotencyRecord, error) {
+	var createdAt time.Time
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO idempotency_keys (tenant_id, key, method, path, request_hash)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (tenant_id, key) DO UPDATE
+		   SET method = EXCLUDED.method, path = EXCLUDED.path, request_hash = EXCLUDED.request_hash,
+		       status_code = NULL, content_type = NULL, response_body = NULL,
+		       created_at = now(), completed_at = NULL
+		 WHERE idempotency_keys.created_at < now() - make_interval(secs => $6)
+		    OR (idempotency_keys.completed_at IS NULL
+		        AND idempotency_keys.created_at < now() - make_interval(secs => $7))
+		RETURNING created_at`,
+		rec.TenantID, rec.Key, rec.Method, rec.Path, rec.RequestHash,
+		retention.Seconds(), lockTimeout.Seconds(),
+	).Scan(&createdAt)
+	if err == nil {
+		rec.CreatedAt = createdAt
+		return nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("claim idempotency key: %w", err)
+	}
+
+	existing := &models.IdempotencyRecord{TenantID: rec.TenantID, Key: rec.Key}
+	var contentType *string
+	err = s.db.QueryRow(ctx, `
+		SELECT method, path, request_hash, status_code, content_type, response_body, created_at, completed_at
+		FROM idempotency_keys
+		WHERE tenant_id = $1 AND key = $2`, rec.TenantID, rec.Key,
+	).Scan(&existing.Method, &existing.Path, &existing.RequestHash, &existing.StatusCode,
+		&contentType, &existing.ResponseBody, &existing.CreatedAt, &existing.CompletedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Purged between the two statements: the caller may simply retry
+		// the claim, but a conflict is the honest answer for this attempt.
+		return nil, ErrIdempotencyKeyVanished
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load idempotency key: %w", err)
+	}
+	if contentType != nil {
+		existing.ContentType = *contentType
+	}
+	return existing, nil
+}
+
+// ErrIdempotencyKeyVanished is returned by ClaimIdempotencyKey when the row
+// that blocked the claim was deleted before it could be read back.
+var ErrIdempotencyKeyVanished = errors.New("idempotency key vanished during claim")
+
+// CompleteIdempotencyKey stores the finished response on a claimed key.
+// Guarded on completed_at IS NULL so a slow first request can never
+// overwrite a row another request has since taken over and completed.
+func (s *PGStore) CompleteIdempotencyKey(ctx context.Context, tenantID uuid.UUID, key string, statusCode int, contentType string, body []byte) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE idempotency_keys
+		   SET status_code = $3, content_type = $4, response_body = $5, completed_at = now()
+		 WHERE tenant_id = $1 AND key = $2 AND completed_at IS NULL`,
+		tenantID, key, statusCode, contentType, body)
+	return err
+}
+
+// ReleaseIdempotencyKey drops an unfinished claim so the client's retry can
+// run the request again (used when the first attempt failed server-side).
+func (s *PGStore) ReleaseIdempotencyKey(ctx context.Context, tenantID uuid.UUID, key string) error {
+	_, err := s.db.Exec(ctx, `
+		DELETE FROM idempotency_keys
+		 WHERE tenant_id = $1 AND key = $2 AND completed_at IS NULL`, tenantID, key)
+	return err
+}
+
+// PurgeExpiredIdempotencyKeys deletes keys older than retention and reports
+// how many were removed. retention <= 0 is a no-op, never "delete all".
+func (s *PGStore) PurgeExpiredIdempotencyKeys(ctx context.Context, retention time.Duration) (int64, error) {
+	if retention <= 0 {
+		return 0, nil
+	}
+	tag, err := s.db.Exec(ctx, `
+		DELETE FROM idempotency_keys
+		 WHERE created_at < now() - make_interval(secs => $1)`, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+func newIdempotencyRecord() *models.IdempotencyRecord {
+	return &models.IdempotencyRecord{
+		TenantID:    uuid.New(),
+		Key:         "k-1",
+		Method:      "POST",
+		Path:        "/api/events",
+		RequestHash: "abc",
+	}
+}
+
+func TestClaimIdempotencyKeyWinsOnInsert(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock.NewPool: %v", err)
+	}
+	defer mock.Close()
+
+	rec := newIdempotencyRecord()
+	now := time.Now()
+	mock.ExpectQuery(`INSERT INTO idempotency_keys`).
+		WithArgs(rec.TenantID, rec.Key, rec.Method, rec.Path, rec.RequestHash, float64(86400), float64(600)).
+		WillReturnRows(pgxmock.NewRows([]string{"created_at"}).AddRow(now))
+
+	s := &PGStore{db: mock}
+	existing, err := s.ClaimIdempotencyKey(context.Background(), rec, 24*time.Hour, 10*time.Minute)
+	if err != nil || existing != nil {
+		t.Fatalf("ClaimIdempotencyKey = %v, %v; want nil, nil (claim won)", existing, err)
+	}
+	if !rec.CreatedAt.Equal(now) {
+		t.Errorf("CreatedAt = %v, want %v", rec.CreatedAt, now)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+// A live conflicting row is returned as-is for the middleware to replay.
+func TestClaimIdempotencyKeyReturnsExistingRow(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock.NewPool: %v", err)
+	}
+	defer mock.Close()
+
+	rec := newIdempotencyRecord()
+	status := 201
+	ct := "application/json"
+	now := time.Now()
+	mock.ExpectQuery(`INSERT INTO idempotency_keys`).
+		WithArgs(rec.TenantID, rec.Key, rec.Method, rec.Path, rec.RequestHash, pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnError(pgx.ErrNoRows)
+	mock.ExpectQuery(`SELECT method, path, request_hash, status_code, content_type, response_body, created_at, completed_at\s+FROM idempotency_keys`).
+		WithArgs(rec.TenantID, rec.Key).
+		WillReturnRows(pgxmock.NewRows([]string{"method", "path", "request_hash", "status_code", "content_type", "response_body", "created_at", "completed_at"}).
+			AddRow("POST", "/api/events", "abc", &status, &ct, []byte(`{"id":"x"}`), now, &now))
+
+	s := &PGStore{db: mock}
+	existing, err := s.ClaimIdempotencyKey(context.Background(), rec, 24*time.Hour, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimIdempotencyKey: %v", err)
+	}
+	if existing == nil || existing.StatusCode == nil || *existing.StatusCode != 201 ||
+		existing.ContentType != ct || string(existing.ResponseBody) != `{"id":"x"}` {
+		t.Fatalf("existing = %+v, want the stored 201 response", existing)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestClaimIdempotencyKeyVanished(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock.NewPool: %v", err)
+	}
+	defer mock.Close()
+
+	rec := newIdempotencyRecord()
+	mock.ExpectQuery(`INSERT INTO idempotency_keys`).
+		WithArgs(rec.TenantID, rec.Key, rec.Method, rec.Path, rec.RequestHash, pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnError(pgx.ErrNoRows)
+	mock.ExpectQuery(`SELECT method, path, request_hash`).
+		WithArgs(rec.TenantID, rec.Key).
+		WillReturnError(pgx.ErrNoRows)
+
+	s := &PGStore{db: mock}
+	if _, err := s.ClaimIdempotencyKey(context.Background(), rec, time.Hour, time.Minute); !errors.Is(err, ErrIdempotencyKeyVanished) {
+		t.Fatalf("err = %v, want ErrIdempotencyKeyVanished", err)
+	}
+}
+
+// retention <= 0 must never turn into "delete every key".
+func TestPurgeExpiredIdempotencyKeysNoopWhenDisabled(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock.NewPool: %v", err)
+	}
+	defer mock.Close()
+
+	s := &PGStore{db: mock}
+	if n, err := s.PurgeExpiredIdempotencyKeys(context.Background(), 0); n != 0 || err != nil {
+		t.Fatalf("PurgeExpiredIdempotencyKeys(0) = %d, %v; want 0, nil", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestPurgeExpiredIdempotencyKeysReportsRows(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock.NewPool: %v", err)
+	}
+	defer mock.Close()
+
+	mock.ExpectExec(`DELETE FROM idempotency_keys\s+WHERE created_at < now\(\) - make_interval\(secs => \$1\)`).
+		WithArgs(float64(3600)).
+		WillReturnResult(pgxmock.NewResult("DELETE", 7))
+
+	s := &PGStore{db: mock}
+	n, err := s.PurgeExpiredIdempotencyKeys(context.Background(), time.Hour)
+	if err != nil || n != 7 {
+		t.Fatalf("PurgeExpiredIdempotencyKeys = %d, %v; want 7, nil", n, err)
+	}
+}
@@ -0,0 +1,459 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// AttendeeImportRow is one external-import row as ImportAttendee applies it.
+// A nil profile field was absent from the row: an update leaves that column
+// as it is, an insert stores it empty. Check-in state, blocked state and
+// printed_count are deliberately not representable here — an import never
+// writes them, whatever the registration system sends.
+type AttendeeImportRow struct {
+	EventID    uuid.UUID
+	ExternalID *string
+	FirstName  *string
+	LastName   *string
+	Email      *string
+	Company    *string
+	Position   *string
+	Code       *string
+	// FallbackCode is the code an INSERT uses when Code is nil. It is never
+	// applied to an existing attendee, so matching a row that carries no
+	// code can't reissue the attendee's badge code.
+	FallbackCode string
+	// CustomFields are merged key-by-key over the existing custom_fields on
+	// update; keys the row doesn't mention keep their stored values.
+	CustomFields map[string]interface{}
+}
+
+// ErrImportNoMatch is returned by ImportAttendee in update-only mode when no
+// live attendee of the event matches the row.
+var ErrImportNoMatch = errors.New("no attendee matches this row")
+
+// ErrImportAmbiguousMatch is returned by ImportAttendee when the row's match
+// value identifies more than one live attendee (possible for email, which
+// attendees don't hold unique); the import refuses to guess which one.
+var ErrImportAmbiguousMatch = errors.New("row matches more than one attendee")
+
+// importMatchExpr is the SQL expression each match field compares on.
+// Email compares case-insensitively, like the sign-in lookups.
+var importMatchExpr = map[string]string{
+	models.ImportMatchExternalID: "external_id",
+	models.ImportMatchEmail:      "lower(email)",
+	models.ImportMatchCode:       "code",
+}
+
+// ImportMatchValue returns row's value for matchOn in the form
+// importMatchExpr compares against ("" when the row has none).
+func ImportMatchValue(row AttendeeImportRow, matchOn string) string {
+	var v *string
+	switch matchOn {
+	case models.ImportMatchExternalID:
+		v = row.ExternalID
+	case models.ImportMatchEmail:
+		if row.Email != nil {
+			lower := strings.ToLower(*row.Email)
+			v = &lower
+		}
+	case models.ImportMatchCode:
+		v = row.Code
+	}
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+// ImportAttendee applies one external-import row to its event in a single
+// transaction and reports the attendee's ID and the row outcome
+// (models.ImportRow*). In insert-only mode it always inserts. Otherwise the
+// live attendee matching the row on matchOn is locked and updated — only
+// the profile columns the row carries, and only when something actually
+// differs (else ImportRowUnchanged, no write) — or, when nothing matches,
+// the row is inserted (upsert) or rejected with ErrImportNoMatch
+// (update-only). A unique-constraint failure (duplicate code or
+// external_id) comes back as the raw error for the caller's row report.
+func (s *PGStore) ImportAttendee(ctx context.Context, row AttendeeImportRow, mode, matchOn string) (uuid.UUID, string, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Printf("rollback attendee import: %v", err)
+		}
+	}()
+	id, status, err := importAttendeeTx(ctx, tx, row, mode, matchOn)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	return id, status, tx.Commit(ctx)
+}
+
+// ImportOutcome is one row's result from PreviewAttendeeImport: what
+// ImportAttendee would have returned for it.
+type ImportOutcome struct {
+	ID     uuid.UUID
+	Status string
+	Err    error
+}
+
+// ErrImportDuplicateCode and ErrImportDuplicateExternalID are how a
+// preview reports the unique violation ImportAttendee would hit on a code
+// or external_id another attendee of the event already holds.
+var (
+	ErrImportDuplicateCode       = errors.New("another attendee of the event already has this code")
+	ErrImportDuplicateExternalID = errors.New("another attendee of the event already has this external_id")
+)
+
+// previewAttendee is an attendee as PreviewAttendeeImport plays the batch
+// against it: the profile columns an import writes, nullable as stored.
+// A row the preview would insert has no ID.
+type previewAttendee struct {
+	id           uuid.UUID
+	externalID   *string
+	firstName    *string
+	lastName     *string
+	email        *string
+	company      *string
+	position     *string
+	code         string
+	customFields map[string]interface{}
+	deleted      bool
+}
+
+// PreviewAttendeeImport reports, row by row, what ImportAttendee would do
+// with rows (all of one event) without writing or locking anything. One
+// SELECT reads the attendees the batch could match or collide with — by
+// match value, external_id, or code, which stays unique even among
+// soft-deleted rows — and the batch is then played against that snapshot
+// in memory: a later row sees an earlier row of the same batch as a real
+// import would, updated vs unchanged is the comparison the UPDATE's IS
+// DISTINCT FROM guard makes, and a taken code or external_id is reported
+// as the violation the import would hit. A concurrent import can still
+// change the outcome; the preview is as of its read. The returned error is
+// for the batch as a whole; per-row failures are in ImportOutcome.Err, and
+// a row that would be created has no ID yet.
+func (s *PGStore) PreviewAttendeeImport(ctx context.Context, rows []AttendeeImportRow, mode, matchOn string) ([]ImportOutcome, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	expr, ok := importMatchExpr[matchOn]
+	if !ok {
+		return nil, fmt.Errorf("unknown import match field %q", matchOn)
+	}
+	keys, externalIDs, codes := []string{}, []string{}, []string{}
+	for _, row := range rows {
+		if v := ImportMatchValue(row, matchOn); v != "" {
+			keys = append(keys, v)
+		}
+		if row.ExternalID != nil {
+			externalIDs = append(externalIDs, *row.ExternalID)
+		}
+		codes = append(codes, row.FallbackCode)
+		if row.Code != nil {
+			codes = append(codes, *row.Code)
+		}
+	}
+	dbRows, err := s.db.Query(ctx, `
+		SELECT id, external_id, first_name, last_name, email, company, position, code, custom_fields,
+		       deleted_at IS NOT NULL
+		FROM attendees
+		WHERE event_id = $1
+		  AND ((deleted_at IS NULL AND (`+expr+` = ANY($2) OR external_id = ANY($3))) OR code = ANY($4))`,
+		rows[0].EventID, keys, externalIDs, codes)
+	if err != nil {
+		return nil, fmt.Errorf("preview attendee import: %w", err)
+	}
+	var snapshot []*previewAttendee
+	for dbRows.Next() {
+		a := &previewAttendee{}
+		var customFields []byte
+		if err := dbRows.Scan(&a.id, &a.externalID, &a.firstName, &a.lastName, &a.email, &a.company, &a.position, &a.code, &customFields, &a.deleted); err != nil {
+			dbRows.Close()
+			return nil, fmt.Errorf("preview attendee import: %w", err)
+		}
+		if customFields != nil {
+			if err := json.Unmarshal(customFields, &a.customFields); err != nil {
+				dbRows.Close()
+				return nil, fmt.Errorf("preview attendee import: %w", err)
+			}
+		}
+		snapshot = append(snapshot, a)
+	}
+	dbRows.Close()
+	if err := dbRows.Err(); err != nil {
+		return nil, fmt.Errorf("preview attendee import: %w", err)
+	}
+
+	outcomes := make([]ImportOutcome, len(rows))
+	for i, row := range rows {
+		var out ImportOutcome
+		snapshot, out = previewImportRow(snapshot, row, mode, matchOn)
+		outcomes[i] = out
+	}
+	return outcomes, nil
+}
+
+// previewImportRow plays one row against snapshot the way importAttendeeTx
+// would apply it, returning the snapshot as the row leaves it.
+func previewImportRow(snapshot []*previewAttendee, row AttendeeImportRow, mode, matchOn string) ([]*previewAttendee, ImportOutcome) {
+	taken := func(self *previewAttendee, code string, externalID *string) error {
+		for _, a := range snapshot {
+			if a == self {
+				continue
+			}
+			if a.code == code {
+				return ErrImportDuplicateCode
+			}
+			if externalID != nil && !a.deleted && a.externalID != nil && *a.externalID == *externalID {
+				return ErrImportDuplicateExternalID
+			}
+		}
+		return nil
+	}
+
+	if mode != models.ImportModeInsertOnly {
+		value := ImportMatchValue(row, matchOn)
+		if value == "" {
+			return snapshot, ImportOutcome{Err: fmt.Errorf("row has no %s to match on", matchOn)}
+		}
+		var matched []*previewAttendee
+		for _, a := range snapshot {
+			if !a.deleted && a.matchValue(matchOn) == value {
+				matched = append(matched, a)
+			}
+		}
+		switch {
+		case len(matched) > 1:
+			return snapshot, ImportOutcome{Err: ErrImportAmbiguousMatch}
+		case len(matched) == 1:
+			a := matched[0]
+			next := *a
+			next.externalID = coalesce(row.ExternalID, a.externalID)
+			next.firstName = coalesce(row.FirstName, a.firstName)
+			next.lastName = coalesce(row.LastName, a.lastName)
+			next.email = coalesce(row.Email, a.email)
+			next.company = coalesce(row.Company, a.company)
+			next.position = coalesce(row.Position, a.position)
+			if row.Code != nil {
+				next.code = *row.Code
+			}
+			if len(row.CustomFields) > 0 {
+				next.customFields = make(map[string]interface{}, len(a.customFields)+len(row.CustomFields))
+				for k, v := range a.customFields {
+					next.customFields[k] = v
+				}
+				for k, v := range row.CustomFields {
+					next.customFields[k] = v
+				}
+			}
+			if next.sameProfile(a) {
+				return snapshot, ImportOutcome{ID: a.id, Status: models.ImportRowUnchanged}
+			}
+			if err := taken(a, next.code, next.externalID); err != nil {
+				return snapshot, ImportOutcome{Err: err}
+			}
+			*a = next
+			return snapshot, ImportOutcome{ID: a.id, Status: models.ImportRowUpdated}
+		case mode == models.ImportModeUpdateOnly:
+			return snapshot, ImportOutcome{Err: ErrImportNoMatch}
+		}
+	}
+
+	code := row.FallbackCode
+	if row.Code != nil && *row.Code != "" {
+		code = *row.Code
+	}
+	if err := taken(nil, code, row.ExternalID); err != nil {
+		return snapshot, ImportOutcome{Err: err}
+	}
+	empty := ""
+	snapshot = append(snapshot, &previewAttendee{
+		externalID:   row.ExternalID,
+		firstName:    coalesce(row.FirstName, &empty),
+		lastName:     coalesce(row.LastName, &empty),
+		email:        coalesce(row.Email, &empty),
+		company:      coalesce(row.Company, &empty),
+		position:     coalesce(row.Position, &empty),
+		code:         code,
+		customFields: row.CustomFields,
+	})
+	return snapshot, ImportOutcome{Status: models.ImportRowCreated}
+}
+
+// matchValue is a's value of importMatchExpr[matchOn].
+func (a *previewAttendee) matchValue(matchOn string) string {
+	var v *string
+	switch matchOn {
+	case models.ImportMatchExternalID:
+		v = a.externalID
+	case models.ImportMatchEmail:
+		if a.email != nil {
+			lower := strings.ToLower(*a.email)
+			v = &lower
+		}
+	case models.ImportMatchCode:
+		v = &a.code
+	}
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+// sameProfile reports whether a and b agree on every column the import's
+// UPDATE compares; custom fields compare as JSON, as jsonb does.
+func (a *previewAttendee) sameProfile(b *previewAttendee) bool {
+	if !equalPtr(a.externalID, b.externalID) || !equalPtr(a.firstName, b.firstName) ||
+		!equalPtr(a.lastName, b.lastName) || !equalPtr(a.email, b.email) ||
+		!equalPtr(a.company, b.company) || !equalPtr(a.position, b.position) || a.code != b.code {
+		return false
+	}
+	x, errX := json.Marshal(a.customFields)
+	y, errY := json.Marshal(b.customFields)
+	return errX == nil && errY == nil && string(x) == string(y)
+}
+
+func coalesce(v, fallback *string) *string {
+	if v != nil {
+		return v
+	}
+	return fallback
+}
+
+func equalPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// importAttendeeTx is ImportAttendee's body, run on tx without committing.
+func importAttendeeTx(ctx context.Context, tx pgx.Tx, row AttendeeImportRow, mode, matchOn string) (uuid.UUID, string, error) {
+	expr, ok := importMatchExpr[matchOn]
+	if !ok {
+		return uuid.Nil, "", fmt.Errorf("unknown import match field %q", matchOn)
+	}
+
+	var customFieldsJSON []byte
+	var err error
+	if len(row.CustomFields) > 0 {
+		customFieldsJSON, err = json.Marshal(row.CustomFields)
+		if err != nil {
+			return uuid.Nil, "", err
+		}
+	}
+
+	if mode != models.ImportModeInsertOnly {
+		value := ImportMatchValue(row, matchOn)
+		if value == "" {
+			return uuid.Nil, "", fmt.Errorf("row has no %s to match on", matchOn)
+		}
+		rows, err := tx.Query(ctx, `
+			SELECT id FROM attendees
+			WHERE event_id = $1 AND deleted_at IS NULL AND `+expr+` = $2
+			ORDER BY created_at
+			LIMIT 2
+			FOR UPDATE`, row.EventID, value)
+		if err != nil {
+			return uuid.Nil, "", fmt.Errorf("match attendee: %w", err)
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+		if err != nil {
+			return uuid.Nil, "", fmt.Errorf("match attendee: %w", err)
+		}
+
+		switch {
+		case len(ids) > 1:
+			return uuid.Nil, "", ErrImportAmbiguousMatch
+		case len(ids) == 1:
+			// The IS DISTINCT FROM guard turns a no-op row into a zero-row
+			// UPDATE, so unchanged rows don't bump updated_at (which would
+			// push every attendee into the next sync delta).
+			tag, err := tx.Exec(ctx, `
+				UPDATE attendees SET
+				  external_id = COALESCE($2, external_id),
+				  first_name = COALESCE($3, first_name), last_name = COALESCE($4, last_name),
+				  email = COALESCE($5, email), company = COALESCE($6, company),
+				  position = COALESCE($7, position), code = COALESCE($8, code),
+				  custom_fields = CASE WHEN $9::jsonb IS NULL THEN custom_fields
+				                       ELSE COALESCE(custom_fields, '{}'::jsonb) || $9::jsonb END,
+				  updated_at = now()
+				WHERE id = $1
+				  AND (external_id, first_name, last_name, email, company, position, code, custom_fields)
+				      IS DISTINCT FROM
+				      (COALESCE($2, external_id), COALESCE($3, first_name), COALESCE($4, last_name),
+				       COALESCE($5, email), COALESCE($6, company), COALESCE($7, position), COALESCE($8, code),
+				       CASE WHEN $9::jsonb IS NULL THEN custom_fields
+				            ELSE COALESCE(custom_fields, '{}'::jsonb) || $9::jsonb END)`,
+				ids[0], row.ExternalID, row.FirstName, row.LastName, row.Email, row.Company, row.Position, row.Code, customFieldsJSON)
+			if err != nil {
+				return uuid.Nil, "", err
+			}
+			status := models.ImportRowUnchanged
+			if tag.RowsAffected() > 0 {
+				status = models.ImportRowUpdated
+			}
+			return ids[0], status, nil
+		case mode == models.ImportModeUpdateOnly:
+			return uuid.Nil, "", ErrImportNoMatch
+		}
+	}
+
+	code := row.FallbackCode
+	if row.Code != nil && *row.Code != "" {
+		code = *row.Code
+	}
+	var id uuid.UUID
+	err = tx.QueryRow(ctx, `
+		INSERT INTO attendees (event_id, external_id, first_name, last_name, email, company, position, code, custom_fields)
+		VALUES ($1, $2, COALESCE($3, ''), COALESCE($4, ''), COALESCE($5, ''), COALESCE($6, ''), COALESCE($7, ''), $8, $9)
+		RETURNING id`,
+		row.EventID, row.ExternalID, row.FirstName, row.LastName, row.Email, row.Company, row.Position, code, customFieldsJSON,
+	).Scan(&id)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	return id, models.ImportRowCreated, nil
+}
+
+// ExistingImportMatchKeys reports which of keys (match values as
+// ImportMatchValue renders them) already identify a live attendee of the
+// event. ExternalImport uses it to size an upsert batch against the
+// attendee limit by the rows that would actually create attendees.
+func (s *PGStore) ExistingImportMatchKeys(ctx context.Context, eventID uuid.UUID, matchOn string, keys []string) (map[string]bool, error) {
+	expr, ok := importMatchExpr[matchOn]
+	if !ok {
+		return nil, fmt.Errorf("unknown import match field %q", matchOn)
+	}
+	found := map[string]bool{}
+	if len(keys) == 0 {
+		return found, nil
+	}
+	rows, err := s.db.Query(ctx, `
+		SELECT DISTINCT `+expr+` FROM attendees
+		WHERE event_id = $1 AND deleted_at IS NULL AND `+expr+` = ANY($2)`, eventID, keys)
+	if err != nil {
+		return nil, fmt.Errorf("existing import match keys: %w", err)
+	}
+	matched, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("existing import match keys: %w", err)
+	}
+	for _, k := range matched {
+		found[k] = true
+	}
+	return found, nil
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrImportMappingNotFound is returned by UpdateImportMapping and
+// DeleteImportMapping when the tenant has no mapping with that ID.
+var ErrImportMappingNotFound = errors.New("import mapping not found")
+
+const importMappingColumnsSQL = `id, name, columns, created_at, updated_at`
+
+func scanImportMapping(row pgx.Row, tenantID uuid.UUID) (*models.ImportMapping, error) {
+	m := models.ImportMapping{TenantID: tenantID}
+	var columnsJSON []byte
+	if err := row.Scan(&m.ID, &m.Name, &columnsJSON, &m.CreatedAt, &m.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(columnsJSON, &m.Columns); err != nil {
+		return nil, fmt.Errorf("unmarshal import mapping columns: %w", err)
+	}
+	if m.Columns == nil {
+		m.Columns = map[string]string{}
+	}
+	return &m, nil
+}
+
+// ListImportMappings returns the tenant's saved import mappings by name.
+func (s *PGStore) ListImportMappings(ctx context.Context, tenantID uuid.UUID) ([]*models.ImportMapping, error) {
+	rows, err := s.db.Query(ctx, `SELECT `+importMappingColumnsSQL+`
+		FROM import_mappings WHERE tenant_id = $1 ORDER BY name`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("query import mappings: %w", err)
+	}
+	defer rows.Close()
+	mappings := []*models.ImportMapping{}
+	for rows.Next() {
+		m, err := scanImportMapping(rows, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, rows.Err()
+}
+
+// GetImportMapping returns one of the tenant's mappings, or (nil, nil) when
+// it does not exist or belongs to another tenant.
+func (s *PGStore) GetImportMapping(ctx context.Context, tenantID, id uuid.UUID) (*models.ImportMapping, error) {
+	m, err := scanImportMapping(s.db.QueryRow(ctx, `SELECT `+importMappingColumnsSQL+`
+		FROM import_mappings WHERE tenant_id = $1 AND id = $2`, tenantID, id), tenantID)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return m, err
+}
+
+// CreateImportMapping inserts m under m.TenantID and fills its ID and
+// timestamps. A duplicate name within the tenant surfaces as the raw
+// unique-violation (23505) error.
+func (s *PGStore) CreateImportMapping(ctx context.Context, m *models.ImportMapping) error {
+	columnsJSON, err := json.Marshal(m.Columns)
+	if err != nil {
+		return err
+	}
+	return s.db.QueryRow(ctx, `
+		INSERT INTO import_mappings (tenant_id, name, columns)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at`,
+		m.TenantID, m.Name, columnsJSON,
+	).Scan(&m.ID, &m.CreatedAt, &m.UpdatedAt)
+}
+
+// UpdateImportMapping replaces the name and columns of the tenant's mapping
+// m.ID. It returns ErrImportMappingNotFound when no such mapping exists for
+// the tenant.
+func (s *PGStore) UpdateImportMapping(ctx context.Context, m *models.ImportMapping) error {
+	columnsJSON, err := json.Marshal(m.Columns)
+	if err != nil {
+		return err
+	}
+	err = s.db.QueryRow(ctx, `
+		UPDATE import_mappings SET name = $3, columns = $4, updated_at = now()
+		WHERE tenant_id = $1 AND id = $2
+		RETURNING created_at, updated_at`,
+		m.TenantID, m.ID, m.Name, columnsJSON,
+	).Scan(&m.CreatedAt, &m.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return ErrImportMappingNotFound
+	}
+	return err
+}
+
+// DeleteImportMapping removes the tenant's mapping id, returning
+// ErrImportMappingNotFound when there was none.
+func (s *PGStore) DeleteImportMapping(ctx context.Context, tenantID, id uuid.UUID) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM import_mappings WHERE tenant_id = $1 AND id = $2`, tenantID, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrImportMappingNotFound
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+func TestListImportMappingsScopesToTenant(t *testing.T) {
+	mock := newImportMock(t)
+	tenantID, id := uuid.New(), uuid.New()
+	now := time.Now()
+	mock.ExpectQuery(`FROM import_mappings WHERE tenant_id = \$1 ORDER BY name`).
+		WithArgs(tenantID).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "name", "columns", "created_at", "updated_at"}).
+			AddRow(id, "Timepad", []byte(`{"Имя":"first_name"}`), now, now))
+
+	got, err := (&PGStore{db: mock}).ListImportMappings(context.Background(), tenantID)
+	if err != nil {
+		t.Fatalf("ListImportMappings: %v", err)
+	}
+	if len(got) != 1 || got[0].TenantID != tenantID || got[0].Columns["Имя"] != "first_name" {
+		t.Fatalf("mappings = %+v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestImportMappingWritesReportNotFound(t *testing.T) {
+	mock := newImportMock(t)
+	tenantID, id := uuid.New(), uuid.New()
+	mock.ExpectQuery(`UPDATE import_mappings SET name = \$3, columns = \$4`).
+		WithArgs(tenantID, id, "Renamed", []byte(`{}`)).
+		WillReturnRows(pgxmock.NewRows([]string{"created_at", "updated_at"}))
+	mock.ExpectExec(`DELETE FROM import_mappings WHERE tenant_id = \$1 AND id = \$2`).
+		WithArgs(tenantID, id).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+
+	s := &PGStore{db: mock}
+	m := &models.ImportMapping{ID: id, TenantID: tenantID, Name: "Renamed", Columns: map[string]string{}}
+	if err := s.UpdateImportMapping(context.Background(), m); !errors.Is(err, ErrImportMappingNotFound) {
+		t.Errorf("UpdateImportMapping err = %v, want ErrImportMappingNotFound", err)
+	}
+	if err := s.DeleteImportMapping(context.Background(), tenantID, id); !errors.Is(err, ErrImportMappingNotFound) {
+		t.Errorf("DeleteImportMapping err = %v, want ErrImportMappingNotFound", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+const importMatchSQL = `SELECT id FROM attendees\s+WHERE event_id = \$1 AND deleted_at IS NULL AND `
+
+func newImportMock(t *testing.T) pgxmock.PgxPoolIface {
+	t.Helper()
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock.NewPool: %v", err)
+	}
+	t.Cleanup(mock.Close)
+	return mock
+}
+
+// A matched row is updated with only profile columns; the UPDATE never
+// names a check-in column, and a zero-row UPDATE (nothing differs) is
+// reported as unchanged.
+func TestImportAttendeeUpsertMatchedUnchanged(t *testing.T) {
+	mock := newImportMock(t)
+	eventID, id := uuid.New(), uuid.New()
+	row := AttendeeImportRow{EventID: eventID, ExternalID: strPtr("A-1"), FirstName: strPtr("Ann")}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(importMatchSQL+`external_id = \$2`).
+		WithArgs(eventID, "A-1").
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(id))
+	mock.ExpectExec(`UPDATE attendees SET\s+external_id = COALESCE\(\$2, external_id\)`).
+		WithArgs(id, row.ExternalID, row.FirstName, row.LastName, row.Email, row.Company, row.Position, row.Code, []byte(nil)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectCommit()
+
+	s := &PGStore{db: mock}
+	gotID, status, err := s.ImportAttendee(context.Background(), row, models.ImportModeUpsert, models.ImportMatchExternalID)
+	if err != nil || gotID != id || status != models.ImportRowUnchanged {
+		t.Fatalf("ImportAttendee = %v, %q, %v; want %v, unchanged, nil", gotID, status, err, id)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestImportAttendeeUpsertInsertsWhenUnmatched(t *testing.T) {
+	mock := newImportMock(t)
+	eventID, id := uuid.New(), uuid.New()
+	row := AttendeeImportRow{EventID: eventID, Email: strPtr("Ann@X.com"), FirstName: strPtr("Ann"), FallbackCode: "GEN1"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(importMatchSQL+`lower\(email\) = \$2`).
+		WithArgs(eventID, "ann@x.com").
+		WillReturnRows(pgxmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`INSERT INTO attendees \(event_id, external_id, first_name, last_name, email, company, position, code, custom_fields\)`).
+		WithArgs(eventID, row.ExternalID, row.FirstName, row.LastName, row.Email, row.Company, row.Position, "GEN1", []byte(nil)).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(id))
+	mock.ExpectCommit()
+
+	s := &PGStore{db: mock}
+	gotID, status, err := s.ImportAttendee(context.Background(), row, models.ImportModeUpsert, models.ImportMatchEmail)
+	if err != nil || gotID != id || status != models.ImportRowCreated {
+		t.Fatalf("ImportAttendee = %v, %q, %v; want %v, created, nil", gotID, status, err, id)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestImportAttendeeUpdateOnlyNoMatch(t *testing.T) {
+	mock := newImportMock(t)
+	eventID := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery(importMatchSQL+`code = \$2`).
+		WithArgs(eventID, "C1").
+		WillReturnRows(pgxmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	s := &PGStore{db: mock}
+	_, _, err := s.ImportAttendee(context.Background(), AttendeeImportRow{EventID: eventID, Code: strPtr("C1")},
+		models.ImportModeUpdateOnly, models.ImportMatchCode)
+	if !errors.Is(err, ErrImportNoMatch) {
+		t.Fatalf("err = %v, want ErrImportNoMatch", err)
+	}
+}
+
+func TestImportAttendeeAmbiguousEmail(t *testing.T) {
+	mock := newImportMock(t)
+	eventID := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery(importMatchSQL).
+		WithArgs(eventID, "a@x.com").
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(uuid.New()).AddRow(uuid.New()))
+	mock.ExpectRollback()
+
+	s := &PGStore{db: mock}
+	_, _, err := s.ImportAttendee(context.Background(), AttendeeImportRow{EventID: eventID, Email: strPtr("a@x.com")},
+		models.ImportModeUpsert, models.ImportMatchEmail)
+	if !errors.Is(err, ErrImportAmbiguousMatch) {
+		t.Fatalf("err = %v, want ErrImportAmbiguousMatch", err)
+	}
+}
+
+func TestExistingImportMatchKeys(t *testing.T) {
+	mock := newImportMock(t)
+	eventID := uuid.New()
+	mock.ExpectQuery(`SELECT DISTINCT external_id FROM attendees\s+WHERE event_id = \$1 AND deleted_at IS NULL AND external_id = ANY\(\$2\)`).
+		WithArgs(eventID, []string{"A-1", "A-2"}).
+		WillReturnRows(pgxmock.NewRows([]string{"external_id"}).AddRow("A-2"))
+
+	s := &PGStore{db: mock}
+	got, err := s.ExistingImportMatchKeys(context.Background(), eventID, models.ImportMatchExternalID, []string{"A-1", "A-2"})
+	if err != nil {
+		t.Fatalf("ExistingImportMatchKeys: %v", err)
+	}
+	if got["A-1"] || !got["A-2"] {
+		t.Fatalf("got %v, want only A-2", got)
+	}
+}
+
+var previewColumns = []string{"id", "external_id", "first_name", "last_name", "email", "company", "position", "code", "custom_fields", "deleted"}
+
+// A preview reads the candidate attendees once and plays the batch in
+// memory: no transaction, no FOR UPDATE, no write. Updated vs unchanged
+// follows the UPDATE's comparison, a later row sees an earlier insert, and
+// a code held even by a soft-deleted attendee is the violation the import
+// would hit.
+func TestPreviewAttendeeImportOnlyReads(t *testing.T) {
+	mock := newImportMock(t)
+	eventID, ann, bob := uuid.New(), uuid.New(), uuid.New()
+	rows := []AttendeeImportRow{
+		{EventID: eventID, ExternalID: strPtr("A-1"), Company: strPtr("Acme")},
+		{EventID: eventID, ExternalID: strPtr("A-2"), FirstName: strPtr("Bob")},
+		{EventID: eventID, ExternalID: strPtr("A-3"), Code: strPtr("GONE"), FallbackCode: "GEN3"},
+		{EventID: eventID, ExternalID: strPtr("A-4"), FallbackCode: "GEN4"},
+		{EventID: eventID, ExternalID: strPtr("A-4"), Company: strPtr("Initech"), FallbackCode: "GEN5"},
+		{EventID: eventID, ExternalID: strPtr("A-6"), Code: strPtr("GEN4"), FallbackCode: "GEN6"},
+	}
+	mock.ExpectQuery(`SELECT id, external_id, first_name, last_name, email, company, position, code, custom_fields,\s+deleted_at IS NOT NULL\s+FROM attendees\s+WHERE event_id = \$1\s+AND \(\(deleted_at IS NULL AND \(external_id = ANY\(\$2\) OR external_id = ANY\(\$3\)\)\) OR code = ANY\(\$4\)\)$`).
+		WithArgs(eventID, pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows(previewColumns).
+			AddRow(ann, strPtr("A-1"), strPtr("Ann"), strPtr("Lee"), strPtr("ann@x.com"), strPtr("Globex"), (*string)(nil), "C1", []byte(nil), false).
+			AddRow(bob, strPtr("A-2"), strPtr("Bob"), strPtr("Ray"), strPtr("bob@x.com"), (*string)(nil), (*string)(nil), "C2", []byte(`{"vip":true}`), false).
+			AddRow(uuid.New(), strPtr("OLD"), strPtr("Gone"), strPtr("Away"), (*string)(nil), (*string)(nil), (*string)(nil), "GONE", []byte(nil), true))
+
+	got, err := (&PGStore{db: mock}).PreviewAttendeeImport(context.Background(), rows, models.ImportModeUpsert, models.ImportMatchExternalID)
+	if err != nil {
+		t.Fatalf("PreviewAttendeeImport: %v", err)
+	}
+	want := []ImportOutcome{
+		{ID: ann, Status: models.ImportRowUpdated},
+		{ID: bob, Status: models.ImportRowUnchanged},
+		{Err: ErrImportDuplicateCode},
+		{Status: models.ImportRowCreated},
+		{Status: models.ImportRowUpdated},
+		{Err: ErrImportDuplicateCode},
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || got[i].Status != want[i].Status || !errors.Is(got[i].Err, want[i].Err) {
+			t.Errorf("row %d = %+v, want %+v", i+1, got[i], want[i])
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+// Email matching is case-insensitive and refuses to pick between two
+// attendees sharing an address, like the import.
+func TestPreviewAttendeeImportAmbiguousEmail(t *testing.T) {
+	mock := newImportMock(t)
+	eventID := uuid.New()
+	rows := []AttendeeImportRow{{EventID: eventID, Email: strPtr("Pat@X.com"), FallbackCode: "GEN1"}}
+	mock.ExpectQuery(`lower\(email\) = ANY\(\$2\)`).
+		WithArgs(eventID, []string{"pat@x.com"}, []string{}, []string{"GEN1"}).
+		WillReturnRows(pgxmock.NewRows(previewColumns).
+			AddRow(uuid.New(), (*string)(nil), strPtr("Pat"), strPtr("A"), strPtr("pat@x.com"), (*string)(nil), (*string)(nil), "P1", []byte(nil), false).
+			AddRow(uuid.New(), (*string)(nil), strPtr("Pat"), strPtr("B"), strPtr("PAT@x.com"), (*string)(nil), (*string)(nil), "P2", []byte(nil), false))
+
+	got, err := (&PGStore{db: mock}).PreviewAttendeeImport(context.Background(), rows, models.ImportModeUpdateOnly, models.ImportMatchEmail)
+	if err != nil {
+		t.Fatalf("PreviewAttendeeImport: %v", err)
+	}
+	if !errors.Is(got[0].Err, ErrImportAmbiguousMatch) {
+		t.Errorf("row 1 = %+v, want an ambiguous match", got[0])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,300 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrJobLost is returned by the worker-side job methods when the worker no
+// longer holds the job: its lease ran out and another worker claimed the
+// row, or the row is gone. The worker must stop working on it.
+var ErrJobLost = errors.New("job lease lost")
+
+// JobFilter narrows ListJobs. EventID nil lists every job of the tenant;
+// Limit is expected to be validated (positive) by the caller.
+type JobFilter struct {
+	EventID *uuid.UUID
+	Limit   int
+}
+
+const jobColumnsSQL = `id, tenant_id, event_id, created_by, type, payload, status,
+	progress_done, progress_total, result, error, artifact_name, attempts,
+	created_at, started_at, finished_at, updated_at`
+
+func scanJob(row pgx.Row) (*models.Job, error) {
+	var j models.Job
+	var payload, result []byte
+	if err := row.Scan(&j.ID, &j.TenantID, &j.EventID, &j.CreatedBy, &j.Type, &payload, &j.Status,
+		&j.Progress.Done, &j.Progress.Total, &result, &j.Error, &j.ArtifactName, &j.Attempts,
+		&j.CreatedAt, &j.StartedAt, &j.FinishedAt, &j.UpdatedAt); err != nil {
+		return nil, err
+	}
+	j.Payload = payload
+	if result != nil {
+		j.Result = result
+	}
+	return &j, nil
+}
+
+// EnqueueJob inserts j as a queued job and fills in its ID and timestamps.
+func (s *PGStore) EnqueueJob(ctx context.Context, j *models.Job) error {
+	payload := j.Payload
+	if payload == nil {
+		payload = json.RawMessage(`{}`)
+	}
+	j.Status = models.JobStatusQueued
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO jobs (tenant_id, event_id, created_by, type, payload, progress_total)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at`,
+		j.TenantID, j.EventID, j.CreatedBy, j.Type, []byte(payload), j.Progress.Total,
+	).Scan(&j.ID, &j.CreatedAt, &j.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("enqueue job: %w", err)
+	}
+	return nil
+}
+
+// ClaimJob hands the oldest claimable job of one of types to workerID and
+// leases it for lease: a queued job, or a running one whose lease expired
+// (its worker died). FOR UPDATE SKIP LOCKED lets any number of workers on
+// any number of replicas claim concurrently without ever sharing a job.
+// attempts counts claims, so the caller can give up on a job that keeps
+// killing its worker. It returns (nil, nil) when nothing is claimable.
+func (s *PGStore) ClaimJob(ctx context.Context, workerID string, types []string, lease time.Duration) (*models.Job, error) {
+	j, err := scanJob(s.db.QueryRow(ctx, `
+		UPDATE jobs
+		   SET status = 'running', attempts = attempts + 1,
+		       locked_by = $1, locked_until = now() + make_interval(secs => $2),
+		       started_at = COALESCE(started_at, now()), updated_at = now()
+		 WHERE id = (
+		       SELECT id FROM jobs
+		        WHERE (status = 'queued' OR (status = 'running' AND locked_until < now()))
+		          AND type = ANY($3)
+		        ORDER BY created_at
+		        LIMIT 1
+		        FOR UPDATE SKIP LOCKED)
+		RETURNING `+jobColumnsSQL,
+		workerID, lease.Seconds(), types))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("claim job: %w", err)
+	}
+	return j, nil
+}
+
+// UpdateJobProgress records progress on a job workerID holds and extends
+// its lease — the worker's heartbeat. ErrJobLost when the lease is gone.
+func (s *PGStore) UpdateJobProgress(ctx context.Context, id uuid.UUID, workerID string, progress models.JobProgress, lease time.Duration) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE jobs
+		   SET progress_done = $3, progress_total = $4,
+		       locked_until = now() + make_interval(secs => $5), updated_at = now()
+		 WHERE id = $1 AND locked_by = $2 AND status = 'running'`,
+		id, workerID, progress.Done, progress.Total, lease.Seconds())
+	if err != nil {
+		return fmt.Errorf("update job progress: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrJobLost
+	}
+	return nil
+}
+
+// jobArtifactChunkSize is the size of one job_artifact_chunks row.
+const jobArtifactChunkSize = 1 << 20
+
+// CompleteJob marks a job workerID holds as succeeded with its result and
+// optional artifact, whose Content is copied into job_artifact_chunks in
+// the same transaction, one chunk at a time. ErrJobLost when the lease is
+// gone — the job's outcome then belongs to whichever worker claimed it
+// since.
+func (s *PGStore) CompleteJob(ctx context.Context, id uuid.UUID, workerID string, progress models.JobProgress, result json.RawMessage, artifact *models.JobArtifact) error {
+	var name, contentType *string
+	var size *int64
+	if artifact != nil {
+		name, contentType, size = &artifact.Name, &artifact.ContentType, &artifact.Size
+	}
+	var resultBytes []byte
+	if result != nil {
+		resultBytes = result
+	}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("complete job: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Printf("rollback job completion: %v", err)
+		}
+	}()
+	tag, err := tx.Exec(ctx, `
+		UPDATE jobs
+		   SET status = 'succeeded', progress_done = $3, progress_total = $4, result = $5,
+		       artifact_name = $6, artifact_content_type = $7, artifact_size = $8,
+		       locked_by = NULL, locked_until = NULL, finished_at = now(), updated_at = now()
+		 WHERE id = $1 AND locked_by = $2 AND status = 'running'`,
+		id, workerID, progress.Done, progress.Total, resultBytes, name, contentType, size)
+	if err != nil {
+		return fmt.Errorf("complete job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrJobLost
+	}
+	if artifact != nil {
+		buf := make([]byte, jobArtifactChunkSize)
+		for seq := 0; ; seq++ {
+			n, err := io.ReadFull(artifact.Content, buf)
+			if n > 0 {
+				if _, err := tx.Exec(ctx,
+					`INSERT INTO job_artifact_chunks (job_id, seq, data) VALUES ($1, $2, $3)`,
+					id, seq, buf[:n]); err != nil {
+					return fmt.Errorf("store job artifact: %w", err)
+				}
+			}
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("read job artifact: %w", err)
+			}
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("complete job: %w", err)
+	}
+	return nil
+}
+
+// FailJob marks a job workerID holds as failed with msg. ErrJobLost when
+// the lease is gone.
+func (s *PGStore) FailJob(ctx context.Context, id uuid.UUID, workerID, msg string) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE jobs
+		   SET status = 'failed', error = $3,
+		       locked_by = NULL, locked_until = NULL, finished_at = now(), updated_at = now()
+		 WHERE id = $1 AND locked_by = $2 AND status = 'running'`,
+		id, workerID, msg)
+	if err != nil {
+		return fmt.Errorf("fail job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrJobLost
+	}
+	return nil
+}
+
+// GetJob returns one of the tenant's jobs, or (nil, nil) when it does not
+// exist or belongs to another tenant.
+func (s *PGStore) GetJob(ctx context.Context, tenantID, id uuid.UUID) (*models.Job, error) {
+	j, err := scanJob(s.db.QueryRow(ctx, `SELECT `+jobColumnsSQL+`
+		FROM jobs WHERE tenant_id = $1 AND id = $2`, tenantID, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get job: %w", err)
+	}
+	return j, nil
+}
+
+// ListJobs returns the tenant's most recent jobs, newest first.
+func (s *PGStore) ListJobs(ctx context.Context, tenantID uuid.UUID, f JobFilter) ([]*models.Job, error) {
+	rows, err := s.db.Query(ctx, `SELECT `+jobColumnsSQL+`
+		FROM jobs
+		WHERE tenant_id = $1 AND ($2::uuid IS NULL OR event_id = $2)
+		ORDER BY created_at DESC
+		LIMIT $3`, tenantID, f.EventID, f.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("query jobs: %w", err)
+	}
+	defer rows.Close()
+	jobs := []*models.Job{}
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// GetJobArtifact describes a tenant job's artifact (without Content), or
+// returns (nil, nil) when the job does not exist, belongs to another
+// tenant, or has no artifact. WriteJobArtifact streams its bytes.
+func (s *PGStore) GetJobArtifact(ctx context.Context, tenantID, id uuid.UUID) (*models.JobArtifact, error) {
+	var name, contentType *string
+	var size *int64
+	err := s.db.QueryRow(ctx, `
+		SELECT artifact_name, artifact_content_type, artifact_size
+		FROM jobs WHERE tenant_id = $1 AND id = $2`, tenantID, id,
+	).Scan(&name, &contentType, &size)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get job artifact: %w", err)
+	}
+	if name == nil || size == nil {
+		return nil, nil
+	}
+	a := &models.JobArtifact{Name: *name, Size: *size}
+	if contentType != nil {
+		a.ContentType = *contentType
+	}
+	return a, nil
+}
+
+// WriteJobArtifact copies a tenant job's artifact into w chunk by chunk,
+// in order; a foreign or missing job writes nothing.
+func (s *PGStore) WriteJobArtifact(ctx context.Context, tenantID, id uuid.UUID, w io.Writer) error {
+	rows, err := s.db.Query(ctx, `
+		SELECT c.data
+		FROM job_artifact_chunks c
+		JOIN jobs j ON j.id = c.job_id
+		WHERE j.tenant_id = $1 AND c.job_id = $2
+		ORDER BY c.seq`, tenantID, id)
+	if err != nil {
+		return fmt.Errorf("query job artifact: %w", err)
+	}
+	defer rows.Close()
+	var chunk []byte
+	for rows.Next() {
+		if err := rows.Scan(&chunk); err != nil {
+			return fmt.Errorf("scan job artifact: %w", err)
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// PurgeFinishedJobs deletes jobs that finished more than retention ago and
+// reports how many were removed. retention <= 0 is a no-op.
+func (s *PGStore) PurgeFinishedJobs(ctx context.Context, retention time.Duration) (int64, error) {
+	if retention <= 0 {
+		return 0, nil
+	}
+	tag, err := s.db.Exec(ctx, `
+		DELETE FROM jobs
+		WHERE finished_at IS NOT NULL AND finished_at < now() - make_interval(secs => $1)`,
+		retention.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("purge finished jobs: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+var jobColumns = []string{"id", "tenant_id", "event_id", "created_by", "type", "payload", "status",
+	"progress_done", "progress_total", "result", "error", "artifact_name", "attempts",
+	"created_at", "started_at", "finished_at", "updated_at"}
+
+// The claim is one UPDATE over a SKIP LOCKED subselect, so concurrent
+// workers never block on or share a row, and it also takes over running
+// jobs whose lease ran out.
+func TestClaimJobSkipsLockedRowsAndRetakesExpiredLeases(t *testing.T) {
+	mock := newImportMock(t)
+	id, tenantID, eventID := uuid.New(), uuid.New(), uuid.New()
+	now := time.Now()
+	types := []string{models.JobTypeAttendeeExport, models.JobTypeAttendeeImport}
+	mock.ExpectQuery(`UPDATE jobs\s+SET status = 'running', attempts = attempts \+ 1,\s+locked_by = \$1, locked_until = now\(\) \+ make_interval\(secs => \$2\).*`+
+		`WHERE \(status = 'queued' OR \(status = 'running' AND locked_until < now\(\)\)\)\s+AND type = ANY\(\$3\)\s+ORDER BY created_at\s+LIMIT 1\s+FOR UPDATE SKIP LOCKED`).
+		WithArgs("worker-1", float64(60), types).
+		WillReturnRows(pgxmock.NewRows(jobColumns).AddRow(
+			id, tenantID, &eventID, (*uuid.UUID)(nil), models.JobTypeAttendeeExport, []byte(`{"format":"csv"}`), models.JobStatusRunning,
+			0, 0, []byte(nil), (*string)(nil), (*string)(nil), 2,
+			now, &now, (*time.Time)(nil), now))
+
+	s := &PGStore{db: mock}
+	j, err := s.ClaimJob(context.Background(), "worker-1", types, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimJob: %v", err)
+	}
+	if j.ID != id || j.TenantID != tenantID || *j.EventID != eventID || j.Attempts != 2 || string(j.Payload) != `{"format":"csv"}` {
+		t.Fatalf("job = %+v", j)
+	}
+	if j.Result != nil {
+		t.Errorf("result = %s, want nil for an unfinished job", j.Result)
+	}
+
+	mock.ExpectQuery(`UPDATE jobs`).
+		WithArgs("worker-1", float64(60), types).
+		WillReturnRows(pgxmock.NewRows(jobColumns))
+	if j, err := s.ClaimJob(context.Background(), "worker-1", types, time.Minute); j != nil || err != nil {
+		t.Fatalf("ClaimJob on an empty queue = %+v, %v; want nil, nil", j, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+// Every worker-side write after the claim is guarded on the lease holder;
+// 0 rows means another worker owns the job now.
+func TestJobWorkerWritesReportLostLease(t *testing.T) {
+	mock := newImportMock(t)
+	id := uuid.New()
+	progress := models.JobProgress{Done: 5, Total: 10}
+	mock.ExpectExec(`UPDATE jobs\s+SET progress_done = \$3, progress_total = \$4,.*WHERE id = \$1 AND locked_by = \$2 AND status = 'running'`).
+		WithArgs(id, "worker-1", 5, 10, float64(60)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET status = 'succeeded'.*WHERE id = \$1 AND locked_by = \$2 AND status = 'running'`).
+		WithArgs(id, "worker-1", 10, 10, []byte(`{"rows":10}`), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectRollback()
+	mock.ExpectExec(`SET status = 'failed', error = \$3.*WHERE id = \$1 AND locked_by = \$2 AND status = 'running'`).
+		WithArgs(id, "worker-1", "boom").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	s := &PGStore{db: mock}
+	ctx := context.Background()
+	if err := s.UpdateJobProgress(ctx, id, "worker-1", progress, time.Minute); err != nil {
+		t.Fatalf("UpdateJobProgress: %v", err)
+	}
+	artifact := &models.JobArtifact{Name: "a.csv", ContentType: "text/csv", Size: 3, Content: strings.NewReader("csv")}
+	if err := s.CompleteJob(ctx, id, "worker-1", models.JobProgress{Done: 10, Total: 10}, []byte(`{"rows":10}`), artifact); !errors.Is(err, ErrJobLost) {
+		t.Fatalf("CompleteJob = %v, want ErrJobLost", err)
+	}
+	if err := s.FailJob(ctx, id, "worker-1", "boom"); !errors.Is(err, ErrJobLost) {
+		t.Fatalf("FailJob = %v, want ErrJobLost", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestCompleteJobStoresTheArtifactInChunks(t *testing.T) {
+	mock := newImportMock(t)
+	id := uuid.New()
+	data := strings.Repeat("x", jobArtifactChunkSize) + "tail"
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET status = 'succeeded'.*artifact_size = \$8`).
+		WithArgs(id, "worker-1", 2, 2, []byte(nil), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec(`INSERT INTO job_artifact_chunks \(job_id, seq, data\) VALUES \(\$1, \$2, \$3\)`).
+		WithArgs(id, 0, []byte(data[:jobArtifactChunkSize])).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(`INSERT INTO job_artifact_chunks`).
+		WithArgs(id, 1, []byte("tail")).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	artifact := &models.JobArtifact{Name: "a.csv", ContentType: "text/csv", Size: int64(len(data)), Content: strings.NewReader(data)}
+	err := (&PGStore{db: mock}).CompleteJob(context.Background(), id, "worker-1", models.JobProgress{Done: 2, Total: 2}, nil, artifact)
+	if err != nil {
+		t.Fatalf("CompleteJob: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetJobArtifactMissing(t *testing.T) {
+	mock := newImportMock(t)
+	tenantID, id := uuid.New(), uuid.New()
+	mock.ExpectQuery(`SELECT artifact_name, artifact_content_type, artifact_size\s+FROM jobs WHERE tenant_id = \$1 AND id = \$2`).
+		WithArgs(tenantID, id).
+		WillReturnRows(pgxmock.NewRows([]string{"artifact_name", "artifact_content_type", "artifact_size"}).
+			AddRow((*string)(nil), (*string)(nil), (*int64)(nil)))
+
+	a, err := (&PGStore{db: mock}).GetJobArtifact(context.Background(), tenantID, id)
+	if a != nil || err != nil {
+		t.Fatalf("GetJobArtifact = %+v, %v; want nil, nil for a job without a file", a, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestWriteJobArtifactCopiesChunksInOrder(t *testing.T) {
+	mock := newImportMock(t)
+	tenantID, id := uuid.New(), uuid.New()
+	mock.ExpectQuery(`SELECT c.data\s+FROM job_artifact_chunks c\s+JOIN jobs j ON j.id = c.job_id\s+WHERE j.tenant_id = \$1 AND c.job_id = \$2\s+ORDER BY c.seq`).
+		WithArgs(tenantID, id).
+		WillReturnRows(pgxmock.NewRows([]string{"data"}).AddRow([]byte("code\n")).AddRow([]byte("ABC\n")))
+
+	var buf bytes.Buffer
+	if err := (&PGStore{db: mock}).WriteJobArtifact(context.Background(), tenantID, id, &buf); err != nil {
+		t.Fatalf("WriteJobArtifact: %v", err)
+	}
+	if buf.String() != "code\nABC\n" {
+		t.Errorf("artifact = %q", buf.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestPurgeFinishedJobs(t *testing.T) {
+	mock := newImportMock(t)
+	s := &PGStore{db: mock}
+	if n, err := s.PurgeFinishedJobs(context.Background(), 0); n != 0 || err != nil {
+		t.Fatalf("PurgeFinishedJobs(0) = %d, %v; want 0, nil", n, err)
+	}
+	mock.ExpectExec(`DELETE FROM jobs\s+WHERE finished_at IS NOT NULL AND finished_at < now\(\) - make_interval\(secs => \$1\)`).
+		WithArgs(float64(7 * 24 * 3600)).
+		WillReturnResult(pgxmock.NewResult("DELETE", 3))
+	if n, err := s.PurgeFinishedJobs(context.Background(), 7*24*time.Hour); n != 3 || err != nil {
+		t.Fatalf("PurgeFinishedJobs = %d, %v; want 3, nil", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
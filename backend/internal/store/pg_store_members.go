@@ -0,0 +1,290 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrMemberNotFound is returned by the membership lifecycle methods when
+// the user is not a member of the tenant.
+var ErrMemberNotFound = errors.New("tenant member not found")
+
+// memberStatusSQL derives models.User.Status from a user_tenants row ut.
+const memberStatusSQL = `CASE WHEN ut.deactivated_at IS NOT NULL THEN 'deactivated'
+	WHEN ut.invited_at IS NOT NULL THEN 'invited' ELSE 'active' END`
+
+// GetTenantMember returns userID with its role and status in tenantID,
+// deactivated memberships included, or (nil, nil) when it is not a member.
+func (s *PGStore) GetTenantMember(ctx context.Context, tenantID, userID uuid.UUID) (*models.User, error) {
+	var u models.User
+	err := s.db.QueryRow(ctx, `
+		SELECT u.id, u.tenant_id, u.email, ut.role, u.is_super_admin, u.qr_token, u.qr_token_created_at,
+		       u.email_verified_at, u.totp_enabled_at IS NOT NULL, `+memberStatusSQL+`, ut.deactivated_at,
+		       u.created_at, u.updated_at
+		FROM user_tenants ut JOIN users u ON u.id = ut.user_id
+		WHERE ut.tenant_id = $1 AND ut.user_id = $2`, tenantID, userID,
+	).Scan(&u.ID, &u.TenantID, &u.Email, &u.Role, &u.IsSuperAdmin, &u.QRToken, &u.QRTokenCreatedAt,
+		&u.EmailVerifiedAt, &u.TwoFactorEnabled, &u.Status, &u.DeactivatedAt, &u.CreatedAt, &u.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// UpdateUserTenantRole changes userID's role in tenantID (and users.role
+// when it is the user's home tenant, which QR sign-in reads). It bumps the
+// token_version so access tokens carrying the old role stop working; the
+// next refresh re-reads the new one.
+func (s *PGStore) UpdateUserTenantRole(ctx context.Context, userID, tenantID uuid.UUID, role string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && rbErr != pgx.ErrTxClosed {
+			log.Printf("Failed to rollback transaction: %v", rbErr)
+		}
+	}()
+
+	tag, err := tx.Exec(ctx, `UPDATE user_tenants SET role = $3 WHERE user_id = $1 AND tenant_id = $2`, userID, tenantID, role)
+	if err != nil {
+		return fmt.Errorf("update member role: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMemberNotFound
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE users SET role = CASE WHEN tenant_id = $2 THEN $3 ELSE role END,
+		       token_version = token_version + 1, updated_at = NOW()
+		WHERE id = $1`, userID, tenantID, role); err != nil {
+		return fmt.Errorf("bump user token version: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// RevokedStation is a station signed out along with its staff member, for
+// the caller to tell over the event's station channel.
+type RevokedStation struct {
+	ID      uuid.UUID
+	EventID uuid.UUID
+}
+
+// revokeMemberAccess signs userID out of tenantID inside tx: it clears the
+// QR sign-in token, bumps the token_version (access tokens for other
+// tenants lapse too, but their refresh tokens renew them), revokes the
+// user's refresh tokens in the tenant, revokes every station of the
+// tenant's events bound to the user and drops their unused provisioning
+// tokens. It returns how many refresh tokens were still live and the
+// stations it revoked.
+func revokeMemberAccess(ctx context.Context, tx pgx.Tx, tenantID, userID uuid.UUID) (int64, []RevokedStation, error) {
+	if _, err := tx.Exec(ctx, `
+		UPDATE users SET qr_token = NULL, qr_token_created_at = NULL,
+		       token_version = token_version + 1, updated_at = NOW()
+		WHERE id = $1`, userID); err != nil {
+		return 0, nil, fmt.Errorf("bump user token version: %w", err)
+	}
+	tag, err := tx.Exec(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND tenant_id = $2 AND revoked_at IS NULL`, userID, tenantID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("revoke member refresh tokens: %w", err)
+	}
+	rows, err := tx.Query(ctx, `
+		UPDATE stations SET token_version = token_version + 1, revoked_at = COALESCE(revoked_at, NOW())
+		WHERE staff_user_id = $1 AND event_id IN (SELECT id FROM events WHERE tenant_id = $2)
+		RETURNING id, event_id`,
+		userID, tenantID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("revoke member stations: %w", err)
+	}
+	var stations []RevokedStation
+	for rows.Next() {
+		var st RevokedStation
+		if err := rows.Scan(&st.ID, &st.EventID); err != nil {
+			rows.Close()
+			return 0, nil, fmt.Errorf("scan revoked station: %w", err)
+		}
+		stations = append(stations, st)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("revoke member stations: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM station_provisioning_tokens
+		WHERE staff_user_id = $1 AND event_id IN (SELECT id FROM events WHERE tenant_id = $2)`,
+		userID, tenantID); err != nil {
+		return 0, nil, fmt.Errorf("delete member provisioning tokens: %w", err)
+	}
+	return tag.RowsAffected(), stations, nil
+}
+
+// DeactivateMember deactivates userID's membership in tenantID and signs
+// them out of it (see revokeMemberAccess). Event and zone assignments stay
+// for a later ReactivateMember. It returns how many refresh tokens were
+// still live and the stations revoked; deactivating twice keeps the first
+// deactivation time.
+func (s *PGStore) DeactivateMember(ctx context.Context, tenantID, userID uuid.UUID) (int64, []RevokedStation, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && rbErr != pgx.ErrTxClosed {
+			log.Printf("Failed to rollback transaction: %v", rbErr)
+		}
+	}()
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE user_tenants SET deactivated_at = COALESCE(deactivated_at, NOW())
+		WHERE tenant_id = $1 AND user_id = $2`, tenantID, userID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("deactivate member: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, nil, ErrMemberNotFound
+	}
+	n, stations, err := revokeMemberAccess(ctx, tx, tenantID, userID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, nil, err
+	}
+	return n, stations, nil
+}
+
+// ReactivateMember lifts a deactivation. Sign-in tokens are not restored:
+// the member signs in again and needs a new QR badge.
+func (s *PGStore) ReactivateMember(ctx context.Context, tenantID, userID uuid.UUID) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE user_tenants SET deactivated_at = NULL WHERE tenant_id = $1 AND user_id = $2`, tenantID, userID)
+	if err != nil {
+		return fmt.Errorf("reactivate member: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMemberNotFound
+	}
+	return nil
+}
+
+// RemoveUserFromTenant ends userID's membership in tenantID: it signs them
+// out of the tenant (see revokeMemberAccess), drops their event and zone
+// assignments there and deletes the membership. The account itself stays,
+// along with its memberships elsewhere and the audit trail. It returns the
+// stations revoked.
+func (s *PGStore) RemoveUserFromTenant(ctx context.Context, userID, tenantID uuid.UUID) ([]RevokedStation, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && rbErr != pgx.ErrTxClosed {
+			log.Printf("Failed to rollback transaction: %v", rbErr)
+		}
+	}()
+
+	tag, err := tx.Exec(ctx, `DELETE FROM user_tenants WHERE user_id = $1 AND tenant_id = $2`, userID, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("delete membership: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrMemberNotFound
+	}
+	_, stations, err := revokeMemberAccess(ctx, tx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM event_staff
+		WHERE user_id = $1 AND event_id IN (SELECT id FROM events WHERE tenant_id = $2)`,
+		userID, tenantID); err != nil {
+		return nil, fmt.Errorf("delete event assignments: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM staff_zone_assignments
+		WHERE user_id = $1 AND zone_id IN (
+			SELECT z.id FROM event_zones z JOIN events e ON e.id = z.event_id WHERE e.tenant_id = $2)`,
+		userID, tenantID); err != nil {
+		return nil, fmt.Errorf("delete zone assignments: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return stations, nil
+}
+
+// CreateInvitedUser creates an account for email with no password and an
+// invited membership of tenantID with role; the invitee sets a password
+// with AcceptInvitationWithToken. A taken email surfaces as the raw
+// unique-violation (23505) error.
+func (s *PGStore) CreateInvitedUser(ctx context.Context, tenantID uuid.UUID, email, role string) (*models.User, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && rbErr != pgx.ErrTxClosed {
+			log.Printf("Failed to rollback transaction: %v", rbErr)
+		}
+	}()
+
+	u := &models.User{TenantID: tenantID, Email: email, Role: role, Status: models.MemberStatusInvited}
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO users (tenant_id, email, password_hash, role) VALUES ($1, $2, '', $3)
+		RETURNING id, created_at, updated_at`, tenantID, email, role,
+	).Scan(&u.ID, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO user_tenants (user_id, tenant_id, role, joined_at, invited_at)
+		VALUES ($1, $2, $3, NOW(), NOW())`, u.ID, tenantID, role); err != nil {
+		return nil, fmt.Errorf("add invited member: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// AcceptInvitationWithToken spends an invitation token, sets the invitee's
+// password, marks the email verified (the link proved the mailbox) and
+// turns their invited memberships active.
+func (s *PGStore) AcceptInvitationWithToken(ctx context.Context, tokenHash, passwordHash string) (uuid.UUID, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && rbErr != pgx.ErrTxClosed {
+			log.Printf("Failed to rollback transaction: %v", rbErr)
+		}
+	}()
+
+	userID, err := consumeUserToken(ctx, tx, models.UserTokenInvitation, tokenHash)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE users SET password_hash = $2, email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW()
+		WHERE id = $1`, userID, passwordHash); err != nil {
+		return uuid.Nil, fmt.Errorf("set invitee password: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE user_tenants SET invited_at = NULL WHERE user_id = $1`, userID); err != nil {
+		return uuid.Nil, fmt.Errorf("activate invited memberships: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
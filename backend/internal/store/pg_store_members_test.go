@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+// Deactivation must sign the member out of everything in the tenant: QR
+// token and token_version, refresh tokens, stations and pending
+// provisioning tokens, in one transaction.
+func TestDeactivateMemberRevokesAccess(t *testing.T) {
+	mock := newImportMock(t)
+	tenantID, userID := uuid.New(), uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE user_tenants SET deactivated_at = COALESCE\(deactivated_at, NOW\(\)\)`).
+		WithArgs(tenantID, userID).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec(`UPDATE users SET qr_token = NULL, qr_token_created_at = NULL,\s+token_version = token_version \+ 1`).
+		WithArgs(userID).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\)`).
+		WithArgs(userID, tenantID).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 3))
+	stationID, eventID := uuid.New(), uuid.New()
+	mock.ExpectQuery(`UPDATE stations SET token_version = token_version \+ 1.*RETURNING id, event_id`).
+		WithArgs(userID, tenantID).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "event_id"}).AddRow(stationID, eventID))
+	mock.ExpectExec(`DELETE FROM station_provisioning_tokens`).
+		WithArgs(userID, tenantID).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	mock.ExpectCommit()
+
+	s := &PGStore{db: mock}
+	n, stations, err := s.DeactivateMember(context.Background(), tenantID, userID)
+	if err != nil || n != 3 {
+		t.Fatalf("DeactivateMember = %d, %v; want 3, nil", n, err)
+	}
+	if len(stations) != 1 || stations[0] != (RevokedStation{ID: stationID, EventID: eventID}) {
+		t.Fatalf("revoked stations = %+v, want the member's station", stations)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRemoveUserFromTenantNotMember(t *testing.T) {
+	mock := newImportMock(t)
+	tenantID, userID := uuid.New(), uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM user_tenants WHERE user_id = \$1 AND tenant_id = \$2`).
+		WithArgs(userID, tenantID).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	mock.ExpectRollback()
+
+	s := &PGStore{db: mock}
+	if _, err := s.RemoveUserFromTenant(context.Background(), userID, tenantID); !errors.Is(err, ErrMemberNotFound) {
+		t.Fatalf("err = %v, want ErrMemberNotFound", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
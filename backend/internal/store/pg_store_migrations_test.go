@@ -1,10 +1,13 @@
 package store
 
 import (
+	"context"
 	"sort"
 	"testing"
 
 	"idento/backend/migrations"
+
+	pgxmock "github.com/pashagolub/pgxmock/v4"
 )
 
 // Regression test for a real incident: 000014_audit_indexes.up.sql and
@@ -78,3 +81,28 @@ func TestEmbeddedMigrationsHaveNoVersionCollision(t *testing.T) {
 		t.Fatalf("embedded migrations have a version collision: %q and %q both resolve to %q", first, second, version)
 	}
 }
+
+func TestGetMigrationStatusListsUnappliedVersions(t *testing.T) {
+	files, err := embeddedMigrationFiles()
+	if err != nil || len(files) < 2 {
+		t.Fatalf("embeddedMigrationFiles = %v, %v", files, err)
+	}
+	latest := migrationVersion(files[len(files)-1])
+	previous := migrationVersion(files[len(files)-2])
+
+	mock := newImportMock(t)
+	rows := pgxmock.NewRows([]string{"version"})
+	for _, f := range files[:len(files)-1] {
+		rows.AddRow(migrationVersion(f))
+	}
+	mock.ExpectQuery(`SELECT version FROM schema_migrations`).WillReturnRows(rows)
+	s := &PGStore{db: mock}
+
+	st, err := s.GetMigrationStatus(context.Background())
+	if err != nil {
+		t.Fatalf("GetMigrationStatus: %v", err)
+	}
+	if st.Latest != latest || st.Applied != previous || len(st.Pending) != 1 || st.Pending[0] != latest {
+		t.Errorf("status = %+v, want latest %s applied %s pending [%s]", st, latest, previous, latest)
+	}
+}
@@ -0,0 +1,260 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrOIDCLoginRequestInvalid is returned by ConsumeOIDCLoginRequest for an
+// unknown, already used or expired state.
+var ErrOIDCLoginRequestInvalid = errors.New("oidc login request invalid")
+
+// ErrOIDCEmailUnverified is returned by LinkOIDCIdentity when the provider
+// does not vouch for an email that belongs to an existing user: linking it
+// would hand that account to whoever controls the provider account.
+var ErrOIDCEmailUnverified = errors.New("oidc email not verified")
+
+// ErrOIDCAccountConflict is returned by LinkOIDCIdentity when the user is a
+// super admin or a member of another tenant. A tenant's identity provider
+// may only sign in accounts confined to that tenant.
+var ErrOIDCAccountConflict = errors.New("account belongs to another organization")
+
+// ErrOIDCMemberDeactivated is returned by LinkOIDCIdentity when the user's
+// membership of the tenant is deactivated: the provider still knows the
+// account, but the organization has signed it out.
+var ErrOIDCMemberDeactivated = errors.New("oidc member deactivated")
+
+// GetTenantOIDCProvider returns the tenant's SSO configuration, or nil when
+// it has none.
+func (s *PGStore) GetTenantOIDCProvider(ctx context.Context, tenantID uuid.UUID) (*models.TenantOIDCProvider, error) {
+	var (
+		p           models.TenantOIDCProvider
+		mappingJSON []byte
+	)
+	err := s.db.QueryRow(ctx, `
+		SELECT tenant_id, issuer, client_id, client_secret, allowed_domains, role_claim, role_mapping,
+		       default_role, enabled, created_at, updated_at
+		FROM tenant_oidc_providers WHERE tenant_id = $1`, tenantID,
+	).Scan(&p.TenantID, &p.Issuer, &p.ClientID, &p.ClientSecret, &p.AllowedDomains, &p.RoleClaim, &mappingJSON,
+		&p.DefaultRole, &p.Enabled, &p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get oidc provider: %w", err)
+	}
+	if err := json.Unmarshal(mappingJSON, &p.RoleMapping); err != nil {
+		return nil, fmt.Errorf("decode oidc role mapping: %w", err)
+	}
+	return &p, nil
+}
+
+// UpsertTenantOIDCProvider creates or replaces the tenant's SSO
+// configuration. An empty ClientSecret keeps the stored one.
+func (s *PGStore) UpsertTenantOIDCProvider(ctx context.Context, p *models.TenantOIDCProvider) error {
+	mapping := p.RoleMapping
+	if mapping == nil {
+		mapping = map[string]string{}
+	}
+	mappingJSON, err := json.Marshal(mapping)
+	if err != nil {
+		return err
+	}
+	err = s.db.QueryRow(ctx, `
+		INSERT INTO tenant_oidc_providers
+			(tenant_id, issuer, client_id, client_secret, allowed_domains, role_claim, role_mapping, default_role, enabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (tenant_id) DO UPDATE SET
+			issuer = EXCLUDED.issuer,
+			client_id = EXCLUDED.client_id,
+			client_secret = COALESCE(NULLIF(EXCLUDED.client_secret, ''), tenant_oidc_providers.client_secret),
+			allowed_domains = EXCLUDED.allowed_domains,
+			role_claim = EXCLUDED.role_claim,
+			role_mapping = EXCLUDED.role_mapping,
+			default_role = EXCLUDED.default_role,
+			enabled = EXCLUDED.enabled,
+			updated_at = NOW()
+		RETURNING created_at, updated_at`,
+		p.TenantID, p.Issuer, p.ClientID, p.ClientSecret, p.AllowedDomains, p.RoleClaim, mappingJSON, p.DefaultRole, p.Enabled,
+	).Scan(&p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("upsert oidc provider: %w", err)
+	}
+	return nil
+}
+
+// DeleteTenantOIDCProvider removes the tenant's SSO configuration and its
+// identity links. It reports whether there was one.
+func (s *PGStore) DeleteTenantOIDCProvider(ctx context.Context, tenantID uuid.UUID) (bool, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && rbErr != pgx.ErrTxClosed {
+			log.Printf("Failed to rollback transaction: %v", rbErr)
+		}
+	}()
+	tag, err := tx.Exec(ctx, `DELETE FROM tenant_oidc_providers WHERE tenant_id = $1`, tenantID)
+	if err != nil {
+		return false, fmt.Errorf("delete oidc provider: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM user_identities WHERE tenant_id = $1`, tenantID); err != nil {
+		return false, fmt.Errorf("delete identities: %w", err)
+	}
+	return tag.RowsAffected() == 1, tx.Commit(ctx)
+}
+
+// FindSSOTenantsByEmailDomain returns the active tenants with enabled SSO
+// that accept emails from domain.
+func (s *PGStore) FindSSOTenantsByEmailDomain(ctx context.Context, domain string) ([]models.SSOTenant, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT t.id, t.name
+		FROM tenant_oidc_providers p
+		JOIN tenants t ON t.id = p.tenant_id
+		WHERE p.enabled AND t.status = 'active' AND p.allowed_domains @> ARRAY[$1]::text[]
+		ORDER BY t.name`, domain)
+	if err != nil {
+		return nil, fmt.Errorf("find sso tenants: %w", err)
+	}
+	defer rows.Close()
+	tenants := []models.SSOTenant{}
+	for rows.Next() {
+		var t models.SSOTenant
+		if err := rows.Scan(&t.TenantID, &t.TenantName); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+// CreateOIDCLoginRequest stores an authorization request in flight and
+// sweeps expired ones.
+func (s *PGStore) CreateOIDCLoginRequest(ctx context.Context, r *models.OIDCLoginRequest) error {
+	_, err := s.db.Exec(ctx, `
+		WITH swept AS (DELETE FROM oidc_login_requests WHERE expires_at < NOW())
+		INSERT INTO oidc_login_requests (state_hash, tenant_id, code_verifier, nonce, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		r.StateHash, r.TenantID, r.CodeVerifier, r.Nonce, r.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("create oidc login request: %w", err)
+	}
+	return nil
+}
+
+// ConsumeOIDCLoginRequest spends the request for stateHash and returns it.
+func (s *PGStore) ConsumeOIDCLoginRequest(ctx context.Context, stateHash string) (*models.OIDCLoginRequest, error) {
+	r := models.OIDCLoginRequest{StateHash: stateHash}
+	err := s.db.QueryRow(ctx, `
+		DELETE FROM oidc_login_requests WHERE state_hash = $1 AND expires_at > NOW()
+		RETURNING tenant_id, code_verifier, nonce, expires_at`, stateHash,
+	).Scan(&r.TenantID, &r.CodeVerifier, &r.Nonce, &r.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrOIDCLoginRequestInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("consume oidc login request: %w", err)
+	}
+	return &r, nil
+}
+
+// LinkOIDCIdentity resolves a signed-in provider account to an Idento user
+// in one transaction: by an existing link, else by email (linking it),
+// else by creating a password-less user with a verified email. It then
+// ensures the tenant membership and returns the user with their role
+// there. A deactivated membership is left as it is and fails with
+// ErrOIDCMemberDeactivated.
+func (s *PGStore) LinkOIDCIdentity(ctx context.Context, id models.OIDCIdentity) (*models.User, string, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && rbErr != pgx.ErrTxClosed {
+			log.Printf("Failed to rollback transaction: %v", rbErr)
+		}
+	}()
+
+	var userID uuid.UUID
+	err = tx.QueryRow(ctx, `
+		UPDATE user_identities SET last_login_at = NOW()
+		WHERE tenant_id = $1 AND issuer = $2 AND subject = $3
+		RETURNING user_id`, id.TenantID, id.Issuer, id.Subject).Scan(&userID)
+	switch {
+	case err == nil:
+	case errors.Is(err, pgx.ErrNoRows):
+		err = tx.QueryRow(ctx, `SELECT id FROM users WHERE email = $1`, id.Email).Scan(&userID)
+		switch {
+		case err == nil:
+			if !id.EmailVerified {
+				return nil, "", ErrOIDCEmailUnverified
+			}
+		case errors.Is(err, pgx.ErrNoRows):
+			if err := tx.QueryRow(ctx, `
+				INSERT INTO users (tenant_id, email, password_hash, role, email_verified_at)
+				VALUES ($1, $2, '', $3, NOW())
+				RETURNING id`, id.TenantID, id.Email, id.Role).Scan(&userID); err != nil {
+				return nil, "", fmt.Errorf("create sso user: %w", err)
+			}
+		default:
+			return nil, "", fmt.Errorf("find user by email: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO user_identities (tenant_id, user_id, issuer, subject) VALUES ($1, $2, $3, $4)`,
+			id.TenantID, userID, id.Issuer, id.Subject); err != nil {
+			return nil, "", fmt.Errorf("link identity: %w", err)
+		}
+	default:
+		return nil, "", fmt.Errorf("find identity: %w", err)
+	}
+
+	var conflict bool
+	if err := tx.QueryRow(ctx, `
+		SELECT u.is_super_admin OR EXISTS (
+			SELECT 1 FROM user_tenants ut WHERE ut.user_id = u.id AND ut.tenant_id <> $2)
+		FROM users u WHERE u.id = $1`, userID, id.TenantID).Scan(&conflict); err != nil {
+		return nil, "", fmt.Errorf("check memberships: %w", err)
+	}
+	if conflict {
+		return nil, "", ErrOIDCAccountConflict
+	}
+
+	// A deactivated membership makes the conflict update match no row, so
+	// nothing is returned.
+	var role string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO user_tenants (id, user_id, tenant_id, role, joined_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (user_id, tenant_id) DO UPDATE
+			SET role = CASE WHEN $5 THEN EXCLUDED.role ELSE user_tenants.role END
+			WHERE user_tenants.deactivated_at IS NULL
+		RETURNING role`, uuid.New(), userID, id.TenantID, id.Role, id.SyncRole).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, "", ErrOIDCMemberDeactivated
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("ensure membership: %w", err)
+	}
+
+	var u models.User
+	if err := tx.QueryRow(ctx, `
+		SELECT id, tenant_id, email, password_hash, role, is_super_admin, qr_token, qr_token_created_at, email_verified_at, totp_enabled_at IS NOT NULL, created_at, updated_at
+		FROM users WHERE id = $1`, userID).Scan(
+		&u.ID, &u.TenantID, &u.Email, &u.PasswordHash, &u.Role, &u.IsSuperAdmin, &u.QRToken, &u.QRTokenCreatedAt, &u.EmailVerifiedAt, &u.TwoFactorEnabled, &u.CreatedAt, &u.UpdatedAt,
+	); err != nil {
+		return nil, "", fmt.Errorf("load user: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, "", err
+	}
+	return &u, role, nil
+}
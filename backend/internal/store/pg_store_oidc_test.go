@@ -0,0 +1,165 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+func testOIDCIdentity() models.OIDCIdentity {
+	return models.OIDCIdentity{
+		TenantID: uuid.New(),
+		Issuer:   "https://idp.example",
+		Subject:  "u-42",
+		Email:    "anna@corp.example",
+		Role:     "manager",
+		SyncRole: true,
+	}
+}
+
+// A first sign-in for an unknown email creates the user, links the
+// identity and adds the membership with the mapped role.
+func TestLinkOIDCIdentityProvisionsUser(t *testing.T) {
+	mock := newImportMock(t)
+	id := testOIDCIdentity()
+	userID := uuid.New()
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE user_identities SET last_login_at = NOW\(\)`).
+		WithArgs(id.TenantID, id.Issuer, id.Subject).
+		WillReturnError(pgx.ErrNoRows)
+	mock.ExpectQuery(`SELECT id FROM users WHERE email = \$1`).
+		WithArgs(id.Email).
+		WillReturnError(pgx.ErrNoRows)
+	mock.ExpectQuery(`INSERT INTO users \(tenant_id, email, password_hash, role, email_verified_at\)`).
+		WithArgs(id.TenantID, id.Email, "manager").
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(userID))
+	mock.ExpectExec(`INSERT INTO user_identities`).
+		WithArgs(id.TenantID, userID, id.Issuer, id.Subject).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectQuery(`SELECT u.is_super_admin OR EXISTS`).
+		WithArgs(userID, id.TenantID).
+		WillReturnRows(pgxmock.NewRows([]string{"conflict"}).AddRow(false))
+	mock.ExpectQuery(`INSERT INTO user_tenants`).
+		WithArgs(pgxmock.AnyArg(), userID, id.TenantID, "manager", true).
+		WillReturnRows(pgxmock.NewRows([]string{"role"}).AddRow("manager"))
+	mock.ExpectQuery(`SELECT id, tenant_id, email, password_hash`).
+		WithArgs(userID).
+		WillReturnRows(pgxmock.NewRows([]string{
+			"id", "tenant_id", "email", "password_hash", "role", "is_super_admin", "qr_token", "qr_token_created_at",
+			"email_verified_at", "two_factor_enabled", "created_at", "updated_at",
+		}).AddRow(userID, id.TenantID, id.Email, "", "manager", false, nil, nil, &now, false, now, now))
+	mock.ExpectCommit()
+
+	s := &PGStore{db: mock}
+	user, role, err := s.LinkOIDCIdentity(context.Background(), id)
+	if err != nil {
+		t.Fatalf("LinkOIDCIdentity: %v", err)
+	}
+	if user.ID != userID || role != "manager" {
+		t.Errorf("got user %s role %s", user.ID, role)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+// An existing password account is only linked when the provider vouches
+// for the email.
+func TestLinkOIDCIdentityRequiresVerifiedEmail(t *testing.T) {
+	mock := newImportMock(t)
+	id := testOIDCIdentity()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE user_identities SET last_login_at = NOW\(\)`).
+		WithArgs(id.TenantID, id.Issuer, id.Subject).
+		WillReturnError(pgx.ErrNoRows)
+	mock.ExpectQuery(`SELECT id FROM users WHERE email = \$1`).
+		WithArgs(id.Email).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectRollback()
+
+	s := &PGStore{db: mock}
+	if _, _, err := s.LinkOIDCIdentity(context.Background(), id); !errors.Is(err, ErrOIDCEmailUnverified) {
+		t.Fatalf("err = %v, want ErrOIDCEmailUnverified", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+// A linked account that is a member of another tenant is refused rather
+// than pulled into this one.
+func TestLinkOIDCIdentityRejectsOtherTenantMember(t *testing.T) {
+	mock := newImportMock(t)
+	id := testOIDCIdentity()
+	userID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE user_identities SET last_login_at = NOW\(\)`).
+		WithArgs(id.TenantID, id.Issuer, id.Subject).
+		WillReturnRows(pgxmock.NewRows([]string{"user_id"}).AddRow(userID))
+	mock.ExpectQuery(`SELECT u.is_super_admin OR EXISTS`).
+		WithArgs(userID, id.TenantID).
+		WillReturnRows(pgxmock.NewRows([]string{"conflict"}).AddRow(true))
+	mock.ExpectRollback()
+
+	s := &PGStore{db: mock}
+	if _, _, err := s.LinkOIDCIdentity(context.Background(), id); !errors.Is(err, ErrOIDCAccountConflict) {
+		t.Fatalf("err = %v, want ErrOIDCAccountConflict", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+// A deactivated member stays signed out: the provider sign-in neither
+// reactivates nor touches the membership.
+func TestLinkOIDCIdentityRejectsDeactivatedMember(t *testing.T) {
+	mock := newImportMock(t)
+	id := testOIDCIdentity()
+	userID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE user_identities SET last_login_at = NOW\(\)`).
+		WithArgs(id.TenantID, id.Issuer, id.Subject).
+		WillReturnRows(pgxmock.NewRows([]string{"user_id"}).AddRow(userID))
+	mock.ExpectQuery(`SELECT u.is_super_admin OR EXISTS`).
+		WithArgs(userID, id.TenantID).
+		WillReturnRows(pgxmock.NewRows([]string{"conflict"}).AddRow(false))
+	mock.ExpectQuery(`INSERT INTO user_tenants .*ON CONFLICT \(user_id, tenant_id\) DO UPDATE.*WHERE user_tenants.deactivated_at IS NULL`).
+		WithArgs(pgxmock.AnyArg(), userID, id.TenantID, "manager", true).
+		WillReturnError(pgx.ErrNoRows)
+	mock.ExpectRollback()
+
+	s := &PGStore{db: mock}
+	if _, _, err := s.LinkOIDCIdentity(context.Background(), id); !errors.Is(err, ErrOIDCMemberDeactivated) {
+		t.Fatalf("err = %v, want ErrOIDCMemberDeactivated", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestConsumeOIDCLoginRequestSpentState(t *testing.T) {
+	mock := newImportMock(t)
+	mock.ExpectQuery(`DELETE FROM oidc_login_requests WHERE state_hash = \$1 AND expires_at > NOW\(\)`).
+		WithArgs("h").
+		WillReturnError(pgx.ErrNoRows)
+
+	s := &PGStore{db: mock}
+	if _, err := s.ConsumeOIDCLoginRequest(context.Background(), "h"); !errors.Is(err, ErrOIDCLoginRequestInvalid) {
+		t.Fatalf("err = %v, want ErrOIDCLoginRequestInvalid", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
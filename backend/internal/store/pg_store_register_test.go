@@ -47,8 +47,8 @@ func TestProvisionTenantExistingEmailWrongPasswordRollsBack(t *testing.T) {
 	expectTenantProvision(mock, "Evil Org", tenantID, planID, now)
 	mock.ExpectQuery(`SELECT id, tenant_id, role, is_super_admin, password_hash`).
 		WithArgs("victim@acme.test").
-		WillReturnRows(pgxmock.NewRows([]string{"id", "tenant_id", "role", "is_super_admin", "password_hash", "created_at", "updated_at"}).
-			AddRow(userID, uuid.New(), "admin", false, string(storedHash), now, now))
+		WillReturnRows(pgxmock.NewRows([]string{"id", "tenant_id", "role", "is_super_admin", "password_hash", "email_verified_at", "two_factor_enabled", "created_at", "updated_at"}).
+			AddRow(userID, uuid.New(), "admin", false, string(storedHash), (*time.Time)(nil), false, now, now))
 	// Rollback comes straight after the user lookup: if the code regresses to
 	// attaching the membership without verifying the password, the unexpected
 	// user_tenants INSERT (or commit) breaks this script.
@@ -84,8 +84,8 @@ func TestProvisionTenantExistingEmailCorrectPasswordAttachesMembership(t *testin
 	expectTenantProvision(mock, "Second Org", tenantID, planID, now)
 	mock.ExpectQuery(`SELECT id, tenant_id, role, is_super_admin, password_hash`).
 		WithArgs("owner@acme.test").
-		WillReturnRows(pgxmock.NewRows([]string{"id", "tenant_id", "role", "is_super_admin", "password_hash", "created_at", "updated_at"}).
-			AddRow(userID, uuid.New(), "admin", false, string(storedHash), now, now))
+		WillReturnRows(pgxmock.NewRows([]string{"id", "tenant_id", "role", "is_super_admin", "password_hash", "email_verified_at", "two_factor_enabled", "created_at", "updated_at"}).
+			AddRow(userID, uuid.New(), "admin", false, string(storedHash), (*time.Time)(nil), false, now, now))
 	mock.ExpectExec(`INSERT INTO user_tenants`).
 		WithArgs(userID, tenantID).
 		WillReturnResult(pgxmock.NewResult("INSERT", 1))
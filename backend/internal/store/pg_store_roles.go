@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrTenantRoleNotFound is returned by UpdateTenantRole and DeleteTenantRole
+// when the tenant has no role with that ID.
+var ErrTenantRoleNotFound = errors.New("tenant role not found")
+
+const tenantRoleColumnsSQL = `r.id, r.name, r.description, r.permissions, r.event_ids,
+	ARRAY(SELECT ut.user_id FROM user_tenants ut WHERE ut.custom_role_id = r.id ORDER BY ut.joined_at),
+	r.created_at, r.updated_at`
+
+func scanTenantRole(row pgx.Row, tenantID uuid.UUID) (*models.TenantRole, error) {
+	r := models.TenantRole{TenantID: tenantID}
+	if err := row.Scan(&r.ID, &r.Name, &r.Description, &r.Permissions, &r.EventIDs, &r.UserIDs, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListTenantRoles returns the tenant's custom roles by name.
+func (s *PGStore) ListTenantRoles(ctx context.Context, tenantID uuid.UUID) ([]*models.TenantRole, error) {
+	rows, err := s.db.Query(ctx, `SELECT `+tenantRoleColumnsSQL+`
+		FROM tenant_roles r WHERE r.tenant_id = $1 ORDER BY lower(r.name)`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	roles := []*models.TenantRole{}
+	for rows.Next() {
+		r, err := scanTenantRole(rows, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, r)
+	}
+	return roles, rows.Err()
+}
+
+// GetTenantRole returns one of the tenant's roles, or (nil, nil) when it
+// does not exist or belongs to another tenant.
+func (s *PGStore) GetTenantRole(ctx context.Context, tenantID, roleID uuid.UUID) (*models.TenantRole, error) {
+	r, err := scanTenantRole(s.db.QueryRow(ctx, `SELECT `+tenantRoleColumnsSQL+`
+		FROM tenant_roles r WHERE r.tenant_id = $1 AND r.id = $2`, tenantID, roleID), tenantID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return r, err
+}
+
+// CreateTenantRole inserts r under r.TenantID and fills its ID and
+// timestamps. A duplicate name within the tenant (case-insensitive)
+// surfaces as the raw unique-violation (23505) error.
+func (s *PGStore) CreateTenantRole(ctx context.Context, r *models.TenantRole) error {
+	r.UserIDs = []uuid.UUID{}
+	return s.db.QueryRow(ctx, `
+		INSERT INTO tenant_roles (tenant_id, name, description, permissions, event_ids)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at`,
+		r.TenantID, r.Name, r.Description, r.Permissions, r.EventIDs,
+	).Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt)
+}
+
+// UpdateTenantRole replaces the name, description, permissions and event
+// scope of the tenant's role r.ID and refreshes r from the stored row.
+func (s *PGStore) UpdateTenantRole(ctx context.Context, r *models.TenantRole) error {
+	updated, err := scanTenantRole(s.db.QueryRow(ctx, `
+		UPDATE tenant_roles r SET name = $3, description = $4, permissions = $5, event_ids = $6, updated_at = NOW()
+		WHERE r.tenant_id = $1 AND r.id = $2
+		RETURNING `+tenantRoleColumnsSQL,
+		r.TenantID, r.ID, r.Name, r.Description, r.Permissions, r.EventIDs,
+	), r.TenantID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrTenantRoleNotFound
+	}
+	if err != nil {
+		return err
+	}
+	*r = *updated
+	return nil
+}
+
+// DeleteTenantRole removes the tenant's role; its members fall back to
+// their built-in role's permissions (ON DELETE SET NULL).
+func (s *PGStore) DeleteTenantRole(ctx context.Context, tenantID, roleID uuid.UUID) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM tenant_roles WHERE tenant_id = $1 AND id = $2`, tenantID, roleID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTenantRoleNotFound
+	}
+	return nil
+}
+
+// SetMemberCustomRole assigns roleID (nil clears it) to the user's
+// membership in the tenant. A role of another tenant matches no row, so it
+// reports false like a non-member.
+func (s *PGStore) SetMemberCustomRole(ctx context.Context, tenantID, userID uuid.UUID, roleID *uuid.UUID) (bool, error) {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE user_tenants SET custom_role_id = $3
+		WHERE tenant_id = $1 AND user_id = $2
+		  AND ($3::uuid IS NULL OR EXISTS (SELECT 1 FROM tenant_roles WHERE id = $3 AND tenant_id = $1))`,
+		tenantID, userID, roleID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// GetMemberCustomRole returns the custom role of the user's membership in
+// the tenant, or (nil, nil) when they have none. Only ID, Name,
+// Permissions and EventIDs are filled: this runs on every authorization
+// check of a non-admin caller.
+func (s *PGStore) GetMemberCustomRole(ctx context.Context, userID, tenantID uuid.UUID) (*models.TenantRole, error) {
+	r := models.TenantRole{TenantID: tenantID}
+	err := s.db.QueryRow(ctx, `
+		SELECT r.id, r.name, r.permissions, r.event_ids
+		FROM user_tenants ut JOIN tenant_roles r ON r.id = ut.custom_role_id
+		WHERE ut.user_id = $1 AND ut.tenant_id = $2`, userID, tenantID,
+	).Scan(&r.ID, &r.Name, &r.Permissions, &r.EventIDs)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
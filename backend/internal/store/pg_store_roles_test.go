@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+func TestUpdateTenantRoleNotFound(t *testing.T) {
+	mock := newImportMock(t)
+	r := &models.TenantRole{ID: uuid.New(), TenantID: uuid.New(), Name: "Door", Permissions: []string{"export"}, EventIDs: []uuid.UUID{}}
+	mock.ExpectQuery(`UPDATE tenant_roles r SET name = \$3`).
+		WithArgs(r.TenantID, r.ID, r.Name, r.Description, r.Permissions, r.EventIDs).
+		WillReturnError(pgx.ErrNoRows)
+
+	s := &PGStore{db: mock}
+	if err := s.UpdateTenantRole(context.Background(), r); !errors.Is(err, ErrTenantRoleNotFound) {
+		t.Fatalf("err = %v, want ErrTenantRoleNotFound", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestDeleteTenantRoleNotFound(t *testing.T) {
+	mock := newImportMock(t)
+	tenantID, roleID := uuid.New(), uuid.New()
+	mock.ExpectExec(`DELETE FROM tenant_roles WHERE tenant_id = \$1 AND id = \$2`).
+		WithArgs(tenantID, roleID).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+
+	s := &PGStore{db: mock}
+	if err := s.DeleteTenantRole(context.Background(), tenantID, roleID); !errors.Is(err, ErrTenantRoleNotFound) {
+		t.Fatalf("err = %v, want ErrTenantRoleNotFound", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+// A role of another tenant matches no membership row, so assigning it
+// reports false rather than linking across tenants.
+func TestSetMemberCustomRoleScopedToTenant(t *testing.T) {
+	mock := newImportMock(t)
+	tenantID, userID, roleID := uuid.New(), uuid.New(), uuid.New()
+	mock.ExpectExec(`UPDATE user_tenants SET custom_role_id = \$3`).
+		WithArgs(tenantID, userID, &roleID).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	s := &PGStore{db: mock}
+	found, err := s.SetMemberCustomRole(context.Background(), tenantID, userID, &roleID)
+	if err != nil || found {
+		t.Fatalf("SetMemberCustomRole = %v, %v; want false, nil", found, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetMemberCustomRole(t *testing.T) {
+	mock := newImportMock(t)
+	tenantID, userID, roleID, eventID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	mock.ExpectQuery(`FROM user_tenants ut JOIN tenant_roles r ON r.id = ut.custom_role_id`).
+		WithArgs(userID, tenantID).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "name", "permissions", "event_ids"}).
+			AddRow(roleID, "Door", []string{"checkin:undo"}, []uuid.UUID{eventID}))
+	mock.ExpectQuery(`FROM user_tenants ut JOIN tenant_roles r ON r.id = ut.custom_role_id`).
+		WithArgs(userID, tenantID).
+		WillReturnError(pgx.ErrNoRows)
+
+	s := &PGStore{db: mock}
+	r, err := s.GetMemberCustomRole(context.Background(), userID, tenantID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ID != roleID || r.TenantID != tenantID || len(r.Permissions) != 1 || r.EventIDs[0] != eventID {
+		t.Errorf("role = %+v", r)
+	}
+	r, err = s.GetMemberCustomRole(context.Background(), userID, tenantID)
+	if r != nil || err != nil {
+		t.Errorf("no custom role: got %+v, %v; want nil, nil", r, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrRefreshTokenInvalid is returned for a refresh token that is unknown,
+// expired or revoked by logout/session revocation.
+var ErrRefreshTokenInvalid = errors.New("refresh token invalid")
+
+// ErrRefreshTokenReused is returned when an already-rotated refresh token is
+// presented again. That means two parties hold the token, so the whole
+// family has been revoked.
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
+// ErrStationNotFound is RevokeStationSessions' and GetStationByID's
+// not-found sentinel.
+var ErrStationNotFound = errors.New("station not found")
+
+// GetTokenVersions returns the current token_version of userID and, when
+// stationID is non-nil, of that station. A missing user or station reads
+// as -1, which no issued token carries.
+func (s *PGStore) GetTokenVersions(ctx context.Context, userID uuid.UUID, stationID *uuid.UUID) (int, int, error) {
+	var userVersion, stationVersion int
+	err := s.db.QueryRow(ctx, `
+		SELECT COALESCE((SELECT token_version FROM users WHERE id = $1), -1),
+		       COALESCE((SELECT token_version FROM stations WHERE id = $2), -1)`,
+		userID, stationID,
+	).Scan(&userVersion, &stationVersion)
+	if err != nil {
+		return 0, 0, fmt.Errorf("get token versions: %w", err)
+	}
+	return userVersion, stationVersion, nil
+}
+
+// SessionActive reports whether familyID is a live session of userID: it
+// still has an unrevoked, unexpired refresh token. Logout and session
+// revocation leave none, so the JWT middleware uses this to reject access
+// tokens of ended sessions before they expire.
+func (s *PGStore) SessionActive(ctx context.Context, userID, familyID uuid.UUID) (bool, error) {
+	var active bool
+	err := s.db.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM refresh_tokens
+			WHERE family_id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW())`,
+		familyID, userID,
+	).Scan(&active)
+	if err != nil {
+		return false, fmt.Errorf("check session: %w", err)
+	}
+	return active, nil
+}
+
+// CreateRefreshToken stores the first token of a new family (session).
+// FamilyID is set to the token's own ID when zero.
+func (s *PGStore) CreateRefreshToken(ctx context.Context, rt *models.RefreshToken) error {
+	if rt.ID == uuid.Nil {
+		rt.ID = uuid.New()
+	}
+	if rt.FamilyID == uuid.Nil {
+		rt.FamilyID = rt.ID
+	}
+	return s.db.QueryRow(ctx, `
+		INSERT INTO refresh_tokens (id, family_id, token_hash, user_id, tenant_id, station_id, two_factor, expires_at, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING created_at`,
+		rt.ID, rt.FamilyID, rt.TokenHash, rt.UserID, rt.TenantID, rt.StationID, rt.TwoFactor, rt.ExpiresAt,
+		auditIPValue(derefString(rt.IPAddress)), rt.UserAgent,
+	).Scan(&rt.CreatedAt)
+}
+
+// RotateRefreshToken exchanges the refresh token hashed as oldHash for next,
+// which inherits its family, user, tenant and station; the caller sets
+// next's TokenHash, ExpiresAt and client details. TwoFactor carries over too. The old token is revoked
+// and linked to its replacement. An unknown, expired or revoked token is
+// ErrRefreshTokenInvalid; one that was already rotated is
+// ErrRefreshTokenReused, after its family has been revoked.
+func (s *PGStore) RotateRefreshToken(ctx context.Context, oldHash string, next *models.RefreshToken) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && rbErr != pgx.ErrTxClosed {
+			log.Printf("Failed to rollback transaction: %v", rbErr)
+		}
+	}()
+
+	var (
+		oldID      uuid.UUID
+		expiresAt  time.Time
+		revokedAt  *time.Time
+		replacedBy *uuid.UUID
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT id, family_id, user_id, tenant_id, station_id, two_factor, expires_at, revoked_at, replaced_by
+		FROM refresh_tokens WHERE token_hash = $1
+		FOR UPDATE`, oldHash,
+	).Scan(&oldID, &next.FamilyID, &next.UserID, &next.TenantID, &next.StationID, &next.TwoFactor, &expiresAt, &revokedAt, &replacedBy)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return fmt.Errorf("look up refresh token: %w", err)
+	}
+	if revokedAt != nil {
+		if replacedBy == nil {
+			return ErrRefreshTokenInvalid
+		}
+		if _, err := tx.Exec(ctx,
+			`UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`,
+			next.FamilyID); err != nil {
+			return fmt.Errorf("revoke reused refresh token family: %w", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return err
+		}
+		return ErrRefreshTokenReused
+	}
+	if time.Now().After(expiresAt) {
+		return ErrRefreshTokenInvalid
+	}
+
+	next.ID = uuid.New()
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO refresh_tokens (id, family_id, token_hash, user_id, tenant_id, station_id, two_factor, expires_at, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING created_at`,
+		next.ID, next.FamilyID, next.TokenHash, next.UserID, next.TenantID, next.StationID, next.TwoFactor, next.ExpiresAt,
+		auditIPValue(derefString(next.IPAddress)), next.UserAgent,
+	).Scan(&next.CreatedAt); err != nil {
+		return fmt.Errorf("insert rotated refresh token: %w", err)
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE refresh_tokens SET revoked_at = NOW(), replaced_by = $2 WHERE id = $1`,
+		oldID, next.ID); err != nil {
+		return fmt.Errorf("revoke rotated refresh token: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// RevokeRefreshTokenFamily ends one session of userID (logout). Revoking
+// an unknown or already revoked family is not an error.
+func (s *PGStore) RevokeRefreshTokenFamily(ctx context.Context, userID, familyID uuid.UUID) error {
+	_, err := s.db.Exec(ctx,
+		`UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND user_id = $2 AND revoked_at IS NULL`,
+		familyID, userID)
+	if err != nil {
+		return fmt.Errorf("revoke refresh token family: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserSessions bumps userID's token_version, invalidating every
+// access token issued so far, and revokes all of the user's refresh
+// tokens. It returns how many refresh tokens were still live.
+func (s *PGStore) RevokeUserSessions(ctx context.Context, userID uuid.UUID) (int64, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && rbErr != pgx.ErrTxClosed {
+			log.Printf("Failed to rollback transaction: %v", rbErr)
+		}
+	}()
+
+	if _, err := tx.Exec(ctx, `UPDATE users SET token_version = token_version + 1 WHERE id = $1`, userID); err != nil {
+		return 0, fmt.Errorf("bump user token version: %w", err)
+	}
+	tag, err := tx.Exec(ctx,
+		`UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("revoke user refresh tokens: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// RevokeStationSessions signs a provisioned station of eventID out for
+// good: it bumps the station's token_version, marks it revoked and revokes
+// its refresh tokens. It returns ErrStationNotFound when the station does
+// not belong to eventID, and how many refresh tokens were still live.
+func (s *PGStore) RevokeStationSessions(ctx context.Context, eventID, stationID uuid.UUID) (int64, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && rbErr != pgx.ErrTxClosed {
+			log.Printf("Failed to rollback transaction: %v", rbErr)
+		}
+	}()
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE stations SET token_version = token_version + 1, revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1 AND event_id = $2`, stationID, eventID)
+	if err != nil {
+		return 0, fmt.Errorf("bump station token version: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, ErrStationNotFound
+	}
+	tag, err = tx.Exec(ctx,
+		`UPDATE refresh_tokens SET revoked_at = NOW() WHERE station_id = $1 AND revoked_at IS NULL`, stationID)
+	if err != nil {
+		return 0, fmt.Errorf("revoke station refresh tokens: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// PurgeExpiredRefreshTokens deletes refresh tokens that expired more than
+// retention ago. Revoked tokens are kept until then so a replayed one is
+// still recognized as reuse rather than as unknown.
+func (s *PGStore) PurgeExpiredRefreshTokens(ctx context.Context, retention time.Duration) (int64, error) {
+	tag, err := s.db.Exec(ctx,
+		`DELETE FROM refresh_tokens WHERE expires_at < now() - make_interval(secs => $1)`,
+		retention.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("purge expired refresh tokens: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+var refreshTokenLookupColumns = []string{
+	"id", "family_id", "user_id", "tenant_id", "station_id", "two_factor", "expires_at", "revoked_at", "replaced_by",
+}
+
+// A live token is replaced by one in the same family, and the old one is
+// revoked and linked to it.
+func TestRotateRefreshTokenReplacesLiveToken(t *testing.T) {
+	mock := newImportMock(t)
+	oldID, family, userID, tenantID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, family_id, .* FROM refresh_tokens WHERE token_hash = \$1\s+FOR UPDATE`).
+		WithArgs("old-hash").
+		WillReturnRows(pgxmock.NewRows(refreshTokenLookupColumns).
+			AddRow(oldID, family, userID, tenantID, (*uuid.UUID)(nil), true, now.Add(time.Hour), (*time.Time)(nil), (*uuid.UUID)(nil)))
+	mock.ExpectQuery(`INSERT INTO refresh_tokens`).
+		WithArgs(anyArgs(10)...).
+		WillReturnRows(pgxmock.NewRows([]string{"created_at"}).AddRow(now))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\), replaced_by = \$2 WHERE id = \$1`).
+		WithArgs(oldID, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectCommit()
+
+	s := &PGStore{db: mock}
+	next := &models.RefreshToken{TokenHash: "new-hash", ExpiresAt: now.Add(24 * time.Hour)}
+	if err := s.RotateRefreshToken(context.Background(), "old-hash", next); err != nil {
+		t.Fatalf("RotateRefreshToken: %v", err)
+	}
+	if next.FamilyID != family || next.UserID != userID || next.TenantID != tenantID || !next.TwoFactor || next.ID == uuid.Nil || next.ID == oldID {
+		t.Errorf("next = %+v, want a new token in family %s", next, family)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+// Presenting a token that was already rotated revokes its whole family and
+// commits that, even though the call fails.
+func TestRotateRefreshTokenReuseRevokesFamily(t *testing.T) {
+	mock := newImportMock(t)
+	oldID, family, replacement := uuid.New(), uuid.New(), uuid.New()
+	revokedAt := time.Now().Add(-time.Minute)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM refresh_tokens WHERE token_hash = \$1`).
+		WithArgs("old-hash").
+		WillReturnRows(pgxmock.NewRows(refreshTokenLookupColumns).
+			AddRow(oldID, family, uuid.New(), uuid.New(), (*uuid.UUID)(nil), false, time.Now().Add(time.Hour), &revokedAt, &replacement))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\) WHERE family_id = \$1 AND revoked_at IS NULL`).
+		WithArgs(family).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectCommit()
+
+	s := &PGStore{db: mock}
+	err := s.RotateRefreshToken(context.Background(), "old-hash", &models.RefreshToken{TokenHash: "new-hash"})
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("err = %v, want ErrRefreshTokenReused", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRotateRefreshTokenRejectsExpiredAndLoggedOut(t *testing.T) {
+	revokedAt := time.Now().Add(-time.Minute)
+	cases := map[string]struct {
+		expiresAt time.Time
+		revokedAt *time.Time
+	}{
+		"expired":    {expiresAt: time.Now().Add(-time.Minute)},
+		"logged out": {expiresAt: time.Now().Add(time.Hour), revokedAt: &revokedAt},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mock := newImportMock(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`FROM refresh_tokens WHERE token_hash = \$1`).
+				WithArgs("old-hash").
+				WillReturnRows(pgxmock.NewRows(refreshTokenLookupColumns).
+					AddRow(uuid.New(), uuid.New(), uuid.New(), uuid.New(), (*uuid.UUID)(nil), false, tc.expiresAt, tc.revokedAt, (*uuid.UUID)(nil)))
+			mock.ExpectRollback()
+
+			s := &PGStore{db: mock}
+			err := s.RotateRefreshToken(context.Background(), "old-hash", &models.RefreshToken{TokenHash: "new-hash"})
+			if !errors.Is(err, ErrRefreshTokenInvalid) {
+				t.Fatalf("err = %v, want ErrRefreshTokenInvalid", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestRevokeStationSessionsUnknownStation(t *testing.T) {
+	mock := newImportMock(t)
+	eventID, stationID := uuid.New(), uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE stations SET token_version = token_version \+ 1`).
+		WithArgs(stationID, eventID).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectRollback()
+
+	s := &PGStore{db: mock}
+	if _, err := s.RevokeStationSessions(context.Background(), eventID, stationID); !errors.Is(err, ErrStationNotFound) {
+		t.Fatalf("err = %v, want ErrStationNotFound", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+// A session is live only while its family has an unrevoked, unexpired
+// refresh token; logout revokes them all.
+func TestSessionActiveChecksLiveFamilyToken(t *testing.T) {
+	for _, active := range []bool{true, false} {
+		mock := newImportMock(t)
+		userID, familyID := uuid.New(), uuid.New()
+		mock.ExpectQuery(`SELECT EXISTS \(\s*SELECT 1 FROM refresh_tokens\s*WHERE family_id = \$1 AND user_id = \$2 AND revoked_at IS NULL AND expires_at > NOW\(\)\)`).
+			WithArgs(familyID, userID).
+			WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(active))
+
+		s := &PGStore{db: mock}
+		got, err := s.SessionActive(context.Background(), userID, familyID)
+		if err != nil {
+			t.Fatalf("SessionActive: %v", err)
+		}
+		if got != active {
+			t.Errorf("SessionActive = %v, want %v", got, active)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	}
+}
@@ -98,3 +98,27 @@ func (s *PGStore) CreateStation(ctx context.Context, eventID, staffUserID uuid.U
 	}
 	return station, nil
 }
+
+// GetStationByID returns a provisioned station. A revoked station reads as
+// ErrStationNotFound: nothing should keep serving it.
+func (s *PGStore) GetStationByID(ctx context.Context, id uuid.UUID) (*models.Station, error) {
+	query := `SELECT id, event_id, device_number, staff_user_id, device_info, created_at
+			  FROM stations WHERE id = $1 AND revoked_at IS NULL`
+	var station models.Station
+	var deviceInfoJSON []byte
+	err := s.db.QueryRow(ctx, query, id).Scan(
+		&station.ID, &station.EventID, &station.DeviceNumber, &station.StaffUserID, &deviceInfoJSON, &station.CreatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, ErrStationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(deviceInfoJSON) > 0 {
+		if err := json.Unmarshal(deviceInfoJSON, &station.DeviceInfo); err != nil {
+			return nil, err
+		}
+	}
+	return &station, nil
+}
@@ -46,13 +46,13 @@ func (s *PGStore) GetEventsChangedSince(ctx context.Context, tenantID uuid.UUID,
 
 func (s *PGStore) GetAttendeesChangedSince(ctx context.Context, tenantID uuid.UUID, since time.Time) ([]*models.Attendee, error) {
 	// Complex join because attendees table doesn't have tenant_id directly (it's on event)
-	query := `SELECT a.id, a.event_id, a.first_name, a.last_name, a.email, a.company, a.position, a.code, a.checkin_status, a.checked_in_at, a.printed_count, a.created_at, a.updated_at 
+	query := `SELECT a.id, a.event_id, a.first_name, a.last_name, a.email, a.company, a.position, a.code, a.external_id, a.checkin_status, a.checked_in_at, a.printed_count, a.created_at, a.updated_at 
 			  FROM attendees a
 			  JOIN events e ON a.event_id = e.id
 			  WHERE e.tenant_id = $1 AND a.updated_at > $2`
 
 	if since.IsZero() {
-		query = `SELECT a.id, a.event_id, a.first_name, a.last_name, a.email, a.company, a.position, a.code, a.checkin_status, a.checked_in_at, a.printed_count, a.created_at, a.updated_at 
+		query = `SELECT a.id, a.event_id, a.first_name, a.last_name, a.email, a.company, a.position, a.code, a.external_id, a.checkin_status, a.checked_in_at, a.printed_count, a.created_at, a.updated_at 
 				 FROM attendees a
 				 JOIN events e ON a.event_id = e.id
 				 WHERE e.tenant_id = $1 AND a.deleted_at IS NULL`
@@ -75,7 +75,7 @@ func (s *PGStore) GetAttendeesChangedSince(ctx context.Context, tenantID uuid.UU
 	var attendees []*models.Attendee
 	for rows.Next() {
 		var a models.Attendee
-		if err := rows.Scan(&a.ID, &a.EventID, &a.FirstName, &a.LastName, &a.Email, &a.Company, &a.Position, &a.Code, &a.CheckinStatus, &a.CheckedInAt, &a.PrintedCount, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		if err := rows.Scan(&a.ID, &a.EventID, &a.FirstName, &a.LastName, &a.Email, &a.Company, &a.Position, &a.Code, &a.ExternalID, &a.CheckinStatus, &a.CheckedInAt, &a.PrintedCount, &a.CreatedAt, &a.UpdatedAt); err != nil {
 			return nil, err
 		}
 		attendees = append(attendees, &a)
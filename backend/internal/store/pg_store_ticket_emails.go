@@ -0,0 +1,277 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// TicketDeliveryQuery selects the attendees QueueTicketDeliveries mails:
+// those of the event matching Filter (paging ignored) and, when
+// AttendeeIDs is set, among them. Attendees without an email address and
+// blocked attendees are always skipped. Without Resend, attendees that
+// already have a delivery record are left alone, whatever its status.
+type TicketDeliveryQuery struct {
+	Filter      AttendeeFilter
+	AttendeeIDs []uuid.UUID
+	Resend      bool
+}
+
+const ticketDeliveryColumns = `id, event_id, attendee_id, email, status, attempts, last_error,
+	CASE WHEN status IN ('queued', 'deferred') THEN next_attempt_at END, sent_at, bounced_at, created_at, updated_at`
+
+func scanTicketDelivery(row pgx.Row) (*models.TicketDelivery, error) {
+	var d models.TicketDelivery
+	err := row.Scan(&d.ID, &d.EventID, &d.AttendeeID, &d.Email, &d.Status, &d.Attempts, &d.LastError,
+		&d.NextAttemptAt, &d.SentAt, &d.BouncedAt, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// GetTicketEmailSettings returns the event's ticket email settings, or nil
+// when it has none.
+func (s *PGStore) GetTicketEmailSettings(ctx context.Context, eventID uuid.UUID) (*models.TicketEmailSettings, error) {
+	var (
+		t             models.TicketEmailSettings
+		templatesJSON []byte
+	)
+	err := s.db.QueryRow(ctx, `
+		SELECT event_id, send_on_import, qr_placement, attach_pdf, default_locale, locale_field, reply_to, templates, updated_at
+		FROM ticket_email_settings WHERE event_id = $1`, eventID,
+	).Scan(&t.EventID, &t.SendOnImport, &t.QRPlacement, &t.AttachPDF, &t.DefaultLocale, &t.LocaleField, &t.ReplyTo, &templatesJSON, &t.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get ticket email settings: %w", err)
+	}
+	if err := json.Unmarshal(templatesJSON, &t.Templates); err != nil {
+		return nil, fmt.Errorf("decode ticket email templates: %w", err)
+	}
+	return &t, nil
+}
+
+// UpsertTicketEmailSettings creates or replaces the event's ticket email
+// settings.
+func (s *PGStore) UpsertTicketEmailSettings(ctx context.Context, t *models.TicketEmailSettings) error {
+	templates := t.Templates
+	if templates == nil {
+		templates = map[string]models.TicketEmailTemplate{}
+	}
+	templatesJSON, err := json.Marshal(templates)
+	if err != nil {
+		return err
+	}
+	err = s.db.QueryRow(ctx, `
+		INSERT INTO ticket_email_settings
+			(event_id, send_on_import, qr_placement, attach_pdf, default_locale, locale_field, reply_to, templates)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (event_id) DO UPDATE SET
+			send_on_import = EXCLUDED.send_on_import,
+			qr_placement = EXCLUDED.qr_placement,
+			attach_pdf = EXCLUDED.attach_pdf,
+			default_locale = EXCLUDED.default_locale,
+			locale_field = EXCLUDED.locale_field,
+			reply_to = EXCLUDED.reply_to,
+			templates = EXCLUDED.templates,
+			updated_at = NOW()
+		RETURNING updated_at`,
+		t.EventID, t.SendOnImport, t.QRPlacement, t.AttachPDF, t.DefaultLocale, t.LocaleField, t.ReplyTo, templatesJSON,
+	).Scan(&t.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("upsert ticket email settings: %w", err)
+	}
+	return nil
+}
+
+// QueueTicketDeliveries queues a ticket email for every attendee q selects
+// and returns how many it queued. Re-queued records start over: status
+// queued, no attempts, no error.
+func (s *PGStore) QueueTicketDeliveries(ctx context.Context, eventID uuid.UUID, q TicketDeliveryQuery) (int64, error) {
+	f := q.Filter
+	join, where, args := attendeeFilterClause(eventID, f.Code, f.Search, f.ZoneID, f.Status)
+	where += " AND a.email <> '' AND NOT a.blocked"
+	if len(q.AttendeeIDs) > 0 {
+		args = append(args, q.AttendeeIDs)
+		where += fmt.Sprintf(" AND a.id = ANY($%d)", len(args))
+	}
+	conflict := "DO NOTHING"
+	if q.Resend {
+		conflict = `DO UPDATE SET
+			email = EXCLUDED.email, status = 'queued', attempts = 0, last_error = NULL,
+			next_attempt_at = NOW(), sent_at = NULL, bounced_at = NULL, updated_at = NOW()`
+	}
+	tag, err := s.db.Exec(ctx, `
+		INSERT INTO ticket_deliveries (event_id, attendee_id, email)
+		SELECT a.event_id, a.id, a.email FROM attendees a`+join+`
+		`+where+`
+		ON CONFLICT (attendee_id) `+conflict, args...)
+	if err != nil {
+		return 0, fmt.Errorf("queue ticket deliveries: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ListTicketDeliveries returns one page of the event's delivery records,
+// newest activity first, optionally only those in status, plus the total
+// matching before paging.
+func (s *PGStore) ListTicketDeliveries(ctx context.Context, eventID uuid.UUID, status string, limit, offset int) ([]*models.TicketDelivery, int, error) {
+	var total int
+	if err := s.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM ticket_deliveries WHERE event_id = $1 AND ($2 = '' OR status = $2)`,
+		eventID, status).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count ticket deliveries: %w", err)
+	}
+	rows, err := s.db.Query(ctx, `
+		SELECT `+ticketDeliveryColumns+`
+		FROM ticket_deliveries WHERE event_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY updated_at DESC, id
+		LIMIT $3 OFFSET $4`, eventID, status, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list ticket deliveries: %w", err)
+	}
+	defer rows.Close()
+	out := []*models.TicketDelivery{}
+	for rows.Next() {
+		d, err := scanTicketDelivery(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scan ticket delivery: %w", err)
+		}
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("ticket delivery rows: %w", err)
+	}
+	return out, total, nil
+}
+
+// CountTicketDeliveries counts the event's delivery records by status.
+func (s *PGStore) CountTicketDeliveries(ctx context.Context, eventID uuid.UUID) (map[string]int, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT status, COUNT(*) FROM ticket_deliveries WHERE event_id = $1 GROUP BY status`, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("count ticket deliveries: %w", err)
+	}
+	defer rows.Close()
+	counts := map[string]int{}
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, fmt.Errorf("scan ticket delivery count: %w", err)
+		}
+		counts[status] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ticket delivery count rows: %w", err)
+	}
+	return counts, nil
+}
+
+// GetTicketDelivery returns the attendee's delivery record, or nil when no
+// ticket was ever queued for them.
+func (s *PGStore) GetTicketDelivery(ctx context.Context, attendeeID uuid.UUID) (*models.TicketDelivery, error) {
+	d, err := scanTicketDelivery(s.db.QueryRow(ctx, `
+		SELECT `+ticketDeliveryColumns+` FROM ticket_deliveries WHERE attendee_id = $1`, attendeeID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get ticket delivery: %w", err)
+	}
+	return d, nil
+}
+
+// CountDueTicketDeliveries counts the event's deliveries waiting to be
+// sent now.
+func (s *PGStore) CountDueTicketDeliveries(ctx context.Context, eventID uuid.UUID) (int, error) {
+	var n int
+	if err := s.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM ticket_deliveries
+		WHERE event_id = $1 AND status IN ('queued', 'deferred') AND next_attempt_at <= NOW()`,
+		eventID).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count due ticket deliveries: %w", err)
+	}
+	return n, nil
+}
+
+// ClaimTicketDeliveries takes up to limit of the event's due deliveries
+// for sending: it counts the attempt and pushes next_attempt_at lease
+// ahead, so a sender that dies mid-way leaves them due again once the
+// lease runs out. SKIP LOCKED keeps concurrent workers on different rows.
+func (s *PGStore) ClaimTicketDeliveries(ctx context.Context, eventID uuid.UUID, limit int, lease time.Duration) ([]*models.TicketDelivery, error) {
+	rows, err := s.db.Query(ctx, `
+		UPDATE ticket_deliveries SET attempts = attempts + 1, next_attempt_at = NOW() + $3 * interval '1 second',
+		       updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM ticket_deliveries
+			WHERE event_id = $1 AND status IN ('queued', 'deferred') AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED)
+		RETURNING `+ticketDeliveryColumns, eventID, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim ticket deliveries: %w", err)
+	}
+	defer rows.Close()
+	out := []*models.TicketDelivery{}
+	for rows.Next() {
+		d, err := scanTicketDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan ticket delivery: %w", err)
+		}
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("claimed ticket delivery rows: %w", err)
+	}
+	return out, nil
+}
+
+// RecordTicketDelivery stores the outcome of the send attempt d came from
+// ClaimTicketDeliveries with: d.Status, d.LastError and, for deferred,
+// d.NextAttemptAt. It is a no-op when the record was re-queued since the
+// claim (its attempts no longer match), so the new send wins.
+func (s *PGStore) RecordTicketDelivery(ctx context.Context, d *models.TicketDelivery) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE ticket_deliveries SET status = $3, last_error = $4,
+		       next_attempt_at = COALESCE($5, next_attempt_at),
+		       sent_at = CASE WHEN $3 = 'sent' THEN NOW() ELSE sent_at END,
+		       bounced_at = CASE WHEN $3 = 'bounced' THEN NOW() ELSE bounced_at END,
+		       updated_at = NOW()
+		WHERE id = $1 AND attempts = $2`,
+		d.ID, d.Attempts, d.Status, d.LastError, d.NextAttemptAt)
+	if err != nil {
+		return fmt.Errorf("record ticket delivery: %w", err)
+	}
+	return nil
+}
+
+// EnqueueDueTicketEmailJobs queues a ticket_email job for every live event
+// with due deliveries (retries coming due) that has no such job queued or
+// running yet, and returns how many it queued.
+func (s *PGStore) EnqueueDueTicketEmailJobs(ctx context.Context) (int64, error) {
+	tag, err := s.db.Exec(ctx, `
+		INSERT INTO jobs (tenant_id, event_id, type, payload)
+		SELECT e.tenant_id, e.id, $1, '{}'::jsonb
+		FROM events e
+		WHERE e.deleted_at IS NULL
+		  AND e.id IN (SELECT DISTINCT event_id FROM ticket_deliveries
+		               WHERE status IN ('queued', 'deferred') AND next_attempt_at <= NOW())
+		  AND NOT EXISTS (SELECT 1 FROM jobs j
+		                  WHERE j.event_id = e.id AND j.type = $1 AND j.status IN ('queued', 'running'))`,
+		models.JobTypeTicketEmail)
+	if err != nil {
+		return 0, fmt.Errorf("enqueue ticket email jobs: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+// Queueing takes the attendee list's filters, skips attendees without an
+// address or blocked, and leaves existing delivery records alone unless
+// resending.
+func TestQueueTicketDeliveries(t *testing.T) {
+	eventID, attendeeID := uuid.New(), uuid.New()
+	checkedIn := false
+
+	mock := newImportMock(t)
+	mock.ExpectExec(`INSERT INTO ticket_deliveries \(event_id, attendee_id, email\)\s+SELECT a.event_id, a.id, a.email FROM attendees a\s+`+
+		`WHERE a.event_id = \$1 AND a.deleted_at IS NULL .*a.email <> '' AND NOT a.blocked AND a.id = ANY\(\$\d\)\s+`+
+		`ON CONFLICT \(attendee_id\) DO NOTHING`).
+		WithArgs(eventID, false, []uuid.UUID{attendeeID}).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	s := &PGStore{db: mock}
+	n, err := s.QueueTicketDeliveries(context.Background(), eventID, TicketDeliveryQuery{
+		Filter:      AttendeeFilter{Status: &checkedIn},
+		AttendeeIDs: []uuid.UUID{attendeeID},
+	})
+	if err != nil || n != 1 {
+		t.Fatalf("QueueTicketDeliveries = %d, %v; want 1, nil", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+
+	mock = newImportMock(t)
+	mock.ExpectExec(`ON CONFLICT \(attendee_id\) DO UPDATE SET\s+email = EXCLUDED.email, status = 'queued', attempts = 0`).
+		WithArgs(eventID).
+		WillReturnResult(pgxmock.NewResult("INSERT", 4))
+	s = &PGStore{db: mock}
+	if n, err := s.QueueTicketDeliveries(context.Background(), eventID, TicketDeliveryQuery{Resend: true}); err != nil || n != 4 {
+		t.Fatalf("resend = %d, %v; want 4, nil", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
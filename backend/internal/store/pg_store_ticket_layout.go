@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetTicketLayout reads the event's PDF ticket layout (events.ticket_layout).
+// Returns (nil, nil) when none is saved or the event does not exist, like
+// GetCheckinSettings.
+func (s *PGStore) GetTicketLayout(ctx context.Context, eventID uuid.UUID) (json.RawMessage, error) {
+	var layout []byte
+	err := s.db.QueryRow(ctx, `SELECT ticket_layout FROM events WHERE id = $1 AND deleted_at IS NULL`, eventID).Scan(&layout)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get ticket layout: %w", err)
+	}
+	if len(layout) == 0 || string(layout) == "null" {
+		return nil, nil
+	}
+	return layout, nil
+}
+
+// UpdateTicketLayout saves the event's PDF ticket layout. The caller must
+// have confirmed the event exists; a soft-delete racing the update
+// returns ErrEventNotFound.
+func (s *PGStore) UpdateTicketLayout(ctx context.Context, eventID uuid.UUID, layout json.RawMessage) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE events SET ticket_layout = $2, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL`, eventID, []byte(layout))
+	if err != nil {
+		return fmt.Errorf("update ticket layout: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrEventNotFound
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+func TestTicketLayoutRoundTrip(t *testing.T) {
+	eventID := uuid.New()
+	layout := json.RawMessage(`{"page_size":"a6"}`)
+
+	mock := newImportMock(t)
+	mock.ExpectQuery(`SELECT ticket_layout FROM events WHERE id = \$1 AND deleted_at IS NULL`).
+		WithArgs(eventID).
+		WillReturnRows(pgxmock.NewRows([]string{"ticket_layout"}).AddRow([]byte(nil)))
+	mock.ExpectExec(`UPDATE events SET ticket_layout = \$2`).
+		WithArgs(eventID, []byte(layout)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectQuery(`SELECT ticket_layout FROM events`).
+		WithArgs(eventID).
+		WillReturnRows(pgxmock.NewRows([]string{"ticket_layout"}).AddRow([]byte(layout)))
+	mock.ExpectExec(`UPDATE events SET ticket_layout = \$2`).
+		WithArgs(eventID, []byte(layout)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	s := &PGStore{db: mock}
+	ctx := context.Background()
+
+	if got, err := s.GetTicketLayout(ctx, eventID); err != nil || got != nil {
+		t.Fatalf("unsaved layout = %s, %v; want nil, nil", got, err)
+	}
+	if err := s.UpdateTicketLayout(ctx, eventID, layout); err != nil {
+		t.Fatalf("UpdateTicketLayout: %v", err)
+	}
+	if got, err := s.GetTicketLayout(ctx, eventID); err != nil || string(got) != string(layout) {
+		t.Fatalf("saved layout = %s, %v", got, err)
+	}
+	if err := s.UpdateTicketLayout(ctx, eventID, layout); !errors.Is(err, ErrEventNotFound) {
+		t.Fatalf("deleted event: err = %v, want ErrEventNotFound", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
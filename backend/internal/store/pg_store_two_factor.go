@@ -0,0 +1,267 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrTwoFactorAlreadyEnabled is returned when setting up or confirming TOTP
+// for a user who already has it enabled.
+var ErrTwoFactorAlreadyEnabled = errors.New("two-factor already enabled")
+
+// ErrTwoFactorNotPending is returned by EnableTOTP when no secret has been
+// set up (or it was enabled concurrently).
+var ErrTwoFactorNotPending = errors.New("two-factor setup not started")
+
+// ErrTwoFactorChallengeSpent is returned for a login challenge that is
+// unknown, expired, already used or out of attempts.
+var ErrTwoFactorChallengeSpent = errors.New("two-factor challenge spent")
+
+// ErrTwoFactorLockedOut is returned while a user has too many recent wrong
+// second-factor codes.
+var ErrTwoFactorLockedOut = errors.New("two-factor locked out")
+
+const (
+	// twoFactorChallengeMaxAttempts is how many codes one login challenge
+	// takes before the password has to be entered again.
+	twoFactorChallengeMaxAttempts = 5
+	// twoFactorMaxFailures wrong codes across a user's challenges within
+	// twoFactorLockoutWindow lock their second step until the oldest of
+	// them ages out; a successful sign-in clears the count.
+	twoFactorMaxFailures   = 10
+	twoFactorLockoutWindow = 15 * time.Minute
+)
+
+// SetPendingTOTPSecret stores a new, not yet confirmed TOTP secret for
+// userID, replacing an earlier unconfirmed one.
+func (s *PGStore) SetPendingTOTPSecret(ctx context.Context, userID uuid.UUID, secret string) error {
+	tag, err := s.db.Exec(ctx,
+		`UPDATE users SET totp_secret = $2, totp_last_step = NULL, updated_at = NOW() WHERE id = $1 AND totp_enabled_at IS NULL`,
+		userID, secret)
+	if err != nil {
+		return fmt.Errorf("set totp secret: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTwoFactorAlreadyEnabled
+	}
+	return nil
+}
+
+// GetTOTPSecret returns userID's TOTP secret ("" when none is set up) and
+// whether it is enabled.
+func (s *PGStore) GetTOTPSecret(ctx context.Context, userID uuid.UUID) (string, bool, error) {
+	var (
+		secret  *string
+		enabled bool
+	)
+	err := s.db.QueryRow(ctx,
+		`SELECT totp_secret, totp_enabled_at IS NOT NULL FROM users WHERE id = $1`, userID,
+	).Scan(&secret, &enabled)
+	if err != nil {
+		return "", false, fmt.Errorf("get totp secret: %w", err)
+	}
+	return derefString(secret), enabled, nil
+}
+
+// EnableTOTP confirms userID's pending secret with the time step of the
+// first valid code and stores the user's recovery codes (hashed).
+func (s *PGStore) EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, recoveryCodeHashes []string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && rbErr != pgx.ErrTxClosed {
+			log.Printf("Failed to rollback transaction: %v", rbErr)
+		}
+	}()
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE users SET totp_enabled_at = NOW(), totp_last_step = $2, updated_at = NOW()
+		WHERE id = $1 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL`, userID, step)
+	if err != nil {
+		return fmt.Errorf("enable totp: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTwoFactorNotPending
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// UseTOTPStep records step as userID's last accepted TOTP step. It reports
+// false, without error, when step is not newer than the last one: the code
+// was already used.
+func (s *PGStore) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE users SET totp_last_step = $2
+		WHERE id = $1 AND totp_enabled_at IS NOT NULL AND (totp_last_step IS NULL OR totp_last_step < $2)`,
+		userID, step)
+	if err != nil {
+		return false, fmt.Errorf("use totp step: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// UseRecoveryCode spends one of userID's unused recovery codes. It reports
+// false, without error, for an unknown or already used code.
+func (s *PGStore) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	tag, err := s.db.Exec(ctx,
+		`UPDATE user_recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+		userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("use recovery code: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// CountUnusedRecoveryCodes returns how many recovery codes userID has left.
+func (s *PGStore) CountUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	var n int
+	err := s.db.QueryRow(ctx,
+		`SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count recovery codes: %w", err)
+	}
+	return n, nil
+}
+
+// ReplaceRecoveryCodes discards userID's recovery codes and stores a new set.
+func (s *PGStore) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && rbErr != pgx.ErrTxClosed {
+			log.Printf("Failed to rollback transaction: %v", rbErr)
+		}
+	}()
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID uuid.UUID, codeHashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO user_recovery_codes (user_id, code_hash) SELECT $1, unnest($2::text[])`,
+		userID, codeHashes); err != nil {
+		return fmt.Errorf("insert recovery codes: %w", err)
+	}
+	return nil
+}
+
+// DisableTOTP removes userID's TOTP secret and recovery codes.
+func (s *PGStore) DisableTOTP(ctx context.Context, userID uuid.UUID) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && rbErr != pgx.ErrTxClosed {
+			log.Printf("Failed to rollback transaction: %v", rbErr)
+		}
+	}()
+	if _, err := tx.Exec(ctx, `
+		UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL, updated_at = NOW()
+		WHERE id = $1`, userID); err != nil {
+		return fmt.Errorf("disable totp: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// GetTenantTwoFactorRequired reports whether the tenant requires two-factor
+// sign-in for admins and managers (settings.two_factor_required = true).
+// A missing tenant reads as false.
+func (s *PGStore) GetTenantTwoFactorRequired(ctx context.Context, tenantID uuid.UUID) (bool, error) {
+	var required bool
+	err := s.db.QueryRow(ctx,
+		`SELECT COALESCE(settings->'two_factor_required' = 'true'::jsonb, false) FROM tenants WHERE id = $1`,
+		tenantID).Scan(&required)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("get tenant two-factor policy: %w", err)
+	}
+	return required, nil
+}
+
+// CreateTwoFactorChallenge records a login challenge for userID that
+// expires at expiresAt and returns its id. The user's challenges older
+// than the lockout window are dropped on the way; they no longer count.
+func (s *PGStore) CreateTwoFactorChallenge(ctx context.Context, userID uuid.UUID, expiresAt time.Time) (uuid.UUID, error) {
+	if _, err := s.db.Exec(ctx,
+		`DELETE FROM two_factor_challenges WHERE user_id = $1 AND created_at < now() - make_interval(secs => $2)`,
+		userID, twoFactorLockoutWindow.Seconds()); err != nil {
+		return uuid.Nil, fmt.Errorf("delete old two-factor challenges: %w", err)
+	}
+	var id uuid.UUID
+	err := s.db.QueryRow(ctx,
+		`INSERT INTO two_factor_challenges (user_id, expires_at) VALUES ($1, $2) RETURNING id`,
+		userID, expiresAt).Scan(&id)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("create two-factor challenge: %w", err)
+	}
+	return id, nil
+}
+
+// AttemptTwoFactorChallenge spends one attempt of challenge id, issued to
+// userID, before a code is checked against it, so concurrent guesses
+// cannot outrun the limits. It fails with ErrTwoFactorLockedOut while the
+// user is locked out and ErrTwoFactorChallengeSpent when the challenge
+// can't take another code.
+func (s *PGStore) AttemptTwoFactorChallenge(ctx context.Context, id, userID uuid.UUID) error {
+	var failures int
+	err := s.db.QueryRow(ctx, `
+		SELECT COALESCE(SUM(attempts), 0) FROM two_factor_challenges
+		WHERE user_id = $1 AND used_at IS NULL AND created_at > now() - make_interval(secs => $2)`,
+		userID, twoFactorLockoutWindow.Seconds()).Scan(&failures)
+	if err != nil {
+		return fmt.Errorf("count two-factor failures: %w", err)
+	}
+	if failures >= twoFactorMaxFailures {
+		return ErrTwoFactorLockedOut
+	}
+	tag, err := s.db.Exec(ctx, `
+		UPDATE two_factor_challenges SET attempts = attempts + 1
+		WHERE id = $1 AND user_id = $2 AND used_at IS NULL AND expires_at > now() AND attempts < $3`,
+		id, userID, twoFactorChallengeMaxAttempts)
+	if err != nil {
+		return fmt.Errorf("attempt two-factor challenge: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTwoFactorChallengeSpent
+	}
+	return nil
+}
+
+// UseTwoFactorChallenge marks challenge id as having signed its user in,
+// which takes its attempts out of the lockout count. A challenge signs in
+// once: a second use fails with ErrTwoFactorChallengeSpent.
+func (s *PGStore) UseTwoFactorChallenge(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.db.Exec(ctx,
+		`UPDATE two_factor_challenges SET used_at = now() WHERE id = $1 AND used_at IS NULL`, id)
+	if err != nil {
+		return fmt.Errorf("use two-factor challenge: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTwoFactorChallengeSpent
+	}
+	return nil
+}
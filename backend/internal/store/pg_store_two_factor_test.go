@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+// Enabling confirms the pending secret and stores the recovery codes in
+// one transaction.
+func TestEnableTOTPStoresRecoveryCodes(t *testing.T) {
+	mock := newImportMock(t)
+	userID := uuid.New()
+	hashes := []string{"h1", "h2"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET totp_enabled_at = NOW\(\), totp_last_step = \$2`).
+		WithArgs(userID, int64(42)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec(`DELETE FROM user_recovery_codes WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	mock.ExpectExec(`INSERT INTO user_recovery_codes \(user_id, code_hash\) SELECT \$1, unnest\(\$2::text\[\]\)`).
+		WithArgs(userID, hashes).
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectCommit()
+
+	s := &PGStore{db: mock}
+	if err := s.EnableTOTP(context.Background(), userID, 42, hashes); err != nil {
+		t.Fatalf("EnableTOTP: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestEnableTOTPWithoutPendingSecret(t *testing.T) {
+	mock := newImportMock(t)
+	userID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET totp_enabled_at`).
+		WithArgs(userID, int64(42)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectRollback()
+
+	s := &PGStore{db: mock}
+	if err := s.EnableTOTP(context.Background(), userID, 42, []string{"h1"}); !errors.Is(err, ErrTwoFactorNotPending) {
+		t.Fatalf("err = %v, want ErrTwoFactorNotPending", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+// A step that is not newer than the last accepted one is a replayed code.
+func TestUseTOTPStepRejectsReplay(t *testing.T) {
+	mock := newImportMock(t)
+	userID := uuid.New()
+
+	mock.ExpectExec(`UPDATE users SET totp_last_step = \$2\s+WHERE id = \$1 AND totp_enabled_at IS NOT NULL AND \(totp_last_step IS NULL OR totp_last_step < \$2\)`).
+		WithArgs(userID, int64(7)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	s := &PGStore{db: mock}
+	ok, err := s.UseTOTPStep(context.Background(), userID, 7)
+	if err != nil || ok {
+		t.Fatalf("UseTOTPStep = %v, %v; want false, nil", ok, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetTenantTwoFactorRequiredMissingTenant(t *testing.T) {
+	mock := newImportMock(t)
+	tenantID := uuid.New()
+
+	mock.ExpectQuery(`SELECT COALESCE\(settings->'two_factor_required' = 'true'::jsonb, false\) FROM tenants WHERE id = \$1`).
+		WithArgs(tenantID).
+		WillReturnError(pgx.ErrNoRows)
+
+	s := &PGStore{db: mock}
+	required, err := s.GetTenantTwoFactorRequired(context.Background(), tenantID)
+	if err != nil || required {
+		t.Fatalf("GetTenantTwoFactorRequired = %v, %v; want false, nil", required, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+// Enough wrong codes across a user's unused challenges lock the second
+// step before the challenge itself is touched.
+func TestAttemptTwoFactorChallengeLockedOut(t *testing.T) {
+	mock := newImportMock(t)
+	id, userID := uuid.New(), uuid.New()
+
+	mock.ExpectQuery(`SELECT COALESCE\(SUM\(attempts\), 0\) FROM two_factor_challenges\s+WHERE user_id = \$1 AND used_at IS NULL`).
+		WithArgs(userID, twoFactorLockoutWindow.Seconds()).
+		WillReturnRows(pgxmock.NewRows([]string{"sum"}).AddRow(twoFactorMaxFailures))
+
+	s := &PGStore{db: mock}
+	if err := s.AttemptTwoFactorChallenge(context.Background(), id, userID); !errors.Is(err, ErrTwoFactorLockedOut) {
+		t.Fatalf("err = %v, want ErrTwoFactorLockedOut", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+// A challenge that is used, expired or out of attempts takes no more codes.
+func TestAttemptTwoFactorChallengeSpent(t *testing.T) {
+	mock := newImportMock(t)
+	id, userID := uuid.New(), uuid.New()
+
+	mock.ExpectQuery(`SELECT COALESCE\(SUM\(attempts\), 0\) FROM two_factor_challenges`).
+		WithArgs(userID, twoFactorLockoutWindow.Seconds()).
+		WillReturnRows(pgxmock.NewRows([]string{"sum"}).AddRow(3))
+	mock.ExpectExec(`UPDATE two_factor_challenges SET attempts = attempts \+ 1\s+WHERE id = \$1 AND user_id = \$2 AND used_at IS NULL AND expires_at > now\(\) AND attempts < \$3`).
+		WithArgs(id, userID, twoFactorChallengeMaxAttempts).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	s := &PGStore{db: mock}
+	if err := s.AttemptTwoFactorChallenge(context.Background(), id, userID); !errors.Is(err, ErrTwoFactorChallengeSpent) {
+		t.Fatalf("err = %v, want ErrTwoFactorChallengeSpent", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestUseTwoFactorChallengeOnce(t *testing.T) {
+	mock := newImportMock(t)
+	id := uuid.New()
+
+	mock.ExpectExec(`UPDATE two_factor_challenges SET used_at = now\(\) WHERE id = \$1 AND used_at IS NULL`).
+		WithArgs(id).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec(`UPDATE two_factor_challenges SET used_at = now\(\) WHERE id = \$1 AND used_at IS NULL`).
+		WithArgs(id).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	s := &PGStore{db: mock}
+	if err := s.UseTwoFactorChallenge(context.Background(), id); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := s.UseTwoFactorChallenge(context.Background(), id); !errors.Is(err, ErrTwoFactorChallengeSpent) {
+		t.Fatalf("second use: err = %v, want ErrTwoFactorChallengeSpent", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrUserTokenInvalid is returned for a password reset, email verification
+// or invitation token that is unknown, expired, already used or superseded.
+var ErrUserTokenInvalid = errors.New("user token invalid")
+
+// CreateUserToken stores a single-use token for userID and purpose (one of
+// models.UserToken*), spending the user's earlier unused tokens of the same
+// purpose so only the latest email's link works.
+func (s *PGStore) CreateUserToken(ctx context.Context, userID uuid.UUID, purpose, tokenHash string, expiresAt time.Time) error {
+	_, err := s.db.Exec(ctx, `
+		WITH superseded AS (
+			UPDATE user_tokens SET used_at = NOW()
+			WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+		)
+		INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at) VALUES ($1, $2, $3, $4)`,
+		userID, purpose, tokenHash, expiresAt)
+	if err != nil {
+		return fmt.Errorf("create user token: %w", err)
+	}
+	return nil
+}
+
+// consumeUserToken spends a live token inside tx and returns its user.
+func consumeUserToken(ctx context.Context, tx pgx.Tx, purpose, tokenHash string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := tx.QueryRow(ctx, `
+		UPDATE user_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`, tokenHash, purpose).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, ErrUserTokenInvalid
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("consume user token: %w", err)
+	}
+	return userID, nil
+}
+
+// ResetPasswordWithToken spends a password reset token and sets the user's
+// password hash. Like RevokeUserSessions it also bumps the token_version
+// and revokes every refresh token, so whoever held the old password is
+// signed out everywhere. Proving control of the mailbox verifies it too.
+func (s *PGStore) ResetPasswordWithToken(ctx context.Context, tokenHash, passwordHash string) (uuid.UUID, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && rbErr != pgx.ErrTxClosed {
+			log.Printf("Failed to rollback transaction: %v", rbErr)
+		}
+	}()
+
+	userID, err := consumeUserToken(ctx, tx, models.UserTokenPasswordReset, tokenHash)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE users SET password_hash = $2, token_version = token_version + 1,
+		       email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW()
+		WHERE id = $1`, userID, passwordHash); err != nil {
+		return uuid.Nil, fmt.Errorf("update password: %w", err)
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return uuid.Nil, fmt.Errorf("revoke user refresh tokens: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
+
+// VerifyEmailWithToken spends an email verification token and marks the
+// user's email verified (keeping the first verification time).
+func (s *PGStore) VerifyEmailWithToken(ctx context.Context, tokenHash string) (uuid.UUID, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer func() {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && rbErr != pgx.ErrTxClosed {
+			log.Printf("Failed to rollback transaction: %v", rbErr)
+		}
+	}()
+
+	userID, err := consumeUserToken(ctx, tx, models.UserTokenEmailVerification, tokenHash)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW() WHERE id = $1`,
+		userID); err != nil {
+		return uuid.Nil, fmt.Errorf("mark email verified: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
+
+// PurgeExpiredUserTokens deletes mailed tokens (password reset, email
+// verification, invitation) that expired more than retention ago.
+func (s *PGStore) PurgeExpiredUserTokens(ctx context.Context, retention time.Duration) (int64, error) {
+	tag, err := s.db.Exec(ctx,
+		`DELETE FROM user_tokens WHERE expires_at < now() - make_interval(secs => $1)`,
+		retention.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("purge expired user tokens: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
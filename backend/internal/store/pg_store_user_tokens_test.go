@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+// A reset spends the token, changes the password, bumps the token version
+// and revokes refresh tokens in one transaction.
+func TestResetPasswordWithTokenRevokesSessions(t *testing.T) {
+	mock := newImportMock(t)
+	userID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE user_tokens SET used_at = NOW\(\)\s+WHERE token_hash = \$1 AND purpose = \$2 AND used_at IS NULL AND expires_at > NOW\(\)`).
+		WithArgs("hash", models.UserTokenPasswordReset).
+		WillReturnRows(pgxmock.NewRows([]string{"user_id"}).AddRow(userID))
+	mock.ExpectExec(`UPDATE users SET password_hash = \$2, token_version = token_version \+ 1`).
+		WithArgs(userID, "bcrypt").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\) WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+	mock.ExpectCommit()
+
+	s := &PGStore{db: mock}
+	got, err := s.ResetPasswordWithToken(context.Background(), "hash", "bcrypt")
+	if err != nil || got != userID {
+		t.Fatalf("ResetPasswordWithToken = %s, %v; want %s", got, err, userID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+// A spent, expired, superseded or unknown token changes nothing.
+func TestVerifyEmailWithTokenRejectsSpentToken(t *testing.T) {
+	mock := newImportMock(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE user_tokens SET used_at`).
+		WithArgs("hash", models.UserTokenEmailVerification).
+		WillReturnError(pgx.ErrNoRows)
+	mock.ExpectRollback()
+
+	s := &PGStore{db: mock}
+	if _, err := s.VerifyEmailWithToken(context.Background(), "hash"); !errors.Is(err, ErrUserTokenInvalid) {
+		t.Fatalf("err = %v, want ErrUserTokenInvalid", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"idento/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetTenantWalletSettings returns the tenant's wallet credentials, or nil
+// when it has none.
+func (s *PGStore) GetTenantWalletSettings(ctx context.Context, tenantID uuid.UUID) (*models.TenantWalletSettings, error) {
+	var w models.TenantWalletSettings
+	err := s.db.QueryRow(ctx, `
+		SELECT tenant_id, apple_certificate, apple_pass_type_id, apple_team_id, apple_expires_at,
+		       google_issuer_id, google_service_account, google_service_account_email, created_at, updated_at
+		FROM tenant_wallet_settings WHERE tenant_id = $1`, tenantID,
+	).Scan(&w.TenantID, &w.AppleCertificate, &w.ApplePassTypeID, &w.AppleTeamID, &w.AppleExpiresAt,
+		&w.GoogleIssuerID, &w.GoogleServiceAccount, &w.GoogleServiceAccountEmail, &w.CreatedAt, &w.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get wallet settings: %w", err)
+	}
+	return &w, nil
+}
+
+// UpsertTenantWalletSettings creates or replaces the tenant's wallet
+// credentials; empty fields clear them.
+func (s *PGStore) UpsertTenantWalletSettings(ctx context.Context, w *models.TenantWalletSettings) error {
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO tenant_wallet_settings
+			(tenant_id, apple_certificate, apple_pass_type_id, apple_team_id, apple_expires_at,
+			 google_issuer_id, google_service_account, google_service_account_email)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (tenant_id) DO UPDATE SET
+			apple_certificate = EXCLUDED.apple_certificate,
+			apple_pass_type_id = EXCLUDED.apple_pass_type_id,
+			apple_team_id = EXCLUDED.apple_team_id,
+			apple_expires_at = EXCLUDED.apple_expires_at,
+			google_issuer_id = EXCLUDED.google_issuer_id,
+			google_service_account = EXCLUDED.google_service_account,
+			google_service_account_email = EXCLUDED.google_service_account_email,
+			updated_at = NOW()
+		RETURNING created_at, updated_at`,
+		w.TenantID, w.AppleCertificate, w.ApplePassTypeID, w.AppleTeamID, w.AppleExpiresAt,
+		w.GoogleIssuerID, w.GoogleServiceAccount, w.GoogleServiceAccountEmail,
+	).Scan(&w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("upsert wallet settings: %w", err)
+	}
+	return nil
+}
+
+// EnsureWalletPass returns the attendee's pass record, creating it with a
+// fresh auth token on first download. A pass reissued under a different
+// pass type counts as changed.
+func (s *PGStore) EnsureWalletPass(ctx context.Context, attendeeID, eventID uuid.UUID, passTypeID string) (*models.WalletPass, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	var p models.WalletPass
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO wallet_passes (attendee_id, event_id, pass_type_id, auth_token)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (attendee_id) DO UPDATE SET
+			pass_type_id = EXCLUDED.pass_type_id,
+			updated_at = CASE WHEN wallet_passes.pass_type_id = EXCLUDED.pass_type_id
+			                  THEN wallet_passes.updated_at ELSE NOW() END
+		RETURNING attendee_id, event_id, pass_type_id, auth_token, updated_at`,
+		attendeeID, eventID, passTypeID, hex.EncodeToString(raw),
+	).Scan(&p.AttendeeID, &p.EventID, &p.PassTypeID, &p.AuthToken, &p.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("ensure wallet pass: %w", err)
+	}
+	return &p, nil
+}
+
+// GetWalletPass returns the pass with serial under passTypeID, or nil.
+func (s *PGStore) GetWalletPass(ctx context.Context, passTypeID string, serial uuid.UUID) (*models.WalletPass, error) {
+	var p models.WalletPass
+	err := s.db.QueryRow(ctx, `
+		SELECT attendee_id, event_id, pass_type_id, auth_token, updated_at
+		FROM wallet_passes WHERE attendee_id = $1 AND pass_type_id = $2`, serial, passTypeID,
+	).Scan(&p.AttendeeID, &p.EventID, &p.PassTypeID, &p.AuthToken, &p.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get wallet pass: %w", err)
+	}
+	return &p, nil
+}
+
+// RegisterWalletDevice records that deviceID installed the pass, or
+// refreshes its push token. It reports whether the registration is new.
+func (s *PGStore) RegisterWalletDevice(ctx context.Context, deviceID string, serial uuid.UUID, pushToken string) (bool, error) {
+	var created bool
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO wallet_registrations (device_library_id, attendee_id, push_token)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (device_library_id, attendee_id) DO UPDATE SET push_token = EXCLUDED.push_token
+		RETURNING xmax = 0`, deviceID, serial, pushToken,
+	).Scan(&created)
+	if err != nil {
+		return false, fmt.Errorf("register wallet device: %w", err)
+	}
+	return created, nil
+}
+
+// UnregisterWalletDevice forgets that deviceID has the pass. It reports
+// whether it was registered.
+func (s *PGStore) UnregisterWalletDevice(ctx context.Context, deviceID string, serial uuid.UUID) (bool, error) {
+	tag, err := s.db.Exec(ctx,
+		`DELETE FROM wallet_registrations WHERE device_library_id = $1 AND attendee_id = $2`, deviceID, serial)
+	if err != nil {
+		return false, fmt.Errorf("unregister wallet device: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetWalletDevicePasses lists the serial numbers of deviceID's passes of
+// passTypeID changed after since (every one when since is nil), and the
+// latest change among them.
+func (s *PGStore) GetWalletDevicePasses(ctx context.Context, deviceID, passTypeID string, since *time.Time) ([]uuid.UUID, time.Time, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT p.attendee_id, p.updated_at
+		FROM wallet_registrations r
+		JOIN wallet_passes p ON p.attendee_id = r.attendee_id
+		WHERE r.device_library_id = $1 AND p.pass_type_id = $2
+		  AND ($3::timestamptz IS NULL OR p.updated_at > $3)
+		ORDER BY p.attendee_id`, deviceID, passTypeID, since)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("get wallet device passes: %w", err)
+	}
+	defer rows.Close()
+	var (
+		serials []uuid.UUID
+		latest  time.Time
+	)
+	for rows.Next() {
+		var (
+			serial  uuid.UUID
+			updated time.Time
+		)
+		if err := rows.Scan(&serial, &updated); err != nil {
+			return nil, time.Time{}, err
+		}
+		serials = append(serials, serial)
+		if updated.After(latest) {
+			latest = updated
+		}
+	}
+	return serials, latest, rows.Err()
+}
+
+// TouchWalletPasses marks every pass of the event changed and returns how
+// many there are.
+func (s *PGStore) TouchWalletPasses(ctx context.Context, eventID uuid.UUID) (int64, error) {
+	tag, err := s.db.Exec(ctx, `UPDATE wallet_passes SET updated_at = NOW() WHERE event_id = $1`, eventID)
+	if err != nil {
+		return 0, fmt.Errorf("touch wallet passes: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetWalletPushTargets returns the distinct push tokens of devices holding
+// a pass of the event, with the pass type to push under.
+func (s *PGStore) GetWalletPushTargets(ctx context.Context, eventID uuid.UUID) ([]models.WalletPushTarget, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT DISTINCT p.pass_type_id, r.push_token
+		FROM wallet_registrations r
+		JOIN wallet_passes p ON p.attendee_id = r.attendee_id
+		WHERE p.event_id = $1
+		ORDER BY 1, 2`, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("get wallet push targets: %w", err)
+	}
+	defer rows.Close()
+	var targets []models.WalletPushTarget
+	for rows.Next() {
+		var t models.WalletPushTarget
+		if err := rows.Scan(&t.PassTypeID, &t.PushToken); err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	return targets, rows.Err()
+}
+
+// DeleteWalletPushTokens drops the registrations of push tokens APNs
+// reported gone.
+func (s *PGStore) DeleteWalletPushTokens(ctx context.Context, tokens []string) (int64, error) {
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+	tag, err := s.db.Exec(ctx, `DELETE FROM wallet_registrations WHERE push_token = ANY($1)`, tokens)
+	if err != nil {
+		return 0, fmt.Errorf("delete wallet push tokens: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+func TestWalletPassRegistrations(t *testing.T) {
+	attendeeID, eventID := uuid.New(), uuid.New()
+	updated := time.Now()
+	const passType = "pass.com.example.idento"
+
+	mock := newImportMock(t)
+	mock.ExpectQuery(`INSERT INTO wallet_passes`).
+		WithArgs(attendeeID, eventID, passType, pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"attendee_id", "event_id", "pass_type_id", "auth_token", "updated_at"}).
+			AddRow(attendeeID, eventID, passType, "token", updated))
+	mock.ExpectQuery(`INSERT INTO wallet_registrations .* RETURNING xmax = 0`).
+		WithArgs("device-1", attendeeID, "push-1").
+		WillReturnRows(pgxmock.NewRows([]string{"created"}).AddRow(true))
+	mock.ExpectQuery(`FROM wallet_registrations r\s+JOIN wallet_passes p .* p.updated_at > \$3`).
+		WithArgs("device-1", passType, (*time.Time)(nil)).
+		WillReturnRows(pgxmock.NewRows([]string{"attendee_id", "updated_at"}).AddRow(attendeeID, updated))
+	mock.ExpectExec(`UPDATE wallet_passes SET updated_at = NOW\(\) WHERE event_id = \$1`).
+		WithArgs(eventID).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec(`DELETE FROM wallet_registrations WHERE push_token = ANY\(\$1\)`).
+		WithArgs([]string{"push-1"}).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	s := &PGStore{db: mock}
+	ctx := context.Background()
+
+	pass, err := s.EnsureWalletPass(ctx, attendeeID, eventID, passType)
+	if err != nil || pass.AuthToken != "token" {
+		t.Fatalf("EnsureWalletPass = %+v, %v", pass, err)
+	}
+	if created, err := s.RegisterWalletDevice(ctx, "device-1", attendeeID, "push-1"); err != nil || !created {
+		t.Fatalf("RegisterWalletDevice = %v, %v", created, err)
+	}
+	serials, latest, err := s.GetWalletDevicePasses(ctx, "device-1", passType, nil)
+	if err != nil || !slices.Equal(serials, []uuid.UUID{attendeeID}) || !latest.Equal(updated) {
+		t.Fatalf("GetWalletDevicePasses = %v, %v, %v", serials, latest, err)
+	}
+	if n, err := s.TouchWalletPasses(ctx, eventID); err != nil || n != 1 {
+		t.Fatalf("TouchWalletPasses = %d, %v", n, err)
+	}
+	if n, err := s.DeleteWalletPushTokens(ctx, nil); err != nil || n != 0 {
+		t.Fatalf("no tokens: %d, %v", n, err)
+	}
+	if n, err := s.DeleteWalletPushTokens(ctx, []string{"push-1"}); err != nil || n != 1 {
+		t.Fatalf("DeleteWalletPushTokens = %d, %v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+// Package tabular parses uploaded spreadsheets (CSV in any delimiter or
+// text encoding, and XLSX) into a header row plus string cells, so every
+// import client can upload the file as-is instead of re-implementing
+// parsing, encoding detection and header handling itself. It also writes
+// CSV and XLSX row by row for exports (Writer).
+package tabular
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Table is a parsed sheet: the trimmed header cells and the data rows
+// below them. Every row has exactly len(Header) cells (short rows are
+// padded with "", extra cells dropped); rows whose cells are all blank are
+// skipped.
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// Options tune parsing. The zero value auto-detects everything.
+type Options struct {
+	// Delimiter is the CSV field separator; 0 sniffs it from the header
+	// line among ',', ';', '\t' and '|'.
+	Delimiter rune
+	// Encoding is a WHATWG encoding label ("utf-8", "windows-1251",
+	// "utf-16le", ...). "" detects it: a BOM wins, valid UTF-8 is taken as
+	// UTF-8, anything else is read as Windows-1251 — what Excel writes for
+	// "CSV" on a Russian-locale Windows.
+	Encoding string
+	// Sheet names the XLSX worksheet to read; "" means the first one.
+	Sheet string
+}
+
+// ErrEmpty is returned when the file has no header row.
+var ErrEmpty = errors.New("file has no header row")
+
+// xlsxMagic is the ZIP local-file signature every XLSX starts with.
+var xlsxMagic = []byte("PK\x03\x04")
+
+// Parse reads data as XLSX when the filename ends in .xlsx or the content
+// is a ZIP container, and as CSV otherwise.
+func Parse(data []byte, filename string, opts Options) (*Table, error) {
+	if strings.EqualFold(filepath.Ext(filename), ".xlsx") || bytes.HasPrefix(data, xlsxMagic) {
+		return parseXLSX(data, opts.Sheet)
+	}
+	return parseCSV(data, opts)
+}
+
+func parseCSV(data []byte, opts Options) (*Table, error) {
+	text, err := decode(data, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	delim := opts.Delimiter
+	if delim == 0 {
+		delim = sniffDelimiter(text)
+	}
+	r := csv.NewReader(strings.NewReader(text))
+	r.Comma = delim
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parse csv: %w", err)
+	}
+	return newTable(records)
+}
+
+func parseXLSX(data []byte, sheet string) (*Table, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("open xlsx: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	if sheet == "" {
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, ErrEmpty
+		}
+		sheet = sheets[0]
+	}
+	records, err := f.GetRows(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("read sheet %q: %w", sheet, err)
+	}
+	return newTable(records)
+}
+
+func newTable(records [][]string) (*Table, error) {
+	// Leading blank lines (common above hand-made spreadsheets) are not
+	// the header.
+	for len(records) > 0 && blank(records[0]) {
+		records = records[1:]
+	}
+	if len(records) == 0 {
+		return nil, ErrEmpty
+	}
+	t := &Table{Header: make([]string, len(records[0]))}
+	for i, h := range records[0] {
+		t.Header[i] = strings.TrimSpace(h)
+	}
+	for _, rec := range records[1:] {
+		if blank(rec) {
+			continue
+		}
+		row := make([]string, len(t.Header))
+		for i := range row {
+			if i < len(rec) {
+				row[i] = strings.TrimSpace(rec[i])
+			}
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t, nil
+}
+
+func blank(rec []string) bool {
+	for _, cell := range rec {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// decode converts data to a UTF-8 string per the Options.Encoding rules,
+// dropping any BOM.
+func decode(data []byte, label string) (string, error) {
+	var enc encoding.Encoding
+	switch {
+	case label != "":
+		e, err := htmlindex.Get(label)
+		if err != nil {
+			return "", fmt.Errorf("unknown encoding %q", label)
+		}
+		enc = e
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:]), nil
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM)
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		enc = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+	case utf8.Valid(data):
+		return string(data), nil
+	default:
+		enc = charmap.Windows1251
+	}
+	out, _, err := transform.Bytes(unicode.BOMOverride(enc.NewDecoder()), data)
+	if err != nil {
+		return "", fmt.Errorf("decode: %w", err)
+	}
+	return string(out), nil
+}
+
+// sniffDelimiter picks the candidate separator that occurs most often
+// (outside quotes) in the first line, defaulting to a comma.
+func sniffDelimiter(text string) rune {
+	line := text
+	if i := strings.IndexAny(text, "\r\n"); i >= 0 {
+		line = text[:i]
+	}
+	counts := map[rune]int{}
+	inQuotes := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case !inQuotes && strings.ContainsRune(",;\t|", r):
+			counts[r]++
+		}
+	}
+	best, bestN := ',', 0
+	for _, r := range []rune{',', ';', '\t', '|'} {
+		if counts[r] > bestN {
+			best, bestN = r, counts[r]
+		}
+	}
+	return best
+}
+
+// Delimiter parses a user-supplied delimiter: a single character, or one
+// of the names "comma", "semicolon", "tab", "pipe". "" means auto (0).
+func Delimiter(s string) (rune, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return 0, nil
+	case "comma":
+		return ',', nil
+	case "semicolon":
+		return ';', nil
+	case "tab", `\t`:
+		return '\t', nil
+	case "pipe":
+		return '|', nil
+	}
+	if utf8.RuneCountInString(s) != 1 {
+		return 0, fmt.Errorf("delimiter must be a single character")
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	if r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, fmt.Errorf("invalid delimiter %q", s)
+	}
+	return r, nil
+}
+
+// Read parses at most limit bytes from r (an uploaded file), failing with
+// ErrTooLarge rather than truncating a bigger input.
+func Read(r io.Reader, limit int64, filename string, opts Options) (*Table, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrTooLarge
+	}
+	return Parse(data, filename, opts)
+}
+
+// ErrTooLarge is returned by Read when the input exceeds its limit.
+var ErrTooLarge = errors.New("file too large")
@@ -0,0 +1,98 @@
+package tabular
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestParseCSVWindows1251Semicolon(t *testing.T) {
+	src := "Имя;Фамилия;Email\r\nИван;Петров;ivan@example.com\r\n;;\r\n"
+	data, err := charmap.Windows1251.NewEncoder().Bytes([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := Parse(data, "guests.csv", Options{})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if want := []string{"Имя", "Фамилия", "Email"}; !reflect.DeepEqual(table.Header, want) {
+		t.Fatalf("header = %q, want %q", table.Header, want)
+	}
+	if want := [][]string{{"Иван", "Петров", "ivan@example.com"}}; !reflect.DeepEqual(table.Rows, want) {
+		t.Fatalf("rows = %q, want %q (blank row skipped)", table.Rows, want)
+	}
+}
+
+func TestParseCSVUTF16WithBOMAndTabs(t *testing.T) {
+	enc := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder()
+	data, err := enc.Bytes([]byte("first_name\tlast_name\nAnn\tLee\tEXTRA\nBob\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := Parse(data, "export.txt", Options{})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := [][]string{{"Ann", "Lee"}, {"Bob", ""}}
+	if !reflect.DeepEqual(table.Rows, want) {
+		t.Fatalf("rows = %q, want %q (extra cells dropped, short rows padded)", table.Rows, want)
+	}
+}
+
+func TestParseCSVExplicitOptions(t *testing.T) {
+	// A quoted comma must not win the sniff; an explicit delimiter and
+	// encoding are honoured as given.
+	table, err := Parse([]byte("a|b\n\"x,y\"|z\n"), "f.csv", Options{Delimiter: '|', Encoding: "utf-8"})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if table.Rows[0][0] != "x,y" || table.Rows[0][1] != "z" {
+		t.Fatalf("rows = %q", table.Rows)
+	}
+	if _, err := Parse([]byte("a,b\n"), "f.csv", Options{Encoding: "no-such-charset"}); err == nil {
+		t.Fatal("unknown encoding accepted")
+	}
+	if _, err := Parse([]byte("\n\n"), "f.csv", Options{}); err != ErrEmpty {
+		t.Fatalf("err = %v, want ErrEmpty", err)
+	}
+}
+
+func TestParseXLSX(t *testing.T) {
+	f := excelize.NewFile()
+	_ = f.SetSheetRow("Sheet1", "A1", &[]interface{}{"First Name", "Email"})
+	_ = f.SetSheetRow("Sheet1", "A2", &[]interface{}{"Ann", "ann@example.com"})
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// Detected by content, not only by extension.
+	table, err := Parse(buf.Bytes(), "upload", Options{})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if table.Header[0] != "First Name" || len(table.Rows) != 1 || table.Rows[0][1] != "ann@example.com" {
+		t.Fatalf("table = %+v", table)
+	}
+	if _, err := Parse(buf.Bytes(), "upload.xlsx", Options{Sheet: "Missing"}); err == nil {
+		t.Fatal("missing sheet accepted")
+	}
+}
+
+func TestDelimiter(t *testing.T) {
+	cases := map[string]rune{"": 0, "tab": '\t', "semicolon": ';', ";": ';', "|": '|'}
+	for in, want := range cases {
+		if got, err := Delimiter(in); err != nil || got != want {
+			t.Errorf("Delimiter(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{`"`, ";;", "\n"} {
+		if _, err := Delimiter(bad); err == nil {
+			t.Errorf("Delimiter(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package tabular
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Writer emits a sheet one row at a time, so an export can stream rows as
+// it reads them instead of building the whole table first. Exactly one of
+// Close (after the last row) or Discard (to abandon the file) must be
+// called; nothing may be written after either.
+type Writer interface {
+	WriteRow(cells []string) error
+	Close() error
+	Discard()
+}
+
+// NewCSVWriter writes comma-separated UTF-8 to w. Output is buffered and
+// reaches w in chunks as rows accumulate; Close flushes the rest. Cells are
+// written verbatim — guarding against formula injection is the caller's
+// job, since only it knows which cells are user-controlled.
+func NewCSVWriter(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (cw *csvWriter) WriteRow(cells []string) error {
+	return cw.w.Write(cells)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// Discard drops the rows still buffered; whatever already reached w stays.
+func (cw *csvWriter) Discard() {}
+
+// NewXLSXWriter writes a single-sheet workbook to w. Rows go through
+// excelize's stream writer, which spills to a temporary file once the sheet
+// outgrows its in-memory buffer; the workbook itself is only written to w
+// by Close, since the XLSX container can't be emitted before its last row.
+// Every cell is a plain string cell, never a formula.
+func NewXLSXWriter(w io.Writer, sheet string) (Writer, error) {
+	f := excelize.NewFile()
+	if sheet != "" && sheet != "Sheet1" {
+		if err := f.SetSheetName("Sheet1", sheet); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("name sheet: %w", err)
+		}
+	} else {
+		sheet = "Sheet1"
+	}
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("open xlsx stream: %w", err)
+	}
+	return &xlsxWriter{f: f, sw: sw, w: w}, nil
+}
+
+type xlsxWriter struct {
+	f  *excelize.File
+	sw *excelize.StreamWriter
+	w  io.Writer
+	n  int
+}
+
+func (xw *xlsxWriter) WriteRow(cells []string) error {
+	xw.n++
+	cell, err := excelize.CoordinatesToCellName(1, xw.n)
+	if err != nil {
+		return err
+	}
+	values := make([]interface{}, len(cells))
+	for i, v := range cells {
+		values[i] = v
+	}
+	return xw.sw.SetRow(cell, values)
+}
+
+// Discard releases the workbook (and any temporary file) without writing.
+func (xw *xlsxWriter) Discard() {
+	_ = xw.f.Close()
+}
+
+func (xw *xlsxWriter) Close() error {
+	defer func() { _ = xw.f.Close() }()
+	if err := xw.sw.Flush(); err != nil {
+		return fmt.Errorf("flush xlsx stream: %w", err)
+	}
+	if _, err := xw.f.WriteTo(xw.w); err != nil {
+		return fmt.Errorf("write xlsx: %w", err)
+	}
+	return nil
+}
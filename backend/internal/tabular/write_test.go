@@ -0,0 +1,48 @@
+package tabular
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// Both writers produce files Parse reads back cell for cell, formula-like
+// text included.
+func TestWritersRoundTrip(t *testing.T) {
+	rows := [][]string{
+		{"code", "first_name", "Зал A"},
+		{"A1", "Ада", "2024-05-01 10:00:00"},
+		{"B2", "=1+1", ""},
+	}
+	for _, tc := range []struct {
+		name     string
+		filename string
+		open     func(*bytes.Buffer) (Writer, error)
+	}{
+		{"csv", "export.csv", func(b *bytes.Buffer) (Writer, error) { return NewCSVWriter(b), nil }},
+		{"xlsx", "export.xlsx", func(b *bytes.Buffer) (Writer, error) { return NewXLSXWriter(b, "Attendees") }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := tc.open(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range rows {
+				if err := w.WriteRow(r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			table, err := Parse(buf.Bytes(), tc.filename, Options{Sheet: map[string]string{"xlsx": "Attendees"}[tc.name]})
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !reflect.DeepEqual(table.Header, rows[0]) || !reflect.DeepEqual(table.Rows, rows[1:]) {
+				t.Fatalf("round trip = %q / %q, want %q", table.Header, table.Rows, rows)
+			}
+		})
+	}
+}
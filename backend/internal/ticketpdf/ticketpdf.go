@@ -0,0 +1,345 @@
+// Package ticketpdf renders printable PDF e-tickets: one page per
+// attendee with the event's name, dates and location, the attendee's name,
+// ticket type and details, and the check-in QR code, laid out per an
+// event's Layout and branded with the tenant's logo and the event's fonts.
+package ticketpdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// Page sizes a Layout can use.
+var pageSizes = map[string]fpdf.SizeType{
+	"a4":     {Wd: 210, Ht: 297},
+	"a5":     {Wd: 148, Ht: 210},
+	"a6":     {Wd: 105, Ht: 148},
+	"letter": {Wd: 215.9, Ht: 279.4},
+}
+
+const (
+	maxDetails   = 10
+	maxNoteLen   = 1000
+	maxFieldName = 100
+)
+
+// Layout is an event's ticket layout template. The zero value, after
+// Normalize, is an A5 portrait ticket in the built-in typeface.
+type Layout struct {
+	PageSize    string `json:"page_size"`    // a4, a5, a6 or letter
+	Orientation string `json:"orientation"`  // portrait or landscape
+	AccentColor string `json:"accent_color"` // #RRGGBB of the header band and ticket type
+	// FontFamily names one of the event's uploaded font families; only
+	// TrueType files are embeddable, so a family without one (or an empty
+	// name) uses the built-in typeface.
+	FontFamily string `json:"font_family"`
+	ShowLogo   bool   `json:"show_logo"` // print the tenant's logo in the header
+	// TicketTypeField names the attendee field (standard or custom) shown
+	// as the ticket type; its line is left out when the attendee has none.
+	TicketTypeField string `json:"ticket_type_field"`
+	// Details are attendee fields printed under the name, one per line,
+	// skipping empty ones.
+	Details []string `json:"details"`
+	Note    string   `json:"note"` // fine print at the bottom of the ticket
+}
+
+// DefaultLayout is the layout of an event that has not saved one.
+func DefaultLayout() Layout {
+	l := Layout{ShowLogo: true, TicketTypeField: "ticket_type", Details: []string{"company"}}
+	_ = l.Normalize()
+	return l
+}
+
+// Normalize fills defaults in l and validates it; the error is fit for a
+// 400.
+func (l *Layout) Normalize() error {
+	if l.PageSize == "" {
+		l.PageSize = "a5"
+	}
+	if _, ok := pageSizes[l.PageSize]; !ok {
+		return errors.New("page_size must be a4, a5, a6 or letter")
+	}
+	if l.Orientation == "" {
+		l.Orientation = "portrait"
+	}
+	if l.Orientation != "portrait" && l.Orientation != "landscape" {
+		return errors.New("orientation must be portrait or landscape")
+	}
+	if l.AccentColor == "" {
+		l.AccentColor = "#1F2937"
+	}
+	if _, err := parseColor(l.AccentColor); err != nil {
+		return err
+	}
+	l.FontFamily = strings.TrimSpace(l.FontFamily)
+	l.TicketTypeField = strings.TrimSpace(l.TicketTypeField)
+	if len(l.TicketTypeField) > maxFieldName {
+		return fmt.Errorf("ticket_type_field is limited to %d characters", maxFieldName)
+	}
+	if l.Details == nil {
+		l.Details = []string{}
+	}
+	if len(l.Details) > maxDetails {
+		return fmt.Errorf("details is limited to %d fields", maxDetails)
+	}
+	for i, d := range l.Details {
+		l.Details[i] = strings.TrimSpace(d)
+		if l.Details[i] == "" || len(l.Details[i]) > maxFieldName {
+			return fmt.Errorf("details[%d] must be a field name of at most %d characters", i, maxFieldName)
+		}
+	}
+	if len(l.Note) > maxNoteLen {
+		return fmt.Errorf("note is limited to %d characters", maxNoteLen)
+	}
+	return nil
+}
+
+type rgb struct{ r, g, b int }
+
+func parseColor(s string) (rgb, error) {
+	if len(s) != 7 || s[0] != '#' {
+		return rgb{}, errors.New("accent_color must be #RRGGBB")
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return rgb{}, errors.New("accent_color must be #RRGGBB")
+	}
+	return rgb{int(v >> 16), int(v >> 8 & 0xff), int(v & 0xff)}, nil
+}
+
+// Ticket is what one ticket shows.
+type Ticket struct {
+	EventName string
+	Dates     string // preformatted, e.g. 2027-05-01 – 2027-05-03
+	Location  string
+	Name      string
+	Type      string   // ticket type, may be empty
+	Details   []string // extra lines under the name
+	Code      string
+	QR        []byte // PNG of the check-in QR code
+}
+
+// Branding is the tenant's and event's look.
+type Branding struct {
+	// Logo is a PNG, JPEG or GIF image; nil prints no logo.
+	Logo []byte
+	// Regular and Bold are TrueType files of the layout's font family;
+	// nil uses the built-in typeface (which covers Latin and Cyrillic).
+	Regular, Bold []byte
+}
+
+// Renderer renders tickets with one layout and branding.
+type Renderer struct {
+	layout    Layout
+	accent    rgb
+	size      fpdf.SizeType
+	orient    string
+	regular   []byte
+	bold      []byte
+	logo      []byte
+	logoType  string
+	createdAt time.Time
+}
+
+const fontFamily = "ticket"
+
+// NewRenderer checks the branding once for every ticket it will render: a
+// font that cannot be embedded falls back to the built-in typeface and a
+// logo that cannot be decoded is left out, so a bad upload never stops
+// tickets from printing. layout must be normalized.
+func NewRenderer(layout Layout, b Branding) *Renderer {
+	r := &Renderer{
+		layout:    layout,
+		size:      pageSizes[layout.PageSize],
+		orient:    "P",
+		regular:   goregular.TTF,
+		bold:      gobold.TTF,
+		createdAt: time.Now(),
+	}
+	r.accent, _ = parseColor(layout.AccentColor)
+	if layout.Orientation == "landscape" {
+		r.orient = "L"
+	}
+	if b.Regular != nil && embeddable(b.Regular) {
+		r.regular, r.bold = b.Regular, b.Regular
+		if b.Bold != nil && embeddable(b.Bold) {
+			r.bold = b.Bold
+		}
+	}
+	if layout.ShowLogo && b.Logo != nil {
+		if t := imageType(b.Logo); t != "" && r.logoFits(b.Logo, t) {
+			r.logo, r.logoType = b.Logo, t
+		}
+	}
+	return r
+}
+
+// embeddable reports whether fpdf can embed a font file, which it does
+// only for TrueType outlines. Its parser may panic on a corrupt file.
+func embeddable(ttf []byte) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	if len(ttf) < 4 || !bytes.Equal(ttf[:4], []byte{0, 1, 0, 0}) && string(ttf[:4]) != "true" {
+		return false
+	}
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(fontFamily, "", ttf)
+	pdf.SetFont(fontFamily, "", 10)
+	return !pdf.Err()
+}
+
+func imageType(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "PNG"
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8, 0xff}):
+		return "JPG"
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		return "GIF"
+	}
+	return ""
+}
+
+// logoFits reports whether fpdf can decode the logo.
+func (r *Renderer) logoFits(logo []byte, typ string) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	pdf := fpdf.New("P", "mm", "A4", "")
+	info := pdf.RegisterImageOptionsReader("logo", fpdf.ImageOptions{ImageType: typ}, bytes.NewReader(logo))
+	return !pdf.Err() && info != nil && info.Width() > 0 && info.Height() > 0
+}
+
+// Render writes t as a one-page PDF to w.
+func (r *Renderer) Render(w io.Writer, t Ticket) error {
+	pdf := fpdf.NewCustom(&fpdf.InitType{OrientationStr: r.orient, UnitStr: "mm", Size: r.size})
+	pdf.SetCreationDate(r.createdAt)
+	pdf.SetTitle(strings.TrimSpace(t.EventName+" — "+t.Name), true)
+	pdf.SetCreator("Idento", true)
+	pdf.AddUTF8FontFromBytes(fontFamily, "", r.regular)
+	pdf.AddUTF8FontFromBytes(fontFamily, "B", r.bold)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddPage()
+
+	pageW, pageH := pdf.GetPageSize()
+	margin := math.Max(8, pageW*0.06)
+	contentW := pageW - 2*margin
+	scale := math.Min(1, pageW/148) // type is sized for A5 and shrinks on A6
+
+	// Header band: logo and event name on the accent color.
+	bandH := 26 * scale
+	pdf.SetFillColor(r.accent.r, r.accent.g, r.accent.b)
+	pdf.Rect(0, 0, pageW, bandH, "F")
+	textX := margin
+	if r.logo != nil {
+		logoH := bandH - 8*scale
+		info := pdf.RegisterImageOptionsReader("logo", fpdf.ImageOptions{ImageType: r.logoType}, bytes.NewReader(r.logo))
+		logoW := math.Min(logoH*info.Width()/info.Height(), contentW/3)
+		pdf.ImageOptions("logo", margin, 4*scale, logoW, 0, false, fpdf.ImageOptions{ImageType: r.logoType}, 0, "")
+		textX += logoW + 4*scale
+	}
+	pdf.SetTextColor(255, 255, 255)
+	pdf.SetFont(fontFamily, "B", 16*scale)
+	pdf.SetXY(textX, 0)
+	pdf.CellFormat(margin+contentW-textX, bandH, fit(pdf, t.EventName, margin+contentW-textX), "", 0, "LM", false, 0, "")
+
+	// Event details.
+	y := bandH + 8*scale
+	pdf.SetTextColor(75, 85, 99)
+	pdf.SetFont(fontFamily, "", 11*scale)
+	for _, line := range []string{t.Dates, t.Location} {
+		if line == "" {
+			continue
+		}
+		pdf.SetXY(margin, y)
+		pdf.MultiCell(contentW, 5.5*scale, line, "", "L", false)
+		y = pdf.GetY()
+	}
+	y += 4 * scale
+	pdf.SetDrawColor(209, 213, 219)
+	pdf.SetLineWidth(0.3)
+	pdf.Line(margin, y, pageW-margin, y)
+	y += 6 * scale
+
+	// The attendee.
+	pdf.SetTextColor(17, 24, 39)
+	pdf.SetFont(fontFamily, "B", 22*scale)
+	pdf.SetXY(margin, y)
+	pdf.MultiCell(contentW, 9*scale, t.Name, "", "L", false)
+	y = pdf.GetY() + 1*scale
+	if t.Type != "" {
+		pdf.SetTextColor(r.accent.r, r.accent.g, r.accent.b)
+		pdf.SetFont(fontFamily, "B", 13*scale)
+		pdf.SetXY(margin, y)
+		pdf.MultiCell(contentW, 6.5*scale, t.Type, "", "L", false)
+		y = pdf.GetY()
+	}
+	pdf.SetTextColor(55, 65, 81)
+	pdf.SetFont(fontFamily, "", 11*scale)
+	for _, line := range t.Details {
+		pdf.SetXY(margin, y)
+		pdf.MultiCell(contentW, 5.5*scale, line, "", "L", false)
+		y = pdf.GetY()
+	}
+
+	// The QR code and its code, centered in what is left above the note.
+	noteH := 0.0
+	if r.layout.Note != "" {
+		pdf.SetFont(fontFamily, "", 8*scale)
+		noteH = float64(len(pdf.SplitText(r.layout.Note, contentW)))*4*scale + 4*scale
+	}
+	codeH := 8 * scale
+	space := pageH - margin - noteH - y - 6*scale
+	qrSize := math.Min(math.Min(contentW, 70), space-codeH)
+	if qrSize < 20 {
+		qrSize = 20 // overlaps the note rather than dropping the code
+	}
+	qrY := y + 6*scale + math.Max(0, (space-qrSize-codeH)/2)
+	if len(t.QR) > 0 {
+		pdf.RegisterImageOptionsReader("qr", fpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(t.QR))
+		pdf.ImageOptions("qr", (pageW-qrSize)/2, qrY, qrSize, qrSize, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+	}
+	pdf.SetTextColor(17, 24, 39)
+	pdf.SetFont(fontFamily, "B", 12*scale)
+	pdf.SetXY(margin, qrY+qrSize)
+	pdf.CellFormat(contentW, codeH, t.Code, "", 0, "CM", false, 0, "")
+
+	if r.layout.Note != "" {
+		pdf.SetTextColor(107, 114, 128)
+		pdf.SetFont(fontFamily, "", 8*scale)
+		pdf.SetXY(margin, pageH-margin-noteH+4*scale)
+		pdf.MultiCell(contentW, 4*scale, r.layout.Note, "", "L", false)
+	}
+
+	if err := pdf.Error(); err != nil {
+		return fmt.Errorf("render ticket: %w", err)
+	}
+	return pdf.Output(w)
+}
+
+// fit shortens s with an ellipsis until it fits width in the current font.
+func fit(pdf *fpdf.Fpdf, s string, width float64) string {
+	if pdf.GetStringWidth(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
@@ -0,0 +1,111 @@
+package ticketpdf
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, x%h, color.Black)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func render(t *testing.T, r *Renderer, ticket Ticket) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := r.Render(&buf, ticket); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "%PDF-") || !strings.Contains(out, "%%EOF") {
+		t.Fatalf("output is not a PDF: %.40q", out)
+	}
+	if n := strings.Count(out, "/Type /Page\n"); n != 1 {
+		t.Errorf("%d pages, want 1", n)
+	}
+	return out
+}
+
+func TestRenderEveryPageSize(t *testing.T) {
+	ticket := Ticket{
+		EventName: "Технологический саммит 2027 с очень длинным названием, которое не помещается в шапку",
+		Dates:     "2027-05-01 – 2027-05-03",
+		Location:  "Main Hall",
+		Name:      "Ада Лавлейс",
+		Type:      "VIP",
+		Details:   []string{"Analytical Engines Ltd"},
+		Code:      "ABC123",
+		QR:        testPNG(t, 64, 64),
+	}
+	for size := range pageSizes {
+		for _, orientation := range []string{"portrait", "landscape"} {
+			l := Layout{PageSize: size, Orientation: orientation, ShowLogo: true, Note: strings.Repeat("Bring photo ID. ", 20)}
+			if err := l.Normalize(); err != nil {
+				t.Fatalf("Normalize: %v", err)
+			}
+			out := render(t, NewRenderer(l, Branding{Logo: testPNG(t, 300, 100)}), ticket)
+			if strings.Count(out, "/Subtype /Image") != 2 {
+				t.Errorf("%s %s: want the logo and QR images", size, orientation)
+			}
+		}
+	}
+}
+
+// A font or logo that cannot be used falls back instead of failing.
+func TestRendererFallsBack(t *testing.T) {
+	l := DefaultLayout()
+	r := NewRenderer(l, Branding{Logo: []byte("not an image"), Regular: []byte("OTTO not truetype")})
+	if r.logo != nil {
+		t.Error("an undecodable logo was kept")
+	}
+	if !bytes.Equal(r.regular, goregular.TTF) {
+		t.Error("a non-TrueType font was kept")
+	}
+	render(t, r, Ticket{Name: "Ada", Code: "ABC123"})
+
+	r = NewRenderer(l, Branding{Regular: gomono.TTF, Bold: []byte{0, 1, 0, 0, 1, 2, 3}})
+	if !bytes.Equal(r.regular, gomono.TTF) || !bytes.Equal(r.bold, gomono.TTF) {
+		t.Error("an uploaded TrueType regular should serve for bold when the bold file is bad")
+	}
+	render(t, r, Ticket{Name: "Ada", Code: "ABC123"})
+
+	l.ShowLogo = false
+	if NewRenderer(l, Branding{Logo: testPNG(t, 10, 10)}).logo != nil {
+		t.Error("the logo was kept with show_logo off")
+	}
+}
+
+func TestLayoutNormalize(t *testing.T) {
+	l := DefaultLayout()
+	if l.PageSize != "a5" || l.Orientation != "portrait" || l.AccentColor != "#1F2937" || l.TicketTypeField != "ticket_type" {
+		t.Errorf("defaults = %+v", l)
+	}
+	for _, bad := range []Layout{
+		{PageSize: "a3"},
+		{Orientation: "sideways"},
+		{AccentColor: "red"},
+		{AccentColor: "#12345G"},
+		{Details: []string{" "}},
+		{Details: make([]string, maxDetails+1)},
+		{Note: strings.Repeat("x", maxNoteLen+1)},
+	} {
+		if err := bad.Normalize(); err == nil {
+			t.Errorf("%+v: want an error", bad)
+		}
+	}
+}
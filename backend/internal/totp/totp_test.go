@@ -0,0 +1,57 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B vectors for SHA1, truncated to 6 digits.
+func TestCodeMatchesRFC6238Vectors(t *testing.T) {
+	secret := encoding.EncodeToString([]byte("12345678901234567890"))
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tc := range cases {
+		got, err := Code(secret, Step(time.Unix(tc.unix, 0)))
+		if err != nil || got != tc.want {
+			t.Errorf("Code at %d = %q, %v; want %q", tc.unix, got, err, tc.want)
+		}
+	}
+}
+
+func TestValidateAcceptsAdjacentStepsOnly(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	for offset, wantOK := range map[int64]bool{-2: false, -1: true, 0: true, 1: true, 2: false} {
+		code, err := Code(secret, Step(now)+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, ok := Validate(secret, code[:3]+" "+code[3:], now)
+		if ok != wantOK || (ok && step != Step(now)+offset) {
+			t.Errorf("offset %d: Validate = %d, %v; want ok=%v", offset, step, ok, wantOK)
+		}
+	}
+	if _, ok := Validate(secret, "12345", now); ok {
+		t.Error("short code accepted")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Idento", "anna@example.com", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/Idento:anna@example.com?") ||
+		!strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") || !strings.Contains(uri, "issuer=Idento") {
+		t.Errorf("URI = %q", uri)
+	}
+}
@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QueryTracer is a pgx.QueryTracer that wraps every query in a client span
+// named after its SQL verb (SELECT, UPDATE, ...). Queries inside a
+// transaction each get their own span, so a retried statement shows up
+// as one span per attempt.
+type QueryTracer struct{}
+
+var _ pgx.QueryTracer = QueryTracer{}
+
+func (QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, _ = Tracer().Start(ctx, queryVerb(data.SQL),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemNamePostgreSQL, semconv.DBQueryText(data.SQL)),
+	)
+	return ctx
+}
+
+func (QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(semconv.DBResponseReturnedRows(int(data.CommandTag.RowsAffected())))
+	End(span, data.Err)
+}
+
+// queryVerb is the first keyword of sql, upper-cased, for the span name.
+// The full text goes in db.query.text; parameters are never recorded.
+func queryVerb(sql string) string {
+	sql = strings.TrimLeftFunc(sql, unicode.IsSpace)
+	if end := strings.IndexFunc(sql, unicode.IsSpace); end >= 0 {
+		sql = sql[:end]
+	}
+	if sql == "" {
+		return "QUERY"
+	}
+	return strings.ToUpper(sql)
+}
@@ -0,0 +1,129 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"idento/backend/internal/config"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a provider that keeps every ended span in memory
+// and restores the previous global provider when the test ends.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return rec
+}
+
+func spanAttr(s sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestMiddlewareStartsServerSpanPerRoute(t *testing.T) {
+	rec := recordSpans(t)
+	e := echo.New()
+	e.Use(Middleware())
+	e.Use(middleware.Recover())
+	e.GET("/api/events/:event_id", func(c echo.Context) error {
+		_, child := Tracer().Start(c.Request().Context(), "child")
+		child.End()
+		return c.NoContent(http.StatusNoContent)
+	})
+	e.GET("/api/panics", func(c echo.Context) error { panic("boom") })
+
+	req := httptest.NewRequest(http.MethodGet, "/api/events/11111111-1111-1111-1111-111111111111", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	res := httptest.NewRecorder()
+	e.ServeHTTP(res, req)
+
+	if got := res.Header().Get(TraceIDHeader); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("%s = %q, want the incoming trace ID", TraceIDHeader, got)
+	}
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want child + server", len(spans))
+	}
+	child, server := spans[0], spans[1]
+	if server.Name() != "GET /api/events/:event_id" {
+		t.Errorf("server span name = %q", server.Name())
+	}
+	if child.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("handler span is not a child of the request span")
+	}
+	if got := spanAttr(server, "http.response.status_code").AsInt64(); got != http.StatusNoContent {
+		t.Errorf("status code attribute = %d", got)
+	}
+
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/panics", nil))
+	panicked := rec.Ended()[2]
+	if panicked.Status().Code != codes.Error || spanAttr(panicked, "http.response.status_code").AsInt64() != http.StatusInternalServerError {
+		t.Errorf("recovered panic span: status %v, code %v", panicked.Status(), spanAttr(panicked, "http.response.status_code"))
+	}
+}
+
+func TestEndRecordsError(t *testing.T) {
+	rec := recordSpans(t)
+	_, span := Tracer().Start(context.Background(), "ok")
+	End(span, nil)
+	_, span = Tracer().Start(context.Background(), "failed")
+	End(span, errors.New("boom"))
+
+	spans := rec.Ended()
+	if spans[0].Status().Code != codes.Unset {
+		t.Errorf("ok span status = %v", spans[0].Status())
+	}
+	if spans[1].Status().Code != codes.Error || len(spans[1].Events()) != 1 {
+		t.Errorf("failed span status = %v, events = %d", spans[1].Status(), len(spans[1].Events()))
+	}
+}
+
+func TestQueryVerb(t *testing.T) {
+	for sql, want := range map[string]string{
+		"SELECT 1":                   "SELECT",
+		"\n\t\tupdate attendees SET": "UPDATE",
+		"INSERT\nINTO x":             "INSERT",
+		"  ":                         "QUERY",
+	} {
+		if got := queryVerb(sql); got != want {
+			t.Errorf("queryVerb(%q) = %q, want %q", sql, got, want)
+		}
+	}
+}
+
+func TestSetupNoneInstallsNothing(t *testing.T) {
+	before := otel.GetTracerProvider()
+	shutdown, err := Setup(context.Background(), &config.Config{TracingExporter: config.TracingNone}, "test")
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if otel.GetTracerProvider() != before {
+		t.Error("Setup(none) replaced the global tracer provider")
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// PushResult reports how a round of update pushes went.
+type PushResult struct {
+	Sent int
+	// Gone lists tokens APNs reported as no longer valid (the pass was
+	// removed or the device reset); their registrations should be dropped.
+	Gone []string
+}
+
+// Push asks APNs to wake the devices behind tokens, each of which then
+// asks the PassKit web service which of its passes changed. The request
+// authenticates with the pass certificate and its topic is the pass type
+// identifier. Pushing continues past failures; the first one is returned
+// alongside the result.
+func (c *Client) Push(ctx context.Context, s *Signer, tokens []string) (PushResult, error) {
+	var res PushResult
+	if len(tokens) == 0 {
+		return res, nil
+	}
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			Certificates: []tls.Certificate{s.tlsCertificate()},
+			RootCAs:      c.RootCAs,
+			MinVersion:   tls.VersionTLS12,
+		},
+		ForceAttemptHTTP2: true,
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport, Timeout: requestTimeout}
+
+	var firstErr error
+	for _, token := range tokens {
+		gone, err := c.pushOne(ctx, client, s.PassTypeID, token)
+		switch {
+		case err != nil:
+			if firstErr == nil {
+				firstErr = err
+			}
+			if ctx.Err() != nil {
+				return res, firstErr
+			}
+		case gone:
+			res.Gone = append(res.Gone, token)
+		default:
+			res.Sent++
+		}
+	}
+	return res, firstErr
+}
+
+func (c *Client) pushOne(ctx context.Context, client *http.Client, topic, token string) (gone bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		c.apnsURL()+"/3/device/"+url.PathEscape(token), bytes.NewReader([]byte("{}")))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("apns-topic", topic)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("apns: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return false, nil
+	}
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&body)
+	// 410: the token is no longer active; BadDeviceToken: it never was.
+	if resp.StatusCode == http.StatusGone || body.Reason == "BadDeviceToken" || body.Reason == "Unregistered" {
+		return true, nil
+	}
+	if body.Reason == "" {
+		body.Reason = resp.Status
+	}
+	return false, errors.New("apns: " + body.Reason)
+}
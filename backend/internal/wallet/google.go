@@ -0,0 +1,231 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	googleSaveURL   = "https://pay.google.com/gp/v/save/"
+	googleTokenURL  = "https://oauth2.googleapis.com/token"
+	googleScope     = "https://www.googleapis.com/auth/wallet_object.issuer"
+	googleClassPath = "/walletobjects/v1/eventTicketClass/"
+)
+
+// ErrGoogleClassNotFound is returned by UpdateGoogleClass when no one has
+// saved a ticket of the event yet, so Google has no class to update.
+var ErrGoogleClassNotFound = errors.New("google wallet class not found")
+
+var issuerIDPattern = regexp.MustCompile(`^[0-9]{10,25}$`)
+
+// ServiceAccount is a Google Cloud service account key allowed to issue
+// Wallet passes for an issuer.
+type ServiceAccount struct {
+	Email    string
+	KeyID    string
+	TokenURI string
+	Key      *rsa.PrivateKey
+}
+
+// ParseServiceAccount reads a service account key file (JSON, as
+// downloaded from the Cloud console).
+func ParseServiceAccount(data []byte) (*ServiceAccount, error) {
+	var f struct {
+		Type         string `json:"type"`
+		ClientEmail  string `json:"client_email"`
+		PrivateKeyID string `json:"private_key_id"`
+		PrivateKey   string `json:"private_key"`
+		TokenURI     string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, errors.New("the service account key is not JSON")
+	}
+	if f.Type != "service_account" || f.ClientEmail == "" || f.PrivateKey == "" {
+		return nil, errors.New("not a service account key: type, client_email and private_key are required")
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(f.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("read service account private_key: %w", err)
+	}
+	a := &ServiceAccount{Email: f.ClientEmail, KeyID: f.PrivateKeyID, TokenURI: f.TokenURI, Key: key}
+	if a.TokenURI == "" {
+		a.TokenURI = googleTokenURL
+	}
+	return a, nil
+}
+
+// ValidIssuerID reports whether id looks like a Google Wallet issuer ID.
+func ValidIssuerID(id string) bool {
+	return issuerIDPattern.MatchString(id)
+}
+
+// GoogleClassID is the event ticket class of an event: one per event,
+// shared by its attendees' passes.
+func GoogleClassID(issuerID, eventID string) string {
+	return issuerID + ".event-" + eventID
+}
+
+func googleObjectID(issuerID, serial string) string {
+	return issuerID + ".attendee-" + serial
+}
+
+type localized struct {
+	DefaultValue struct {
+		Language string `json:"language"`
+		Value    string `json:"value"`
+	} `json:"defaultValue"`
+}
+
+func localize(v string) *localized {
+	l := &localized{}
+	l.DefaultValue.Language = "en"
+	l.DefaultValue.Value = v
+	return l
+}
+
+func googleClass(issuerID string, t Ticket) map[string]interface{} {
+	class := map[string]interface{}{
+		"id":           GoogleClassID(issuerID, t.EventID),
+		"issuerName":   t.Organization,
+		"reviewStatus": "UNDER_REVIEW",
+		"eventName":    localize(t.EventName),
+	}
+	if t.Location != "" {
+		class["venue"] = map[string]interface{}{"name": localize(t.Location), "address": localize(t.Location)}
+	}
+	if t.Start != nil {
+		dt := map[string]string{"start": t.Start.UTC().Format(time.RFC3339)}
+		if t.End != nil {
+			dt["end"] = t.End.UTC().Format(time.RFC3339)
+		}
+		class["dateTime"] = dt
+	}
+	if strings.HasPrefix(t.LogoURL, "https://") {
+		class["logo"] = map[string]interface{}{"sourceUri": map[string]string{"uri": t.LogoURL}}
+	}
+	if _, _, _, ok := parseHexColor(t.Color); ok {
+		class["hexBackgroundColor"] = t.Color
+	}
+	return class
+}
+
+// SaveURL returns the "Add to Google Wallet" link for the ticket: a JWT
+// carrying the event's class and the attendee's object (barcode = the
+// attendee's code), which Google creates on first save. origins are the
+// web origins allowed to show the save button.
+func (a *ServiceAccount) SaveURL(issuerID string, t Ticket, origins []string) (string, error) {
+	object := map[string]interface{}{
+		"id":               googleObjectID(issuerID, t.Serial),
+		"classId":          GoogleClassID(issuerID, t.EventID),
+		"state":            "ACTIVE",
+		"ticketHolderName": t.AttendeeName,
+		"barcode": map[string]string{
+			"type":          "QR_CODE",
+			"value":         t.Code,
+			"alternateText": t.Code,
+		},
+	}
+	claims := jwt.MapClaims{
+		"iss":     a.Email,
+		"aud":     "google",
+		"typ":     "savetowallet",
+		"iat":     time.Now().Unix(),
+		"origins": origins,
+		"payload": map[string]interface{}{
+			"eventTicketClasses": []interface{}{googleClass(issuerID, t)},
+			"eventTicketObjects": []interface{}{object},
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	if a.KeyID != "" {
+		token.Header["kid"] = a.KeyID
+	}
+	signed, err := token.SignedString(a.Key)
+	if err != nil {
+		return "", err
+	}
+	return googleSaveURL + signed, nil
+}
+
+// accessToken trades a signed assertion for an OAuth access token
+// (RFC 7523 JWT bearer grant).
+func (c *Client) accessToken(ctx context.Context, a *ServiceAccount) (string, error) {
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   a.Email,
+		"scope": googleScope,
+		"aud":   a.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(a.Key)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("google token: %w", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&body); err != nil || resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return "", fmt.Errorf("google token: %s %s", resp.Status, body.Error)
+	}
+	return body.AccessToken, nil
+}
+
+// UpdateGoogleClass brings the event's class (name, dates, venue) in line
+// with t; Google then updates every saved pass of the event.
+func (c *Client) UpdateGoogleClass(ctx context.Context, a *ServiceAccount, issuerID string, t Ticket) error {
+	token, err := c.accessToken(ctx, a)
+	if err != nil {
+		return err
+	}
+	class := googleClass(issuerID, t)
+	body, err := json.Marshal(class)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch,
+		c.googleAPIURL()+googleClassPath+url.PathEscape(class["id"].(string)), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("google wallet: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrGoogleClassNotFound
+	case resp.StatusCode/100 != 2:
+		return fmt.Errorf("google wallet: update class: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package wallet
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	_ "image/gif" // logo formats
+	_ "image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+)
+
+// Pass image sizes in points; each is also rendered at 2x.
+const (
+	iconSize   = 29
+	logoWidth  = 160
+	logoHeight = 50
+)
+
+// Images returns a pass's icon and logo PNGs at 1x and 2x, scaled from
+// the tenant logo. Wallet requires an icon, so without a usable logo the
+// icon is a plain square in the pass color and there is no logo.
+func Images(logo []byte, hexColor string) map[string][]byte {
+	src, _, err := image.Decode(bytes.NewReader(logo))
+	if err != nil || src.Bounds().Empty() {
+		fill := color.RGBA{0x1F, 0x29, 0x37, 0xFF}
+		if r, g, b, ok := parseHexColor(hexColor); ok {
+			fill = color.RGBA{r, g, b, 0xFF}
+		}
+		images := map[string][]byte{}
+		for name, size := range map[string]int{"icon.png": iconSize, "icon@2x.png": 2 * iconSize} {
+			img := image.NewRGBA(image.Rect(0, 0, size, size))
+			draw.Draw(img, img.Bounds(), &image.Uniform{fill}, image.Point{}, draw.Src)
+			images[name] = encodePNG(img)
+		}
+		return images
+	}
+	images := map[string][]byte{}
+	for scale, suffix := range map[int]string{1: "", 2: "@2x"} {
+		images["icon"+suffix+".png"] = encodePNG(fit(src, iconSize*scale, iconSize*scale, true))
+		images["logo"+suffix+".png"] = encodePNG(fit(src, logoWidth*scale, logoHeight*scale, false))
+	}
+	return images
+}
+
+// fit scales src to fit within w×h keeping its aspect ratio. A padded
+// result is exactly w×h with src centered on transparency; otherwise it
+// is the scaled size.
+func fit(src image.Image, w, h int, padded bool) image.Image {
+	b := src.Bounds()
+	sw, sh := w, b.Dy()*w/b.Dx()
+	if sh > h {
+		sw, sh = b.Dx()*h/b.Dy(), h
+	}
+	sw, sh = max(sw, 1), max(sh, 1)
+	canvas := image.Rect(0, 0, sw, sh)
+	target := canvas
+	if padded {
+		canvas = image.Rect(0, 0, w, h)
+		target = image.Rect((w-sw)/2, (h-sh)/2, (w-sw)/2+sw, (h-sh)/2+sh)
+	}
+	dst := image.NewRGBA(canvas)
+	draw.CatmullRom.Scale(dst, target, src, b, draw.Over, nil)
+	return dst
+}
+
+func encodePNG(img image.Image) []byte {
+	var buf bytes.Buffer
+	// Encoding an in-memory RGBA image cannot fail.
+	_ = png.Encode(&buf, img)
+	return buf.Bytes()
+}
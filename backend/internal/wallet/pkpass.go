@@ -0,0 +1,388 @@
+package wallet
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/smallstep/pkcs7"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// oidUserID is the subject attribute (UID) holding a pass certificate's
+// pass type identifier.
+var oidUserID = asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 1}
+
+// ErrMissingIntermediate is returned by ParseCertificate when the pass
+// certificate is not self-signed and its issuer (Apple's WWDR
+// intermediate) was not supplied: Wallet rejects passes whose signature
+// does not carry it.
+var ErrMissingIntermediate = errors.New("the certificate's issuer (the Apple WWDR intermediate) is missing")
+
+// Signer is a tenant's pass certificate: it signs .pkpass bundles and
+// authenticates pass update pushes to APNs.
+type Signer struct {
+	Certificate *x509.Certificate
+	// Chain holds the intermediates, the certificate's issuer first.
+	Chain      []*x509.Certificate
+	Key        crypto.Signer
+	PassTypeID string
+	TeamID     string
+}
+
+// ParseCertificate reads a pass certificate and its private key from a
+// PKCS#12 file (as exported from Keychain Access) or a PEM bundle, plus
+// optional intermediates (PEM or DER). The pass type identifier and team
+// come from the certificate's subject UID and OU.
+func ParseCertificate(data []byte, password string, intermediates []byte) (*Signer, error) {
+	var (
+		key   interface{}
+		leaf  *x509.Certificate
+		extra []*x509.Certificate
+		err   error
+	)
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		key, leaf, extra, err = parsePEMBundle(data)
+	} else {
+		key, leaf, extra, err = pkcs12.DecodeChain(data, password)
+		if err != nil {
+			err = fmt.Errorf("read PKCS#12 file: %w", err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	more, err := parseCertificates(intermediates)
+	if err != nil {
+		return nil, fmt.Errorf("read intermediate: %w", err)
+	}
+	extra = append(extra, more...)
+
+	s := &Signer{Certificate: leaf}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	pub, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(leaf.PublicKey) {
+		return nil, errors.New("the private key does not match the certificate")
+	}
+	s.Key = signer
+	for _, name := range leaf.Subject.Names {
+		if name.Type.Equal(oidUserID) {
+			s.PassTypeID, _ = name.Value.(string)
+		}
+	}
+	if !strings.HasPrefix(s.PassTypeID, "pass.") {
+		return nil, errors.New("not a pass type certificate: the subject has no pass.* UID")
+	}
+	if len(leaf.Subject.OrganizationalUnit) == 0 || leaf.Subject.OrganizationalUnit[0] == "" {
+		return nil, errors.New("not a pass type certificate: the subject has no team identifier (OU)")
+	}
+	s.TeamID = leaf.Subject.OrganizationalUnit[0]
+	if time.Now().After(leaf.NotAfter) {
+		return nil, fmt.Errorf("the certificate expired on %s", leaf.NotAfter.Format("2006-01-02"))
+	}
+	if !bytes.Equal(leaf.RawIssuer, leaf.RawSubject) {
+		issuer := -1
+		for i, c := range extra {
+			if leaf.CheckSignatureFrom(c) == nil {
+				issuer = i
+				break
+			}
+		}
+		if issuer < 0 {
+			return nil, ErrMissingIntermediate
+		}
+		s.Chain = append(s.Chain, extra[issuer])
+		for i, c := range extra {
+			if i != issuer && !c.Equal(leaf) {
+				s.Chain = append(s.Chain, c)
+			}
+		}
+	}
+	return s, nil
+}
+
+// LoadSigner reads a signer saved with PEM.
+func LoadSigner(pemData []byte) (*Signer, error) {
+	return ParseCertificate(pemData, "", nil)
+}
+
+// PEM encodes the certificate, its chain and its key (PKCS#8) for storage.
+func (s *Signer) PEM() ([]byte, error) {
+	var buf bytes.Buffer
+	for _, c := range append([]*x509.Certificate{s.Certificate}, s.Chain...) {
+		if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw}); err != nil {
+			return nil, err
+		}
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(s.Key)
+	if err != nil {
+		return nil, err
+	}
+	if err := pem.Encode(&buf, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func parsePEMBundle(data []byte) (interface{}, *x509.Certificate, []*x509.Certificate, error) {
+	var (
+		key   interface{}
+		certs []*x509.Certificate
+	)
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		var err error
+		switch block.Type {
+		case "CERTIFICATE":
+			var c *x509.Certificate
+			if c, err = x509.ParseCertificate(block.Bytes); err == nil {
+				certs = append(certs, c)
+			}
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		case "ENCRYPTED PRIVATE KEY":
+			err = errors.New("encrypted PEM keys are not supported; upload a PKCS#12 file instead")
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("read PEM %s: %w", strings.ToLower(block.Type), err)
+		}
+	}
+	if len(certs) == 0 {
+		return nil, nil, nil, errors.New("no certificate found")
+	}
+	if key == nil {
+		return nil, nil, nil, errors.New("no private key found")
+	}
+	return key, certs[0], certs[1:], nil
+}
+
+// parseCertificates reads PEM or DER certificates; empty input is none.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	if !bytes.Contains(data, []byte("-----BEGIN")) {
+		return x509.ParseCertificates(data)
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	return certs, nil
+}
+
+// tlsCertificate is the APNs client certificate.
+func (s *Signer) tlsCertificate() tls.Certificate {
+	cert := tls.Certificate{PrivateKey: s.Key, Leaf: s.Certificate}
+	for _, c := range append([]*x509.Certificate{s.Certificate}, s.Chain...) {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+	return cert
+}
+
+// Pass is pass.json of an event ticket (PassKit Package Format).
+type Pass struct {
+	FormatVersion       int        `json:"formatVersion"`
+	PassTypeIdentifier  string     `json:"passTypeIdentifier"`
+	SerialNumber        string     `json:"serialNumber"`
+	TeamIdentifier      string     `json:"teamIdentifier"`
+	OrganizationName    string     `json:"organizationName"`
+	Description         string     `json:"description"`
+	LogoText            string     `json:"logoText,omitempty"`
+	BackgroundColor     string     `json:"backgroundColor,omitempty"`
+	ForegroundColor     string     `json:"foregroundColor,omitempty"`
+	LabelColor          string     `json:"labelColor,omitempty"`
+	RelevantDate        string     `json:"relevantDate,omitempty"`
+	WebServiceURL       string     `json:"webServiceURL,omitempty"`
+	AuthenticationToken string     `json:"authenticationToken,omitempty"`
+	Barcodes            []Barcode  `json:"barcodes"`
+	EventTicket         PassFields `json:"eventTicket"`
+}
+
+// Barcode is a pass barcode.
+type Barcode struct {
+	Format          string `json:"format"`
+	Message         string `json:"message"`
+	MessageEncoding string `json:"messageEncoding"`
+	AltText         string `json:"altText,omitempty"`
+}
+
+// PassFields are the fields of a pass style, by placement.
+type PassFields struct {
+	PrimaryFields   []PassField `json:"primaryFields,omitempty"`
+	SecondaryFields []PassField `json:"secondaryFields,omitempty"`
+	AuxiliaryFields []PassField `json:"auxiliaryFields,omitempty"`
+	BackFields      []PassField `json:"backFields,omitempty"`
+}
+
+// PassField is one labelled value. ChangeMessage, when set, is shown in a
+// notification when an update changes the value (%@ is the new value).
+type PassField struct {
+	Key           string `json:"key"`
+	Label         string `json:"label,omitempty"`
+	Value         string `json:"value"`
+	ChangeMessage string `json:"changeMessage,omitempty"`
+}
+
+// NewPass lays the ticket out as an event ticket pass issued under the
+// signer's pass type. The barcode carries the same code as the attendee's
+// QR image.
+func (s *Signer) NewPass(t Ticket) *Pass {
+	org := t.Organization
+	if org == "" {
+		org = "Idento"
+	}
+	p := &Pass{
+		FormatVersion:      1,
+		PassTypeIdentifier: s.PassTypeID,
+		SerialNumber:       t.Serial,
+		TeamIdentifier:     s.TeamID,
+		OrganizationName:   org,
+		Description:        "Ticket for " + t.EventName,
+		LogoText:           org,
+		Barcodes: []Barcode{{
+			Format:          "PKBarcodeFormatQR",
+			Message:         t.Code,
+			MessageEncoding: "iso-8859-1",
+			AltText:         t.Code,
+		}},
+		EventTicket: PassFields{
+			PrimaryFields: []PassField{{Key: "event", Label: "EVENT", Value: t.EventName, ChangeMessage: "Event renamed to %@"}},
+			SecondaryFields: []PassField{
+				{Key: "attendee", Label: "ATTENDEE", Value: t.AttendeeName},
+				{Key: "dates", Label: "DATE", Value: t.Dates, ChangeMessage: "Event date changed to %@"},
+			},
+			AuxiliaryFields: []PassField{{Key: "location", Label: "LOCATION", Value: t.Location, ChangeMessage: "Event moved to %@"}},
+			BackFields: []PassField{
+				{Key: "code", Label: "Ticket code", Value: t.Code},
+				{Key: "organizer", Label: "Organizer", Value: org},
+			},
+		},
+	}
+	if t.Start != nil {
+		p.RelevantDate = t.Start.UTC().Format(time.RFC3339)
+	}
+	if r, g, b, ok := parseHexColor(t.Color); ok {
+		p.BackgroundColor = fmt.Sprintf("rgb(%d, %d, %d)", r, g, b)
+		fg := "rgb(255, 255, 255)"
+		if 299*int(r)+587*int(g)+114*int(b) > 150000 {
+			fg = "rgb(0, 0, 0)"
+		}
+		p.ForegroundColor, p.LabelColor = fg, fg
+	}
+	return p
+}
+
+// Package builds the signed .pkpass bundle of p and its images (file name
+// to PNG, e.g. from Images): pass.json, the images, manifest.json with
+// every file's SHA-1, and signature, a detached PKCS#7 signature of the
+// manifest that carries the certificate chain.
+func (s *Signer) Package(p *Pass, images map[string][]byte) ([]byte, error) {
+	passJSON, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{"pass.json": passJSON}
+	for name, data := range images {
+		files[name] = data
+	}
+	manifest := make(map[string]string, len(files))
+	for name, data := range files {
+		sum := sha1.Sum(data)
+		manifest[name] = hex.EncodeToString(sum[:])
+	}
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := s.sign(manifestJSON)
+	if err != nil {
+		return nil, fmt.Errorf("sign manifest: %w", err)
+	}
+	files["manifest.json"] = manifestJSON
+	files["signature"] = signature
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Signer) sign(manifest []byte) ([]byte, error) {
+	sd, err := pkcs7.NewSignedData(manifest)
+	if err != nil {
+		return nil, err
+	}
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err := sd.AddSignerChain(s.Certificate, s.Key, s.Chain, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, err
+	}
+	sd.Detach()
+	return sd.Finish()
+}
+
+// parseHexColor reads #RRGGBB.
+func parseHexColor(s string) (r, g, b uint8, ok bool) {
+	if len(s) != 7 || s[0] != '#' {
+		return 0, 0, 0, false
+	}
+	v, err := hex.DecodeString(s[1:])
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return v[0], v[1], v[2], true
+}
@@ -0,0 +1,77 @@
+// Package wallet issues attendee tickets as Apple Wallet passes (.pkpass
+// bundles signed with a tenant's pass certificate, kept current through
+// the PassKit web service and APNs pushes) and Google Wallet "save" links
+// (JWTs signed with a tenant's service account). It knows nothing about
+// storage; the handler supplies the ticket and the credentials.
+package wallet
+
+import (
+	"crypto/x509"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultAPNsURL is Apple's production push gateway. Pass updates are
+	// always pushed through production, even for development passes.
+	DefaultAPNsURL = "https://api.push.apple.com"
+	// DefaultGoogleAPIURL is the Google Wallet REST API.
+	DefaultGoogleAPIURL = "https://walletobjects.googleapis.com"
+
+	requestTimeout = 10 * time.Second
+	// maxResponseBytes caps what we read from Apple or Google.
+	maxResponseBytes = 1 << 20
+)
+
+// Ticket is what a wallet pass shows for one attendee: the event, the
+// holder, and Code, the check-in payload GetAttendeeQR encodes.
+type Ticket struct {
+	EventID      string
+	Serial       string // the attendee ID
+	Code         string
+	AttendeeName string
+	EventName    string
+	Dates        string // the event's dates as printed on the ticket
+	Start, End   *time.Time
+	Location     string
+	Organization string
+	LogoURL      string // https only; Google fetches it itself
+	Color        string // #RRGGBB background
+}
+
+// Client talks to APNs and the Google Wallet API. The zero value uses the
+// production endpoints; tests point them at local servers.
+type Client struct {
+	APNsURL      string
+	GoogleAPIURL string
+	// RootCAs verifies the APNs server; nil uses the system roots.
+	RootCAs *x509.CertPool
+	// HTTP makes Google requests; nil uses a client with requestTimeout.
+	HTTP *http.Client
+}
+
+// NewClient returns a client for the production endpoints.
+func NewClient() *Client {
+	return &Client{}
+}
+
+func (c *Client) apnsURL() string {
+	if c.APNsURL == "" {
+		return DefaultAPNsURL
+	}
+	return c.APNsURL
+}
+
+func (c *Client) googleAPIURL() string {
+	if c.GoogleAPIURL == "" {
+		return DefaultGoogleAPIURL
+	}
+	return c.GoogleAPIURL
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTP == nil {
+		return &http.Client{Timeout: requestTimeout}
+	}
+	return c.HTTP
+}
@@ -0,0 +1,299 @@
+package wallet
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"idento/backend/internal/wallet/wallettest"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/smallstep/pkcs7"
+)
+
+const testPassType = "pass.com.example.idento"
+
+func testTicket() Ticket {
+	start := time.Date(2027, 5, 1, 9, 0, 0, 0, time.UTC)
+	end := start.Add(48 * time.Hour)
+	return Ticket{
+		EventID:      "8c5e7c1e-0d3e-4a8e-9e43-0b5c8f1f7a10",
+		Serial:       "0f4b7f5e-3c1a-4e59-a4ad-3a9a1bd2a6c1",
+		Code:         "ABC123",
+		AttendeeName: "Ada Lovelace",
+		EventName:    "Tech Summit",
+		Dates:        "2027-05-01 – 2027-05-03",
+		Start:        &start,
+		End:          &end,
+		Location:     "Main Hall",
+		Organization: "Acme",
+		LogoURL:      "https://cdn.example.com/logo.png",
+		Color:        "#0055AA",
+	}
+}
+
+func newSigner(t *testing.T, intermediate bool) (*Signer, *wallettest.Certificate) {
+	t.Helper()
+	cert, err := wallettest.NewCertificate(testPassType, "TEAM123456", intermediate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ParseCertificate(cert.PEM(), "", cert.IssuerPEM())
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	return s, cert
+}
+
+func TestParseCertificate(t *testing.T) {
+	cert, err := wallettest.NewCertificate(testPassType, "TEAM123456", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p12, err := cert.PKCS12("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ParseCertificate(p12, "secret", cert.IssuerPEM())
+	if err != nil {
+		t.Fatalf("PKCS#12: %v", err)
+	}
+	if s.PassTypeID != testPassType || s.TeamID != "TEAM123456" || len(s.Chain) != 1 || !s.Chain[0].Equal(cert.Issuer) {
+		t.Errorf("signer = %s %s chain %d", s.PassTypeID, s.TeamID, len(s.Chain))
+	}
+
+	// Stored as PEM and loaded back, intermediate included.
+	saved, err := s.PEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSigner(saved)
+	if err != nil || !loaded.Certificate.Equal(s.Certificate) || len(loaded.Chain) != 1 {
+		t.Fatalf("LoadSigner = %+v, %v", loaded, err)
+	}
+
+	if _, err := ParseCertificate(p12, "wrong", cert.IssuerPEM()); err == nil {
+		t.Error("wrong password accepted")
+	}
+	if _, err := ParseCertificate(p12, "secret", nil); !errors.Is(err, ErrMissingIntermediate) {
+		t.Errorf("without the intermediate: err = %v", err)
+	}
+	other, err := wallettest.NewCertificate(testPassType, "TEAM123456", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mismatched := append(pemCert(cert), []byte(strings.SplitAfter(string(other.PEM()), "-----END CERTIFICATE-----\n")[1])...)
+	if _, err := ParseCertificate(mismatched, "", cert.IssuerPEM()); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("mismatched key: err = %v", err)
+	}
+	notPass, err := wallettest.NewCertificate("com.example.web", "TEAM123456", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseCertificate(notPass.PEM(), "", nil); err == nil {
+		t.Error("a certificate without a pass type UID was accepted")
+	}
+}
+
+func pemCert(c *wallettest.Certificate) []byte {
+	return []byte(strings.SplitAfter(string(c.PEM()), "-----END CERTIFICATE-----\n")[0])
+}
+
+// A pass bundle verifies against the certificate's root, and its manifest
+// lists every other file's SHA-1.
+func TestPackage(t *testing.T) {
+	for _, intermediate := range []bool{false, true} {
+		s, cert := newSigner(t, intermediate)
+		p := s.NewPass(testTicket())
+		p.WebServiceURL = "https://api.example.com/passkit"
+		p.AuthenticationToken = strings.Repeat("t", 32)
+		data, err := s.Package(p, Images(nil, "#0055AA"))
+		if err != nil {
+			t.Fatalf("Package: %v", err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := map[string][]byte{}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[f.Name], _ = io.ReadAll(rc)
+			rc.Close()
+		}
+		var names []string
+		for name := range files {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		if want := []string{"icon.png", "icon@2x.png", "manifest.json", "pass.json", "signature"}; !slices.Equal(names, want) {
+			t.Fatalf("bundle holds %v, want %v", names, want)
+		}
+
+		var manifest map[string]string
+		if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+			t.Fatal(err)
+		}
+		if len(manifest) != 3 {
+			t.Errorf("manifest lists %d files, want 3", len(manifest))
+		}
+		for name, sum := range manifest {
+			got := sha1.Sum(files[name])
+			if hex.EncodeToString(got[:]) != sum {
+				t.Errorf("manifest hash of %s is wrong", name)
+			}
+		}
+
+		p7, err := pkcs7.Parse(files["signature"])
+		if err != nil {
+			t.Fatalf("parse signature: %v", err)
+		}
+		p7.Content = files["manifest.json"]
+		if err := p7.VerifyWithChain(cert.Roots()); err != nil {
+			t.Errorf("intermediate=%v: signature does not verify: %v", intermediate, err)
+		}
+
+		var pass map[string]interface{}
+		if err := json.Unmarshal(files["pass.json"], &pass); err != nil {
+			t.Fatal(err)
+		}
+		barcode := pass["barcodes"].([]interface{})[0].(map[string]interface{})
+		if pass["passTypeIdentifier"] != testPassType || pass["teamIdentifier"] != "TEAM123456" ||
+			pass["serialNumber"] != testTicket().Serial || barcode["message"] != "ABC123" ||
+			pass["relevantDate"] != "2027-05-01T09:00:00Z" || pass["backgroundColor"] != "rgb(0, 85, 170)" {
+			t.Errorf("pass.json = %s", files["pass.json"])
+		}
+	}
+}
+
+func TestImages(t *testing.T) {
+	var logo bytes.Buffer
+	if err := png.Encode(&logo, image.NewGray(image.Rect(0, 0, 400, 100))); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]image.Point{
+		"icon.png": {29, 29}, "icon@2x.png": {58, 58},
+		"logo.png": {160, 40}, "logo@2x.png": {320, 80},
+	}
+	images := Images(logo.Bytes(), "")
+	if len(images) != len(want) {
+		t.Fatalf("images = %d, want %d", len(images), len(want))
+	}
+	for name, size := range want {
+		cfg, err := png.DecodeConfig(bytes.NewReader(images[name]))
+		if err != nil || cfg.Width != size.X || cfg.Height != size.Y {
+			t.Errorf("%s: %dx%d, %v; want %v", name, cfg.Width, cfg.Height, err, size)
+		}
+	}
+	if images := Images([]byte("not an image"), "#0055AA"); len(images) != 2 || images["icon.png"] == nil {
+		t.Errorf("without a logo: %d images", len(images))
+	}
+}
+
+func TestPush(t *testing.T) {
+	s, _ := newSigner(t, false)
+	apns := wallettest.NewAPNs()
+	defer apns.Close()
+	apns.SetGone("removed")
+	c := &Client{APNsURL: apns.URL, RootCAs: apns.RootCAs()}
+
+	res, err := c.Push(context.Background(), s, []string{"device-a", "removed", "device-b"})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if res.Sent != 2 || !slices.Equal(res.Gone, []string{"removed"}) {
+		t.Errorf("result = %+v", res)
+	}
+	pushes := apns.Pushes()
+	if len(pushes) != 3 {
+		t.Fatalf("%d pushes, want 3", len(pushes))
+	}
+	for _, p := range pushes {
+		if p.Topic != testPassType || !p.Client.Equal(s.Certificate) {
+			t.Errorf("push %+v: want topic %s with the pass certificate", p, testPassType)
+		}
+	}
+}
+
+func TestGoogleSaveURL(t *testing.T) {
+	keyFile, key, err := wallettest.NewServiceAccount("issuer@idento-test.iam.gserviceaccount.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := ParseServiceAccount(keyFile)
+	if err != nil {
+		t.Fatalf("ParseServiceAccount: %v", err)
+	}
+	link, err := a.SaveURL("3388000000012345678", testTicket(), []string{"https://app.example.com"})
+	if err != nil {
+		t.Fatalf("SaveURL: %v", err)
+	}
+	raw, ok := strings.CutPrefix(link, "https://pay.google.com/gp/v/save/")
+	if !ok {
+		t.Fatalf("link = %s", link)
+	}
+	claims := jwt.MapClaims{}
+	tok, err := jwt.ParseWithClaims(raw, claims, func(*jwt.Token) (interface{}, error) { return &key.PublicKey, nil },
+		jwt.WithValidMethods([]string{"RS256"}), jwt.WithAudience("google"))
+	if err != nil || tok.Header["kid"] != "wallettest" {
+		t.Fatalf("JWT does not verify: %v", err)
+	}
+	payload := claims["payload"].(map[string]interface{})
+	class := payload["eventTicketClasses"].([]interface{})[0].(map[string]interface{})
+	object := payload["eventTicketObjects"].([]interface{})[0].(map[string]interface{})
+	classID := GoogleClassID("3388000000012345678", testTicket().EventID)
+	if class["id"] != classID || object["classId"] != classID || object["barcode"].(map[string]interface{})["value"] != "ABC123" {
+		t.Errorf("payload = %v", payload)
+	}
+	if claims["typ"] != "savetowallet" || claims["iss"] != a.Email {
+		t.Errorf("claims = %v", claims)
+	}
+
+	for _, bad := range []string{`{}`, `not json`, `{"type":"service_account","client_email":"a@b","private_key":"junk"}`} {
+		if _, err := ParseServiceAccount([]byte(bad)); err == nil {
+			t.Errorf("%s: accepted", bad)
+		}
+	}
+}
+
+func TestUpdateGoogleClass(t *testing.T) {
+	google := wallettest.NewGoogle()
+	defer google.Close()
+	keyFile, _, err := wallettest.NewServiceAccount("issuer@idento-test.iam.gserviceaccount.com", google.URL+"/token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := ParseServiceAccount(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{GoogleAPIURL: google.URL}
+	ticket := testTicket()
+	if err := c.UpdateGoogleClass(context.Background(), a, "3388000000012345678", ticket); !errors.Is(err, ErrGoogleClassNotFound) {
+		t.Fatalf("unsaved class: err = %v", err)
+	}
+	classID := GoogleClassID("3388000000012345678", ticket.EventID)
+	google.Save(classID)
+	ticket.EventName = "Tech Summit 2027"
+	if err := c.UpdateGoogleClass(context.Background(), a, "3388000000012345678", ticket); err != nil {
+		t.Fatalf("UpdateGoogleClass: %v", err)
+	}
+	name := google.Class(classID)["eventName"].(map[string]interface{})["defaultValue"].(map[string]interface{})["value"]
+	if name != "Tech Summit 2027" {
+		t.Errorf("class event name = %v", name)
+	}
+}
@@ -0,0 +1,280 @@
+// Package wallettest makes wallet passes testable offline: self-signed
+// pass type certificates (optionally issued by a stand-in for Apple's WWDR
+// intermediate), Google service account keys, and local stand-ins for APNs
+// and the Google Wallet API. cmd/wallet_devcert uses it to create
+// credentials for trying passes without Apple or Google accounts.
+package wallettest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+var oidUserID = asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 1}
+
+// Certificate is a pass type certificate and its key. Issuer is nil for a
+// self-signed one.
+type Certificate struct {
+	Cert   *x509.Certificate
+	Key    *rsa.PrivateKey
+	Issuer *x509.Certificate
+}
+
+// NewCertificate creates a pass type certificate for passTypeID and team,
+// valid for a year. With intermediate it is issued by a generated
+// "WWDR" CA, like the ones Apple hands out; otherwise it is self-signed.
+func NewCertificate(passTypeID, teamID string, intermediate bool) (*Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(now.UnixNano()),
+		Subject: pkix.Name{
+			CommonName:         "Pass Type ID: " + passTypeID,
+			OrganizationalUnit: []string{teamID},
+			Organization:       []string{"Idento Test"},
+			ExtraNames:         []pkix.AttributeTypeAndValue{{Type: oidUserID, Value: passTypeID}},
+		},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.AddDate(1, 0, 0),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	c := &Certificate{Key: key}
+	parent, signer := tmpl, key
+	if intermediate {
+		caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		caTmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(now.UnixNano() + 1),
+			Subject:               pkix.Name{CommonName: "Test Worldwide Developer Relations CA", OrganizationalUnit: []string{"G4"}},
+			NotBefore:             now.Add(-time.Hour),
+			NotAfter:              now.AddDate(5, 0, 0),
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+		if err != nil {
+			return nil, err
+		}
+		if c.Issuer, err = x509.ParseCertificate(der); err != nil {
+			return nil, err
+		}
+		parent, signer = c.Issuer, caKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer)
+	if err != nil {
+		return nil, err
+	}
+	if c.Cert, err = x509.ParseCertificate(der); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// PEM is the certificate followed by its PKCS#1 key.
+func (c *Certificate) PEM() []byte {
+	out := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Cert.Raw})
+	return append(out, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(c.Key)})...)
+}
+
+// IssuerPEM is the intermediate, or nil for a self-signed certificate.
+func (c *Certificate) IssuerPEM() []byte {
+	if c.Issuer == nil {
+		return nil
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Issuer.Raw})
+}
+
+// PKCS12 exports the certificate and key as Keychain Access would,
+// without the intermediate.
+func (c *Certificate) PKCS12(password string) ([]byte, error) {
+	return pkcs12.Modern.Encode(c.Key, c.Cert, nil, password)
+}
+
+// Roots trusts the certificate's root: the intermediate, or the
+// certificate itself.
+func (c *Certificate) Roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	if c.Issuer != nil {
+		pool.AddCert(c.Issuer)
+	} else {
+		pool.AddCert(c.Cert)
+	}
+	return pool
+}
+
+// NewServiceAccount creates a Google service account key file for email
+// whose token_uri is tokenURI (the Google stand-in's, or "" for Google's).
+func NewServiceAccount(email, tokenURI string) ([]byte, *rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	f := map[string]string{
+		"type":           "service_account",
+		"project_id":     "idento-test",
+		"private_key_id": "wallettest",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   email,
+	}
+	if tokenURI != "" {
+		f["token_uri"] = tokenURI
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	return data, key, err
+}
+
+// Push is one update push APNs received.
+type Push struct {
+	Topic  string
+	Token  string
+	Client *x509.Certificate
+}
+
+// APNs is a local push gateway over HTTP/2 and TLS that requires a client
+// certificate. Tokens in Gone answer 410 like uninstalled passes.
+type APNs struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	pushes []Push
+	gone   map[string]bool
+}
+
+// NewAPNs starts the gateway; Close it when done.
+func NewAPNs() *APNs {
+	a := &APNs{gone: map[string]bool{}}
+	a.Server = httptest.NewUnstartedServer(http.HandlerFunc(a.serve))
+	a.EnableHTTP2 = true
+	a.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	a.StartTLS()
+	return a
+}
+
+// RootCAs trusts the gateway's certificate.
+func (a *APNs) RootCAs() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(a.Certificate())
+	return pool
+}
+
+// SetGone makes pushes to token answer 410 Unregistered.
+func (a *APNs) SetGone(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.gone[token] = true
+}
+
+// Pushes returns the pushes received so far.
+func (a *APNs) Pushes() []Push {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Push(nil), a.pushes...)
+}
+
+func (a *APNs) serve(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.URL.Path, "/3/device/")
+	if !ok || r.Method != http.MethodPost || r.ProtoMajor != 2 || r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"reason":"BadPath"}`)
+		return
+	}
+	a.mu.Lock()
+	a.pushes = append(a.pushes, Push{Topic: r.Header.Get("apns-topic"), Token: token, Client: r.TLS.PeerCertificates[0]})
+	gone := a.gone[token]
+	a.mu.Unlock()
+	if gone {
+		w.WriteHeader(http.StatusGone)
+		_, _ = io.WriteString(w, `{"reason":"Unregistered"}`)
+	}
+}
+
+// Google stands in for Google's token endpoint (/token) and the Wallet
+// API's event ticket classes. Classes must be Saved before they can be
+// updated, as when an attendee saved a pass.
+type Google struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	classes map[string]map[string]interface{}
+}
+
+// NewGoogle starts the stand-in; Close it when done.
+func NewGoogle() *Google {
+	g := &Google{classes: map[string]map[string]interface{}{}}
+	g.Server = httptest.NewServer(http.HandlerFunc(g.serve))
+	return g
+}
+
+// Save creates a class, as a first "Add to Google Wallet" does.
+func (g *Google) Save(classID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.classes[classID] = map[string]interface{}{"id": classID}
+}
+
+// Class returns the class's current fields, or nil.
+func (g *Google) Class(classID string) map[string]interface{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.classes[classID]
+}
+
+func (g *Google) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/token" {
+		if r.PostFormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || r.PostFormValue("assertion") == "" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"access_token":"wallettest-token","token_type":"Bearer","expires_in":3600}`)
+		return
+	}
+	id, ok := strings.CutPrefix(r.URL.Path, "/walletobjects/v1/eventTicketClass/")
+	if !ok || r.Method != http.MethodPatch || r.Header.Get("Authorization") != "Bearer wallettest-token" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	var patch map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	class, found := g.classes[id]
+	if !found {
+		http.Error(w, `{"error":{"code":404}}`, http.StatusNotFound)
+		return
+	}
+	for k, v := range patch {
+		class[k] = v
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(class)
+}
@@ -3,14 +3,23 @@ package main
 import (
 	"context"
 	_ "embed"
+	"errors"
+	"fmt"
 	"idento/backend/internal/bootstrap"
-	"idento/backend/internal/broker"
 	"idento/backend/internal/config"
 	"idento/backend/internal/handler"
+	"idento/backend/internal/health"
+	"idento/backend/internal/jobs"
+	"idento/backend/internal/mail"
+	"idento/backend/internal/metrics"
 	"idento/backend/internal/retention"
 	"idento/backend/internal/store"
+	"idento/backend/internal/tracing"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -31,6 +40,16 @@ var version = "dev"
 var backendOpenAPISpec string
 
 func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run starts the server and blocks until SIGINT/SIGTERM, then shuts down in
+// order: open SSE streams get a reconnect frame, the listener closes and
+// in-flight requests finish, background loops and job workers stop, and
+// finally the broker and the store close (deferred, in that order).
+func run() error {
 	// Try .env in cwd first (Docker/packaged runs), then repo root (make dev runs from backend/).
 	if err := godotenv.Load(".env"); err != nil {
 		if err := godotenv.Load("../.env"); err != nil {
@@ -40,68 +59,134 @@ func main() {
 
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
+	// `idento-backend healthcheck` asks the server already running in this
+	// container whether it is ready, for the container healthcheck.
+	if len(os.Args) > 1 && os.Args[1] == "healthcheck" {
+		os.Exit(healthcheck(cfg.Port))
+	}
+
+	// Tracing first, so the store's and broker's startup queries are
+	// already traced. TRACING_EXPORTER=none (the default) installs nothing.
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg, version)
+	if err != nil {
+		return fmt.Errorf("unable to set up tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("Tracing shutdown: %v", err)
+		}
+	}()
+
 	// Initialize Store
 	pgStore, err := store.NewPGStore(cfg.DatabaseURL)
 	if err != nil {
-		log.Fatalf("Unable to connect to database: %v\n", err)
+		return fmt.Errorf("unable to connect to database: %w", err)
 	}
 	defer pgStore.Close()
 
-	// Initialize the P4.2 live-monitor event broker (LISTEN/NOTIFY
-	// transport) — constructed right after the store, closed the same way
-	// pgStore.Close() is (plan-time fact 4): a dedicated LISTEN connection
-	// plus a small notify pool, both against the same DatabaseURL.
+	// Initialize the P4.2 live-monitor event broker — constructed right
+	// after the store, closed the same way pgStore.Close() is (plan-time
+	// fact 4). EVENT_BROKER picks the transport: LISTEN/NOTIFY (a dedicated
+	// LISTEN connection plus a small notify pool, both against the same
+	// DatabaseURL) or Redis pub/sub (see openBroker).
 	//
 	// Bounded with a 10s timeout (PR #81 bot-review round, Finding B2c):
-	// NewPGBroker's initial connect used to run against an unbounded
+	// the broker's initial connect used to run against an unbounded
 	// context.Background(), so a wedged/unreachable Postgres at boot could
 	// hang startup forever. store.NewPGStore's own Ping already refuses to
 	// boot without Postgres reachable at all — this timeout gives the
 	// broker's connect the same fail-fast-at-boot posture instead of
 	// hanging indefinitely on a reachable-but-stalled connection.
 	brokerCtx, brokerCancel := context.WithTimeout(context.Background(), 10*time.Second)
-	eventBroker, err := broker.NewPGBroker(brokerCtx, cfg.DatabaseURL)
+	eventBroker, err := openBroker(brokerCtx, cfg)
 	brokerCancel()
 	if err != nil {
-		log.Fatalf("Unable to start event broker: %v\n", err)
+		return fmt.Errorf("unable to start event broker: %w", err)
 	}
 	defer eventBroker.Close()
 
 	// Run migrations on startup (already-applied migrations are skipped and logged)
 	if err := pgStore.RunMigrations(); err != nil {
-		log.Fatalf("Migrations failed: %v", err)
+		return fmt.Errorf("migrations failed: %w", err)
 	}
 
 	if err := pgStore.EnsureSeedData(context.Background(), cfg.DeploymentMode); err != nil {
-		log.Fatalf("Seed data failed: %v", err)
+		return fmt.Errorf("seed data failed: %w", err)
 	}
 
 	if cfg.DeploymentMode == config.ModeOnPrem {
 		if err := bootstrap.OnPremAdmin(context.Background(), pgStore, cfg); err != nil {
-			log.Fatalf("Bootstrap failed: %v", err)
+			return fmt.Errorf("bootstrap failed: %w", err)
 		}
 	}
 
 	// Initialize Handler
 	h := handler.New(pgStore)
 	h.Broker = eventBroker
+	h.JobArtifactMaxBytes = int64(cfg.JobArtifactMaxMB) << 20
+	h.AllowLoopbackSSOIssuers = cfg.DeploymentMode != config.ModeSaaS
+	if cfg.SMTPHost != "" {
+		h.Mailer = mail.NewSMTPSender(mail.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		})
+	} else {
+		log.Println("SMTP_HOST not set: password reset and verification emails are logged and dropped")
+	}
+
+	// Background loops stop when loops is cancelled, after the HTTP server
+	// has drained.
+	loops, stopLoops := context.WithCancel(context.Background())
+	defer stopLoops()
 
 	// Tenant retention purge (P1.4 soft-delete): first pass a minute after
 	// boot, then daily. Logs and no-ops when retention is 0.
-	retention.Start(pgStore, cfg.TenantRetentionDays, time.Minute, 24*time.Hour)
+	retention.Start(loops, pgStore, cfg.TenantRetentionDays, time.Minute, 24*time.Hour)
+
+	// Idempotency-Key responses past IDEMPOTENCY_RETENTION_HOURS: hourly.
+	retention.StartIdempotencyPurge(loops, pgStore, config.IdempotencyRetention(), time.Hour)
+
+	// Background jobs: JOB_WORKERS workers claim queued jobs (safe across
+	// replicas) and publish progress on the event broker under the job ID.
+	// Finished jobs past JOB_RETENTION_DAYS are purged hourly.
+	jobRunner := jobs.NewRunner(pgStore, eventBroker, h.JobHandlers())
+	jobRunner.Start(loops, cfg.JobWorkers)
+	retention.StartJobPurge(loops, pgStore, time.Duration(cfg.JobRetentionDays)*24*time.Hour, time.Hour)
+
+	// Deferred ticket emails that came due get a ticket_email job: every
+	// minute, only with SMTP configured.
+	h.StartTicketEmailRetries(loops, time.Minute)
+
+	// Public API requests rejected over their key's budget: written to
+	// usage_logs every minute, and once more at shutdown.
+	h.StartAPIKeyThrottleFlush(loops, time.Minute)
+
+	// Refresh tokens a day past expiry: hourly.
+	retention.StartRefreshTokenPurge(loops, pgStore, 24*time.Hour, time.Hour)
+
+	// Password reset / email verification links a day past expiry: hourly.
+	retention.StartUserTokenPurge(loops, pgStore, 24*time.Hour, time.Hour)
 
 	// Initialize Echo
 	e := echo.New()
 
 	// Middleware
 	e.Use(middleware.Logger())
+	e.Use(tracing.Middleware())
+	e.Use(metrics.Middleware())
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: cfg.CORSAllowedOrigins,
-		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
+		AllowOrigins:  cfg.CORSAllowedOrigins,
+		AllowHeaders:  []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
+		ExposeHeaders: []string{tracing.TraceIDHeader},
 	}))
 
 	// Public utility routes (no auth) - BEFORE RegisterRoutes
@@ -113,6 +198,30 @@ func main() {
 	// Meta routes (health check, instance metadata)
 	handler.RegisterMetaRoutes(e, cfg.DeploymentMode, version)
 
+	// Liveness: this process's own loops. Readiness: everything it needs
+	// to serve, reported per component.
+	liveness := health.NewChecker().
+		Add("jobs", health.Err(jobRunner.Health))
+	readiness := health.NewChecker().
+		Add("database", health.Database(pgStore.Ping, health.SlowPing)).
+		Add("migrations", health.Migrations(pgStore.GetMigrationStatus)).
+		Add("broker", health.Err(eventBroker.Health)).
+		Add("jobs", health.Err(jobRunner.Health))
+	handler.RegisterProbeRoutes(e, liveness, readiness, cfg.MetricsToken)
+
+	// Prometheus metrics: both connection pools are read at scrape time.
+	if err := metrics.RegisterPool("store", pgStore); err != nil {
+		return fmt.Errorf("register store pool metrics: %w", err)
+	}
+	if pool, ok := eventBroker.(metrics.PoolStatter); ok {
+		if err := metrics.RegisterPool("broker", pool); err != nil {
+			return fmt.Errorf("register broker pool metrics: %w", err)
+		}
+	}
+	if !handler.RegisterMetricsRoute(e, cfg.DeploymentMode, cfg.MetricsToken) {
+		log.Println("METRICS_TOKEN not set: /metrics is disabled in saas mode")
+	}
+
 	// Version / instance metadata (public: web reads the mode before login).
 	e.GET("/api/version", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, map[string]string{"version": version})
@@ -147,5 +256,40 @@ func main() {
 	})
 
 	// Start server
-	e.Logger.Fatal(e.Start(":" + cfg.Port))
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	serverErr := make(chan error, 1)
+	go func() { serverErr <- e.Start(":" + cfg.Port) }()
+
+	var startErr error
+	select {
+	case <-signals.Done():
+		log.Println("Shutdown signal received, draining")
+	case startErr = <-serverErr:
+		log.Printf("Server stopped: %v", startErr)
+	}
+	// A second signal kills the process the default way.
+	stopSignals()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	// Streams never go idle, so Shutdown would wait on them until its
+	// deadline: end them first, each with a reconnect hint.
+	h.DrainStreams()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP shutdown: %v", err)
+	}
+
+	stopLoops()
+	h.FlushAPIKeyThrottles(shutdownCtx)
+	if err := jobRunner.Wait(shutdownCtx); err != nil {
+		log.Printf("Background jobs still running at shutdown deadline: %v", err)
+	}
+	log.Println("Shutdown complete")
+
+	if startErr != nil && !errors.Is(startErr, http.ErrServerClosed) {
+		return startErr
+	}
+	return nil
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 )
@@ -32,3 +34,22 @@ func TestBackendOpenAPISpecEmbedsRealFile(t *testing.T) {
 			"embed of the real backend/openapi.yaml (Task 1 already fixed this path)")
 	}
 }
+
+func TestHealthcheckExitCode(t *testing.T) {
+	ready := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health/ready" || !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	port := srv.URL[strings.LastIndex(srv.URL, ":")+1:]
+
+	if code := healthcheck(port); code != 0 {
+		t.Errorf("ready server: exit %d", code)
+	}
+	ready = false
+	if code := healthcheck(port); code != 1 {
+		t.Errorf("unready server: exit %d", code)
+	}
+}
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_usage_logs_api_call;
+ALTER TABLE api_keys DROP COLUMN rate_limit_per_minute;
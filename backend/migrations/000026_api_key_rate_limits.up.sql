@@ -0,0 +1,13 @@
+-- Per-key request budget for the public API-key surface. Existing keys
+-- inherit the same default new keys get when the creator leaves it unset
+-- (models.DefaultAPIKeyRateLimitPerMinute).
+ALTER TABLE api_keys
+  ADD COLUMN rate_limit_per_minute integer NOT NULL DEFAULT 60
+    CHECK (rate_limit_per_minute > 0);
+
+-- API-key usage metering writes one usage_logs row per request with
+-- resource_type = 'api_call' and resource_id = the key's id; the per-key
+-- usage endpoint filters on exactly that pair over a time window.
+CREATE INDEX IF NOT EXISTS idx_usage_logs_api_call
+  ON usage_logs (resource_id, logged_at)
+  WHERE resource_type = 'api_call';
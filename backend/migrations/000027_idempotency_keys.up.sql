@@ -0,0 +1,20 @@
+-- Stored first responses for Idempotency-Key retries (middleware.Idempotency).
+-- One row per (tenant, key): claimed with completed_at NULL while the first
+-- request runs, then filled with that request's status and body so retries
+-- replay it instead of re-executing. request_hash fingerprints method, path
+-- and body so a key reused for a different request is rejected.
+CREATE TABLE idempotency_keys (
+    tenant_id     uuid NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    key           text NOT NULL,
+    method        text NOT NULL,
+    path          text NOT NULL,
+    request_hash  text NOT NULL,
+    status_code   integer,
+    content_type  text,
+    response_body bytea,
+    created_at    timestamptz NOT NULL DEFAULT now(),
+    completed_at  timestamptz,
+    PRIMARY KEY (tenant_id, key)
+);
+
+CREATE INDEX idempotency_keys_created_at ON idempotency_keys (created_at);
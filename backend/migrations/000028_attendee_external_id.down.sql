@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_attendees_event_external_id;
+ALTER TABLE attendees DROP COLUMN IF EXISTS external_id;
@@ -0,0 +1,9 @@
+-- The registration system's own identifier for an attendee, so repeated
+-- external imports can update the row they created earlier instead of
+-- failing on it. Unique per event among live rows only: a soft-deleted
+-- attendee must not block re-importing the same person.
+ALTER TABLE attendees ADD COLUMN external_id text;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_attendees_event_external_id
+  ON attendees (event_id, external_id)
+  WHERE external_id IS NOT NULL AND deleted_at IS NULL;
@@ -0,0 +1,14 @@
+-- Saved column-mapping presets for the server-side spreadsheet import:
+-- columns maps a source header (as it appears in the uploaded file) to the
+-- attendee field it fills — a standard field name or a custom field name;
+-- an empty target drops the column. Per tenant, so every event of an
+-- organization can reuse the same registration-system export layout.
+CREATE TABLE import_mappings (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id uuid NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name text NOT NULL,
+    columns jsonb NOT NULL DEFAULT '{}'::jsonb,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (tenant_id, name)
+);
@@ -0,0 +1,42 @@
+-- Background jobs (internal/jobs): long-running imports, exports and badge
+-- print batches run by worker goroutines instead of inside one HTTP
+-- request. Workers on any replica claim queued rows with
+-- FOR UPDATE SKIP LOCKED and hold them under a lease (locked_by /
+-- locked_until) they keep extending while the job runs; a row whose lease
+-- ran out belongs to a dead worker and is claimed again. The finished
+-- result (a JSON summary) and any downloadable artifact live on the row
+-- itself until the retention purge deletes it.
+CREATE TABLE jobs (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id uuid NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    event_id uuid REFERENCES events(id) ON DELETE CASCADE,
+    created_by uuid REFERENCES users(id) ON DELETE SET NULL,
+    type text NOT NULL,
+    payload jsonb NOT NULL DEFAULT '{}'::jsonb,
+    status text NOT NULL DEFAULT 'queued'
+        CHECK (status IN ('queued', 'running', 'succeeded', 'failed')),
+    progress_done integer NOT NULL DEFAULT 0,
+    progress_total integer NOT NULL DEFAULT 0,
+    result jsonb,
+    error text,
+    artifact bytea,
+    artifact_name text,
+    artifact_content_type text,
+    attempts integer NOT NULL DEFAULT 0,
+    locked_by text,
+    locked_until timestamptz,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    started_at timestamptz,
+    finished_at timestamptz,
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- The claim query's scan: unfinished jobs, oldest first.
+CREATE INDEX idx_jobs_claimable ON jobs (created_at)
+  WHERE status IN ('queued', 'running');
+
+CREATE INDEX idx_jobs_tenant_created ON jobs (tenant_id, created_at DESC);
+
+-- The retention purge's scan.
+CREATE INDEX idx_jobs_finished ON jobs (finished_at)
+  WHERE finished_at IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tenant_audit_log;
+DROP FUNCTION IF EXISTS tenant_audit_log_append_only();
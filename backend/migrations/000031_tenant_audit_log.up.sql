@@ -0,0 +1,36 @@
+-- Tenant-level audit trail: who changed what inside an organization
+-- (attendee edits, blocks, deletions, event edits, zone access rules, staff
+-- assignments), with the field-level before/after diff in changes. Actor
+-- and event ids are deliberately not foreign keys, so the history outlives
+-- the users and events it mentions; only purging the tenant removes it.
+-- station_id is the X-Station-ID the client sent, if any.
+CREATE TABLE tenant_audit_log (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id uuid NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    event_id uuid,
+    actor_user_id uuid,
+    impersonated_by uuid,
+    station_id uuid,
+    action text NOT NULL,
+    target_type text NOT NULL,
+    target_id uuid,
+    changes jsonb NOT NULL DEFAULT '{}'::jsonb,
+    ip_address inet,
+    user_agent text,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_tenant_audit_log_tenant_created ON tenant_audit_log (tenant_id, created_at DESC);
+CREATE INDEX idx_tenant_audit_log_target ON tenant_audit_log (tenant_id, target_id);
+CREATE INDEX idx_tenant_audit_log_event ON tenant_audit_log (event_id, created_at DESC);
+
+-- Append-only: rows can be inserted, and go away with their tenant, but are
+-- never rewritten.
+CREATE FUNCTION tenant_audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'tenant_audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tenant_audit_log_no_update BEFORE UPDATE ON tenant_audit_log
+    FOR EACH ROW EXECUTE FUNCTION tenant_audit_log_append_only();
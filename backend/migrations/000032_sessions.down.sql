@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS refresh_tokens;
+ALTER TABLE stations DROP COLUMN IF EXISTS revoked_at;
+ALTER TABLE stations DROP COLUMN IF EXISTS token_version;
+ALTER TABLE users DROP COLUMN IF EXISTS token_version;
@@ -0,0 +1,29 @@
+-- Revocable sessions. Access JWTs are short-lived and carry the user's
+-- token_version (and, for a provisioned station phone, the station's);
+-- bumping a version invalidates every access token minted before it.
+-- Refresh tokens live here, hashed, and rotate on every use: each refresh
+-- revokes the presented token and issues the next one in the same family,
+-- and presenting an already-rotated token revokes the whole family.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INT NOT NULL DEFAULT 0;
+ALTER TABLE stations ADD COLUMN IF NOT EXISTS token_version INT NOT NULL DEFAULT 0;
+ALTER TABLE stations ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMPTZ;
+
+CREATE TABLE refresh_tokens (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    family_id uuid NOT NULL,
+    token_hash text NOT NULL UNIQUE,
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id uuid NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    station_id uuid REFERENCES stations(id) ON DELETE CASCADE,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    revoked_at timestamptz,
+    replaced_by uuid,
+    ip_address inet,
+    user_agent text
+);
+
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens (family_id);
+CREATE INDEX idx_refresh_tokens_user_active ON refresh_tokens (user_id) WHERE revoked_at IS NULL;
+CREATE INDEX idx_refresh_tokens_station ON refresh_tokens (station_id) WHERE station_id IS NOT NULL;
+CREATE INDEX idx_refresh_tokens_expires ON refresh_tokens (expires_at);
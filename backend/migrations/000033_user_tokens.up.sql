@@ -0,0 +1,18 @@
+-- Self-service account email flows. user_tokens holds the single-use links
+-- mailed for a password reset or an email verification, hashed like
+-- refresh tokens; a token is spent (used_at) on first use, and issuing a
+-- new one spends the user's earlier unused tokens of the same purpose.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+
+CREATE TABLE user_tokens (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose varchar(32) NOT NULL CHECK (purpose IN ('password_reset', 'email_verification')),
+    token_hash text NOT NULL UNIQUE,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_user_tokens_user_unused ON user_tokens (user_id, purpose) WHERE used_at IS NULL;
+CREATE INDEX idx_user_tokens_expires ON user_tokens (expires_at);
@@ -0,0 +1,21 @@
+-- TOTP two-factor authentication. totp_secret is written at setup and only
+-- takes effect once a first code confirms it (totp_enabled_at);
+-- totp_last_step is the last accepted time step, so a code cannot be
+-- replayed. Recovery codes are stored hashed and spent once.
+-- refresh_tokens.two_factor records that a session was signed in with a
+-- second factor, so refreshed access tokens keep the "mfa" claim the
+-- tenant two-factor policy checks.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT;
+
+CREATE TABLE user_recovery_codes (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash text NOT NULL,
+    used_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, code_hash)
+);
+
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS two_factor BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS oidc_login_requests;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS tenant_oidc_providers;
@@ -0,0 +1,50 @@
+-- Per-tenant OpenID Connect single sign-on. A tenant has at most one
+-- provider; client_secret is never returned by the API. allowed_domains
+-- limits which email addresses may sign in; role_mapping maps values of
+-- role_claim (e.g. "groups") to Idento roles, falling back to
+-- default_role.
+CREATE TABLE tenant_oidc_providers (
+    tenant_id uuid PRIMARY KEY REFERENCES tenants(id) ON DELETE CASCADE,
+    issuer text NOT NULL,
+    client_id text NOT NULL,
+    client_secret text NOT NULL,
+    allowed_domains text[] NOT NULL DEFAULT '{}',
+    role_claim text NOT NULL DEFAULT '',
+    role_mapping jsonb NOT NULL DEFAULT '{}',
+    default_role varchar(50) NOT NULL DEFAULT 'staff' CHECK (default_role IN ('admin', 'manager', 'staff')),
+    enabled boolean NOT NULL DEFAULT true,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_tenant_oidc_providers_domains ON tenant_oidc_providers USING gin (allowed_domains);
+
+-- A provider account (issuer + subject) linked to an Idento user, per
+-- tenant: the same IdP account is linked separately for each tenant that
+-- trusts the issuer.
+CREATE TABLE user_identities (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id uuid NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    issuer text NOT NULL,
+    subject text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    last_login_at timestamptz NOT NULL DEFAULT NOW(),
+    UNIQUE (tenant_id, issuer, subject)
+);
+
+CREATE INDEX idx_user_identities_user ON user_identities (user_id);
+
+-- In-flight authorization requests, keyed by the hash of the state
+-- parameter. The PKCE verifier and nonce never leave the server. Spent on
+-- the callback; expired rows are swept when new ones are created.
+CREATE TABLE oidc_login_requests (
+    state_hash text PRIMARY KEY,
+    tenant_id uuid NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    code_verifier text NOT NULL,
+    nonce text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_oidc_login_requests_expires ON oidc_login_requests (expires_at);
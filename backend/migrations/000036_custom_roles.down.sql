@@ -0,0 +1,2 @@
+ALTER TABLE user_tenants DROP COLUMN IF EXISTS custom_role_id;
+DROP TABLE IF EXISTS tenant_roles;
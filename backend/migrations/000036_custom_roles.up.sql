@@ -0,0 +1,22 @@
+-- Tenant-defined roles built from permissions (see handler/authz.go for
+-- the permission names). event_ids scopes the role to specific events;
+-- empty means every event of the tenant.
+CREATE TABLE tenant_roles (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id uuid NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name varchar(100) NOT NULL,
+    description text NOT NULL DEFAULT '',
+    permissions text[] NOT NULL DEFAULT '{}',
+    event_ids uuid[] NOT NULL DEFAULT '{}',
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_tenant_roles_name ON tenant_roles (tenant_id, lower(name));
+
+-- A member with a custom role gets exactly its permissions instead of the
+-- built-in set of their role; admins always keep every permission.
+ALTER TABLE user_tenants
+    ADD COLUMN custom_role_id uuid REFERENCES tenant_roles(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_user_tenants_custom_role ON user_tenants (custom_role_id) WHERE custom_role_id IS NOT NULL;
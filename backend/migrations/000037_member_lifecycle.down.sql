@@ -0,0 +1,7 @@
+DELETE FROM user_tokens WHERE purpose = 'invitation';
+ALTER TABLE user_tokens DROP CONSTRAINT IF EXISTS user_tokens_purpose_check;
+ALTER TABLE user_tokens ADD CONSTRAINT user_tokens_purpose_check
+    CHECK (purpose IN ('password_reset', 'email_verification'));
+
+ALTER TABLE user_tenants DROP COLUMN IF EXISTS invited_at;
+ALTER TABLE user_tenants DROP COLUMN IF EXISTS deactivated_at;
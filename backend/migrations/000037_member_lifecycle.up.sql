@@ -0,0 +1,11 @@
+-- Staff lifecycle. A membership can be deactivated (kept with its event and
+-- zone assignments, but unable to sign in to the tenant and not counted
+-- against the users limit) and reactivated. invited_at marks a membership
+-- whose account was created by an invitation and has no password yet; it
+-- clears when the invitee accepts.
+ALTER TABLE user_tenants ADD COLUMN deactivated_at timestamptz;
+ALTER TABLE user_tenants ADD COLUMN invited_at timestamptz;
+
+ALTER TABLE user_tokens DROP CONSTRAINT IF EXISTS user_tokens_purpose_check;
+ALTER TABLE user_tokens ADD CONSTRAINT user_tokens_purpose_check
+    CHECK (purpose IN ('password_reset', 'email_verification', 'invitation'));
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS ticket_deliveries;
+DROP TABLE IF EXISTS ticket_email_settings;
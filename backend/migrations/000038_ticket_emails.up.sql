@@ -0,0 +1,38 @@
+-- Ticket emails: each event may configure the email that carries an
+-- attendee's QR ticket (subject/body per locale, with {{placeholders}}),
+-- and whether an import mails the new attendees automatically.
+CREATE TABLE ticket_email_settings (
+    event_id uuid PRIMARY KEY REFERENCES events(id) ON DELETE CASCADE,
+    send_on_import boolean NOT NULL DEFAULT false,
+    qr_placement varchar(20) NOT NULL DEFAULT 'inline' CHECK (qr_placement IN ('inline', 'attachment')),
+    default_locale varchar(10) NOT NULL DEFAULT 'en',
+    locale_field text NOT NULL DEFAULT '',
+    reply_to text NOT NULL DEFAULT '',
+    templates jsonb NOT NULL DEFAULT '{}',
+    updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+-- One delivery record per attendee: the latest ticket email and its state.
+-- queued and deferred rows are sent once next_attempt_at has passed (a
+-- worker pushes it forward while sending, so a crashed send is retried);
+-- deferred means a temporary failure, failed that the retries ran out and
+-- bounced that the mail server rejected the recipient.
+CREATE TABLE ticket_deliveries (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_id uuid NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    attendee_id uuid NOT NULL UNIQUE REFERENCES attendees(id) ON DELETE CASCADE,
+    email text NOT NULL,
+    status varchar(20) NOT NULL DEFAULT 'queued'
+        CHECK (status IN ('queued', 'deferred', 'sent', 'failed', 'bounced')),
+    attempts integer NOT NULL DEFAULT 0,
+    last_error text,
+    next_attempt_at timestamptz NOT NULL DEFAULT NOW(),
+    sent_at timestamptz,
+    bounced_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_ticket_deliveries_event_status ON ticket_deliveries (event_id, status);
+CREATE INDEX idx_ticket_deliveries_due ON ticket_deliveries (next_attempt_at)
+    WHERE status IN ('queued', 'deferred');
@@ -0,0 +1,2 @@
+ALTER TABLE ticket_email_settings DROP COLUMN IF EXISTS attach_pdf;
+ALTER TABLE events DROP COLUMN IF EXISTS ticket_layout;
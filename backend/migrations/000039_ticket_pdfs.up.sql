@@ -0,0 +1,5 @@
+-- PDF e-tickets: each event may save a ticket layout (page size, accent
+-- color, font family, fields shown), and ticket emails may carry the PDF.
+ALTER TABLE events ADD COLUMN ticket_layout jsonb NULL;
+
+ALTER TABLE ticket_email_settings ADD COLUMN attach_pdf boolean NOT NULL DEFAULT false;
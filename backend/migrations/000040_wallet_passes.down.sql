@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS wallet_registrations;
+DROP TABLE IF EXISTS wallet_passes;
+DROP TABLE IF EXISTS tenant_wallet_settings;
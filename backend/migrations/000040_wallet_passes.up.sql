@@ -0,0 +1,44 @@
+-- Wallet passes. A tenant may upload an Apple pass type certificate
+-- (stored as PEM: certificate, intermediates, PKCS#8 key) and a Google
+-- Wallet issuer with its service account key; neither secret is ever
+-- returned by the API.
+CREATE TABLE tenant_wallet_settings (
+    tenant_id uuid PRIMARY KEY REFERENCES tenants(id) ON DELETE CASCADE,
+    apple_certificate text NOT NULL DEFAULT '',
+    apple_pass_type_id text NOT NULL DEFAULT '',
+    apple_team_id text NOT NULL DEFAULT '',
+    apple_expires_at timestamptz,
+    google_issuer_id text NOT NULL DEFAULT '',
+    google_service_account text NOT NULL DEFAULT '',
+    google_service_account_email text NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+-- An attendee's Apple Wallet pass, created on first download. The serial
+-- number is the attendee ID; auth_token authenticates the device's calls
+-- to the PassKit web service. updated_at moves when the event changes, so
+-- devices know to fetch the pass again.
+CREATE TABLE wallet_passes (
+    attendee_id uuid PRIMARY KEY REFERENCES attendees(id) ON DELETE CASCADE,
+    event_id uuid NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    pass_type_id text NOT NULL,
+    auth_token text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_wallet_passes_event ON wallet_passes (event_id);
+
+-- Devices that installed a pass, with the APNs token that wakes them when
+-- it changes.
+CREATE TABLE wallet_registrations (
+    device_library_id text NOT NULL,
+    attendee_id uuid NOT NULL REFERENCES wallet_passes(attendee_id) ON DELETE CASCADE,
+    push_token text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (device_library_id, attendee_id)
+);
+
+CREATE INDEX idx_wallet_registrations_attendee ON wallet_registrations (attendee_id);
+CREATE INDEX idx_wallet_registrations_push_token ON wallet_registrations (push_token);
@@ -0,0 +1,10 @@
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS artifact bytea;
+
+UPDATE jobs j
+   SET artifact = (SELECT string_agg(c.data, ''::bytea ORDER BY c.seq)
+                     FROM job_artifact_chunks c WHERE c.job_id = j.id)
+ WHERE j.artifact_size IS NOT NULL;
+
+ALTER TABLE jobs DROP COLUMN IF EXISTS artifact_size;
+
+DROP TABLE IF EXISTS job_artifact_chunks;
@@ -0,0 +1,19 @@
+-- Job artifacts move out of the jobs row into 1 MiB chunks: a worker
+-- stores them as it reads its spooled file and a download streams them
+-- back in order, so neither side ever holds a whole export in memory (a
+-- single bytea value has to be built and read in one piece).
+CREATE TABLE job_artifact_chunks (
+    job_id uuid NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    seq integer NOT NULL,
+    data bytea NOT NULL,
+    PRIMARY KEY (job_id, seq)
+);
+
+ALTER TABLE jobs ADD COLUMN artifact_size bigint;
+
+INSERT INTO job_artifact_chunks (job_id, seq, data)
+SELECT id, 0, artifact FROM jobs WHERE artifact IS NOT NULL;
+
+UPDATE jobs SET artifact_size = length(artifact) WHERE artifact IS NOT NULL;
+
+ALTER TABLE jobs DROP COLUMN artifact;
@@ -0,0 +1,14 @@
+-- Two-factor login challenges. The challenge token handed out after the
+-- password step carries one of these ids; every code tried against it
+-- counts an attempt, a challenge that signed someone in is used, and the
+-- attempts of a user's unused recent challenges add up to a lockout.
+CREATE TABLE two_factor_challenges (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    attempts integer NOT NULL DEFAULT 0,
+    used_at timestamptz,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_two_factor_challenges_user ON two_factor_challenges (user_id, created_at);
@@ -0,0 +1,3 @@
+UPDATE tenant_roles
+SET permissions = array_remove(permissions, 'zones:edit'), updated_at = NOW()
+WHERE 'zones:edit' = ANY(permissions);
@@ -0,0 +1,5 @@
+-- zones:manage no longer covers editing zones, access rules and attendee
+-- zone access; that is zones:edit now. Roles that could do it keep it.
+UPDATE tenant_roles
+SET permissions = array_append(permissions, 'zones:edit'), updated_at = NOW()
+WHERE 'zones:manage' = ANY(permissions) AND NOT 'zones:edit' = ANY(permissions);